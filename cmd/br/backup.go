@@ -37,7 +37,9 @@ func runBackupCommand(command *cobra.Command, cmdName string) error {
 		session.DisableStats4Test()
 	}
 
-	if err := task.RunBackup(ctx, tidbGlue, cmdName, &cfg); err != nil {
+	err := task.RunBackup(ctx, tidbGlue, cmdName, &cfg)
+	task.WriteReport(ctx, &cfg.Config, cmdName, err)
+	if err != nil {
 		log.Error("failed to backup", zap.Error(err))
 		return errors.Trace(err)
 	}
@@ -57,7 +59,9 @@ func runBackupRawCommand(command *cobra.Command, cmdName string) error {
 		ctx, store = trace.TracerStartSpan(ctx)
 		defer trace.TracerFinishSpan(ctx, store)
 	}
-	if err := task.RunBackupRaw(ctx, gluetikv.Glue{}, cmdName, &cfg); err != nil {
+	err := task.RunBackupRaw(ctx, gluetikv.Glue{}, cmdName, &cfg)
+	task.WriteReport(ctx, &cfg.Config, cmdName, err)
+	if err != nil {
 		log.Error("failed to backup raw kv", zap.Error(err))
 		return errors.Trace(err)
 	}
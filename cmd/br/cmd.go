@@ -18,6 +18,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/pingcap/br/pkg/gluetidb"
+	"github.com/pingcap/br/pkg/httputil"
 	"github.com/pingcap/br/pkg/redact"
 	"github.com/pingcap/br/pkg/summary"
 	"github.com/pingcap/br/pkg/task"
@@ -56,6 +57,8 @@ const (
 	FlagLogFormat = "log-format"
 	// FlagStatusAddr is the name of status-addr flag.
 	FlagStatusAddr = "status-addr"
+	// FlagStatusAuthFile is the name of status-auth-file flag.
+	FlagStatusAuthFile = "status-auth-file"
 	// FlagSlowLogFile is the name of slow-log-file flag.
 	FlagSlowLogFile = "slow-log-file"
 	// FlagRedactLog is whether to redact sensitive information in log, already deprecated by FlagRedactInfoLog
@@ -89,6 +92,9 @@ func AddFlags(cmd *cobra.Command) {
 		"Set whether to redact sensitive info in log")
 	cmd.PersistentFlags().String(FlagStatusAddr, "",
 		"Set the HTTP listening address for the status report service. Set to empty string to disable")
+	cmd.PersistentFlags().String(FlagStatusAuthFile, "",
+		"Set the TOML file authorizing the clients of the status report service by "+
+			"admin-tokens, read-only-tokens, admin-cert-cn and read-only-cert-cn. Set to empty string to allow all clients")
 	task.DefineCommonFlags(cmd.PersistentFlags())
 
 	cmd.PersistentFlags().StringP(FlagSlowLogFile, "", "",
@@ -184,11 +190,24 @@ func startPProf(cmd *cobra.Command) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	authFile, err := cmd.Flags().GetString(FlagStatusAuthFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	auth := &httputil.AuthConfig{}
+	if authFile != "" {
+		if auth, err = httputil.LoadAuthConfigFile(authFile); err != nil {
+			return errors.Trace(err)
+		}
+	}
 
 	if statusAddr != "" {
-		return utils.StartPProfListener(statusAddr, tls)
+		return utils.StartPProfListener(statusAddr, tls, auth)
+	}
+	if err := auth.Validate(tls.TLSConfig() != nil); err != nil {
+		return errors.Trace(err)
 	}
-	utils.StartDynamicPProfListener(tls)
+	utils.StartDynamicPProfListener(tls, auth)
 	return nil
 }
 
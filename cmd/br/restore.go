@@ -31,7 +31,9 @@ func runRestoreCommand(command *cobra.Command, cmdName string) error {
 		ctx, store = trace.TracerStartSpan(ctx)
 		defer trace.TracerFinishSpan(ctx, store)
 	}
-	if err := task.RunRestore(GetDefaultContext(), tidbGlue, cmdName, &cfg); err != nil {
+	err := task.RunRestore(GetDefaultContext(), tidbGlue, cmdName, &cfg)
+	task.WriteReport(ctx, &cfg.Config, cmdName, err)
+	if err != nil {
 		log.Error("failed to restore", zap.Error(err))
 		return errors.Trace(err)
 	}
@@ -51,7 +53,9 @@ func runLogRestoreCommand(command *cobra.Command) error {
 		ctx, store = trace.TracerStartSpan(ctx)
 		defer trace.TracerFinishSpan(ctx, store)
 	}
-	if err := task.RunLogRestore(GetDefaultContext(), tidbGlue, &cfg); err != nil {
+	err := task.RunLogRestore(GetDefaultContext(), tidbGlue, &cfg)
+	task.WriteReport(ctx, &cfg.Config, "Log restore", err)
+	if err != nil {
 		log.Error("failed to restore", zap.Error(err))
 		return errors.Trace(err)
 	}
@@ -73,7 +77,9 @@ func runRestoreRawCommand(command *cobra.Command, cmdName string) error {
 		ctx, store = trace.TracerStartSpan(ctx)
 		defer trace.TracerFinishSpan(ctx, store)
 	}
-	if err := task.RunRestoreRaw(GetDefaultContext(), gluetikv.Glue{}, cmdName, &cfg); err != nil {
+	err := task.RunRestoreRaw(GetDefaultContext(), gluetikv.Glue{}, cmdName, &cfg)
+	task.WriteReport(ctx, &cfg.Config, cmdName, err)
+	if err != nil {
 		log.Error("failed to restore raw kv", zap.Error(err))
 		return errors.Trace(err)
 	}
@@ -15,8 +15,10 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -51,6 +53,7 @@ func run() error {
 		compact, flagFetchMode                      *bool
 		mode, flagImportEngine, flagCleanupEngine   *string
 		cpRemove, cpErrIgnore, cpErrDestroy, cpDump *string
+		cpDumpFormat, cpConvert                     *string
 		localStoringTables                          *bool
 
 		fsUsage func()
@@ -74,7 +77,9 @@ func run() error {
 		cpRemove = fs.String("checkpoint-remove", "", "remove the checkpoint associated with the given table (value can be 'all' or '`db`.`table`')")
 		cpErrIgnore = fs.String("checkpoint-error-ignore", "", "ignore errors encoutered previously on the given table (value can be 'all' or '`db`.`table`'); may corrupt this table if used incorrectly")
 		cpErrDestroy = fs.String("checkpoint-error-destroy", "", "deletes imported data with table which has an error before (value can be 'all' or '`db`.`table`')")
-		cpDump = fs.String("checkpoint-dump", "", "dump the checkpoint information as two CSV files in the given folder")
+		cpDump = fs.String("checkpoint-dump", "", "dump the checkpoint information as three CSV files in the given folder")
+		cpDumpFormat = fs.String("checkpoint-dump-format", checkpoints.DumpFormatCSV, "format of the files written by -checkpoint-dump, values can be ['csv', 'json']")
+		cpConvert = fs.String("checkpoint-convert", "", "copy the checkpoints into the other driver, the value is a MySQL DSN if the checkpoint driver is 'file', or a file path if it is 'mysql'")
 
 		localStoringTables = fs.Bool("check-local-storage", false, "show tables that are missing local intermediate files (value can be 'all' or '`db`.`table`')")
 
@@ -125,7 +130,10 @@ func run() error {
 		return errors.Trace(checkpointErrorDestroy(ctx, cfg, tls, *cpErrDestroy))
 	}
 	if len(*cpDump) != 0 {
-		return errors.Trace(checkpointDump(ctx, cfg, *cpDump))
+		return errors.Trace(checkpointDump(ctx, cfg, *cpDump, *cpDumpFormat))
+	}
+	if len(*cpConvert) != 0 {
+		return errors.Trace(checkpointConvert(ctx, cfg, *cpConvert))
 	}
 	if *localStoringTables {
 		return errors.Trace(getLocalStoringTables(ctx, cfg))
@@ -276,7 +284,11 @@ func checkpointErrorDestroy(ctx context.Context, cfg *config.Config, tls *common
 	return errors.Trace(lastErr)
 }
 
-func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string) error {
+func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string, format string) error {
+	if format != checkpoints.DumpFormatCSV && format != checkpoints.DumpFormatJSON {
+		return errors.Errorf("invalid checkpoint dump format '%s', please choose valid option between ['csv', 'json']", format)
+	}
+
 	cpdb, err := checkpoints.OpenCheckpointsDB(ctx, cfg)
 	if err != nil {
 		return errors.Trace(err)
@@ -287,37 +299,84 @@ func checkpointDump(ctx context.Context, cfg *config.Config, dumpFolder string)
 		return errors.Trace(err)
 	}
 
-	tablesFileName := filepath.Join(dumpFolder, "tables.csv")
-	tablesFile, err := os.Create(tablesFileName)
-	if err != nil {
-		return errors.Annotatef(err, "failed to create %s", tablesFileName)
-	}
-	defer tablesFile.Close()
-
-	enginesFileName := filepath.Join(dumpFolder, "engines.csv")
-	enginesFile, err := os.Create(tablesFileName)
-	if err != nil {
-		return errors.Annotatef(err, "failed to create %s", enginesFileName)
+	for _, d := range []struct {
+		name string
+		dump func(context.Context, io.Writer) error
+	}{
+		{name: "tables", dump: cpdb.DumpTables},
+		{name: "engines", dump: cpdb.DumpEngines},
+		{name: "chunks", dump: cpdb.DumpChunks},
+	} {
+		if err := dumpCheckpointFile(ctx, filepath.Join(dumpFolder, d.name+"."+format), format, d.dump); err != nil {
+			return errors.Trace(err)
+		}
 	}
-	defer enginesFile.Close()
+	return nil
+}
 
-	chunksFileName := filepath.Join(dumpFolder, "chunks.csv")
-	chunksFile, err := os.Create(chunksFileName)
+func dumpCheckpointFile(ctx context.Context, fileName string, format string, dump func(context.Context, io.Writer) error) error {
+	file, err := os.Create(fileName)
 	if err != nil {
-		return errors.Annotatef(err, "failed to create %s", chunksFileName)
+		return errors.Annotatef(err, "failed to create %s", fileName)
 	}
-	defer chunksFile.Close()
+	defer file.Close()
 
-	if err := cpdb.DumpTables(ctx, tablesFile); err != nil {
-		return errors.Trace(err)
+	if format == checkpoints.DumpFormatJSON {
+		err = checkpoints.DumpJSON(file, func(csv io.Writer) error {
+			return dump(ctx, csv)
+		})
+	} else {
+		err = dump(ctx, file)
 	}
-	if err := cpdb.DumpEngines(ctx, enginesFile); err != nil {
+	if err != nil {
 		return errors.Trace(err)
 	}
-	if err := cpdb.DumpChunks(ctx, chunksFile); err != nil {
-		return errors.Trace(err)
+	return errors.Trace(file.Close())
+}
+
+func checkpointConvert(ctx context.Context, cfg *config.Config, target string) error {
+	switch cfg.Checkpoint.Driver {
+	case config.CheckpointDriverFile:
+		if _, err := os.Stat(cfg.Checkpoint.DSN); err != nil {
+			return errors.Annotatef(err, "failed to open checkpoint file %s", cfg.Checkpoint.DSN)
+		}
+		from := checkpoints.NewFileCheckpointsDB(cfg.Checkpoint.DSN)
+		defer from.Close()
+
+		db, err := sql.Open("mysql", target)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		to, err := checkpoints.NewMySQLCheckpointsDB(ctx, db, cfg.Checkpoint.Schema)
+		if err != nil {
+			db.Close()
+			return errors.Trace(err)
+		}
+		defer to.Close()
+
+		return errors.Trace(checkpoints.ConvertFileCheckpointsToMySQL(ctx, from, to))
+
+	case config.CheckpointDriverMySQL:
+		db, err := sql.Open("mysql", cfg.Checkpoint.DSN)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		from, err := checkpoints.NewMySQLCheckpointsDB(ctx, db, cfg.Checkpoint.Schema)
+		if err != nil {
+			db.Close()
+			return errors.Trace(err)
+		}
+		defer from.Close()
+
+		to := checkpoints.NewFileCheckpointsDB(target)
+		if err := checkpoints.ConvertMySQLCheckpointsToFile(ctx, from, to); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(to.Close())
+
+	default:
+		return errors.Errorf("converting the checkpoints of driver %s is not supported", cfg.Checkpoint.Driver)
 	}
-	return nil
 }
 
 func getLocalStoringTables(ctx context.Context, cfg *config.Config) (err2 error) {
@@ -0,0 +1,104 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package backup
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/pdutil"
+)
+
+// TableIDs returns the IDs of the backed up tables and their partitions.
+func (ss *Schemas) TableIDs() map[int64]struct{} {
+	ids := make(map[int64]struct{}, len(ss.schemas))
+	for _, schema := range ss.schemas {
+		ids[schema.tableInfo.ID] = struct{}{}
+		if schema.tableInfo.Partition != nil {
+			for _, def := range schema.tableInfo.Partition.Definitions {
+				ids[def.ID] = struct{}{}
+			}
+		}
+	}
+	return ids
+}
+
+// FilterPlacementMeta keeps the placement rules and label rules which only cover the given tables.
+func FilterPlacementMeta(
+	rules []placement.Rule, labelRules []pdutil.LabelRule, tableIDs map[int64]struct{},
+) *metautil.PlacementMeta {
+	meta := &metautil.PlacementMeta{
+		Rules:      make([]placement.Rule, 0),
+		LabelRules: make([]pdutil.LabelRule, 0),
+	}
+	inTable := func(startKeyHex, endKeyHex string) bool {
+		startID, ok := pdutil.DecodeTableIDFromKeyHex(startKeyHex)
+		if !ok {
+			return false
+		}
+		if _, ok = tableIDs[startID]; !ok {
+			return false
+		}
+		// the rule must not cross the table boundary.
+		endID, ok := pdutil.DecodeTableIDFromKeyHex(endKeyHex)
+		return ok && (endID == startID || endID == startID+1)
+	}
+	for _, rule := range rules {
+		if inTable(rule.StartKeyHex, rule.EndKeyHex) {
+			meta.Rules = append(meta.Rules, rule)
+		}
+	}
+	for _, rule := range labelRules {
+		covered := len(rule.Data) > 0
+		for _, r := range rule.Data {
+			covered = covered && inTable(r.StartKeyHex, r.EndKeyHex)
+		}
+		if covered {
+			meta.LabelRules = append(meta.LabelRules, rule)
+		}
+	}
+	return meta
+}
+
+// BackupPlacement saves the placement rules and table attributes of the backed up tables.
+func BackupPlacement(
+	ctx context.Context,
+	metaWriter *metautil.MetaWriter,
+	pdAddrs []string,
+	tlsConf *tls.Config,
+	tableIDs map[int64]struct{},
+) error {
+	var (
+		rules      []placement.Rule
+		labelRules []pdutil.LabelRule
+		err        error
+	)
+	for _, addr := range pdAddrs {
+		rules, err = pdutil.GetPlacementRules(ctx, addr, tlsConf)
+		if err != nil {
+			log.Warn("get placement rules failed", zap.String("pd", addr), zap.Error(err))
+			continue
+		}
+		labelRules, err = pdutil.GetLabelRules(ctx, addr, tlsConf)
+		if err != nil {
+			log.Warn("get label rules failed", zap.String("pd", addr), zap.Error(err))
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	meta := FilterPlacementMeta(rules, labelRules, tableIDs)
+	if len(meta.Rules) == 0 && len(meta.LabelRules) == 0 {
+		log.Info("no placement rules of the backed up tables")
+		return nil
+	}
+	return errors.Trace(metaWriter.WritePlacement(ctx, meta))
+}
@@ -31,6 +31,9 @@ const (
 	// DefaultSchemaConcurrency is the default number of the concurrent
 	// backup schema tasks.
 	DefaultSchemaConcurrency = 64
+	// DefaultStatsConcurrency is the default number of the concurrent
+	// dump stats tasks, which bounds the memory used by stats dumping.
+	DefaultStatsConcurrency = 4
 )
 
 type scheamInfo struct {
@@ -39,7 +42,6 @@ type scheamInfo struct {
 	crc64xor   uint64
 	totalKvs   uint64
 	totalBytes uint64
-	stats      *handle.JSONTable
 }
 
 // Schemas is task for backuping schemas.
@@ -88,9 +90,16 @@ func (ss *Schemas) BackupSchemas(
 	startAll := time.Now()
 	op := metautil.AppendSchema
 	metaWriter.StartWriteMetasAsync(ctx, op)
+	// statsLimiter bounds the number of tables whose stats are in memory.
+	statsLimiter := make(chan struct{}, DefaultStatsConcurrency)
 	for _, s := range ss.schemas {
 		schema := s
 		workerPool.ApplyOnErrorGroup(errg, func() error {
+			result := summary.TableResult{
+				DB:       schema.dbInfo.Name.O,
+				Table:    schema.tableInfo.Name.O,
+				Checksum: summary.ChecksumSkipped,
+			}
 			if utils.IsSysDB(schema.dbInfo.Name.L) {
 				schema.dbInfo.Name = utils.TemporaryDBName(schema.dbInfo.Name.O)
 			}
@@ -105,11 +114,15 @@ func (ss *Schemas) BackupSchemas(
 				checksumResp, err := calculateChecksum(
 					ectx, schema.tableInfo, store.GetClient(), backupTS, copConcurrency)
 				if err != nil {
+					result.Error = err.Error()
+					summary.CollectTable(result)
 					return errors.Trace(err)
 				}
 				schema.crc64xor = checksumResp.Checksum
 				schema.totalKvs = checksumResp.TotalKvs
 				schema.totalBytes = checksumResp.TotalBytes
+				result.KVs, result.Bytes = schema.totalKvs, schema.totalBytes
+				result.Checksum = summary.ChecksumCalculated
 				logger.Info("table checksum finished",
 					zap.Uint64("Crc64Xor", checksumResp.Checksum),
 					zap.Uint64("TotalKvs", checksumResp.TotalKvs),
@@ -117,12 +130,10 @@ func (ss *Schemas) BackupSchemas(
 					zap.Duration("take", time.Since(start)))
 			}
 			if statsHandle != nil {
-				jsonTable, err := statsHandle.DumpStatsToJSON(
-					schema.dbInfo.Name.String(), schema.tableInfo, nil)
-				if err != nil {
-					logger.Error("dump table stats failed", logutil.ShortError(err))
+				if err := backupTableStats(
+					ectx, metaWriter, statsHandle, schema, backupTS, statsLimiter); err != nil {
+					return errors.Trace(err)
 				}
-				schema.stats = jsonTable
 			}
 			// Send schema to metawriter
 			dbBytes, err := json.Marshal(schema.dbInfo)
@@ -133,25 +144,21 @@ func (ss *Schemas) BackupSchemas(
 			if err != nil {
 				return errors.Trace(err)
 			}
-			var statsBytes []byte
-			if schema.stats != nil {
-				statsBytes, err = json.Marshal(schema.stats)
-				if err != nil {
-					return errors.Trace(err)
-				}
-			}
 			s := &backuppb.Schema{
 				Db:         dbBytes,
 				Table:      tableBytes,
 				Crc64Xor:   schema.crc64xor,
 				TotalKvs:   schema.totalKvs,
 				TotalBytes: schema.totalBytes,
-				Stats:      statsBytes,
+			}
+			if schema.tableInfo.TiFlashReplica != nil {
+				s.TiflashReplicas = uint32(schema.tableInfo.TiFlashReplica.Count)
 			}
 
 			if err := metaWriter.Send(s, op); err != nil {
 				return errors.Trace(err)
 			}
+			summary.CollectTable(result)
 			updateCh.Inc()
 			return nil
 		})
@@ -161,9 +168,48 @@ func (ss *Schemas) BackupSchemas(
 	}
 	log.Info("backup checksum", zap.Duration("take", time.Since(startAll)))
 	summary.CollectDuration("backup checksum", time.Since(startAll))
+	if statsHandle != nil {
+		if err := metaWriter.FinishWriteStats(ctx); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	return metaWriter.FinishWriteMetas(ctx, op)
 }
 
+// backupTableStats dumps the stats of one table and writes them as a separate metafile.
+// A table whose stats cannot be dumped is skipped, and would be analyzed after restore.
+func backupTableStats(
+	ctx context.Context,
+	metaWriter *metautil.MetaWriter,
+	statsHandle *handle.Handle,
+	schema *scheamInfo,
+	backupTS uint64,
+	limiter chan struct{},
+) error {
+	select {
+	case <-ctx.Done():
+		return errors.Trace(ctx.Err())
+	case limiter <- struct{}{}:
+	}
+	defer func() { <-limiter }()
+
+	logger := log.With(
+		zap.String("db", schema.dbInfo.Name.O),
+		zap.String("table", schema.tableInfo.Name.O),
+	)
+	jsonTable, err := statsHandle.DumpStatsToJSONBySnapshot(
+		schema.dbInfo.Name.String(), schema.tableInfo, backupTS)
+	if err != nil {
+		logger.Error("dump table stats failed", logutil.ShortError(err))
+		summary.CollectInt("stats skipped tables", 1)
+		return nil
+	}
+	if jsonTable == nil {
+		return nil
+	}
+	return errors.Trace(metaWriter.WriteStats(ctx, schema.tableInfo.ID, jsonTable))
+}
+
 // Len returns the number of schemas.
 func (ss *Schemas) Len() int {
 	return len(ss.schemas)
@@ -232,8 +232,14 @@ func (s *testBackupSchemaSuite) TestBuildBackupRangeAndSchemaWithBrokenStats(c *
 
 	schemas2 := s.GetSchemasFromMeta(c, es2)
 	c.Assert(schemas2, HasLen, 1)
-	// the stats should now be filled, and other than that the result should be equivalent to the first backup.
-	c.Assert(schemas2[0].Stats, NotNil)
+	// the stats should now be written as a separate metafile,
+	// and other than that the result should be equivalent to the first backup.
+	c.Assert(schemas2[0].Stats, IsNil)
+	c.Assert(schemas2[0].StatsFile, NotNil)
+	stats, err := metautil.NewMetaReader(nil, es2).ReadStats(ctx, schemas2[0].StatsFile)
+	c.Assert(err, IsNil)
+	c.Assert(stats.TableName, Equals, "t3")
+	c.Assert(stats.Count, Equals, int64(1))
 	c.Assert(schemas2[0].Crc64Xor, Equals, schemas[0].Crc64Xor)
 	c.Assert(schemas2[0].TotalKvs, Equals, schemas[0].TotalKvs)
 	c.Assert(schemas2[0].TotalBytes, Equals, schemas[0].TotalBytes)
@@ -0,0 +1,240 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package httputil
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// Role is the permission granted to a client of an HTTP API.
+type Role int
+
+const (
+	// RoleNone grants no endpoint.
+	RoleNone Role = iota
+	// RoleReadOnly grants the endpoints which only read the states.
+	RoleReadOnly
+	// RoleAdmin grants all the endpoints.
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleNone:
+		return "none"
+	case RoleReadOnly:
+		return "read-only"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+// AuthConfig is the authorization config of an HTTP API. A client is identified
+// either by the bearer token in the Authorization header, or by the common name
+// of its verified TLS client certificate. If nothing is configured, all
+// requests are allowed.
+type AuthConfig struct {
+	AdminTokens     []string `toml:"admin-tokens" json:"-"`
+	ReadOnlyTokens  []string `toml:"read-only-tokens" json:"-"`
+	AdminCertCNs    []string `toml:"admin-cert-cn" json:"admin-cert-cn"`
+	ReadOnlyCertCNs []string `toml:"read-only-cert-cn" json:"read-only-cert-cn"`
+}
+
+// LoadAuthConfigFile loads the config from the TOML file.
+func LoadAuthConfigFile(path string) (*AuthConfig, error) {
+	cfg := &AuthConfig{}
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		return nil, errors.Annotatef(err, "failed to load the auth config %s", path)
+	}
+	return cfg, nil
+}
+
+// IsEnabled returns whether the requests are authorized.
+func (cfg *AuthConfig) IsEnabled() bool {
+	return len(cfg.AdminTokens)+len(cfg.ReadOnlyTokens) > 0 || cfg.needClientCert()
+}
+
+func (cfg *AuthConfig) needClientCert() bool {
+	return len(cfg.AdminCertCNs)+len(cfg.ReadOnlyCertCNs) > 0
+}
+
+// Validate checks the config is usable with the server, whose TLS is enabled
+// only if tlsEnabled is true.
+func (cfg *AuthConfig) Validate(tlsEnabled bool) error {
+	if cfg.needClientCert() && !tlsEnabled {
+		return errors.New("the client certificates can only be authorized with TLS enabled")
+	}
+	for _, token := range append(append([]string(nil), cfg.AdminTokens...), cfg.ReadOnlyTokens...) {
+		if len(token) == 0 {
+			return errors.New("the token must not be empty")
+		}
+	}
+	return nil
+}
+
+// ServerTLSConfig returns the TLS config of the server, which additionally
+// verifies the client certificates if they are authorized. The client
+// certificates are issued by the same CA as the server's. It returns nil if
+// tlsConfig is nil.
+func (cfg *AuthConfig) ServerTLSConfig(tlsConfig *tls.Config) *tls.Config {
+	if tlsConfig == nil || !cfg.needClientCert() {
+		return tlsConfig
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ClientCAs == nil {
+		tlsConfig.ClientCAs = tlsConfig.RootCAs
+	}
+	// the clients authorized by tokens need no certificate.
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig
+}
+
+// Authorizer checks whether the clients are allowed to access the endpoints.
+type Authorizer struct {
+	enabled bool
+	tokens  map[Role][]string
+	certCNs map[string]Role
+}
+
+// NewAuthorizer creates an Authorizer from the config.
+func NewAuthorizer(cfg *AuthConfig) *Authorizer {
+	a := &Authorizer{
+		enabled: cfg.IsEnabled(),
+		tokens: map[Role][]string{
+			RoleAdmin:    cfg.AdminTokens,
+			RoleReadOnly: cfg.ReadOnlyTokens,
+		},
+		certCNs: make(map[string]Role),
+	}
+	for _, cn := range cfg.ReadOnlyCertCNs {
+		a.certCNs[cn] = RoleReadOnly
+	}
+	// the admin role wins if a common name is configured in both.
+	for _, cn := range cfg.AdminCertCNs {
+		a.certCNs[cn] = RoleAdmin
+	}
+	return a
+}
+
+// Role returns the role of the client sending the request, and a description
+// of the client for logging.
+func (a *Authorizer) Role(req *http.Request) (Role, string) {
+	if !a.enabled {
+		return RoleAdmin, "anonymous"
+	}
+
+	role, client := RoleNone, "anonymous"
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+		role, client = a.certCNs[cn], "cert:"+cn
+	}
+	if token, ok := bearerToken(req); ok {
+		tokenRole := a.tokenRole(token)
+		if tokenRole > role {
+			role = tokenRole
+		}
+		if client == "anonymous" {
+			client = "token"
+		} else {
+			client += ",token"
+		}
+	}
+	return role, client
+}
+
+func (a *Authorizer) tokenRole(token string) Role {
+	// compare with all the tokens in constant time to not leak them.
+	role := RoleNone
+	for _, r := range []Role{RoleReadOnly, RoleAdmin} {
+		for _, t := range a.tokens[r] {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				role = r
+			}
+		}
+	}
+	return role
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	const prefix = "bearer "
+	auth := req.Header.Get("Authorization")
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len(prefix):]), true
+}
+
+// Wrap returns a handler which serves the request with h only if its client
+// has the role required by the endpoint. The rejected requests are logged for
+// auditing.
+func (a *Authorizer) Wrap(h http.Handler, required func(*http.Request) Role) http.Handler {
+	if !a.enabled {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requiredRole := required(req)
+		role, client := a.Role(req)
+		if role >= requiredRole {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		log.Warn("rejected unauthorized HTTP request",
+			zap.String("method", req.Method),
+			zap.String("path", req.URL.Path),
+			zap.String("remoteAddr", req.RemoteAddr),
+			zap.String("client", client),
+			zap.Stringer("role", role),
+			zap.Stringer("requiredRole", requiredRole))
+
+		code := http.StatusForbidden
+		if role == RoleNone {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			code = http.StatusUnauthorized
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": "the " + requiredRole.String() + " role is required",
+		})
+	})
+}
+
+// ReadOnlyForSafeMethods requires RoleReadOnly for the GET and HEAD requests,
+// and RoleAdmin for the others.
+func ReadOnlyForSafeMethods(req *http.Request) Role {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return RoleReadOnly
+	default:
+		return RoleAdmin
+	}
+}
+
+// AdminOnly requires RoleAdmin for all the requests.
+func AdminOnly(*http.Request) Role {
+	return RoleAdmin
+}
+
+// PProfRole requires RoleAdmin for the pprof endpoints which expose the
+// command line arguments or keep profiling for a while, and RoleReadOnly for
+// the other snapshots.
+func PProfRole(req *http.Request) Role {
+	switch strings.TrimPrefix(req.URL.Path, "/debug/pprof/") {
+	case "cmdline", "profile", "trace":
+		return RoleAdmin
+	default:
+		return ReadOnlyForSafeMethods(req)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package httputil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/pingcap/check"
+)
+
+func TestT(t *testing.T) {
+	TestingT(t)
+}
+
+type authSuite struct{}
+
+var _ = Suite(&authSuite{})
+
+func newRequest(method, path, token, cn string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return req
+}
+
+func (s *authSuite) TestRole(c *C) {
+	a := NewAuthorizer(&AuthConfig{
+		AdminTokens:     []string{"admin-token"},
+		ReadOnlyTokens:  []string{"read-token"},
+		AdminCertCNs:    []string{"admin", "both"},
+		ReadOnlyCertCNs: []string{"reader", "both"},
+	})
+
+	cases := []struct {
+		token  string
+		cn     string
+		role   Role
+		client string
+	}{
+		{"", "", RoleNone, "anonymous"},
+		{"admin-token", "", RoleAdmin, "token"},
+		{"read-token", "", RoleReadOnly, "token"},
+		{"wrong-token", "", RoleNone, "token"},
+		{"", "admin", RoleAdmin, "cert:admin"},
+		{"", "reader", RoleReadOnly, "cert:reader"},
+		{"", "both", RoleAdmin, "cert:both"},
+		{"", "stranger", RoleNone, "cert:stranger"},
+		{"admin-token", "reader", RoleAdmin, "cert:reader,token"},
+	}
+	for _, ca := range cases {
+		role, client := a.Role(newRequest(http.MethodGet, "/", ca.token, ca.cn))
+		c.Assert(role, Equals, ca.role, Commentf("case %+v", ca))
+		c.Assert(client, Equals, ca.client, Commentf("case %+v", ca))
+	}
+
+	req := newRequest(http.MethodGet, "/", "", "")
+	req.Header.Set("Authorization", "bearer admin-token")
+	role, _ := a.Role(req)
+	c.Assert(role, Equals, RoleAdmin)
+	req.Header.Set("Authorization", "Basic YWRtaW4tdG9rZW4=")
+	role, _ = a.Role(req)
+	c.Assert(role, Equals, RoleNone)
+
+	role, _ = NewAuthorizer(&AuthConfig{}).Role(req)
+	c.Assert(role, Equals, RoleAdmin)
+}
+
+func (s *authSuite) TestWrap(c *C) {
+	a := NewAuthorizer(&AuthConfig{
+		AdminTokens:    []string{"admin-token"},
+		ReadOnlyTokens: []string{"read-token"},
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := a.Wrap(ok, ReadOnlyForSafeMethods)
+	pprofHandler := a.Wrap(ok, PProfRole)
+
+	serve := func(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(handler, newRequest(http.MethodGet, "/tasks", "", ""))
+	c.Assert(w.Code, Equals, http.StatusUnauthorized)
+	c.Assert(w.Header().Get("WWW-Authenticate"), Equals, "Bearer")
+	c.Assert(w.Body.String(), Matches, `\{"error":"the read-only role is required"\}\n`)
+	c.Assert(serve(handler, newRequest(http.MethodGet, "/tasks", "wrong-token", "")).Code, Equals, http.StatusUnauthorized)
+	c.Assert(serve(handler, newRequest(http.MethodGet, "/tasks", "read-token", "")).Code, Equals, http.StatusOK)
+	c.Assert(serve(handler, newRequest(http.MethodPost, "/tasks", "read-token", "")).Code, Equals, http.StatusForbidden)
+	c.Assert(serve(handler, newRequest(http.MethodPost, "/tasks", "admin-token", "")).Code, Equals, http.StatusOK)
+
+	c.Assert(serve(pprofHandler, newRequest(http.MethodGet, "/debug/pprof/heap", "read-token", "")).Code, Equals, http.StatusOK)
+	c.Assert(serve(pprofHandler, newRequest(http.MethodGet, "/debug/pprof/cmdline", "read-token", "")).Code, Equals, http.StatusForbidden)
+	c.Assert(serve(pprofHandler, newRequest(http.MethodGet, "/debug/pprof/profile", "admin-token", "")).Code, Equals, http.StatusOK)
+
+	// all requests are allowed without any config.
+	handler = NewAuthorizer(&AuthConfig{}).Wrap(ok, AdminOnly)
+	c.Assert(serve(handler, newRequest(http.MethodPost, "/tasks", "", "")).Code, Equals, http.StatusOK)
+}
+
+func (s *authSuite) TestConfig(c *C) {
+	cfg := &AuthConfig{AdminCertCNs: []string{"admin"}}
+	c.Assert(cfg.IsEnabled(), IsTrue)
+	c.Assert(cfg.Validate(false), ErrorMatches, ".*only be authorized with TLS enabled")
+	c.Assert(cfg.Validate(true), IsNil)
+	c.Assert(cfg.ServerTLSConfig(nil), IsNil)
+
+	pool := x509.NewCertPool()
+	tlsConfig := &tls.Config{RootCAs: pool}
+	serverConfig := cfg.ServerTLSConfig(tlsConfig)
+	c.Assert(serverConfig.ClientAuth, Equals, tls.VerifyClientCertIfGiven)
+	c.Assert(serverConfig.ClientCAs, Equals, pool)
+	c.Assert(tlsConfig.ClientAuth, Equals, tls.NoClientCert)
+
+	cfg = &AuthConfig{ReadOnlyTokens: []string{""}}
+	c.Assert(cfg.Validate(true), ErrorMatches, "the token must not be empty")
+	c.Assert((&AuthConfig{}).IsEnabled(), IsFalse)
+	c.Assert((&AuthConfig{}).ServerTLSConfig(tlsConfig), Equals, tlsConfig)
+
+	path := filepath.Join(c.MkDir(), "auth.toml")
+	err := os.WriteFile(path, []byte(`
+admin-tokens = ["a"]
+read-only-tokens = ["b", "c"]
+read-only-cert-cn = ["reader"]
+`), 0o600)
+	c.Assert(err, IsNil)
+	cfg, err = LoadAuthConfigFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(cfg, DeepEquals, &AuthConfig{
+		AdminTokens:     []string{"a"},
+		ReadOnlyTokens:  []string{"b", "c"},
+		ReadOnlyCertCNs: []string{"reader"},
+	})
+	_, err = LoadAuthConfigFile(filepath.Join(c.MkDir(), "missing.toml"))
+	c.Assert(err, ErrorMatches, "failed to load the auth config.*")
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/verification"
 )
 
 const (
@@ -91,6 +92,9 @@ type EngineFileSize struct {
 	// MemSize is the total memory size used by the engine. This is the
 	// estimated additional size saved onto disk after calling Flush().
 	MemSize int64
+	// RemoteSize is the total file size spilled into the external storage.
+	// The spilled size is not counted in the disk quota.
+	RemoteSize int64
 	// IsImporting indicates whether the engine performing Import().
 	IsImporting bool
 }
@@ -191,6 +195,14 @@ type AbstractBackend interface {
 	// It can return nil if the content are all stored remotely.
 	EngineFileSizes() []EngineFileSize
 
+	// SpillEngine moves the files of a closed engine into the external storage
+	// to release the local disk space, and returns whether the engine is
+	// spilled. The spilled engine is loaded back when it is imported.
+	//
+	// This method is only relevant for local backend, and returns false for
+	// all other backends.
+	SpillEngine(ctx context.Context, engineUUID uuid.UUID) (bool, error)
+
 	// ResetEngine clears all written KV pairs in this opened engine.
 	ResetEngine(ctx context.Context, engineUUID uuid.UUID) error
 
@@ -198,14 +210,41 @@ type AbstractBackend interface {
 	LocalWriter(ctx context.Context, cfg *LocalWriterConfig, engineUUID uuid.UUID) (EngineWriter, error)
 
 	// CollectLocalDuplicateRows collect duplicate keys from local db. We will store the duplicate keys which
-	//  may be repeated with other keys in local data source.
-	CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error
+	//  may be repeated with other keys in local data source. The duplicate keys are reported to the conflict
+	//  keys table of the task info schema.
+	CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error
 
 	// CollectLocalDuplicateRows collect duplicate keys from remote TiKV storage. This keys may be duplicate with
-	//  the data import by other lightning.
-	CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error
+	//  the data import by other lightning. The duplicate keys are reported as the local ones.
+	CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error
+
+	// ResolveDuplicateRows resolves the duplicate rows collected by CollectLocalDuplicateRows according to
+	//  `tikv-importer.duplicate-resolution`, and returns the checksum of the KV pairs of the removed rows.
+	//  `options` must be the same as the options encoding the rows, and `locator` returns the path of the data
+	//  file where the row of the row ID is read from.
+	ResolveDuplicateRows(
+		ctx context.Context,
+		tbl table.Table,
+		tableName string,
+		options *kv.SessionOptions,
+		locator RowSourceLocator,
+	) (verification.KVChecksum, error)
+
+	// MergeExistingRows reconciles the imported rows with the rows existing in the target table before the import
+	//  when `tikv-importer.upsert` is enabled, so that an imported row replaces the existing rows with the same
+	//  primary key or unique keys, and returns the checksum of the KV pairs of the replaced rows. `options` must be
+	//  the same as the options encoding the rows.
+	MergeExistingRows(ctx context.Context, tbl table.Table, tableName string, options *kv.SessionOptions) (verification.KVChecksum, error)
+
+	// FinishMergeExistingRows removes the states kept for MergeExistingRows of the table. It is called after the
+	//  checksum of the table, so that the rows can still be merged again if lightning restarts before.
+	FinishMergeExistingRows(ctx context.Context, tbl table.Table) error
 }
 
+// RowSourceLocator returns the path of the data file where the row of the row
+// ID is read from.
+type RowSourceLocator func(rowID int64) string
+
 // Backend is the delivery target for Lightning
 type Backend struct {
 	abstract AbstractBackend
@@ -275,14 +314,21 @@ func (be Backend) FlushAll(ctx context.Context) error {
 	return be.abstract.FlushAllEngines(ctx)
 }
 
+// SpillEngine moves the files of a closed engine into the external storage,
+// and returns whether the engine is spilled.
+func (be Backend) SpillEngine(ctx context.Context, engineUUID uuid.UUID) (bool, error) {
+	return be.abstract.SpillEngine(ctx, engineUUID)
+}
+
 // CheckDiskQuota verifies if the total engine file size is below the given
 // quota. If the quota is exceeded, this method returns an array of engines,
-// which after importing can decrease the total size below quota.
+// which after importing or spilling can decrease the total size below quota.
 func (be Backend) CheckDiskQuota(quota int64) (
 	largeEngines []uuid.UUID,
 	inProgressLargeEngines int,
 	totalDiskSize int64,
 	totalMemSize int64,
+	totalRemoteSize int64,
 ) {
 	sizes := be.abstract.EngineFileSizes()
 	sort.Slice(sizes, func(i, j int) bool {
@@ -295,6 +341,11 @@ func (be Backend) CheckDiskQuota(quota int64) (
 	for _, size := range sizes {
 		totalDiskSize += size.DiskSize
 		totalMemSize += size.MemSize
+		totalRemoteSize += size.RemoteSize
+		// the engines occupying no local space (e.g. spilled) can't decrease the size.
+		if size.DiskSize+size.MemSize == 0 {
+			continue
+		}
 		if totalDiskSize+totalMemSize > quota {
 			if size.IsImporting {
 				inProgressLargeEngines++
@@ -359,12 +410,35 @@ func (be Backend) OpenEngine(ctx context.Context, config *EngineConfig, tableNam
 	}, nil
 }
 
-func (be Backend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error {
-	return be.abstract.CollectLocalDuplicateRows(ctx, tbl)
+func (be Backend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	return be.abstract.CollectLocalDuplicateRows(ctx, tbl, tableName)
+}
+
+func (be Backend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	return be.abstract.CollectRemoteDuplicateRows(ctx, tbl, tableName)
+}
+
+func (be Backend) ResolveDuplicateRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	locator RowSourceLocator,
+) (verification.KVChecksum, error) {
+	return be.abstract.ResolveDuplicateRows(ctx, tbl, tableName, options, locator)
+}
+
+func (be Backend) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+) (verification.KVChecksum, error) {
+	return be.abstract.MergeExistingRows(ctx, tbl, tableName, options)
 }
 
-func (be Backend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error {
-	return be.abstract.CollectRemoteDuplicateRows(ctx, tbl)
+func (be Backend) FinishMergeExistingRows(ctx context.Context, tbl table.Table) error {
+	return be.abstract.FinishMergeExistingRows(ctx, tbl)
 }
 
 // Close the opened engine to prepare it for importing.
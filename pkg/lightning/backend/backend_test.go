@@ -340,6 +340,7 @@ func (s *backendSuite) TestCheckDiskQuota(c *C) {
 	defer s.tearDownTest()
 
 	uuid1 := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	uuid2 := uuid.MustParse("22222222-2222-2222-2222-222222222222")
 	uuid3 := uuid.MustParse("33333333-3333-3333-3333-333333333333")
 	uuid5 := uuid.MustParse("55555555-5555-5555-5555-555555555555")
 	uuid7 := uuid.MustParse("77777777-7777-7777-7777-777777777777")
@@ -352,6 +353,11 @@ func (s *backendSuite) TestCheckDiskQuota(c *C) {
 			MemSize:     0,
 			IsImporting: false,
 		},
+		{
+			UUID:        uuid2,
+			RemoteSize:  6000,
+			IsImporting: false,
+		},
 		{
 			UUID:        uuid3,
 			DiskSize:    2000,
@@ -381,30 +387,34 @@ func (s *backendSuite) TestCheckDiskQuota(c *C) {
 	s.mockBackend.EXPECT().EngineFileSizes().Return(fileSizes).Times(4)
 
 	// No quota exceeded
-	le, iple, ds, ms := s.backend.CheckDiskQuota(30000)
+	le, iple, ds, ms, rs := s.backend.CheckDiskQuota(30000)
 	c.Assert(le, HasLen, 0)
 	c.Assert(iple, Equals, 0)
 	c.Assert(ds, Equals, int64(9000))
 	c.Assert(ms, Equals, int64(16000))
+	c.Assert(rs, Equals, int64(6000))
 
 	// Quota exceeded, the largest one is out
-	le, iple, ds, ms = s.backend.CheckDiskQuota(20000)
+	le, iple, ds, ms, rs = s.backend.CheckDiskQuota(20000)
 	c.Assert(le, DeepEquals, []uuid.UUID{uuid9})
 	c.Assert(iple, Equals, 0)
 	c.Assert(ds, Equals, int64(9000))
 	c.Assert(ms, Equals, int64(16000))
+	c.Assert(rs, Equals, int64(6000))
 
 	// Quota exceeded, the importing one should be ranked least priority
-	le, iple, ds, ms = s.backend.CheckDiskQuota(12000)
+	le, iple, ds, ms, rs = s.backend.CheckDiskQuota(12000)
 	c.Assert(le, DeepEquals, []uuid.UUID{uuid5, uuid9})
 	c.Assert(iple, Equals, 0)
 	c.Assert(ds, Equals, int64(9000))
 	c.Assert(ms, Equals, int64(16000))
+	c.Assert(rs, Equals, int64(6000))
 
-	// Quota exceeded, the importing ones should not be visible
-	le, iple, ds, ms = s.backend.CheckDiskQuota(5000)
+	// Quota exceeded, the importing ones and the spilled one should not be visible
+	le, iple, ds, ms, rs = s.backend.CheckDiskQuota(5000)
 	c.Assert(le, DeepEquals, []uuid.UUID{uuid1, uuid5, uuid9})
 	c.Assert(iple, Equals, 1)
 	c.Assert(ds, Equals, int64(9000))
 	c.Assert(ms, Equals, int64(16000))
+	c.Assert(rs, Equals, int64(6000))
 }
@@ -35,6 +35,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/tikv"
+	"github.com/pingcap/br/pkg/lightning/verification"
 	"github.com/pingcap/br/pkg/version"
 )
 
@@ -102,6 +103,16 @@ func NewImporter(ctx context.Context, tls *common.TLS, importServerAddr string,
 	}), nil
 }
 
+// NewCheckOnlyImporter creates an *unconnected* importer for the pre-checks,
+// which only checks the requirements, fetches the table models and encodes the
+// rows. It must not be used to import data.
+func NewCheckOnlyImporter(tls *common.TLS, pdAddr string) backend.Backend {
+	return backend.MakeBackend(&importer{
+		pdAddr: pdAddr,
+		tls:    tls,
+	})
+}
+
 // NewMockImporter creates an *unconnected* importer based on a custom
 // ImportKVClient. This is provided for testing only. Do not use this function
 // outside of tests.
@@ -225,14 +236,37 @@ func (importer *importer) CleanupEngine(ctx context.Context, engineUUID uuid.UUI
 	return errors.Trace(err)
 }
 
-func (importer *importer) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (importer *importer) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	panic("Unsupported Operation")
+}
+
+func (importer *importer) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
 	panic("Unsupported Operation")
 }
 
-func (importer *importer) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (importer *importer) ResolveDuplicateRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	locator backend.RowSourceLocator,
+) (verification.KVChecksum, error) {
 	panic("Unsupported Operation")
 }
 
+func (importer *importer) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+) (verification.KVChecksum, error) {
+	panic("Unsupported Operation")
+}
+
+func (importer *importer) FinishMergeExistingRows(ctx context.Context, tbl table.Table) error {
+	return nil
+}
+
 func (importer *importer) WriteRows(
 	ctx context.Context,
 	engineUUID uuid.UUID,
@@ -374,6 +408,10 @@ func (importer *importer) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+func (importer *importer) SpillEngine(context.Context, uuid.UUID) (bool, error) {
+	return false, nil
+}
+
 func (importer *importer) FlushEngine(context.Context, uuid.UUID) error {
 	return nil
 }
@@ -14,6 +14,7 @@
 package kv
 
 import (
+	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/table"
@@ -21,6 +22,7 @@ import (
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/types"
 
+	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/metric"
 )
 
@@ -47,6 +49,39 @@ func (t *TableKVDecoder) DecodeRawRowData(h kv.Handle, value []byte) ([]types.Da
 	return tables.DecodeRawRowData(t.se, t.tbl.Meta(), h, t.tbl.Cols(), value)
 }
 
+// EncodeRawRow decodes the raw row data of the handle and encodes the row back
+// into the KV pairs of the record and all its indices, i.e. the KV pairs
+// written when the row was imported.
+func (t *TableKVDecoder) EncodeRawRow(h kv.Handle, value []byte) ([]types.Datum, []common.KvPair, error) {
+	row, _, err := t.DecodeRawRowData(h, value)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	record := row
+	if common.TableHasAutoRowID(t.tbl.Meta()) {
+		record = append(row[:len(row):len(row)], types.NewIntDatum(h.IntValue()))
+	}
+	if _, err = t.tbl.AddRecord(t.se, record); err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	kvPairs := t.se.takeKvPairs()
+	pairs := make([]common.KvPair, 0, len(kvPairs.pairs))
+	for _, pair := range kvPairs.pairs {
+		pairs = append(pairs, common.KvPair{
+			Key: append([]byte{}, pair.Key...),
+			Val: append([]byte{}, pair.Val...),
+		})
+	}
+	kvPairs.Clear()
+	return row, pairs, nil
+}
+
+// Close releases the buffers of the decoder.
+func (t *TableKVDecoder) Close() {
+	t.se.Close()
+	metric.KvEncoderCounter.WithLabelValues("closed").Inc()
+}
+
 func NewTableKVDecoder(tbl table.Table, options *SessionOptions) (*TableKVDecoder, error) {
 	metric.KvEncoderCounter.WithLabelValues("open").Inc()
 	se := newSession(options)
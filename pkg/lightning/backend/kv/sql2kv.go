@@ -320,6 +320,7 @@ func (kvcodec *tableKVEncoder) Encode(
 	row []types.Datum,
 	rowID int64,
 	columnPermutation []int,
+	_ string,
 	offset int64,
 ) (Row, error) {
 	cols := kvcodec.tbl.Cols()
@@ -342,7 +343,9 @@ func (kvcodec *tableKVEncoder) Encode(
 		isAutoIncCol := mysql.HasAutoIncrementFlag(col.Flag)
 		isPk := mysql.HasPriKeyFlag(col.Flag)
 		switch {
-		case j >= 0 && j < len(row):
+		// a MinNotNull datum marks a missing value, e.g. a missing key of a
+		// JSON object, which is filled as if the column isn't provided.
+		case j >= 0 && j < len(row) && row[j].Kind() != types.KindMinNotNull:
 			value, err = table.CastValue(kvcodec.se, row[j], col.ToInfo(), false, false)
 			if err == nil {
 				err = col.HandleBadNull(&value, kvcodec.se.vars.StmtCtx)
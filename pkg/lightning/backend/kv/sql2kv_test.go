@@ -89,7 +89,7 @@ func (s *kvSuite) TestEncode(c *C) {
 		Timestamp: 1234567890,
 	})
 	c.Assert(err, IsNil)
-	pairs, err := strictMode.Encode(logger, rows, 1, []int{0, 1}, 1234)
+	pairs, err := strictMode.Encode(logger, rows, 1, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, ErrorMatches, "failed to cast value as tinyint\\(4\\) for column `c1` \\(#1\\):.*overflows tinyint")
 	c.Assert(pairs, IsNil)
 
@@ -97,14 +97,14 @@ func (s *kvSuite) TestEncode(c *C) {
 		types.NewIntDatum(1),
 		types.NewStringDatum("invalid-pk"),
 	}
-	_, err = strictMode.Encode(logger, rowsWithPk, 2, []int{0, 1}, 1234)
+	_, err = strictMode.Encode(logger, rowsWithPk, 2, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, ErrorMatches, "failed to cast value as bigint\\(20\\) for column `_tidb_rowid`.*Truncated.*")
 
 	rowsWithPk2 := []types.Datum{
 		types.NewIntDatum(1),
 		types.NewStringDatum("1"),
 	}
-	pairs, err = strictMode.Encode(logger, rowsWithPk2, 2, []int{0, 1}, 1234)
+	pairs, err = strictMode.Encode(logger, rowsWithPk2, 2, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -122,7 +122,7 @@ func (s *kvSuite) TestEncode(c *C) {
 		Timestamp: 1234567891,
 	})
 	c.Assert(err, IsNil)
-	_, err = mockMode.Encode(logger, rowsWithPk2, 2, []int{0, 1}, 1234)
+	_, err = mockMode.Encode(logger, rowsWithPk2, 2, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, ErrorMatches, "mock error")
 
 	// Non-strict mode
@@ -132,7 +132,7 @@ func (s *kvSuite) TestEncode(c *C) {
 		SysVars:   map[string]string{"tidb_row_format_version": "1"},
 	})
 	c.Assert(err, IsNil)
-	pairs, err = noneMode.Encode(logger, rows, 1, []int{0, 1}, 1234)
+	pairs, err = noneMode.Encode(logger, rows, 1, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -207,7 +207,7 @@ func (s *kvSuite) TestDecodeIndex(c *C) {
 		Timestamp: 1234567890,
 	})
 	c.Assert(err, IsNil)
-	pairs, err := strictMode.Encode(logger, rows, 1, []int{0, 1, -1}, 123)
+	pairs, err := strictMode.Encode(logger, rows, 1, []int{0, 1, -1}, "1.csv", 123)
 	data := pairs.(*KvPairs)
 	c.Assert(len(data.pairs), DeepEquals, 2)
 
@@ -226,6 +226,42 @@ func (s *kvSuite) TestDecodeIndex(c *C) {
 	c.Assert(rawData, DeepEquals, rows)
 }
 
+func (s *kvSuite) TestEncodeRawRow(c *C) {
+	logger := log.Logger{Logger: zap.NewNop()}
+	p := parser.New()
+	se := mock.NewContext()
+	node, err := p.ParseOneStmt("create table t (a int, b varchar(10), unique key uk(b), key ka(a))", "utf8mb4", "utf8mb4_bin")
+	c.Assert(err, IsNil)
+	tblInfo, err := ddl.MockTableInfo(se, node.(*ast.CreateTableStmt), 1)
+	c.Assert(err, IsNil)
+	tblInfo.State = model.StatePublic
+	tbl, err := tables.TableFromMeta(NewPanickingAllocators(0), tblInfo)
+	c.Assert(err, IsNil)
+
+	encoder, err := NewTableKVEncoder(tbl, &SessionOptions{SQLMode: mysql.ModeStrictAllTables})
+	c.Assert(err, IsNil)
+	row := []types.Datum{types.NewIntDatum(7), types.NewStringDatum("seven")}
+	pairs, err := encoder.Encode(logger, row, 11, []int{0, 1, -1}, "1.csv", 123)
+	c.Assert(err, IsNil)
+	encoded := pairs.(*KvPairs).pairs
+	c.Assert(encoded, HasLen, 3)
+
+	decoder, err := NewTableKVDecoder(tbl, &SessionOptions{SQLMode: mysql.ModeStrictAllTables})
+	c.Assert(err, IsNil)
+	defer decoder.Close()
+	h, err := decoder.DecodeHandleFromTable(encoded[0].Key)
+	c.Assert(err, IsNil)
+	c.Assert(h.IntValue(), Equals, int64(11))
+	decoded, reencoded, err := decoder.EncodeRawRow(h, encoded[0].Val)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, DeepEquals, row)
+	c.Assert(reencoded, HasLen, len(encoded))
+	for i, pair := range reencoded {
+		c.Assert(pair.Key, BytesEquals, encoded[i].Key)
+		c.Assert(pair.Val, BytesEquals, encoded[i].Val)
+	}
+}
+
 func (s *kvSuite) TestEncodeRowFormatV2(c *C) {
 	// Test encoding in row format v2, as described in <https://github.com/pingcap/tidb/blob/master/docs/design/2018-07-19-row-format.md>.
 
@@ -246,7 +282,7 @@ func (s *kvSuite) TestEncodeRowFormatV2(c *C) {
 		SysVars:   map[string]string{"tidb_row_format_version": "2"},
 	})
 	c.Assert(err, IsNil)
-	pairs, err := noneMode.Encode(logger, rows, 1, []int{0, 1}, 1234)
+	pairs, err := noneMode.Encode(logger, rows, 1, []int{0, 1}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -295,7 +331,7 @@ func (s *kvSuite) TestEncodeTimestamp(c *C) {
 		},
 	})
 	c.Assert(err, IsNil)
-	pairs, err := encoder.Encode(logger, nil, 70, []int{-1, 1}, 1234)
+	pairs, err := encoder.Encode(logger, nil, 70, []int{-1, 1}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -305,6 +341,13 @@ func (s *kvSuite) TestEncodeTimestamp(c *C) {
 			Offset: 1234,
 		},
 	}})
+
+	// a MinNotNull datum marks a missing value, which is filled by the default value.
+	missing := types.Datum{}
+	missing.SetMinNotNull()
+	missingPairs, err := encoder.Encode(logger, []types.Datum{missing}, 70, []int{0, -1}, "1.csv", 1234)
+	c.Assert(err, IsNil)
+	c.Assert(missingPairs, DeepEquals, pairs)
 }
 
 func (s *kvSuite) TestEncodeDoubleAutoIncrement(c *C) {
@@ -323,7 +366,7 @@ func (s *kvSuite) TestEncodeDoubleAutoIncrement(c *C) {
 	c.Assert(err, IsNil)
 	pairs, err := encoder.Encode(logger, []types.Datum{
 		types.NewStringDatum("1"),
-	}, 70, []int{0, -1}, 1234)
+	}, 70, []int{0, -1}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -367,7 +410,7 @@ func (s *kvSuite) TestDefaultAutoRandoms(c *C) {
 	})
 	c.Assert(err, IsNil)
 	logger := log.Logger{Logger: zap.NewNop()}
-	pairs, err := encoder.Encode(logger, []types.Datum{types.NewStringDatum("")}, 70, []int{-1, 0}, 1234)
+	pairs, err := encoder.Encode(logger, []types.Datum{types.NewStringDatum("")}, 70, []int{-1, 0}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -379,7 +422,7 @@ func (s *kvSuite) TestDefaultAutoRandoms(c *C) {
 	}})
 	c.Assert(tbl.Allocators(encoder.(*tableKVEncoder).se).Get(autoid.AutoRandomType).Base(), Equals, int64(70))
 
-	pairs, err = encoder.Encode(logger, []types.Datum{types.NewStringDatum("")}, 71, []int{-1, 0}, 1234)
+	pairs, err = encoder.Encode(logger, []types.Datum{types.NewStringDatum("")}, 71, []int{-1, 0}, "1.csv", 1234)
 	c.Assert(err, IsNil)
 	c.Assert(pairs, DeepEquals, &KvPairs{pairs: []common.KvPair{
 		{
@@ -406,7 +449,7 @@ func (s *kvSuite) TestShardRowId(c *C) {
 	logger := log.Logger{Logger: zap.NewNop()}
 	keyMap := make(map[int64]struct{}, 16)
 	for i := int64(1); i <= 32; i++ {
-		pairs, err := encoder.Encode(logger, []types.Datum{types.NewStringDatum(fmt.Sprintf("%d", i))}, i, []int{0, -1}, i*32)
+		pairs, err := encoder.Encode(logger, []types.Datum{types.NewStringDatum(fmt.Sprintf("%d", i))}, i, []int{0, -1}, "1.csv", i*32)
 		c.Assert(err, IsNil)
 		kvs := pairs.(*KvPairs)
 		c.Assert(len(kvs.pairs), Equals, 1)
@@ -595,7 +638,7 @@ func (s *benchSQL2KVSuite) SetUpTest(c *C) {
 // Run `go test github.com/pingcap/br/pkg/lightning/backend -check.b -test.v` to get benchmark result.
 func (s *benchSQL2KVSuite) BenchmarkSQL2KV(c *C) {
 	for i := 0; i < c.N; i++ {
-		rows, err := s.encoder.Encode(s.logger, s.row, 1, s.colPerm, 0)
+		rows, err := s.encoder.Encode(s.logger, s.row, 1, s.colPerm, "", 0)
 		c.Assert(err, IsNil)
 		c.Assert(rows, HasLen, 2)
 	}
@@ -0,0 +1,300 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/expression"
+	"github.com/pingcap/tidb/sessionctx/variable"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/chunk"
+)
+
+// variableCollector collects the names of the user variables in an expression.
+type variableCollector struct {
+	names []string
+	err   error
+}
+
+func (v *variableCollector) Enter(n ast.Node) (ast.Node, bool) {
+	if expr, ok := n.(*ast.VariableExpr); ok {
+		switch {
+		case expr.IsSystem:
+			v.err = errors.Errorf("system variable @@%s is not supported", expr.Name)
+		case expr.Value != nil:
+			v.err = errors.Errorf("assignment to @%s is not supported", expr.Name)
+		default:
+			v.names = append(v.names, strings.ToLower(expr.Name))
+		}
+	}
+	return n, v.err != nil
+}
+
+func (v *variableCollector) Leave(n ast.Node) (ast.Node, bool) {
+	return n, v.err == nil
+}
+
+// whereClause is the name of the `where` condition in the error messages.
+const whereClause = "where"
+
+// parseTransformExpr parses the expression of a transform rule, and returns
+// the names of the source columns it refers to. `name` describes the
+// expression in the error messages.
+func parseTransformExpr(p *parser.Parser, name string, expr string) (ast.ExprNode, []string, error) {
+	stmt, err := p.ParseOneStmt("SELECT "+expr, "", "")
+	if err != nil {
+		return nil, nil, errors.Annotatef(err, "invalid transform expression of %s", name)
+	}
+	sel, ok := stmt.(*ast.SelectStmt)
+	if !ok || sel.From != nil || sel.Where != nil || len(sel.Fields.Fields) != 1 || sel.Fields.Fields[0].Expr == nil {
+		return nil, nil, errors.Errorf("invalid transform expression of %s: %s", name, expr)
+	}
+	exprNode := sel.Fields.Fields[0].Expr
+	collector := &variableCollector{}
+	exprNode.Accept(collector)
+	if collector.err != nil {
+		return nil, nil, errors.Annotatef(collector.err, "invalid transform expression of %s", name)
+	}
+	return exprNode, collector.names, nil
+}
+
+// TransformVariables returns the names of the source columns referred by the
+// transform expressions and the `where` condition, in lower case.
+func TransformVariables(columns map[string]string, where string) ([]string, error) {
+	p := parser.New()
+	seen := make(map[string]struct{})
+	var names []string
+	collect := func(name string, expr string) error {
+		_, vars, err := parseTransformExpr(p, name, expr)
+		if err != nil {
+			return err
+		}
+		for _, v := range vars {
+			if _, ok := seen[v]; !ok {
+				seen[v] = struct{}{}
+				names = append(names, v)
+			}
+		}
+		return nil
+	}
+	for column, expr := range columns {
+		if err := collect("column "+column, expr); err != nil {
+			return nil, err
+		}
+	}
+	if len(where) > 0 {
+		if err := collect(whereClause, where); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// sourceField is the source of a field of the transformed row.
+type sourceField struct {
+	// index is the index of the source field, or -1 if the field is computed
+	// by the expression.
+	index int
+	expr  expression.Expression
+}
+
+// variableField binds a user variable to a source field.
+type variableField struct {
+	name  string
+	index int
+}
+
+// RowTransformer computes the target columns of the source rows with the
+// expressions of a transform rule, and filters out the rows not satisfying the
+// `where` condition. The transformed rows only contain the fields of the
+// target columns, which are described by Columns and ColumnPermutation.
+type RowTransformer struct {
+	se          *session
+	variables   []variableField
+	where       expression.Expression
+	fields      []sourceField
+	columns     []string
+	permutation []int
+	row         []types.Datum
+}
+
+// NewRowTransformer creates a transformer of the rows with the source columns
+// `srcColumns` and the column permutation `permutation` of the table. If
+// `srcColumns` is empty, the source fields are in the order of the table
+// columns. An empty `where` keeps all rows.
+func NewRowTransformer(
+	tableInfo *model.TableInfo,
+	columns map[string]string,
+	where string,
+	srcColumns []string,
+	permutation []int,
+	options *SessionOptions,
+) (*RowTransformer, error) {
+	if len(srcColumns) == 0 {
+		srcColumns = make([]string, 0, len(tableInfo.Columns))
+		for _, col := range tableInfo.Columns {
+			srcColumns = append(srcColumns, col.Name.L)
+		}
+	}
+	srcIndex := make(map[string]int, len(srcColumns))
+	for i, name := range srcColumns {
+		srcIndex[strings.ToLower(name)] = i
+	}
+
+	targetIndex := make(map[string]int, len(tableInfo.Columns))
+	for i, col := range tableInfo.Columns {
+		targetIndex[col.Name.L] = i
+	}
+
+	se := newSession(options)
+	// the expression rewriter requires a non-nil TxnCtx.
+	se.vars.TxnCtx = new(variable.TransactionContext)
+	defer func() {
+		se.vars.TxnCtx = nil
+	}()
+
+	t := &RowTransformer{se: se}
+	p := parser.New()
+	bound := make(map[string]struct{})
+	schema := expression.NewSchema()
+	// compile parses the expression and binds the source columns it refers to.
+	compile := func(column string, exprStr string) (expression.Expression, error) {
+		exprNode, vars, err := parseTransformExpr(p, column, exprStr)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range vars {
+			index, ok := srcIndex[name]
+			if !ok {
+				return nil, errors.Errorf("unknown source column @%s in transform expression of %s", name, column)
+			}
+			if _, ok := bound[name]; !ok {
+				bound[name] = struct{}{}
+				t.variables = append(t.variables, variableField{name: name, index: index})
+			}
+		}
+		expr, err := expression.RewriteAstExpr(se, exprNode, schema, nil)
+		return expr, errors.Annotatef(err, "invalid transform expression of %s", column)
+	}
+
+	exprs := make(map[int]expression.Expression, len(columns))
+	for column, exprStr := range columns {
+		i, ok := targetIndex[strings.ToLower(column)]
+		if !ok {
+			return nil, errors.Errorf("unknown column %s in transform rule of table %s", column, tableInfo.Name.O)
+		}
+		if tableInfo.Columns[i].IsGenerated() {
+			return nil, errors.Errorf("cannot transform the generated column %s of table %s", column, tableInfo.Name.O)
+		}
+		expr, err := compile("column "+column, exprStr)
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = expr
+	}
+	if len(where) > 0 {
+		expr, err := compile(whereClause, where)
+		if err != nil {
+			return nil, err
+		}
+		t.where = expr
+	}
+
+	t.permutation = make([]int, 0, len(permutation))
+	for i, index := range permutation {
+		var field sourceField
+		if expr, ok := exprs[i]; ok {
+			field = sourceField{index: -1, expr: expr}
+		} else if index >= 0 {
+			field = sourceField{index: index}
+		} else {
+			t.permutation = append(t.permutation, -1)
+			continue
+		}
+		t.permutation = append(t.permutation, len(t.fields))
+		t.fields = append(t.fields, field)
+		if i < len(tableInfo.Columns) {
+			t.columns = append(t.columns, tableInfo.Columns[i].Name.O)
+		} else {
+			t.columns = append(t.columns, model.ExtraHandleName.O)
+		}
+	}
+	t.row = make([]types.Datum, 0, len(t.fields))
+	return t, nil
+}
+
+// Columns returns the names of the columns of the transformed rows.
+func (t *RowTransformer) Columns() []string {
+	return t.columns
+}
+
+// ColumnPermutation returns the column permutation of the transformed rows.
+func (t *RowTransformer) ColumnPermutation() []int {
+	return t.permutation
+}
+
+// Transform computes the transformed row from the source row. It returns false
+// if the row doesn't satisfy the `where` condition, which is false for a NULL
+// result like SQL. The returned row is only valid until the next call.
+func (t *RowTransformer) Transform(row []types.Datum) ([]types.Datum, bool, error) {
+	vars := t.se.vars
+	vars.UsersLock.Lock()
+	for _, v := range t.variables {
+		// a missing or NULL field leaves the variable as NULL.
+		if v.index < len(row) && !row[v.index].IsNull() && row[v.index].Kind() != types.KindMinNotNull {
+			vars.Users[v.name] = row[v.index]
+		} else {
+			delete(vars.Users, v.name)
+		}
+	}
+	vars.UsersLock.Unlock()
+
+	if t.where != nil {
+		keep, _, err := expression.EvalBool(t.se, []expression.Expression{t.where}, chunk.Row{})
+		if err != nil || !keep {
+			return nil, false, errors.Trace(err)
+		}
+	}
+
+	res := t.row[:0]
+	for _, field := range t.fields {
+		switch {
+		case field.expr != nil:
+			value, err := field.expr.Eval(chunk.Row{})
+			if err != nil {
+				return nil, false, errors.Trace(err)
+			}
+			res = append(res, value)
+		case field.index < len(row):
+			res = append(res, row[field.index])
+		default:
+			// the missing field is filled with the default value.
+			res = append(res, types.MinNotNullDatum())
+		}
+	}
+	t.row = res
+	return res, true, nil
+}
+
+// Close releases the resources of the transformer.
+func (t *RowTransformer) Close() {
+	t.se.Close()
+}
@@ -0,0 +1,147 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kv
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+)
+
+func (s *kvSuite) TestTransformVariables(c *C) {
+	vars, err := TransformVariables(map[string]string{
+		"full_name": "CONCAT(@First, ' ', @last)",
+		"initial":   "LEFT(@first, 1)",
+		"note":      "'imported'",
+	}, "@tenant = 42")
+	c.Assert(err, IsNil)
+	c.Assert(vars, DeepEquals, []string{"first", "last", "tenant"})
+
+	_, err = TransformVariables(map[string]string{"a": "@@sql_mode"}, "")
+	c.Assert(err, ErrorMatches, "invalid transform expression of column a: system variable @@sql_mode is not supported")
+	_, err = TransformVariables(map[string]string{"a": "@b := 1"}, "")
+	c.Assert(err, ErrorMatches, "invalid transform expression of column a: assignment to @b is not supported")
+	_, err = TransformVariables(map[string]string{"a": "1 FROM t"}, "")
+	c.Assert(err, ErrorMatches, "invalid transform expression of column a: 1 FROM t")
+	_, err = TransformVariables(map[string]string{"a": "CONCAT("}, "")
+	c.Assert(err, ErrorMatches, "invalid transform expression of column a: line 1 column 14 near .*")
+	_, err = TransformVariables(nil, "@a = (")
+	c.Assert(err, ErrorMatches, "invalid transform expression of where: .*")
+}
+
+func (s *kvSuite) TestRowTransformer(c *C) {
+	tblInfo := mockTableInfo(c, "create table t (id int primary key, full_name varchar(64), created datetime, note varchar(16), age int)")
+	columns := map[string]string{
+		"full_name": "CONCAT(TRIM(@first), ' ', TRIM(@last))",
+		"created":   "STR_TO_DATE(@created, '%d/%m/%Y')",
+		"note":      "'imported'",
+	}
+	// the source columns are (id, first, last, created, age), in which the
+	// `first`, `last` and `created` columns are ignored.
+	srcColumns := []string{"id", "First", "last", "created", "age"}
+	permutation := []int{0, -1, -1, -1, 4}
+
+	transformer, err := NewRowTransformer(tblInfo, columns, "", srcColumns, permutation, &SessionOptions{
+		SQLMode: mysql.ModeStrictAllTables,
+		SysVars: map[string]string{"max_allowed_packet": "67108864"},
+	})
+	c.Assert(err, IsNil)
+	defer transformer.Close()
+	c.Assert(transformer.Columns(), DeepEquals, []string{"id", "full_name", "created", "note", "age"})
+	c.Assert(transformer.ColumnPermutation(), DeepEquals, []int{0, 1, 2, 3, 4})
+
+	row, keep, err := transformer.Transform([]types.Datum{
+		types.NewStringDatum("1"),
+		types.NewStringDatum(" John "),
+		types.NewStringDatum("Smith"),
+		types.NewStringDatum("25/12/2020"),
+		types.NewStringDatum("30"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(keep, IsTrue)
+	c.Assert(row, HasLen, 5)
+	c.Assert(row[0].GetString(), Equals, "1")
+	c.Assert(row[1].GetString(), Equals, "John Smith")
+	created, err := row[2].ToString()
+	c.Assert(err, IsNil)
+	c.Assert(created, Equals, "2020-12-25")
+	c.Assert(row[3].GetString(), Equals, "imported")
+	c.Assert(row[4].GetString(), Equals, "30")
+
+	// NULL source columns are NULL variables, and the missing fields are
+	// filled with the default values.
+	row, _, err = transformer.Transform([]types.Datum{
+		types.NewStringDatum("2"),
+		types.NewDatum(nil),
+		types.NewStringDatum("Doe"),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(row, HasLen, 5)
+	c.Assert(row[1].IsNull(), IsTrue)
+	c.Assert(row[2].IsNull(), IsTrue)
+	c.Assert(row[4].Kind(), Equals, types.KindMinNotNull)
+}
+
+func (s *kvSuite) TestRowTransformerWithoutSourceColumns(c *C) {
+	tblInfo := mockTableInfo(c, "create table t (a int, b varchar(16))")
+
+	// the source fields are in the order of the table columns.
+	transformer, err := NewRowTransformer(tblInfo, map[string]string{"b": "UPPER(@b)"}, "", nil, []int{0, 1, -1}, &SessionOptions{})
+	c.Assert(err, IsNil)
+	defer transformer.Close()
+	c.Assert(transformer.Columns(), DeepEquals, []string{"a", "b"})
+	c.Assert(transformer.ColumnPermutation(), DeepEquals, []int{0, 1, -1})
+
+	row, _, err := transformer.Transform([]types.Datum{types.NewStringDatum("1"), types.NewStringDatum("abc")})
+	c.Assert(err, IsNil)
+	c.Assert(row[1].GetString(), Equals, "ABC")
+
+	_, err = NewRowTransformer(tblInfo, map[string]string{"c": "1"}, "", nil, []int{0, 1, -1}, &SessionOptions{})
+	c.Assert(err, ErrorMatches, "unknown column c in transform rule of table t")
+	_, err = NewRowTransformer(tblInfo, map[string]string{"b": "@c"}, "", nil, []int{0, 1, -1}, &SessionOptions{})
+	c.Assert(err, ErrorMatches, "unknown source column @c in transform expression of column b")
+	_, err = NewRowTransformer(tblInfo, nil, "@c > 0", nil, []int{0, 1, -1}, &SessionOptions{})
+	c.Assert(err, ErrorMatches, "unknown source column @c in transform expression of where")
+}
+
+func (s *kvSuite) TestRowTransformerWhere(c *C) {
+	tblInfo := mockTableInfo(c, "create table t (tenant_id int, name varchar(16))")
+
+	transformer, err := NewRowTransformer(tblInfo, nil, "@tenant_id = 42 AND @name LIKE 'a%'", nil, []int{0, 1, -1}, &SessionOptions{})
+	c.Assert(err, IsNil)
+	defer transformer.Close()
+	c.Assert(transformer.Columns(), DeepEquals, []string{"tenant_id", "name"})
+
+	cases := []struct {
+		tenantID types.Datum
+		name     types.Datum
+		keep     bool
+	}{
+		{types.NewStringDatum("42"), types.NewStringDatum("abc"), true},
+		{types.NewStringDatum("42"), types.NewStringDatum("xyz"), false},
+		{types.NewStringDatum("7"), types.NewStringDatum("abc"), false},
+		// NULL doesn't satisfy the condition.
+		{types.NewDatum(nil), types.NewStringDatum("abc"), false},
+	}
+	for _, ca := range cases {
+		row, keep, err := transformer.Transform([]types.Datum{ca.tenantID, ca.name})
+		c.Assert(err, IsNil)
+		c.Assert(keep, Equals, ca.keep)
+		if keep {
+			c.Assert(row, DeepEquals, []types.Datum{ca.tenantID, ca.name})
+		} else {
+			c.Assert(row, IsNil)
+		}
+	}
+}
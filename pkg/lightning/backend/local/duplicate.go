@@ -16,6 +16,7 @@ package local
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"sort"
 	"time"
@@ -33,6 +34,8 @@ import (
 	"github.com/pingcap/tidb/distsql"
 	tidbkv "github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/ranger"
 	"go.uber.org/zap"
@@ -41,9 +44,13 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 
+	"github.com/pingcap/br/pkg/lightning/backend"
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/verification"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/restore"
 )
@@ -88,7 +95,14 @@ func NewDuplicateManager(
 	}, nil
 }
 
-func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(ctx context.Context, tbl table.Table) error {
+// CollectDuplicateRowsFromTiKV detects the duplicate keys of the table in TiKV
+// and records them into errorMgr.
+func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	errorMgr *errormanager.ErrorManager,
+) error {
 	log.L().Info("Begin collect duplicate data from remote TiKV")
 	reqs, err := buildDuplicateRequests(tbl.Meta())
 	if err != nil {
@@ -105,7 +119,9 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(ctx context.Contex
 	for _, r := range reqs {
 		req := r
 		g.Go(func() error {
-			err := manager.sendRequestToTiKV(rpcctx, decoder, req)
+			err := manager.sendRequestToTiKV(rpcctx, req, 0, func(resp *import_sstpb.DuplicateDetectResponse) ([][]byte, error) {
+				return manager.storeDuplicateData(rpcctx, resp, decoder, req, tableName, errorMgr)
+			})
 			if err != nil {
 				log.L().Error("error occur when collect duplicate data from TiKV", zap.Error(err))
 			}
@@ -117,9 +133,14 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromTiKV(ctx context.Contex
 	return err
 }
 
+// sendRequestToTiKV detects the keys with multiple versions newer than
+// minCommitTS in the key range of req, and passes the responses to storeFn,
+// which returns the handle keys whose values are to be fetched into db.
 func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
-	decoder *kv.TableKVDecoder,
-	req *DuplicateRequest) error {
+	req *DuplicateRequest,
+	minCommitTS uint64,
+	storeFn func(resp *import_sstpb.DuplicateDetectResponse) ([][]byte, error),
+) error {
 	startKey := codec.EncodeBytes([]byte{}, req.start)
 	endKey := codec.EncodeBytes([]byte{}, req.end)
 
@@ -154,7 +175,7 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 				end = req.end
 			}
 
-			cli, err := manager.getDuplicateStream(ctx, region, start, end)
+			cli, err := manager.getDuplicateStream(ctx, region, start, end, minCommitTS)
 			if err != nil {
 				r, err := manager.splitCli.GetRegionByID(ctx, region.Region.GetId())
 				if err != nil {
@@ -223,7 +244,7 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 					break
 				}
 
-				handles, err := manager.storeDuplicateData(ctx, resp, decoder, req)
+				handles, err := storeFn(resp)
 				if err != nil {
 					return err
 				}
@@ -243,12 +264,21 @@ func (manager *DuplicateManager) sendRequestToTiKV(ctx context.Context,
 	return nil
 }
 
+// storeDuplicateData stores the duplicate record keys of the response into db,
+// records the duplicate keys into errorMgr, and returns the handle keys of the
+// duplicate index keys.
 func (manager *DuplicateManager) storeDuplicateData(
 	ctx context.Context,
 	resp *import_sstpb.DuplicateDetectResponse,
 	decoder *kv.TableKVDecoder,
 	req *DuplicateRequest,
+	tableName string,
+	errorMgr *errormanager.ErrorManager,
 ) ([][]byte, error) {
+	indexName := "PRIMARY"
+	if req.indexInfo != nil {
+		indexName = req.indexInfo.Name.O
+	}
 	opts := &pebble.WriteOptions{Sync: false}
 	var err error
 	maxKeyLen := 0
@@ -262,7 +292,10 @@ func (manager *DuplicateManager) storeDuplicateData(
 	for i := 0; i < maxRetryTimes; i++ {
 		b := manager.db.NewBatch()
 		handles := make([][]byte, 0)
+		// the versions of a key are adjacent in the response.
+		keys := make([]errormanager.DuplicateKey, 0)
 		for _, kv := range resp.Pairs {
+			rowKey := kv.Key
 			if req.indexInfo != nil {
 				h, err := decoder.DecodeHandleFromIndex(req.indexInfo, kv.Key, kv.Value)
 				if err != nil {
@@ -273,16 +306,24 @@ func (manager *DuplicateManager) storeDuplicateData(
 				}
 				key := decoder.EncodeHandleKey(h)
 				handles = append(handles, key)
+				rowKey = key
 			} else {
 				encodedKey := manager.keyAdapter.Encode(buf, kv.Key, 0, int64(kv.CommitTs))
 				b.Set(encodedKey, kv.Value, opts)
 			}
+			if len(keys) == 0 || !bytes.Equal(keys[len(keys)-1].Key, kv.Key) {
+				keys = append(keys, errormanager.DuplicateKey{IndexName: indexName, Key: kv.Key})
+			}
+			keys[len(keys)-1].RowKeys = append(keys[len(keys)-1].RowKeys, rowKey)
 		}
 		err = b.Commit(opts)
 		if err != nil {
 			continue
 		}
 		b.Close()
+		if err = errorMgr.RecordDuplicateKeys(ctx, log.With(zap.String("table", tableName)), tableName, keys); err != nil {
+			return nil, err
+		}
 		if len(handles) == 0 {
 			return handles, nil
 		}
@@ -291,15 +332,679 @@ func (manager *DuplicateManager) storeDuplicateData(
 	return nil, err
 }
 
-func (manager *DuplicateManager) ReportDuplicateData() error {
+// ReportDuplicateData records the duplicate keys of the local data source
+// stored in db by CollectDuplicateRowsFromLocalIndex into errorMgr, together
+// with the record keys of the rows owning them.
+func (manager *DuplicateManager) ReportDuplicateData(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	errorMgr *errormanager.ErrorManager,
+) error {
+	decoder, err := kv.NewTableKVDecoder(tbl, &kv.SessionOptions{
+		SQLMode: mysql.ModeStrictAllTables,
+	})
+	if err != nil {
+		return errors.Annotate(err, "create decoder failed")
+	}
+	defer decoder.Close()
+
+	keys, rows, err := manager.collectDuplicateKeys(tbl, decoder)
+	if err != nil {
+		return err
+	}
+	rowIDs := make([]int64, 0, len(rows))
+	for rowID := range rows {
+		rowIDs = append(rowIDs, rowID)
+	}
+	sort.Slice(rowIDs, func(i, j int) bool { return rowIDs[i] < rowIDs[j] })
+
+	reported := make([]errormanager.DuplicateKey, len(keys))
+	for i, key := range keys {
+		reported[i] = errormanager.DuplicateKey{IndexName: key.indexName, Key: key.key}
+	}
+	for _, rowID := range rowIDs {
+		row := rows[rowID]
+		rowKey := decoder.EncodeHandleKey(row.handle)
+		for _, i := range row.conflicts {
+			reported[i].RowKeys = append(reported[i].RowKeys, rowKey)
+		}
+	}
+	return errorMgr.RecordDuplicateKeys(ctx, log.With(zap.String("table", tableName)), tableName, reported)
+}
+
+// duplicateKey is a key shared by several rows of the local data source.
+type duplicateKey struct {
+	key       []byte
+	indexName string
+	rowCount  int
+}
+
+// duplicateRow is a row of the local data source owning some duplicate keys.
+type duplicateRow struct {
+	rowID    int64
+	offset   int64
+	handle   tidbkv.Handle
+	rawValue []byte
+	// conflicts are the indices of the duplicate keys owned by this row.
+	conflicts []int
+
+	datums []types.Datum
+	pairs  []common.KvPair
+}
+
+// RepairDuplicateData resolves the duplicate rows of the local data source
+// stored in db by CollectDuplicateRowsFromLocalIndex, so that every duplicate
+// key is owned by at most one row:
+//
+//   - keep-first keeps the rows in the order of the row IDs, i.e. the order in
+//     the data source, and removes a row if any of its keys is owned by a kept
+//     row.
+//   - keep-last does the same in the reversed order.
+//   - remove removes all the rows owning a duplicate key.
+//
+// Only one version of each duplicate key has been imported, so the keys of
+// the removed rows are deleted from the store, and the KV pairs of the rest
+// rows are encoded with `options` and written again. The removed rows are recorded by errorMgr, and the
+// checksum of their KV pairs is returned.
+//
+// The duplicate rows conflicting with the data existing in the store are
+// not resolved here.
+func (manager *DuplicateManager) RepairDuplicateData(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	store tidbkv.Storage,
+	algorithm string,
+	locator backend.RowSourceLocator,
+	errorMgr *errormanager.ErrorManager,
+) (verification.KVChecksum, error) {
+	var removedChecksum verification.KVChecksum
+	logger := log.With(zap.String("table", tableName), zap.String("algorithm", algorithm))
+
+	decoder, err := kv.NewTableKVDecoder(tbl, options)
+	if err != nil {
+		return removedChecksum, errors.Annotate(err, "create decoder failed")
+	}
+	defer decoder.Close()
+
+	keys, rows, err := manager.collectDuplicateKeys(tbl, decoder)
+	if err != nil {
+		return removedChecksum, err
+	}
+	if len(rows) == 0 {
+		return removedChecksum, nil
+	}
+	logger.Info("begin resolve duplicate rows", zap.Int("keys", len(keys)), zap.Int("rows", len(rows)))
+
+	if err = fillDuplicateRowValues(ctx, store, decoder, rows); err != nil {
+		return removedChecksum, err
+	}
+	for _, row := range rows {
+		row.datums, row.pairs, err = decoder.EncodeRawRow(row.handle, row.rawValue)
+		if err != nil {
+			return removedChecksum, errors.Annotatef(err, "encode duplicate row (rowID=%d) failed", row.rowID)
+		}
+	}
+
+	kept, removed, removedBy := resolveDuplicateRows(algorithm, keys, rows)
+
+	// delete all the keys of the removed rows first, since some of them may
+	// be shared with the kept rows, which are written again afterwards.
+	err = runInBatchedTxns(store, removed, func(txn tidbkv.Transaction, row *duplicateRow) error {
+		for _, pair := range row.pairs {
+			if err := txn.Delete(pair.Key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return removedChecksum, errors.Annotate(err, "delete duplicate rows failed")
+	}
+	err = runInBatchedTxns(store, kept, func(txn tidbkv.Transaction, row *duplicateRow) error {
+		for _, pair := range row.pairs {
+			if err := txn.Set(pair.Key, pair.Val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return removedChecksum, errors.Annotate(err, "rewrite resolved rows failed")
+	}
+
+	for i, row := range removed {
+		removedChecksum.Update(row.pairs)
+		rowText, err := types.DatumsToString(row.datums, true)
+		if err != nil {
+			rowText = fmt.Sprintf("%v", row.datums)
+		}
+		err = errorMgr.RecordConflictRecord(ctx, logger, tableName, keys[removedBy[i]].indexName,
+			locator(row.rowID), row.offset, rowText)
+		if err != nil {
+			return removedChecksum, err
+		}
+	}
+	logger.Info("end resolve duplicate rows", zap.Int("kept", len(kept)), zap.Int("removed", len(removed)),
+		zap.Object("removedChecksum", &removedChecksum))
+	return removedChecksum, nil
+}
+
+// collectDuplicateKeys reads the duplicate keys of the local data source and
+// the rows owning them from db. The rows fetched from TiKV (with row ID 0) are
+// used as the raw values of the rows found only by the index keys.
+func (manager *DuplicateManager) collectDuplicateKeys(
+	tbl table.Table,
+	decoder *kv.TableKVDecoder,
+) ([]*duplicateKey, map[int64]*duplicateRow, error) {
+	tableID := tbl.Meta().ID
+	indexNames := make(map[int64]string, len(tbl.Meta().Indices))
+	indexInfos := make(map[int64]*model.IndexInfo, len(tbl.Meta().Indices))
+	for _, indexInfo := range tbl.Meta().Indices {
+		indexNames[indexInfo.ID] = indexInfo.Name.O
+		indexInfos[indexInfo.ID] = indexInfo
+	}
+
+	keys := make([]*duplicateKey, 0)
+	rows := make(map[int64]*duplicateRow)
+	fetched := make(map[string][]byte)
+	startKey := codec.EncodeBytes(nil, tablecodec.EncodeTablePrefix(tableID))
+	endKey := codec.EncodeBytes(nil, tablecodec.EncodeTablePrefix(tableID+1))
+	iter := manager.db.NewIter(&pebble.IterOptions{LowerBound: startKey, UpperBound: endKey})
+	defer iter.Close()
+	for iter.SeekGE(startKey); iter.Valid(); iter.Next() {
+		rawKey, rowID, offset, err := manager.keyAdapter.Decode(nil, iter.Key())
+		if err != nil {
+			return nil, nil, errors.Annotate(err, "decode key from duplicate db failed")
+		}
+		value := append([]byte{}, iter.Value()...)
+		if rowID == 0 {
+			fetched[string(rawKey)] = value
+			continue
+		}
+
+		row, ok := rows[rowID]
+		if !ok {
+			row = &duplicateRow{rowID: rowID, offset: offset}
+			rows[rowID] = row
+		}
+		var indexName string
+		if tablecodec.IsRecordKey(rawKey) {
+			indexName = "PRIMARY"
+			if row.handle, err = decoder.DecodeHandleFromTable(rawKey); err != nil {
+				return nil, nil, errors.Annotate(err, "decode handle from duplicate record key failed")
+			}
+			row.rawValue = value
+		} else {
+			_, indexID, _, err := tablecodec.DecodeKeyHead(rawKey)
+			if err != nil {
+				return nil, nil, errors.Annotate(err, "decode duplicate index key failed")
+			}
+			indexInfo, ok := indexInfos[indexID]
+			if !ok {
+				return nil, nil, errors.Errorf("unknown index (id=%d) of the duplicate index key", indexID)
+			}
+			indexName = indexNames[indexID]
+			h, err := decoder.DecodeHandleFromIndex(indexInfo, rawKey, value)
+			if err != nil {
+				return nil, nil, errors.Annotate(err, "decode handle from duplicate index key failed")
+			}
+			if row.handle == nil {
+				row.handle = h
+			}
+		}
+
+		if len(keys) == 0 || !bytes.Equal(keys[len(keys)-1].key, rawKey) {
+			keys = append(keys, &duplicateKey{key: rawKey, indexName: indexName})
+		}
+		keys[len(keys)-1].rowCount++
+		row.conflicts = append(row.conflicts, len(keys)-1)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, nil, errors.Annotate(err, "iterate duplicate db failed")
+	}
+
+	for _, row := range rows {
+		if row.rawValue == nil {
+			row.rawValue = fetched[string(decoder.EncodeHandleKey(row.handle))]
+		}
+	}
+	return keys, rows, nil
+}
+
+// fillDuplicateRowValues reads the raw values of the rows which are not
+// found in the duplicate db from the store.
+func fillDuplicateRowValues(
+	ctx context.Context,
+	store tidbkv.Storage,
+	decoder *kv.TableKVDecoder,
+	rows map[int64]*duplicateRow,
+) error {
+	var txn tidbkv.Transaction
+	for _, row := range rows {
+		if row.rawValue != nil {
+			continue
+		}
+		if txn == nil {
+			var err error
+			if txn, err = store.Begin(); err != nil {
+				return errors.Trace(err)
+			}
+			defer txn.Rollback() //nolint:errcheck
+		}
+		value, err := txn.Get(ctx, decoder.EncodeHandleKey(row.handle))
+		if err != nil {
+			return errors.Annotatef(err, "get the raw value of duplicate row (rowID=%d) failed", row.rowID)
+		}
+		row.rawValue = value
+	}
 	return nil
 }
 
-func (manager *DuplicateManager) RepairDuplicateData() error {
-	// TODO
+// resolveDuplicateRows decides the rows to keep and to remove. For each
+// removed row, it also returns the index of the duplicate key for which the
+// row is removed.
+func resolveDuplicateRows(
+	algorithm string,
+	keys []*duplicateKey,
+	rows map[int64]*duplicateRow,
+) (kept []*duplicateRow, removed []*duplicateRow, removedBy []int) {
+	sortedRows := make([]*duplicateRow, 0, len(rows))
+	for _, row := range rows {
+		sortedRows = append(sortedRows, row)
+	}
+	sort.Slice(sortedRows, func(i, j int) bool {
+		if algorithm == config.DupeResAlgKeepLast {
+			return sortedRows[i].rowID > sortedRows[j].rowID
+		}
+		return sortedRows[i].rowID < sortedRows[j].rowID
+	})
+
+	owned := make([]bool, len(keys))
+	for _, row := range sortedRows {
+		conflict := -1
+		for _, k := range row.conflicts {
+			if (algorithm == config.DupeResAlgRemove && keys[k].rowCount > 1) || owned[k] {
+				conflict = k
+				break
+			}
+		}
+		if conflict >= 0 {
+			removed = append(removed, row)
+			removedBy = append(removedBy, conflict)
+			continue
+		}
+		for _, k := range row.conflicts {
+			owned[k] = true
+		}
+		kept = append(kept, row)
+	}
+	return kept, removed, removedBy
+}
+
+// runInBatchedTxns applies fn to the rows in transactions of at most
+// maxWriteBatchCount rows.
+func runInBatchedTxns(
+	store tidbkv.Storage,
+	rows []*duplicateRow,
+	fn func(txn tidbkv.Transaction, row *duplicateRow) error,
+) error {
+	for len(rows) > 0 {
+		batch := rows
+		if len(batch) > maxWriteBatchCount {
+			batch = batch[:maxWriteBatchCount]
+		}
+		rows = rows[len(batch):]
+
+		txn, err := store.Begin()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, row := range batch {
+			if err = fn(txn, row); err != nil {
+				_ = txn.Rollback()
+				return errors.Trace(err)
+			}
+		}
+		if err = txn.Commit(context.Background()); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	return nil
 }
 
+// keyVersion is a version of a key detected by DuplicateDetect of TiKV.
+type keyVersion struct {
+	value    []byte
+	commitTS uint64
+}
+
+// MergeExistingRows merges the rows imported at the timestamps newer than
+// minCommitTS into the rows existing in the store before the import, like
+// REPLACE INTO:
+//
+//   - an imported row replaces the existing row of the same handle, and the
+//     index entries of the existing row not written by the imported row are
+//     deleted.
+//   - an existing row owning a unique key taken by an imported row of another
+//     handle is deleted with all its index entries.
+//
+// The replaced versions of the keys are detected by DuplicateDetect and stored
+// into db. `options` must be the same as the options encoding the rows. The
+// checksum of the KV pairs of the existing rows replaced or deleted is
+// returned.
+func (manager *DuplicateManager) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	store tidbkv.Storage,
+	minCommitTS uint64,
+) (verification.KVChecksum, error) {
+	log.L().Info("Begin collect replaced versions from remote TiKV", zap.String("table", tableName))
+	reqs, err := buildDuplicateRequests(tbl.Meta())
+	if err != nil {
+		return verification.KVChecksum{}, err
+	}
+	g, rpcctx := errgroup.WithContext(ctx)
+	for _, r := range reqs {
+		req := r
+		g.Go(func() error {
+			err := manager.sendRequestToTiKV(rpcctx, req, minCommitTS, manager.storeKeyVersions)
+			if err != nil {
+				log.L().Error("error occur when collect replaced versions from TiKV", zap.Error(err))
+			}
+			return err
+		})
+	}
+	if err = g.Wait(); err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "collect replaced versions failed")
+	}
+	log.L().Info("End collect replaced versions from remote TiKV", zap.String("table", tableName))
+
+	return manager.mergeExistingRows(ctx, tbl, tableName, options, store, minCommitTS)
+}
+
+// storeKeyVersions stores all the versions in resp into db.
+func (manager *DuplicateManager) storeKeyVersions(resp *import_sstpb.DuplicateDetectResponse) ([][]byte, error) {
+	opts := &pebble.WriteOptions{Sync: false}
+	b := manager.db.NewBatch()
+	defer b.Close()
+	var buf []byte
+	for _, pair := range resp.Pairs {
+		buf = manager.keyAdapter.Encode(buf, pair.Key, 0, int64(pair.CommitTs))
+		if err := b.Set(buf, pair.Value, opts); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return nil, errors.Trace(b.Commit(opts))
+}
+
+// mergeExistingRows reconciles the versions of the keys stored in db with the
+// rows in the store. See MergeExistingRows for details.
+func (manager *DuplicateManager) mergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	store tidbkv.Storage,
+	minCommitTS uint64,
+) (verification.KVChecksum, error) {
+	var replacedChecksum verification.KVChecksum
+	logger := log.With(zap.String("table", tableName))
+
+	decoder, err := kv.NewTableKVDecoder(tbl, options)
+	if err != nil {
+		return replacedChecksum, errors.Annotate(err, "create decoder failed")
+	}
+	defer decoder.Close()
+
+	indexInfos := make(map[int64]*model.IndexInfo, len(tbl.Meta().Indices))
+	for _, indexInfo := range tbl.Meta().Indices {
+		indexInfos[indexInfo.ID] = indexInfo
+	}
+	indexOf := func(key []byte) (*model.IndexInfo, error) {
+		_, indexID, _, err := tablecodec.DecodeKeyHead(key)
+		if err != nil {
+			return nil, errors.Annotate(err, "decode index key failed")
+		}
+		indexInfo, ok := indexInfos[indexID]
+		if !ok {
+			return nil, errors.Errorf("unknown index (id=%d) of the index key", indexID)
+		}
+		return indexInfo, nil
+	}
+
+	// deletedKeys maps the keys to delete to their current values.
+	deletedKeys := make(map[string][]byte)
+	// deleteIfOwned deletes the index entry of the row of handle h, unless the
+	// index entry is owned by another row now.
+	deleteIfOwned := func(pair common.KvPair, h tidbkv.Handle) error {
+		current, ok, err := manager.latestVersion(pair.Key)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// not written by the import, so it is still the index entry of the row.
+			current = pair.Val
+		}
+		indexInfo, err := indexOf(pair.Key)
+		if err != nil {
+			return err
+		}
+		owner, err := decoder.DecodeHandleFromIndex(indexInfo, pair.Key, current)
+		if err != nil {
+			return errors.Annotate(err, "decode handle from index failed")
+		}
+		if owner.Equal(h) {
+			deletedKeys[string(pair.Key)] = current
+		}
+		return nil
+	}
+
+	replacedRows, deletedRows := 0, 0
+	// stolenHandles are the handles of the existing rows owning the unique
+	// keys taken by the imported rows.
+	stolenHandles := make(map[string]tidbkv.Handle)
+	tableID := tbl.Meta().ID
+	startKey := codec.EncodeBytes(nil, tablecodec.EncodeTablePrefix(tableID))
+	endKey := codec.EncodeBytes(nil, tablecodec.EncodeTablePrefix(tableID+1))
+	iter := manager.db.NewIter(&pebble.IterOptions{LowerBound: startKey, UpperBound: endKey})
+	defer iter.Close()
+	var (
+		key      []byte
+		versions []keyVersion
+	)
+	// reconcile processes all the versions of key.
+	reconcile := func() error {
+		replaced := replacedVersions(versions, minCommitTS)
+		if len(replaced) == 0 {
+			return nil
+		}
+		latest := versions[len(versions)-1]
+		for _, v := range replaced {
+			replacedChecksum.Update([]common.KvPair{{Key: key, Val: v.value}})
+		}
+
+		if tablecodec.IsRecordKey(key) {
+			h, err := decoder.DecodeHandleFromTable(key)
+			if err != nil {
+				return errors.Annotate(err, "decode handle from record key failed")
+			}
+			_, newPairs, err := decoder.EncodeRawRow(h, latest.value)
+			if err != nil {
+				return errors.Annotatef(err, "encode the imported row (handle=%s) failed", h)
+			}
+			written := make(map[string]struct{}, len(newPairs))
+			for _, pair := range newPairs {
+				written[string(pair.Key)] = struct{}{}
+			}
+			for _, v := range replaced {
+				_, oldPairs, err := decoder.EncodeRawRow(h, v.value)
+				if err != nil {
+					return errors.Annotatef(err, "encode the replaced row (handle=%s) failed", h)
+				}
+				for _, pair := range oldPairs {
+					if _, ok := written[string(pair.Key)]; ok || tablecodec.IsRecordKey(pair.Key) {
+						continue
+					}
+					if err := deleteIfOwned(pair, h); err != nil {
+						return err
+					}
+				}
+			}
+			replacedRows += len(replaced)
+			return nil
+		}
+
+		indexInfo, err := indexOf(key)
+		if err != nil {
+			return err
+		}
+		if !indexInfo.Unique {
+			// the non-unique index keys contain the handle, so they are
+			// always written by the same row.
+			return nil
+		}
+		newHandle, err := decoder.DecodeHandleFromIndex(indexInfo, key, latest.value)
+		if err != nil {
+			return errors.Annotate(err, "decode handle from unique index failed")
+		}
+		for _, v := range replaced {
+			oldHandle, err := decoder.DecodeHandleFromIndex(indexInfo, key, v.value)
+			if err != nil {
+				return errors.Annotate(err, "decode handle from unique index failed")
+			}
+			if !oldHandle.Equal(newHandle) {
+				stolenHandles[string(decoder.EncodeHandleKey(oldHandle))] = oldHandle
+			}
+		}
+		return nil
+	}
+	for iter.First(); iter.Valid(); iter.Next() {
+		rawKey, _, commitTS, err := manager.keyAdapter.Decode(nil, iter.Key())
+		if err != nil {
+			return replacedChecksum, errors.Annotate(err, "decode key of the versions failed")
+		}
+		if key != nil && !bytes.Equal(key, rawKey) {
+			if err := reconcile(); err != nil {
+				return replacedChecksum, err
+			}
+			versions = versions[:0]
+		}
+		key = rawKey
+		versions = append(versions, keyVersion{value: append([]byte{}, iter.Value()...), commitTS: uint64(commitTS)})
+	}
+	if err := iter.Error(); err != nil {
+		return replacedChecksum, errors.Annotate(err, "iterate the versions failed")
+	}
+	if key != nil {
+		if err := reconcile(); err != nil {
+			return replacedChecksum, err
+		}
+	}
+
+	if len(stolenHandles) > 0 {
+		txn, err := store.Begin()
+		if err != nil {
+			return replacedChecksum, errors.Trace(err)
+		}
+		defer txn.Rollback() //nolint:errcheck
+		for recordKey, h := range stolenHandles {
+			if _, ok, err := manager.latestVersion([]byte(recordKey)); err != nil {
+				return replacedChecksum, err
+			} else if ok {
+				// the row is replaced by an imported row, and has been reconciled above.
+				continue
+			}
+			value, err := txn.Get(ctx, tidbkv.Key(recordKey))
+			if tidbkv.ErrNotExist.Equal(err) {
+				continue
+			} else if err != nil {
+				return replacedChecksum, errors.Annotatef(err, "get the existing row (handle=%s) failed", h)
+			}
+			_, pairs, err := decoder.EncodeRawRow(h, value)
+			if err != nil {
+				return replacedChecksum, errors.Annotatef(err, "encode the existing row (handle=%s) failed", h)
+			}
+			deletedRows++
+			for _, pair := range pairs {
+				if tablecodec.IsRecordKey(pair.Key) {
+					deletedKeys[string(pair.Key)] = pair.Val
+				} else if err := deleteIfOwned(pair, h); err != nil {
+					return replacedChecksum, err
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(deletedKeys))
+	for k, v := range deletedKeys {
+		keys = append(keys, k)
+		replacedChecksum.Update([]common.KvPair{{Key: []byte(k), Val: v}})
+	}
+	sort.Strings(keys)
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > maxWriteBatchCount {
+			batch = batch[:maxWriteBatchCount]
+		}
+		keys = keys[len(batch):]
+
+		txn, err := store.Begin()
+		if err != nil {
+			return replacedChecksum, errors.Trace(err)
+		}
+		for _, k := range batch {
+			if err = txn.Delete(tidbkv.Key(k)); err != nil {
+				_ = txn.Rollback()
+				return replacedChecksum, errors.Trace(err)
+			}
+		}
+		if err = txn.Commit(ctx); err != nil {
+			return replacedChecksum, errors.Annotate(err, "delete the replaced keys failed")
+		}
+	}
+
+	logger.Info("merged imported rows into existing rows", zap.Int("replacedRows", replacedRows),
+		zap.Int("deletedRows", deletedRows), zap.Int("deletedKeys", len(deletedKeys)),
+		zap.Object("replacedChecksum", &replacedChecksum))
+	return replacedChecksum, nil
+}
+
+// latestVersion returns the latest version of the key stored in db.
+func (manager *DuplicateManager) latestVersion(key []byte) ([]byte, bool, error) {
+	iter := manager.db.NewIter(&pebble.IterOptions{
+		LowerBound: codec.EncodeBytes(nil, key),
+		UpperBound: manager.keyAdapter.Encode(nil, key, 1, 0),
+	})
+	defer iter.Close()
+	if !iter.Last() {
+		return nil, false, errors.Trace(iter.Error())
+	}
+	return append([]byte{}, iter.Value()...), true, nil
+}
+
+// replacedVersions returns the versions replaced by the import, i.e. the
+// versions other than the latest one, which are written by the import or
+// visible right before the import. The versions are in the ascending order of
+// the commit timestamps.
+func replacedVersions(versions []keyVersion, minCommitTS uint64) []keyVersion {
+	latest := len(versions) - 1
+	if latest < 0 || versions[latest].commitTS <= minCommitTS {
+		return nil
+	}
+	replaced := make([]keyVersion, 0, latest)
+	for i, v := range versions[:latest] {
+		if v.commitTS > minCommitTS || versions[i+1].commitTS > minCommitTS {
+			replaced = append(replaced, v)
+		}
+	}
+	return replaced
+}
+
 // Collect rows by read the index in db.
 func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 	ctx context.Context,
@@ -312,8 +1017,8 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 	if err != nil {
 		return err
 	}
+	defer decoder.Close()
 	handles := make([][]byte, 0)
-	allRanges := make([]tidbkv.KeyRange, 0)
 	for _, indexInfo := range tbl.Meta().Indices {
 		if indexInfo.State != model.StatePublic {
 			continue
@@ -323,7 +1028,6 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 		if err != nil {
 			return err
 		}
-		allRanges = append(allRanges, keysRanges...)
 		for _, r := range keysRanges {
 			startKey := codec.EncodeBytes([]byte{}, r.StartKey)
 			endKey := codec.EncodeBytes([]byte{}, r.EndKey)
@@ -363,25 +1067,19 @@ func (manager *DuplicateManager) CollectDuplicateRowsFromLocalIndex(
 			if len(handles) > 0 {
 				handles = manager.getValues(ctx, handles)
 			}
-			if len(handles) == 0 {
-				db.DeleteRange(r.StartKey, r.EndKey, &pebble.WriteOptions{Sync: false})
-			}
 			iter.Close()
 		}
 	}
-	if len(handles) == 0 {
-		return nil
-	}
 
-	for i := 0; i < maxRetryTimes; i++ {
+	// The duplicate index keys are kept in db, they are needed to resolve the
+	// duplicate rows by RepairDuplicateData.
+	for i := 0; i < maxRetryTimes && len(handles) > 0; i++ {
 		handles = manager.getValues(ctx, handles)
-		if len(handles) == 0 {
-			for _, r := range allRanges {
-				db.DeleteRange(r.StartKey, r.EndKey, &pebble.WriteOptions{Sync: false})
-			}
-		}
 	}
-	return errors.Errorf("retry getValues time exceed limit")
+	if len(handles) > 0 {
+		return errors.Errorf("retry getValues time exceed limit")
+	}
+	return nil
 }
 
 func (manager *DuplicateManager) getValues(
@@ -498,7 +1196,7 @@ func (manager *DuplicateManager) getValuesFromRegion(
 
 func (manager *DuplicateManager) getDuplicateStream(ctx context.Context,
 	region *restore.RegionInfo,
-	start []byte, end []byte) (import_sstpb.ImportSST_DuplicateDetectClient, error) {
+	start []byte, end []byte, minCommitTS uint64) (import_sstpb.ImportSST_DuplicateDetectClient, error) {
 	leader := region.Leader
 	if leader == nil {
 		leader = region.Region.GetPeers()[0]
@@ -515,10 +1213,11 @@ func (manager *DuplicateManager) getDuplicateStream(ctx context.Context,
 		Peer:        leader,
 	}
 	req := &import_sstpb.DuplicateDetectRequest{
-		Context:  reqCtx,
-		StartKey: start,
-		EndKey:   end,
-		KeyOnly:  false,
+		Context:     reqCtx,
+		StartKey:    start,
+		EndKey:      end,
+		KeyOnly:     false,
+		MinCommitTs: minCommitTS,
 	}
 	stream, err := cli.DuplicateDetect(ctx, req)
 	return stream, err
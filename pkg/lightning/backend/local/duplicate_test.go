@@ -0,0 +1,365 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"path/filepath"
+	"sort"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cockroachdb/pebble"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/ddl"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/store/mockstore"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/table/tables"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/mock"
+
+	"github.com/pingcap/br/pkg/lightning/backend/kv"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/lightning/verification"
+)
+
+type duplicateSuite struct{}
+
+var _ = Suite(&duplicateSuite{})
+
+// encodeDuplicateRows encodes the rows (a, b) with the row IDs 1, 2, 3, ...
+func encodeDuplicateRows(c *C, tbl table.Table, options *kv.SessionOptions, rows [][2]int64) [][]common.KvPair {
+	encoder, err := kv.NewTableKVEncoder(tbl, options)
+	c.Assert(err, IsNil)
+	result := make([][]common.KvPair, 0, len(rows))
+	for i, row := range rows {
+		rowID := int64(i + 1)
+		datums := []types.Datum{types.NewIntDatum(row[0]), types.NewIntDatum(row[1])}
+		encoded, err := encoder.Encode(log.L(), datums, rowID, []int{0, 1, -1}, "t.csv", rowID*100)
+		c.Assert(err, IsNil)
+		data, indices := kv.MakeRowsFromKvPairs(nil), kv.MakeRowsFromKvPairs(nil)
+		var dataChecksum, indexChecksum verification.KVChecksum
+		encoded.ClassifyAndAppend(&data, &dataChecksum, &indices, &indexChecksum)
+		result = append(result, append(kv.KvPairsFromRows(data), kv.KvPairsFromRows(indices)...))
+	}
+	return result
+}
+
+// prepareDuplicateRows imports the rows into the store the same way as the
+// local backend, i.e. only the version of the smallest row ID is imported for
+// each duplicate key, and records all the versions of the duplicate keys into
+// db.
+func prepareDuplicateRows(c *C, store tidbkv.Storage, db *pebble.DB, rowPairs [][]common.KvPair) {
+	var pairs []common.KvPair
+	for _, rp := range rowPairs {
+		pairs = append(pairs, rp...)
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	txn, err := store.Begin()
+	c.Assert(err, IsNil)
+	keyAdapter := duplicateKeyAdapter{}
+	for i, pair := range pairs {
+		isFirst := i == 0 || !bytes.Equal(pairs[i-1].Key, pair.Key)
+		if isFirst {
+			c.Assert(txn.Set(pair.Key, pair.Val), IsNil)
+		}
+		hasDuplicate := !isFirst || (i+1 < len(pairs) && bytes.Equal(pairs[i+1].Key, pair.Key))
+		if hasDuplicate {
+			c.Assert(db.Set(keyAdapter.Encode(nil, pair.Key, pair.RowID, pair.Offset), pair.Val, nil), IsNil)
+		}
+	}
+	c.Assert(txn.Commit(context.Background()), IsNil)
+}
+
+func scanTable(c *C, store tidbkv.Storage, tableID int64) []common.KvPair {
+	txn, err := store.Begin()
+	c.Assert(err, IsNil)
+	defer txn.Rollback() //nolint:errcheck
+	iter, err := txn.Iter(tablecodec.EncodeTablePrefix(tableID), tablecodec.EncodeTablePrefix(tableID+1))
+	c.Assert(err, IsNil)
+	defer iter.Close()
+	var pairs []common.KvPair
+	for iter.Valid() {
+		pairs = append(pairs, common.KvPair{
+			Key: append([]byte{}, iter.Key()...),
+			Val: append([]byte{}, iter.Value()...),
+		})
+		c.Assert(iter.Next(), IsNil)
+	}
+	return pairs
+}
+
+// newDuplicateTable creates the table (a int primary key, b int, unique key uk(b)).
+func newDuplicateTable(c *C) table.Table {
+	se := mock.NewContext()
+	node, err := parser.New().ParseOneStmt("create table t (a int primary key, b int, unique key uk(b))", "utf8mb4", "utf8mb4_bin")
+	c.Assert(err, IsNil)
+	tblInfo, err := ddl.MockTableInfo(se, node.(*ast.CreateTableStmt), 1)
+	c.Assert(err, IsNil)
+	tblInfo.State = model.StatePublic
+	c.Assert(tblInfo.PKIsHandle, IsTrue)
+	tbl, err := tables.TableFromMeta(kv.NewPanickingAllocators(0), tblInfo)
+	c.Assert(err, IsNil)
+	return tbl
+}
+
+func (s *duplicateSuite) TestRepairDuplicateData(c *C) {
+	tbl := newDuplicateTable(c)
+	tblInfo := tbl.Meta()
+	rows := [][2]int64{
+		{1, 10}, // row ID 1
+		{1, 20}, // row ID 2, duplicate PRIMARY with row 1
+		{3, 20}, // row ID 3, duplicate uk with row 2
+		{4, 40}, // row ID 4
+		{5, 10}, // row ID 5, duplicate uk with row 1
+	}
+	options := &kv.SessionOptions{
+		SQLMode: mysql.ModeStrictAllTables,
+		SysVars: map[string]string{"tidb_row_format_version": "2"},
+	}
+	rowPairs := encodeDuplicateRows(c, tbl, options, rows)
+
+	testCases := []struct {
+		algorithm string
+		kept      []int
+		removed   []int
+	}{
+		{algorithm: config.DupeResAlgKeepFirst, kept: []int{0, 2, 3}, removed: []int{1, 4}},
+		{algorithm: config.DupeResAlgKeepLast, kept: []int{2, 3, 4}, removed: []int{0, 1}},
+		{algorithm: config.DupeResAlgRemove, kept: []int{3}, removed: []int{0, 1, 2, 4}},
+	}
+	for _, tc := range testCases {
+		store, err := mockstore.NewMockStore()
+		c.Assert(err, IsNil)
+		db, err := pebble.Open(filepath.Join(c.MkDir(), "duplicates"), &pebble.Options{})
+		c.Assert(err, IsNil)
+		prepareDuplicateRows(c, store, db, rowPairs)
+
+		manager, err := NewDuplicateManager(db, nil, 0, nil, 1)
+		c.Assert(err, IsNil)
+		var located []int64
+		locator := func(rowID int64) string {
+			located = append(located, rowID)
+			return "t.csv"
+		}
+		removedChecksum, err := manager.RepairDuplicateData(context.Background(), tbl, "`db`.`t`", options, store, tc.algorithm, locator, nil)
+		c.Assert(err, IsNil, Commentf("algorithm: %s", tc.algorithm))
+
+		var expected []common.KvPair
+		for _, i := range tc.kept {
+			expected = append(expected, rowPairs[i]...)
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			return bytes.Compare(expected[i].Key, expected[j].Key) < 0
+		})
+		actual := scanTable(c, store, tblInfo.ID)
+		c.Assert(actual, HasLen, len(expected), Commentf("algorithm: %s", tc.algorithm))
+		for i := range expected {
+			c.Assert(actual[i].Key, BytesEquals, expected[i].Key)
+			c.Assert(actual[i].Val, BytesEquals, expected[i].Val)
+		}
+
+		var expectedChecksum verification.KVChecksum
+		expectedLocated := make([]int64, 0, len(tc.removed))
+		for _, i := range tc.removed {
+			expectedChecksum.Update(rowPairs[i])
+			expectedLocated = append(expectedLocated, int64(i+1))
+		}
+		c.Assert(removedChecksum, Equals, expectedChecksum)
+		sort.Slice(located, func(i, j int) bool { return located[i] < located[j] })
+		c.Assert(located, DeepEquals, expectedLocated)
+
+		c.Assert(db.Close(), IsNil)
+		c.Assert(store.Close(), IsNil)
+	}
+}
+
+func (s *duplicateSuite) TestReportDuplicateData(c *C) {
+	tbl := newDuplicateTable(c)
+	rows := [][2]int64{
+		{1, 10}, // row ID 1
+		{1, 20}, // row ID 2, duplicate PRIMARY with row 1
+		{3, 20}, // row ID 3, duplicate uk with row 2
+		{4, 40}, // row ID 4
+		{5, 10}, // row ID 5, duplicate uk with row 1
+	}
+	options := &kv.SessionOptions{
+		SQLMode: mysql.ModeStrictAllTables,
+		SysVars: map[string]string{"tidb_row_format_version": "2"},
+	}
+	store, err := mockstore.NewMockStore()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	db, err := pebble.Open(filepath.Join(c.MkDir(), "duplicates"), &pebble.Options{})
+	c.Assert(err, IsNil)
+	defer db.Close()
+	prepareDuplicateRows(c, store, db, encodeDuplicateRows(c, tbl, options, rows))
+
+	sqlDB, mockDB, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer sqlDB.Close()
+	cfg := config.NewConfig()
+	cfg.TaskID = 42
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.TikvImporter.DuplicateDetection = true
+	errorMgr := errormanager.New(sqlDB, cfg)
+
+	rowKey := func(handle int64) string {
+		return tidbkv.Key(tablecodec.EncodeRowKeyWithHandle(tbl.Meta().ID, tidbkv.IntHandle(handle))).String()
+	}
+	// the index keys are sorted before the record keys, and the rows owning a
+	// key are sorted by the row IDs.
+	expected := [][3]string{
+		{"uk", "", rowKey(1)}, {"uk", "", rowKey(5)},
+		{"uk", "", rowKey(1)}, {"uk", "", rowKey(3)},
+		{"PRIMARY", rowKey(1), rowKey(1)}, {"PRIMARY", rowKey(1), rowKey(1)},
+	}
+	args := make([]driver.Value, 0, len(expected)*5)
+	for _, e := range expected {
+		var keyData driver.Value = e[1]
+		if e[1] == "" {
+			keyData = sqlmock.AnyArg()
+		}
+		args = append(args, 42, "`db`.`t`", e[0], keyData, e[2])
+	}
+	mockDB.ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_keys_v1.*").
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(6, 6))
+
+	manager, err := NewDuplicateManager(db, nil, 0, nil, 1)
+	c.Assert(err, IsNil)
+	c.Assert(manager.ReportDuplicateData(context.Background(), tbl, "`db`.`t`", errorMgr), IsNil)
+	c.Assert(mockDB.ExpectationsWereMet(), IsNil)
+}
+
+func (s *duplicateSuite) TestMergeExistingRows(c *C) {
+	se := mock.NewContext()
+	node, err := parser.New().ParseOneStmt("create table t (a int primary key, b int, c int, unique key uk(b), key ic(c))", "utf8mb4", "utf8mb4_bin")
+	c.Assert(err, IsNil)
+	tblInfo, err := ddl.MockTableInfo(se, node.(*ast.CreateTableStmt), 1)
+	c.Assert(err, IsNil)
+	tblInfo.State = model.StatePublic
+	tbl, err := tables.TableFromMeta(kv.NewPanickingAllocators(0), tblInfo)
+	c.Assert(err, IsNil)
+
+	options := &kv.SessionOptions{
+		SQLMode: mysql.ModeStrictAllTables,
+		SysVars: map[string]string{"tidb_row_format_version": "2"},
+	}
+	encoder, err := kv.NewTableKVEncoder(tbl, options)
+	c.Assert(err, IsNil)
+	encodeRow := func(row [3]int64) []common.KvPair {
+		datums := []types.Datum{types.NewIntDatum(row[0]), types.NewIntDatum(row[1]), types.NewIntDatum(row[2])}
+		encoded, err := encoder.Encode(log.L(), datums, row[0], []int{0, 1, 2, -1}, "t.csv", 0)
+		c.Assert(err, IsNil)
+		data, indices := kv.MakeRowsFromKvPairs(nil), kv.MakeRowsFromKvPairs(nil)
+		var dataChecksum, indexChecksum verification.KVChecksum
+		encoded.ClassifyAndAppend(&data, &dataChecksum, &indices, &indexChecksum)
+		return append(kv.KvPairsFromRows(data), kv.KvPairsFromRows(indices)...)
+	}
+
+	existing := [][]common.KvPair{
+		encodeRow([3]int64{1, 10, 100}),
+		encodeRow([3]int64{2, 20, 200}),
+		encodeRow([3]int64{3, 30, 300}),
+	}
+	imported := [][]common.KvPair{
+		encodeRow([3]int64{1, 11, 100}), // replaces row 1, uk 10 is left dangling
+		encodeRow([3]int64{4, 20, 400}), // takes uk 20 of row 2
+	}
+
+	store, err := mockstore.NewMockStore()
+	c.Assert(err, IsNil)
+	defer store.Close()
+	db, err := pebble.Open(filepath.Join(c.MkDir(), "upsert_versions"), &pebble.Options{})
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	// write the existing rows at ts 5 and the imported rows at ts 10, and
+	// record the versions of the keys written twice as DuplicateDetect does.
+	const existingTS, importedTS = 5, 10
+	versions := make(map[string][]keyVersion)
+	for i, rows := range [][][]common.KvPair{existing, imported} {
+		ts := uint64(existingTS)
+		if i == 1 {
+			ts = importedTS
+		}
+		txn, err := store.Begin()
+		c.Assert(err, IsNil)
+		for _, rp := range rows {
+			for _, pair := range rp {
+				c.Assert(txn.Set(pair.Key, pair.Val), IsNil)
+				versions[string(pair.Key)] = append(versions[string(pair.Key)], keyVersion{value: pair.Val, commitTS: ts})
+			}
+		}
+		c.Assert(txn.Commit(context.Background()), IsNil)
+	}
+	keyAdapter := duplicateKeyAdapter{}
+	for key, vs := range versions {
+		if len(vs) < 2 {
+			continue
+		}
+		for _, v := range vs {
+			c.Assert(db.Set(keyAdapter.Encode(nil, []byte(key), 0, int64(v.commitTS)), v.value, nil), IsNil)
+		}
+	}
+
+	manager, err := NewDuplicateManager(db, nil, 0, nil, 1)
+	c.Assert(err, IsNil)
+	replacedChecksum, err := manager.mergeExistingRows(context.Background(), tbl, "`db`.`t`", options, store, importedTS-1)
+	c.Assert(err, IsNil)
+
+	expected := append(append(append([]common.KvPair{}, imported[0]...), existing[2]...), imported[1]...)
+	sort.Slice(expected, func(i, j int) bool {
+		return bytes.Compare(expected[i].Key, expected[j].Key) < 0
+	})
+	actual := scanTable(c, store, tblInfo.ID)
+	c.Assert(actual, HasLen, len(expected))
+	for i := range expected {
+		c.Assert(actual[i].Key, BytesEquals, expected[i].Key)
+		c.Assert(actual[i].Val, BytesEquals, expected[i].Val)
+	}
+
+	// existing + imported - replaced = merged
+	var expectedChecksum verification.KVChecksum
+	expectedChecksum.Update(existing[0])
+	expectedChecksum.Update(existing[1])
+	c.Assert(replacedChecksum, Equals, expectedChecksum)
+}
+
+func (s *duplicateSuite) TestReplacedVersions(c *C) {
+	versions := []keyVersion{
+		{value: []byte("v1"), commitTS: 1},
+		{value: []byte("v2"), commitTS: 3},
+		{value: []byte("v3"), commitTS: 6},
+		{value: []byte("v4"), commitTS: 8},
+	}
+	c.Assert(replacedVersions(versions, 5), DeepEquals, versions[1:3])
+	c.Assert(replacedVersions(versions, 0), DeepEquals, versions[:3])
+	c.Assert(replacedVersions(versions, 8), HasLen, 0)
+	c.Assert(replacedVersions(versions[:1], 0), HasLen, 0)
+}
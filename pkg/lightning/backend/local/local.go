@@ -25,6 +25,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -42,13 +43,13 @@ import (
 	"github.com/pingcap/kvproto/pkg/kvrpcpb"
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/parser/model"
-	"github.com/pingcap/parser/mysql"
-	"github.com/pingcap/tidb/distsql"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/store/driver"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/hack"
-	"github.com/pingcap/tidb/util/ranger"
+	tikvconfig "github.com/tikv/client-go/v2/config"
 	"github.com/tikv/client-go/v2/oracle"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
@@ -67,16 +68,19 @@ import (
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/manual"
 	"github.com/pingcap/br/pkg/lightning/metric"
 	"github.com/pingcap/br/pkg/lightning/tikv"
+	"github.com/pingcap/br/pkg/lightning/verification"
 	"github.com/pingcap/br/pkg/lightning/worker"
 	"github.com/pingcap/br/pkg/logutil"
 	"github.com/pingcap/br/pkg/membuf"
 	"github.com/pingcap/br/pkg/pdutil"
 	split "github.com/pingcap/br/pkg/restore"
+	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/utils"
 	"github.com/pingcap/br/pkg/version"
 )
@@ -105,6 +109,8 @@ const (
 
 	duplicateDBName       = "duplicates"
 	remoteDuplicateDBName = "remote_duplicates"
+	upsertVersionsDBName  = "upsert_versions"
+	upsertTSSuffix        = ".upsert-ts"
 	scanRegionLimit       = 128
 )
 
@@ -206,6 +212,11 @@ type File struct {
 	keyAdapter         KeyAdapter
 	duplicateDetection bool
 	duplicateDB        *pebble.DB
+
+	// spilled indicates the engine DB is spilled into the external storage,
+	// and spilledSize is the total size of the spilled files.
+	spilled     atomic.Bool
+	spilledSize atomic.Int64
 }
 
 func (e *File) setError(err error) {
@@ -230,6 +241,9 @@ func (e *File) Cleanup(dataDir string) error {
 	if err := os.RemoveAll(e.sstDir); err != nil {
 		return errors.Trace(err)
 	}
+	if err := os.RemoveAll(spilledEngineMetaPath(dataDir, e.UUID)); err != nil {
+		return errors.Trace(err)
+	}
 
 	dbPath := filepath.Join(dataDir, e.UUID.String())
 	return os.RemoveAll(dbPath)
@@ -299,6 +313,13 @@ func (e *File) isLocked() bool {
 }
 
 func (e *File) getEngineFileSize() backend.EngineFileSize {
+	if e.spilled.Load() {
+		return backend.EngineFileSize{
+			UUID:        e.UUID,
+			RemoteSize:  e.spilledSize.Load(),
+			IsImporting: e.isLocked(),
+		}
+	}
 	metrics := e.db.Metrics()
 	total := metrics.Total()
 	var memSize int64
@@ -823,8 +844,18 @@ type local struct {
 	localWriterMemCacheSize int64
 	supportMultiIngest      bool
 
-	duplicateDetection bool
-	duplicateDB        *pebble.DB
+	duplicateDetection  bool
+	duplicateResolution string
+	duplicateDB         *pebble.DB
+	errorMgr            *errormanager.ErrorManager
+	metrics             *metric.Metrics
+
+	upsert     bool
+	upsertTSMu sync.Mutex
+
+	// spillStorage is the external storage into which the closed engines are
+	// spilled, or nil if spilling is disabled.
+	spillStorage storage.ExternalStorage
 }
 
 // connPool is a lazy pool of gRPC channels.
@@ -894,6 +925,25 @@ func openDuplicateDB(storeDir string) (*pebble.DB, error) {
 	return pebble.Open(dbPath, opts)
 }
 
+// checkOnlyBackend is a local backend which only checks the requirements,
+// fetches the table models and encodes the rows.
+type checkOnlyBackend struct {
+	*local
+}
+
+// NewCheckOnlyBackend creates a local backend for the pre-checks, which
+// neither creates any local files nor connects to the cluster until the
+// requirements are checked. It must not be used to import data.
+func NewCheckOnlyBackend(tls *common.TLS, pdAddr string, g glue.Glue) backend.Backend {
+	return backend.MakeBackend(checkOnlyBackend{
+		local: &local{tls: tls, pdAddr: pdAddr, g: g},
+	})
+}
+
+// Close implements backend.AbstractBackend. Nothing is opened by the
+// check-only backend.
+func (checkOnlyBackend) Close() {}
+
 // NewLocalBackend creates new connections to tikv.
 func NewLocalBackend(
 	ctx context.Context,
@@ -903,6 +953,8 @@ func NewLocalBackend(
 	enableCheckpoint bool,
 	g glue.Glue,
 	maxOpenFiles int,
+	errorMgr *errormanager.ErrorManager,
+	metrics *metric.Metrics,
 ) (backend.Backend, error) {
 	localFile := cfg.SortedKVDir
 	rangeConcurrency := cfg.RangeConcurrency
@@ -931,6 +983,18 @@ func NewLocalBackend(
 		}
 	}
 
+	var spillStorage storage.ExternalStorage
+	if len(cfg.SortedKVSpillURL) > 0 {
+		u, err := storage.ParseBackend(cfg.SortedKVSpillURL, nil)
+		if err != nil {
+			return backend.MakeBackend(nil), errors.Annotate(err, "parse sorted-kv-spill-url failed")
+		}
+		spillStorage, err = storage.New(ctx, u, &storage.ExternalStorageOptions{})
+		if err != nil {
+			return backend.MakeBackend(nil), errors.Annotate(err, "create storage for sorted-kv-spill-url failed")
+		}
+	}
+
 	var duplicateDB *pebble.DB
 	if cfg.DuplicateDetection {
 		duplicateDB, err = openDuplicateDB(localFile)
@@ -967,7 +1031,12 @@ func NewLocalBackend(
 		engineMemCacheSize:      int(cfg.EngineMemCacheSize),
 		localWriterMemCacheSize: int64(cfg.LocalWriterMemCacheSize),
 		duplicateDetection:      cfg.DuplicateDetection,
+		duplicateResolution:     cfg.DuplicateResolution,
 		duplicateDB:             duplicateDB,
+		errorMgr:                errorMgr,
+		metrics:                 metrics,
+		upsert:                  cfg.Upsert,
+		spillStorage:            spillStorage,
 	}
 	local.conns = common.NewGRPCConns()
 	if err = local.checkMultiIngestSupport(ctx, pdCtl); err != nil {
@@ -1270,6 +1339,11 @@ func (local *local) OpenEngine(ctx context.Context, cfg *backend.EngineConfig, e
 	if err = local.allocateTSIfNotExists(ctx, engine); err != nil {
 		return errors.Trace(err)
 	}
+	if local.upsert && cfg.TableInfo != nil {
+		if err = local.saveUpsertTS(cfg.TableInfo.ID, engine.TS); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	engine.wg.Add(1)
 	go engine.ingestSSTLoop()
 	return nil
@@ -1288,6 +1362,41 @@ func (local *local) allocateTSIfNotExists(ctx context.Context, engine *File) err
 	return engine.saveEngineMeta()
 }
 
+// upsertTSPath returns the path of the file recording the minimum timestamp of
+// the engines of the table in upsert mode. The versions committed before the
+// timestamp are the existing ones.
+func (local *local) upsertTSPath(tableID int64) string {
+	return filepath.Join(local.localStoreDir, fmt.Sprintf("%d%s", tableID, upsertTSSuffix))
+}
+
+// saveUpsertTS records ts as the minimum timestamp of the engines of the table
+// if it is smaller than the recorded one.
+func (local *local) saveUpsertTS(tableID int64, ts uint64) error {
+	local.upsertTSMu.Lock()
+	defer local.upsertTSMu.Unlock()
+	savedTS, err := local.loadUpsertTS(tableID)
+	if err != nil {
+		return err
+	}
+	if savedTS > 0 && savedTS <= ts {
+		return nil
+	}
+	return errors.Trace(os.WriteFile(local.upsertTSPath(tableID), []byte(strconv.FormatUint(ts, 10)), 0o644))
+}
+
+// loadUpsertTS returns the minimum timestamp of the engines of the table, or 0
+// if it is not recorded.
+func (local *local) loadUpsertTS(tableID int64) (uint64, error) {
+	content, err := os.ReadFile(local.upsertTSPath(tableID))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, errors.Trace(err)
+	}
+	ts, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	return ts, errors.Annotatef(err, "invalid upsert timestamp of table %d", tableID)
+}
+
 // CloseEngine closes backend engine by uuid
 // NOTE: we will return nil if engine is not exist. This will happen if engine import&cleanup successfully
 // but exit before update checkpoint. Thus after restart, we will try to import this engine again.
@@ -1297,24 +1406,35 @@ func (local *local) CloseEngine(ctx context.Context, cfg *backend.EngineConfig,
 	engine, ok := local.engines.Load(engineUUID)
 	if !ok {
 		// recovery mode, we should reopen this engine file
-		db, err := local.openEngineDB(engineUUID, true)
-		if err != nil {
-			// if engine db does not exist, just skip
-			if os.IsNotExist(errors.Cause(err)) {
-				return nil
-			}
-			return err
-		}
 		engineFile := &File{
 			UUID:               engineUUID,
-			db:                 db,
 			sstMetasChan:       make(chan metaOrFlush),
 			tableInfo:          cfg.TableInfo,
 			duplicateDetection: local.duplicateDetection,
 			duplicateDB:        local.duplicateDB,
 		}
 		engineFile.sstIngester = dbSSTIngester{e: engineFile}
-		if err = engineFile.loadEngineMeta(); err != nil {
+		engineFile.closed.Store(true)
+		db, err := local.openEngineDB(engineUUID, true)
+		switch {
+		case err == nil:
+			engineFile.db = db
+			if err = engineFile.loadEngineMeta(); err != nil {
+				return err
+			}
+		case os.IsNotExist(errors.Cause(err)):
+			// the engine db may be spilled into the external storage.
+			spilled, err := local.loadSpilledEngineMeta(engineUUID, &engineFile.localFileMeta)
+			if err != nil {
+				return err
+			}
+			// if engine db does not exist, just skip
+			if spilled == nil {
+				return nil
+			}
+			engineFile.spilledSize.Store(spilled.totalSize())
+			engineFile.spilled.Store(true)
+		default:
 			return err
 		}
 		local.engines.Store(engineUUID, engineFile)
@@ -1881,7 +2001,7 @@ loopWrite:
 			engineFile.importedKVSize.Add(rangeStats.totalBytes)
 			engineFile.importedKVCount.Add(rangeStats.count)
 			engineFile.finishedRanges.add(finishedRange)
-			metric.BytesCounter.WithLabelValues(metric.TableStateImported).Add(float64(rangeStats.totalBytes))
+			local.metrics.BytesCounter.WithLabelValues(metric.TableStateImported).Add(float64(rangeStats.totalBytes))
 		}
 		return errors.Trace(err)
 	}
@@ -1973,6 +2093,9 @@ func (local *local) ImportEngine(ctx context.Context, engineUUID uuid.UUID) erro
 		log.L().Info("engine contains no kv, skip import", zap.Stringer("engine", engineUUID))
 		return nil
 	}
+	if err := local.loadSpilledEngine(ctx, lf); err != nil {
+		return err
+	}
 
 	// split sorted file into range by 96MB size per file
 	ranges, err := local.readAndSplitIntoRange(ctx, lf)
@@ -2031,7 +2154,7 @@ func (local *local) ImportEngine(ctx context.Context, engineUUID uuid.UUID) erro
 	return nil
 }
 
-func (local *local) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (local *local) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
 	if local.duplicateDB == nil {
 		return nil
 	}
@@ -2050,10 +2173,10 @@ func (local *local) CollectLocalDuplicateRows(ctx context.Context, tbl table.Tab
 	if err := duplicateManager.CollectDuplicateRowsFromLocalIndex(ctx, tbl, local.duplicateDB); err != nil {
 		return errors.Annotate(err, "collect local duplicate rows failed")
 	}
-	return local.reportDuplicateRows(tbl, local.duplicateDB)
+	return duplicateManager.ReportDuplicateData(ctx, tbl, tableName, local.errorMgr)
 }
 
-func (local *local) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (local *local) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
 	log.L().Info("Begin collect remote duplicate keys", zap.String("table", tbl.Meta().Name.String()))
 	physicalTS, logicalTS, err := local.pdCtl.GetPDClient().GetTS(ctx)
 	if err != nil {
@@ -2074,66 +2197,121 @@ func (local *local) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Ta
 	if err != nil {
 		return errors.Annotate(err, "open duplicatemanager failed")
 	}
-	if err = duplicateManager.CollectDuplicateRowsFromTiKV(ctx, tbl); err != nil {
-		return errors.Annotate(err, "collect remote duplicate rows failed")
-	}
-	err = local.reportDuplicateRows(tbl, duplicateDB)
+	err = duplicateManager.CollectDuplicateRowsFromTiKV(ctx, tbl, tableName, local.errorMgr)
 	duplicateDB.Close()
-	return err
+	return errors.Annotate(err, "collect remote duplicate rows failed")
 }
 
-func (local *local) reportDuplicateRows(tbl table.Table, db *pebble.DB) error {
-	log.L().Info("Begin report duplicate rows", zap.String("table", tbl.Meta().Name.String()))
-	decoder, err := kv.NewTableKVDecoder(tbl, &kv.SessionOptions{
-		SQLMode: mysql.ModeStrictAllTables,
-	})
+func (local *local) ResolveDuplicateRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	locator backend.RowSourceLocator,
+) (verification.KVChecksum, error) {
+	if local.duplicateDB == nil || local.duplicateResolution == config.DupeResAlgNone {
+		return verification.KVChecksum{}, nil
+	}
+
+	store, err := local.openTiKVStore()
 	if err != nil {
-		return errors.Annotate(err, "create decoder failed")
-	}
-
-	ranges := ranger.FullIntRange(false)
-	keysRanges := distsql.TableRangesToKVRanges(tbl.Meta().ID, ranges, nil)
-	keyAdapter := duplicateKeyAdapter{}
-	var nextUserKey []byte = nil
-	for _, r := range keysRanges {
-		startKey := codec.EncodeBytes([]byte{}, r.StartKey)
-		endKey := codec.EncodeBytes([]byte{}, r.EndKey)
-		opts := &pebble.IterOptions{
-			LowerBound: startKey,
-			UpperBound: endKey,
-		}
-		iter := db.NewIter(opts)
-		for iter.SeekGE(startKey); iter.Valid(); iter.Next() {
-			nextUserKey, _, _, err = keyAdapter.Decode(nextUserKey[:0], iter.Key())
-			if err != nil {
-				log.L().Error("decode key error from index for duplicatedb",
-					zap.Error(err), logutil.Key("key", iter.Key()))
-				continue
-			}
+		return verification.KVChecksum{}, err
+	}
+	defer store.Close()
 
-			h, err := decoder.DecodeHandleFromTable(nextUserKey)
-			if err != nil {
-				log.L().Error("decode handle error from index for duplicatedb",
-					zap.Error(err), logutil.Key("key", iter.Key()))
-				continue
-			}
-			rows, _, err := decoder.DecodeRawRowData(h, iter.Value())
-			if err != nil {
-				log.L().Error("decode row error from index for duplicatedb",
-					zap.Error(err), logutil.Key("key", iter.Key()))
-				continue
-			}
-			// TODO: We need to output the duplicate rows into files or database.
-			//  Here I just output them for debug.
-			r := "row "
-			for _, row := range rows {
-				r += "," + row.String()
-			}
-			log.L().Info(r)
+	duplicateManager, err := NewDuplicateManager(local.duplicateDB, local.splitCli, 0, local.tls, local.tcpConcurrency)
+	if err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "open duplicatemanager failed")
+	}
+	removed, err := duplicateManager.RepairDuplicateData(ctx, tbl, tableName, options, store, local.duplicateResolution, locator, local.errorMgr)
+	if err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "resolve duplicate rows failed")
+	}
+	return removed, nil
+}
+
+func (local *local) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+) (verification.KVChecksum, error) {
+	if !local.upsert {
+		return verification.KVChecksum{}, nil
+	}
+	tableID := tbl.Meta().ID
+	minTS, err := local.loadUpsertTS(tableID)
+	if err != nil {
+		return verification.KVChecksum{}, err
+	}
+	if minTS == 0 {
+		// no engine is opened.
+		log.L().Warn("skip merging existing rows, the timestamp of the import is unknown", zap.String("table", tableName))
+		return verification.KVChecksum{}, nil
+	}
+
+	physicalTS, logicalTS, err := local.pdCtl.GetPDClient().GetTS(ctx)
+	if err != nil {
+		return verification.KVChecksum{}, err
+	}
+	ts := oracle.ComposeTS(physicalTS, logicalTS)
+
+	dbPath := filepath.Join(local.localStoreDir, upsertVersionsDBName)
+	if err = os.RemoveAll(dbPath); err != nil {
+		return verification.KVChecksum{}, errors.Trace(err)
+	}
+	versionsDB, err := pebble.Open(dbPath, &pebble.Options{})
+	if err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "open upsert versions db failed")
+	}
+	defer func() {
+		versionsDB.Close()
+		if err := os.RemoveAll(dbPath); err != nil {
+			log.L().Warn("remove upsert versions db failed", zap.Error(err))
 		}
-		iter.Close()
+	}()
+
+	store, err := local.openTiKVStore()
+	if err != nil {
+		return verification.KVChecksum{}, err
 	}
-	return nil
+	defer store.Close()
+
+	duplicateManager, err := NewDuplicateManager(versionsDB, local.splitCli, ts, local.tls, local.tcpConcurrency)
+	if err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "open duplicatemanager failed")
+	}
+	// the engines are ingested with their own timestamps, so the versions
+	// committed at minTS are imported ones.
+	replaced, err := duplicateManager.MergeExistingRows(ctx, tbl, tableName, options, store, minTS-1)
+	if err != nil {
+		return verification.KVChecksum{}, errors.Annotate(err, "merge existing rows failed")
+	}
+	return replaced, nil
+}
+
+func (local *local) FinishMergeExistingRows(ctx context.Context, tbl table.Table) error {
+	if !local.upsert {
+		return nil
+	}
+	err := os.Remove(local.upsertTSPath(tbl.Meta().ID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+// openTiKVStore opens the transactional KV store of the cluster.
+func (local *local) openTiKVStore() (tidbkv.Storage, error) {
+	tlsOpt := local.tls.ToPDSecurityOption()
+	store, err := driver.TiKVDriver{}.OpenWithOptions(
+		fmt.Sprintf("tikv://%s?disableGC=true", local.pdAddr),
+		driver.WithSecurity(tikvconfig.NewSecurity(tlsOpt.CAPath, tlsOpt.CertPath, tlsOpt.KeyPath, nil)),
+	)
+	if err != nil {
+		return nil, errors.Annotate(err, "open tikv store failed")
+	}
+	return store, nil
 }
 
 func (e *File) unfinishedRanges(ranges []Range) []Range {
@@ -2225,6 +2403,7 @@ func (local *local) ResetEngine(ctx context.Context, engineUUID uuid.UUID) error
 	if err := localEngine.Close(); err != nil {
 		return err
 	}
+	local.cleanupSpilledEngine(ctx, localEngine)
 	if err := localEngine.Cleanup(local.localStoreDir); err != nil {
 		return err
 	}
@@ -2239,6 +2418,8 @@ func (local *local) ResetEngine(ctx context.Context, engineUUID uuid.UUID) error
 		}
 		localEngine.db = db
 		localEngine.localFileMeta = meta
+		localEngine.spilled.Store(false)
+		localEngine.spilledSize.Store(0)
 		if !common.IsDirExists(localEngine.sstDir) {
 			if err := os.Mkdir(localEngine.sstDir, 0o755); err != nil {
 				return errors.Trace(err)
@@ -2272,6 +2453,7 @@ func (local *local) CleanupEngine(ctx context.Context, engineUUID uuid.UUID) err
 	if err != nil {
 		return err
 	}
+	local.cleanupSpilledEngine(ctx, localEngine)
 	err = localEngine.Cleanup(local.localStoreDir)
 	if err != nil {
 		return err
@@ -0,0 +1,324 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/go-units"
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+const (
+	// spilledEngineSuffix is the suffix of the file in the sorted-kv-dir
+	// recording the engine spilled into the external storage.
+	spilledEngineSuffix = ".spilled"
+	// loadingEngineSuffix is the suffix of the directory which the spilled
+	// engine is downloaded into before it is renamed as the engine directory.
+	loadingEngineSuffix = ".loading"
+
+	spillBufferSize = 8 * units.MiB
+)
+
+// spilledFile is a file of the engine DB spilled into the external storage.
+type spilledFile struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// spilledEngineMeta is saved in the sorted-kv-dir after the engine is spilled,
+// as the engine meta saved in the engine DB is not available locally.
+type spilledEngineMeta struct {
+	Meta  *localFileMeta `json:"meta"`
+	Files []spilledFile  `json:"files"`
+}
+
+func (m *spilledEngineMeta) totalSize() (size int64) {
+	for _, f := range m.Files {
+		size += f.Size
+	}
+	return
+}
+
+func spilledEngineMetaPath(storeDir string, engineUUID uuid.UUID) string {
+	return filepath.Join(storeDir, engineUUID.String()+spilledEngineSuffix)
+}
+
+// spilledFileName returns the name of the spilled file in the external
+// storage. The name is flat since not all storages create the directories.
+func spilledFileName(engineUUID uuid.UUID, name string) string {
+	return engineUUID.String() + "_" + name
+}
+
+// loadSpilledEngineMeta loads the meta of the spilled engine into `meta`, and
+// returns nil if the engine is not spilled.
+func (local *local) loadSpilledEngineMeta(engineUUID uuid.UUID, meta *localFileMeta) (*spilledEngineMeta, error) {
+	content, err := os.ReadFile(spilledEngineMetaPath(local.localStoreDir, engineUUID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Trace(err)
+	}
+	spilled := &spilledEngineMeta{Meta: meta}
+	if err := json.Unmarshal(content, spilled); err != nil {
+		return nil, errors.Annotatef(err, "invalid spilled engine meta of engine %s", engineUUID)
+	}
+	return spilled, nil
+}
+
+// SpillEngine uploads the files of a closed engine into the external storage
+// configured by `tikv-importer.sorted-kv-spill-url`, and removes the local
+// files. The engine is loaded back by ImportEngine.
+func (local *local) SpillEngine(ctx context.Context, engineUUID uuid.UUID) (bool, error) {
+	if local.spillStorage == nil {
+		return false, nil
+	}
+	e, ok := local.engines.Load(engineUUID)
+	if !ok {
+		return false, nil
+	}
+	engine := e.(*File)
+	// the engine being imported is going to be cleaned up, spilling it is a waste.
+	if !engine.lockUnless(importMutexStateClose, importMutexStateImport|importMutexStateClose) {
+		return false, nil
+	}
+	defer engine.unlock()
+	if !engine.closed.Load() || engine.spilled.Load() || engine.db == nil {
+		return false, nil
+	}
+
+	task := log.With(zap.Stringer("engine", engineUUID)).Begin(zap.InfoLevel, "spill engine")
+	spilled, err := local.spillEngine(ctx, engine)
+	task.End(zap.ErrorLevel, err, zap.Int64("size", spilled.totalSize()))
+	if err != nil {
+		return false, err
+	}
+	engine.spilledSize.Store(spilled.totalSize())
+	engine.spilled.Store(true)
+	return true, nil
+}
+
+func (local *local) spillEngine(ctx context.Context, engine *File) (spilled *spilledEngineMeta, err error) {
+	spilled = &spilledEngineMeta{Meta: &engine.localFileMeta}
+	if err = engine.Close(); err != nil {
+		return spilled, err
+	}
+	defer func() {
+		// keep the engine available locally if it is not spilled.
+		if err != nil {
+			local.removeSpilledFiles(ctx, engine.UUID, spilled)
+			db, openErr := local.openEngineDB(engine.UUID, false)
+			if openErr != nil {
+				log.L().Error("reopen engine failed", zap.Stringer("engine", engine.UUID), log.ShortError(openErr))
+				return
+			}
+			engine.db = db
+		}
+	}()
+
+	dbPath := filepath.Join(local.localStoreDir, engine.UUID.String())
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return spilled, errors.Trace(err)
+	}
+	buf := make([]byte, spillBufferSize)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		size, err := local.uploadSpilledFile(ctx, filepath.Join(dbPath, entry.Name()),
+			spilledFileName(engine.UUID, entry.Name()), buf)
+		if err != nil {
+			return spilled, errors.Annotatef(err, "spill file %s of engine %s failed", entry.Name(), engine.UUID)
+		}
+		spilled.Files = append(spilled.Files, spilledFile{Name: entry.Name(), Size: size})
+	}
+
+	content, err := json.Marshal(spilled)
+	if err != nil {
+		return spilled, errors.Trace(err)
+	}
+	// the engine is spilled once the meta is saved, and the engine directory
+	// left by a crash before it is removed is preferred on restart.
+	metaPath := spilledEngineMetaPath(local.localStoreDir, engine.UUID)
+	if err = os.WriteFile(metaPath+".tmp", content, 0o644); err != nil {
+		return spilled, errors.Trace(err)
+	}
+	if err = os.Rename(metaPath+".tmp", metaPath); err != nil {
+		return spilled, errors.Trace(err)
+	}
+	if removeErr := os.RemoveAll(dbPath); removeErr != nil {
+		log.L().Warn("remove spilled engine files failed", zap.Stringer("engine", engine.UUID), log.ShortError(removeErr))
+	}
+	return spilled, nil
+}
+
+func (local *local) uploadSpilledFile(ctx context.Context, path string, name string, buf []byte) (_ int64, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer file.Close()
+
+	writer, err := local.spillStorage.Create(ctx, name)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	closed := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		// the storage writer has no abort, so the partial file is closed and deleted.
+		if !closed {
+			if closeErr := writer.Close(ctx); closeErr != nil {
+				log.L().Warn("close spilled file failed", zap.String("file", name), log.ShortError(closeErr))
+			}
+		}
+		if deleteErr := local.spillStorage.DeleteFile(ctx, name); deleteErr != nil {
+			log.L().Warn("remove partial spilled file failed", zap.String("file", name), log.ShortError(deleteErr))
+		}
+	}()
+	var size int64
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if _, err := writer.Write(ctx, buf[:n]); err != nil {
+				return 0, errors.Trace(err)
+			}
+			size += int64(n)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+	closed = true
+	return size, errors.Trace(writer.Close(ctx))
+}
+
+// removeSpilledFiles deletes the spilled files of the engine from the external
+// storage. The failures are only logged, as the files are useless anyway.
+func (local *local) removeSpilledFiles(ctx context.Context, engineUUID uuid.UUID, spilled *spilledEngineMeta) {
+	for _, f := range spilled.Files {
+		name := spilledFileName(engineUUID, f.Name)
+		if err := local.spillStorage.DeleteFile(ctx, name); err != nil {
+			log.L().Warn("remove spilled file failed", zap.Stringer("engine", engineUUID),
+				zap.String("file", name), log.ShortError(err))
+		}
+	}
+}
+
+// cleanupSpilledEngine deletes the spilled files of the engine being cleaned
+// up from the external storage. This method must be called with holding the
+// lock of the engine, and before the spilled engine meta is removed.
+func (local *local) cleanupSpilledEngine(ctx context.Context, engine *File) {
+	if !engine.spilled.Load() || local.spillStorage == nil {
+		return
+	}
+	spilled, err := local.loadSpilledEngineMeta(engine.UUID, &localFileMeta{})
+	if err != nil || spilled == nil {
+		log.L().Warn("load spilled engine meta failed, the spilled files are left in the external storage",
+			zap.Stringer("engine", engine.UUID), log.ShortError(err))
+		return
+	}
+	local.removeSpilledFiles(ctx, engine.UUID, spilled)
+}
+
+// loadSpilledEngine downloads the files of the spilled engine from the
+// external storage and reopens the engine DB. This method must be called with
+// holding the lock of the engine.
+func (local *local) loadSpilledEngine(ctx context.Context, engine *File) error {
+	if !engine.spilled.Load() {
+		return nil
+	}
+	if local.spillStorage == nil {
+		return errors.Errorf("engine %s is spilled but `tikv-importer.sorted-kv-spill-url` is not set", engine.UUID)
+	}
+	// the meta in memory is more recent than the saved one.
+	spilled, err := local.loadSpilledEngineMeta(engine.UUID, &localFileMeta{})
+	if err != nil {
+		return err
+	}
+	if spilled == nil {
+		return errors.Errorf("the meta of the spilled engine %s is missing", engine.UUID)
+	}
+
+	task := log.With(zap.Stringer("engine", engine.UUID)).Begin(zap.InfoLevel, "load spilled engine")
+	err = local.downloadSpilledEngine(ctx, engine.UUID, spilled)
+	task.End(zap.ErrorLevel, err, zap.Int64("size", spilled.totalSize()))
+	if err != nil {
+		return err
+	}
+
+	db, err := local.openEngineDB(engine.UUID, false)
+	if err != nil {
+		return err
+	}
+	engine.db = db
+	engine.spilled.Store(false)
+	engine.spilledSize.Store(0)
+	if err := os.Remove(spilledEngineMetaPath(local.localStoreDir, engine.UUID)); err != nil {
+		log.L().Warn("remove spilled engine meta failed", zap.Stringer("engine", engine.UUID), log.ShortError(err))
+	}
+	local.removeSpilledFiles(ctx, engine.UUID, spilled)
+	return nil
+}
+
+func (local *local) downloadSpilledEngine(ctx context.Context, engineUUID uuid.UUID, spilled *spilledEngineMeta) error {
+	dbPath := filepath.Join(local.localStoreDir, engineUUID.String())
+	loadingPath := dbPath + loadingEngineSuffix
+	if err := os.RemoveAll(loadingPath); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.Mkdir(loadingPath, 0o755); err != nil {
+		return errors.Trace(err)
+	}
+	for _, f := range spilled.Files {
+		if err := downloadSpilledFile(ctx, local.spillStorage, spilledFileName(engineUUID, f.Name),
+			filepath.Join(loadingPath, f.Name)); err != nil {
+			return errors.Annotatef(err, "load spilled file %s of engine %s failed", f.Name, engineUUID)
+		}
+	}
+	// the engine directory appears only after all the files are downloaded.
+	return errors.Trace(os.Rename(loadingPath, dbPath))
+}
+
+func downloadSpilledFile(ctx context.Context, s storage.ExternalStorage, name string, path string) error {
+	reader, err := s.Open(ctx, name)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer reader.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		return errors.Trace(err)
+	}
+	return errors.Trace(file.Close())
+}
@@ -0,0 +1,203 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/google/uuid"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/backend"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type spillSuite struct{}
+
+var _ = Suite(&spillSuite{})
+
+// newSpillTestEngine creates a closed engine with 100 KV pairs.
+func newSpillTestEngine(c *C, local *local) *File {
+	engineUUID := uuid.New()
+	db, err := local.openEngineDB(engineUUID, false)
+	c.Assert(err, IsNil)
+	engine := &File{UUID: engineUUID, db: db}
+	for i := 0; i < 100; i++ {
+		key, value := fmt.Sprintf("key%03d", i), fmt.Sprintf("value%03d", i)
+		c.Assert(db.Set([]byte(key), []byte(value), &pebble.WriteOptions{}), IsNil)
+		engine.Length.Inc()
+		engine.TotalSize.Add(int64(len(key) + len(value)))
+	}
+	c.Assert(engine.saveEngineMeta(), IsNil)
+	c.Assert(db.Flush(), IsNil)
+	engine.closed.Store(true)
+	local.engines.Store(engineUUID, engine)
+	return engine
+}
+
+func spilledFileCount(c *C, s storage.ExternalStorage) int {
+	count := 0
+	err := s.WalkDir(context.Background(), &storage.WalkOption{}, func(string, int64) error {
+		count++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return count
+}
+
+func (s *spillSuite) TestSpillAndLoadEngine(c *C) {
+	ctx := context.Background()
+	storeDir := c.MkDir()
+	spillStorage, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	localBackend := &local{localStoreDir: storeDir, spillStorage: spillStorage}
+
+	engine := newSpillTestEngine(c, localBackend)
+	dbPath := filepath.Join(storeDir, engine.UUID.String())
+
+	spilled, err := localBackend.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(spilled, IsTrue)
+	c.Assert(engine.db, IsNil)
+	_, err = os.Stat(dbPath)
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	sizes := localBackend.EngineFileSizes()
+	c.Assert(sizes, HasLen, 1)
+	c.Assert(sizes[0].DiskSize, Equals, int64(0))
+	c.Assert(sizes[0].RemoteSize, Greater, int64(0))
+
+	// the spilled engine is not spilled again.
+	spilled, err = localBackend.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(spilled, IsFalse)
+
+	// the spilled engine is recovered from the spilled meta after restart.
+	recovered := &local{localStoreDir: storeDir, spillStorage: spillStorage}
+	c.Assert(recovered.CloseEngine(ctx, &backend.EngineConfig{}, engine.UUID), IsNil)
+	e, ok := recovered.engines.Load(engine.UUID)
+	c.Assert(ok, IsTrue)
+	recoveredEngine := e.(*File)
+	c.Assert(recoveredEngine.spilled.Load(), IsTrue)
+	c.Assert(recoveredEngine.Length.Load(), Equals, int64(100))
+	c.Assert(recoveredEngine.TotalSize.Load(), Equals, engine.TotalSize.Load())
+
+	recoveredEngine.lock(importMutexStateImport)
+	err = recovered.loadSpilledEngine(ctx, recoveredEngine)
+	recoveredEngine.unlock()
+	c.Assert(err, IsNil)
+	c.Assert(recoveredEngine.spilled.Load(), IsFalse)
+	_, err = os.Stat(spilledEngineMetaPath(storeDir, engine.UUID))
+	c.Assert(os.IsNotExist(err), IsTrue)
+	// the spilled files are deleted once loaded.
+	c.Assert(spilledFileCount(c, spillStorage), Equals, 0)
+
+	value, closer, err := recoveredEngine.db.Get([]byte("key042"))
+	c.Assert(err, IsNil)
+	c.Assert(string(value), Equals, "value042")
+	c.Assert(closer.Close(), IsNil)
+	c.Assert(recoveredEngine.Close(), IsNil)
+}
+
+func (s *spillSuite) TestSpillEngineUnsupported(c *C) {
+	ctx := context.Background()
+	storeDir := c.MkDir()
+
+	// spilling is disabled without the external storage.
+	local := &local{localStoreDir: storeDir}
+	engine := newSpillTestEngine(c, local)
+	spilled, err := local.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(spilled, IsFalse)
+
+	// the engine still being written is not spilled.
+	local.spillStorage, err = storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	engine.closed.Store(false)
+	spilled, err = local.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(spilled, IsFalse)
+	c.Assert(engine.Close(), IsNil)
+}
+
+func (s *spillSuite) TestCleanupSpilledEngine(c *C) {
+	ctx := context.Background()
+	storeDir := c.MkDir()
+	spillStorage, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	localBackend := &local{localStoreDir: storeDir, spillStorage: spillStorage}
+
+	engine := newSpillTestEngine(c, localBackend)
+	spilled, err := localBackend.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, IsNil)
+	c.Assert(spilled, IsTrue)
+	c.Assert(spilledFileCount(c, spillStorage), Greater, 0)
+
+	c.Assert(localBackend.CleanupEngine(ctx, engine.UUID), IsNil)
+	c.Assert(spilledFileCount(c, spillStorage), Equals, 0)
+	_, err = os.Stat(spilledEngineMetaPath(storeDir, engine.UUID))
+	c.Assert(os.IsNotExist(err), IsTrue)
+}
+
+// failingWriter fails to write, and records whether it is closed.
+type failingWriter struct {
+	closed *bool
+}
+
+func (w failingWriter) Write(ctx context.Context, p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func (w failingWriter) Close(ctx context.Context) error {
+	*w.closed = true
+	return nil
+}
+
+// failingStorage creates an empty file for each failing writer.
+type failingStorage struct {
+	storage.ExternalStorage
+	closed bool
+}
+
+func (s *failingStorage) Create(ctx context.Context, name string) (storage.ExternalFileWriter, error) {
+	if err := s.ExternalStorage.WriteFile(ctx, name, nil); err != nil {
+		return nil, err
+	}
+	return failingWriter{closed: &s.closed}, nil
+}
+
+func (s *spillSuite) TestSpillEngineFailed(c *C) {
+	ctx := context.Background()
+	storeDir := c.MkDir()
+	inner, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	spillStorage := &failingStorage{ExternalStorage: inner}
+	localBackend := &local{localStoreDir: storeDir, spillStorage: spillStorage}
+
+	engine := newSpillTestEngine(c, localBackend)
+	spilled, err := localBackend.SpillEngine(ctx, engine.UUID)
+	c.Assert(err, ErrorMatches, ".*write failed")
+	c.Assert(spilled, IsFalse)
+	// the writer is closed and the partial file is deleted.
+	c.Assert(spillStorage.closed, IsTrue)
+	c.Assert(spilledFileCount(c, inner), Equals, 0)
+	// the engine is still available locally.
+	c.Assert(engine.db, NotNil)
+	c.Assert(engine.Close(), IsNil)
+}
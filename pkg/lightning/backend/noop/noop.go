@@ -132,6 +132,12 @@ func (b noopBackend) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+// SpillEngine moves the files of a closed engine into the external storage,
+// and returns whether the engine is spilled.
+func (b noopBackend) SpillEngine(ctx context.Context, engineUUID uuid.UUID) (bool, error) {
+	return false, nil
+}
+
 // ResetEngine clears all written KV pairs in this opened engine.
 func (b noopBackend) ResetEngine(ctx context.Context, engineUUID uuid.UUID) error {
 	return nil
@@ -142,21 +148,44 @@ func (b noopBackend) LocalWriter(context.Context, *backend.LocalWriterConfig, uu
 	return noopWriter{}, nil
 }
 
-func (b noopBackend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (b noopBackend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	panic("Unsupported Operation")
+}
+
+func (b noopBackend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
 	panic("Unsupported Operation")
 }
 
-func (b noopBackend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (b noopBackend) ResolveDuplicateRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	locator backend.RowSourceLocator,
+) (verification.KVChecksum, error) {
 	panic("Unsupported Operation")
 }
 
+func (b noopBackend) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+) (verification.KVChecksum, error) {
+	panic("Unsupported Operation")
+}
+
+func (b noopBackend) FinishMergeExistingRows(ctx context.Context, tbl table.Table) error {
+	return nil
+}
+
 type noopEncoder struct{}
 
 // Close the encoder.
 func (e noopEncoder) Close() {}
 
 // Encode encodes a row of SQL values into a backend-friendly format.
-func (e noopEncoder) Encode(log.Logger, []types.Datum, int64, []int, int64) (kv.Row, error) {
+func (e noopEncoder) Encode(log.Logger, []types.Datum, int64, []int, string, int64) (kv.Row, error) {
 	return noopRow{}, nil
 }
 
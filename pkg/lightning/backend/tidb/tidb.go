@@ -20,8 +20,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	gmysql "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
@@ -30,6 +32,7 @@ import (
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/types"
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -37,6 +40,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/verification"
 	"github.com/pingcap/br/pkg/redact"
@@ -51,16 +55,39 @@ var extraHandleTableColumn = &table.Column{
 
 const (
 	writeRowsMaxRetryTimes = 3
+
+	// maxPlaceholders is the maximum number of the placeholders in a prepared
+	// statement supported by the MySQL protocol.
+	maxPlaceholders = 65535
+	// maxCachedStmts is the maximum number of the prepared statements cached
+	// by the backend.
+	maxCachedStmts = 256
+	// growRowsPerStmtAfter is the number of the consecutive succeeded
+	// statements after which the rows per statement is doubled again.
+	growRowsPerStmtAfter = 64
 )
 
-type tidbRow string
+type tidbRow struct {
+	// insertStmt is the tuple of the values in the INSERT statement.
+	insertStmt string
+	// placeholders is the tuple of the placeholders in the prepared statement,
+	// and args are the values bound to the placeholders.
+	placeholders string
+	args         []interface{}
+	path         string
+	offset       int64
+}
+
+func (row tidbRow) String() string {
+	return row.insertStmt
+}
 
 type tidbRows []tidbRow
 
 // MarshalLogArray implements the zapcore.ArrayMarshaler interface
 func (rows tidbRows) MarshalLogArray(encoder zapcore.ArrayEncoder) error {
 	for _, r := range rows {
-		encoder.AppendString(redact.String(string(r)))
+		encoder.AppendString(redact.String(r.insertStmt))
 	}
 	return nil
 }
@@ -73,29 +100,59 @@ type tidbEncoder struct {
 	// index == len(table.columns) means this field is `_tidb_rowid`
 	columnIdx []int
 	columnCnt int
+	// prepared indicates encoding the rows for the prepared statements.
+	prepared bool
 }
 
 type tidbBackend struct {
 	db          *sql.DB
 	onDuplicate string
+	errorMgr    *errormanager.ErrorManager
+
+	prepared bool
+	// maxRowsPerStmt is the configured maximum number of the rows written in a
+	// statement, 0 means no limit.
+	maxRowsPerStmt int
+	// rowsPerStmt is the current number of the rows written in a statement,
+	// which is shrunk when a statement is too large, 0 means no limit.
+	rowsPerStmt atomic.Int64
+	// succeededStmts is the number of the consecutive succeeded statements
+	// since rowsPerStmt is changed.
+	succeededStmts atomic.Int64
+
+	stmtsMu sync.Mutex
+	stmts   map[string]*sql.Stmt
 }
 
 // NewTiDBBackend creates a new TiDB backend using the given database.
 //
 // The backend does not take ownership of `db`. Caller should close `db`
 // manually after the backend expired.
-func NewTiDBBackend(db *sql.DB, onDuplicate string) backend.Backend {
+//
+// The rows rejected by TiDB are recorded by `errorMgr`, which could be nil if
+// no error is tolerated.
+func NewTiDBBackend(db *sql.DB, cfg *config.TikvImporter, errorMgr *errormanager.ErrorManager) backend.Backend {
+	onDuplicate := cfg.OnDuplicate
 	switch onDuplicate {
 	case config.ReplaceOnDup, config.IgnoreOnDup, config.ErrorOnDup:
 	default:
 		log.L().Warn("unsupported action on duplicate, overwrite with `replace`")
 		onDuplicate = config.ReplaceOnDup
 	}
-	return backend.MakeBackend(&tidbBackend{db: db, onDuplicate: onDuplicate})
+	be := &tidbBackend{
+		db:             db,
+		onDuplicate:    onDuplicate,
+		errorMgr:       errorMgr,
+		prepared:       cfg.PreparedStatement,
+		maxRowsPerStmt: cfg.RowsPerStatement,
+		stmts:          make(map[string]*sql.Stmt),
+	}
+	be.rowsPerStmt.Store(int64(cfg.RowsPerStatement))
+	return backend.MakeBackend(be)
 }
 
 func (row tidbRow) Size() uint64 {
-	return uint64(len(row))
+	return uint64(len(row.insertStmt))
 }
 
 func (row tidbRow) ClassifyAndAppend(data *kv.Rows, checksum *verification.KVChecksum, _ *kv.Rows, _ *verification.KVChecksum) {
@@ -103,7 +160,7 @@ func (row tidbRow) ClassifyAndAppend(data *kv.Rows, checksum *verification.KVChe
 	// Cannot do `rows := data.(*tidbRows); *rows = append(*rows, row)`.
 	//nolint:gocritic
 	*data = append(rows, row)
-	cs := verification.MakeKVChecksum(uint64(len(row)), 1, 0)
+	cs := verification.MakeKVChecksum(row.Size(), 1, 0)
 	checksum.Add(&cs)
 }
 
@@ -117,12 +174,12 @@ func (rows tidbRows) SplitIntoChunks(splitSize int) []kv.Rows {
 	cumSize := 0
 
 	for j, row := range rows {
-		if i < j && cumSize+len(row) > splitSize {
+		if i < j && cumSize+len(row.insertStmt) > splitSize {
 			res = append(res, rows[i:j])
 			i = j
 			cumSize = 0
 		}
-		cumSize += len(row)
+		cumSize += len(row.insertStmt)
 	}
 
 	return append(res, rows[i:])
@@ -178,7 +235,8 @@ func (enc *tidbEncoder) appendSQL(sb *strings.Builder, datum *types.Datum, _ *ta
 		sb.WriteString("NULL")
 
 	case types.KindMinNotNull:
-		sb.WriteString("MINVALUE")
+		// a MinNotNull datum marks a missing value, e.g. a missing key of a JSON object.
+		sb.WriteString("DEFAULT")
 
 	case types.KindMaxValue:
 		sb.WriteString("MAXVALUE")
@@ -253,6 +311,56 @@ func (enc *tidbEncoder) appendSQL(sb *strings.Builder, datum *types.Datum, _ *ta
 	return nil
 }
 
+// appendArg appends the placeholder of the Datum into the string builder, and
+// the Datum converted into the argument of the prepared statement into args.
+// The keywords DEFAULT and MAXVALUE are appended in place of the placeholder.
+func (enc *tidbEncoder) appendArg(sb *strings.Builder, args []interface{}, datum *types.Datum) ([]interface{}, error) {
+	switch datum.Kind() {
+	case types.KindMinNotNull:
+		sb.WriteString("DEFAULT")
+		return args, nil
+	case types.KindMaxValue:
+		sb.WriteString("MAXVALUE")
+		return args, nil
+	}
+
+	sb.WriteByte('?')
+	switch datum.Kind() {
+	case types.KindNull:
+		return append(args, nil), nil
+	case types.KindInt64:
+		return append(args, datum.GetInt64()), nil
+	case types.KindUint64, types.KindMysqlEnum, types.KindMysqlSet:
+		return append(args, datum.GetUint64()), nil
+	case types.KindFloat32, types.KindFloat64:
+		return append(args, datum.GetFloat64()), nil
+	case types.KindString, types.KindBytes:
+		// the bytes may be reused by the parser, so they must be copied.
+		return append(args, string(datum.GetBytes())), nil
+	case types.KindMysqlJSON:
+		value, err := datum.GetMysqlJSON().MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return append(args, string(value)), nil
+	case types.KindBinaryLiteral:
+		return append(args, []byte(datum.GetBinaryLiteral())), nil
+	case types.KindMysqlBit:
+		value, err := datum.GetBinaryLiteral().ToInt(nil)
+		if err != nil {
+			return nil, err
+		}
+		return append(args, value), nil
+	default:
+		// time, duration, decimal
+		value, err := datum.ToString()
+		if err != nil {
+			return nil, err
+		}
+		return append(args, value), nil
+	}
+}
+
 func (*tidbEncoder) Close() {}
 
 func getColumnByIndex(cols []*table.Column, index int) *table.Column {
@@ -262,7 +370,7 @@ func getColumnByIndex(cols []*table.Column, index int) *table.Column {
 	return cols[index]
 }
 
-func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, columnPermutation []int, _ int64) (kv.Row, error) {
+func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, columnPermutation []int, path string, offset int64) (kv.Row, error) {
 	cols := enc.tbl.Cols()
 
 	if len(enc.columnIdx) == 0 {
@@ -305,12 +413,50 @@ func (enc *tidbEncoder) Encode(logger log.Logger, row []types.Datum, _ int64, co
 		}
 	}
 	encoded.WriteByte(')')
-	return tidbRow(encoded.String()), nil
+	result := tidbRow{
+		insertStmt: encoded.String(),
+		path:       path,
+		offset:     offset,
+	}
+
+	if enc.prepared {
+		var placeholders strings.Builder
+		placeholders.Grow(2 * len(row))
+		placeholders.WriteByte('(')
+		args := make([]interface{}, 0, len(row))
+		for i, field := range row {
+			if i != 0 {
+				placeholders.WriteByte(',')
+			}
+			datum := field
+			var err error
+			if args, err = enc.appendArg(&placeholders, args, &datum); err != nil {
+				logger.Error("tidb encode failed",
+					zap.Array("original", kv.RowArrayMarshaler(row)),
+					zap.Int("originalCol", i),
+					log.ShortError(err),
+				)
+				return nil, err
+			}
+		}
+		placeholders.WriteByte(')')
+		result.placeholders = placeholders.String()
+		result.args = args
+	}
+	return result, nil
 }
 
 func (be *tidbBackend) Close() {
 	// *Not* going to close `be.db`. The db object is normally borrowed from a
 	// TidbManager, so we let the manager to close it.
+	be.stmtsMu.Lock()
+	defer be.stmtsMu.Unlock()
+	for query, stmt := range be.stmts {
+		if err := stmt.Close(); err != nil {
+			log.L().Warn("close prepared statement failed", zap.String("stmt", redact.String(query)), log.ShortError(err))
+		}
+	}
+	be.stmts = make(map[string]*sql.Stmt)
 }
 
 func (be *tidbBackend) MakeEmptyRows() kv.Rows {
@@ -344,7 +490,7 @@ func (be *tidbBackend) NewEncoder(tbl table.Table, options *kv.SessionOptions) (
 		se.GetSessionVars().SkipASCIICheck = false
 	}
 
-	return &tidbEncoder{mode: options.SQLMode, tbl: tbl, se: se}, nil
+	return &tidbEncoder{mode: options.SQLMode, tbl: tbl, se: se, prepared: be.prepared}, nil
 }
 
 func (be *tidbBackend) OpenEngine(context.Context, *backend.EngineConfig, uuid.UUID) error {
@@ -359,38 +505,216 @@ func (be *tidbBackend) CleanupEngine(context.Context, uuid.UUID) error {
 	return nil
 }
 
-func (be *tidbBackend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (be *tidbBackend) CollectLocalDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	panic("Unsupported Operation")
+}
+
+func (be *tidbBackend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table, tableName string) error {
+	panic("Unsupported Operation")
+}
+
+func (be *tidbBackend) ResolveDuplicateRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+	locator backend.RowSourceLocator,
+) (verification.KVChecksum, error) {
 	panic("Unsupported Operation")
 }
 
-func (be *tidbBackend) CollectRemoteDuplicateRows(ctx context.Context, tbl table.Table) error {
+func (be *tidbBackend) MergeExistingRows(
+	ctx context.Context,
+	tbl table.Table,
+	tableName string,
+	options *kv.SessionOptions,
+) (verification.KVChecksum, error) {
 	panic("Unsupported Operation")
 }
 
+func (be *tidbBackend) FinishMergeExistingRows(ctx context.Context, tbl table.Table) error {
+	return nil
+}
+
 func (be *tidbBackend) ImportEngine(context.Context, uuid.UUID) error {
 	return nil
 }
 
 func (be *tidbBackend) WriteRows(ctx context.Context, _ uuid.UUID, tableName string, columnNames []string, rows kv.Rows) error {
-	var err error
-outside:
 	for _, r := range rows.SplitIntoChunks(be.MaxChunkSize()) {
-		for i := 0; i < writeRowsMaxRetryTimes; i++ {
-			err = be.WriteRowsToDB(ctx, tableName, columnNames, r)
-			switch {
-			case err == nil:
-				continue outside
-			case common.IsRetryableError(err):
-				// retry next loop
-			default:
+		if err := be.writeChunk(ctx, tableName, columnNames, r.(tidbRows)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeChunk writes the rows in statements of at most `rowsPerStmt` rows. A
+// statement too large is retried with fewer rows, and a statement rejected by
+// TiDB is retried row by row to skip the rejected rows within the budget.
+func (be *tidbBackend) writeChunk(ctx context.Context, tableName string, columnNames []string, rows tidbRows) error {
+	for len(rows) > 0 {
+		n := be.stmtRows(rows)
+		err := be.writeRowsWithRetry(ctx, tableName, columnNames, rows[:n])
+		switch {
+		case err == nil:
+			be.onStmtSucceeded()
+			rows = rows[n:]
+		case common.IsStatementTooLargeError(err) && n > 1:
+			rowsPerStmt := be.shrinkRowsPerStmt(n)
+			log.L().Warn("statement too large, retry with fewer rows", zap.String("table", tableName),
+				zap.Int("rows", n), zap.Int("rowsPerStatement", rowsPerStmt), log.ShortError(err))
+		case isRejectedRowError(err) && be.errorMgr.Remaining(rejectedRowErrorKind(err)) > 0:
+			// the statement is rolled back as a whole, so we find out the
+			// rejected rows by writing the rows one by one.
+			if err = be.writeRowsOneByOne(ctx, tableName, columnNames, rows[:n]); err != nil {
 				return err
 			}
+			rows = rows[n:]
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRowsWithRetry writes the rows in a statement, and retries on the
+// retryable errors.
+func (be *tidbBackend) writeRowsWithRetry(ctx context.Context, tableName string, columnNames []string, rows tidbRows) error {
+	var err error
+	for i := 0; i < writeRowsMaxRetryTimes; i++ {
+		err = be.WriteRowsToDB(ctx, tableName, columnNames, rows)
+		if err == nil || !common.IsRetryableError(err) {
+			return err
+		}
+	}
+	return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, writeRowsMaxRetryTimes)
+}
+
+// writeRowsOneByOne writes every row in a statement, the rows rejected by TiDB
+// are recorded and skipped until the budget is exhausted.
+func (be *tidbBackend) writeRowsOneByOne(ctx context.Context, tableName string, columnNames []string, rows tidbRows) error {
+	logger := log.With(zap.String("table", tableName))
+	for i := range rows {
+		err := be.writeRowsWithRetry(ctx, tableName, columnNames, rows[i:i+1])
+		if err == nil {
+			continue
+		}
+		if !isRejectedRowError(err) {
+			return err
+		}
+		row := rows[i]
+		if rejectedRowErrorKind(err) == errormanager.ConflictError {
+			err = be.errorMgr.RecordConflictError(ctx, logger, tableName, row.path, row.offset, row.insertStmt, err)
+		} else {
+			err = be.errorMgr.RecordTypeError(ctx, logger, tableName, row.path, row.offset, row.insertStmt, err)
+		}
+		if err != nil {
+			return err
 		}
-		return errors.Annotatef(err, "[%s] write rows reach max retry %d and still failed", tableName, writeRowsMaxRetryTimes)
 	}
 	return nil
 }
 
+// rejectedRowErrorCodes are the codes of the errors caused by the values of a
+// row, such as truncation, out-of-range, invalid value, duplicate entry and
+// bad null.
+var rejectedRowErrorCodes = map[uint16]struct{}{
+	mysql.ErrBadNull:                     {},
+	mysql.ErrDupEntry:                    {},
+	mysql.ErrDupUnique:                   {},
+	mysql.ErrWarnNullToNotnull:           {},
+	mysql.ErrWarnDataOutOfRange:          {},
+	mysql.WarnDataTruncated:              {},
+	mysql.ErrTruncatedWrongValue:         {},
+	mysql.ErrInvalidCharacterString:      {},
+	mysql.ErrNoDefaultForField:           {},
+	mysql.ErrTruncatedWrongValueForField: {},
+	mysql.ErrIllegalValueForType:         {},
+	mysql.ErrDataTooLong:                 {},
+	mysql.ErrWrongValueForType:           {},
+	mysql.ErrWrongValue:                  {},
+	mysql.ErrDataOutOfRange:              {},
+	mysql.ErrInvalidJSONText:             {},
+}
+
+// isRejectedRowError checks if the error is caused by the data of the rows,
+// so the rows could be skipped. Any other error, e.g. an unknown column or a
+// denied access, fails every row and must not be skipped.
+func isRejectedRowError(err error) bool {
+	mysqlErr, ok := errors.Cause(err).(*gmysql.MySQLError)
+	if !ok {
+		return false
+	}
+	_, ok = rejectedRowErrorCodes[mysqlErr.Number]
+	return ok
+}
+
+// rejectedRowErrorKind returns the kind of the error rejecting a row.
+func rejectedRowErrorKind(err error) errormanager.ErrorKind {
+	if common.IsDuplicateEntryError(err) {
+		return errormanager.ConflictError
+	}
+	return errormanager.TypeError
+}
+
+// stmtRows returns the number of the rows written in the next statement.
+func (be *tidbBackend) stmtRows(rows tidbRows) int {
+	n := len(rows)
+	if limit := int(be.rowsPerStmt.Load()); limit > 0 && n > limit {
+		n = limit
+	}
+	if be.prepared {
+		placeholders := 0
+		for i, row := range rows[:n] {
+			placeholders += len(row.args)
+			if placeholders > maxPlaceholders {
+				// there is always at least one row in a statement.
+				if i == 0 {
+					i = 1
+				}
+				return i
+			}
+		}
+	}
+	return n
+}
+
+// shrinkRowsPerStmt halves the rows per statement after a statement of n rows
+// is too large, and returns the new rows per statement.
+func (be *tidbBackend) shrinkRowsPerStmt(n int) int {
+	target := int64(n / 2)
+	for {
+		current := be.rowsPerStmt.Load()
+		if current > 0 && current <= target {
+			return int(current)
+		}
+		if be.rowsPerStmt.CAS(current, target) {
+			be.succeededStmts.Store(0)
+			return int(target)
+		}
+	}
+}
+
+// onStmtSucceeded doubles the rows per statement after growRowsPerStmtAfter
+// consecutive statements succeed, until it reaches the configured limit.
+func (be *tidbBackend) onStmtSucceeded() {
+	current := be.rowsPerStmt.Load()
+	if current == 0 || current == int64(be.maxRowsPerStmt) {
+		return
+	}
+	if be.succeededStmts.Inc() < growRowsPerStmtAfter {
+		return
+	}
+	next := current * 2
+	if be.maxRowsPerStmt > 0 && next > int64(be.maxRowsPerStmt) {
+		next = int64(be.maxRowsPerStmt)
+	}
+	if be.rowsPerStmt.CAS(current, next) {
+		be.succeededStmts.Store(0)
+	}
+}
+
 func (be *tidbBackend) WriteRowsToDB(ctx context.Context, tableName string, columnNames []string, r kv.Rows) error {
 	rows := r.(tidbRows)
 	if len(rows) == 0 {
@@ -420,18 +744,29 @@ func (be *tidbBackend) WriteRowsToDB(ctx context.Context, tableName string, colu
 	}
 	insertStmt.WriteString(" VALUES")
 
-	// Note: we are not going to do interpolation (prepared statements) to avoid
-	// complication arise from data length overflow of BIT and BINARY columns
-
-	for i, row := range rows {
-		if i != 0 {
-			insertStmt.WriteByte(',')
+	var err error
+	if be.prepared {
+		// Note: the binary literals are bound as binary strings, which are
+		// converted by TiDB the same way as the hexadecimal literals.
+		var args []interface{}
+		for i, row := range rows {
+			if i != 0 {
+				insertStmt.WriteByte(',')
+			}
+			insertStmt.WriteString(row.placeholders)
+			args = append(args, row.args...)
+		}
+		err = be.execPrepared(ctx, insertStmt.String(), args)
+	} else {
+		for i, row := range rows {
+			if i != 0 {
+				insertStmt.WriteByte(',')
+			}
+			insertStmt.WriteString(row.insertStmt)
 		}
-		insertStmt.WriteString(string(row))
+		// Retry will be done externally, so we're not going to retry here.
+		_, err = be.db.ExecContext(ctx, insertStmt.String())
 	}
-
-	// Retry will be done externally, so we're not going to retry here.
-	_, err := be.db.ExecContext(ctx, insertStmt.String())
 	if err != nil && !common.IsContextCanceledError(err) {
 		log.L().Error("execute statement failed", zap.String("stmt", redact.String(insertStmt.String())),
 			zap.Array("rows", rows), zap.Error(err))
@@ -442,6 +777,35 @@ func (be *tidbBackend) WriteRowsToDB(ctx context.Context, tableName string, colu
 	return errors.Trace(err)
 }
 
+// execPrepared executes the query as a prepared statement with args. At most
+// maxCachedStmts prepared statements are cached for reuse.
+func (be *tidbBackend) execPrepared(ctx context.Context, query string, args []interface{}) error {
+	be.stmtsMu.Lock()
+	stmt, ok := be.stmts[query]
+	be.stmtsMu.Unlock()
+	if !ok {
+		var err error
+		if stmt, err = be.db.PrepareContext(ctx, query); err != nil {
+			return err
+		}
+		be.stmtsMu.Lock()
+		cached, ok := be.stmts[query]
+		switch {
+		case ok:
+			// prepared concurrently by another writer.
+			_ = stmt.Close()
+			stmt = cached
+		case len(be.stmts) < maxCachedStmts:
+			be.stmts[query] = stmt
+		default:
+			defer stmt.Close()
+		}
+		be.stmtsMu.Unlock()
+	}
+	_, err := stmt.ExecContext(ctx, args...)
+	return err
+}
+
 //nolint:nakedret // TODO: refactor
 func (be *tidbBackend) FetchRemoteTableModels(ctx context.Context, schemaName string) (tables []*model.TableInfo, err error) {
 	s := common.SQLWithRetry{
@@ -551,6 +915,10 @@ func (be *tidbBackend) EngineFileSizes() []backend.EngineFileSize {
 	return nil
 }
 
+func (be *tidbBackend) SpillEngine(context.Context, uuid.UUID) (bool, error) {
+	return false, nil
+}
+
 func (be *tidbBackend) FlushEngine(context.Context, uuid.UUID) error {
 	return nil
 }
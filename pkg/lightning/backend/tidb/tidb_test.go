@@ -22,7 +22,9 @@ import (
 	"github.com/pingcap/parser/charset"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	gmysql "github.com/go-sql-driver/mysql"
 	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/table"
@@ -33,6 +35,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/backend/kv"
 	"github.com/pingcap/br/pkg/lightning/backend/tidb"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/verification"
 )
@@ -69,7 +72,7 @@ func (s *mysqlSuite) SetUpTest(c *C) {
 
 	s.dbHandle = db
 	s.mockDB = mock
-	s.backend = tidb.NewTiDBBackend(db, config.ReplaceOnDup)
+	s.backend = tidb.NewTiDBBackend(db, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil)
 	s.tbl = tbl
 }
 
@@ -118,7 +121,7 @@ func (s *mysqlSuite) TestWriteRowsReplaceOnDup(c *C) {
 		types.NewMysqlBitDatum(types.NewBinaryLiteralFromUint(0x98765432, 4)),
 		types.NewDecimalDatum(types.NewDecFromFloatForTest(12.5)),
 		types.NewMysqlEnumDatum(types.Enum{Name: "ENUM_NAME", Value: 51}),
-	}, 1, perms, 0)
+	}, 1, perms, "1.csv", 0)
 	c.Assert(err, IsNil)
 	row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
 
@@ -139,7 +142,7 @@ func (s *mysqlSuite) TestWriteRowsIgnoreOnDup(c *C) {
 	ctx := context.Background()
 	logger := log.L()
 
-	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.IgnoreOnDup)
+	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.IgnoreOnDup}, nil)
 	engine, err := ignoreBackend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
 	c.Assert(err, IsNil)
 
@@ -152,7 +155,7 @@ func (s *mysqlSuite) TestWriteRowsIgnoreOnDup(c *C) {
 	c.Assert(err, IsNil)
 	row, err := encoder.Encode(logger, []types.Datum{
 		types.NewIntDatum(1),
-	}, 1, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, 0)
+	}, 1, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, "1.csv", 0)
 	c.Assert(err, IsNil)
 	row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
 
@@ -169,7 +172,7 @@ func (s *mysqlSuite) TestWriteRowsIgnoreOnDup(c *C) {
 	rowWithID, err := encoder.Encode(logger, []types.Datum{
 		types.NewIntDatum(1),
 		types.NewIntDatum(1), // _tidb_rowid field
-	}, 1, []int{0, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, 1}, 0)
+	}, 1, []int{0, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1, 1}, "1.csv", 0)
 	c.Assert(err, IsNil)
 	// tidbRow is string.
 	c.Assert(fmt.Sprint(rowWithID), Equals, "(1,1)")
@@ -183,7 +186,7 @@ func (s *mysqlSuite) TestWriteRowsErrorOnDup(c *C) {
 	ctx := context.Background()
 	logger := log.L()
 
-	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	ignoreBackend := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	engine, err := ignoreBackend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
 	c.Assert(err, IsNil)
 
@@ -196,7 +199,7 @@ func (s *mysqlSuite) TestWriteRowsErrorOnDup(c *C) {
 	c.Assert(err, IsNil)
 	row, err := encoder.Encode(logger, []types.Datum{
 		types.NewIntDatum(1),
-	}, 1, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, 0)
+	}, 1, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, "1.csv", 0)
 	c.Assert(err, IsNil)
 
 	row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
@@ -210,6 +213,167 @@ func (s *mysqlSuite) TestWriteRowsErrorOnDup(c *C) {
 	c.Assert(st, IsNil)
 }
 
+func (s *mysqlSuite) TestWriteRowsRecordConflict(c *C) {
+	dupErr := &gmysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'PRIMARY'"}
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1),(2)\\E").
+		WillReturnError(dupErr)
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1)\\E").
+		WillReturnError(dupErr)
+	s.mockDB.
+		ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_error_v1.*").
+		WithArgs(0, "`foo`.`bar`", "1.csv", 0, dupErr.Error(), "(1)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(2)\\E").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx := context.Background()
+	logger := log.L()
+
+	cfg := config.NewConfig()
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.App.MaxError.Conflict = 1
+	errorMgr := errormanager.New(s.dbHandle, cfg)
+	errorBackend := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, errorMgr)
+	engine, err := errorBackend.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
+	c.Assert(err, IsNil)
+
+	dataRows := errorBackend.MakeEmptyRows()
+	dataChecksum := verification.MakeKVChecksum(0, 0, 0)
+	indexRows := errorBackend.MakeEmptyRows()
+	indexChecksum := verification.MakeKVChecksum(0, 0, 0)
+
+	encoder, err := errorBackend.NewEncoder(s.tbl, &kv.SessionOptions{})
+	c.Assert(err, IsNil)
+	for i := int64(1); i <= 2; i++ {
+		row, err := encoder.Encode(logger, []types.Datum{
+			types.NewIntDatum(i),
+		}, i, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, "1.csv", (i-1)*2)
+		c.Assert(err, IsNil)
+		row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
+	}
+
+	writer, err := engine.LocalWriter(ctx, nil)
+	c.Assert(err, IsNil)
+	err = writer.WriteRows(ctx, []string{"a"}, dataRows)
+	c.Assert(err, IsNil)
+	c.Assert(errorMgr.Remaining(errormanager.ConflictError), Equals, int64(0))
+	_, err = writer.Close(ctx)
+	c.Assert(err, IsNil)
+}
+
+// writeIntRows writes the rows with the integers in column `a` through the
+// backend.
+func writeIntRows(c *C, bk backend.Backend, tbl table.Table, values ...int64) error {
+	ctx := context.Background()
+	logger := log.L()
+
+	engine, err := bk.OpenEngine(ctx, &backend.EngineConfig{}, "`foo`.`bar`", 1)
+	c.Assert(err, IsNil)
+
+	dataRows := bk.MakeEmptyRows()
+	dataChecksum := verification.MakeKVChecksum(0, 0, 0)
+	indexRows := bk.MakeEmptyRows()
+	indexChecksum := verification.MakeKVChecksum(0, 0, 0)
+
+	encoder, err := bk.NewEncoder(tbl, &kv.SessionOptions{})
+	c.Assert(err, IsNil)
+	for i, value := range values {
+		row, err := encoder.Encode(logger, []types.Datum{
+			types.NewIntDatum(value),
+		}, int64(i+1), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, -1}, "1.csv", int64(i*2))
+		c.Assert(err, IsNil)
+		row.ClassifyAndAppend(&dataRows, &dataChecksum, &indexRows, &indexChecksum)
+	}
+
+	writer, err := engine.LocalWriter(ctx, nil)
+	c.Assert(err, IsNil)
+	err = writer.WriteRows(ctx, []string{"a"}, dataRows)
+	_, closeErr := writer.Close(ctx)
+	c.Assert(closeErr, IsNil)
+	return err
+}
+
+func (s *mysqlSuite) TestWriteRowsPrepared(c *C) {
+	s.mockDB.
+		ExpectPrepare("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(?),(?)\\E").
+		ExpectExec().
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	s.mockDB.
+		ExpectPrepare("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(?)\\E").
+		ExpectExec().
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{
+		OnDuplicate:       config.ErrorOnDup,
+		RowsPerStatement:  2,
+		PreparedStatement: true,
+	}, nil)
+	c.Assert(writeIntRows(c, bk, s.tbl, 1, 2, 3), IsNil)
+	bk.Close()
+}
+
+func (s *mysqlSuite) TestWriteRowsShrinkOnTooLarge(c *C) {
+	tooLargeErr := &gmysql.MySQLError{Number: 1153, Message: "Got a packet bigger than 'max_allowed_packet' bytes"}
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1),(2),(3),(4)\\E").
+		WillReturnError(tooLargeErr)
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1),(2)\\E").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(3),(4)\\E").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
+	c.Assert(writeIntRows(c, bk, s.tbl, 1, 2, 3, 4), IsNil)
+}
+
+func (s *mysqlSuite) TestWriteRowsRecordTypeError(c *C) {
+	rangeErr := &gmysql.MySQLError{Number: 1264, Message: "Out of range value for column 'a' at row 1"}
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1),(2)\\E").
+		WillReturnError(rangeErr)
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1)\\E").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(2)\\E").
+		WillReturnError(rangeErr)
+	s.mockDB.
+		ExpectExec("INSERT INTO `lightning_task_info`\\.type_error_v1.*").
+		WithArgs(0, "`foo`.`bar`", "1.csv", 2, rangeErr.Error(), "(2)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cfg := config.NewConfig()
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.App.MaxError.Type = 1
+	errorMgr := errormanager.New(s.dbHandle, cfg)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, errorMgr)
+	c.Assert(writeIntRows(c, bk, s.tbl, 1, 2), IsNil)
+	c.Assert(errorMgr.Remaining(errormanager.TypeError), Equals, int64(0))
+}
+
+func (s *mysqlSuite) TestWriteRowsFailOnNonDataError(c *C) {
+	unknownColumnErr := &gmysql.MySQLError{Number: 1054, Message: "Unknown column 'a' in 'field list'"}
+	s.mockDB.
+		ExpectExec("\\QINSERT INTO `foo`.`bar`(`a`) VALUES(1),(2)\\E").
+		WillReturnError(unknownColumnErr)
+
+	cfg := config.NewConfig()
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.App.MaxError.Type = 10
+	errorMgr := errormanager.New(s.dbHandle, cfg)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, errorMgr)
+	err := writeIntRows(c, bk, s.tbl, 1, 2)
+	c.Assert(errors.Cause(err), Equals, unknownColumnErr)
+	c.Assert(errorMgr.Remaining(errormanager.TypeError), Equals, int64(10))
+}
+
 // TODO: temporarily disable this test before we fix strict mode
 //nolint:unused
 func (s *mysqlSuite) testStrictMode(c *C) {
@@ -223,19 +387,19 @@ func (s *mysqlSuite) testStrictMode(c *C) {
 	tbl, err := tables.TableFromMeta(kv.NewPanickingAllocators(0), tblInfo)
 	c.Assert(err, IsNil)
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	encoder, err := bk.NewEncoder(tbl, &kv.SessionOptions{SQLMode: mysql.ModeStrictAllTables})
 	c.Assert(err, IsNil)
 
 	logger := log.L()
 	_, err = encoder.Encode(logger, []types.Datum{
 		types.NewStringDatum("test"),
-	}, 1, []int{0, -1, -1}, 0)
+	}, 1, []int{0, -1, -1}, "1.csv", 0)
 	c.Assert(err, IsNil)
 
 	_, err = encoder.Encode(logger, []types.Datum{
 		types.NewStringDatum("\xff\xff\xff\xff"),
-	}, 1, []int{0, -1, -1}, 0)
+	}, 1, []int{0, -1, -1}, "1.csv", 0)
 	c.Assert(err, ErrorMatches, `.*incorrect utf8 value .* for column s0`)
 
 	// oepn a new encode because column count changed.
@@ -244,7 +408,7 @@ func (s *mysqlSuite) testStrictMode(c *C) {
 	_, err = encoder.Encode(logger, []types.Datum{
 		types.NewStringDatum(""),
 		types.NewStringDatum("非 ASCII 字符串"),
-	}, 1, []int{0, 1, -1}, 0)
+	}, 1, []int{0, 1, -1}, "1.csv", 0)
 	c.Assert(err, ErrorMatches, ".*incorrect ascii value .* for column s1")
 }
 
@@ -258,7 +422,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_3_x(c *C) {
 			AddRow("t", "id", "int(10)", "auto_increment"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -293,7 +457,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_0(c *C) {
 			AddRow("test", "t", "id", int64(1)))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -328,7 +492,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_x_auto_increment(c *C) {
 			AddRow("test", "t", "id", int64(1), "AUTO_INCREMENT"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -363,7 +527,7 @@ func (s *mysqlSuite) TestFetchRemoteTableModels_4_x_auto_random(c *C) {
 			AddRow("test", "t", "id", int64(1), "AUTO_RANDOM"))
 	s.mockDB.ExpectCommit()
 
-	bk := tidb.NewTiDBBackend(s.dbHandle, config.ErrorOnDup)
+	bk := tidb.NewTiDBBackend(s.dbHandle, &config.TikvImporter{OnDuplicate: config.ErrorOnDup}, nil)
 	tableInfos, err := bk.FetchRemoteTableModels(context.Background(), "test")
 	c.Assert(err, IsNil)
 	c.Assert(tableInfos, DeepEquals, []*model.TableInfo{
@@ -16,6 +16,7 @@ package checkpoints
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -49,6 +50,7 @@ const (
 	CheckpointStatusImported        CheckpointStatus = 120
 	CheckpointStatusIndexImported   CheckpointStatus = 140
 	CheckpointStatusAlteredAutoInc  CheckpointStatus = 150
+	CheckpointStatusDupeResolved    CheckpointStatus = 160
 	CheckpointStatusChecksumSkipped CheckpointStatus = 170
 	CheckpointStatusChecksummed     CheckpointStatus = 180
 	CheckpointStatusAnalyzeSkipped  CheckpointStatus = 200
@@ -57,11 +59,38 @@ const (
 
 const WholeTableEngineID = math.MaxInt32
 
+// TableControl is the state of a table set through the HTTP API in the server
+// mode, which is kept across restarts.
+type TableControl uint8
+
+const (
+	// TableControlNone means the table is restored normally.
+	TableControlNone TableControl = 0
+	// TableControlPaused means the table is not dispatched, or stops encoding
+	// if it is being restored, until it is resumed.
+	TableControlPaused TableControl = 1
+	// TableControlCancelled means the table is skipped until it is resumed.
+	TableControlCancelled TableControl = 2
+)
+
+func (control TableControl) String() string {
+	switch control {
+	case TableControlNone:
+		return "none"
+	case TableControlPaused:
+		return "paused"
+	case TableControlCancelled:
+		return "cancelled"
+	default:
+		return "invalid"
+	}
+}
+
 const (
 	// the table names to store each kind of checkpoint in the checkpoint database
 	// remember to increase the version number in case of incompatible change.
 	CheckpointTableNameTask   = "task_v2"
-	CheckpointTableNameTable  = "table_v7"
+	CheckpointTableNameTable  = "table_v9"
 	CheckpointTableNameEngine = "engine_v5"
 	CheckpointTableNameChunk  = "chunk_v5"
 
@@ -100,6 +129,11 @@ const (
 			kv_bytes bigint unsigned NOT NULL DEFAULT 0,
 			kv_kvs bigint unsigned NOT NULL DEFAULT 0,
 			kv_checksum bigint unsigned NOT NULL DEFAULT 0,
+			control tinyint unsigned NOT NULL DEFAULT 0,
+			priority int NOT NULL DEFAULT 0,
+			removed_kv_bytes bigint unsigned NOT NULL DEFAULT 0,
+			removed_kv_kvs bigint unsigned NOT NULL DEFAULT 0,
+			removed_kv_checksum bigint unsigned NOT NULL DEFAULT 0,
 			INDEX(task_id)
 		);`
 	CreateEngineTableTemplate = `
@@ -155,7 +189,10 @@ const (
 		FROM %s.%s WHERE table_name = ?
 		ORDER BY engine_id, path, offset;`
 	ReadTableRemainTemplate = `
-		SELECT status, alloc_base, table_id, kv_bytes, kv_kvs, kv_checksum FROM %s.%s WHERE table_name = ?;`
+		SELECT
+			status, alloc_base, table_id, kv_bytes, kv_kvs, kv_checksum, control, priority,
+			removed_kv_bytes, removed_kv_kvs, removed_kv_checksum
+		FROM %s.%s WHERE table_name = ?;`
 	ReplaceEngineTemplate = `
 		REPLACE INTO %s.%s (table_name, engine_id, status) VALUES (?, ?, ?);`
 	ReplaceChunkTemplate = `
@@ -178,8 +215,11 @@ const (
 	UpdateTableStatusTemplate = `
 		UPDATE %s.%s SET status = ? WHERE table_name = ?;`
 	UpdateTableChecksumTemplate = `UPDATE %s.%s SET kv_bytes = ?, kv_kvs = ?, kv_checksum = ? WHERE table_name = ?;`
+	UpdateTableControlTemplate  = `UPDATE %s.%s SET control = ?, priority = ? WHERE table_name = ?;`
 	UpdateEngineTemplate        = `
 		UPDATE %s.%s SET status = ? WHERE (table_name, engine_id) = (?, ?);`
+	UpdateTableRemovedChecksumTemplate = `
+		UPDATE %s.%s SET removed_kv_bytes = ?, removed_kv_kvs = ?, removed_kv_checksum = ? WHERE table_name = ?;`
 	DeleteCheckpointRecordTemplate = "DELETE FROM %s.%s WHERE table_name = ?;"
 )
 
@@ -206,6 +246,8 @@ func (status CheckpointStatus) MetricName() string {
 		return "index_imported"
 	case CheckpointStatusAlteredAutoInc:
 		return "altered_auto_inc"
+	case CheckpointStatusDupeResolved:
+		return "duplicate_resolved"
 	case CheckpointStatusChecksummed, CheckpointStatusChecksumSkipped:
 		return "checksum"
 	case CheckpointStatusAnalyzed, CheckpointStatusAnalyzeSkipped:
@@ -282,6 +324,12 @@ type TableCheckpoint struct {
 	TableID   int64
 	// remote checksum before restore
 	Checksum verify.KVChecksum
+	Control  TableControl
+	// tables of higher priorities are restored first.
+	Priority int32
+	// checksum of the rows removed from the target table after the import, when
+	// merging the existing rows or resolving the duplicate rows.
+	RemovedChecksum verify.KVChecksum
 }
 
 func (cp *TableCheckpoint) DeepCopy() *TableCheckpoint {
@@ -295,6 +343,10 @@ func (cp *TableCheckpoint) DeepCopy() *TableCheckpoint {
 		Engines:   engines,
 		TableID:   cp.TableID,
 		Checksum:  cp.Checksum,
+		Control:   cp.Control,
+		Priority:  cp.Priority,
+
+		RemovedChecksum: cp.RemovedChecksum,
 	}
 }
 
@@ -323,10 +375,16 @@ type TableCheckpointDiff struct {
 	hasStatus   bool
 	hasRebase   bool
 	hasChecksum bool
+	hasControl  bool
 	status      CheckpointStatus
 	allocBase   int64
 	engines     map[int32]engineCheckpointDiff
 	checksum    verify.KVChecksum
+	control     TableControl
+	priority    int32
+
+	hasRemovedChecksum bool
+	removedChecksum    verify.KVChecksum
 }
 
 func NewTableCheckpointDiff() *TableCheckpointDiff {
@@ -364,6 +422,13 @@ func (cp *TableCheckpoint) Apply(cpd *TableCheckpointDiff) {
 	if cpd.hasRebase {
 		cp.AllocBase = cpd.allocBase
 	}
+	if cpd.hasControl {
+		cp.Control = cpd.control
+		cp.Priority = cpd.priority
+	}
+	if cpd.hasRemovedChecksum {
+		cp.RemovedChecksum = cpd.removedChecksum
+	}
 	for engineID, engineDiff := range cpd.engines {
 		engine := cp.Engines[engineID]
 		if engine == nil {
@@ -454,6 +519,29 @@ func (m *TableChecksumMerger) MergeInto(cpd *TableCheckpointDiff) {
 	cpd.checksum = m.Checksum
 }
 
+// TableControlMerger records the control state and the priority of a table.
+type TableControlMerger struct {
+	Control  TableControl
+	Priority int32
+}
+
+func (m *TableControlMerger) MergeInto(cpd *TableCheckpointDiff) {
+	cpd.hasControl = true
+	cpd.control = m.Control
+	cpd.priority = m.Priority
+}
+
+// RemovedChecksumMerger records the checksum of the rows removed from the
+// target table after the import.
+type RemovedChecksumMerger struct {
+	Checksum verify.KVChecksum
+}
+
+func (m *RemovedChecksumMerger) MergeInto(cpd *TableCheckpointDiff) {
+	cpd.hasRemovedChecksum = true
+	cpd.removedChecksum = m.Checksum
+}
+
 type RebaseCheckpointMerger struct {
 	AllocBase int64
 }
@@ -527,6 +615,13 @@ func OpenCheckpointsDB(ctx context.Context, cfg *config.Config) (DB, error) {
 	case config.CheckpointDriverFile:
 		return NewFileCheckpointsDB(cfg.Checkpoint.DSN), nil
 
+	case config.CheckpointDriverPebble:
+		cpdb, err := NewPebbleCheckpointsDB(cfg.Checkpoint.DSN)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return cpdb, nil
+
 	default:
 		return nil, errors.Errorf("Unknown checkpoint driver %s", cfg.Checkpoint.Driver)
 	}
@@ -555,7 +650,7 @@ func IsCheckpointsDBExists(ctx context.Context, cfg *config.Config) (bool, error
 		}
 		return result, nil
 
-	case config.CheckpointDriverFile:
+	case config.CheckpointDriverFile, config.CheckpointDriverPebble:
 		_, err := os.Stat(cfg.Checkpoint.DSN)
 		if err == nil {
 			return true, err
@@ -792,15 +887,20 @@ func (cpdb *MySQLCheckpointsDB) Get(ctx context.Context, tableName string) (*Tab
 		tableQuery := fmt.Sprintf(ReadTableRemainTemplate, cpdb.schema, CheckpointTableNameTable)
 		tableRow := tx.QueryRowContext(c, tableQuery, tableName)
 
-		var status uint8
-		var kvs, bytes, checksum uint64
-		if err := tableRow.Scan(&status, &cp.AllocBase, &cp.TableID, &bytes, &kvs, &checksum); err != nil {
+		var status, control uint8
+		var kvs, bytes, checksum, removedKVs, removedBytes, removedChecksum uint64
+		if err := tableRow.Scan(
+			&status, &cp.AllocBase, &cp.TableID, &bytes, &kvs, &checksum, &control, &cp.Priority,
+			&removedBytes, &removedKVs, &removedChecksum,
+		); err != nil {
 			if err == sql.ErrNoRows {
 				return errors.NotFoundf("checkpoint for table %s", tableName)
 			}
 		}
 		cp.Checksum = verify.MakeKVChecksum(bytes, kvs, checksum)
 		cp.Status = CheckpointStatus(status)
+		cp.Control = TableControl(control)
+		cp.RemovedChecksum = verify.MakeKVChecksum(removedBytes, removedKVs, removedChecksum)
 		return nil
 	})
 	if err != nil {
@@ -864,6 +964,8 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 	rebaseQuery := fmt.Sprintf(UpdateTableRebaseTemplate, cpdb.schema, CheckpointTableNameTable)
 	tableStatusQuery := fmt.Sprintf(UpdateTableStatusTemplate, cpdb.schema, CheckpointTableNameTable)
 	tableChecksumQuery := fmt.Sprintf(UpdateTableChecksumTemplate, cpdb.schema, CheckpointTableNameTable)
+	tableControlQuery := fmt.Sprintf(UpdateTableControlTemplate, cpdb.schema, CheckpointTableNameTable)
+	tableRemovedChecksumQuery := fmt.Sprintf(UpdateTableRemovedChecksumTemplate, cpdb.schema, CheckpointTableNameTable)
 	engineStatusQuery := fmt.Sprintf(UpdateEngineTemplate, cpdb.schema, CheckpointTableNameEngine)
 
 	s := common.SQLWithRetry{DB: cpdb.db, Logger: log.L()}
@@ -888,6 +990,16 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 			return errors.Trace(e)
 		}
 		defer tableChecksumStmt.Close()
+		tableControlStmt, e := tx.PrepareContext(c, tableControlQuery)
+		if e != nil {
+			return errors.Trace(e)
+		}
+		defer tableControlStmt.Close()
+		tableRemovedChecksumStmt, e := tx.PrepareContext(c, tableRemovedChecksumQuery)
+		if e != nil {
+			return errors.Trace(e)
+		}
+		defer tableRemovedChecksumStmt.Close()
 		engineStatusStmt, e := tx.PrepareContext(c, engineStatusQuery)
 		if e != nil {
 			return errors.Trace(e)
@@ -909,6 +1021,18 @@ func (cpdb *MySQLCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoi
 					return errors.Trace(e)
 				}
 			}
+			if cpd.hasControl {
+				if _, e := tableControlStmt.ExecContext(c, cpd.control, cpd.priority, tableName); e != nil {
+					return errors.Trace(e)
+				}
+			}
+			if cpd.hasRemovedChecksum {
+				if _, e := tableRemovedChecksumStmt.ExecContext(
+					c, cpd.removedChecksum.SumSize(), cpd.removedChecksum.SumKVS(), cpd.removedChecksum.Sum(), tableName,
+				); e != nil {
+					return errors.Trace(e)
+				}
+			}
 			for engineID, engineDiff := range cpd.engines {
 				if engineDiff.hasStatus {
 					if _, e := engineStatusStmt.ExecContext(c, engineDiff.status, tableName, engineID); e != nil {
@@ -1079,6 +1203,10 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 		Engines:   make(map[int32]*EngineCheckpoint, len(tableModel.Engines)),
 		TableID:   tableModel.TableID,
 		Checksum:  verify.MakeKVChecksum(tableModel.KvBytes, tableModel.KvKvs, tableModel.KvChecksum),
+		Control:   TableControl(tableModel.Control),
+		Priority:  tableModel.Priority,
+
+		RemovedChecksum: verify.MakeKVChecksum(tableModel.RemovedKvBytes, tableModel.RemovedKvKvs, tableModel.RemovedKvChecksum),
 	}
 
 	for engineID, engineModel := range tableModel.Engines {
@@ -1088,32 +1216,7 @@ func (cpdb *FileCheckpointsDB) Get(_ context.Context, tableName string) (*TableC
 		}
 
 		for _, chunkModel := range engineModel.Chunks {
-			colPerm := make([]int, 0, len(chunkModel.ColumnPermutation))
-			for _, c := range chunkModel.ColumnPermutation {
-				colPerm = append(colPerm, int(c))
-			}
-			engine.Chunks = append(engine.Chunks, &ChunkCheckpoint{
-				Key: ChunkCheckpointKey{
-					Path:   chunkModel.Path,
-					Offset: chunkModel.Offset,
-				},
-				FileMeta: mydump.SourceFileMeta{
-					Path:        chunkModel.Path,
-					Type:        mydump.SourceType(chunkModel.Type),
-					Compression: mydump.Compression(chunkModel.Compression),
-					SortKey:     chunkModel.SortKey,
-					FileSize:    chunkModel.FileSize,
-				},
-				ColumnPermutation: colPerm,
-				Chunk: mydump.Chunk{
-					Offset:       chunkModel.Pos,
-					EndOffset:    chunkModel.EndOffset,
-					PrevRowIDMax: chunkModel.PrevRowidMax,
-					RowIDMax:     chunkModel.RowidMax,
-				},
-				Checksum:  verify.MakeKVChecksum(chunkModel.KvcBytes, chunkModel.KvcKvs, chunkModel.KvcChecksum),
-				Timestamp: chunkModel.Timestamp,
-			})
+			engine.Chunks = append(engine.Chunks, chunkCheckpointFromModel(chunkModel))
 		}
 
 		sort.Slice(engine.Chunks, func(i, j int) bool {
@@ -1146,18 +1249,7 @@ func (cpdb *FileCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableN
 				}
 				engineModel.Chunks[key] = chunk
 			}
-			chunk.Type = int32(value.FileMeta.Type)
-			chunk.Compression = int32(value.FileMeta.Compression)
-			chunk.SortKey = value.FileMeta.SortKey
-			chunk.FileSize = value.FileMeta.FileSize
-			chunk.Pos = value.Chunk.Offset
-			chunk.EndOffset = value.Chunk.EndOffset
-			chunk.PrevRowidMax = value.Chunk.PrevRowIDMax
-			chunk.RowidMax = value.Chunk.RowIDMax
-			chunk.Timestamp = value.Timestamp
-			if len(value.ColumnPermutation) > 0 {
-				chunk.ColumnPermutation = intSlice2Int32Slice(value.ColumnPermutation)
-			}
+			fillChunkModel(chunk, value)
 		}
 		tableModel.Engines[engineID] = engineModel
 	}
@@ -1182,6 +1274,15 @@ func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoin
 			tableModel.KvKvs = cpd.checksum.SumKVS()
 			tableModel.KvChecksum = cpd.checksum.Sum()
 		}
+		if cpd.hasControl {
+			tableModel.Control = uint32(cpd.control)
+			tableModel.Priority = cpd.priority
+		}
+		if cpd.hasRemovedChecksum {
+			tableModel.RemovedKvBytes = cpd.removedChecksum.SumSize()
+			tableModel.RemovedKvKvs = cpd.removedChecksum.SumKVS()
+			tableModel.RemovedKvChecksum = cpd.removedChecksum.Sum()
+		}
 		for engineID, engineDiff := range cpd.engines {
 			engineModel := tableModel.Engines[engineID]
 			if engineDiff.hasStatus {
@@ -1189,13 +1290,7 @@ func (cpdb *FileCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpoin
 			}
 
 			for key, diff := range engineDiff.chunks {
-				chunkModel := engineModel.Chunks[key.String()]
-				chunkModel.Pos = diff.pos
-				chunkModel.PrevRowidMax = diff.rowID
-				chunkModel.KvcBytes = diff.checksum.SumSize()
-				chunkModel.KvcKvs = diff.checksum.SumKVS()
-				chunkModel.KvcChecksum = diff.checksum.Sum()
-				chunkModel.ColumnPermutation = intSlice2Int32Slice(diff.columnPermutation)
+				applyChunkDiff(engineModel.Chunks[key.String()], diff)
 			}
 		}
 	}
@@ -1463,6 +1558,8 @@ func (cpdb *MySQLCheckpointsDB) DumpTables(ctx context.Context, writer io.Writer
 			hex(hash) AS hash,
 			status,
 			alloc_base,
+			control,
+			priority,
 			create_time,
 			update_time
 		FROM %s.%s;
@@ -1636,16 +1733,148 @@ func (cpdb *FileCheckpointsDB) DestroyErrorCheckpoint(_ context.Context, targetT
 	return targetTables, nil
 }
 
-func (cpdb *FileCheckpointsDB) DumpTables(context.Context, io.Writer) error {
-	return errors.Errorf("dumping file checkpoint into CSV not unsupported, you may copy %s instead", cpdb.path)
+// sortedTableNames returns the names of the table checkpoints in order, so the
+// dumps are stable.
+func (cpdb *FileCheckpointsDB) sortedTableNames() []string {
+	tableNames := make([]string, 0, len(cpdb.checkpoints.Checkpoints))
+	for tableName := range cpdb.checkpoints.Checkpoints {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	return tableNames
 }
 
-func (cpdb *FileCheckpointsDB) DumpEngines(context.Context, io.Writer) error {
-	return errors.Errorf("dumping file checkpoint into CSV not unsupported, you may copy %s instead", cpdb.path)
+func sortedEngineIDs(tableModel *checkpointspb.TableCheckpointModel) []int32 {
+	engineIDs := make([]int32, 0, len(tableModel.Engines))
+	for engineID := range tableModel.Engines {
+		engineIDs = append(engineIDs, engineID)
+	}
+	sort.Slice(engineIDs, func(i, j int) bool { return engineIDs[i] < engineIDs[j] })
+	return engineIDs
+}
+
+func (cpdb *FileCheckpointsDB) DumpTables(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	var taskID int64
+	if cpdb.checkpoints.TaskCheckpoint != nil {
+		taskID = cpdb.checkpoints.TaskCheckpoint.TaskId
+	}
+	return dumpCSV(writer, tableDumpColumns, func(w *csv.Writer) error {
+		for _, tableName := range cpdb.sortedTableNames() {
+			tableModel := cpdb.checkpoints.Checkpoints[tableName]
+			if err := w.Write(tableDumpRecord(taskID, tableName, tableModel)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	})
+}
+
+func (cpdb *FileCheckpointsDB) DumpEngines(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	return dumpCSV(writer, engineDumpColumns, func(w *csv.Writer) error {
+		for _, tableName := range cpdb.sortedTableNames() {
+			tableModel := cpdb.checkpoints.Checkpoints[tableName]
+			for _, engineID := range sortedEngineIDs(tableModel) {
+				if err := w.Write(engineDumpRecord(tableName, engineID, tableModel.Engines[engineID])); err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (cpdb *FileCheckpointsDB) DumpChunks(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	return dumpCSV(writer, chunkDumpColumns, func(w *csv.Writer) error {
+		for _, tableName := range cpdb.sortedTableNames() {
+			tableModel := cpdb.checkpoints.Checkpoints[tableName]
+			for _, engineID := range sortedEngineIDs(tableModel) {
+				chunks := make([]*checkpointspb.ChunkCheckpointModel, 0, len(tableModel.Engines[engineID].Chunks))
+				for _, chunkModel := range tableModel.Engines[engineID].Chunks {
+					chunks = append(chunks, chunkModel)
+				}
+				sort.Slice(chunks, func(i, j int) bool {
+					if chunks[i].Path != chunks[j].Path {
+						return chunks[i].Path < chunks[j].Path
+					}
+					return chunks[i].Offset < chunks[j].Offset
+				})
+				for _, chunkModel := range chunks {
+					record, err := chunkDumpRecord(tableName, chunkModel)
+					if err != nil {
+						return err
+					}
+					if err := w.Write(record); err != nil {
+						return errors.Trace(err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// chunkCheckpointFromModel converts the chunk checkpoint stored by the file
+// and pebble checkpoints.
+func chunkCheckpointFromModel(chunkModel *checkpointspb.ChunkCheckpointModel) *ChunkCheckpoint {
+	colPerm := make([]int, 0, len(chunkModel.ColumnPermutation))
+	for _, c := range chunkModel.ColumnPermutation {
+		colPerm = append(colPerm, int(c))
+	}
+	return &ChunkCheckpoint{
+		Key: ChunkCheckpointKey{
+			Path:   chunkModel.Path,
+			Offset: chunkModel.Offset,
+		},
+		FileMeta: mydump.SourceFileMeta{
+			Path:        chunkModel.Path,
+			Type:        mydump.SourceType(chunkModel.Type),
+			Compression: mydump.Compression(chunkModel.Compression),
+			SortKey:     chunkModel.SortKey,
+			FileSize:    chunkModel.FileSize,
+		},
+		ColumnPermutation: colPerm,
+		Chunk: mydump.Chunk{
+			Offset:       chunkModel.Pos,
+			EndOffset:    chunkModel.EndOffset,
+			PrevRowIDMax: chunkModel.PrevRowidMax,
+			RowIDMax:     chunkModel.RowidMax,
+		},
+		Checksum:  verify.MakeKVChecksum(chunkModel.KvcBytes, chunkModel.KvcKvs, chunkModel.KvcChecksum),
+		Timestamp: chunkModel.Timestamp,
+	}
+}
+
+func fillChunkModel(chunk *checkpointspb.ChunkCheckpointModel, value *ChunkCheckpoint) {
+	chunk.Type = int32(value.FileMeta.Type)
+	chunk.Compression = int32(value.FileMeta.Compression)
+	chunk.SortKey = value.FileMeta.SortKey
+	chunk.FileSize = value.FileMeta.FileSize
+	chunk.Pos = value.Chunk.Offset
+	chunk.EndOffset = value.Chunk.EndOffset
+	chunk.PrevRowidMax = value.Chunk.PrevRowIDMax
+	chunk.RowidMax = value.Chunk.RowIDMax
+	chunk.Timestamp = value.Timestamp
+	if len(value.ColumnPermutation) > 0 {
+		chunk.ColumnPermutation = intSlice2Int32Slice(value.ColumnPermutation)
+	}
 }
 
-func (cpdb *FileCheckpointsDB) DumpChunks(context.Context, io.Writer) error {
-	return errors.Errorf("dumping file checkpoint into CSV not unsupported, you may copy %s instead", cpdb.path)
+func applyChunkDiff(chunkModel *checkpointspb.ChunkCheckpointModel, diff chunkCheckpointDiff) {
+	chunkModel.Pos = diff.pos
+	chunkModel.PrevRowidMax = diff.rowID
+	chunkModel.KvcBytes = diff.checksum.SumSize()
+	chunkModel.KvcKvs = diff.checksum.SumKVS()
+	chunkModel.KvcChecksum = diff.checksum.Sum()
+	chunkModel.ColumnPermutation = intSlice2Int32Slice(diff.columnPermutation)
 }
 
 func intSlice2Int32Slice(s []int) []int32 {
@@ -2,8 +2,10 @@ package checkpoints_test
 
 import (
 	"context"
+	"io"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	. "github.com/pingcap/check"
@@ -122,6 +124,15 @@ func (s *cpFileSuite) SetUpTest(c *C) {
 		Checksum: verification.MakeKVChecksum(4492, 686, 486070148910),
 	}
 	cksum.MergeInto(cpd)
+	tcm := checkpoints.TableControlMerger{
+		Control:  checkpoints.TableControlPaused,
+		Priority: 7,
+	}
+	tcm.MergeInto(cpd)
+	removed := checkpoints.RemovedChecksumMerger{
+		Checksum: verification.MakeKVChecksum(12, 3, 456),
+	}
+	removed.MergeInto(cpd)
 	ccm := checkpoints.ChunkCheckpointMerger{
 		EngineID: 0,
 		Key:      checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
@@ -164,6 +175,8 @@ func (s *cpFileSuite) TestGet(c *C) {
 		Status:    checkpoints.CheckpointStatusAllWritten,
 		AllocBase: 132861,
 		Checksum:  verification.MakeKVChecksum(4492, 686, 486070148910),
+		Control:   checkpoints.TableControlPaused,
+		Priority:  7,
 		Engines: map[int32]*checkpoints.EngineCheckpoint{
 			-1: {
 				Status: checkpoints.CheckpointStatusLoaded,
@@ -192,6 +205,8 @@ func (s *cpFileSuite) TestGet(c *C) {
 				}},
 			},
 		},
+
+		RemovedChecksum: verification.MakeKVChecksum(12, 3, 456),
 	})
 
 	cp, err = s.cpdb.Get(ctx, "`db2`.`t3`")
@@ -328,3 +343,55 @@ func (s *cpFileSuite) TestDestroyOneErrorCheckpoint(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusAllWritten/10)
 }
+
+func (s *cpFileSuite) TestDump(c *C) {
+	ctx := context.Background()
+
+	var csvBuilder strings.Builder
+	c.Assert(s.cpdb.DumpTables(ctx, &csvBuilder), IsNil)
+	c.Assert(csvBuilder.String(), Equals,
+		"task_id,table_name,hash,status,alloc_base,control,priority,create_time,update_time\n"+
+			"123,`db1`.`t1`,,30,0,0,0,,\n"+
+			"123,`db1`.`t2`,,60,132861,1,7,,\n"+
+			"123,`db2`.`t3`,,30,0,0,0,,\n",
+	)
+
+	csvBuilder.Reset()
+	c.Assert(s.cpdb.DumpEngines(ctx, &csvBuilder), IsNil)
+	c.Assert(csvBuilder.String(), Equals,
+		"table_name,engine_id,status,create_time,update_time\n"+
+			"`db1`.`t2`,-1,30,,\n"+
+			"`db1`.`t2`,0,120,,\n"+
+			"`db2`.`t3`,-1,30,,\n",
+	)
+
+	csvBuilder.Reset()
+	c.Assert(s.cpdb.DumpChunks(ctx, &csvBuilder), IsNil)
+	c.Assert(csvBuilder.String(), Equals,
+		"table_name,path,offset,type,compression,sort_key,file_size,columns,pos,end_offset,prev_rowid_max,rowid_max,kvc_bytes,kvc_kvs,kvc_checksum,create_time,update_time\n"+
+			"`db1`.`t2`,/tmp/path/1.sql,0,3,0,,12345,[],55904,102400,681,5000,4491,586,486070148917,,\n",
+	)
+}
+
+func (s *cpFileSuite) TestDumpJSON(c *C) {
+	ctx := context.Background()
+
+	var jsonBuilder strings.Builder
+	err := checkpoints.DumpJSON(&jsonBuilder, func(csv io.Writer) error {
+		return s.cpdb.DumpEngines(ctx, csv)
+	})
+	c.Assert(err, IsNil)
+	c.Assert(jsonBuilder.String(), Equals, "[\n"+
+		`{"table_name":"`+"`db1`.`t2`"+`","engine_id":"-1","status":"30","create_time":"","update_time":""},`+"\n"+
+		`{"table_name":"`+"`db1`.`t2`"+`","engine_id":"0","status":"120","create_time":"","update_time":""},`+"\n"+
+		`{"table_name":"`+"`db2`.`t3`"+`","engine_id":"-1","status":"30","create_time":"","update_time":""}`+"\n"+
+		"]\n",
+	)
+
+	// the error of the dump is returned.
+	jsonBuilder.Reset()
+	err = checkpoints.DumpJSON(&jsonBuilder, func(io.Writer) error {
+		return errors.New("dump failed")
+	})
+	c.Assert(err, ErrorMatches, "dump failed")
+}
@@ -0,0 +1,738 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints/checkpointspb"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/log"
+	verify "github.com/pingcap/br/pkg/lightning/verification"
+	"github.com/pingcap/br/pkg/version/build"
+)
+
+// The keys of the pebble checkpoints are laid out as
+//
+//	task                                    -> TaskCheckpointModel
+//	table/<table name>                      -> TableCheckpointModel (without engines)
+//	engine/<table name>\x00<engine id>      -> EngineCheckpointModel (without chunks)
+//	chunk/<table name>\x00<engine id><key>  -> ChunkCheckpointModel
+//
+// where the engine ID is encoded in 4 bytes which sort in the numerical order,
+// so every chunk update only rewrites a single small key.
+const (
+	pebbleTaskKey      = "task"
+	pebbleTablePrefix  = "table/"
+	pebbleEnginePrefix = "engine/"
+	pebbleChunkPrefix  = "chunk/"
+
+	pebbleTableNameSep = '\x00'
+	pebbleEngineIDLen  = 4
+)
+
+var pebbleWriteOptions = &pebble.WriteOptions{Sync: true}
+
+// PebbleCheckpointsDB stores the checkpoints in a local pebble database, so
+// that updating a chunk checkpoint does not rewrite the whole checkpoints.
+type PebbleCheckpointsDB struct {
+	lock sync.Mutex // guards the read-modify-write of the models
+	db   *pebble.DB
+	path string
+}
+
+func NewPebbleCheckpointsDB(path string) (*PebbleCheckpointsDB, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, errors.Annotatef(err, "open checkpoint database %s failed", path)
+	}
+	return &PebbleCheckpointsDB{db: db, path: path}, nil
+}
+
+func pebbleTableKey(tableName string) []byte {
+	return []byte(pebbleTablePrefix + tableName)
+}
+
+func encodePebbleEngineID(b []byte, engineID int32) []byte {
+	var buf [pebbleEngineIDLen]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(engineID)^(1<<31))
+	return append(b, buf[:]...)
+}
+
+func decodePebbleEngineID(b []byte) int32 {
+	return int32(binary.BigEndian.Uint32(b) ^ (1 << 31))
+}
+
+func pebbleTableEnginesPrefix(tableName string) []byte {
+	return append([]byte(pebbleEnginePrefix+tableName), pebbleTableNameSep)
+}
+
+func pebbleEngineKey(tableName string, engineID int32) []byte {
+	return encodePebbleEngineID(pebbleTableEnginesPrefix(tableName), engineID)
+}
+
+func pebbleTableChunksPrefix(tableName string) []byte {
+	return append([]byte(pebbleChunkPrefix+tableName), pebbleTableNameSep)
+}
+
+func pebbleEngineChunksPrefix(tableName string, engineID int32) []byte {
+	return encodePebbleEngineID(pebbleTableChunksPrefix(tableName), engineID)
+}
+
+func pebbleChunkKey(tableName string, engineID int32, key *ChunkCheckpointKey) []byte {
+	return append(pebbleEngineChunksPrefix(tableName, engineID), key.String()...)
+}
+
+// decodePebbleEngineKey extracts the table name and the engine ID from the key
+// of an engine or a chunk.
+func decodePebbleEngineKey(key []byte, prefix string) (string, int32, error) {
+	rest := key[len(prefix):]
+	sep := bytes.IndexByte(rest, pebbleTableNameSep)
+	if sep < 0 || len(rest) < sep+1+pebbleEngineIDLen {
+		return "", 0, errors.Errorf("invalid checkpoint key %q", key)
+	}
+	return string(rest[:sep]), decodePebbleEngineID(rest[sep+1 : sep+1+pebbleEngineIDLen]), nil
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the prefix.
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+type pebbleModel interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+func (cpdb *PebbleCheckpointsDB) checkOpen() error {
+	if cpdb.db == nil {
+		return errors.Errorf("checkpoints in %s have been removed or moved", cpdb.path)
+	}
+	return nil
+}
+
+// getModel reads the model of the key, and returns false if the key does not exist.
+func (cpdb *PebbleCheckpointsDB) getModel(key []byte, model pebbleModel) (bool, error) {
+	value, closer, err := cpdb.db.Get(key)
+	if err == pebble.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, errors.Trace(err)
+	}
+	defer closer.Close()
+	return true, errors.Trace(model.Unmarshal(value))
+}
+
+func setModel(batch *pebble.Batch, key []byte, model pebbleModel) error {
+	value, err := model.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(batch.Set(key, value, nil))
+}
+
+// iterate calls `fn` with every key having the prefix in order.
+func (cpdb *PebbleCheckpointsDB) iterate(prefix []byte, fn func(key, value []byte) error) error {
+	iter := cpdb.db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: prefixUpperBound(prefix),
+	})
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err := fn(iter.Key(), iter.Value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cpdb *PebbleCheckpointsDB) iterateTables(fn func(tableName string, tableModel *checkpointspb.TableCheckpointModel) error) error {
+	return cpdb.iterate([]byte(pebbleTablePrefix), func(key, value []byte) error {
+		tableModel := &checkpointspb.TableCheckpointModel{}
+		if err := tableModel.Unmarshal(value); err != nil {
+			return errors.Trace(err)
+		}
+		return fn(string(key[len(pebbleTablePrefix):]), tableModel)
+	})
+}
+
+func (cpdb *PebbleCheckpointsDB) iterateEngines(
+	prefix []byte,
+	fn func(tableName string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) error,
+) error {
+	return cpdb.iterate(prefix, func(key, value []byte) error {
+		tableName, engineID, err := decodePebbleEngineKey(key, pebbleEnginePrefix)
+		if err != nil {
+			return err
+		}
+		engineModel := &checkpointspb.EngineCheckpointModel{}
+		if err := engineModel.Unmarshal(value); err != nil {
+			return errors.Trace(err)
+		}
+		return fn(tableName, engineID, engineModel)
+	})
+}
+
+func (cpdb *PebbleCheckpointsDB) iterateChunks(
+	prefix []byte,
+	fn func(tableName string, engineID int32, chunkModel *checkpointspb.ChunkCheckpointModel) error,
+) error {
+	return cpdb.iterate(prefix, func(key, value []byte) error {
+		tableName, engineID, err := decodePebbleEngineKey(key, pebbleChunkPrefix)
+		if err != nil {
+			return err
+		}
+		chunkModel := &checkpointspb.ChunkCheckpointModel{}
+		if err := chunkModel.Unmarshal(value); err != nil {
+			return errors.Trace(err)
+		}
+		return fn(tableName, engineID, chunkModel)
+	})
+}
+
+// deleteTable deletes all checkpoints of the table in the batch.
+func deleteTable(batch *pebble.Batch, tableName string) error {
+	chunksPrefix := pebbleTableChunksPrefix(tableName)
+	if err := batch.DeleteRange(chunksPrefix, prefixUpperBound(chunksPrefix), nil); err != nil {
+		return errors.Trace(err)
+	}
+	enginesPrefix := pebbleTableEnginesPrefix(tableName)
+	if err := batch.DeleteRange(enginesPrefix, prefixUpperBound(enginesPrefix), nil); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(batch.Delete(pebbleTableKey(tableName), nil))
+}
+
+func (cpdb *PebbleCheckpointsDB) Initialize(ctx context.Context, cfg *config.Config, dbInfo map[string]*TidbDBInfo) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+
+	taskModel := &checkpointspb.TaskCheckpointModel{
+		TaskId:       cfg.TaskID,
+		SourceDir:    cfg.Mydumper.SourceDir,
+		Backend:      cfg.TikvImporter.Backend,
+		ImporterAddr: cfg.TikvImporter.Addr,
+		TidbHost:     cfg.TiDB.Host,
+		TidbPort:     int32(cfg.TiDB.Port),
+		PdAddr:       cfg.TiDB.PdAddr,
+		SortedKvDir:  cfg.TikvImporter.SortedKVDir,
+		LightningVer: build.ReleaseVersion,
+	}
+	if err := setModel(batch, []byte(pebbleTaskKey), taskModel); err != nil {
+		return err
+	}
+
+	for _, db := range dbInfo {
+		for _, table := range db.Tables {
+			key := pebbleTableKey(common.UniqueTable(db.Name, table.Name))
+			exists, err := cpdb.getModel(key, &checkpointspb.TableCheckpointModel{})
+			if err != nil {
+				return err
+			}
+			if exists {
+				// TODO check if hash matches
+				continue
+			}
+			tableModel := &checkpointspb.TableCheckpointModel{
+				Status:  uint32(CheckpointStatusLoaded),
+				TableID: table.ID,
+			}
+			if err := setModel(batch, key, tableModel); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errors.Trace(batch.Commit(pebbleWriteOptions))
+}
+
+func (cpdb *PebbleCheckpointsDB) TaskCheckpoint(_ context.Context) (*TaskCheckpoint, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if cpdb.db == nil {
+		return nil, nil
+	}
+	cp := &checkpointspb.TaskCheckpointModel{}
+	exists, err := cpdb.getModel([]byte(pebbleTaskKey), cp)
+	if err != nil || !exists || cp.TaskId == 0 {
+		return nil, err
+	}
+
+	return &TaskCheckpoint{
+		TaskID:       cp.TaskId,
+		SourceDir:    cp.SourceDir,
+		Backend:      cp.Backend,
+		ImporterAddr: cp.ImporterAddr,
+		TiDBHost:     cp.TidbHost,
+		TiDBPort:     int(cp.TidbPort),
+		PdAddr:       cp.PdAddr,
+		SortedKVDir:  cp.SortedKvDir,
+		LightningVer: cp.LightningVer,
+	}, nil
+}
+
+func (cpdb *PebbleCheckpointsDB) Close() error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if cpdb.db == nil {
+		return nil
+	}
+	err := cpdb.db.Close()
+	cpdb.db = nil
+	return errors.Trace(err)
+}
+
+func (cpdb *PebbleCheckpointsDB) Get(_ context.Context, tableName string) (*TableCheckpoint, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	tableModel := &checkpointspb.TableCheckpointModel{}
+	exists := false
+	if cpdb.db != nil {
+		var err error
+		if exists, err = cpdb.getModel(pebbleTableKey(tableName), tableModel); err != nil {
+			return nil, err
+		}
+	}
+	if !exists {
+		return nil, errors.NotFoundf("checkpoint for table %s", tableName)
+	}
+
+	cp := &TableCheckpoint{
+		Status:    CheckpointStatus(tableModel.Status),
+		AllocBase: tableModel.AllocBase,
+		Engines:   make(map[int32]*EngineCheckpoint),
+		TableID:   tableModel.TableID,
+		Checksum:  verify.MakeKVChecksum(tableModel.KvBytes, tableModel.KvKvs, tableModel.KvChecksum),
+		Control:   TableControl(tableModel.Control),
+		Priority:  tableModel.Priority,
+
+		RemovedChecksum: verify.MakeKVChecksum(tableModel.RemovedKvBytes, tableModel.RemovedKvKvs, tableModel.RemovedKvChecksum),
+	}
+
+	err := cpdb.iterateEngines(pebbleTableEnginesPrefix(tableName),
+		func(_ string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) error {
+			cp.Engines[engineID] = &EngineCheckpoint{
+				Status: CheckpointStatus(engineModel.Status),
+				Chunks: []*ChunkCheckpoint{},
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	err = cpdb.iterateChunks(pebbleTableChunksPrefix(tableName),
+		func(_ string, engineID int32, chunkModel *checkpointspb.ChunkCheckpointModel) error {
+			engine, ok := cp.Engines[engineID]
+			if !ok {
+				return errors.Errorf("chunk checkpoint %s:%d of table %s has no engine %d",
+					chunkModel.Path, chunkModel.Offset, tableName, engineID)
+			}
+			engine.Chunks = append(engine.Chunks, chunkCheckpointFromModel(chunkModel))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, engine := range cp.Engines {
+		sort.Slice(engine.Chunks, func(i, j int) bool {
+			return engine.Chunks[i].Key.less(&engine.Chunks[j].Key)
+		})
+	}
+
+	return cp, nil
+}
+
+func (cpdb *PebbleCheckpointsDB) InsertEngineCheckpoints(_ context.Context, tableName string, checkpoints map[int32]*EngineCheckpoint) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+
+	for engineID, engine := range checkpoints {
+		engineModel := &checkpointspb.EngineCheckpointModel{Status: uint32(CheckpointStatusLoaded)}
+		if err := setModel(batch, pebbleEngineKey(tableName, engineID), engineModel); err != nil {
+			return err
+		}
+		chunksPrefix := pebbleEngineChunksPrefix(tableName, engineID)
+		if err := batch.DeleteRange(chunksPrefix, prefixUpperBound(chunksPrefix), nil); err != nil {
+			return errors.Trace(err)
+		}
+		for _, value := range engine.Chunks {
+			chunk := &checkpointspb.ChunkCheckpointModel{
+				Path:   value.Key.Path,
+				Offset: value.Key.Offset,
+			}
+			fillChunkModel(chunk, value)
+			if err := setModel(batch, pebbleChunkKey(tableName, engineID, &value.Key), chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	return errors.Trace(batch.Commit(pebbleWriteOptions))
+}
+
+func (cpdb *PebbleCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDiff) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.update(checkpointDiffs); err != nil {
+		log.L().Error("save checkpoint failed", zap.Error(err))
+	}
+}
+
+func (cpdb *PebbleCheckpointsDB) update(checkpointDiffs map[string]*TableCheckpointDiff) error {
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+
+	for tableName, cpd := range checkpointDiffs {
+		if cpd.hasStatus || cpd.hasRebase || cpd.hasChecksum || cpd.hasControl || cpd.hasRemovedChecksum {
+			key := pebbleTableKey(tableName)
+			tableModel := &checkpointspb.TableCheckpointModel{}
+			if exists, err := cpdb.getModel(key, tableModel); err != nil {
+				return err
+			} else if !exists {
+				return errors.NotFoundf("checkpoint for table %s", tableName)
+			}
+			if cpd.hasStatus {
+				tableModel.Status = uint32(cpd.status)
+			}
+			if cpd.hasRebase {
+				tableModel.AllocBase = cpd.allocBase
+			}
+			if cpd.hasChecksum {
+				tableModel.KvBytes = cpd.checksum.SumSize()
+				tableModel.KvKvs = cpd.checksum.SumKVS()
+				tableModel.KvChecksum = cpd.checksum.Sum()
+			}
+			if cpd.hasControl {
+				tableModel.Control = uint32(cpd.control)
+				tableModel.Priority = cpd.priority
+			}
+			if cpd.hasRemovedChecksum {
+				tableModel.RemovedKvBytes = cpd.removedChecksum.SumSize()
+				tableModel.RemovedKvKvs = cpd.removedChecksum.SumKVS()
+				tableModel.RemovedKvChecksum = cpd.removedChecksum.Sum()
+			}
+			if err := setModel(batch, key, tableModel); err != nil {
+				return err
+			}
+		}
+
+		for engineID, engineDiff := range cpd.engines {
+			if engineDiff.hasStatus {
+				key := pebbleEngineKey(tableName, engineID)
+				engineModel := &checkpointspb.EngineCheckpointModel{}
+				if exists, err := cpdb.getModel(key, engineModel); err != nil {
+					return err
+				} else if !exists {
+					return errors.NotFoundf("checkpoint for engine %s:%d", tableName, engineID)
+				}
+				engineModel.Status = uint32(engineDiff.status)
+				if err := setModel(batch, key, engineModel); err != nil {
+					return err
+				}
+			}
+
+			for key, diff := range engineDiff.chunks {
+				chunkKey := pebbleChunkKey(tableName, engineID, &key)
+				chunkModel := &checkpointspb.ChunkCheckpointModel{}
+				if exists, err := cpdb.getModel(chunkKey, chunkModel); err != nil {
+					return err
+				} else if !exists {
+					return errors.NotFoundf("checkpoint for chunk %s of engine %s:%d", key.String(), tableName, engineID)
+				}
+				applyChunkDiff(chunkModel, diff)
+				if err := setModel(batch, chunkKey, chunkModel); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return errors.Trace(batch.Commit(pebbleWriteOptions))
+}
+
+func (cpdb *PebbleCheckpointsDB) RemoveCheckpoint(_ context.Context, tableName string) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+
+	if tableName == allTables {
+		err := cpdb.db.Close()
+		cpdb.db = nil
+		if err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(os.RemoveAll(cpdb.path))
+	}
+
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+	if err := deleteTable(batch, tableName); err != nil {
+		return err
+	}
+	return errors.Trace(batch.Commit(pebbleWriteOptions))
+}
+
+func (cpdb *PebbleCheckpointsDB) MoveCheckpoints(_ context.Context, taskID int64) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	err := cpdb.db.Close()
+	cpdb.db = nil
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	newPath := fmt.Sprintf("%s.%d.bak", cpdb.path, taskID)
+	return errors.Trace(os.Rename(cpdb.path, newPath))
+}
+
+func (cpdb *PebbleCheckpointsDB) GetLocalStoringTables(_ context.Context) (map[string][]int32, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	targetTables := make(map[string][]int32)
+
+	err := cpdb.iterateTables(func(tableName string, tableModel *checkpointspb.TableCheckpointModel) error {
+		if tableModel.Status <= uint32(CheckpointStatusMaxInvalid) ||
+			tableModel.Status >= uint32(CheckpointStatusIndexImported) {
+			return nil
+		}
+		return cpdb.iterateEngines(pebbleTableEnginesPrefix(tableName),
+			func(_ string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) error {
+				if engineModel.Status <= uint32(CheckpointStatusMaxInvalid) ||
+					engineModel.Status >= uint32(CheckpointStatusImported) {
+					return nil
+				}
+				errFound := errors.New("found")
+				err := cpdb.iterateChunks(pebbleEngineChunksPrefix(tableName, engineID),
+					func(_ string, _ int32, chunkModel *checkpointspb.ChunkCheckpointModel) error {
+						if chunkModel.Pos > chunkModel.Offset {
+							return errFound
+						}
+						return nil
+					})
+				if err == errFound {
+					targetTables[tableName] = append(targetTables[tableName], engineID)
+					return nil
+				}
+				return err
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return targetTables, nil
+}
+
+func (cpdb *PebbleCheckpointsDB) IgnoreErrorCheckpoint(_ context.Context, targetTableName string) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+
+	err := cpdb.iterateTables(func(tableName string, tableModel *checkpointspb.TableCheckpointModel) error {
+		if !(targetTableName == allTables || targetTableName == tableName) {
+			return nil
+		}
+		if tableModel.Status <= uint32(CheckpointStatusMaxInvalid) {
+			tableModel.Status = uint32(CheckpointStatusLoaded)
+			if err := setModel(batch, pebbleTableKey(tableName), tableModel); err != nil {
+				return err
+			}
+		}
+		return cpdb.iterateEngines(pebbleTableEnginesPrefix(tableName),
+			func(_ string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) error {
+				if engineModel.Status > uint32(CheckpointStatusMaxInvalid) {
+					return nil
+				}
+				engineModel.Status = uint32(CheckpointStatusLoaded)
+				return setModel(batch, pebbleEngineKey(tableName, engineID), engineModel)
+			})
+	})
+	if err != nil {
+		return err
+	}
+
+	return errors.Trace(batch.Commit(pebbleWriteOptions))
+}
+
+func (cpdb *PebbleCheckpointsDB) DestroyErrorCheckpoint(_ context.Context, targetTableName string) ([]DestroyedTableCheckpoint, error) {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	var targetTables []DestroyedTableCheckpoint
+
+	// Obtain the list of tables
+	err := cpdb.iterateTables(func(tableName string, tableModel *checkpointspb.TableCheckpointModel) error {
+		if !(targetTableName == allTables || targetTableName == tableName) {
+			return nil
+		}
+		if tableModel.Status > uint32(CheckpointStatusMaxInvalid) {
+			return nil
+		}
+		var minEngineID, maxEngineID int32 = math.MaxInt32, math.MinInt32
+		err := cpdb.iterateEngines(pebbleTableEnginesPrefix(tableName),
+			func(_ string, engineID int32, _ *checkpointspb.EngineCheckpointModel) error {
+				if engineID < minEngineID {
+					minEngineID = engineID
+				}
+				if engineID > maxEngineID {
+					maxEngineID = engineID
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+		targetTables = append(targetTables, DestroyedTableCheckpoint{
+			TableName:   tableName,
+			MinEngineID: minEngineID,
+			MaxEngineID: maxEngineID,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete the checkpoints
+	batch := cpdb.db.NewBatch()
+	defer batch.Close()
+	for _, dtcp := range targetTables {
+		if err := deleteTable(batch, dtcp.TableName); err != nil {
+			return nil, err
+		}
+	}
+	if err := batch.Commit(pebbleWriteOptions); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return targetTables, nil
+}
+
+func (cpdb *PebbleCheckpointsDB) DumpTables(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+	taskModel := &checkpointspb.TaskCheckpointModel{}
+	if _, err := cpdb.getModel([]byte(pebbleTaskKey), taskModel); err != nil {
+		return err
+	}
+
+	return dumpCSV(writer, tableDumpColumns, func(w *csv.Writer) error {
+		return cpdb.iterateTables(func(tableName string, tableModel *checkpointspb.TableCheckpointModel) error {
+			return errors.Trace(w.Write(tableDumpRecord(taskModel.TaskId, tableName, tableModel)))
+		})
+	})
+}
+
+func (cpdb *PebbleCheckpointsDB) DumpEngines(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+
+	return dumpCSV(writer, engineDumpColumns, func(w *csv.Writer) error {
+		return cpdb.iterateEngines([]byte(pebbleEnginePrefix),
+			func(tableName string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) error {
+				return errors.Trace(w.Write(engineDumpRecord(tableName, engineID, engineModel)))
+			})
+	})
+}
+
+func (cpdb *PebbleCheckpointsDB) DumpChunks(_ context.Context, writer io.Writer) error {
+	cpdb.lock.Lock()
+	defer cpdb.lock.Unlock()
+
+	if err := cpdb.checkOpen(); err != nil {
+		return err
+	}
+
+	return dumpCSV(writer, chunkDumpColumns, func(w *csv.Writer) error {
+		return cpdb.iterateChunks([]byte(pebbleChunkPrefix),
+			func(tableName string, _ int32, chunkModel *checkpointspb.ChunkCheckpointModel) error {
+				record, err := chunkDumpRecord(tableName, chunkModel)
+				if err != nil {
+					return err
+				}
+				return errors.Trace(w.Write(record))
+			})
+	})
+}
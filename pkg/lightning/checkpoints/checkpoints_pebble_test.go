@@ -0,0 +1,364 @@
+package checkpoints_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/verification"
+)
+
+var _ = Suite(&cpPebbleSuite{})
+
+type cpPebbleSuite struct {
+	path string
+	cpdb *checkpoints.PebbleCheckpointsDB
+}
+
+func (s *cpPebbleSuite) SetUpTest(c *C) {
+	s.path = filepath.Join(c.MkDir(), "cp.pebble")
+	var err error
+	s.cpdb, err = checkpoints.NewPebbleCheckpointsDB(s.path)
+	c.Assert(err, IsNil)
+
+	ctx := context.Background()
+	cpdb := s.cpdb
+
+	// 2. initialize with checkpoint data.
+	cfg := newTestConfig()
+	err = cpdb.Initialize(ctx, cfg, map[string]*checkpoints.TidbDBInfo{
+		"db1": {
+			Name: "db1",
+			Tables: map[string]*checkpoints.TidbTableInfo{
+				"t1": {Name: "t1"},
+				"t2": {Name: "t2"},
+			},
+		},
+		"db2": {
+			Name: "db2",
+			Tables: map[string]*checkpoints.TidbTableInfo{
+				"t3": {Name: "t3"},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// 3. set some checkpoints
+
+	err = cpdb.InsertEngineCheckpoints(ctx, "`db1`.`t2`", map[int32]*checkpoints.EngineCheckpoint{
+		0: {
+			Status: checkpoints.CheckpointStatusLoaded,
+			Chunks: []*checkpoints.ChunkCheckpoint{{
+				Key: checkpoints.ChunkCheckpointKey{
+					Path:   "/tmp/path/1.sql",
+					Offset: 0,
+				},
+				FileMeta: mydump.SourceFileMeta{
+					Path:     "/tmp/path/1.sql",
+					Type:     mydump.SourceTypeSQL,
+					FileSize: 12345,
+				},
+				Chunk: mydump.Chunk{
+					Offset:       12,
+					EndOffset:    102400,
+					PrevRowIDMax: 1,
+					RowIDMax:     5000,
+				},
+			}},
+		},
+		-1: {
+			Status: checkpoints.CheckpointStatusLoaded,
+			Chunks: nil,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	err = cpdb.InsertEngineCheckpoints(ctx, "`db2`.`t3`", map[int32]*checkpoints.EngineCheckpoint{
+		-1: {
+			Status: checkpoints.CheckpointStatusLoaded,
+			Chunks: nil,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	// 4. update some checkpoints
+
+	cpd := checkpoints.NewTableCheckpointDiff()
+	scm := checkpoints.StatusCheckpointMerger{
+		EngineID: 0,
+		Status:   checkpoints.CheckpointStatusImported,
+	}
+	scm.MergeInto(cpd)
+	scm = checkpoints.StatusCheckpointMerger{
+		EngineID: checkpoints.WholeTableEngineID,
+		Status:   checkpoints.CheckpointStatusAllWritten,
+	}
+	scm.MergeInto(cpd)
+	rcm := checkpoints.RebaseCheckpointMerger{
+		AllocBase: 132861,
+	}
+	rcm.MergeInto(cpd)
+	cksum := checkpoints.TableChecksumMerger{
+		Checksum: verification.MakeKVChecksum(4492, 686, 486070148910),
+	}
+	cksum.MergeInto(cpd)
+	tcm := checkpoints.TableControlMerger{
+		Control:  checkpoints.TableControlPaused,
+		Priority: 7,
+	}
+	tcm.MergeInto(cpd)
+	removed := checkpoints.RemovedChecksumMerger{
+		Checksum: verification.MakeKVChecksum(12, 3, 456),
+	}
+	removed.MergeInto(cpd)
+	ccm := checkpoints.ChunkCheckpointMerger{
+		EngineID: 0,
+		Key:      checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+		Checksum: verification.MakeKVChecksum(4491, 586, 486070148917),
+		Pos:      55904,
+		RowID:    681,
+	}
+	ccm.MergeInto(cpd)
+
+	cpdb.Update(map[string]*checkpoints.TableCheckpointDiff{"`db1`.`t2`": cpd})
+}
+
+func (s *cpPebbleSuite) TearDownTest(c *C) {
+	c.Assert(s.cpdb.Close(), IsNil)
+}
+
+func (s *cpPebbleSuite) setInvalidStatus() {
+	cpd := checkpoints.NewTableCheckpointDiff()
+	scm := checkpoints.StatusCheckpointMerger{
+		EngineID: -1,
+		Status:   checkpoints.CheckpointStatusAllWritten,
+	}
+	scm.SetInvalid()
+	scm.MergeInto(cpd)
+
+	s.cpdb.Update(map[string]*checkpoints.TableCheckpointDiff{
+		"`db1`.`t2`": cpd,
+		"`db2`.`t3`": cpd,
+	})
+}
+
+func (s *cpPebbleSuite) TestGet(c *C) {
+	ctx := context.Background()
+
+	// the checkpoints are persisted after reopening.
+	c.Assert(s.cpdb.Close(), IsNil)
+	var err error
+	s.cpdb, err = checkpoints.NewPebbleCheckpointsDB(s.path)
+	c.Assert(err, IsNil)
+
+	taskCp, err := s.cpdb.TaskCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(taskCp.TaskID, Equals, int64(123))
+	c.Assert(taskCp.Backend, Equals, "local")
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp, DeepEquals, &checkpoints.TableCheckpoint{
+		Status:    checkpoints.CheckpointStatusAllWritten,
+		AllocBase: 132861,
+		Checksum:  verification.MakeKVChecksum(4492, 686, 486070148910),
+		Control:   checkpoints.TableControlPaused,
+		Priority:  7,
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			-1: {
+				Status: checkpoints.CheckpointStatusLoaded,
+				Chunks: []*checkpoints.ChunkCheckpoint{},
+			},
+			0: {
+				Status: checkpoints.CheckpointStatusImported,
+				Chunks: []*checkpoints.ChunkCheckpoint{{
+					Key: checkpoints.ChunkCheckpointKey{
+						Path:   "/tmp/path/1.sql",
+						Offset: 0,
+					},
+					FileMeta: mydump.SourceFileMeta{
+						Path:     "/tmp/path/1.sql",
+						Type:     mydump.SourceTypeSQL,
+						FileSize: 12345,
+					},
+					ColumnPermutation: []int{},
+					Chunk: mydump.Chunk{
+						Offset:       55904,
+						EndOffset:    102400,
+						PrevRowIDMax: 681,
+						RowIDMax:     5000,
+					},
+					Checksum: verification.MakeKVChecksum(4491, 586, 486070148917),
+				}},
+			},
+		},
+
+		RemovedChecksum: verification.MakeKVChecksum(12, 3, 456),
+	})
+
+	cp, err = s.cpdb.Get(ctx, "`db2`.`t3`")
+	c.Assert(err, IsNil)
+	c.Assert(cp, DeepEquals, &checkpoints.TableCheckpoint{
+		Status: checkpoints.CheckpointStatusLoaded,
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			-1: {
+				Status: checkpoints.CheckpointStatusLoaded,
+				Chunks: []*checkpoints.ChunkCheckpoint{},
+			},
+		},
+	})
+
+	cp, err = s.cpdb.Get(ctx, "`db3`.`not-exists`")
+	c.Assert(cp, IsNil)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+}
+
+func (s *cpPebbleSuite) TestRemoveAllCheckpoints(c *C) {
+	ctx := context.Background()
+
+	err := s.cpdb.RemoveCheckpoint(ctx, "all")
+	c.Assert(err, IsNil)
+	_, err = os.Stat(s.path)
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(cp, IsNil)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+}
+
+func (s *cpPebbleSuite) TestRemoveOneCheckpoint(c *C) {
+	ctx := context.Background()
+
+	err := s.cpdb.RemoveCheckpoint(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(cp, IsNil)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+
+	cp, err = s.cpdb.Get(ctx, "`db2`.`t3`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusLoaded)
+	c.Assert(cp.Engines, HasLen, 1)
+}
+
+func (s *cpPebbleSuite) TestMoveCheckpoints(c *C) {
+	err := s.cpdb.MoveCheckpoints(context.Background(), 123)
+	c.Assert(err, IsNil)
+	_, err = os.Stat(s.path)
+	c.Assert(os.IsNotExist(err), IsTrue)
+
+	s.cpdb, err = checkpoints.NewPebbleCheckpointsDB(s.path + ".123.bak")
+	c.Assert(err, IsNil)
+	cp, err := s.cpdb.Get(context.Background(), "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.AllocBase, Equals, int64(132861))
+}
+
+func (s *cpPebbleSuite) TestGetLocalStoringTables(c *C) {
+	tables, err := s.cpdb.GetLocalStoringTables(context.Background())
+	c.Assert(err, IsNil)
+	// engine 0 of `db1`.`t2` is already imported.
+	c.Assert(tables, HasLen, 0)
+
+	cpd := checkpoints.NewTableCheckpointDiff()
+	scm := checkpoints.StatusCheckpointMerger{
+		EngineID: 0,
+		Status:   checkpoints.CheckpointStatusClosed,
+	}
+	scm.MergeInto(cpd)
+	s.cpdb.Update(map[string]*checkpoints.TableCheckpointDiff{"`db1`.`t2`": cpd})
+
+	tables, err = s.cpdb.GetLocalStoringTables(context.Background())
+	c.Assert(err, IsNil)
+	c.Assert(tables, DeepEquals, map[string][]int32{"`db1`.`t2`": {0}})
+}
+
+func (s *cpPebbleSuite) TestIgnoreOneErrorCheckpoints(c *C) {
+	ctx := context.Background()
+
+	s.setInvalidStatus()
+
+	err := s.cpdb.IgnoreErrorCheckpoint(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusLoaded)
+	c.Assert(cp.Engines[-1].Status, Equals, checkpoints.CheckpointStatusLoaded)
+
+	cp, err = s.cpdb.Get(ctx, "`db2`.`t3`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusAllWritten/10)
+	c.Assert(cp.Engines[-1].Status, Equals, checkpoints.CheckpointStatusAllWritten/10)
+}
+
+func (s *cpPebbleSuite) TestDestroyAllErrorCheckpoints(c *C) {
+	ctx := context.Background()
+
+	s.setInvalidStatus()
+
+	dtc, err := s.cpdb.DestroyErrorCheckpoint(ctx, "all")
+	c.Assert(err, IsNil)
+	sort.Slice(dtc, func(i, j int) bool { return dtc[i].TableName < dtc[j].TableName })
+	c.Assert(dtc, DeepEquals, []checkpoints.DestroyedTableCheckpoint{
+		{
+			TableName:   "`db1`.`t2`",
+			MinEngineID: -1,
+			MaxEngineID: 0,
+		},
+		{
+			TableName:   "`db2`.`t3`",
+			MinEngineID: -1,
+			MaxEngineID: -1,
+		},
+	})
+
+	cp, err := s.cpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(cp, IsNil)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+
+	cp, err = s.cpdb.Get(ctx, "`db2`.`t3`")
+	c.Assert(cp, IsNil)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+
+	cp, err = s.cpdb.Get(ctx, "`db1`.`t1`")
+	c.Assert(err, IsNil)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusLoaded)
+}
+
+func (s *cpPebbleSuite) TestDump(c *C) {
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	c.Assert(s.cpdb.DumpTables(ctx, &buf), IsNil)
+	c.Assert(buf.String(), Equals,
+		"task_id,table_name,hash,status,alloc_base,control,priority,create_time,update_time\n"+
+			"123,`db1`.`t1`,,30,0,0,0,,\n"+
+			"123,`db1`.`t2`,,60,132861,1,7,,\n"+
+			"123,`db2`.`t3`,,30,0,0,0,,\n",
+	)
+
+	buf.Reset()
+	c.Assert(s.cpdb.DumpEngines(ctx, &buf), IsNil)
+	c.Assert(buf.String(), Equals,
+		"table_name,engine_id,status,create_time,update_time\n"+
+			"`db1`.`t2`,-1,30,,\n"+
+			"`db1`.`t2`,0,120,,\n"+
+			"`db2`.`t3`,-1,30,,\n",
+	)
+
+	buf.Reset()
+	c.Assert(s.cpdb.DumpChunks(ctx, &buf), IsNil)
+	c.Assert(buf.String(), Equals,
+		"table_name,path,offset,type,compression,sort_key,file_size,columns,pos,end_offset,prev_rowid_max,rowid_max,kvc_bytes,kvc_kvs,kvc_checksum,create_time,update_time\n"+
+			"`db1`.`t2`,/tmp/path/1.sql,0,3,0,,12345,null,55904,102400,681,5000,4491,586,486070148917,,\n",
+	)
+}
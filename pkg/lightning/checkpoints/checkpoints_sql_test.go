@@ -3,6 +3,7 @@ package checkpoints_test
 import (
 	"context"
 	"database/sql"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -179,6 +180,15 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 		Checksum: verification.MakeKVChecksum(4492, 686, 486070148910),
 	}
 	cksum.MergeInto(cpd)
+	tcm := checkpoints.TableControlMerger{
+		Control:  checkpoints.TableControlPaused,
+		Priority: 7,
+	}
+	tcm.MergeInto(cpd)
+	removed := checkpoints.RemovedChecksumMerger{
+		Checksum: verification.MakeKVChecksum(12, 3, 456),
+	}
+	removed.MergeInto(cpd)
 	ccm := checkpoints.ChunkCheckpointMerger{
 		EngineID: 0,
 		Key:      checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
@@ -217,6 +227,16 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 		ExpectExec().
 		WithArgs(4492, 686, 486070148910, "`db1`.`t2`").
 		WillReturnResult(sqlmock.NewResult(15, 1))
+	s.mock.
+		ExpectPrepare("UPDATE `mock-schema`\\.table_v\\d+ SET control = .+").
+		ExpectExec().
+		WithArgs(checkpoints.TableControlPaused, 7, "`db1`.`t2`").
+		WillReturnResult(sqlmock.NewResult(16, 1))
+	s.mock.
+		ExpectPrepare("UPDATE `mock-schema`\\.table_v\\d+ SET removed_kv_bytes = .+").
+		ExpectExec().
+		WithArgs(12, 3, 456, "`db1`.`t2`").
+		WillReturnResult(sqlmock.NewResult(17, 1))
 
 	s.mock.ExpectCommit()
 
@@ -252,11 +272,14 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 				),
 		)
 	s.mock.
-		ExpectQuery("SELECT .+ FROM `mock-schema`\\.table_v\\d+").
+		ExpectQuery("SELECT (?s:.+) FROM `mock-schema`\\.table_v\\d+").
 		WithArgs("`db1`.`t2`").
 		WillReturnRows(
-			sqlmock.NewRows([]string{"status", "alloc_base", "table_id", "kv_bytes", "kv_kvs", "kv_checksum"}).
-				AddRow(60, 132861, int64(2), uint64(4492), uint64(686), uint64(486070148910)),
+			sqlmock.NewRows([]string{
+				"status", "alloc_base", "table_id", "kv_bytes", "kv_kvs", "kv_checksum", "control", "priority",
+				"removed_kv_bytes", "removed_kv_kvs", "removed_kv_checksum",
+			}).
+				AddRow(60, 132861, int64(2), uint64(4492), uint64(686), uint64(486070148910), 1, 7, uint64(12), uint64(3), uint64(456)),
 		)
 	s.mock.ExpectCommit()
 
@@ -293,6 +316,10 @@ func (s *cpSQLSuite) TestNormalOperations(c *C) {
 			},
 		},
 		Checksum: verification.MakeKVChecksum(4492, 686, 486070148910),
+		Control:  checkpoints.TableControlPaused,
+		Priority: 7,
+
+		RemovedChecksum: verification.MakeKVChecksum(12, 3, 456),
 	})
 	c.Assert(s.mock.ExpectationsWereMet(), IsNil)
 }
@@ -494,16 +521,16 @@ func (s *cpSQLSuite) TestDump(c *C) {
 	s.mock.
 		ExpectQuery("SELECT .+ FROM `mock-schema`\\.table_v\\d+").
 		WillReturnRows(
-			sqlmock.NewRows([]string{"task_id", "table_name", "hash", "status", "alloc_base", "create_time", "update_time"}).
-				AddRow(1555555555, "`db1`.`t2`", 0, 90, 132861, t, t),
+			sqlmock.NewRows([]string{"task_id", "table_name", "hash", "status", "alloc_base", "control", "priority", "create_time", "update_time"}).
+				AddRow(1555555555, "`db1`.`t2`", 0, 90, 132861, 1, 7, t, t),
 		)
 
 	csvBuilder.Reset()
 	err = s.cpdb.DumpTables(ctx, &csvBuilder)
 	c.Assert(err, IsNil)
 	c.Assert(csvBuilder.String(), Equals,
-		"task_id,table_name,hash,status,alloc_base,create_time,update_time\n"+
-			"1555555555,`db1`.`t2`,0,90,132861,2019-04-18 02:45:55 +0000 UTC,2019-04-18 02:45:55 +0000 UTC\n",
+		"task_id,table_name,hash,status,alloc_base,control,priority,create_time,update_time\n"+
+			"1555555555,`db1`.`t2`,0,90,132861,1,7,2019-04-18 02:45:55 +0000 UTC,2019-04-18 02:45:55 +0000 UTC\n",
 	)
 }
 
@@ -529,3 +556,169 @@ func (s *cpSQLSuite) TestMoveCheckpoints(c *C) {
 	err := s.cpdb.MoveCheckpoints(ctx, 12345678)
 	c.Assert(err, IsNil)
 }
+
+func (s *cpSQLSuite) TestConvertFileCheckpointsToMySQL(c *C) {
+	ctx := context.Background()
+
+	fileCpdb := checkpoints.NewFileCheckpointsDB(filepath.Join(c.MkDir(), "cp.pb"))
+	defer fileCpdb.Close()
+	err := fileCpdb.Initialize(ctx, newTestConfig(), map[string]*checkpoints.TidbDBInfo{
+		"db1": {
+			Name:   "db1",
+			Tables: map[string]*checkpoints.TidbTableInfo{"t2": {ID: 2, Name: "t2"}},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = fileCpdb.InsertEngineCheckpoints(ctx, "`db1`.`t2`", map[int32]*checkpoints.EngineCheckpoint{
+		0: {
+			Status: checkpoints.CheckpointStatusLoaded,
+			Chunks: []*checkpoints.ChunkCheckpoint{{
+				Key:               checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+				FileMeta:          mydump.SourceFileMeta{Path: "/tmp/path/1.sql", Type: mydump.SourceTypeSQL, FileSize: 456},
+				ColumnPermutation: []int{1, 0},
+				Chunk:             mydump.Chunk{Offset: 0, EndOffset: 102400, PrevRowIDMax: 1, RowIDMax: 5000},
+				Timestamp:         1555555555,
+			}},
+		},
+	})
+	c.Assert(err, IsNil)
+	cpd := checkpoints.NewTableCheckpointDiff()
+	ccm := checkpoints.ChunkCheckpointMerger{
+		EngineID:          0,
+		Key:               checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+		Checksum:          verification.MakeKVChecksum(4491, 586, 486070148917),
+		Pos:               55904,
+		RowID:             681,
+		ColumnPermutation: []int{1, 0},
+	}
+	ccm.MergeInto(cpd)
+	fileCpdb.Update(map[string]*checkpoints.TableCheckpointDiff{"`db1`.`t2`": cpd})
+
+	s.mock.ExpectBegin()
+	s.mock.ExpectExec("DELETE FROM `mock-schema`\\.chunk_v\\d+").WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("DELETE FROM `mock-schema`\\.engine_v\\d+").WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("DELETE FROM `mock-schema`\\.table_v\\d+").WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.ExpectExec("DELETE FROM `mock-schema`\\.task_v\\d+").WillReturnResult(sqlmock.NewResult(0, 0))
+	s.mock.
+		ExpectExec("REPLACE INTO `mock-schema`\\.task_v\\d+").
+		WithArgs(123, "/data", "local", "127.0.0.1:8287", "127.0.0.1", 4000, "127.0.0.1:2379", "/tmp/sorted-kv", build.ReleaseVersion).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	tableStmt := s.mock.ExpectPrepare("REPLACE INTO `mock-schema`\\.table_v\\d+")
+	engineStmt := s.mock.ExpectPrepare("REPLACE INTO `mock-schema`\\.engine_v\\d+")
+	chunkStmt := s.mock.ExpectPrepare("REPLACE INTO `mock-schema`\\.chunk_v\\d+")
+	tableStmt.ExpectExec().
+		WithArgs(123, "`db1`.`t2`", []byte{}, 30, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	engineStmt.ExpectExec().
+		WithArgs("`db1`.`t2`", 0, 30).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	chunkStmt.ExpectExec().
+		WithArgs(
+			"`db1`.`t2`", 0,
+			"/tmp/path/1.sql", 0, mydump.SourceTypeSQL, 0, "", 456, []byte("[1,0]"),
+			55904, 102400, 681, 5000,
+			4491, 586, 486070148917, 1555555555,
+		).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	s.mock.ExpectCommit()
+
+	err = checkpoints.ConvertFileCheckpointsToMySQL(ctx, fileCpdb, s.cpdb)
+	c.Assert(err, IsNil)
+	c.Assert(s.mock.ExpectationsWereMet(), IsNil)
+}
+
+func (s *cpSQLSuite) TestConvertMySQLCheckpointsToFile(c *C) {
+	ctx := context.Background()
+
+	s.mock.ExpectBegin()
+	s.mock.
+		ExpectQuery("SELECT .+ FROM `mock-schema`\\.task_v\\d+").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"task_id", "source_dir", "backend", "importer_addr", "tidb_host", "tidb_port", "pd_addr", "sorted_kv_dir", "lightning_ver"}).
+				AddRow(123, "/data", "local", "127.0.0.1:8287", "127.0.0.1", 4000, "127.0.0.1:2379", "/tmp/sorted-kv", "v5.0.0"),
+		)
+	s.mock.
+		ExpectQuery("SELECT (?s:.+) FROM `mock-schema`\\.table_v\\d+").
+		WillReturnRows(
+			sqlmock.NewRows([]string{
+				"table_name", "hash", "status", "alloc_base", "table_id", "kv_bytes", "kv_kvs", "kv_checksum", "control", "priority",
+				"removed_kv_bytes", "removed_kv_kvs", "removed_kv_checksum",
+			}).
+				AddRow("`db1`.`t2`", []byte{}, 60, 132861, 2, 4492, 686, 486070148910, 2, -3, 12, 3, 456),
+		)
+	s.mock.
+		ExpectQuery("SELECT .+ FROM `mock-schema`\\.engine_v\\d+").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"table_name", "engine_id", "status"}).
+				AddRow("`db1`.`t2`", -1, 30).
+				AddRow("`db1`.`t2`", 0, 120),
+		)
+	s.mock.
+		ExpectQuery("SELECT .+ FROM `mock-schema`\\.chunk_v\\d+").
+		WillReturnRows(
+			sqlmock.NewRows([]string{
+				"table_name", "engine_id", "path", "offset", "type", "compression", "sort_key", "file_size", "columns",
+				"pos", "end_offset", "prev_rowid_max", "rowid_max",
+				"kvc_bytes", "kvc_kvs", "kvc_checksum", "unix_timestamp(create_time)",
+			}).AddRow(
+				"`db1`.`t2`", 0, "/tmp/path/1.sql", 0, mydump.SourceTypeSQL, mydump.CompressionNone, "", 456, "[]",
+				55904, 102400, 681, 5000,
+				4491, 586, 486070148917, 1555555555,
+			),
+		)
+	s.mock.ExpectCommit()
+
+	path := filepath.Join(c.MkDir(), "cp.pb")
+	fileCpdb := checkpoints.NewFileCheckpointsDB(path)
+	err := checkpoints.ConvertMySQLCheckpointsToFile(ctx, s.cpdb, fileCpdb)
+	c.Assert(err, IsNil)
+	c.Assert(s.mock.ExpectationsWereMet(), IsNil)
+	c.Assert(fileCpdb.Close(), IsNil)
+
+	// the converted checkpoints are saved.
+	fileCpdb = checkpoints.NewFileCheckpointsDB(path)
+	defer fileCpdb.Close()
+	taskCp, err := fileCpdb.TaskCheckpoint(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(taskCp.TaskID, Equals, int64(123))
+	c.Assert(taskCp.LightningVer, Equals, "v5.0.0")
+
+	cp, err := fileCpdb.Get(ctx, "`db1`.`t2`")
+	c.Assert(err, IsNil)
+	c.Assert(cp, DeepEquals, &checkpoints.TableCheckpoint{
+		Status:    checkpoints.CheckpointStatusAllWritten,
+		AllocBase: 132861,
+		TableID:   2,
+		Checksum:  verification.MakeKVChecksum(4492, 686, 486070148910),
+		Control:   checkpoints.TableControlCancelled,
+		Priority:  -3,
+
+		RemovedChecksum: verification.MakeKVChecksum(12, 3, 456),
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			-1: {
+				Status: checkpoints.CheckpointStatusLoaded,
+				Chunks: []*checkpoints.ChunkCheckpoint{},
+			},
+			0: {
+				Status: checkpoints.CheckpointStatusImported,
+				Chunks: []*checkpoints.ChunkCheckpoint{{
+					Key: checkpoints.ChunkCheckpointKey{Path: "/tmp/path/1.sql", Offset: 0},
+					FileMeta: mydump.SourceFileMeta{
+						Path:     "/tmp/path/1.sql",
+						Type:     mydump.SourceTypeSQL,
+						FileSize: 456,
+					},
+					ColumnPermutation: []int{},
+					Chunk: mydump.Chunk{
+						Offset:       55904,
+						EndOffset:    102400,
+						PrevRowIDMax: 681,
+						RowIDMax:     5000,
+					},
+					Checksum:  verification.MakeKVChecksum(4491, 586, 486070148917),
+					Timestamp: 1555555555,
+				}},
+			},
+		},
+	})
+}
@@ -190,6 +190,22 @@ func (s *checkpointSuite) TestRebaseCheckpoint(c *C) {
 	})
 }
 
+func (s *checkpointSuite) TestTableControlCheckpoint(c *C) {
+	cpd := NewTableCheckpointDiff()
+
+	m := TableControlMerger{Control: TableControlPaused, Priority: 3}
+	m.MergeInto(cpd)
+	m = TableControlMerger{Control: TableControlCancelled, Priority: 5}
+	m.MergeInto(cpd)
+
+	c.Assert(cpd, DeepEquals, &TableCheckpointDiff{
+		hasControl: true,
+		control:    TableControlCancelled,
+		priority:   5,
+		engines:    make(map[int32]engineCheckpointDiff),
+	})
+}
+
 func (s *checkpointSuite) TestApplyDiff(c *C) {
 	cp := TableCheckpoint{
 		Status:    CheckpointStatusLoaded,
@@ -229,6 +245,7 @@ func (s *checkpointSuite) TestApplyDiff(c *C) {
 	(&StatusCheckpointMerger{EngineID: WholeTableEngineID, Status: CheckpointStatusAllWritten}).MergeInto(cpd)
 	(&StatusCheckpointMerger{EngineID: 1234, Status: CheckpointStatusAnalyzeSkipped}).MergeInto(cpd)
 	(&RebaseCheckpointMerger{AllocBase: 11111}).MergeInto(cpd)
+	(&TableControlMerger{Control: TableControlPaused, Priority: -2}).MergeInto(cpd)
 	(&ChunkCheckpointMerger{
 		EngineID: 0,
 		Key:      ChunkCheckpointKey{Path: "/tmp/01.sql"},
@@ -260,6 +277,8 @@ func (s *checkpointSuite) TestApplyDiff(c *C) {
 	c.Assert(cp, DeepEquals, TableCheckpoint{
 		Status:    CheckpointStatusAllWritten,
 		AllocBase: 11111,
+		Control:   TableControlPaused,
+		Priority:  -2,
 		Engines: map[int32]*EngineCheckpoint{
 			-1: {
 				Status: CheckpointStatusImported,
@@ -109,14 +109,19 @@ func (m *TaskCheckpointModel) XXX_DiscardUnknown() {
 var xxx_messageInfo_TaskCheckpointModel proto.InternalMessageInfo
 
 type TableCheckpointModel struct {
-	Hash       []byte                           `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
-	Status     uint32                           `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
-	AllocBase  int64                            `protobuf:"varint,4,opt,name=alloc_base,json=allocBase,proto3" json:"alloc_base,omitempty"`
-	Engines    map[int32]*EngineCheckpointModel `protobuf:"bytes,8,rep,name=engines,proto3" json:"engines,omitempty" protobuf_key:"zigzag32,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
-	TableID    int64                            `protobuf:"varint,9,opt,name=tableID,proto3" json:"tableID,omitempty"`
-	KvBytes    uint64                           `protobuf:"varint,10,opt,name=kv_bytes,json=kvBytes,proto3" json:"kv_bytes,omitempty"`
-	KvKvs      uint64                           `protobuf:"varint,11,opt,name=kv_kvs,json=kvKvs,proto3" json:"kv_kvs,omitempty"`
-	KvChecksum uint64                           `protobuf:"fixed64,12,opt,name=kv_checksum,json=kvChecksum,proto3" json:"kv_checksum,omitempty"`
+	Hash              []byte                           `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Status            uint32                           `protobuf:"varint,3,opt,name=status,proto3" json:"status,omitempty"`
+	AllocBase         int64                            `protobuf:"varint,4,opt,name=alloc_base,json=allocBase,proto3" json:"alloc_base,omitempty"`
+	Engines           map[int32]*EngineCheckpointModel `protobuf:"bytes,8,rep,name=engines,proto3" json:"engines,omitempty" protobuf_key:"zigzag32,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	TableID           int64                            `protobuf:"varint,9,opt,name=tableID,proto3" json:"tableID,omitempty"`
+	KvBytes           uint64                           `protobuf:"varint,10,opt,name=kv_bytes,json=kvBytes,proto3" json:"kv_bytes,omitempty"`
+	KvKvs             uint64                           `protobuf:"varint,11,opt,name=kv_kvs,json=kvKvs,proto3" json:"kv_kvs,omitempty"`
+	KvChecksum        uint64                           `protobuf:"fixed64,12,opt,name=kv_checksum,json=kvChecksum,proto3" json:"kv_checksum,omitempty"`
+	Control           uint32                           `protobuf:"varint,13,opt,name=control,proto3" json:"control,omitempty"`
+	Priority          int32                            `protobuf:"varint,14,opt,name=priority,proto3" json:"priority,omitempty"`
+	RemovedKvBytes    uint64                           `protobuf:"varint,15,opt,name=removed_kv_bytes,json=removedKvBytes,proto3" json:"removed_kv_bytes,omitempty"`
+	RemovedKvKvs      uint64                           `protobuf:"varint,16,opt,name=removed_kv_kvs,json=removedKvKvs,proto3" json:"removed_kv_kvs,omitempty"`
+	RemovedKvChecksum uint64                           `protobuf:"fixed64,17,opt,name=removed_kv_checksum,json=removedKvChecksum,proto3" json:"removed_kv_checksum,omitempty"`
 }
 
 func (m *TableCheckpointModel) Reset()         { *m = TableCheckpointModel{} }
@@ -258,62 +263,66 @@ func init() {
 }
 
 var fileDescriptor_c57c7b77a714394c = []byte{
-	// 870 bytes of a gzipped FileDescriptorProto
+	// 937 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x55, 0xcd, 0x6e, 0xdb, 0x46,
-	0x10, 0x36, 0x45, 0x8b, 0x92, 0x96, 0x92, 0x23, 0x6f, 0xed, 0x64, 0xeb, 0xb6, 0x2a, 0xab, 0xf4,
-	0x20, 0x20, 0x8d, 0x04, 0xa4, 0x97, 0x22, 0x68, 0x0b, 0xd4, 0x76, 0x80, 0x06, 0x46, 0x50, 0x63,
-	0x9b, 0xf6, 0xd0, 0x0b, 0xc1, 0x9f, 0xb5, 0x44, 0xac, 0xc8, 0x25, 0xb8, 0x4b, 0x36, 0xca, 0x53,
-	0xf4, 0x31, 0xfa, 0x12, 0xbd, 0x07, 0x3d, 0xe5, 0xd8, 0x63, 0x6a, 0xdf, 0xfb, 0x0c, 0xc5, 0xce,
-	0x52, 0x16, 0x15, 0x08, 0x46, 0x6e, 0x33, 0xdf, 0x7c, 0x3b, 0x3b, 0xfb, 0xe9, 0x1b, 0x11, 0x7d,
-	0x9f, 0xf3, 0xf9, 0x6c, 0x99, 0xcc, 0x17, 0x2a, 0x4b, 0xb2, 0xf9, 0x2c, 0x5a, 0xb0, 0x88, 0xe7,
-	0x22, 0xc9, 0x94, 0x6c, 0xc6, 0x79, 0x38, 0xbb, 0x4a, 0x96, 0xcc, 0x6f, 0x40, 0xd3, 0xbc, 0x10,
-	0x4a, 0x9c, 0x3c, 0x9e, 0x27, 0x6a, 0x51, 0x86, 0xd3, 0x48, 0xa4, 0xb3, 0xb9, 0x98, 0x8b, 0x19,
-	0xc0, 0x61, 0x79, 0x05, 0x19, 0x24, 0x10, 0x19, 0xfa, 0xf8, 0x3f, 0x0b, 0x0d, 0xcf, 0x36, 0x4d,
-	0x5e, 0x88, 0x98, 0x2d, 0xf1, 0x39, 0x72, 0x1b, 0x8d, 0x89, 0xe5, 0xd9, 0x13, 0xf7, 0xc9, 0x78,
-	0xfa, 0x3e, 0xaf, 0x09, 0x3c, 0xcb, 0x54, 0xb1, 0xa2, 0xcd, 0x63, 0xf8, 0x3b, 0x74, 0x4f, 0x05,
-	0x92, 0x37, 0x66, 0x24, 0x2d, 0xcf, 0x9a, 0xb8, 0x4f, 0x8e, 0xa6, 0x2f, 0x03, 0xc9, 0x37, 0x87,
-	0xa1, 0x19, 0x3d, 0x50, 0x5b, 0xe0, 0xc9, 0x2f, 0x5b, 0x83, 0x41, 0x7f, 0x3c, 0x44, 0x36, 0x67,
-	0x2b, 0x62, 0x79, 0xd6, 0xa4, 0x47, 0x75, 0x88, 0x1f, 0xa1, 0x76, 0x15, 0x2c, 0x4b, 0x56, 0xb7,
-	0x3e, 0x9e, 0xbe, 0x0c, 0xc2, 0x25, 0x7b, 0xbf, 0xb7, 0xe1, 0x3c, 0x6d, 0x7d, 0x63, 0x8d, 0xff,
-	0x6c, 0xa1, 0x8f, 0x76, 0x5c, 0x8f, 0x1f, 0xa0, 0x0e, 0x4c, 0x9b, 0xc4, 0xd0, 0xde, 0xa6, 0x8e,
-	0x4e, 0x9f, 0xc7, 0xf8, 0x33, 0x84, 0xa4, 0x28, 0x8b, 0x88, 0xf9, 0x71, 0x52, 0xc0, 0x35, 0x3d,
-	0xda, 0x33, 0xc8, 0x79, 0x52, 0x60, 0x82, 0x3a, 0x61, 0x10, 0x71, 0x96, 0xc5, 0xc4, 0x86, 0xda,
-	0x3a, 0xc5, 0x0f, 0xd1, 0x20, 0x49, 0x73, 0x51, 0x28, 0x56, 0xf8, 0x41, 0x1c, 0x17, 0x64, 0x1f,
-	0xea, 0xfd, 0x35, 0xf8, 0x43, 0x1c, 0x17, 0xf8, 0x13, 0xd4, 0x53, 0x49, 0x1c, 0xfa, 0x0b, 0x21,
-	0x15, 0x69, 0x03, 0xa1, 0xab, 0x81, 0x1f, 0x85, 0x54, 0xb7, 0x45, 0xcd, 0x27, 0x8e, 0x67, 0x4d,
-	0xda, 0xa6, 0x78, 0x29, 0x0a, 0xa5, 0x07, 0xce, 0x63, 0xd3, 0xb8, 0x03, 0xe7, 0x9c, 0x3c, 0x86,
-	0x96, 0x63, 0x34, 0x90, 0xfa, 0x82, 0xd8, 0xe7, 0x15, 0xcc, 0xdc, 0x85, 0xb2, 0x6b, 0xc0, 0x8b,
-	0x4a, 0x4f, 0xfd, 0x10, 0x0d, 0x6e, 0x3d, 0xe6, 0x57, 0xac, 0x20, 0x3d, 0x33, 0xdb, 0x2d, 0xf8,
-	0x2b, 0x2b, 0xc6, 0xef, 0x5a, 0xe8, 0x68, 0x97, 0x9c, 0x18, 0xa3, 0xfd, 0x45, 0x20, 0x17, 0x20,
-	0x54, 0x9f, 0x42, 0x8c, 0xef, 0x23, 0x47, 0xaa, 0x40, 0x95, 0x12, 0x64, 0x18, 0xd0, 0x3a, 0xd3,
-	0xf2, 0x05, 0xcb, 0xa5, 0x88, 0xfc, 0x30, 0x90, 0x0c, 0x24, 0xb0, 0x69, 0x0f, 0x90, 0xd3, 0x40,
-	0x32, 0xfc, 0x2d, 0xea, 0xb0, 0x6c, 0x9e, 0x64, 0x4c, 0x92, 0x6e, 0x6d, 0xb3, 0x5d, 0x57, 0x4e,
-	0x9f, 0x19, 0x92, 0xb1, 0xd9, 0xfa, 0x88, 0x16, 0x5f, 0x69, 0xf6, 0xf3, 0x73, 0x78, 0x80, 0x4d,
-	0xd7, 0x29, 0xfe, 0x18, 0x75, 0x79, 0xe5, 0x87, 0x2b, 0xc5, 0x24, 0x41, 0x9e, 0x35, 0xd9, 0xa7,
-	0x1d, 0x5e, 0x9d, 0xea, 0x14, 0x1f, 0x23, 0x87, 0x57, 0x3e, 0xaf, 0x24, 0x71, 0xa1, 0xd0, 0xe6,
-	0xd5, 0x45, 0x25, 0xf1, 0xe7, 0xc8, 0xe5, 0x95, 0x31, 0xab, 0x2c, 0x53, 0xd2, 0xf7, 0xac, 0x89,
-	0x43, 0x11, 0xaf, 0xce, 0x6a, 0xe4, 0x84, 0xa2, 0x7e, 0x73, 0x8a, 0xa6, 0x19, 0x0f, 0x8d, 0x19,
-	0xbf, 0xda, 0x36, 0xe3, 0xfd, 0x7a, 0xea, 0x3b, 0xdc, 0xf8, 0x97, 0x85, 0x8e, 0x77, 0x92, 0x1a,
-	0x7a, 0x5a, 0x5b, 0x7a, 0x3e, 0x45, 0x4e, 0xb4, 0x28, 0x33, 0x2e, 0x49, 0xab, 0xd6, 0x6b, 0xe7,
-	0xf9, 0xe9, 0x19, 0x90, 0x8c, 0x5e, 0xf5, 0x89, 0x93, 0x4b, 0xe4, 0x36, 0xe0, 0x0f, 0xd9, 0x26,
-	0xa0, 0xdf, 0x31, 0xff, 0xdf, 0x36, 0x3a, 0xda, 0xc5, 0xd1, 0x16, 0xc9, 0x03, 0xb5, 0xa8, 0x9b,
-	0x43, 0xac, 0x9f, 0x24, 0xae, 0xae, 0x24, 0x33, 0xff, 0x03, 0x36, 0xad, 0x33, 0xfc, 0x18, 0xe1,
-	0x48, 0x2c, 0xcb, 0x34, 0xf3, 0x73, 0x56, 0xa4, 0xa5, 0x0a, 0x54, 0x22, 0x32, 0xd2, 0xf7, 0xec,
-	0x49, 0x9b, 0x1e, 0x9a, 0xca, 0xe5, 0xa6, 0xa0, 0x1d, 0xc5, 0xb2, 0xd8, 0xaf, 0x5b, 0xb5, 0x8d,
-	0xa3, 0x58, 0x16, 0xff, 0x64, 0xba, 0x0d, 0x91, 0x9d, 0x0b, 0x09, 0xeb, 0x62, 0x53, 0x1d, 0xe2,
-	0x2f, 0xd1, 0x41, 0x5e, 0xb0, 0xca, 0x2f, 0xc4, 0xef, 0x49, 0xec, 0xa7, 0xc1, 0x2b, 0x58, 0x18,
-	0x9b, 0xf6, 0x35, 0x4a, 0x35, 0xf8, 0x22, 0x78, 0xa5, 0x97, 0x6d, 0x43, 0xe8, 0x02, 0xa1, 0x5b,
-	0x34, 0x8a, 0xbc, 0x8a, 0x6a, 0x3f, 0xf5, 0xc0, 0x36, 0x5d, 0x5e, 0x45, 0xc6, 0x50, 0x0f, 0x50,
-	0x47, 0x17, 0xb5, 0xa3, 0x8c, 0xd5, 0x1c, 0x5e, 0x45, 0xda, 0x52, 0x5f, 0xa0, 0xbe, 0x2e, 0xdc,
-	0x7a, 0xca, 0x05, 0x4f, 0xb9, 0xbc, 0x8a, 0xd6, 0xa6, 0xc2, 0x9f, 0xea, 0x15, 0x4f, 0x99, 0x54,
-	0x41, 0x9a, 0x93, 0x81, 0x67, 0x4d, 0x86, 0x74, 0x03, 0x68, 0x15, 0xd5, 0x2a, 0x67, 0xe4, 0x00,
-	0x76, 0x1f, 0x62, 0xec, 0x21, 0x37, 0x12, 0x69, 0x5e, 0x30, 0x29, 0xb5, 0x4c, 0xf7, 0xa0, 0xd4,
-	0x84, 0xb4, 0xf7, 0xf5, 0xae, 0xfb, 0xfa, 0xc7, 0x1d, 0x9a, 0xff, 0x24, 0x9d, 0x5f, 0xb0, 0x95,
-	0x7e, 0x07, 0x7c, 0x37, 0x64, 0xf2, 0x9a, 0x91, 0x43, 0xf3, 0x48, 0x0d, 0xfc, 0x9c, 0xbc, 0x66,
-	0xa7, 0x8f, 0xde, 0xfc, 0x3b, 0xda, 0x7b, 0x73, 0x3d, 0xb2, 0xde, 0x5e, 0x8f, 0xac, 0x77, 0xd7,
-	0x23, 0xeb, 0x8f, 0x9b, 0xd1, 0xde, 0xdb, 0x9b, 0xd1, 0xde, 0x3f, 0x37, 0xa3, 0xbd, 0xdf, 0x06,
-	0x5b, 0x9f, 0x9f, 0xd0, 0x81, 0xef, 0xc7, 0xd7, 0xff, 0x07, 0x00, 0x00, 0xff, 0xff, 0x8b, 0xff,
-	0xf2, 0x75, 0xb0, 0x06, 0x00, 0x00,
+	0x10, 0x36, 0x4d, 0xeb, 0x6f, 0x28, 0x39, 0xf2, 0xc6, 0x4e, 0x58, 0xb5, 0x55, 0x55, 0xa5, 0x07,
+	0x01, 0x69, 0x24, 0x20, 0xbd, 0x14, 0x41, 0x5b, 0xa0, 0xb6, 0x03, 0x34, 0x30, 0x82, 0x1a, 0x6c,
+	0xda, 0x43, 0x2f, 0x04, 0x45, 0xae, 0x25, 0x62, 0x45, 0x2e, 0xb1, 0xbb, 0xda, 0x46, 0x79, 0x8a,
+	0x9e, 0xfa, 0x0c, 0x7d, 0x89, 0xde, 0x83, 0x9e, 0x72, 0xec, 0xb1, 0xb5, 0xef, 0x7d, 0x86, 0x62,
+	0x67, 0x29, 0x89, 0x36, 0x84, 0xa0, 0xb7, 0x9d, 0x6f, 0xbe, 0x9d, 0xfd, 0x38, 0xfb, 0xcd, 0x12,
+	0xbe, 0x29, 0xd8, 0x6c, 0xb2, 0x48, 0x67, 0x73, 0x95, 0xa7, 0xf9, 0x6c, 0x12, 0xcf, 0x69, 0xcc,
+	0x0a, 0x9e, 0xe6, 0x4a, 0x56, 0xd7, 0xc5, 0x74, 0x72, 0x95, 0x2e, 0x68, 0x58, 0x81, 0xc6, 0x85,
+	0xe0, 0x8a, 0xf7, 0x9e, 0xcc, 0x52, 0x35, 0x5f, 0x4e, 0xc7, 0x31, 0xcf, 0x26, 0x33, 0x3e, 0xe3,
+	0x13, 0x84, 0xa7, 0xcb, 0x2b, 0x8c, 0x30, 0xc0, 0x95, 0xa5, 0x0f, 0xff, 0x75, 0xa0, 0x7b, 0xb6,
+	0x2d, 0xf2, 0x92, 0x27, 0x74, 0x41, 0xce, 0xc1, 0xab, 0x14, 0xf6, 0x9d, 0x81, 0x3b, 0xf2, 0x9e,
+	0x0e, 0xc7, 0x77, 0x79, 0x55, 0xe0, 0x79, 0xae, 0xc4, 0x2a, 0xa8, 0x6e, 0x23, 0x5f, 0xc3, 0x3d,
+	0x15, 0x49, 0x56, 0xd1, 0xe8, 0xef, 0x0f, 0x9c, 0x91, 0xf7, 0xf4, 0x78, 0xfc, 0x2a, 0x92, 0x6c,
+	0xbb, 0x19, 0x8b, 0x05, 0x87, 0xea, 0x16, 0xd8, 0xfb, 0x11, 0xba, 0x77, 0xeb, 0x93, 0x2e, 0xb8,
+	0x8c, 0xae, 0x7c, 0x67, 0xe0, 0x8c, 0x5a, 0x81, 0x59, 0x92, 0xc7, 0x50, 0xd3, 0xd1, 0x62, 0x49,
+	0xcb, 0xd2, 0x27, 0xe3, 0x57, 0xd1, 0x74, 0x41, 0xef, 0xd6, 0xb6, 0x9c, 0x67, 0xfb, 0x5f, 0x3a,
+	0xc3, 0xdf, 0xf7, 0xe1, 0xfe, 0x8e, 0xe3, 0xc9, 0x43, 0x68, 0xa0, 0xda, 0x34, 0xc1, 0xf2, 0x6e,
+	0x50, 0x37, 0xe1, 0x8b, 0x84, 0x7c, 0x0c, 0x20, 0xf9, 0x52, 0xc4, 0x34, 0x4c, 0x52, 0x81, 0xc7,
+	0xb4, 0x82, 0x96, 0x45, 0xce, 0x53, 0x41, 0x7c, 0x68, 0x4c, 0xa3, 0x98, 0xd1, 0x3c, 0xf1, 0x5d,
+	0xcc, 0xad, 0x43, 0xf2, 0x08, 0x3a, 0x69, 0x56, 0x70, 0xa1, 0xa8, 0x08, 0xa3, 0x24, 0x11, 0xfe,
+	0x01, 0xe6, 0xdb, 0x6b, 0xf0, 0xdb, 0x24, 0x11, 0xe4, 0x43, 0x68, 0xa9, 0x34, 0x99, 0x86, 0x73,
+	0x2e, 0x95, 0x5f, 0x43, 0x42, 0xd3, 0x00, 0xdf, 0x71, 0xa9, 0x36, 0x49, 0xc3, 0xf7, 0xeb, 0x03,
+	0x67, 0x54, 0xb3, 0xc9, 0x4b, 0x2e, 0x94, 0x11, 0x5c, 0x24, 0xb6, 0x70, 0x03, 0xf7, 0xd5, 0x8b,
+	0x04, 0x4b, 0x0e, 0xa1, 0x23, 0xcd, 0x01, 0x49, 0xc8, 0x34, 0x6a, 0x6e, 0x62, 0xda, 0xb3, 0xe0,
+	0x85, 0x36, 0xaa, 0x1f, 0x41, 0x67, 0xe3, 0xb1, 0x50, 0x53, 0xe1, 0xb7, 0xac, 0xb6, 0x0d, 0xf8,
+	0x13, 0x15, 0xc3, 0xdf, 0x0e, 0xe0, 0x78, 0x57, 0x3b, 0x09, 0x81, 0x83, 0x79, 0x24, 0xe7, 0xd8,
+	0xa8, 0x76, 0x80, 0x6b, 0xf2, 0x00, 0xea, 0x52, 0x45, 0x6a, 0x29, 0xb1, 0x0d, 0x9d, 0xa0, 0x8c,
+	0x4c, 0xfb, 0xa2, 0xc5, 0x82, 0xc7, 0xe1, 0x34, 0x92, 0x14, 0x5b, 0xe0, 0x06, 0x2d, 0x44, 0x4e,
+	0x23, 0x49, 0xc9, 0x57, 0xd0, 0xa0, 0xf9, 0x2c, 0xcd, 0xa9, 0xf4, 0x9b, 0xa5, 0xcd, 0x76, 0x1d,
+	0x39, 0x7e, 0x6e, 0x49, 0xd6, 0x66, 0xeb, 0x2d, 0xa6, 0xf9, 0xca, 0xb0, 0x5f, 0x9c, 0xe3, 0x07,
+	0xb8, 0xc1, 0x3a, 0x24, 0x1f, 0x40, 0x93, 0xe9, 0x70, 0xba, 0x52, 0x54, 0xfa, 0x30, 0x70, 0x46,
+	0x07, 0x41, 0x83, 0xe9, 0x53, 0x13, 0x92, 0x13, 0xa8, 0x33, 0x1d, 0x32, 0x2d, 0x7d, 0x0f, 0x13,
+	0x35, 0xa6, 0x2f, 0xb4, 0x24, 0x9f, 0x80, 0xc7, 0xb4, 0x35, 0xab, 0x5c, 0x66, 0x7e, 0x7b, 0xe0,
+	0x8c, 0xea, 0x01, 0x30, 0x7d, 0x56, 0x22, 0xe6, 0xb0, 0x98, 0xe7, 0x4a, 0xf0, 0x85, 0xdf, 0xc1,
+	0x4f, 0x5c, 0x87, 0xa4, 0x07, 0xcd, 0x42, 0xa4, 0x5c, 0xa4, 0x6a, 0xe5, 0x1f, 0xda, 0x6b, 0x5a,
+	0xc7, 0x64, 0x04, 0x5d, 0x41, 0x33, 0xae, 0xed, 0x75, 0x58, 0x41, 0xf7, 0xf0, 0xdc, 0xc3, 0x12,
+	0xbf, 0x28, 0x75, 0x7d, 0x06, 0x87, 0x15, 0xa6, 0xd1, 0xd7, 0x45, 0x5e, 0x7b, 0xc3, 0x33, 0x32,
+	0xc7, 0x70, 0xbf, 0xc2, 0xda, 0xc8, 0x3d, 0x42, 0xb9, 0x47, 0x1b, 0xea, 0x5a, 0x75, 0x2f, 0x80,
+	0x76, 0xb5, 0x77, 0xd5, 0x11, 0x3a, 0xb2, 0x23, 0xf4, 0xf9, 0xed, 0x11, 0x7a, 0x50, 0xf6, 0xfa,
+	0x3d, 0x33, 0xf4, 0x87, 0x03, 0x27, 0x3b, 0x49, 0x15, 0x17, 0x38, 0xb7, 0x5c, 0xf0, 0x0c, 0xea,
+	0xf1, 0x7c, 0x99, 0x33, 0xe9, 0xef, 0x97, 0xb7, 0xbc, 0x73, 0xff, 0xf8, 0x0c, 0x49, 0xf6, 0x96,
+	0xcb, 0x1d, 0xbd, 0x4b, 0xf0, 0x2a, 0xf0, 0xff, 0x79, 0x03, 0x90, 0xfe, 0x1e, 0xfd, 0x7f, 0xba,
+	0x70, 0xbc, 0x8b, 0x63, 0x8c, 0x5d, 0x44, 0x6a, 0x5e, 0x16, 0xc7, 0xb5, 0xf9, 0x24, 0x7e, 0x75,
+	0x25, 0xa9, 0x7d, 0xbd, 0xdc, 0xa0, 0x8c, 0xc8, 0x13, 0x20, 0x31, 0x5f, 0x2c, 0xb3, 0x3c, 0x2c,
+	0xa8, 0xc8, 0x96, 0x2a, 0x52, 0x29, 0xcf, 0xfd, 0xf6, 0xc0, 0x1d, 0xd5, 0x82, 0x23, 0x9b, 0xb9,
+	0xdc, 0x26, 0xcc, 0x1c, 0xd0, 0x3c, 0x09, 0xcb, 0x52, 0x35, 0x3b, 0x07, 0x34, 0x4f, 0xbe, 0xb7,
+	0xd5, 0xba, 0xe0, 0x16, 0x5c, 0xe2, 0x90, 0xbb, 0x81, 0x59, 0x1a, 0x3b, 0x14, 0x82, 0xea, 0x50,
+	0xf0, 0x5f, 0xd2, 0x24, 0xcc, 0xa2, 0xd7, 0x38, 0xe6, 0x6e, 0xd0, 0x36, 0x68, 0x60, 0xc0, 0x97,
+	0xd1, 0x6b, 0xf3, 0x44, 0x6c, 0x09, 0x4d, 0x24, 0x34, 0x45, 0x25, 0xc9, 0x74, 0x5c, 0x9a, 0xae,
+	0x85, 0x66, 0x6a, 0x32, 0x1d, 0x5b, 0xbb, 0x3d, 0x84, 0x86, 0x49, 0x32, 0xbd, 0x1e, 0x90, 0x3a,
+	0xd3, 0xb1, 0x71, 0xd8, 0xa7, 0xd0, 0x36, 0x89, 0x8d, 0xb5, 0x3c, 0xb4, 0x96, 0xc7, 0x74, 0xbc,
+	0x19, 0x85, 0x8f, 0xcc, 0xc3, 0x94, 0x51, 0xa9, 0xa2, 0xac, 0xc0, 0x61, 0xe8, 0x06, 0x5b, 0xc0,
+	0x74, 0x51, 0xad, 0x0a, 0x5a, 0x8e, 0x02, 0xae, 0xc9, 0x00, 0xbc, 0x98, 0x67, 0x85, 0xa0, 0x52,
+	0x9a, 0x36, 0xdd, 0xc3, 0x54, 0x15, 0x32, 0x13, 0x6b, 0x5e, 0xa8, 0xd0, 0x5c, 0x6e, 0xd7, 0xbe,
+	0xa4, 0x26, 0xbe, 0xa0, 0x2b, 0xf3, 0x1d, 0xf8, 0xb7, 0x93, 0xe9, 0x1b, 0x8a, 0x4e, 0x77, 0x83,
+	0xa6, 0x01, 0x7e, 0x48, 0xdf, 0xd0, 0xd3, 0xc7, 0x6f, 0xff, 0xe9, 0xef, 0xbd, 0xbd, 0xee, 0x3b,
+	0xef, 0xae, 0xfb, 0xce, 0xdf, 0xd7, 0x7d, 0xe7, 0xd7, 0x9b, 0xfe, 0xde, 0xbb, 0x9b, 0xfe, 0xde,
+	0x5f, 0x37, 0xfd, 0xbd, 0x9f, 0x3b, 0xb7, 0x7e, 0x9a, 0xd3, 0x3a, 0xfe, 0xf5, 0xbe, 0xf8, 0x6f,
+	0x00, 0x39, 0xb6, 0xd4, 0x25, 0x66, 0x07, 0x00, 0x00,
 }
 
 func (m *CheckpointsModel) Marshal() (dAtA []byte, err error) {
@@ -479,6 +488,36 @@ func (m *TableCheckpointModel) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.RemovedKvChecksum != 0 {
+		i -= 8
+		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(m.RemovedKvChecksum))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x89
+	}
+	if m.RemovedKvKvs != 0 {
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.RemovedKvKvs))
+		i--
+		dAtA[i] = 0x1
+		i--
+		dAtA[i] = 0x80
+	}
+	if m.RemovedKvBytes != 0 {
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.RemovedKvBytes))
+		i--
+		dAtA[i] = 0x78
+	}
+	if m.Priority != 0 {
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.Priority))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.Control != 0 {
+		i = encodeVarintFileCheckpoints(dAtA, i, uint64(m.Control))
+		i--
+		dAtA[i] = 0x68
+	}
 	if m.KvChecksum != 0 {
 		i -= 8
 		encoding_binary.LittleEndian.PutUint64(dAtA[i:], uint64(m.KvChecksum))
@@ -841,6 +880,21 @@ func (m *TableCheckpointModel) Size() (n int) {
 	if m.KvChecksum != 0 {
 		n += 9
 	}
+	if m.Control != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.Control))
+	}
+	if m.Priority != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.Priority))
+	}
+	if m.RemovedKvBytes != 0 {
+		n += 1 + sovFileCheckpoints(uint64(m.RemovedKvBytes))
+	}
+	if m.RemovedKvKvs != 0 {
+		n += 2 + sovFileCheckpoints(uint64(m.RemovedKvKvs))
+	}
+	if m.RemovedKvChecksum != 0 {
+		n += 10
+	}
 	return n
 }
 
@@ -1754,6 +1808,92 @@ func (m *TableCheckpointModel) Unmarshal(dAtA []byte) error {
 			}
 			m.KvChecksum = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
 			iNdEx += 8
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Control", wireType)
+			}
+			m.Control = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Control |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Priority", wireType)
+			}
+			m.Priority = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Priority |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 15:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemovedKvBytes", wireType)
+			}
+			m.RemovedKvBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RemovedKvBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 16:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemovedKvKvs", wireType)
+			}
+			m.RemovedKvKvs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFileCheckpoints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RemovedKvKvs |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 17:
+			if wireType != 1 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RemovedKvChecksum", wireType)
+			}
+			m.RemovedKvChecksum = 0
+			if (iNdEx + 8) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.RemovedKvChecksum = uint64(encoding_binary.LittleEndian.Uint64(dAtA[iNdEx:]))
+			iNdEx += 8
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFileCheckpoints(dAtA[iNdEx:])
@@ -0,0 +1,286 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoints
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints/checkpointspb"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+const (
+	readAllTablesTemplate = `
+		SELECT
+			table_name, hash, status, alloc_base, table_id, kv_bytes, kv_kvs, kv_checksum, control, priority,
+			removed_kv_bytes, removed_kv_kvs, removed_kv_checksum
+		FROM %s.%s;`
+	readAllEnginesTemplate = `
+		SELECT table_name, engine_id, status FROM %s.%s;`
+	readAllChunksTemplate = `
+		SELECT
+			table_name, engine_id, path, offset, type, compression, sort_key, file_size, columns,
+			pos, end_offset, prev_rowid_max, rowid_max,
+			kvc_bytes, kvc_kvs, kvc_checksum, unix_timestamp(create_time)
+		FROM %s.%s;`
+	replaceTableTemplate = `
+		REPLACE INTO %s.%s (
+				task_id, table_name, hash, status, alloc_base, table_id, kv_bytes, kv_kvs, kv_checksum, control, priority,
+				removed_kv_bytes, removed_kv_kvs, removed_kv_checksum
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	replaceChunkWithChecksumTemplate = `
+		REPLACE INTO %s.%s (
+				table_name, engine_id,
+				path, offset, type, compression, sort_key, file_size, columns, should_include_row_id,
+				pos, end_offset, prev_rowid_max, rowid_max,
+				kvc_bytes, kvc_kvs, kvc_checksum, create_time
+			) VALUES (
+				?, ?,
+				?, ?, ?, ?, ?, ?, ?, FALSE,
+				?, ?, ?, ?,
+				?, ?, ?, from_unixtime(?)
+			);`
+	deleteAllTemplate = "DELETE FROM %s.%s;"
+)
+
+// ConvertFileCheckpointsToMySQL replaces the checkpoints in the MySQL schema by
+// the checkpoints in the file.
+func ConvertFileCheckpointsToMySQL(ctx context.Context, from *FileCheckpointsDB, to *MySQLCheckpointsDB) error {
+	from.lock.Lock()
+	defer from.lock.Unlock()
+
+	return to.storeModel(ctx, &from.checkpoints)
+}
+
+// ConvertMySQLCheckpointsToFile replaces the checkpoints in the file by the
+// checkpoints in the MySQL schema.
+func ConvertMySQLCheckpointsToFile(ctx context.Context, from *MySQLCheckpointsDB, to *FileCheckpointsDB) error {
+	model, err := from.loadModel(ctx)
+	if err != nil {
+		return err
+	}
+
+	to.lock.Lock()
+	defer to.lock.Unlock()
+
+	to.checkpoints.TaskCheckpoint = model.TaskCheckpoint
+	to.checkpoints.Checkpoints = model.Checkpoints
+	return errors.Trace(to.save())
+}
+
+// loadModel reads all checkpoints in the layout of the file checkpoints.
+func (cpdb *MySQLCheckpointsDB) loadModel(ctx context.Context) (*checkpointspb.CheckpointsModel, error) {
+	var model *checkpointspb.CheckpointsModel
+
+	s := common.SQLWithRetry{DB: cpdb.db, Logger: log.L()}
+	err := s.Transact(ctx, "read all checkpoints", func(c context.Context, tx *sql.Tx) error {
+		model = &checkpointspb.CheckpointsModel{
+			TaskCheckpoint: &checkpointspb.TaskCheckpointModel{},
+			Checkpoints:    make(map[string]*checkpointspb.TableCheckpointModel),
+		}
+
+		// 1. Read the task.
+
+		task := model.TaskCheckpoint
+		taskQuery := fmt.Sprintf(ReadTaskTemplate, cpdb.schema, CheckpointTableNameTask)
+		err := tx.QueryRowContext(c, taskQuery).Scan(&task.TaskId, &task.SourceDir, &task.Backend,
+			&task.ImporterAddr, &task.TidbHost, &task.TidbPort, &task.PdAddr, &task.SortedKvDir, &task.LightningVer)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.Trace(err)
+		}
+
+		// 2. Read the tables.
+
+		tableRows, err := tx.QueryContext(c, fmt.Sprintf(readAllTablesTemplate, cpdb.schema, CheckpointTableNameTable))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer tableRows.Close()
+		for tableRows.Next() {
+			var tableName string
+			tableModel := &checkpointspb.TableCheckpointModel{
+				Engines: make(map[int32]*checkpointspb.EngineCheckpointModel),
+			}
+			if err := tableRows.Scan(&tableName, &tableModel.Hash, &tableModel.Status, &tableModel.AllocBase,
+				&tableModel.TableID, &tableModel.KvBytes, &tableModel.KvKvs, &tableModel.KvChecksum,
+				&tableModel.Control, &tableModel.Priority,
+				&tableModel.RemovedKvBytes, &tableModel.RemovedKvKvs, &tableModel.RemovedKvChecksum); err != nil {
+				return errors.Trace(err)
+			}
+			model.Checkpoints[tableName] = tableModel
+		}
+		if err := tableRows.Err(); err != nil {
+			return errors.Trace(err)
+		}
+
+		// 3. Read the engines.
+
+		engineRows, err := tx.QueryContext(c, fmt.Sprintf(readAllEnginesTemplate, cpdb.schema, CheckpointTableNameEngine))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer engineRows.Close()
+		for engineRows.Next() {
+			var (
+				tableName string
+				engineID  int32
+			)
+			engineModel := &checkpointspb.EngineCheckpointModel{
+				Chunks: make(map[string]*checkpointspb.ChunkCheckpointModel),
+			}
+			if err := engineRows.Scan(&tableName, &engineID, &engineModel.Status); err != nil {
+				return errors.Trace(err)
+			}
+			tableModel, ok := model.Checkpoints[tableName]
+			if !ok {
+				return errors.Errorf("engine checkpoint %s:%d has no table checkpoint", tableName, engineID)
+			}
+			tableModel.Engines[engineID] = engineModel
+		}
+		if err := engineRows.Err(); err != nil {
+			return errors.Trace(err)
+		}
+
+		// 4. Read the chunks.
+
+		chunkRows, err := tx.QueryContext(c, fmt.Sprintf(readAllChunksTemplate, cpdb.schema, CheckpointTableNameChunk))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer chunkRows.Close()
+		for chunkRows.Next() {
+			var (
+				tableName string
+				engineID  int32
+				colPerm   []byte
+			)
+			chunkModel := &checkpointspb.ChunkCheckpointModel{}
+			if err := chunkRows.Scan(
+				&tableName, &engineID, &chunkModel.Path, &chunkModel.Offset, &chunkModel.Type, &chunkModel.Compression,
+				&chunkModel.SortKey, &chunkModel.FileSize, &colPerm, &chunkModel.Pos, &chunkModel.EndOffset,
+				&chunkModel.PrevRowidMax, &chunkModel.RowidMax, &chunkModel.KvcBytes, &chunkModel.KvcKvs,
+				&chunkModel.KvcChecksum, &chunkModel.Timestamp,
+			); err != nil {
+				return errors.Trace(err)
+			}
+			if err := json.Unmarshal(colPerm, &chunkModel.ColumnPermutation); err != nil {
+				return errors.Trace(err)
+			}
+			var engineModel *checkpointspb.EngineCheckpointModel
+			if tableModel, ok := model.Checkpoints[tableName]; ok {
+				engineModel = tableModel.Engines[engineID]
+			}
+			if engineModel == nil {
+				return errors.Errorf("chunk checkpoint %s:%d has no engine %s:%d",
+					chunkModel.Path, chunkModel.Offset, tableName, engineID)
+			}
+			key := ChunkCheckpointKey{Path: chunkModel.Path, Offset: chunkModel.Offset}
+			engineModel.Chunks[key.String()] = chunkModel
+		}
+		return errors.Trace(chunkRows.Err())
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return model, nil
+}
+
+// storeModel replaces all checkpoints by the checkpoints in the layout of the
+// file checkpoints.
+func (cpdb *MySQLCheckpointsDB) storeModel(ctx context.Context, model *checkpointspb.CheckpointsModel) error {
+	s := common.SQLWithRetry{DB: cpdb.db, Logger: log.L()}
+	return s.Transact(ctx, "replace all checkpoints", func(c context.Context, tx *sql.Tx) error {
+		for _, tbl := range []string{
+			CheckpointTableNameChunk, CheckpointTableNameEngine,
+			CheckpointTableNameTable, CheckpointTableNameTask,
+		} {
+			if _, err := tx.ExecContext(c, fmt.Sprintf(deleteAllTemplate, cpdb.schema, tbl)); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		task := model.TaskCheckpoint
+		if task == nil {
+			task = &checkpointspb.TaskCheckpointModel{}
+		}
+		if task.TaskId != 0 {
+			_, err := tx.ExecContext(c, fmt.Sprintf(InitTaskTemplate, cpdb.schema, CheckpointTableNameTask),
+				task.TaskId, task.SourceDir, task.Backend, task.ImporterAddr, task.TidbHost, task.TidbPort,
+				task.PdAddr, task.SortedKvDir, task.LightningVer)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		tableStmt, err := tx.PrepareContext(c, fmt.Sprintf(replaceTableTemplate, cpdb.schema, CheckpointTableNameTable))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer tableStmt.Close()
+		engineStmt, err := tx.PrepareContext(c, fmt.Sprintf(ReplaceEngineTemplate, cpdb.schema, CheckpointTableNameEngine))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer engineStmt.Close()
+		chunkStmt, err := tx.PrepareContext(c, fmt.Sprintf(replaceChunkWithChecksumTemplate, cpdb.schema, CheckpointTableNameChunk))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer chunkStmt.Close()
+
+		for tableName, tableModel := range model.Checkpoints {
+			// the hash column is NOT NULL.
+			hash := tableModel.Hash
+			if hash == nil {
+				hash = []byte{}
+			}
+			_, err := tableStmt.ExecContext(c, task.TaskId, tableName, hash, tableModel.Status, tableModel.AllocBase,
+				tableModel.TableID, tableModel.KvBytes, tableModel.KvKvs, tableModel.KvChecksum,
+				tableModel.Control, tableModel.Priority,
+				tableModel.RemovedKvBytes, tableModel.RemovedKvKvs, tableModel.RemovedKvChecksum)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			for engineID, engineModel := range tableModel.Engines {
+				if _, err := engineStmt.ExecContext(c, tableName, engineID, engineModel.Status); err != nil {
+					return errors.Trace(err)
+				}
+				for _, chunkModel := range engineModel.Chunks {
+					columnPerm, err := json.Marshal(chunkModel.ColumnPermutation)
+					if err != nil {
+						return errors.Trace(err)
+					}
+					_, err = chunkStmt.ExecContext(
+						c, tableName, engineID,
+						chunkModel.Path, chunkModel.Offset, chunkModel.Type, chunkModel.Compression,
+						chunkModel.SortKey, chunkModel.FileSize, columnPerm,
+						chunkModel.Pos, chunkModel.EndOffset, chunkModel.PrevRowidMax, chunkModel.RowidMax,
+						chunkModel.KvcBytes, chunkModel.KvcKvs, chunkModel.KvcChecksum, chunkModel.Timestamp,
+					)
+					if err != nil {
+						return errors.Trace(err)
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checkpoints
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints/checkpointspb"
+)
+
+const (
+	// DumpFormatCSV dumps the checkpoints as CSV files.
+	DumpFormatCSV = "csv"
+	// DumpFormatJSON dumps the checkpoints as JSON arrays of objects.
+	DumpFormatJSON = "json"
+)
+
+// The CSV dumps of the file and pebble checkpoints share the layout of the
+// MySQL checkpoints. The create and update time are not recorded by them and
+// are left empty.
+var (
+	tableDumpColumns = []string{
+		"task_id", "table_name", "hash", "status", "alloc_base", "control", "priority",
+		"create_time", "update_time",
+	}
+	engineDumpColumns = []string{"table_name", "engine_id", "status", "create_time", "update_time"}
+	chunkDumpColumns  = []string{
+		"table_name", "path", "offset", "type", "compression", "sort_key", "file_size", "columns",
+		"pos", "end_offset", "prev_rowid_max", "rowid_max", "kvc_bytes", "kvc_kvs", "kvc_checksum",
+		"create_time", "update_time",
+	}
+)
+
+func tableDumpRecord(taskID int64, tableName string, tableModel *checkpointspb.TableCheckpointModel) []string {
+	return []string{
+		strconv.FormatInt(taskID, 10),
+		tableName,
+		fmt.Sprintf("%X", tableModel.Hash),
+		strconv.FormatUint(uint64(tableModel.Status), 10),
+		strconv.FormatInt(tableModel.AllocBase, 10),
+		strconv.FormatUint(uint64(tableModel.Control), 10),
+		strconv.FormatInt(int64(tableModel.Priority), 10),
+		"",
+		"",
+	}
+}
+
+func engineDumpRecord(tableName string, engineID int32, engineModel *checkpointspb.EngineCheckpointModel) []string {
+	return []string{
+		tableName,
+		strconv.FormatInt(int64(engineID), 10),
+		strconv.FormatUint(uint64(engineModel.Status), 10),
+		"",
+		"",
+	}
+}
+
+func chunkDumpRecord(tableName string, chunkModel *checkpointspb.ChunkCheckpointModel) ([]string, error) {
+	columnPerm, err := json.Marshal(chunkModel.ColumnPermutation)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return []string{
+		tableName,
+		chunkModel.Path,
+		strconv.FormatInt(chunkModel.Offset, 10),
+		strconv.FormatInt(int64(chunkModel.Type), 10),
+		strconv.FormatInt(int64(chunkModel.Compression), 10),
+		chunkModel.SortKey,
+		strconv.FormatInt(chunkModel.FileSize, 10),
+		string(columnPerm),
+		strconv.FormatInt(chunkModel.Pos, 10),
+		strconv.FormatInt(chunkModel.EndOffset, 10),
+		strconv.FormatInt(chunkModel.PrevRowidMax, 10),
+		strconv.FormatInt(chunkModel.RowidMax, 10),
+		strconv.FormatUint(chunkModel.KvcBytes, 10),
+		strconv.FormatUint(chunkModel.KvcKvs, 10),
+		strconv.FormatUint(chunkModel.KvcChecksum, 10),
+		"",
+		"",
+	}, nil
+}
+
+// dumpCSV writes the header and the records produced by `fn` into the writer.
+func dumpCSV(writer io.Writer, header []string, fn func(w *csv.Writer) error) error {
+	w := csv.NewWriter(writer)
+	if err := w.Write(header); err != nil {
+		return errors.Trace(err)
+	}
+	if err := fn(w); err != nil {
+		return err
+	}
+	w.Flush()
+	return errors.Trace(w.Error())
+}
+
+// DumpJSON converts the CSV written by `dump` (e.g. DB.DumpTables) into a JSON
+// array of objects, one object per line, keyed by the CSV header. The values
+// are kept as strings, so the 64-bit checksums are not rounded by JSON readers.
+func DumpJSON(writer io.Writer, dump func(csv io.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(dump(pw))
+	}()
+	err := csvToJSON(writer, pr)
+	// unblock the dump if the conversion failed.
+	pr.CloseWithError(err)
+	return err
+}
+
+func csvToJSON(writer io.Writer, reader io.Reader) error {
+	r := csv.NewReader(reader)
+	header, err := r.Read()
+	if err == io.EOF {
+		_, err = io.WriteString(writer, "[]\n")
+		return errors.Trace(err)
+	} else if err != nil {
+		return errors.Trace(err)
+	}
+
+	w := bufio.NewWriter(writer)
+	if _, err := w.WriteString("["); err != nil {
+		return errors.Trace(err)
+	}
+	for i := 0; ; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString("\n{")
+		for j, value := range record {
+			if j > 0 {
+				w.WriteByte(',')
+			}
+			// json.Marshal never fails on strings.
+			key, _ := json.Marshal(header[j])
+			val, _ := json.Marshal(value)
+			w.Write(key)
+			w.WriteByte(':')
+			w.Write(val)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteString("\n]\n")
+	return errors.Trace(w.Flush())
+}
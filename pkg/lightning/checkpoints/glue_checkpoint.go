@@ -336,6 +336,9 @@ func (g GlueCheckpointsDB) Get(ctx context.Context, tableName string) (*TableChe
 		cp.Status = CheckpointStatus(row.GetUint64(0))
 		cp.AllocBase = row.GetInt64(1)
 		cp.TableID = row.GetInt64(2)
+		cp.Control = TableControl(row.GetUint64(6))
+		cp.Priority = int32(row.GetInt64(7))
+		cp.RemovedChecksum = verify.MakeKVChecksum(row.GetUint64(8), row.GetUint64(9), row.GetUint64(10))
 		return nil
 	})
 
@@ -423,6 +426,8 @@ func (g GlueCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDif
 	chunkQuery := fmt.Sprintf(UpdateChunkTemplate, g.schema, CheckpointTableNameChunk)
 	rebaseQuery := fmt.Sprintf(UpdateTableRebaseTemplate, g.schema, CheckpointTableNameTable)
 	tableStatusQuery := fmt.Sprintf(UpdateTableStatusTemplate, g.schema, CheckpointTableNameTable)
+	tableControlQuery := fmt.Sprintf(UpdateTableControlTemplate, g.schema, CheckpointTableNameTable)
+	tableRemovedChecksumQuery := fmt.Sprintf(UpdateTableRemovedChecksumTemplate, g.schema, CheckpointTableNameTable)
 	engineStatusQuery := fmt.Sprintf(UpdateEngineTemplate, g.schema, CheckpointTableNameEngine)
 	err = Transact(context.Background(), "update checkpoints", se, logger, func(c context.Context, s Session) error {
 		chunkStmt, _, _, err := s.PrepareStmt(chunkQuery)
@@ -440,6 +445,16 @@ func (g GlueCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDif
 			return errors.Trace(err)
 		}
 		defer dropPreparedStmt(s, tableStatusStmt)
+		tableControlStmt, _, _, err := s.PrepareStmt(tableControlQuery)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer dropPreparedStmt(s, tableControlStmt)
+		tableRemovedChecksumStmt, _, _, err := s.PrepareStmt(tableRemovedChecksumQuery)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer dropPreparedStmt(s, tableRemovedChecksumStmt)
 		engineStatusStmt, _, _, err := s.PrepareStmt(engineStatusQuery)
 		if err != nil {
 			return errors.Trace(err)
@@ -465,6 +480,27 @@ func (g GlueCheckpointsDB) Update(checkpointDiffs map[string]*TableCheckpointDif
 					return errors.Trace(err)
 				}
 			}
+			if cpd.hasControl {
+				_, err := s.ExecutePreparedStmt(c, tableControlStmt, []types.Datum{
+					types.NewUintDatum(uint64(cpd.control)),
+					types.NewIntDatum(int64(cpd.priority)),
+					types.NewStringDatum(tableName),
+				})
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+			if cpd.hasRemovedChecksum {
+				_, err := s.ExecutePreparedStmt(c, tableRemovedChecksumStmt, []types.Datum{
+					types.NewUintDatum(cpd.removedChecksum.SumSize()),
+					types.NewUintDatum(cpd.removedChecksum.SumKVS()),
+					types.NewUintDatum(cpd.removedChecksum.Sum()),
+					types.NewStringDatum(tableName),
+				})
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
 			for engineID, engineDiff := range cpd.engines {
 				if engineDiff.hasStatus {
 					_, err := s.ExecutePreparedStmt(c, engineStatusStmt, []types.Datum{
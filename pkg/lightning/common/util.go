@@ -224,6 +224,25 @@ func IsRetryableError(err error) bool {
 	return true
 }
 
+// IsDuplicateEntryError checks if the error is caused by a row conflicting with
+// the existing rows on a unique key.
+func IsDuplicateEntryError(err error) bool {
+	mysqlErr, ok := errors.Cause(err).(*mysql.MySQLError)
+	return ok && mysqlErr.Number == tmysql.ErrDupEntry
+}
+
+// IsStatementTooLargeError checks if the error is caused by a statement
+// exceeding `max_allowed_packet` or the transaction size limit, which could
+// succeed after being split into smaller statements.
+func IsStatementTooLargeError(err error) bool {
+	err = errors.Cause(err)
+	if err == mysql.ErrPktTooLarge {
+		return true
+	}
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && (mysqlErr.Number == tmysql.ErrNetPacketTooLarge || mysqlErr.Number == tmysql.ErrTxnTooLarge)
+}
+
 func isSingleRetryableError(err error) bool {
 	err = errors.Cause(err)
 
@@ -127,6 +127,15 @@ func (s *utilSuite) TestIsRetryableError(c *C) {
 	c.Assert(common.IsRetryableError(multierr.Combine(context.Canceled, &net.DNSError{IsTimeout: true})), IsFalse)
 }
 
+func (s *utilSuite) TestIsStatementTooLargeError(c *C) {
+	c.Assert(common.IsStatementTooLargeError(mysql.ErrPktTooLarge), IsTrue)
+	c.Assert(common.IsStatementTooLargeError(errors.Trace(mysql.ErrPktTooLarge)), IsTrue)
+	c.Assert(common.IsStatementTooLargeError(&mysql.MySQLError{Number: tmysql.ErrNetPacketTooLarge}), IsTrue)
+	c.Assert(common.IsStatementTooLargeError(&mysql.MySQLError{Number: tmysql.ErrTxnTooLarge}), IsTrue)
+	c.Assert(common.IsStatementTooLargeError(&mysql.MySQLError{Number: tmysql.ErrDupEntry}), IsFalse)
+	c.Assert(common.IsStatementTooLargeError(context.Canceled), IsFalse)
+}
+
 func (s *utilSuite) TestToDSN(c *C) {
 	param := common.MySQLConnectParam{
 		Host:             "127.0.0.1",
@@ -26,6 +26,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/BurntSushi/toml"
 	"github.com/docker/go-units"
@@ -40,6 +41,7 @@ import (
 
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/storage"
 )
 
 const (
@@ -60,6 +62,9 @@ const (
 	CheckpointDriverMySQL = "mysql"
 	// CheckpointDriverFile is a constant for choosing the "File" checkpoint driver in the configuration.
 	CheckpointDriverFile = "file"
+	// CheckpointDriverPebble is a constant for choosing the "Pebble" checkpoint driver in the configuration.
+	// In this mode, the checkpoints are stored incrementally in a local pebble database.
+	CheckpointDriverPebble = "pebble"
 
 	// ReplaceOnDup indicates using REPLACE INTO to insert data
 	ReplaceOnDup = "replace"
@@ -68,6 +73,15 @@ const (
 	// ErrorOnDup indicates using INSERT INTO to insert data, which would violate PK or UNIQUE constraint
 	ErrorOnDup = "error"
 
+	// DupeResAlgNone indicates the duplicate rows detected by the local backend are only reported
+	DupeResAlgNone = "none"
+	// DupeResAlgKeepLast indicates keeping the row appearing last in the data source among the duplicate rows
+	DupeResAlgKeepLast = "keep-last"
+	// DupeResAlgKeepFirst indicates keeping the row appearing first in the data source among the duplicate rows
+	DupeResAlgKeepFirst = "keep-first"
+	// DupeResAlgRemove indicates removing all the duplicate rows
+	DupeResAlgRemove = "remove"
+
 	defaultDistSQLScanConcurrency     = 15
 	distSQLScanConcurrencyPerStore    = 4
 	defaultBuildStatsConcurrency      = 20
@@ -78,6 +92,8 @@ const (
 
 	// defaultMetaSchemaName is the default database name used to store lightning metadata
 	defaultMetaSchemaName = "lightning_metadata"
+	// defaultTaskInfoSchemaName is the default database name used to store the rejected rows
+	defaultTaskInfoSchemaName = "lightning_task_info"
 
 	// autoDiskQuotaLocalReservedSpeed is the estimated size increase per
 	// millisecond per write thread the local backend may gain on all engines.
@@ -166,6 +182,31 @@ type Lightning struct {
 	IOConcurrency     int    `toml:"io-concurrency" json:"io-concurrency"`
 	CheckRequirements bool   `toml:"check-requirements" json:"check-requirements"`
 	MetaSchemaName    string `toml:"meta-schema-name" json:"meta-schema-name"`
+
+	MaxError           MaxError `toml:"max-error" json:"max-error"`
+	TaskInfoSchemaName string   `toml:"task-info-schema-name" json:"task-info-schema-name"`
+}
+
+// MaxError configures the maximum number of rejected rows of each kind of
+// errors, the import fails once any of them is exceeded.
+type MaxError struct {
+	// Syntax is the number of rows which can't be parsed.
+	Syntax int64 `toml:"syntax" json:"syntax"`
+	// Charset is the number of rows containing invalid characters of the
+	// column charset.
+	Charset int64 `toml:"charset" json:"charset"`
+	// Type is the number of rows which can't be converted to the column types,
+	// e.g. a string into an integer column or a too-long string.
+	Type int64 `toml:"type" json:"type"`
+	// Conflict is the number of rows conflicting with the existing rows on
+	// unique keys, only the TiDB backend with `on-duplicate = "error"` reports
+	// such errors.
+	Conflict int64 `toml:"conflict" json:"conflict"`
+}
+
+// IsZero returns whether no error is tolerated.
+func (cfg MaxError) IsZero() bool {
+	return cfg == MaxError{}
 }
 
 type PostOpLevel int
@@ -251,6 +292,44 @@ type CSVConfig struct {
 	TrimLastSep     bool   `toml:"trim-last-separator" json:"trim-last-separator"`
 	NotNull         bool   `toml:"not-null" json:"not-null"`
 	BackslashEscape bool   `toml:"backslash-escape" json:"backslash-escape"`
+	// EscapedBy is the escape character used when BackslashEscape is true,
+	// defaults to '\'.
+	EscapedBy string `toml:"escaped-by" json:"escaped-by"`
+	// Comment is the prefix of the comment lines which are skipped.
+	Comment string `toml:"comment" json:"comment"`
+	// UnquotedEmptyNull makes an unquoted empty field NULL, while a quoted
+	// empty field is always an empty string.
+	UnquotedEmptyNull bool `toml:"unquoted-empty-null" json:"unquoted-empty-null"`
+	// Terminators are the accepted line terminators, replacing Terminator.
+	Terminators []string `toml:"terminators" json:"terminators"`
+	// SkipLines is the number of leading lines skipped in every file before
+	// the header.
+	SkipLines int `toml:"skip-lines" json:"skip-lines"`
+}
+
+// GetEscapeChar returns the escape character, or 0 if the escape sequences are
+// not recognized.
+func (csv *CSVConfig) GetEscapeChar() byte {
+	switch {
+	case !csv.BackslashEscape:
+		return 0
+	case len(csv.EscapedBy) > 0:
+		return csv.EscapedBy[0]
+	default:
+		return '\\'
+	}
+}
+
+// GetTerminators returns the accepted line terminators, or nil if both "\r"
+// and "\n" are accepted.
+func (csv *CSVConfig) GetTerminators() []string {
+	if len(csv.Terminators) > 0 {
+		return csv.Terminators
+	}
+	if len(csv.Terminator) > 0 {
+		return []string{csv.Terminator}
+	}
+	return nil
 }
 
 type MydumperRuntime struct {
@@ -259,16 +338,20 @@ type MydumperRuntime struct {
 	BatchImportRatio float64          `toml:"batch-import-ratio" json:"batch-import-ratio"`
 	SourceDir        string           `toml:"data-source-dir" json:"data-source-dir"`
 	CharacterSet     string           `toml:"character-set" json:"character-set"`
+	DataCharacterSet string           `toml:"data-character-set" json:"data-character-set"`
+	DataInvalidChar  string           `toml:"data-invalid-char" json:"data-invalid-char"`
 	CSV              CSVConfig        `toml:"csv" json:"csv"`
 	MaxRegionSize    ByteSize         `toml:"max-region-size" json:"max-region-size"`
 	Filter           []string         `toml:"filter" json:"filter"`
 	FileRouters      []*FileRouteRule `toml:"files" json:"files"`
 	// Deprecated: only used to keep the compatibility.
-	NoSchema         bool             `toml:"no-schema" json:"no-schema"`
-	CaseSensitive    bool             `toml:"case-sensitive" json:"case-sensitive"`
-	StrictFormat     bool             `toml:"strict-format" json:"strict-format"`
-	DefaultFileRules bool             `toml:"default-file-rules" json:"default-file-rules"`
-	IgnoreColumns    AllIgnoreColumns `toml:"ignore-data-columns" json:"ignore-data-columns"`
+	NoSchema         bool               `toml:"no-schema" json:"no-schema"`
+	CaseSensitive    bool               `toml:"case-sensitive" json:"case-sensitive"`
+	StrictFormat     bool               `toml:"strict-format" json:"strict-format"`
+	DefaultFileRules bool               `toml:"default-file-rules" json:"default-file-rules"`
+	IgnoreColumns    AllIgnoreColumns   `toml:"ignore-data-columns" json:"ignore-data-columns"`
+	Transform        AllTransformRules  `toml:"transform" json:"transform"`
+	FixedWidth       AllFixedWidthRules `toml:"fixed-width" json:"fixed-width"`
 }
 
 type AllIgnoreColumns []*IgnoreColumns
@@ -301,6 +384,103 @@ func (igCols AllIgnoreColumns) GetIgnoreColumns(db string, table string, caseSen
 	return &IgnoreColumns{Columns: make([]string, 0)}, nil
 }
 
+type AllTransformRules []*TransformRule
+
+// TransformRule computes the target columns of the matched tables with
+// expressions, and only imports the rows satisfying the `Where` condition. The
+// source columns are referred as `@name` in the expressions.
+type TransformRule struct {
+	DB          string            `toml:"db" json:"db"`
+	Table       string            `toml:"table" json:"table"`
+	TableFilter []string          `toml:"table-filter" json:"table-filter"`
+	Columns     map[string]string `toml:"columns" json:"columns"`
+	Where       string            `toml:"where" json:"where"`
+}
+
+// GetTransformRule gets the transform rule by schema name/regex and table name/regex.
+// It returns nil if no rule matches.
+func (rules AllTransformRules) GetTransformRule(db string, table string, caseSensitive bool) (*TransformRule, error) {
+	if !caseSensitive {
+		db = strings.ToLower(db)
+		table = strings.ToLower(table)
+	}
+	for _, rule := range rules {
+		if rule.DB == db && rule.Table == table {
+			return rule, nil
+		}
+		f, err := filter.Parse(rule.TableFilter)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if f.MatchTable(db, table) {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+type AllFixedWidthRules []*FixedWidthRule
+
+// FixedWidthRule describes the layout of the records in the fixed-width data
+// files of the matched tables, either by the columns or by a COBOL copybook.
+type FixedWidthRule struct {
+	DB          string              `toml:"db" json:"db"`
+	Table       string              `toml:"table" json:"table"`
+	TableFilter []string            `toml:"table-filter" json:"table-filter"`
+	Columns     []*FixedWidthColumn `toml:"columns" json:"columns"`
+	Copybook    string              `toml:"copybook" json:"copybook"`
+	// Trim trims the leading and trailing spaces of the fields.
+	Trim bool `toml:"trim" json:"trim"`
+	// Null is the value of the (trimmed) fields representing NULL, no field is
+	// NULL if it is not set.
+	Null *string `toml:"null" json:"null"`
+}
+
+// FixedWidthColumn is a field of the fixed-width records.
+type FixedWidthColumn struct {
+	Name string `toml:"name" json:"name"`
+	// Start is the byte offset of the field in the record, starting from 0.
+	Start int `toml:"start" json:"start"`
+	Width int `toml:"width" json:"width"`
+	// Scale is the number of the implied decimal digits of a numeric field.
+	Scale int `toml:"scale" json:"scale"`
+	// Sign is how the sign of a numeric field is encoded other than a leading
+	// '+' or '-', one of FixedWidthSignLeading, FixedWidthSignTrailing and
+	// FixedWidthSignTrailingSeparate.
+	Sign string `toml:"sign" json:"sign"`
+}
+
+const (
+	// FixedWidthSignLeading is the sign overpunched on the leading digit.
+	FixedWidthSignLeading = "leading"
+	// FixedWidthSignTrailing is the sign overpunched on the trailing digit.
+	FixedWidthSignTrailing = "trailing"
+	// FixedWidthSignTrailingSeparate is a trailing '+' or '-'.
+	FixedWidthSignTrailingSeparate = "trailing-separate"
+)
+
+// GetFixedWidthRule gets the fixed-width rule by schema name/regex and table
+// name/regex. It returns nil if no rule matches.
+func (rules AllFixedWidthRules) GetFixedWidthRule(db string, table string, caseSensitive bool) (*FixedWidthRule, error) {
+	if !caseSensitive {
+		db = strings.ToLower(db)
+		table = strings.ToLower(table)
+	}
+	for _, rule := range rules {
+		if rule.DB == db && rule.Table == table {
+			return rule, nil
+		}
+		f, err := filter.Parse(rule.TableFilter)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if f.MatchTable(db, table) {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
 type FileRouteRule struct {
 	Pattern     string `json:"pattern" toml:"pattern" yaml:"pattern"`
 	Path        string `json:"path" toml:"path" yaml:"path"`
@@ -312,16 +492,21 @@ type FileRouteRule struct {
 }
 
 type TikvImporter struct {
-	Addr               string   `toml:"addr" json:"addr"`
-	Backend            string   `toml:"backend" json:"backend"`
-	OnDuplicate        string   `toml:"on-duplicate" json:"on-duplicate"`
-	MaxKVPairs         int      `toml:"max-kv-pairs" json:"max-kv-pairs"`
-	SendKVPairs        int      `toml:"send-kv-pairs" json:"send-kv-pairs"`
-	RegionSplitSize    ByteSize `toml:"region-split-size" json:"region-split-size"`
-	SortedKVDir        string   `toml:"sorted-kv-dir" json:"sorted-kv-dir"`
-	DiskQuota          ByteSize `toml:"disk-quota" json:"disk-quota"`
-	RangeConcurrency   int      `toml:"range-concurrency" json:"range-concurrency"`
-	DuplicateDetection bool     `toml:"duplicate-detection" json:"duplicate-detection"`
+	Addr                string   `toml:"addr" json:"addr"`
+	Backend             string   `toml:"backend" json:"backend"`
+	OnDuplicate         string   `toml:"on-duplicate" json:"on-duplicate"`
+	RowsPerStatement    int      `toml:"rows-per-statement" json:"rows-per-statement"`
+	PreparedStatement   bool     `toml:"prepared-statement" json:"prepared-statement"`
+	MaxKVPairs          int      `toml:"max-kv-pairs" json:"max-kv-pairs"`
+	SendKVPairs         int      `toml:"send-kv-pairs" json:"send-kv-pairs"`
+	RegionSplitSize     ByteSize `toml:"region-split-size" json:"region-split-size"`
+	SortedKVDir         string   `toml:"sorted-kv-dir" json:"sorted-kv-dir"`
+	SortedKVSpillURL    string   `toml:"sorted-kv-spill-url" json:"sorted-kv-spill-url"`
+	DiskQuota           ByteSize `toml:"disk-quota" json:"disk-quota"`
+	RangeConcurrency    int      `toml:"range-concurrency" json:"range-concurrency"`
+	DuplicateDetection  bool     `toml:"duplicate-detection" json:"duplicate-detection"`
+	DuplicateResolution string   `toml:"duplicate-resolution" json:"duplicate-resolution"`
+	Upsert              bool     `toml:"upsert" json:"upsert"`
 
 	EngineMemCacheSize      ByteSize `toml:"engine-mem-cache-size" json:"engine-mem-cache-size"`
 	LocalWriterMemCacheSize ByteSize `toml:"local-writer-mem-cache-size" json:"local-writer-mem-cache-size"`
@@ -432,12 +617,13 @@ func NewConfig() *Config {
 			Filter:        DefaultFilter,
 		},
 		TikvImporter: TikvImporter{
-			Backend:         "",
-			OnDuplicate:     ReplaceOnDup,
-			MaxKVPairs:      4096,
-			SendKVPairs:     32768,
-			RegionSplitSize: SplitRegionSize,
-			DiskQuota:       ByteSize(math.MaxInt64),
+			Backend:             "",
+			OnDuplicate:         ReplaceOnDup,
+			DuplicateResolution: DupeResAlgNone,
+			MaxKVPairs:          4096,
+			SendKVPairs:         32768,
+			RegionSplitSize:     SplitRegionSize,
+			DiskQuota:           ByteSize(math.MaxInt64),
 		},
 		PostRestore: PostRestore{
 			Checksum:          OpLevelRequired,
@@ -474,6 +660,34 @@ func (cfg *Config) LoadFromGlobal(global *GlobalConfig) error {
 	return nil
 }
 
+// DivideResources gives the task its share of the resources when `n` tasks run
+// concurrently in the server mode. It should be called after LoadFromGlobal, so
+// the resources set in the task configuration are not divided.
+func (cfg *Config) DivideResources(n int) {
+	if n <= 1 {
+		return
+	}
+	divide := func(v int) int {
+		if v < n {
+			return 1
+		}
+		return v / n
+	}
+	cfg.App.RegionConcurrency = divide(cfg.App.RegionConcurrency)
+	cfg.App.IOConcurrency = divide(cfg.App.IOConcurrency)
+	if cfg.TikvImporter.DiskQuota != ByteSize(math.MaxInt64) {
+		cfg.TikvImporter.DiskQuota /= ByteSize(n)
+	}
+	if cfg.TikvImporter.EngineMemCacheSize == 0 {
+		cfg.TikvImporter.EngineMemCacheSize = defaultEngineMemCacheSize
+	}
+	cfg.TikvImporter.EngineMemCacheSize /= ByteSize(n)
+	if cfg.TikvImporter.LocalWriterMemCacheSize == 0 {
+		cfg.TikvImporter.LocalWriterMemCacheSize = defaultLocalWriterMemCacheSize
+	}
+	cfg.TikvImporter.LocalWriterMemCacheSize /= ByteSize(n)
+}
+
 // LoadFromTOML overwrites the current configuration by the TOML data
 // If data contains toml items not in Config and GlobalConfig, return an error
 // If data contains toml items not in Config, thus won't take effect, warn user
@@ -546,15 +760,79 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		return errors.New("invalid config: `mydumper.csv.separator` and `mydumper.csv.delimiter` must not be prefix of each other")
 	}
 
-	if csv.BackslashEscape {
-		if csv.Separator == `\` {
-			return errors.New("invalid config: cannot use '\\' as CSV separator when `mydumper.csv.backslash-escape` is true")
+	if len(csv.Terminator) > 0 && len(csv.Terminators) > 0 {
+		return errors.New("invalid config: `mydumper.csv.terminator` and `mydumper.csv.terminators` must not be both set")
+	}
+	for _, terminator := range csv.Terminators {
+		if len(terminator) == 0 {
+			return errors.New("invalid config: `mydumper.csv.terminators` must not contain empty terminator")
+		}
+	}
+
+	if len(csv.EscapedBy) > 0 {
+		if len(csv.EscapedBy) != 1 || csv.EscapedBy[0] >= utf8.RuneSelf {
+			return errors.Errorf("invalid config: `mydumper.csv.escaped-by` (%s) must be a single ASCII character", csv.EscapedBy)
+		}
+		if !csv.BackslashEscape {
+			return errors.New("invalid config: `mydumper.csv.escaped-by` requires `mydumper.csv.backslash-escape` to be true")
+		}
+	}
+	if esc := string(csv.GetEscapeChar()); csv.BackslashEscape {
+		if csv.Separator == esc {
+			return errors.Errorf("invalid config: cannot use '%s' as CSV separator when `mydumper.csv.backslash-escape` is true", esc)
+		}
+		if csv.Delimiter == esc {
+			return errors.Errorf("invalid config: cannot use '%s' as CSV delimiter when `mydumper.csv.backslash-escape` is true", esc)
+		}
+		for _, terminator := range csv.GetTerminators() {
+			if terminator == esc {
+				return errors.Errorf("invalid config: cannot use '%s' as CSV terminator when `mydumper.csv.backslash-escape` is true", esc)
+			}
 		}
-		if csv.Delimiter == `\` {
-			return errors.New("invalid config: cannot use '\\' as CSV delimiter when `mydumper.csv.backslash-escape` is true")
+	}
+
+	if len(csv.Comment) > 0 {
+		if strings.HasPrefix(csv.Comment, csv.Separator) || strings.HasPrefix(csv.Separator, csv.Comment) {
+			return errors.New("invalid config: `mydumper.csv.separator` and `mydumper.csv.comment` must not be prefix of each other")
+		}
+		if len(csv.Delimiter) > 0 && (strings.HasPrefix(csv.Comment, csv.Delimiter) || strings.HasPrefix(csv.Delimiter, csv.Comment)) {
+			return errors.New("invalid config: `mydumper.csv.delimiter` and `mydumper.csv.comment` must not be prefix of each other")
+		}
+	}
+	if csv.UnquotedEmptyNull && csv.NotNull {
+		return errors.New("invalid config: `mydumper.csv.unquoted-empty-null` and `mydumper.csv.not-null` must not be both true")
+	}
+	if csv.SkipLines < 0 {
+		return errors.Errorf("invalid config: `mydumper.csv.skip-lines` (%d) must not be negative", csv.SkipLines)
+	}
+
+	cfg.Mydumper.DataCharacterSet = strings.ToLower(cfg.Mydumper.DataCharacterSet)
+	switch cfg.Mydumper.DataCharacterSet {
+	case "", "binary", "utf8mb4", "gbk", "gb18030", "latin1":
+	default:
+		return errors.Errorf("invalid config: unsupported `mydumper.data-character-set` (%s)", cfg.Mydumper.DataCharacterSet)
+	}
+	cfg.Mydumper.DataInvalidChar = strings.ToLower(cfg.Mydumper.DataInvalidChar)
+	switch cfg.Mydumper.DataInvalidChar {
+	case "", "error", "replace":
+	default:
+		return errors.Errorf("invalid config: unsupported `mydumper.data-invalid-char` (%s)", cfg.Mydumper.DataInvalidChar)
+	}
+
+	for _, rule := range cfg.Mydumper.FixedWidth {
+		if (len(rule.Columns) == 0) == (len(rule.Copybook) == 0) {
+			return errors.New("invalid config: exactly one of `columns` and `copybook` must be set in `mydumper.fixed-width`")
 		}
-		if csv.Terminator == `\` {
-			return errors.New("invalid config: cannot use '\\' as CSV terminator when `mydumper.csv.backslash-escape` is true")
+		for _, col := range rule.Columns {
+			if len(col.Name) == 0 || col.Start < 0 || col.Width <= 0 || col.Scale < 0 {
+				return errors.Errorf("invalid config: invalid column %+v in `mydumper.fixed-width`", *col)
+			}
+			switch col.Sign {
+			case "", FixedWidthSignLeading, FixedWidthSignTrailing, FixedWidthSignTrailingSeparate:
+			default:
+				return errors.Errorf("invalid config: unsupported sign '%s' of column %s in `mydumper.fixed-width`, "+
+					"should be one of 'leading|trailing|trailing-separate'", col.Sign, col.Name)
+			}
 		}
 	}
 
@@ -590,6 +868,8 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		cfg.PostRestore.Checksum = OpLevelOff
 		cfg.PostRestore.Analyze = OpLevelOff
 		cfg.TikvImporter.DuplicateDetection = false
+		cfg.TikvImporter.DuplicateResolution = DupeResAlgNone
+		cfg.TikvImporter.Upsert = false
 	case BackendImporter, BackendLocal:
 		// RegionConcurrency > NumCPU is meaningless.
 		cpuCount := runtime.NumCPU()
@@ -615,6 +895,23 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		}
 	} else if cfg.TikvImporter.DuplicateDetection {
 		return errors.Errorf("invalid config: unsupported backend (%s) for duplicate-detection", cfg.TikvImporter.Backend)
+	} else if cfg.TikvImporter.Upsert {
+		return errors.Errorf("invalid config: unsupported backend (%s) for upsert", cfg.TikvImporter.Backend)
+	} else if cfg.TikvImporter.SortedKVSpillURL != "" {
+		return errors.Errorf("invalid config: unsupported backend (%s) for sorted-kv-spill-url", cfg.TikvImporter.Backend)
+	}
+
+	cfg.TikvImporter.DuplicateResolution = strings.ToLower(cfg.TikvImporter.DuplicateResolution)
+	switch cfg.TikvImporter.DuplicateResolution {
+	case "":
+		cfg.TikvImporter.DuplicateResolution = DupeResAlgNone
+	case DupeResAlgNone:
+	case DupeResAlgKeepLast, DupeResAlgKeepFirst, DupeResAlgRemove:
+		if !cfg.TikvImporter.DuplicateDetection {
+			return errors.Errorf("invalid config: `tikv-importer.duplicate-resolution` (%s) requires `tikv-importer.duplicate-detection` to be true", cfg.TikvImporter.DuplicateResolution)
+		}
+	default:
+		return errors.Errorf("invalid config: unsupported `tikv-importer.duplicate-resolution` (%s)", cfg.TikvImporter.DuplicateResolution)
 	}
 
 	if cfg.TikvImporter.Backend == BackendTiDB {
@@ -624,6 +921,17 @@ func (cfg *Config) Adjust(ctx context.Context) error {
 		default:
 			return errors.Errorf("invalid config: unsupported `tikv-importer.on-duplicate` (%s)", cfg.TikvImporter.OnDuplicate)
 		}
+		if cfg.TikvImporter.RowsPerStatement < 0 {
+			return errors.New("invalid config: `tikv-importer.rows-per-statement` must not be negative")
+		}
+	}
+
+	maxError := cfg.App.MaxError
+	if maxError.Syntax < 0 || maxError.Charset < 0 || maxError.Type < 0 || maxError.Conflict < 0 {
+		return errors.New("invalid config: `lightning.max-error` must not be negative")
+	}
+	if len(cfg.App.TaskInfoSchemaName) == 0 {
+		cfg.App.TaskInfoSchemaName = defaultTaskInfoSchemaName
 	}
 
 	var err error
@@ -682,6 +990,12 @@ func (cfg *Config) CheckAndAdjustForLocalBackend() error {
 		return errors.Annotate(err, "invalid tikv-importer.sorted-kv-dir")
 	}
 
+	if len(cfg.TikvImporter.SortedKVSpillURL) > 0 {
+		if _, err := storage.ParseBackend(cfg.TikvImporter.SortedKVSpillURL, nil); err != nil {
+			return errors.Annotate(err, "invalid tikv-importer.sorted-kv-spill-url")
+		}
+	}
+
 	return nil
 }
 
@@ -855,6 +1169,8 @@ func (cfg *Config) AdjustCheckPoint() {
 			cfg.Checkpoint.DSN = param.ToDSN()
 		case CheckpointDriverFile:
 			cfg.Checkpoint.DSN = "/tmp/" + cfg.Checkpoint.Schema + ".pb"
+		case CheckpointDriverPebble:
+			cfg.Checkpoint.DSN = "/tmp/" + cfg.Checkpoint.Schema + ".pebble"
 		}
 	}
 }
@@ -869,6 +1185,12 @@ func (cfg *Config) AdjustMydumper() {
 	if len(cfg.Mydumper.CharacterSet) == 0 {
 		cfg.Mydumper.CharacterSet = "auto"
 	}
+	if len(cfg.Mydumper.DataCharacterSet) == 0 {
+		cfg.Mydumper.DataCharacterSet = "binary"
+	}
+	if len(cfg.Mydumper.DataInvalidChar) == 0 {
+		cfg.Mydumper.DataInvalidChar = "error"
+	}
 
 	if len(cfg.Mydumper.IgnoreColumns) != 0 {
 		// Tolower columns cause we use Name.L to compare column in tidb.
@@ -880,6 +1202,14 @@ func (cfg *Config) AdjustMydumper() {
 			ig.Columns = cols
 		}
 	}
+
+	for _, rule := range cfg.Mydumper.Transform {
+		columns := make(map[string]string, len(rule.Columns))
+		for col, expr := range rule.Columns {
+			columns[strings.ToLower(col)] = expr
+		}
+		rule.Columns = columns
+	}
 }
 
 func (cfg *Config) CheckAndAdjustSecurity() error {
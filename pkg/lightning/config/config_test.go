@@ -18,10 +18,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -29,6 +31,7 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/docker/go-units"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/parser/mysql"
 
@@ -422,6 +425,93 @@ func (s *configTestSuite) TestInvalidCSV(c *C) {
 			`,
 			err: "invalid config: cannot use '\\' as CSV delimiter when `mydumper.csv.backslash-escape` is true",
 		},
+		{
+			input: `
+				[mydumper.csv]
+				escaped-by = '^'
+				null = '^N'
+				comment = '#'
+				unquoted-empty-null = true
+				terminators = ["\r\n", "\n"]
+				skip-lines = 2
+			`,
+			err: "",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				separator = '^'
+				escaped-by = '^'
+			`,
+			err: "invalid config: cannot use '^' as CSV separator when `mydumper.csv.backslash-escape` is true",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				terminators = ["\n", "^"]
+				escaped-by = '^'
+			`,
+			err: "invalid config: cannot use '^' as CSV terminator when `mydumper.csv.backslash-escape` is true",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				escaped-by = '^^'
+			`,
+			err: "invalid config: `mydumper.csv.escaped-by` (^^) must be a single ASCII character",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				escaped-by = '^'
+				backslash-escape = false
+			`,
+			err: "invalid config: `mydumper.csv.escaped-by` requires `mydumper.csv.backslash-escape` to be true",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				terminator = "\n"
+				terminators = ["\r\n"]
+			`,
+			err: "invalid config: `mydumper.csv.terminator` and `mydumper.csv.terminators` must not be both set",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				terminators = [""]
+			`,
+			err: "invalid config: `mydumper.csv.terminators` must not contain empty terminator",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				comment = ',--'
+			`,
+			err: "invalid config: `mydumper.csv.separator` and `mydumper.csv.comment` must not be prefix of each other",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				comment = '"'
+			`,
+			err: "invalid config: `mydumper.csv.delimiter` and `mydumper.csv.comment` must not be prefix of each other",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				not-null = true
+				unquoted-empty-null = true
+			`,
+			err: "invalid config: `mydumper.csv.unquoted-empty-null` and `mydumper.csv.not-null` must not be both true",
+		},
+		{
+			input: `
+				[mydumper.csv]
+				skip-lines = -1
+			`,
+			err: "invalid config: `mydumper.csv.skip-lines` (-1) must not be negative",
+		},
 		{
 			input: `
 				[tidb]
@@ -445,6 +535,65 @@ func (s *configTestSuite) TestInvalidCSV(c *C) {
 			`,
 			err: "target schema of table route rule should not be empty",
 		},
+		{
+			input: `
+				[mydumper]
+				data-character-set = "GB18030"
+				data-invalid-char = "Replace"
+			`,
+		},
+		{
+			input: `
+				[mydumper]
+				data-character-set = "ebcdic"
+			`,
+			err: "invalid config: unsupported `mydumper.data-character-set` (ebcdic)",
+		},
+		{
+			input: `
+				[mydumper]
+				data-invalid-char = "ignore"
+			`,
+			err: "invalid config: unsupported `mydumper.data-invalid-char` (ignore)",
+		},
+		{
+			input: `
+				[[mydumper.fixed-width]]
+				table-filter = ["bank.*"]
+				columns = [{name = "id", start = 0, width = 5}, {name = "amount", start = 5, width = 9, scale = 2}]
+				trim = true
+				null = ""
+			`,
+		},
+		{
+			input: `
+				[[mydumper.fixed-width]]
+				db = "bank"
+				table = "accounts"
+				columns = [{name = "id", start = 0, width = 5}]
+				copybook = "01 REC. 05 ID PIC 9(5)."
+			`,
+			err: "invalid config: exactly one of `columns` and `copybook` must be set in `mydumper.fixed-width`",
+		},
+		{
+			input: `
+				[[mydumper.fixed-width]]
+				db = "bank"
+				table = "accounts"
+				columns = [{name = "id", start = 0, width = 0}]
+			`,
+			err: "invalid config: invalid column {Name:id Start:0 Width:0 Scale:0 Sign:} in `mydumper.fixed-width`",
+		},
+		{
+			input: `
+				[[mydumper.fixed-width]]
+				db = "bank"
+				table = "accounts"
+				columns = [{name = "balance", start = 0, width = 8, sign = "separate"}]
+			`,
+			err: "invalid config: unsupported sign 'separate' of column balance in `mydumper.fixed-width`, " +
+				"should be one of 'leading|trailing|trailing-separate'",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -524,6 +673,24 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 	c.Assert(err, ErrorMatches, "If server-mode is enabled, the status-addr must be a valid listen address")
 	c.Assert(cfg, IsNil)
 
+	configFile := filepath.Join(c.MkDir(), "config.toml")
+	err = os.WriteFile(configFile, []byte("[lightning]\nmax-concurrent-tasks = 0\n"), 0o644)
+	c.Assert(err, IsNil)
+	cfg, err = config.LoadGlobalConfig([]string{"-config", configFile}, nil)
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.max-concurrent-tasks` must be positive")
+	c.Assert(cfg, IsNil)
+
+	err = os.WriteFile(configFile, []byte("[lightning.status-auth]\nadmin-cert-cn = [\"admin\"]\n"), 0o644)
+	c.Assert(err, IsNil)
+	cfg, err = config.LoadGlobalConfig([]string{"-config", configFile}, nil)
+	c.Assert(err, ErrorMatches, "invalid config: `lightning.status-auth`: the client certificates can only be authorized with TLS enabled")
+	c.Assert(cfg, IsNil)
+	err = os.WriteFile(configFile, []byte("[lightning.status-auth]\nadmin-tokens = [\"secret\"]\n"), 0o644)
+	c.Assert(err, IsNil)
+	cfg, err = config.LoadGlobalConfig([]string{"-config", configFile}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.App.StatusAuth.AdminTokens, DeepEquals, []string{"secret"})
+
 	path, _ := filepath.Abs(".")
 	cfg, err = config.LoadGlobalConfig([]string{
 		"-L", "debug",
@@ -569,6 +736,39 @@ func (s *configTestSuite) TestLoadConfig(c *C) {
 	c.Assert(result, Matches, `.*"pd-addr":"172.16.30.11:2379,172.16.30.12:2379".*`)
 }
 
+func (s *configTestSuite) TestDivideResources(c *C) {
+	cfg := config.NewGlobalConfig()
+	cfg.ConfigFileContent = []byte(`
+		[lightning]
+		region-concurrency = 16
+		io-concurrency = 5
+		[tikv-importer]
+		disk-quota = "300GiB"
+	`)
+
+	taskCfg := config.NewConfig()
+	err := taskCfg.LoadFromGlobal(cfg)
+	c.Assert(err, IsNil)
+	taskCfg.DivideResources(3)
+	err = taskCfg.LoadFromTOML([]byte(`
+		[lightning]
+		io-concurrency = 4
+	`))
+	c.Assert(err, IsNil)
+	c.Assert(taskCfg.App.RegionConcurrency, Equals, 5)
+	c.Assert(taskCfg.App.IOConcurrency, Equals, 4)
+	c.Assert(taskCfg.TikvImporter.DiskQuota, Equals, config.ByteSize(100*units.GiB))
+	c.Assert(taskCfg.TikvImporter.EngineMemCacheSize, Equals, config.ByteSize(512*units.MiB/3))
+	c.Assert(taskCfg.TikvImporter.LocalWriterMemCacheSize, Equals, config.ByteSize(128*units.MiB/3))
+
+	// the resources are not divided if the tasks run one by one.
+	taskCfg = config.NewConfig()
+	taskCfg.DivideResources(1)
+	c.Assert(taskCfg.App.IOConcurrency, Equals, 5)
+	c.Assert(taskCfg.TikvImporter.DiskQuota, Equals, config.ByteSize(math.MaxInt64))
+	c.Assert(taskCfg.TikvImporter.EngineMemCacheSize, Equals, config.ByteSize(0))
+}
+
 func (s *configTestSuite) TestDefaultImporterBackendValue(c *C) {
 	cfg := config.NewConfig()
 	assignMinimalLegalValue(cfg)
@@ -710,3 +910,158 @@ func (s *configTestSuite) TestAdjustDiskQuota(c *C) {
 	c.Assert(cfg.Adjust(ctx), IsNil)
 	c.Assert(int64(cfg.TikvImporter.DiskQuota), Equals, int64(0))
 }
+
+func (s *configTestSuite) TestAdjustDuplicateResolution(c *C) {
+	ctx := context.Background()
+	newCfg := func(backend string) *config.Config {
+		cfg := config.NewConfig()
+		assignMinimalLegalValue(cfg)
+		cfg.TikvImporter.Backend = backend
+		cfg.TikvImporter.SortedKVDir = c.MkDir()
+		cfg.TiDB.DistSQLScanConcurrency = 1
+		return cfg
+	}
+
+	cfg := newCfg(config.BackendLocal)
+	cfg.TikvImporter.DuplicateResolution = ""
+	c.Assert(cfg.Adjust(ctx), IsNil)
+	c.Assert(cfg.TikvImporter.DuplicateResolution, Equals, config.DupeResAlgNone)
+
+	cfg = newCfg(config.BackendLocal)
+	cfg.TikvImporter.DuplicateDetection = true
+	cfg.TikvImporter.DuplicateResolution = "Keep-Last"
+	c.Assert(cfg.Adjust(ctx), IsNil)
+	c.Assert(cfg.TikvImporter.DuplicateResolution, Equals, config.DupeResAlgKeepLast)
+
+	cfg = newCfg(config.BackendLocal)
+	cfg.TikvImporter.DuplicateResolution = config.DupeResAlgRemove
+	c.Assert(cfg.Adjust(ctx), ErrorMatches, "invalid config: `tikv-importer.duplicate-resolution` \\(remove\\) requires `tikv-importer.duplicate-detection` to be true")
+
+	cfg = newCfg(config.BackendLocal)
+	cfg.TikvImporter.DuplicateDetection = true
+	cfg.TikvImporter.DuplicateResolution = "keep-any"
+	c.Assert(cfg.Adjust(ctx), ErrorMatches, "invalid config: unsupported `tikv-importer.duplicate-resolution` \\(keep-any\\)")
+
+	// the TiDB backend resolves the duplicate rows by `on-duplicate` instead.
+	cfg = newCfg(config.BackendTiDB)
+	cfg.TikvImporter.DuplicateDetection = true
+	cfg.TikvImporter.DuplicateResolution = config.DupeResAlgKeepFirst
+	c.Assert(cfg.Adjust(ctx), IsNil)
+	c.Assert(cfg.TikvImporter.DuplicateResolution, Equals, config.DupeResAlgNone)
+}
+
+func (s *configTestSuite) TestAdjustUpsert(c *C) {
+	ctx := context.Background()
+	newCfg := func(backend string) *config.Config {
+		cfg := config.NewConfig()
+		assignMinimalLegalValue(cfg)
+		cfg.TikvImporter.Backend = backend
+		cfg.TikvImporter.SortedKVDir = c.MkDir()
+		cfg.TikvImporter.Upsert = true
+		cfg.TiDB.DistSQLScanConcurrency = 1
+		return cfg
+	}
+
+	cfg := newCfg(config.BackendLocal)
+	c.Assert(cfg.Adjust(ctx), IsNil)
+	c.Assert(cfg.TikvImporter.Upsert, IsTrue)
+
+	cfg = newCfg(config.BackendImporter)
+	c.Assert(cfg.Adjust(ctx), ErrorMatches, "invalid config: unsupported backend \\(importer\\) for upsert")
+
+	// the TiDB backend merges the rows by `on-duplicate` instead.
+	cfg = newCfg(config.BackendTiDB)
+	c.Assert(cfg.Adjust(ctx), IsNil)
+	c.Assert(cfg.TikvImporter.Upsert, IsFalse)
+}
+
+func (s *configTestSuite) TestAdjustSortedKVSpillURL(c *C) {
+	ctx := context.Background()
+	newCfg := func(backend string, url string) *config.Config {
+		cfg := config.NewConfig()
+		assignMinimalLegalValue(cfg)
+		cfg.TikvImporter.Backend = backend
+		cfg.TikvImporter.SortedKVDir = c.MkDir()
+		cfg.TikvImporter.SortedKVSpillURL = url
+		cfg.TiDB.DistSQLScanConcurrency = 1
+		return cfg
+	}
+
+	cfg := newCfg(config.BackendLocal, "s3://bucket/prefix")
+	c.Assert(cfg.Adjust(ctx), IsNil)
+
+	cfg = newCfg(config.BackendLocal, "xxx://bucket/prefix")
+	c.Assert(cfg.Adjust(ctx), ErrorMatches, "invalid tikv-importer.sorted-kv-spill-url.*")
+
+	cfg = newCfg(config.BackendTiDB, "s3://bucket/prefix")
+	c.Assert(cfg.Adjust(ctx), ErrorMatches, "invalid config: unsupported backend \\(tidb\\) for sorted-kv-spill-url")
+}
+
+func (s *configTestSuite) TestAdjustRowsPerStatement(c *C) {
+	cfg := config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.TiDB.DistSQLScanConcurrency = 1
+	cfg.TikvImporter.RowsPerStatement = -1
+	c.Assert(cfg.Adjust(context.Background()), ErrorMatches, "invalid config: `tikv-importer.rows-per-statement` must not be negative")
+
+	cfg.TikvImporter.RowsPerStatement = 100
+	c.Assert(cfg.Adjust(context.Background()), IsNil)
+	c.Assert(cfg.TikvImporter.RowsPerStatement, Equals, 100)
+}
+
+func (s *configTestSuite) TestMaxError(c *C) {
+	cfg := config.NewConfig()
+	c.Assert(cfg.LoadFromTOML([]byte(`
+		[lightning.max-error]
+		syntax = 1
+		charset = 2
+		type = 3
+		conflict = 4
+	`)), IsNil)
+	c.Assert(cfg.App.MaxError, Equals, config.MaxError{Syntax: 1, Charset: 2, Type: 3, Conflict: 4})
+
+	c.Assert(cfg.LoadFromTOML([]byte(`
+		[lightning.max-error]
+		typo = 1
+	`)), ErrorMatches, ".*unknown configuration options: lightning.max-error.typo.*")
+
+	cfg = config.NewConfig()
+	assignMinimalLegalValue(cfg)
+	cfg.TiDB.DistSQLScanConcurrency = 1
+	cfg.App.MaxError.Conflict = -1
+	c.Assert(cfg.Adjust(context.Background()), ErrorMatches, "invalid config: `lightning.max-error` must not be negative")
+	cfg.App.MaxError.Conflict = 0
+	c.Assert(cfg.Adjust(context.Background()), IsNil)
+	c.Assert(cfg.App.TaskInfoSchemaName, Equals, "lightning_task_info")
+}
+
+func (s *configTestSuite) TestTransform(c *C) {
+	cfg := config.NewConfig()
+	c.Assert(cfg.LoadFromTOML([]byte(`
+		[[mydumper.transform]]
+		db = "db"
+		table = "tbl"
+		columns = { Full_Name = "CONCAT(@first, ' ', @last)" }
+
+		[[mydumper.transform]]
+		table-filter = ["db.t*"]
+		where = "@tenant_id = 42"
+		[mydumper.transform.columns]
+		note = "'imported'"
+	`)), IsNil)
+	c.Assert(cfg.Mydumper.Transform, HasLen, 2)
+	cfg.AdjustMydumper()
+	c.Assert(cfg.Mydumper.Transform[0].Columns, DeepEquals, map[string]string{"full_name": "CONCAT(@first, ' ', @last)"})
+
+	rule, err := cfg.Mydumper.Transform.GetTransformRule("DB", "TBL", false)
+	c.Assert(err, IsNil)
+	c.Assert(rule, Equals, cfg.Mydumper.Transform[0])
+	rule, err = cfg.Mydumper.Transform.GetTransformRule("db", "t1", false)
+	c.Assert(err, IsNil)
+	c.Assert(rule, Equals, cfg.Mydumper.Transform[1])
+	c.Assert(rule.Where, Equals, "@tenant_id = 42")
+	rule, err = cfg.Mydumper.Transform.GetTransformRule("db", "other", false)
+	c.Assert(err, IsNil)
+	c.Assert(rule, IsNil)
+}
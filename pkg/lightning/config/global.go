@@ -24,6 +24,7 @@ import (
 	"github.com/carlmjohnson/flagext"
 	"github.com/pingcap/errors"
 
+	"github.com/pingcap/br/pkg/httputil"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/version/build"
 )
@@ -33,6 +34,11 @@ type GlobalLightning struct {
 	StatusAddr        string `toml:"status-addr" json:"status-addr"`
 	ServerMode        bool   `toml:"server-mode" json:"server-mode"`
 	CheckRequirements bool   `toml:"check-requirements" json:"check-requirements"`
+	// MaxConcurrentTasks is the number of tasks running concurrently in the
+	// server mode, each of which gets a share of the resources.
+	MaxConcurrentTasks int `toml:"max-concurrent-tasks" json:"max-concurrent-tasks"`
+	// StatusAuth authorizes the clients of the HTTP API on the status address.
+	StatusAuth httputil.AuthConfig `toml:"status-auth" json:"status-auth"`
 
 	// The legacy alias for setting "status-addr". The value should always the
 	// same as StatusAddr, and will not be published in the JSON encoding.
@@ -87,8 +93,9 @@ type GlobalPostRestore struct {
 func NewGlobalConfig() *GlobalConfig {
 	return &GlobalConfig{
 		App: GlobalLightning{
-			ServerMode:        false,
-			CheckRequirements: true,
+			ServerMode:         false,
+			CheckRequirements:  true,
+			MaxConcurrentTasks: 1,
 		},
 		Checkpoint: GlobalCheckpoint{
 			Enable: true,
@@ -279,6 +286,12 @@ func LoadGlobalConfig(args []string, extraFlags func(*flag.FlagSet)) (*GlobalCon
 	if cfg.App.StatusAddr == "" && cfg.App.ServerMode {
 		return nil, errors.New("If server-mode is enabled, the status-addr must be a valid listen address")
 	}
+	if cfg.App.MaxConcurrentTasks < 1 {
+		return nil, errors.New("invalid config: `lightning.max-concurrent-tasks` must be positive")
+	}
+	if err := cfg.App.StatusAuth.Validate(cfg.Security.CAPath != ""); err != nil {
+		return nil, errors.Annotate(err, "invalid config: `lightning.status-auth`")
+	}
 
 	cfg.App.Config.Adjust()
 	return cfg, nil
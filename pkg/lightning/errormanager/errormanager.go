@@ -0,0 +1,494 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errormanager
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	tidbkv "github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/table"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/redact"
+)
+
+const (
+	createSchema = `
+		CREATE SCHEMA IF NOT EXISTS %s;`
+
+	createErrorTable = `
+		CREATE TABLE IF NOT EXISTS %s.%s (
+			task_id     bigint NOT NULL,
+			create_time datetime(6) NOT NULL DEFAULT now(6),
+			table_name  varchar(261) NOT NULL,
+			path        varchar(2048) NOT NULL,
+			offset      bigint NOT NULL,
+			error       text NOT NULL,
+			row_data    text NOT NULL,
+			INDEX (task_id, table_name)
+		);`
+
+	insertIntoErrorTable = `
+		INSERT INTO %s.%s (task_id, table_name, path, offset, error, row_data) VALUES (?, ?, ?, ?, ?, ?);`
+
+	// conflictRecordsTableName is the table recording the rows removed when
+	// resolving the duplicate rows detected by the local backend.
+	conflictRecordsTableName = "conflict_records_v1"
+
+	createConflictRecordsTable = `
+		CREATE TABLE IF NOT EXISTS %s.` + conflictRecordsTableName + ` (
+			task_id     bigint NOT NULL,
+			create_time datetime(6) NOT NULL DEFAULT now(6),
+			table_name  varchar(261) NOT NULL,
+			index_name  varchar(128) NOT NULL,
+			path        varchar(2048) NOT NULL,
+			offset      bigint NOT NULL,
+			resolution  varchar(16) NOT NULL,
+			row_data    text NOT NULL,
+			INDEX (task_id, table_name)
+		);`
+
+	insertIntoConflictRecordsTable = `
+		INSERT INTO %s.` + conflictRecordsTableName + ` (task_id, table_name, index_name, path, offset, resolution, row_data) VALUES (?, ?, ?, ?, ?, ?, ?);`
+
+	// conflictKeysTableName is the table recording the duplicate keys detected
+	// by the local backend, one row for each row owning the key.
+	conflictKeysTableName = "conflict_keys_v1"
+
+	createConflictKeysTable = `
+		CREATE TABLE IF NOT EXISTS %s.` + conflictKeysTableName + ` (
+			task_id     bigint NOT NULL,
+			create_time datetime(6) NOT NULL DEFAULT now(6),
+			table_name  varchar(261) NOT NULL,
+			index_name  varchar(128) NOT NULL,
+			key_data    text NOT NULL,
+			row_key     text NOT NULL,
+			INDEX (task_id, table_name)
+		);`
+
+	insertIntoConflictKeysTable = `
+		INSERT INTO %s.` + conflictKeysTableName + ` (task_id, table_name, index_name, key_data, row_key) VALUES `
+
+	// maxConflictKeysPerInsert is the number of rows inserted into the
+	// conflict keys table by one statement.
+	maxConflictKeysPerInsert = 256
+)
+
+// ErrorKind is the kind of the errors of the rejected rows.
+type ErrorKind int
+
+const (
+	SyntaxError ErrorKind = iota
+	CharsetError
+	TypeError
+	ConflictError
+
+	errorKindCount = iota
+)
+
+var errorKindNames = [errorKindCount]string{
+	SyntaxError:   "syntax",
+	CharsetError:  "charset",
+	TypeError:     "type",
+	ConflictError: "conflict",
+}
+
+func (kind ErrorKind) String() string {
+	return errorKindNames[kind]
+}
+
+// TableName returns the name of the table recording the rejected rows of this
+// kind of errors.
+func (kind ErrorKind) TableName() string {
+	return kind.String() + "_error_v1"
+}
+
+// ErrorManager records the rejected rows into the task info schema until the
+// `max-error` budget of the error kind is exhausted.
+//
+// A nil ErrorManager tolerates no error.
+type ErrorManager struct {
+	db            *sql.DB
+	taskID        int64
+	schemaEscaped string
+	maxError      [errorKindCount]int64
+	remaining     [errorKindCount]atomic.Int64
+	dupeResAlg    string
+	dupeDetection bool
+
+	// duplicates are the numbers of the duplicate keys and their rows of each
+	// index, grouped by the table names.
+	duplicatesLock sync.Mutex
+	duplicates     map[string]map[string]*duplicateCount
+}
+
+type duplicateCount struct {
+	keys int64
+	rows int64
+}
+
+// DuplicateKey is a key owned by several rows, detected by the local backend.
+type DuplicateKey struct {
+	// IndexName is the name of the index of the key, or "PRIMARY" if the key
+	// is a handle.
+	IndexName string
+	Key       []byte
+	// RowKeys are the record keys of the rows owning the key.
+	RowKeys [][]byte
+}
+
+// New creates a new error manager, the rejected rows are written through `db`
+// if it is not nil.
+func New(db *sql.DB, cfg *config.Config) *ErrorManager {
+	em := &ErrorManager{
+		db:            db,
+		taskID:        cfg.TaskID,
+		schemaEscaped: common.EscapeIdentifier(cfg.App.TaskInfoSchemaName),
+		dupeResAlg:    cfg.TikvImporter.DuplicateResolution,
+		dupeDetection: cfg.TikvImporter.DuplicateDetection,
+		duplicates:    make(map[string]map[string]*duplicateCount),
+	}
+	em.maxError = [errorKindCount]int64{
+		SyntaxError:   cfg.App.MaxError.Syntax,
+		CharsetError:  cfg.App.MaxError.Charset,
+		TypeError:     cfg.App.MaxError.Type,
+		ConflictError: cfg.App.MaxError.Conflict,
+	}
+	for kind, maxError := range em.maxError {
+		em.remaining[kind].Store(maxError)
+	}
+	return em
+}
+
+// Init creates the schema and the tables for the error kinds with a non-zero
+// budget, the conflict keys table if the duplicate keys are detected, and the
+// conflict records table if the duplicate rows are resolved.
+func (em *ErrorManager) Init(ctx context.Context) error {
+	if em == nil || em.db == nil {
+		return nil
+	}
+
+	exec := common.SQLWithRetry{
+		DB:     em.db,
+		Logger: log.L(),
+	}
+	schemaCreated := false
+	createSchemaOnce := func() error {
+		if schemaCreated {
+			return nil
+		}
+		if err := exec.Exec(ctx, "create task info schema", fmt.Sprintf(createSchema, em.schemaEscaped)); err != nil {
+			return errors.Annotate(err, "create task info schema failed")
+		}
+		schemaCreated = true
+		return nil
+	}
+	for kind := ErrorKind(0); kind < errorKindCount; kind++ {
+		if em.maxError[kind] == 0 {
+			continue
+		}
+		if err := createSchemaOnce(); err != nil {
+			return err
+		}
+		query := fmt.Sprintf(createErrorTable, em.schemaEscaped, kind.TableName())
+		if err := exec.Exec(ctx, "create error table", query); err != nil {
+			return errors.Annotatef(err, "create %s error table failed", kind)
+		}
+	}
+	if em.dupeDetection {
+		if err := createSchemaOnce(); err != nil {
+			return err
+		}
+		query := fmt.Sprintf(createConflictKeysTable, em.schemaEscaped)
+		if err := exec.Exec(ctx, "create conflict keys table", query); err != nil {
+			return errors.Annotate(err, "create conflict keys table failed")
+		}
+	}
+	if em.dupeResAlg != "" && em.dupeResAlg != config.DupeResAlgNone {
+		if err := createSchemaOnce(); err != nil {
+			return err
+		}
+		query := fmt.Sprintf(createConflictRecordsTable, em.schemaEscaped)
+		if err := exec.Exec(ctx, "create conflict records table", query); err != nil {
+			return errors.Annotate(err, "create conflict records table failed")
+		}
+	}
+	return nil
+}
+
+// Remaining returns the number of the rows of the error kind which can still
+// be rejected.
+func (em *ErrorManager) Remaining(kind ErrorKind) int64 {
+	if em == nil {
+		return 0
+	}
+	if remaining := em.remaining[kind].Load(); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RecordSyntaxError records a row which can't be parsed. It returns nil if the
+// row can be skipped, or returns `rowErr` once the budget is exhausted.
+func (em *ErrorManager) RecordSyntaxError(
+	ctx context.Context,
+	logger log.Logger,
+	tableName string,
+	path string,
+	offset int64,
+	rowText string,
+	rowErr error,
+) error {
+	return em.Record(ctx, logger, SyntaxError, tableName, path, offset, rowText, rowErr)
+}
+
+// RecordTypeError records a row which can't be encoded, the error is
+// classified as a charset error if the row contains invalid characters,
+// otherwise a type error. It returns nil if the row can be skipped, or returns
+// `encodeErr` once the budget is exhausted.
+func (em *ErrorManager) RecordTypeError(
+	ctx context.Context,
+	logger log.Logger,
+	tableName string,
+	path string,
+	offset int64,
+	rowText string,
+	encodeErr error,
+) error {
+	kind := TypeError
+	if table.ErrTruncatedWrongValueForField.Equal(errors.Cause(encodeErr)) {
+		kind = CharsetError
+	}
+	return em.Record(ctx, logger, kind, tableName, path, offset, rowText, encodeErr)
+}
+
+// RecordConflictError records a row conflicting with the existing rows. It
+// returns nil if the row can be skipped, or returns `conflictErr` once the
+// budget is exhausted.
+func (em *ErrorManager) RecordConflictError(
+	ctx context.Context,
+	logger log.Logger,
+	tableName string,
+	path string,
+	offset int64,
+	rowText string,
+	conflictErr error,
+) error {
+	return em.Record(ctx, logger, ConflictError, tableName, path, offset, rowText, conflictErr)
+}
+
+// Record records a rejected row of the error kind.
+func (em *ErrorManager) Record(
+	ctx context.Context,
+	logger log.Logger,
+	kind ErrorKind,
+	tableName string,
+	path string,
+	offset int64,
+	rowText string,
+	rowErr error,
+) error {
+	if em == nil || em.maxError[kind] == 0 {
+		return rowErr
+	}
+	if em.remaining[kind].Dec() < 0 {
+		return errors.Annotatef(rowErr, "the number of %s errors exceeds `lightning.max-error.%s`", kind, kind)
+	}
+
+	logger.Warn("skip the rejected row",
+		zap.Stringer("kind", kind),
+		zap.String("table", tableName),
+		zap.String("path", path),
+		zap.Int64("offset", offset),
+		zap.String("row", redact.String(rowText)),
+		log.ShortError(rowErr))
+	if em.db == nil {
+		return nil
+	}
+
+	exec := common.SQLWithRetry{
+		DB:           em.db,
+		Logger:       logger,
+		HideQueryLog: redact.NeedRedact(),
+	}
+	query := fmt.Sprintf(insertIntoErrorTable, em.schemaEscaped, kind.TableName())
+	if err := exec.Exec(ctx, "insert error record", query,
+		em.taskID, tableName, path, offset, rowErr.Error(), rowText,
+	); err != nil {
+		return errors.Annotatef(err, "record %s error failed", kind)
+	}
+	return nil
+}
+
+// RecordConflictRecord records a row removed when resolving the duplicate
+// rows. `indexName` is the name of the conflicting index, or "PRIMARY" if the
+// row conflicts on the handle.
+func (em *ErrorManager) RecordConflictRecord(
+	ctx context.Context,
+	logger log.Logger,
+	tableName string,
+	indexName string,
+	path string,
+	offset int64,
+	rowText string,
+) error {
+	if em == nil {
+		return nil
+	}
+
+	logger.Warn("remove the duplicate row",
+		zap.String("table", tableName),
+		zap.String("index", indexName),
+		zap.String("path", path),
+		zap.Int64("offset", offset),
+		zap.String("resolution", em.dupeResAlg),
+		zap.String("row", redact.String(rowText)))
+	if em.db == nil {
+		return nil
+	}
+
+	exec := common.SQLWithRetry{
+		DB:           em.db,
+		Logger:       logger,
+		HideQueryLog: redact.NeedRedact(),
+	}
+	query := fmt.Sprintf(insertIntoConflictRecordsTable, em.schemaEscaped)
+	if err := exec.Exec(ctx, "insert conflict record", query,
+		em.taskID, tableName, indexName, path, offset, em.dupeResAlg, rowText,
+	); err != nil {
+		return errors.Annotate(err, "record conflict record failed")
+	}
+	return nil
+}
+
+// RecordDuplicateKeys records the duplicate keys of the table into the
+// conflict keys table, and counts them for the summary of the task.
+func (em *ErrorManager) RecordDuplicateKeys(
+	ctx context.Context,
+	logger log.Logger,
+	tableName string,
+	keys []DuplicateKey,
+) error {
+	if em == nil || len(keys) == 0 {
+		return nil
+	}
+
+	var rowCount int
+	em.duplicatesLock.Lock()
+	counts, ok := em.duplicates[tableName]
+	if !ok {
+		counts = make(map[string]*duplicateCount)
+		em.duplicates[tableName] = counts
+	}
+	for _, key := range keys {
+		count, ok := counts[key.IndexName]
+		if !ok {
+			count = &duplicateCount{}
+			counts[key.IndexName] = count
+		}
+		count.keys++
+		count.rows += int64(len(key.RowKeys))
+		rowCount += len(key.RowKeys)
+	}
+	em.duplicatesLock.Unlock()
+
+	logger.Warn("detected duplicate keys",
+		zap.String("table", tableName),
+		zap.Int("keys", len(keys)),
+		zap.Int("rows", rowCount))
+	if em.db == nil {
+		return nil
+	}
+
+	exec := common.SQLWithRetry{
+		DB:           em.db,
+		Logger:       logger,
+		HideQueryLog: redact.NeedRedact(),
+	}
+	var sb strings.Builder
+	args := make([]interface{}, 0, maxConflictKeysPerInsert*5)
+	flush := func() error {
+		if len(args) == 0 {
+			return nil
+		}
+		query := fmt.Sprintf(insertIntoConflictKeysTable, em.schemaEscaped) + sb.String()
+		if err := exec.Exec(ctx, "insert conflict keys", query, args...); err != nil {
+			return errors.Annotate(err, "record conflict keys failed")
+		}
+		sb.Reset()
+		args = args[:0]
+		return nil
+	}
+	for _, key := range keys {
+		keyData := tidbkv.Key(key.Key).String()
+		for _, rowKey := range key.RowKeys {
+			if len(args) > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("(?, ?, ?, ?, ?)")
+			args = append(args, em.taskID, tableName, key.IndexName, keyData, tidbkv.Key(rowKey).String())
+			if len(args) == cap(args) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return flush()
+}
+
+// LogDuplicateSummary logs the numbers of the duplicate keys and their rows of
+// each index detected in the task.
+func (em *ErrorManager) LogDuplicateSummary(logger log.Logger) {
+	if em == nil {
+		return
+	}
+	em.duplicatesLock.Lock()
+	defer em.duplicatesLock.Unlock()
+
+	if len(em.duplicates) == 0 {
+		return
+	}
+	tableNames := make([]string, 0, len(em.duplicates))
+	for tableName := range em.duplicates {
+		tableNames = append(tableNames, tableName)
+	}
+	sort.Strings(tableNames)
+	logger.Warn("tables have duplicate keys", zap.Int("count", len(tableNames)))
+	for _, tableName := range tableNames {
+		counts := em.duplicates[tableName]
+		indexNames := make([]string, 0, len(counts))
+		for indexName := range counts {
+			indexNames = append(indexNames, indexName)
+		}
+		sort.Strings(indexNames)
+		for _, indexName := range indexNames {
+			logger.Warn("-",
+				zap.String("table", tableName),
+				zap.String("index", indexName),
+				zap.Int64("keys", counts[indexName].keys),
+				zap.Int64("rows", counts[indexName].rows))
+		}
+	}
+}
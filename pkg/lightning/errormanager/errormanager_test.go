@@ -0,0 +1,180 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errormanager_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/table"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+func Test(t *testing.T) {
+	TestingT(t)
+}
+
+var _ = Suite(&errorManagerSuite{})
+
+type errorManagerSuite struct{}
+
+func newConfig(maxError config.MaxError) *config.Config {
+	cfg := config.NewConfig()
+	cfg.TaskID = 42
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.App.MaxError = maxError
+	return cfg
+}
+
+func (s *errorManagerSuite) TestInit(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	em := errormanager.New(db, newConfig(config.MaxError{Type: 10, Conflict: 1}))
+	mock.ExpectExec("CREATE SCHEMA IF NOT EXISTS `lightning_task_info`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `lightning_task_info`\\.type_error_v1.*").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `lightning_task_info`\\.conflict_error_v1.*").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	c.Assert(em.Init(context.Background()), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+
+	// nothing is created if no error is tolerated.
+	em = errormanager.New(db, newConfig(config.MaxError{}))
+	c.Assert(em.Init(context.Background()), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
+
+func (s *errorManagerSuite) TestRecord(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	ctx := context.Background()
+	logger := log.L()
+	em := errormanager.New(db, newConfig(config.MaxError{Type: 1, Charset: 1}))
+	c.Assert(em.Remaining(errormanager.TypeError), Equals, int64(1))
+
+	typeErr := errors.New("invalid integer")
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.type_error_v1.*").
+		WithArgs(42, "`db`.`tbl`", "db.tbl.csv", 10, "invalid integer", "1,a").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	c.Assert(em.RecordTypeError(ctx, logger, "`db`.`tbl`", "db.tbl.csv", 10, "1,a", typeErr), IsNil)
+	c.Assert(em.Remaining(errormanager.TypeError), Equals, int64(0))
+
+	// the budget is exhausted.
+	err = em.RecordTypeError(ctx, logger, "`db`.`tbl`", "db.tbl.csv", 20, "2,b", typeErr)
+	c.Assert(errors.Cause(err), Equals, typeErr)
+	c.Assert(err, ErrorMatches, "the number of type errors exceeds `lightning.max-error.type`.*")
+
+	// the invalid characters are charset errors.
+	charsetErr := table.ErrTruncatedWrongValueForField.FastGen("Incorrect string value '\\xFF' for column 'c'")
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.charset_error_v1.*").
+		WithArgs(42, "`db`.`tbl`", "db.tbl.csv", 30, sqlmock.AnyArg(), "3,\xff").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	c.Assert(em.RecordTypeError(ctx, logger, "`db`.`tbl`", "db.tbl.csv", 30, "3,\xff", errors.Trace(charsetErr)), IsNil)
+
+	// no syntax error is tolerated.
+	syntaxErr := errors.New("syntax error")
+	c.Assert(em.RecordSyntaxError(ctx, logger, "`db`.`tbl`", "db.tbl.csv", 40, "'", syntaxErr), Equals, syntaxErr)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+
+	// a nil error manager tolerates no error.
+	em = nil
+	c.Assert(em.RecordConflictError(ctx, logger, "`db`.`tbl`", "db.tbl.csv", 50, "(1)", typeErr), Equals, typeErr)
+	c.Assert(em.Remaining(errormanager.ConflictError), Equals, int64(0))
+}
+
+func (s *errorManagerSuite) TestRecordConflictRecord(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	ctx := context.Background()
+	cfg := newConfig(config.MaxError{})
+	cfg.TikvImporter.DuplicateResolution = config.DupeResAlgKeepLast
+	em := errormanager.New(db, cfg)
+
+	// the conflict records table is created even if no error is tolerated.
+	mock.ExpectExec("CREATE SCHEMA IF NOT EXISTS `lightning_task_info`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `lightning_task_info`\\.conflict_records_v1.*").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	c.Assert(em.Init(ctx), IsNil)
+
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_records_v1.*").
+		WithArgs(42, "`db`.`tbl`", "uk", "db.tbl.csv", 10, "keep-last", "(1, 2)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	c.Assert(em.RecordConflictRecord(ctx, log.L(), "`db`.`tbl`", "uk", "db.tbl.csv", 10, "(1, 2)"), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+}
+
+func (s *errorManagerSuite) TestRecordDuplicateKeys(c *C) {
+	db, mock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+
+	ctx := context.Background()
+	logger := log.L()
+	cfg := newConfig(config.MaxError{})
+	cfg.TikvImporter.DuplicateDetection = true
+	em := errormanager.New(db, cfg)
+
+	mock.ExpectExec("CREATE SCHEMA IF NOT EXISTS `lightning_task_info`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS `lightning_task_info`\\.conflict_keys_v1.*").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	c.Assert(em.Init(ctx), IsNil)
+
+	keys := []errormanager.DuplicateKey{
+		{IndexName: "uk", Key: []byte("ab"), RowKeys: [][]byte{{0x0, 0x1}, {0x0, 0x2}}},
+		{IndexName: "PRIMARY", Key: []byte{0x1}, RowKeys: [][]byte{{0x1}}},
+	}
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_keys_v1.*").
+		WithArgs(
+			42, "`db`.`tbl`", "uk", "6162", "0001",
+			42, "`db`.`tbl`", "uk", "6162", "0002",
+			42, "`db`.`tbl`", "PRIMARY", "01", "01",
+		).
+		WillReturnResult(sqlmock.NewResult(3, 3))
+	c.Assert(em.RecordDuplicateKeys(ctx, logger, "`db`.`tbl`", keys), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+
+	// the rows are inserted in batches of 256.
+	rowKeys := make([][]byte, 300)
+	for i := range rowKeys {
+		rowKeys[i] = []byte{byte(i >> 8), byte(i)}
+	}
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_keys_v1.*").
+		WillReturnResult(sqlmock.NewResult(256, 256))
+	mock.ExpectExec("INSERT INTO `lightning_task_info`\\.conflict_keys_v1.*").
+		WillReturnResult(sqlmock.NewResult(44, 44))
+	keys = []errormanager.DuplicateKey{{IndexName: "PRIMARY", Key: []byte{0x2}, RowKeys: rowKeys}}
+	c.Assert(em.RecordDuplicateKeys(ctx, logger, "`db`.`tbl`", keys), IsNil)
+	c.Assert(mock.ExpectationsWereMet(), IsNil)
+	em.LogDuplicateSummary(logger)
+
+	// a nil manager drops the keys.
+	var nilEm *errormanager.ErrorManager
+	c.Assert(nilEm.RecordDuplicateKeys(ctx, logger, "`db`.`tbl`", keys), IsNil)
+	nilEm.LogDuplicateSummary(logger)
+}
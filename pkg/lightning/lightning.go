@@ -16,6 +16,7 @@ package lightning
 import (
 	"compress/gzip"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -36,6 +37,7 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
+	"github.com/pingcap/br/pkg/httputil"
 	"github.com/pingcap/br/pkg/lightning/backend/local"
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
@@ -63,8 +65,20 @@ type Lightning struct {
 	serverLock sync.Mutex
 
 	cancelLock sync.Mutex
-	curTask    *config.Config
-	cancel     context.CancelFunc // for per task context, which maybe different from lightning context
+	// tasks are the running tasks in the order they are started, there are
+	// more than one only if the server mode runs tasks concurrently.
+	tasks []*runningTask
+}
+
+type runningTask struct {
+	// id is the task ID, which is replaced by the one in the checkpoints when
+	// the task is resumed.
+	id     int64
+	cfg    *config.Config
+	cancel context.CancelFunc // for per task context, which maybe different from lightning context
+	// controller restores the task, the tables of which can be controlled
+	// through the HTTP API in the server mode.
+	controller *restore.Controller
 }
 
 func initEnv(cfg *config.GlobalConfig) error {
@@ -126,25 +140,37 @@ func (l *Lightning) GoServe() error {
 
 func (l *Lightning) goServe(statusAddr string, realAddrWriter io.Writer) error {
 	mux := http.NewServeMux()
-	mux.Handle("/", http.RedirectHandler("/web/", http.StatusFound))
-	mux.Handle("/metrics", promhttp.Handler())
+	// every endpoint requires the read-only role to read, and the admin role
+	// to change anything, unless specified otherwise.
+	auth := httputil.NewAuthorizer(&l.globalCfg.App.StatusAuth)
+	handle := func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, auth.Wrap(handler, httputil.ReadOnlyForSafeMethods))
+	}
+	handle("/", http.RedirectHandler("/web/", http.StatusFound))
+	handle("/metrics", promhttp.Handler())
 
-	mux.HandleFunc("/debug/pprof/", pprof.Index)
-	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	handlePProf := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, auth.Wrap(handler, httputil.PProfRole))
+	}
+	handlePProf("/debug/pprof/", pprof.Index)
+	handlePProf("/debug/pprof/cmdline", pprof.Cmdline)
+	handlePProf("/debug/pprof/profile", pprof.Profile)
+	handlePProf("/debug/pprof/symbol", pprof.Symbol)
+	handlePProf("/debug/pprof/trace", pprof.Trace)
 
 	handleTasks := http.StripPrefix("/tasks", http.HandlerFunc(l.handleTask))
-	mux.Handle("/tasks", handleTasks)
-	mux.Handle("/tasks/", handleTasks)
-	mux.HandleFunc("/progress/task", handleProgressTask)
-	mux.HandleFunc("/progress/table", handleProgressTable)
-	mux.HandleFunc("/pause", handlePause)
-	mux.HandleFunc("/resume", handleResume)
-	mux.HandleFunc("/loglevel", handleLogLevel)
-
-	mux.Handle("/web/", http.StripPrefix("/web", httpgzip.FileServer(web.Res, httpgzip.FileServerOptions{
+	handle("/tasks", handleTasks)
+	handle("/tasks/", handleTasks)
+	handle("/tables/", http.StripPrefix("/tables", http.HandlerFunc(l.handleTable)))
+	// the precheck connects to the target cluster with the posted config.
+	mux.Handle("/precheck", auth.Wrap(http.HandlerFunc(l.handlePreCheck), httputil.AdminOnly))
+	handle("/progress/task", http.HandlerFunc(handleProgressTask))
+	handle("/progress/table", http.HandlerFunc(handleProgressTable))
+	handle("/pause", http.HandlerFunc(handlePause))
+	handle("/resume", http.HandlerFunc(handleResume))
+	handle("/loglevel", http.HandlerFunc(handleLogLevel))
+
+	handle("/web/", http.StripPrefix("/web", httpgzip.FileServer(web.Res, httpgzip.FileServerOptions{
 		IndexHTML: true,
 		ServeError: func(w http.ResponseWriter, req *http.Request, err error) {
 			if os.IsNotExist(err) && !strings.Contains(req.URL.Path, ".") {
@@ -163,7 +189,9 @@ func (l *Lightning) goServe(statusAddr string, realAddrWriter io.Writer) error {
 	log.L().Info("starting HTTP server", zap.Stringer("address", l.serverAddr))
 	fmt.Fprintln(realAddrWriter, "started HTTP server on", l.serverAddr)
 	l.server.Handler = mux
-	listener = l.globalTLS.WrapListener(listener)
+	if tlsConfig := l.globalCfg.App.StatusAuth.ServerTLSConfig(l.globalTLS.TLSConfig()); tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 
 	go func() {
 		err := l.server.Serve(listener)
@@ -174,7 +202,7 @@ func (l *Lightning) goServe(statusAddr string, realAddrWriter io.Writer) error {
 
 // RunOnce is used by binary lightning and host when using lightning as a library.
 // - for binary lightning, taskCtx could be context.Background which means taskCtx wouldn't be canceled directly by its
-//   cancel function, but only by Lightning.Stop or HTTP DELETE using l.cancel. and glue could be nil to let lightning
+//   cancel function, but only by Lightning.Stop or HTTP DELETE canceling the task. and glue could be nil to let lightning
 //   use a default glue later.
 // - for lightning as a library, taskCtx could be a meaningful context that get canceled outside, and glue could be a
 //   caller implemented glue.
@@ -198,16 +226,41 @@ func (l *Lightning) RunServer() error {
 		zap.Stringer("address", l.serverAddr),
 	)
 
+	maxTasks := l.globalCfg.App.MaxConcurrentTasks
+	if maxTasks < 1 {
+		maxTasks = 1
+	}
+	// slots limits the number of tasks running concurrently, the tasks are
+	// kept in the queue until they can run, so they can still be reordered.
+	slots := make(chan struct{}, maxTasks)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
 	for {
+		select {
+		case slots <- struct{}{}:
+		case <-l.ctx.Done():
+			return l.ctx.Err()
+		}
 		task, err := l.taskCfgs.Pop(l.ctx)
 		if err != nil {
 			return err
 		}
-		err = l.run(context.Background(), task, nil)
-		if err != nil {
-			restore.DeliverPauser.Pause() // force pause the progress on error
-			log.L().Error("tidb lightning encountered error", zap.Error(err))
-		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			err := l.run(context.Background(), task, nil)
+			if err != nil {
+				// force pause the progress on error, unless other tasks are running.
+				if maxTasks == 1 {
+					restore.DeliverPauser.Pause()
+				}
+				log.L().Error("tidb lightning encountered error", zap.Int64("taskID", task.TaskID), zap.Error(err))
+			}
+		}()
 	}
 }
 
@@ -220,18 +273,16 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 	utils.LogEnvVariables()
 
 	ctx, cancel := context.WithCancel(taskCtx)
+	task := &runningTask{id: taskCfg.TaskID, cfg: taskCfg, cancel: cancel}
 	l.cancelLock.Lock()
-	l.cancel = cancel
-	l.curTask = taskCfg
+	l.tasks = append(l.tasks, task)
 	l.cancelLock.Unlock()
-	web.BroadcastStartTask()
+	web.BroadcastStartTask(task.id)
 
 	defer func() {
 		cancel()
-		l.cancelLock.Lock()
-		l.cancel = nil
-		l.cancelLock.Unlock()
-		web.BroadcastEndTask(err)
+		l.removeTask(task)
+		web.BroadcastEndTask(task.id, err)
 	}()
 
 	failpoint.Inject("SkipRunTask", func() {
@@ -269,13 +320,49 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 		g = glue.NewExternalTiDBGlue(db, taskCfg.TiDB.SQLMode)
 	}
 
+	s, dbMetas, err := loadDataSource(ctx, taskCfg)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	web.BroadcastInitProgress(task.id, dbMetas)
+
+	var procedure *restore.Controller
+	procedure, err = restore.NewRestoreController(ctx, dbMetas, taskCfg, s, g)
+	if err != nil {
+		log.L().Error("restore failed", log.ShortError(err))
+		return errors.Trace(err)
+	}
+	defer procedure.Close()
+
+	if l.taskCfgs != nil {
+		procedure.EnableTableControl()
+	}
+	l.cancelLock.Lock()
+	if taskCfg.TaskID != task.id {
+		// the task reuses the ID in the checkpoints.
+		if l.findTask(taskCfg.TaskID) != nil {
+			l.cancelLock.Unlock()
+			return errors.Errorf("task %d resumed from the checkpoints is already running", taskCfg.TaskID)
+		}
+		web.BroadcastTaskID(task.id, taskCfg.TaskID)
+		task.id = taskCfg.TaskID
+	}
+	task.controller = procedure
+	l.cancelLock.Unlock()
+
+	err = procedure.Run(ctx)
+	return errors.Trace(err)
+}
+
+// loadDataSource opens the source storage of the task and loads the data files.
+func loadDataSource(ctx context.Context, taskCfg *config.Config) (storage.ExternalStorage, []*mydump.MDDatabaseMeta, error) {
 	u, err := storage.ParseBackend(taskCfg.Mydumper.SourceDir, nil)
 	if err != nil {
-		return errors.Annotate(err, "parse backend failed")
+		return nil, nil, errors.Annotate(err, "parse backend failed")
 	}
 	s, err := storage.New(ctx, u, &storage.ExternalStorageOptions{})
 	if err != nil {
-		return errors.Annotate(err, "create storage failed")
+		return nil, nil, errors.Annotate(err, "create storage failed")
 	}
 
 	loadTask := log.L().Begin(zap.InfoLevel, "load data source")
@@ -283,39 +370,78 @@ func (l *Lightning) run(taskCtx context.Context, taskCfg *config.Config, g glue.
 	mdl, err = mydump.NewMyDumpLoaderWithStore(ctx, taskCfg, s)
 	loadTask.End(zap.ErrorLevel, err)
 	if err != nil {
-		return errors.Trace(err)
+		return nil, nil, errors.Trace(err)
 	}
 	err = checkSystemRequirement(taskCfg, mdl.GetDatabases())
 	if err != nil {
 		log.L().Error("check system requirements failed", zap.Error(err))
-		return errors.Trace(err)
+		return nil, nil, errors.Trace(err)
 	}
 	// check table schema conflicts
 	err = checkSchemaConflict(taskCfg, mdl.GetDatabases())
 	if err != nil {
 		log.L().Error("checkpoint schema conflicts with data files", zap.Error(err))
-		return errors.Trace(err)
+		return nil, nil, errors.Trace(err)
 	}
+	return s, mdl.GetDatabases(), nil
+}
 
-	dbMetas := mdl.GetDatabases()
-	web.BroadcastInitProgress(dbMetas)
+// PreCheck runs the checks of the task, and parses up to previewRowCount rows
+// of each table for preview, without importing anything.
+func (l *Lightning) PreCheck(ctx context.Context, taskCfg *config.Config, previewRowCount int) (*restore.PreCheckResult, error) {
+	s, dbMetas, err := loadDataSource(ctx, taskCfg)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 
-	var procedure *restore.Controller
-	procedure, err = restore.NewRestoreController(ctx, dbMetas, taskCfg, s, g)
+	if err := taskCfg.TiDB.Security.RegisterMySQL(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	db, err := restore.DBFromConfig(taskCfg.TiDB)
 	if err != nil {
-		log.L().Error("restore failed", log.ShortError(err))
-		return errors.Trace(err)
+		return nil, errors.Trace(err)
+	}
+	g := glue.NewExternalTiDBGlue(db, taskCfg.TiDB.SQLMode)
+	procedure, err := restore.NewPreCheckController(ctx, dbMetas, taskCfg, s, g)
+	if err != nil {
+		db.Close()
+		return nil, errors.Trace(err)
 	}
 	defer procedure.Close()
 
-	err = procedure.Run(ctx)
-	return errors.Trace(err)
+	result, err := procedure.PreCheck(ctx, previewRowCount)
+	return result, errors.Trace(err)
+}
+
+// findTask returns the running task of the ID, or nil if not found. It should
+// be called with cancelLock held.
+func (l *Lightning) findTask(taskID int64) *runningTask {
+	for _, task := range l.tasks {
+		if task.id == taskID {
+			return task
+		}
+	}
+	return nil
+}
+
+// removeTask removes the task from the running tasks, if it is not removed
+// yet.
+func (l *Lightning) removeTask(task *runningTask) {
+	l.cancelLock.Lock()
+	defer l.cancelLock.Unlock()
+
+	for i, t := range l.tasks {
+		if t == task {
+			l.tasks = append(l.tasks[:i], l.tasks[i+1:]...)
+			return
+		}
+	}
 }
 
 func (l *Lightning) Stop() {
 	l.cancelLock.Lock()
-	if l.cancel != nil {
-		l.cancel()
+	for _, task := range l.tasks {
+		task.cancel()
 	}
 	l.cancelLock.Unlock()
 	if err := l.server.Shutdown(l.ctx); err != nil {
@@ -386,8 +512,10 @@ func (l *Lightning) handleTask(w http.ResponseWriter, req *http.Request) {
 
 func (l *Lightning) handleGetTask(w http.ResponseWriter) {
 	var response struct {
-		Current   *int64  `json:"current"`
-		QueuedIDs []int64 `json:"queue"`
+		// Current is the task started first among the running tasks.
+		Current    *int64  `json:"current"`
+		RunningIDs []int64 `json:"running"`
+		QueuedIDs  []int64 `json:"queue"`
 	}
 
 	if l.taskCfgs != nil {
@@ -397,11 +525,14 @@ func (l *Lightning) handleGetTask(w http.ResponseWriter) {
 	}
 
 	l.cancelLock.Lock()
-	if l.cancel != nil && l.curTask != nil {
-		response.Current = new(int64)
-		*response.Current = l.curTask.TaskID
+	response.RunningIDs = make([]int64, 0, len(l.tasks))
+	for _, task := range l.tasks {
+		response.RunningIDs = append(response.RunningIDs, task.id)
 	}
 	l.cancelLock.Unlock()
+	if len(response.RunningIDs) > 0 {
+		response.Current = &response.RunningIDs[0]
+	}
 
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(response)
@@ -411,8 +542,8 @@ func (l *Lightning) handleGetOneTask(w http.ResponseWriter, req *http.Request, t
 	var task *config.Config
 
 	l.cancelLock.Lock()
-	if l.curTask != nil && l.curTask.TaskID == taskID {
-		task = l.curTask
+	if running := l.findTask(taskID); running != nil {
+		task = running.cfg
 	}
 	l.cancelLock.Unlock()
 
@@ -448,30 +579,84 @@ func (l *Lightning) handlePostTask(w http.ResponseWriter, req *http.Request) {
 		ID int64 `json:"id"`
 	}
 
+	cfg := l.readTaskConfig(w, req)
+	if cfg == nil {
+		return
+	}
+
+	l.taskCfgs.Push(cfg)
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(taskResponse{ID: cfg.TaskID})
+}
+
+// readTaskConfig reads the task config in TOML from the request body. It writes
+// the error response and returns nil if the config is invalid.
+func (l *Lightning) readTaskConfig(w http.ResponseWriter, req *http.Request) *config.Config {
 	data, err := io.ReadAll(req.Body)
 	if err != nil {
 		writeJSONError(w, http.StatusBadRequest, "cannot read request", err)
-		return
+		return nil
 	}
 	log.L().Debug("received task config", zap.ByteString("content", data))
 
 	cfg := config.NewConfig()
 	if err = cfg.LoadFromGlobal(l.globalCfg); err != nil {
 		writeJSONError(w, http.StatusInternalServerError, "cannot restore from global config", err)
-		return
+		return nil
 	}
+	cfg.DivideResources(l.globalCfg.App.MaxConcurrentTasks)
 	if err = cfg.LoadFromTOML(data); err != nil {
 		writeJSONError(w, http.StatusBadRequest, "cannot parse task (must be TOML)", err)
-		return
+		return nil
 	}
 	if err = cfg.Adjust(l.ctx); err != nil {
 		writeJSONError(w, http.StatusBadRequest, "invalid task configuration", err)
+		return nil
+	}
+	return cfg
+}
+
+// defaultPreviewRowCount is the number of rows of each table previewed by
+// default in the precheck.
+const defaultPreviewRowCount = 10
+
+func (l *Lightning) handlePreCheck(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only POST is allowed", nil)
+		return
+	}
+	if l.taskCfgs == nil {
+		// the task config is based on the global config of the server mode.
+		writeJSONError(w, http.StatusNotImplemented, "server-mode not enabled", nil)
 		return
 	}
 
-	l.taskCfgs.Push(cfg)
+	previewRowCount := defaultPreviewRowCount
+	if rows := req.URL.Query().Get("rows"); rows != "" {
+		n, err := strconv.Atoi(rows)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "invalid row count", err)
+			return
+		}
+		previewRowCount = n
+	}
+
+	cfg := l.readTaskConfig(w, req)
+	if cfg == nil {
+		return
+	}
+
+	result, err := l.PreCheck(req.Context(), cfg, previewRowCount)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "precheck failed", err)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(taskResponse{ID: cfg.TaskID})
+	_ = json.NewEncoder(w).Encode(result)
 }
 
 func (l *Lightning) handleDeleteOneTask(w http.ResponseWriter, req *http.Request) {
@@ -487,9 +672,15 @@ func (l *Lightning) handleDeleteOneTask(w http.ResponseWriter, req *http.Request
 	cancelSuccess := false
 
 	l.cancelLock.Lock()
-	if l.cancel != nil && l.curTask != nil && l.curTask.TaskID == taskID {
-		cancel = l.cancel
-		l.cancel = nil
+	if task := l.findTask(taskID); task != nil {
+		cancel = task.cancel
+		// the task is no longer reported as running once canceled.
+		for i, t := range l.tasks {
+			if t == task {
+				l.tasks = append(l.tasks[:i], l.tasks[i+1:]...)
+				break
+			}
+		}
 	}
 	l.cancelLock.Unlock()
 
@@ -541,6 +732,87 @@ func (l *Lightning) handlePatchOneTask(w http.ResponseWriter, req *http.Request)
 	}
 }
 
+// handleTable pauses, resumes, cancels or reprioritizes a table of a running
+// task, on `PUT /tables/<table>/<verb>?task=<task ID>` where the table is in
+// the form "`db`.`tbl`". The task ID can be omitted if only one task is running.
+func (l *Lightning) handleTable(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Method != http.MethodPut {
+		w.Header().Set("Allow", http.MethodPut)
+		writeJSONError(w, http.StatusMethodNotAllowed, "only PUT is allowed", nil)
+		return
+	}
+	if l.taskCfgs == nil {
+		writeJSONError(w, http.StatusNotImplemented, "server-mode not enabled", nil)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "invalid table control, must be /tables/<table>/<verb>", nil)
+		return
+	}
+	tableName, verb := path[:i], path[i+1:]
+
+	taskID, err := parseTaskIDQuery(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+		return
+	}
+	var controller *restore.Controller
+	l.cancelLock.Lock()
+	switch {
+	case taskID != 0:
+		if task := l.findTask(taskID); task != nil {
+			controller = task.controller
+		}
+	case len(l.tasks) == 1:
+		controller = l.tasks[0].controller
+	case len(l.tasks) > 1:
+		l.cancelLock.Unlock()
+		writeJSONError(w, http.StatusBadRequest, "task ID must be specified when multiple tasks are running", nil)
+		return
+	}
+	l.cancelLock.Unlock()
+	if controller == nil {
+		writeJSONError(w, http.StatusNotFound, "no running task", nil)
+		return
+	}
+
+	switch verb {
+	case "pause":
+		err = controller.PauseTable(tableName)
+	case "resume":
+		err = controller.ResumeTable(tableName)
+	case "cancel":
+		err = controller.CancelTable(tableName)
+	case "priority":
+		var priority struct {
+			Priority int32 `json:"priority"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&priority); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid priority", err)
+			return
+		}
+		err = controller.SetTablePriority(tableName, priority.Priority)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "unknown table action", nil)
+		return
+	}
+
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{}"))
+	case errors.IsNotFound(err):
+		writeJSONError(w, http.StatusNotFound, "table not found", err)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "cannot "+verb+" table", err)
+	}
+}
+
 func writeBytesCompressed(w http.ResponseWriter, req *http.Request, b []byte) {
 	if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
 		_, _ = w.Write(b)
@@ -554,21 +826,47 @@ func writeBytesCompressed(w http.ResponseWriter, req *http.Request, b []byte) {
 	_ = gw.Close()
 }
 
+// parseTaskIDQuery parses the optional task ID in the `task` query parameter,
+// which is zero if not given.
+func parseTaskIDQuery(req *http.Request) (int64, error) {
+	taskIDString := req.URL.Query().Get("task")
+	if len(taskIDString) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(taskIDString, 10, 64)
+}
+
+// handleProgressTask reports the progress of the task given by the `task`
+// query parameter, or of the task started most recently.
 func handleProgressTask(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	res, err := web.MarshalTaskProgress()
+	taskID, err := parseTaskIDQuery(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+		return
+	}
+	res, err := web.MarshalTaskProgress(taskID)
 	if err == nil {
 		writeBytesCompressed(w, req, res)
 	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+		if errors.IsNotFound(err) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		_ = json.NewEncoder(w).Encode(err.Error())
 	}
 }
 
 func handleProgressTable(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	taskID, err := parseTaskIDQuery(req)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid task ID", err)
+		return
+	}
 	tableName := req.URL.Query().Get("t")
-	res, err := web.MarshalTableCheckpoints(tableName)
+	res, err := web.MarshalTableCheckpoints(taskID, tableName)
 	if err == nil {
 		writeBytesCompressed(w, req, res)
 	} else {
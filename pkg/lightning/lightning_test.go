@@ -27,7 +27,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
@@ -227,8 +229,11 @@ func (s *lightningServerSuite) TestGetDeleteTask(c *C) {
 		return
 	}
 
+	// the scan concurrency is set so that adjusting the config skips querying PD.
 	postTask := func(i int) int64 {
 		resp, err := http.Post(url, "application/toml", strings.NewReader(fmt.Sprintf(`
+			[tidb]
+			distsql-scan-concurrency = 16
 			[mydumper]
 			data-source-dir = 'file://demo-path-%d'
 		`, i)))
@@ -364,6 +369,109 @@ func (s *lightningServerSuite) TestGetDeleteTask(c *C) {
 	})
 }
 
+func (s *lightningServerSuite) TestRunConcurrentTasks(c *C) {
+	s.lightning.globalCfg.App.MaxConcurrentTasks = 2
+	url := "http://" + s.lightning.serverAddr.String() + "/tasks"
+
+	type getAllResultType struct {
+		Current int64
+		Running []int64
+		Queue   []int64
+	}
+
+	getAllTasks := func() (result getAllResultType) {
+		resp, err := http.Get(url)
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, http.StatusOK)
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		return
+	}
+
+	// waitTasks polls the tasks until they are in the expected state.
+	waitTasks := func(expected getAllResultType) {
+		var result getAllResultType
+		for i := 0; i < 100; i++ {
+			if result = getAllTasks(); reflect.DeepEqual(result, expected) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		c.Assert(result, DeepEquals, expected)
+	}
+
+	// the scan concurrency is set so that adjusting the config skips querying
+	// PD, and the tasks wait in the SkipRunTask failpoint until canceled.
+	postTask := func() int64 {
+		resp, err := http.Post(url, "application/toml", strings.NewReader(fmt.Sprintf(`
+			[tidb]
+			distsql-scan-concurrency = 16
+			[mydumper]
+			data-source-dir = '%s'
+		`, c.MkDir())))
+		c.Assert(err, IsNil)
+		c.Assert(resp.StatusCode, Equals, http.StatusOK)
+		var result struct{ ID int64 }
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		return result.ID
+	}
+
+	go func() {
+		_ = s.lightning.RunServer()
+	}()
+	time.Sleep(500 * time.Millisecond)
+
+	first := postTask()
+	second := postTask()
+	third := postTask()
+
+	// Check the first two tasks are running together.
+
+	waitTasks(getAllResultType{
+		Current: first,
+		Running: []int64{first, second},
+		Queue:   []int64{third},
+	})
+
+	// Check each task gets its share of the resources.
+
+	var resCfg config.Config
+	resp, err := http.Get(fmt.Sprintf("%s/%d", url, second))
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	err = json.NewDecoder(resp.Body).Decode(&resCfg)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(resCfg.App.IOConcurrency, Equals, 2)
+
+	// Check the table control requires the task ID.
+
+	req, err := http.NewRequest(http.MethodPut, "http://"+s.lightning.serverAddr.String()+"/tables/`db`.`tbl`/pause", nil)
+	c.Assert(err, IsNil)
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusBadRequest)
+	resp.Body.Close()
+
+	// Cancel a running task, then the queued task starts.
+
+	req.Method = http.MethodDelete
+	req.URL.Path = fmt.Sprintf("/tasks/%d", first)
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	resp.Body.Close()
+
+	waitTasks(getAllResultType{
+		Current: second,
+		Running: []int64{second, third},
+		Queue:   []int64{},
+	})
+}
+
 func (s *lightningServerSuite) TestHTTPAPIOutsideServerMode(c *C) {
 	s.lightning.globalCfg.App.ServerMode = false
 
@@ -419,6 +527,14 @@ func (s *lightningServerSuite) TestHTTPAPIOutsideServerMode(c *C) {
 	c.Assert(resp.StatusCode, Equals, http.StatusNotImplemented)
 	resp.Body.Close()
 
+	// `PUT /tables/(table)/pause` should return 501
+	req.Method = http.MethodPut
+	req.URL.Path = "/tables/`db`.`tbl`/pause"
+	resp, err = http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNotImplemented)
+	resp.Body.Close()
+
 	// `DELETE /tasks/123456` should return 404
 	req.Method = http.MethodDelete
 	req.URL.Path = "/tasks/123456"
@@ -437,6 +553,121 @@ func (s *lightningServerSuite) TestHTTPAPIOutsideServerMode(c *C) {
 	c.Assert(<-errCh, Equals, context.Canceled)
 }
 
+func (s *lightningServerSuite) TestHTTPAPITable(c *C) {
+	s.lightning.taskCfgs = config.NewConfigList()
+	baseURL := "http://" + s.lightning.serverAddr.String() + "/tables/"
+
+	do := func(method, path string, body string) int {
+		req, err := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		c.Assert(err, IsNil)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		c.Assert(resp.Body.Close(), IsNil)
+		return resp.StatusCode
+	}
+
+	tablePath := url.PathEscape("`db`.`tbl`")
+	// only PUT is allowed.
+	c.Assert(do(http.MethodGet, tablePath+"/pause", ""), Equals, http.StatusMethodNotAllowed)
+	// no task is running.
+	c.Assert(do(http.MethodPut, tablePath+"/pause", ""), Equals, http.StatusNotFound)
+	c.Assert(do(http.MethodPut, tablePath+"/priority", `{"priority":3}`), Equals, http.StatusNotFound)
+	// the action is required.
+	c.Assert(do(http.MethodPut, "tbl", ""), Equals, http.StatusBadRequest)
+	// the task ID must be a number.
+	c.Assert(do(http.MethodPut, tablePath+"/pause?task=abc", ""), Equals, http.StatusBadRequest)
+	c.Assert(do(http.MethodPut, tablePath+"/pause?task=123456", ""), Equals, http.StatusNotFound)
+}
+
+func (s *lightningServerSuite) TestHTTPAPIProgress(c *C) {
+	baseURL := "http://" + s.lightning.serverAddr.String() + "/progress/"
+
+	get := func(path string) int {
+		resp, err := http.Get(baseURL + path)
+		c.Assert(err, IsNil)
+		c.Assert(resp.Body.Close(), IsNil)
+		return resp.StatusCode
+	}
+
+	c.Assert(get("task"), Equals, http.StatusOK)
+	c.Assert(get("task?task=abc"), Equals, http.StatusBadRequest)
+	c.Assert(get("task?task=123456"), Equals, http.StatusNotFound)
+	c.Assert(get("table?t=tbl&task=abc"), Equals, http.StatusBadRequest)
+	c.Assert(get("table?t=tbl&task=123456"), Equals, http.StatusNotFound)
+}
+
+func (s *lightningServerSuite) TestHTTPAPIPreCheck(c *C) {
+	url := "http://" + s.lightning.serverAddr.String() + "/precheck"
+
+	post := func(query string, body string) (int, string) {
+		resp, err := http.Post(url+query, "application/toml", strings.NewReader(body))
+		c.Assert(err, IsNil)
+		defer resp.Body.Close()
+		var data map[string]string
+		c.Assert(json.NewDecoder(resp.Body).Decode(&data), IsNil)
+		return resp.StatusCode, data["error"]
+	}
+
+	code, msg := post("", "")
+	c.Assert(code, Equals, http.StatusNotImplemented)
+	c.Assert(msg, Equals, "server-mode not enabled")
+
+	go func() {
+		_ = s.lightning.RunServer()
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get(url)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusMethodNotAllowed)
+	c.Assert(resp.Header.Get("Allow"), Equals, http.MethodPost)
+	resp.Body.Close()
+
+	code, msg = post("?rows=-1", "")
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(msg, Equals, "invalid row count")
+	code, msg = post("?rows=5", "????")
+	c.Assert(code, Equals, http.StatusBadRequest)
+	c.Assert(msg, Matches, "cannot parse task.*")
+}
+
+func (s *lightningServerSuite) TestHTTPAPIAuth(c *C) {
+	cfg := config.NewGlobalConfig()
+	cfg.App.StatusAddr = "127.0.0.1:0"
+	cfg.App.StatusAuth.AdminTokens = []string{"admin-token"}
+	cfg.App.StatusAuth.ReadOnlyTokens = []string{"read-token"}
+	lightning := New(cfg)
+	c.Assert(lightning.GoServe(), IsNil)
+	defer lightning.Stop()
+	baseURL := "http://" + lightning.serverAddr.String()
+
+	do := func(method, path, token string) int {
+		req, err := http.NewRequest(method, baseURL+path, nil)
+		c.Assert(err, IsNil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		c.Assert(resp.Body.Close(), IsNil)
+		return resp.StatusCode
+	}
+
+	c.Assert(do(http.MethodGet, "/tasks", ""), Equals, http.StatusUnauthorized)
+	c.Assert(do(http.MethodGet, "/tasks", "wrong-token"), Equals, http.StatusUnauthorized)
+	c.Assert(do(http.MethodGet, "/tasks", "read-token"), Equals, http.StatusOK)
+	c.Assert(do(http.MethodGet, "/progress/task", "read-token"), Equals, http.StatusOK)
+	c.Assert(do(http.MethodGet, "/metrics", "read-token"), Equals, http.StatusOK)
+	c.Assert(do(http.MethodPut, "/pause", "read-token"), Equals, http.StatusForbidden)
+	c.Assert(do(http.MethodPost, "/loglevel", "read-token"), Equals, http.StatusForbidden)
+	c.Assert(do(http.MethodPost, "/precheck", "read-token"), Equals, http.StatusForbidden)
+	c.Assert(do(http.MethodGet, "/debug/pprof/heap", "read-token"), Equals, http.StatusOK)
+	c.Assert(do(http.MethodGet, "/debug/pprof/cmdline", "read-token"), Equals, http.StatusForbidden)
+	c.Assert(do(http.MethodGet, "/debug/pprof/cmdline", "admin-token"), Equals, http.StatusOK)
+	// the server mode is not enabled.
+	c.Assert(do(http.MethodPost, "/tasks", "admin-token"), Equals, http.StatusNotImplemented)
+}
+
 func (s *lightningServerSuite) TestCheckSystemRequirement(c *C) {
 	if runtime.GOOS == "windows" {
 		c.Skip("Local-backend is not supported on Windows")
@@ -15,6 +15,7 @@ package metric
 
 import (
 	"math"
+	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -41,8 +42,15 @@ const (
 	ChunkStateFinished  = "finished"
 	ChunkStateFailed    = "failed"
 
+	// states used for the RowsCounter labels
+	RowStateFiltered = "filtered"
+
 	BlockDeliverKindIndex = "index"
 	BlockDeliverKindData  = "data"
+
+	// taskLabel distinguishes the progress of the tasks running concurrently
+	// in the server mode.
+	taskLabel = "task_id"
 )
 
 var (
@@ -73,25 +81,31 @@ var (
 			Namespace: "lightning",
 			Name:      "tables",
 			Help:      "count number of tables processed",
-		}, []string{"state", "result"})
+		}, []string{"state", "result", taskLabel})
 	ProcessedEngineCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "lightning",
 			Name:      "engines",
 			Help:      "count number of engines processed",
-		}, []string{"state", "result"})
+		}, []string{"state", "result", taskLabel})
 	ChunkCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "lightning",
 			Name:      "chunks",
 			Help:      "count number of chunks processed",
-		}, []string{"state"})
+		}, []string{"state", taskLabel})
 	BytesCounter = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: "lightning",
 			Name:      "bytes",
 			Help:      "count of total bytes",
-		}, []string{"state"})
+		}, []string{"state", taskLabel})
+	RowsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "lightning",
+			Name:      "rows",
+			Help:      "count of rows not imported",
+		}, []string{"state", taskLabel})
 	// state can be one of:
 	//  - estimated (an estimation derived from the file size)
 	//  - pending
@@ -192,7 +206,7 @@ var (
 		prometheus.GaugeOpts{
 			Namespace: "lightning",
 			Name:      "local_storage_usage_bytes",
-			Help:      "disk/memory size currently occupied by intermediate files in local backend",
+			Help:      "disk/memory/remote size currently occupied by intermediate files in local backend",
 		}, []string{"medium"},
 	)
 )
@@ -206,6 +220,7 @@ func init() {
 	prometheus.MustRegister(ProcessedEngineCounter)
 	prometheus.MustRegister(ChunkCounter)
 	prometheus.MustRegister(BytesCounter)
+	prometheus.MustRegister(RowsCounter)
 	prometheus.MustRegister(ImportSecondsHistogram)
 	prometheus.MustRegister(RowReadSecondsHistogram)
 	prometheus.MustRegister(RowReadBytesHistogram)
@@ -220,24 +235,91 @@ func init() {
 	prometheus.MustRegister(LocalStorageUsageBytesGauge)
 }
 
-func RecordTableCount(status string, err error) {
+// Metrics are the counters of the progress of a single task.
+type Metrics struct {
+	TableCounter           *prometheus.CounterVec
+	ProcessedEngineCounter *prometheus.CounterVec
+	ChunkCounter           *prometheus.CounterVec
+	BytesCounter           *prometheus.CounterVec
+	RowsCounter            *prometheus.CounterVec
+
+	taskID string
+}
+
+// NewMetrics returns the counters of the task, which are labeled with the task
+// ID.
+func NewMetrics(taskID int64) *Metrics {
+	id := strconv.FormatInt(taskID, 10)
+	labels := prometheus.Labels{taskLabel: id}
+	return &Metrics{
+		TableCounter:           TableCounter.MustCurryWith(labels),
+		ProcessedEngineCounter: ProcessedEngineCounter.MustCurryWith(labels),
+		ChunkCounter:           ChunkCounter.MustCurryWith(labels),
+		BytesCounter:           BytesCounter.MustCurryWith(labels),
+		RowsCounter:            RowsCounter.MustCurryWith(labels),
+		taskID:                 id,
+	}
+}
+
+// Delete removes the series of the task after the task ends, so that the
+// server running many tasks doesn't keep the series of all of them.
+func (m *Metrics) Delete() {
+	for _, vec := range []*prometheus.CounterVec{
+		TableCounter,
+		ProcessedEngineCounter,
+		ChunkCounter,
+		BytesCounter,
+		RowsCounter,
+	} {
+		deleteTaskSeries(vec, m.taskID)
+	}
+}
+
+// deleteTaskSeries deletes the series of vec labeled with the task ID.
+func deleteTaskSeries(vec *prometheus.CounterVec, taskID string) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+	// the series are deleted after collecting, as Collect holds the lock of vec.
+	var matched []prometheus.Labels
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			continue
+		}
+		labels := make(prometheus.Labels, len(pb.Label))
+		for _, pair := range pb.Label {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		if labels[taskLabel] == taskID {
+			matched = append(matched, labels)
+		}
+	}
+	for _, labels := range matched {
+		vec.Delete(labels)
+	}
+}
+
+func (m *Metrics) RecordTableCount(status string, err error) {
 	var result string
 	if err != nil {
 		result = TableResultFailure
 	} else {
 		result = TableResultSuccess
 	}
-	TableCounter.WithLabelValues(status, result).Inc()
+	m.TableCounter.WithLabelValues(status, result).Inc()
 }
 
-func RecordEngineCount(status string, err error) {
+func (m *Metrics) RecordEngineCount(status string, err error) {
 	var result string
 	if err != nil {
 		result = TableResultFailure
 	} else {
 		result = TableResultSuccess
 	}
-	ProcessedEngineCounter.WithLabelValues(status, result).Inc()
+	m.ProcessedEngineCounter.WithLabelValues(status, result).Inc()
 }
 
 // ReadCounter reports the current value of the counter.
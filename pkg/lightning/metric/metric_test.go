@@ -50,12 +50,32 @@ func (s *testMetricSuite) TestReadHistogramSum(c *C) {
 }
 
 func (s *testMetricSuite) TestRecordEngineCount(c *C) {
-	metric.RecordEngineCount("table1", nil)
-	metric.RecordEngineCount("table1", errors.New("mock error"))
-	successCounter, err := metric.ProcessedEngineCounter.GetMetricWithLabelValues("table1", "success")
+	m := metric.NewMetrics(1)
+	m.RecordEngineCount("table1", nil)
+	m.RecordEngineCount("table1", errors.New("mock error"))
+	successCounter, err := m.ProcessedEngineCounter.GetMetricWithLabelValues("table1", "success")
 	c.Assert(err, IsNil)
 	c.Assert(metric.ReadCounter(successCounter), Equals, 1.0)
-	failureCount, err := metric.ProcessedEngineCounter.GetMetricWithLabelValues("table1", "failure")
+	failureCount, err := m.ProcessedEngineCounter.GetMetricWithLabelValues("table1", "failure")
 	c.Assert(err, IsNil)
 	c.Assert(metric.ReadCounter(failureCount), Equals, 1.0)
+
+	// the counters of other tasks are separated.
+	otherCounter, err := metric.NewMetrics(2).ProcessedEngineCounter.GetMetricWithLabelValues("table1", "success")
+	c.Assert(err, IsNil)
+	c.Assert(metric.ReadCounter(otherCounter), Equals, 0.0)
+}
+
+func (s *testMetricSuite) TestDelete(c *C) {
+	m := metric.NewMetrics(3)
+	m.RecordTableCount(metric.TableStateCompleted, nil)
+	m.ChunkCounter.WithLabelValues(metric.ChunkStateFinished).Add(2)
+	other := metric.NewMetrics(4)
+	other.ChunkCounter.WithLabelValues(metric.ChunkStateFinished).Add(3)
+
+	m.Delete()
+	c.Assert(metric.ReadCounter(m.ChunkCounter.WithLabelValues(metric.ChunkStateFinished)), Equals, 0.0)
+	c.Assert(metric.ReadCounter(m.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess)), Equals, 0.0)
+	c.Assert(metric.ReadCounter(other.ChunkCounter.WithLabelValues(metric.ChunkStateFinished)), Equals, 3.0)
+	other.Delete()
 }
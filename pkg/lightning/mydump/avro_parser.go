@@ -0,0 +1,813 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	tidbjson "github.com/pingcap/tidb/types/json"
+
+	"github.com/pingcap/br/pkg/lightning/log"
+	"github.com/pingcap/br/pkg/storage"
+)
+
+// The Avro object container file format.
+//
+// See: https://avro.apache.org/docs/current/spec.html#Object+Container+Files
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+const (
+	avroSyncSize = 16
+
+	avroCodecNull    = "null"
+	avroCodecDeflate = "deflate"
+	avroCodecSnappy  = "snappy"
+)
+
+// avroSchema is a parsed Avro schema.
+type avroSchema struct {
+	// Type is a primitive type name, or one of record, enum, array, map, fixed
+	// and union.
+	Type        string
+	Fields      []*avroField
+	Symbols     []string
+	Items       *avroSchema
+	Values      *avroSchema
+	Size        int
+	Branches    []*avroSchema
+	LogicalType string
+	Scale       int
+}
+
+type avroField struct {
+	Name   string
+	Schema *avroSchema
+}
+
+// parseAvroSchema parses the JSON representation of an Avro schema. The named
+// types are registered into `names` so they can be referenced later.
+func parseAvroSchema(v interface{}, names map[string]*avroSchema) (*avroSchema, error) {
+	switch s := v.(type) {
+	case string:
+		switch s {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{Type: s}, nil
+		}
+		if named, ok := names[s]; ok {
+			return named, nil
+		}
+		return nil, errors.Errorf("unknown avro type '%s'", s)
+	case []interface{}:
+		union := &avroSchema{Type: "union", Branches: make([]*avroSchema, 0, len(s))}
+		for _, branch := range s {
+			schema, err := parseAvroSchema(branch, names)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, schema)
+		}
+		return union, nil
+	case map[string]interface{}:
+		typeName, _ := s["type"].(string)
+		schema := &avroSchema{Type: typeName}
+		if logicalType, ok := s["logicalType"].(string); ok {
+			schema.LogicalType = logicalType
+		}
+		if scale, ok := s["scale"].(float64); ok {
+			schema.Scale = int(scale)
+		}
+		if name, ok := s["name"].(string); ok {
+			if ns, ok := s["namespace"].(string); ok && ns != "" && !strings.Contains(name, ".") {
+				names[ns+"."+name] = schema
+			}
+			names[name] = schema
+		}
+		switch typeName {
+		case "record", "error":
+			schema.Type = "record"
+			fields, _ := s["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["name"].(string)
+				fieldSchema, err := parseAvroSchema(field["type"], names)
+				if err != nil {
+					return nil, errors.Annotatef(err, "in field '%s'", name)
+				}
+				schema.Fields = append(schema.Fields, &avroField{Name: name, Schema: fieldSchema})
+			}
+		case "enum":
+			symbols, _ := s["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				name, _ := symbol.(string)
+				schema.Symbols = append(schema.Symbols, name)
+			}
+		case "array":
+			items, err := parseAvroSchema(s["items"], names)
+			if err != nil {
+				return nil, err
+			}
+			schema.Items = items
+		case "map":
+			values, err := parseAvroSchema(s["values"], names)
+			if err != nil {
+				return nil, err
+			}
+			schema.Values = values
+		case "fixed":
+			size, _ := s["size"].(float64)
+			schema.Size = int(size)
+		default:
+			// a primitive type with attributes, e.g. a logical type.
+			primitive, err := parseAvroSchema(typeName, names)
+			if err != nil {
+				return nil, err
+			}
+			schema.Type = primitive.Type
+		}
+		return schema, nil
+	default:
+		return nil, errors.Errorf("invalid avro schema %v", v)
+	}
+}
+
+// avroDecoder decodes the values of Avro binary encoding.
+type avroDecoder struct {
+	r *bytes.Reader
+}
+
+func (d *avroDecoder) readLong() (int64, error) {
+	v, err := binary.ReadVarint(d.r)
+	return v, errors.Trace(err)
+}
+
+func (d *avroDecoder) readBytes() ([]byte, error) {
+	n, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > int64(d.r.Len()) {
+		return nil, errors.Errorf("invalid avro bytes length %d", n)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(d.r, b)
+	return b, errors.Trace(err)
+}
+
+func (d *avroDecoder) readFixed(size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := io.ReadFull(d.r, b)
+	return b, errors.Trace(err)
+}
+
+// readBlockCount reads the item count of a block of an array or a map, whose
+// items take at least itemSize bytes each. The count is bounded by the bytes
+// left in the data block, so a corrupted count fails rather than allocating
+// or looping without limit. The items taking no bytes, e.g. the nulls, are
+// bounded as if they took one byte each.
+func (d *avroDecoder) readBlockCount(itemSize int64) (int64, error) {
+	count, err := d.readLong()
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		// a negative count is followed by the size of the block in bytes.
+		count = -count
+		size, err := d.readLong()
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 || size > int64(d.r.Len()) {
+			return 0, errors.Errorf("invalid avro block size %d", size)
+		}
+	}
+	if itemSize < 1 {
+		itemSize = 1
+	}
+	if count < 0 || count > int64(d.r.Len())/itemSize {
+		return 0, errors.Errorf("invalid avro block count %d", count)
+	}
+	return count, nil
+}
+
+// avroMinSize returns the minimum number of bytes of the encoded values of the
+// schema.
+func avroMinSize(s *avroSchema) int64 {
+	switch s.Type {
+	case "null":
+		return 0
+	case "float":
+		return 4
+	case "double":
+		return 8
+	case "fixed":
+		return int64(s.Size)
+	case "record":
+		var size int64
+		for _, field := range s.Fields {
+			size += avroMinSize(field.Schema)
+		}
+		return size
+	default:
+		// boolean, int, long, bytes, string, enum and union take at least one
+		// byte, and so do the empty arrays and maps.
+		return 1
+	}
+}
+
+// decode decodes a value of the schema into nil, bool, int64, float64,
+// string, []byte, []interface{} or map[string]interface{}. The values of the
+// logical types decimal, date, time and timestamp are formatted as strings.
+func (d *avroDecoder) decode(s *avroSchema) (interface{}, error) {
+	switch s.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.r.ReadByte()
+		return b != 0, errors.Trace(err)
+	case "int", "long":
+		v, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		return formatAvroLogicalInt(v, s.LogicalType), nil
+	case "float":
+		b, err := d.readFixed(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+	case "double":
+		b, err := d.readFixed(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case "string":
+		b, err := d.readBytes()
+		return string(b), err
+	case "bytes", "fixed":
+		var b []byte
+		var err error
+		if s.Type == "fixed" {
+			b, err = d.readFixed(s.Size)
+		} else {
+			b, err = d.readBytes()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.LogicalType == "decimal" {
+			if len(b) == 0 {
+				return "0", nil
+			}
+			return binaryToDecimalStr(b, s.Scale), nil
+		}
+		return b, nil
+	case "enum":
+		index, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(s.Symbols)) {
+			return nil, errors.Errorf("invalid avro enum index %d", index)
+		}
+		return s.Symbols[index], nil
+	case "union":
+		index, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || index >= int64(len(s.Branches)) {
+			return nil, errors.Errorf("invalid avro union index %d", index)
+		}
+		return d.decode(s.Branches[index])
+	case "array":
+		items := make([]interface{}, 0)
+		for {
+			count, err := d.readBlockCount(avroMinSize(s.Items))
+			if err != nil || count == 0 {
+				return items, err
+			}
+			for i := int64(0); i < count; i++ {
+				item, err := d.decode(s.Items)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+		}
+	case "map":
+		values := make(map[string]interface{})
+		for {
+			// the key takes at least one byte of its length.
+			count, err := d.readBlockCount(1 + avroMinSize(s.Values))
+			if err != nil || count == 0 {
+				return values, err
+			}
+			for i := int64(0); i < count; i++ {
+				key, err := d.readBytes()
+				if err != nil {
+					return nil, err
+				}
+				if values[string(key)], err = d.decode(s.Values); err != nil {
+					return nil, err
+				}
+			}
+		}
+	case "record":
+		values := make(map[string]interface{}, len(s.Fields))
+		for _, field := range s.Fields {
+			value, err := d.decode(field.Schema)
+			if err != nil {
+				return nil, err
+			}
+			values[field.Name] = value
+		}
+		return values, nil
+	default:
+		return nil, errors.Errorf("unsupported avro type '%s'", s.Type)
+	}
+}
+
+// formatAvroLogicalInt formats the int or long value of the date, time and
+// timestamp logical types, the same as the Parquet parser does.
+func formatAvroLogicalInt(v int64, logicalType string) interface{} {
+	switch logicalType {
+	case "date":
+		return time.Unix(v*86400, 0).UTC().Format("2006-01-02")
+	case "time-millis":
+		return time.Unix(0, v*int64(time.Millisecond)).UTC().Format("15:04:05.999999")
+	case "time-micros":
+		return time.Unix(0, v*int64(time.Microsecond)).UTC().Format("15:04:05.999999")
+	case "timestamp-millis":
+		return time.Unix(v/1e3, (v%1e3)*1e6).UTC().Format("2006-01-02 15:04:05.999999Z")
+	case "timestamp-micros":
+		return time.Unix(v/1e6, (v%1e6)*1e3).UTC().Format("2006-01-02 15:04:05.999999Z")
+	case "local-timestamp-millis":
+		return time.Unix(v/1e3, (v%1e3)*1e6).UTC().Format("2006-01-02 15:04:05.999999")
+	case "local-timestamp-micros":
+		return time.Unix(v/1e6, (v%1e6)*1e3).UTC().Format("2006-01-02 15:04:05.999999")
+	default:
+		return v
+	}
+}
+
+// setAvroDatum converts a decoded Avro value to a datum. The nested records,
+// arrays and maps are converted to JSON.
+func setAvroDatum(d *types.Datum, v interface{}) error {
+	switch value := v.(type) {
+	case nil:
+		// the datum is reused, so reset all the fields.
+		*d = types.Datum{}
+	case bool:
+		if value {
+			d.SetInt64(1)
+		} else {
+			d.SetInt64(0)
+		}
+	case int64:
+		d.SetInt64(value)
+	case float64:
+		d.SetFloat64(value)
+	case string:
+		d.SetString(value, "utf8mb4_bin")
+	case []byte:
+		d.SetBytes(value)
+	default:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		bj, err := tidbjson.ParseBinaryFromString(string(content))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		d.SetMysqlJSON(bj)
+	}
+	return nil
+}
+
+// avroFileReader reads an Avro object container file and tracks the offset.
+type avroFileReader struct {
+	r   storage.ReadSeekCloser
+	buf *bufio.Reader
+	pos int64
+}
+
+func newAvroFileReader(r storage.ReadSeekCloser) *avroFileReader {
+	return &avroFileReader{r: r, buf: bufio.NewReader(r)}
+}
+
+func (r *avroFileReader) ReadByte() (byte, error) {
+	b, err := r.buf.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return b, err
+}
+
+func (r *avroFileReader) readFull(b []byte) error {
+	n, err := io.ReadFull(r.buf, b)
+	r.pos += int64(n)
+	return errors.Trace(err)
+}
+
+func (r *avroFileReader) readLong() (int64, error) {
+	return binary.ReadVarint(r)
+}
+
+func (r *avroFileReader) readBytes() ([]byte, error) {
+	n, err := r.readLong()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if n < 0 {
+		return nil, errors.Errorf("invalid avro bytes length %d", n)
+	}
+	return r.readN(n)
+}
+
+// readN reads n bytes. The buffer grows as the bytes are read, so a corrupted
+// length fails at the end of the file rather than allocating n bytes at once.
+func (r *avroFileReader) readN(n int64) ([]byte, error) {
+	var b bytes.Buffer
+	m, err := io.CopyN(&b, r.buf, n)
+	r.pos += m
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b.Bytes(), errors.Trace(err)
+}
+
+// seek moves to the file offset, the buffered data is reused if possible.
+func (r *avroFileReader) seek(pos int64) error {
+	if pos >= r.pos && pos-r.pos <= int64(r.buf.Buffered()) {
+		n, err := r.buf.Discard(int(pos - r.pos))
+		r.pos += int64(n)
+		return errors.Trace(err)
+	}
+	if _, err := r.r.Seek(pos, io.SeekStart); err != nil {
+		return errors.Trace(err)
+	}
+	r.buf.Reset(r.r)
+	r.pos = pos
+	return nil
+}
+
+// avroHeader is the header of an Avro object container file.
+type avroHeader struct {
+	schema *avroSchema
+	codec  string
+	sync   [avroSyncSize]byte
+	// dataOffset is the offset of the first block.
+	dataOffset int64
+}
+
+func readAvroHeader(r *avroFileReader) (*avroHeader, error) {
+	magic := make([]byte, len(avroMagic))
+	if err := r.readFull(magic); err != nil {
+		return nil, errors.Annotate(err, "read avro magic failed")
+	}
+	if !bytes.Equal(magic, avroMagic) {
+		return nil, errors.New("not an avro object container file")
+	}
+
+	meta := make(map[string][]byte)
+	for {
+		count, err := r.readLong()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			count = -count
+			if _, err = r.readLong(); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if count < 0 {
+			return nil, errors.Errorf("invalid avro metadata count %d", count)
+		}
+		// every entry reads at least the lengths of the key and the value, so
+		// a corrupted count fails at the end of the file.
+		for i := int64(0); i < count; i++ {
+			key, err := r.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			if meta[string(key)], err = r.readBytes(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	header := &avroHeader{codec: string(meta["avro.codec"])}
+	if header.codec == "" {
+		header.codec = avroCodecNull
+	}
+	switch header.codec {
+	case avroCodecNull, avroCodecDeflate, avroCodecSnappy:
+	default:
+		return nil, errors.Errorf("unsupported avro codec '%s'", header.codec)
+	}
+	var schemaJSON interface{}
+	if err := json.Unmarshal(meta["avro.schema"], &schemaJSON); err != nil {
+		return nil, errors.Annotate(err, "invalid avro schema")
+	}
+	schema, err := parseAvroSchema(schemaJSON, make(map[string]*avroSchema))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if schema.Type != "record" {
+		return nil, errors.Errorf("the avro schema should be a record, but got '%s'", schema.Type)
+	}
+	header.schema = schema
+	if err = r.readFull(header.sync[:]); err != nil {
+		return nil, err
+	}
+	header.dataOffset = r.pos
+	return header, nil
+}
+
+// AvroBlock is a data block of an Avro object container file.
+type AvroBlock struct {
+	// Offset is the file offset of the block.
+	Offset int64
+	// Size is the size of the block in bytes, including the headers and the sync marker.
+	Size int64
+	// Rows is the number of rows in the block.
+	Rows int64
+}
+
+// readAvroBlockHeader reads the row count and the data size of the next block.
+func readAvroBlockHeader(r *avroFileReader) (rows int64, size int64, err error) {
+	if rows, err = r.readLong(); err != nil {
+		return 0, 0, err
+	}
+	if size, err = r.readLong(); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if rows < 0 || size < 0 {
+		return 0, 0, errors.Errorf("invalid avro block at offset %d", r.pos)
+	}
+	return rows, size, nil
+}
+
+// ReadAvroFileBlocks reads the block headers of an Avro object container file
+// without decoding the data.
+func ReadAvroFileBlocks(ctx context.Context, store storage.ExternalStorage, path string) ([]AvroBlock, error) {
+	fr, err := store.Open(ctx, path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer fr.Close()
+
+	r := newAvroFileReader(fr)
+	header, err := readAvroHeader(r)
+	if err != nil {
+		return nil, errors.Annotatef(err, "file %s", path)
+	}
+	var blocks []AvroBlock
+	for {
+		offset := r.pos
+		rows, size, err := readAvroBlockHeader(r)
+		if errors.Cause(err) == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, errors.Annotatef(err, "file %s", path)
+		}
+		end := r.pos + size + avroSyncSize
+		if err = r.seek(end - avroSyncSize); err != nil {
+			return nil, errors.Trace(err)
+		}
+		var sync [avroSyncSize]byte
+		if err = r.readFull(sync[:]); err != nil {
+			return nil, errors.Annotatef(err, "file %s", path)
+		}
+		if sync != header.sync {
+			return nil, errors.Errorf("file %s: invalid avro sync marker at offset %d", path, end-avroSyncSize)
+		}
+		blocks = append(blocks, AvroBlock{Offset: offset, Size: end - offset, Rows: rows})
+	}
+}
+
+// AvroParser is a parser of the Avro object container files. Like the Parquet
+// parser, the position is the row number of the file.
+type AvroParser struct {
+	reader  *avroFileReader
+	header  *avroHeader
+	columns []string
+
+	// the decoder of the current block and the number of rows left in it.
+	block     avroDecoder
+	blockRows int64
+	// pos is the row number of the next row.
+	pos     int64
+	lastRow Row
+	logger  log.Logger
+}
+
+// NewAvroParser creates a parser of the Avro object container file, which
+// reads the writer schema from the file header.
+func NewAvroParser(r storage.ReadSeekCloser) (*AvroParser, error) {
+	reader := newAvroFileReader(r)
+	header, err := readAvroHeader(reader)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	columns := make([]string, 0, len(header.schema.Fields))
+	for _, field := range header.schema.Fields {
+		columns = append(columns, strings.ToLower(field.Name))
+	}
+	return &AvroParser{
+		reader:  reader,
+		header:  header,
+		columns: columns,
+		block:   avroDecoder{r: bytes.NewReader(nil)},
+		logger:  log.L(),
+	}, nil
+}
+
+// readBlock reads and decompresses the next block of the file.
+func (ap *AvroParser) readBlock() error {
+	rows, size, err := readAvroBlockHeader(ap.reader)
+	if err != nil {
+		return err
+	}
+	data, err := ap.reader.readN(size)
+	if err != nil {
+		return err
+	}
+	var sync [avroSyncSize]byte
+	if err = ap.reader.readFull(sync[:]); err != nil {
+		return err
+	}
+	if sync != ap.header.sync {
+		return errors.Errorf("invalid avro sync marker at offset %d", ap.reader.pos-avroSyncSize)
+	}
+
+	switch ap.header.codec {
+	case avroCodecDeflate:
+		if data, err = io.ReadAll(flate.NewReader(bytes.NewReader(data))); err != nil {
+			return errors.Trace(err)
+		}
+	case avroCodecSnappy:
+		// the compressed data is followed by the 4-byte CRC32 of the uncompressed data.
+		if len(data) < 4 {
+			return errors.New("invalid avro snappy block")
+		}
+		checksum := binary.BigEndian.Uint32(data[len(data)-4:])
+		if data, err = snappy.Decode(nil, data[:len(data)-4]); err != nil {
+			return errors.Trace(err)
+		}
+		if crc32.ChecksumIEEE(data) != checksum {
+			return errors.New("avro snappy block checksum mismatch")
+		}
+	}
+	if minSize := avroMinSize(ap.header.schema); minSize > 0 && rows > int64(len(data))/minSize {
+		return errors.Errorf("invalid avro block row count %d at offset %d", rows, ap.reader.pos)
+	}
+	ap.block.r.Reset(data)
+	ap.blockRows = rows
+	return nil
+}
+
+// Pos returns the row number of the avro file.
+func (ap *AvroParser) Pos() (pos int64, rowID int64) {
+	return ap.pos, ap.lastRow.RowID
+}
+
+// SetPos seeks to the row. The blocks before the row are skipped without
+// decoding.
+func (ap *AvroParser) SetPos(pos int64, rowID int64) error {
+	if pos < ap.pos {
+		if err := ap.reader.seek(ap.header.dataOffset); err != nil {
+			return err
+		}
+		ap.pos = 0
+		ap.blockRows = 0
+	}
+	for ap.pos < pos {
+		if ap.blockRows == 0 {
+			offset := ap.reader.pos
+			rows, size, err := readAvroBlockHeader(ap.reader)
+			if err != nil {
+				return errors.Annotatef(err, "seek to row %d", pos)
+			}
+			if ap.pos+rows <= pos {
+				if err = ap.reader.seek(ap.reader.pos + size + avroSyncSize); err != nil {
+					return err
+				}
+				ap.pos += rows
+				continue
+			}
+			// the row is in this block, read it again to decode the rows.
+			if err = ap.reader.seek(offset); err != nil {
+				return err
+			}
+			if err = ap.readBlock(); err != nil {
+				return err
+			}
+		}
+		if _, err := ap.block.decode(ap.header.schema); err != nil {
+			return err
+		}
+		ap.blockRows--
+		ap.pos++
+	}
+	ap.lastRow.RowID = rowID
+	return nil
+}
+
+// ReadRow reads a row from the datafile.
+func (ap *AvroParser) ReadRow() error {
+	ap.lastRow.RowID++
+	ap.lastRow.Length = 0
+	for ap.blockRows == 0 {
+		if err := ap.readBlock(); err != nil {
+			if errors.Cause(err) == io.EOF {
+				return io.EOF
+			}
+			return errors.Trace(err)
+		}
+	}
+
+	fields := ap.header.schema.Fields
+	if cap(ap.lastRow.Row) < len(fields) {
+		ap.lastRow.Row = make([]types.Datum, len(fields))
+	} else {
+		ap.lastRow.Row = ap.lastRow.Row[:len(fields)]
+	}
+	before := ap.block.r.Len()
+	for i, field := range fields {
+		value, err := ap.block.decode(field.Schema)
+		if err != nil {
+			return errors.Annotatef(err, "decode avro field '%s' of row %d", field.Name, ap.pos)
+		}
+		if err = setAvroDatum(&ap.lastRow.Row[i], value); err != nil {
+			return errors.Annotatef(err, "convert avro field '%s' of row %d", field.Name, ap.pos)
+		}
+	}
+	ap.lastRow.Length = before - ap.block.r.Len()
+	ap.blockRows--
+	ap.pos++
+	return nil
+}
+
+func (ap *AvroParser) Close() error {
+	return ap.reader.r.Close()
+}
+
+func (ap *AvroParser) LastRow() Row {
+	return ap.lastRow
+}
+
+// RowText implements Parser. The rows have no text in a binary file.
+func (ap *AvroParser) RowText() []byte {
+	return nil
+}
+
+func (ap *AvroParser) RecycleRow(row Row) {
+}
+
+// Columns returns the _lower-case_ column names corresponding to values in
+// the LastRow.
+func (ap *AvroParser) Columns() []string {
+	return ap.columns
+}
+
+// SetColumns set restored column names to parser
+func (ap *AvroParser) SetColumns(cols []string) {
+	// just do nothing
+}
+
+func (ap *AvroParser) SetLogger(l log.Logger) {
+	ap.logger = l
+}
@@ -0,0 +1,277 @@
+package mydump
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/snappy"
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
+
+	"github.com/pingcap/br/pkg/storage"
+)
+
+type testAvroParserSuite struct{}
+
+var _ = Suite(testAvroParserSuite{})
+
+const testAvroSchema = `{
+	"type": "record", "name": "Order", "namespace": "test",
+	"fields": [
+		{"name": "ID", "type": "long"},
+		{"name": "name", "type": ["null", "string"]},
+		{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+		{"name": "uid", "type": {"type": "string", "logicalType": "uuid"}},
+		{"name": "score", "type": "double"},
+		{"name": "ok", "type": "boolean"},
+		{"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["A", "B"]}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attrs", "type": {"type": "record", "name": "Attrs", "fields": [{"name": "n", "type": "int"}]}}
+	]
+}`
+
+type avroTestWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *avroTestWriter) long(v int64) *avroTestWriter {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutVarint(b[:], v)])
+	return w
+}
+
+func (w *avroTestWriter) bytes(b []byte) *avroTestWriter {
+	w.long(int64(len(b)))
+	w.buf.Write(b)
+	return w
+}
+
+func (w *avroTestWriter) double(f float64) *avroTestWriter {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	w.buf.Write(b[:])
+	return w
+}
+
+func writeAvroTestRow(w *avroTestWriter, id int64, name string) {
+	w.long(id)
+	if name == "" {
+		w.long(0)
+	} else {
+		w.long(1).bytes([]byte(name))
+	}
+	// -1.50 and 12.34
+	if id%2 == 0 {
+		w.bytes([]byte{0xff, 0x6a})
+	} else {
+		w.bytes([]byte{0x04, 0xd2})
+	}
+	w.long(18628)
+	w.long(1609459200123456)
+	w.bytes([]byte("b1e7a6c4-7f6c-4a39-9f5a-8f0d8a1c2e3f"))
+	w.double(0.5)
+	w.buf.WriteByte(1)
+	w.long(1)
+	w.long(2).bytes([]byte("x")).bytes([]byte("y")).long(0)
+	w.long(7)
+}
+
+// writeAvroTestFile writes an avro file with the rows [0, 2) in the first block
+// and [2, 3) in the second block.
+func writeAvroTestFile(c *C, path string, codec string) {
+	sync := []byte("0123456789abcdef")
+	w := &avroTestWriter{}
+	w.buf.Write(avroMagic)
+	w.long(2)
+	w.bytes([]byte("avro.schema")).bytes([]byte(testAvroSchema))
+	w.bytes([]byte("avro.codec")).bytes([]byte(codec))
+	w.long(0)
+	w.buf.Write(sync)
+
+	for _, block := range [][]int64{{0, 1}, {2}} {
+		data := &avroTestWriter{}
+		for _, id := range block {
+			name := ""
+			if id != 1 {
+				name = "name"
+			}
+			writeAvroTestRow(data, id, name)
+		}
+		content := data.buf.Bytes()
+		switch codec {
+		case avroCodecDeflate:
+			var compressed bytes.Buffer
+			fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+			c.Assert(err, IsNil)
+			_, err = fw.Write(content)
+			c.Assert(err, IsNil)
+			c.Assert(fw.Close(), IsNil)
+			content = compressed.Bytes()
+		case avroCodecSnappy:
+			checksum := make([]byte, 4)
+			binary.BigEndian.PutUint32(checksum, crc32.ChecksumIEEE(content))
+			content = append(snappy.Encode(nil, content), checksum...)
+		}
+		w.long(int64(len(block))).bytes(content)
+		w.buf.Write(sync)
+	}
+	c.Assert(os.WriteFile(path, w.buf.Bytes(), 0o644), IsNil)
+}
+
+func (s testAvroParserSuite) TestAvroParser(c *C) {
+	dir := c.MkDir()
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	ctx := context.Background()
+
+	tags, err := json.ParseBinaryFromString(`["x", "y"]`)
+	c.Assert(err, IsNil)
+	attrs, err := json.ParseBinaryFromString(`{"n": 7}`)
+	c.Assert(err, IsNil)
+	expectedRow := func(id int64) []types.Datum {
+		name, price := types.NewStringDatum("name"), types.NewStringDatum("-1.50")
+		if id == 1 {
+			name, price = types.NewDatum(nil), types.NewStringDatum("12.34")
+		}
+		return []types.Datum{
+			types.NewIntDatum(id),
+			name,
+			price,
+			types.NewStringDatum("2021-01-01"),
+			types.NewStringDatum("2021-01-01 00:00:00.123456Z"),
+			types.NewStringDatum("b1e7a6c4-7f6c-4a39-9f5a-8f0d8a1c2e3f"),
+			types.NewFloat64Datum(0.5),
+			types.NewIntDatum(1),
+			types.NewStringDatum("B"),
+			types.NewJSONDatum(tags),
+			types.NewJSONDatum(attrs),
+		}
+	}
+
+	for _, codec := range []string{avroCodecNull, avroCodecDeflate, avroCodecSnappy} {
+		name := "test." + codec + ".avro"
+		writeAvroTestFile(c, filepath.Join(dir, name), codec)
+
+		r, err := store.Open(ctx, name)
+		c.Assert(err, IsNil)
+		parser, err := NewAvroParser(r)
+		c.Assert(err, IsNil)
+		c.Assert(parser.Columns(), DeepEquals,
+			[]string{"id", "name", "price", "day", "ts", "uid", "score", "ok", "kind", "tags", "attrs"})
+		for i := int64(0); i < 3; i++ {
+			c.Assert(parser.ReadRow(), IsNil, Commentf("codec %s", codec))
+			c.Assert(parser.LastRow().RowID, Equals, i+1)
+			c.Assert(parser.LastRow().Row, DeepEquals, expectedRow(i), Commentf("codec %s, row %d", codec, i))
+			pos, _ := parser.Pos()
+			c.Assert(pos, Equals, i+1)
+		}
+		c.Assert(parser.ReadRow(), Equals, io.EOF)
+
+		// seek back to the second row, then to the third row in the next block.
+		c.Assert(parser.SetPos(1, 10), IsNil)
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.LastRow().RowID, Equals, int64(11))
+		c.Assert(parser.LastRow().Row, DeepEquals, expectedRow(1))
+		c.Assert(parser.SetPos(2, 20), IsNil)
+		c.Assert(parser.ReadRow(), IsNil)
+		c.Assert(parser.LastRow().Row, DeepEquals, expectedRow(2))
+		c.Assert(parser.Close(), IsNil)
+	}
+}
+
+func (s testAvroParserSuite) TestMakeAvroFileRegions(c *C) {
+	dir := c.MkDir()
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	writeAvroTestFile(c, filepath.Join(dir, "db.tbl.avro"), avroCodecNull)
+
+	blocks, err := ReadAvroFileBlocks(context.Background(), store, "db.tbl.avro")
+	c.Assert(err, IsNil)
+	c.Assert(blocks, HasLen, 2)
+	c.Assert(blocks[0].Rows, Equals, int64(2))
+	c.Assert(blocks[1].Rows, Equals, int64(1))
+	c.Assert(blocks[1].Offset, Equals, blocks[0].Offset+blocks[0].Size)
+
+	meta := &MDTableMeta{DB: "db", Name: "tbl"}
+	fileInfo := FileInfo{FileMeta: SourceFileMeta{Path: "db.tbl.avro", Type: SourceTypeAvro}}
+	// every block is a region.
+	regions, sizes, err := makeAvroFileRegions(context.Background(), store, meta, fileInfo, 1)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 2)
+	c.Assert(regions[0].Chunk, DeepEquals, Chunk{Offset: 0, EndOffset: 2, PrevRowIDMax: 0, RowIDMax: 2})
+	c.Assert(regions[1].Chunk, DeepEquals, Chunk{Offset: 2, EndOffset: 3, PrevRowIDMax: 2, RowIDMax: 3})
+	c.Assert(sizes, DeepEquals, []float64{float64(blocks[0].Size), float64(blocks[1].Size)})
+
+	// all blocks are in one region.
+	regions, _, err = makeAvroFileRegions(context.Background(), store, meta, fileInfo, 1<<20)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 1)
+	c.Assert(regions[0].Chunk, DeepEquals, Chunk{Offset: 0, EndOffset: 3, PrevRowIDMax: 0, RowIDMax: 3})
+}
+
+func (s testAvroParserSuite) TestAvroCorruptedCount(c *C) {
+	nulls := &avroSchema{Type: "array", Items: &avroSchema{Type: "null"}}
+	longs := &avroSchema{Type: "map", Values: &avroSchema{Type: "long"}}
+	cases := []struct {
+		schema *avroSchema
+		data   []byte
+		err    string
+	}{
+		{nulls, (&avroTestWriter{}).long(1 << 40).buf.Bytes(), "invalid avro block count 1099511627776"},
+		{nulls, (&avroTestWriter{}).long(math.MinInt64).long(0).buf.Bytes(), "invalid avro block count -9223372036854775808"},
+		{nulls, (&avroTestWriter{}).long(-2).long(100).buf.Bytes(), "invalid avro block size 100"},
+		// every entry takes at least two bytes.
+		{longs, (&avroTestWriter{}).long(2).bytes([]byte("k")).buf.Bytes(), "invalid avro block count 2"},
+	}
+	for _, ca := range cases {
+		d := avroDecoder{r: bytes.NewReader(ca.data)}
+		_, err := d.decode(ca.schema)
+		c.Assert(err, ErrorMatches, ca.err)
+	}
+
+	d := avroDecoder{r: bytes.NewReader((&avroTestWriter{}).long(1).long(0).buf.Bytes())}
+	items, err := d.decode(nulls)
+	c.Assert(err, IsNil)
+	c.Assert(items, DeepEquals, []interface{}{nil})
+
+	// the metadata count and the row count of a block are bounded as well.
+	dir := c.MkDir()
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	w := &avroTestWriter{}
+	w.buf.Write(avroMagic)
+	w.long(1 << 40)
+	c.Assert(os.WriteFile(filepath.Join(dir, "meta.avro"), w.buf.Bytes(), 0o644), IsNil)
+	r, err := store.Open(context.Background(), "meta.avro")
+	c.Assert(err, IsNil)
+	_, err = NewAvroParser(r)
+	c.Assert(err, NotNil)
+	c.Assert(r.Close(), IsNil)
+
+	sync := []byte("0123456789abcdef")
+	w = &avroTestWriter{}
+	w.buf.Write(avroMagic)
+	w.long(1).bytes([]byte("avro.schema")).bytes([]byte(testAvroSchema)).long(0)
+	w.buf.Write(sync)
+	row := &avroTestWriter{}
+	writeAvroTestRow(row, 0, "name")
+	w.long(1 << 40).bytes(row.buf.Bytes())
+	w.buf.Write(sync)
+	c.Assert(os.WriteFile(filepath.Join(dir, "rows.avro"), w.buf.Bytes(), 0o644), IsNil)
+	r, err = store.Open(context.Background(), "rows.avro")
+	c.Assert(err, IsNil)
+	parser, err := NewAvroParser(r)
+	c.Assert(err, IsNil)
+	c.Assert(parser.ReadRow(), ErrorMatches, "invalid avro block row count 1099511627776 at offset .*")
+	c.Assert(parser.Close(), IsNil)
+}
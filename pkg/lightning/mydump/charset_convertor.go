@@ -0,0 +1,191 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// The character sets of the data files.
+const (
+	CharsetBinary  = "binary"
+	CharsetUTF8MB4 = "utf8mb4"
+	CharsetGBK     = "gbk"
+	CharsetGB18030 = "gb18030"
+	CharsetLatin1  = "latin1"
+)
+
+// The policies of the invalid characters in the data files.
+const (
+	// InvalidCharError rejects the rows containing invalid characters.
+	InvalidCharError = "error"
+	// InvalidCharReplace replaces the invalid characters with U+FFFD.
+	InvalidCharReplace = "replace"
+)
+
+// ErrInvalidCharacter is returned when a value contains byte sequences which
+// are invalid in the character set of the data file.
+var ErrInvalidCharacter = errors.New("invalid character")
+
+// CharsetConvertor converts the values of the data files from the source
+// character set into utf8mb4. The values are converted after being parsed,
+// unless DecodeStream returns true, in which case the data files are decoded
+// by a DecodeReader before being parsed. It is not safe for concurrent use.
+type CharsetConvertor struct {
+	charset  string
+	encoding encoding.Encoding
+	decoder  *encoding.Decoder
+	replace  bool
+}
+
+// NewCharsetConvertor creates a convertor from the character set `charset`
+// with the policy `invalidChar` of the invalid characters. It returns nil if
+// the data needs no conversion.
+func NewCharsetConvertor(charset string, invalidChar string) (*CharsetConvertor, error) {
+	cc := &CharsetConvertor{charset: charset}
+	switch charset {
+	case "", CharsetBinary:
+		return nil, nil
+	case CharsetUTF8MB4:
+		// the values are validated only.
+	case CharsetGBK:
+		cc.encoding = simplifiedchinese.GBK
+	case CharsetGB18030:
+		cc.encoding = simplifiedchinese.GB18030
+	case CharsetLatin1:
+		// latin1 in MySQL is actually cp1252.
+		cc.encoding = charmap.Windows1252
+	default:
+		return nil, errors.Errorf("unsupported data character set %s", charset)
+	}
+	if cc.encoding != nil {
+		cc.decoder = cc.encoding.NewDecoder()
+	}
+	switch invalidChar {
+	case "", InvalidCharError:
+	case InvalidCharReplace:
+		cc.replace = true
+	default:
+		return nil, errors.Errorf("unsupported policy %s of invalid characters", invalidChar)
+	}
+	return cc, nil
+}
+
+// Charset returns the source character set of the convertor.
+func (cc *CharsetConvertor) Charset() string {
+	return cc.charset
+}
+
+// Decode converts the value from the source character set into utf8mb4. The
+// invalid byte sequences are replaced by U+FFFD if the policy is "replace",
+// otherwise ErrInvalidCharacter is returned.
+func (cc *CharsetConvertor) Decode(value string) (string, error) {
+	if cc.decoder == nil {
+		if utf8.ValidString(value) {
+			return value, nil
+		}
+		if cc.replace {
+			return strings.ToValidUTF8(value, string(utf8.RuneError)), nil
+		}
+		return value, errors.Trace(ErrInvalidCharacter)
+	}
+	if isASCII(value) {
+		// all supported character sets are supersets of ASCII.
+		return value, nil
+	}
+	decoded, err := cc.decoder.String(value)
+	if err != nil {
+		return value, errors.Trace(err)
+	}
+	// the decoders replace the invalid byte sequences with U+FFFD.
+	if !cc.replace && strings.ContainsRune(decoded, utf8.RuneError) {
+		return value, errors.Trace(ErrInvalidCharacter)
+	}
+	return decoded, nil
+}
+
+// DecodeStream returns whether the data files must be decoded before being
+// parsed. The trailing bytes of the multi-byte characters in gbk and gb18030
+// may be taken as ASCII escapes or separators, e.g. "\x95\x5c" is a Chinese
+// character ending with a backslash.
+func (cc *CharsetConvertor) DecodeStream() bool {
+	return cc != nil && (cc.charset == CharsetGBK || cc.charset == CharsetGB18030)
+}
+
+// CheckDecoded checks the value read from a DecodeReader, which contains
+// U+FFFD in place of the invalid byte sequences. It returns
+// ErrInvalidCharacter if there is any unless the policy is "replace".
+func (cc *CharsetConvertor) CheckDecoded(value string) error {
+	if !cc.replace && strings.ContainsRune(value, utf8.RuneError) {
+		return errors.Trace(ErrInvalidCharacter)
+	}
+	return nil
+}
+
+// Encode converts the value read from a DecodeReader back into the source
+// character set, which is used by the binary columns whose values are not
+// converted.
+func (cc *CharsetConvertor) Encode(value string) (string, error) {
+	if err := cc.CheckDecoded(value); err != nil {
+		return value, err
+	}
+	if isASCII(value) {
+		return value, nil
+	}
+	encoded, err := encoding.ReplaceUnsupported(cc.encoding.NewEncoder()).String(value)
+	if err != nil {
+		return value, errors.Trace(err)
+	}
+	return encoded, nil
+}
+
+// NewDecodeReader creates a DecodeReader which decodes the data file read
+// from r. It must only be called if DecodeStream returns true.
+func (cc *CharsetConvertor) NewDecodeReader(r ReadSeekCloser) *DecodeReader {
+	return &DecodeReader{
+		r:       r,
+		decoder: cc.encoding.NewDecoder(),
+		gb18030: cc.charset == CharsetGB18030,
+		runs:    []decodedRun{{}},
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectCharset guesses the character set of the sample of a data file. It
+// returns an empty string if the sample is neither utf8mb4 nor gb18030.
+func DetectCharset(sample []byte) string {
+	if utf8.Valid(sample) {
+		return CharsetUTF8MB4
+	}
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(sample)
+	if err == nil && !bytes.ContainsRune(decoded, utf8.RuneError) {
+		return CharsetGB18030
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	. "github.com/pingcap/br/pkg/lightning/mydump"
+)
+
+var _ = Suite(&testCharsetConvertorSuite{})
+
+type testCharsetConvertorSuite struct{}
+
+const (
+	// "中文" encoded in gb18030 and gbk.
+	gbChinese = "\xd6\xd0\xce\xc4"
+	// an incomplete gb18030 character.
+	gbInvalid = "\xd6\xd0\xce"
+)
+
+func (s *testCharsetConvertorSuite) TestNewCharsetConvertor(c *C) {
+	cc, err := NewCharsetConvertor(CharsetBinary, InvalidCharError)
+	c.Assert(err, IsNil)
+	c.Assert(cc, IsNil)
+
+	cc, err = NewCharsetConvertor(CharsetGB18030, "")
+	c.Assert(err, IsNil)
+	c.Assert(cc.Charset(), Equals, CharsetGB18030)
+
+	_, err = NewCharsetConvertor("ebcdic", InvalidCharError)
+	c.Assert(err, ErrorMatches, "unsupported data character set ebcdic")
+	_, err = NewCharsetConvertor(CharsetGBK, "ignore")
+	c.Assert(err, ErrorMatches, "unsupported policy ignore of invalid characters")
+}
+
+func (s *testCharsetConvertorSuite) TestDecode(c *C) {
+	cases := []struct {
+		charset  string
+		input    string
+		expected string
+	}{
+		{CharsetGB18030, gbChinese, "中文"},
+		{CharsetGB18030, "abc," + gbChinese, "abc,中文"},
+		{CharsetGBK, gbChinese, "中文"},
+		{CharsetLatin1, "caf\xe9 \x80", "café €"},
+		{CharsetUTF8MB4, "中文", "中文"},
+		{CharsetGB18030, "plain ascii", "plain ascii"},
+	}
+	for _, ca := range cases {
+		cc, err := NewCharsetConvertor(ca.charset, InvalidCharError)
+		c.Assert(err, IsNil)
+		decoded, err := cc.Decode(ca.input)
+		c.Assert(err, IsNil)
+		c.Assert(decoded, Equals, ca.expected)
+	}
+}
+
+func (s *testCharsetConvertorSuite) TestDecodeInvalid(c *C) {
+	cc, err := NewCharsetConvertor(CharsetGB18030, InvalidCharError)
+	c.Assert(err, IsNil)
+	_, err = cc.Decode(gbInvalid)
+	c.Assert(errors.Cause(err), Equals, ErrInvalidCharacter)
+
+	cc, err = NewCharsetConvertor(CharsetUTF8MB4, InvalidCharError)
+	c.Assert(err, IsNil)
+	_, err = cc.Decode(gbChinese)
+	c.Assert(errors.Cause(err), Equals, ErrInvalidCharacter)
+
+	cc, err = NewCharsetConvertor(CharsetGB18030, InvalidCharReplace)
+	c.Assert(err, IsNil)
+	decoded, err := cc.Decode(gbInvalid)
+	c.Assert(err, IsNil)
+	c.Assert(decoded, Equals, "中�")
+
+	cc, err = NewCharsetConvertor(CharsetUTF8MB4, InvalidCharReplace)
+	c.Assert(err, IsNil)
+	decoded, err = cc.Decode("a\xffb")
+	c.Assert(err, IsNil)
+	c.Assert(decoded, Equals, "a�b")
+}
+
+func (s *testCharsetConvertorSuite) TestDetectCharset(c *C) {
+	c.Assert(DetectCharset([]byte("1,中文\n")), Equals, CharsetUTF8MB4)
+	c.Assert(DetectCharset([]byte("1,"+gbChinese+"\n")), Equals, CharsetGB18030)
+	c.Assert(DetectCharset([]byte("1,\xff\xff\n")), Equals, "")
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"golang.org/x/text/encoding"
+)
+
+const decodeReaderBufSize = 64 * 1024
+
+// decodedRun is a run of non-ASCII characters, whose decoded bytes are at
+// [decStart, decEnd) of the decoded stream, and whose source bytes are at
+// [srcStart, srcEnd) of the data file. The ASCII bytes between the runs are
+// the same in both.
+type decodedRun struct {
+	decStart, decEnd int64
+	srcStart, srcEnd int64
+}
+
+// DecodeReader decodes a gbk or gb18030 data file into utf8mb4, so that the
+// parser never takes the trailing byte of a character as a separator. The
+// invalid byte sequences are decoded as U+FFFD.
+//
+// The positions of the decoded stream start at the offset of the last Seek, so
+// they are the same as the offsets of the data file until the first non-ASCII
+// character. SourceOffset maps them back to the offsets of the data file.
+type DecodeReader struct {
+	r       ReadSeekCloser
+	decoder *encoding.Decoder
+	gb18030 bool
+
+	// buf is the buffer of the source bytes, src is the part not decoded yet,
+	// which is an incomplete character at the end of the read bytes.
+	buf []byte
+	src []byte
+	// out is the decoded bytes not read yet.
+	out []byte
+	eof bool
+
+	// srcPos and decPos are the positions of the end of the decoded bytes.
+	srcPos int64
+	decPos int64
+	// runs are the non-ASCII runs decoded after the last mapped position. The
+	// first one may have no bytes, which only marks the start.
+	runs []decodedRun
+}
+
+// Read implements io.Reader.
+func (d *DecodeReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.eof && len(d.src) == 0 {
+			return 0, io.EOF
+		}
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// fill reads more source bytes and decodes the complete characters.
+func (d *DecodeReader) fill() error {
+	if !d.eof {
+		if d.buf == nil {
+			d.buf = make([]byte, decodeReaderBufSize)
+		}
+		n := copy(d.buf, d.src)
+		m, err := d.r.Read(d.buf[n:])
+		switch {
+		case err == io.EOF:
+			d.eof = true
+		case err != nil:
+			return errors.Trace(err)
+		}
+		d.src = d.buf[:n+m]
+	}
+
+	src := d.src
+	out := make([]byte, 0, len(src)+len(src)/2)
+	i := 0
+	for i < len(src) {
+		if src[i] < utf8.RuneSelf {
+			start := i
+			for i < len(src) && src[i] < utf8.RuneSelf {
+				i++
+			}
+			out = append(out, src[start:i]...)
+			continue
+		}
+
+		start := i
+		for i < len(src) && src[i] >= utf8.RuneSelf {
+			size := d.charSize(src[i:])
+			if size == 0 {
+				break
+			}
+			i += size
+		}
+		if i == start {
+			// the incomplete character is decoded after reading more bytes.
+			break
+		}
+		decoded, err := d.decoder.Bytes(src[start:i])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		run := decodedRun{
+			decStart: d.decPos + int64(len(out)),
+			srcStart: d.srcPos + int64(start),
+		}
+		out = append(out, decoded...)
+		run.decEnd = d.decPos + int64(len(out))
+		run.srcEnd = d.srcPos + int64(i)
+		d.runs = append(d.runs, run)
+	}
+
+	d.out = out
+	d.src = src[i:]
+	d.srcPos += int64(i)
+	d.decPos += int64(len(out))
+	return nil
+}
+
+// charSize returns the size of the character starting with the non-ASCII byte
+// src[0], in the same way as the decoder. It returns 0 if the character is
+// incomplete and more bytes may follow.
+func (d *DecodeReader) charSize(src []byte) int {
+	c0 := src[0]
+	if c0 == 0x80 || c0 == 0xff {
+		return 1
+	}
+	if len(src) < 2 {
+		return d.incompleteSize()
+	}
+	c1 := src[1]
+	switch {
+	case 0x40 <= c1 && c1 < 0x7f, 0x80 <= c1 && c1 < 0xff:
+		return 2
+	case d.gb18030 && 0x30 <= c1 && c1 < 0x40:
+		if len(src) < 4 {
+			return d.incompleteSize()
+		}
+		if c2 := src[2]; c2 < 0x81 || 0xff <= c2 {
+			return 1
+		}
+		if c3 := src[3]; c3 < 0x30 || 0x3a <= c3 {
+			return 1
+		}
+		return 4
+	default:
+		return 1
+	}
+}
+
+func (d *DecodeReader) incompleteSize() int {
+	if d.eof {
+		// the decoder takes the truncated byte as an invalid character.
+		return 1
+	}
+	return 0
+}
+
+// Seek implements io.Seeker. Only io.SeekStart is supported, and the offset is
+// an offset of the data file.
+func (d *DecodeReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart {
+		return 0, errors.Errorf("unsupported whence %d of the decode reader", whence)
+	}
+	p, err := d.r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return p, errors.Trace(err)
+	}
+	d.src, d.out, d.eof = nil, nil, false
+	d.srcPos, d.decPos = p, p
+	d.runs = append(d.runs[:0], decodedRun{decStart: p, decEnd: p, srcStart: p, srcEnd: p})
+	return p, nil
+}
+
+// Close implements io.Closer.
+func (d *DecodeReader) Close() error {
+	return d.r.Close()
+}
+
+// SourceOffset maps the position pos of the decoded stream back to the offset
+// of the data file. A position inside a run of non-ASCII characters, which is
+// never the end of a row, is mapped to the start of the run. The positions
+// must be mapped in ascending order, as the runs before pos are discarded.
+func (d *DecodeReader) SourceOffset(pos int64) int64 {
+	i := sort.Search(len(d.runs), func(i int) bool {
+		return d.runs[i].decStart >= pos
+	})
+	// the last run starting before pos, or the first run.
+	if i == len(d.runs) || (d.runs[i].decStart > pos && i > 0) {
+		i--
+	}
+	run := d.runs[i]
+	d.runs = d.runs[i:]
+	if pos < run.decEnd {
+		return run.srcStart
+	}
+	return run.srcEnd + pos - run.decEnd
+}
+
+// decodedParser is a parser reading a DecodeReader, whose positions are mapped
+// back to the offsets of the data file.
+type decodedParser struct {
+	Parser
+	reader *DecodeReader
+}
+
+// NewDecodedParser wraps the parser reading from the decode reader, so that
+// Pos returns the offsets of the data file.
+func NewDecodedParser(parser Parser, reader *DecodeReader) Parser {
+	return &decodedParser{Parser: parser, reader: reader}
+}
+
+// Pos implements Parser.
+func (p *decodedParser) Pos() (int64, int64) {
+	pos, rowID := p.Parser.Pos()
+	return p.reader.SourceOffset(pos), rowID
+}
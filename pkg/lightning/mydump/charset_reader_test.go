@@ -0,0 +1,158 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	. "github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/worker"
+)
+
+var _ = Suite(&testDecodeReaderSuite{})
+
+type testDecodeReaderSuite struct{}
+
+// oneByteReader reads one byte at a time, so that the characters are split
+// across the reads.
+type oneByteReader struct {
+	StringReader
+}
+
+func (r oneByteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.StringReader.Read(p)
+}
+
+func (s *testDecodeReaderSuite) TestDecodeReader(c *C) {
+	// "\x95\x5c" is "昞" ending with a backslash in gbk, "\x81\x30\x81\x30" is
+	// U+0080 in gb18030, and "\xd6" is a truncated character.
+	cases := []struct {
+		charset  string
+		input    string
+		expected string
+	}{
+		{CharsetGBK, "a\x95\x5cb\xd6\xd0\xce\xc4\n", "a昞b中文\n"},
+		{CharsetGBK, "\x81\x30,\xd6", "�0,�"},
+		{CharsetGB18030, "\x81\x30\x81\x30,\x95\x5c\xd6", "\u0080,昞�"},
+	}
+	for _, ca := range cases {
+		cc, err := NewCharsetConvertor(ca.charset, InvalidCharReplace)
+		c.Assert(err, IsNil)
+		c.Assert(cc.DecodeStream(), IsTrue)
+		for _, r := range []ReadSeekCloser{NewStringReader(ca.input), oneByteReader{NewStringReader(ca.input)}} {
+			decoded, err := io.ReadAll(cc.NewDecodeReader(r))
+			c.Assert(err, IsNil)
+			c.Assert(string(decoded), Equals, ca.expected, Commentf("case %+v", ca))
+		}
+	}
+
+	cc, err := NewCharsetConvertor(CharsetLatin1, InvalidCharError)
+	c.Assert(err, IsNil)
+	c.Assert(cc.DecodeStream(), IsFalse)
+}
+
+func (s *testDecodeReaderSuite) TestSourceOffset(c *C) {
+	cc, err := NewCharsetConvertor(CharsetGB18030, InvalidCharError)
+	c.Assert(err, IsNil)
+	input := "ab\xd6\xd0\xce\xc4cd\x81\x30\x81\x30e"
+	r := cc.NewDecodeReader(oneByteReader{NewStringReader(input)})
+	decoded, err := io.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, "ab中文cd\u0080e")
+
+	// the positions inside the non-ASCII characters are mapped to the start.
+	for _, ca := range [][2]int64{{0, 0}, {2, 2}, {3, 2}, {8, 6}, {9, 7}, {10, 8}, {11, 8}, {12, 12}, {13, 13}} {
+		c.Assert(r.SourceOffset(ca[0]), Equals, ca[1], Commentf("pos %d", ca[0]))
+	}
+
+	// the positions start at the offset of the data file after seeking.
+	_, err = r.Seek(6, io.SeekStart)
+	c.Assert(err, IsNil)
+	decoded, err = io.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(decoded), Equals, "cd\u0080e")
+	c.Assert(r.SourceOffset(8), Equals, int64(8))
+	c.Assert(r.SourceOffset(10), Equals, int64(12))
+	c.Assert(r.SourceOffset(11), Equals, int64(13))
+
+	_, err = r.Seek(0, io.SeekEnd)
+	c.Assert(err, ErrorMatches, "unsupported whence 2 of the decode reader")
+}
+
+func (s *testDecodeReaderSuite) TestDecodedCSVParser(c *C) {
+	cc, err := NewCharsetConvertor(CharsetGBK, InvalidCharError)
+	c.Assert(err, IsNil)
+	cfg := config.NewConfig()
+	cfg.Mydumper.CSV.BackslashEscape = true
+	ioWorkers := worker.NewPool(context.Background(), 1, "test")
+
+	// the trailing backslash of "\x95\x5c" escapes neither the delimiter nor
+	// the quote.
+	lines := []string{"1,\x95\x5c,\"\x95\x5c\"\n", "2,\\,,\xd6\xd0\n"}
+	input := strings.Join(lines, "")
+	reader := cc.NewDecodeReader(NewStringReader(input))
+	parser := NewDecodedParser(NewCSVParser(&cfg.Mydumper.CSV, reader, 4, ioWorkers, false), reader)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{
+		types.NewStringDatum("1"),
+		types.NewStringDatum("昞"),
+		types.NewStringDatum("昞"),
+	})
+	c.Assert(parser, posEq, len(lines[0]), 1)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{
+		types.NewStringDatum("2"),
+		types.NewStringDatum(","),
+		types.NewStringDatum("中"),
+	})
+	c.Assert(parser, posEq, len(input), 2)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+
+	// start parsing from the second line.
+	c.Assert(parser.SetPos(int64(len(lines[0])), 1), IsNil)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row[2], DeepEquals, types.NewStringDatum("中"))
+	c.Assert(parser, posEq, len(input), 2)
+	c.Assert(parser.Close(), IsNil)
+}
+
+func (s *testDecodeReaderSuite) TestCheckDecoded(c *C) {
+	cc, err := NewCharsetConvertor(CharsetGBK, InvalidCharError)
+	c.Assert(err, IsNil)
+	c.Assert(cc.CheckDecoded("中文"), IsNil)
+	c.Assert(errors.Cause(cc.CheckDecoded("中�")), Equals, ErrInvalidCharacter)
+	encoded, err := cc.Encode("昞,a")
+	c.Assert(err, IsNil)
+	c.Assert(encoded, Equals, "\x95\x5c,a")
+	_, err = cc.Encode("中�")
+	c.Assert(errors.Cause(err), Equals, ErrInvalidCharacter)
+
+	cc, err = NewCharsetConvertor(CharsetGBK, InvalidCharReplace)
+	c.Assert(err, IsNil)
+	c.Assert(cc.CheckDecoded("中�"), IsNil)
+	encoded, err = cc.Encode("中�")
+	c.Assert(err, IsNil)
+	c.Assert(encoded, Equals, "\xd6\xd0\x1a")
+}
@@ -16,6 +16,7 @@ package mydump
 import (
 	"bytes"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/pingcap/errors"
@@ -37,17 +38,19 @@ type CSVParser struct {
 	blockParser
 	cfg *config.CSVConfig
 
-	comma   []byte
-	quote   []byte
-	newLine []byte
+	comma    []byte
+	quote    []byte
+	newLines [][]byte
+	comment  []byte
+	escChar  byte
 
 	// These variables are used with IndexAnyByte to search a byte slice for the
 	// first index which some special character may appear.
 	// quoteByteSet is used inside quoted fields (so the first characters of
-	// the closing delimiter and backslash are special).
+	// the closing delimiter and escape character are special).
 	// unquoteByteSet is used outside quoted fields (so the first characters
-	// of the opening delimiter, separator, terminator and backslash are
-	// special).
+	// of the opening delimiter, separator, terminator and escape character
+	// are special).
 	// newLineByteSet is used in strict-format CSV dividing (so the first
 	// characters of the terminator are special).
 	quoteByteSet   byteSet
@@ -63,6 +66,8 @@ type CSVParser struct {
 	// fieldIndexes is an index of fields inside recordBuffer.
 	// The i'th field ends at offset fieldIndexes[i] in recordBuffer.
 	fieldIndexes []int
+	// fieldQuoted tells whether the i'th field is enclosed by the delimiter.
+	fieldQuoted []bool
 
 	lastRecord []string
 
@@ -79,24 +84,33 @@ func NewCSVParser(
 	shouldParseHeader bool,
 ) *CSVParser {
 	escFlavor := backslashEscapeFlavorNone
+	escChar := cfg.GetEscapeChar()
 	var quoteStopSet, newLineStopSet []byte
 	unquoteStopSet := []byte{cfg.Separator[0]}
 	if len(cfg.Delimiter) > 0 {
 		quoteStopSet = []byte{cfg.Delimiter[0]}
 		unquoteStopSet = append(unquoteStopSet, cfg.Delimiter[0])
 	}
-	if len(cfg.Terminator) > 0 {
-		newLineStopSet = []byte{cfg.Terminator[0]}
-	} else {
+	terminators := cfg.GetTerminators()
+	newLines := make([][]byte, 0, len(terminators))
+	for _, terminator := range terminators {
+		newLines = append(newLines, []byte(terminator))
+		newLineStopSet = append(newLineStopSet, terminator[0])
+	}
+	// prefer the longest terminator, e.g. "\r\n" over "\r".
+	sort.SliceStable(newLines, func(i, j int) bool {
+		return len(newLines[i]) > len(newLines[j])
+	})
+	if len(newLines) == 0 {
 		newLineStopSet = []byte{'\r', '\n'}
 	}
 	unquoteStopSet = append(unquoteStopSet, newLineStopSet...)
-	if cfg.BackslashEscape {
+	if escChar != 0 {
 		escFlavor = backslashEscapeFlavorMySQL
-		quoteStopSet = append(quoteStopSet, '\\')
-		unquoteStopSet = append(unquoteStopSet, '\\')
+		quoteStopSet = append(quoteStopSet, escChar)
+		unquoteStopSet = append(unquoteStopSet, escChar)
 		// we need special treatment of the NULL value \N, used by MySQL.
-		if !cfg.NotNull && cfg.Null == `\N` {
+		if !cfg.NotNull && cfg.Null == string(escChar)+"N" {
 			escFlavor = backslashEscapeFlavorMySQLWithNull
 		}
 	}
@@ -106,7 +120,9 @@ func NewCSVParser(
 		cfg:               cfg,
 		comma:             []byte(cfg.Separator),
 		quote:             []byte(cfg.Delimiter),
-		newLine:           []byte(cfg.Terminator),
+		newLines:          newLines,
+		comment:           []byte(cfg.Comment),
+		escChar:           escChar,
 		escFlavor:         escFlavor,
 		quoteByteSet:      makeByteSet(quoteStopSet),
 		unquoteByteSet:    makeByteSet(unquoteStopSet),
@@ -135,6 +151,8 @@ const (
 	// csvTokenAnyUnquoted is a placeholder to represent any unquoted character.
 	csvTokenAnyUnquoted csvToken = 0
 	// csvTokenWithBackslash is a mask indicating an escaped character.
+	// The escape sequences are recorded in the backslash form regardless of
+	// the actual escape character.
 	// The actual token is represented like `csvTokenWithBackslash | 'n'`.
 	csvTokenWithBackslash csvToken = 0x100
 	// csvTokenComma is the CSV separator token.
@@ -197,13 +215,18 @@ func (parser *CSVParser) tryReadExact(content []byte) (bool, error) {
 }
 
 func (parser *CSVParser) tryReadNewLine(b byte) (bool, error) {
-	if len(parser.newLine) == 0 {
+	if len(parser.newLines) == 0 {
 		return b == '\r' || b == '\n', nil
 	}
-	if b != parser.newLine[0] {
-		return false, nil
+	for _, newLine := range parser.newLines {
+		if b != newLine[0] {
+			continue
+		}
+		if ok, err := parser.tryReadExact(newLine[1:]); ok || err != nil {
+			return ok, err
+		}
 	}
-	return parser.tryReadExact(parser.newLine[1:])
+	return false, nil
 }
 
 func (parser *CSVParser) tryReadOpenDelimiter(b byte) (bool, error) {
@@ -230,7 +253,7 @@ func (parser *CSVParser) tryReadComma(b byte) (bool, error) {
 }
 
 func (parser *CSVParser) tryReadBackslashed(bs byte) (bool, byte, error) {
-	if bs != '\\' || parser.escFlavor == backslashEscapeFlavorNone {
+	if parser.escFlavor == backslashEscapeFlavorNone || bs != parser.escChar {
 		return false, 0, nil
 	}
 	b, err := parser.readByte()
@@ -267,9 +290,30 @@ func (parser *CSVParser) readUnquoteToken(b byte) (csvToken, error) {
 
 func (parser *CSVParser) appendCSVTokenToRecordBuffer(token csvToken) {
 	if token&csvTokenWithBackslash != 0 {
+		parser.recordBuffer = append(parser.recordBuffer, '\\', byte(token))
+		return
+	}
+	parser.appendToRecordBuffer([]byte{byte(token)})
+}
+
+// appendToRecordBuffer appends the unescaped content to the record buffer. If
+// the escape character is not a backslash, the literal backslashes are
+// escaped to be distinguished from the escape sequences.
+func (parser *CSVParser) appendToRecordBuffer(content []byte) {
+	if parser.escFlavor == backslashEscapeFlavorNone || parser.escChar == '\\' {
+		parser.recordBuffer = append(parser.recordBuffer, content...)
+		return
+	}
+	for {
+		i := bytes.IndexByte(content, '\\')
+		if i < 0 {
+			break
+		}
+		parser.recordBuffer = append(parser.recordBuffer, content[:i+1]...)
 		parser.recordBuffer = append(parser.recordBuffer, '\\')
+		content = content[i+1:]
 	}
-	parser.recordBuffer = append(parser.recordBuffer, byte(token))
+	parser.recordBuffer = append(parser.recordBuffer, content...)
 }
 
 // readUntil reads the buffer until any character from the `chars` set is found.
@@ -308,14 +352,29 @@ func (parser *CSVParser) readUntil(chars *byteSet) ([]byte, byte, error) {
 func (parser *CSVParser) readRecord(dst []string) ([]string, error) {
 	parser.recordBuffer = parser.recordBuffer[:0]
 	parser.fieldIndexes = parser.fieldIndexes[:0]
+	parser.fieldQuoted = parser.fieldQuoted[:0]
 
 	isEmptyLine := true
 	whitespaceLine := true
+	quoted := false
 	prevToken := csvTokenNewLine
 	var firstToken csvToken
 
 outside:
 	for {
+		if len(parser.comment) > 0 && prevToken == csvTokenNewLine {
+			isComment, err := parser.tryReadExact(parser.comment)
+			if err != nil {
+				return nil, err
+			}
+			if isComment {
+				if err = parser.skipLine(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
 		content, firstByte, err := parser.readUntil(&parser.unquoteByteSet)
 
 		if len(content) > 0 {
@@ -324,7 +383,7 @@ outside:
 				parser.logSyntaxError()
 				return nil, errors.AddStack(errUnexpectedQuoteField)
 			}
-			parser.recordBuffer = append(parser.recordBuffer, content...)
+			parser.appendToRecordBuffer(content)
 			prevToken = csvTokenAnyUnquoted
 		}
 
@@ -346,6 +405,8 @@ outside:
 		case csvTokenComma:
 			whitespaceLine = false
 			parser.fieldIndexes = append(parser.fieldIndexes, len(parser.recordBuffer))
+			parser.fieldQuoted = append(parser.fieldQuoted, quoted)
+			quoted = false
 		case csvTokenDelimiter:
 			if prevToken != csvTokenComma && prevToken != csvTokenNewLine {
 				parser.logSyntaxError()
@@ -355,6 +416,7 @@ outside:
 				return nil, err
 			}
 			whitespaceLine = false
+			quoted = true
 		case csvTokenNewLine:
 			// new line = end of record (ignore empty lines)
 			prevToken = firstToken
@@ -367,6 +429,7 @@ outside:
 				continue
 			}
 			parser.fieldIndexes = append(parser.fieldIndexes, len(parser.recordBuffer))
+			parser.fieldQuoted = append(parser.fieldQuoted, quoted)
 			break outside
 		default:
 			if prevToken == csvTokenDelimiter {
@@ -403,7 +466,7 @@ func (parser *CSVParser) readQuotedField() error {
 		if err != nil {
 			return err
 		}
-		parser.recordBuffer = append(parser.recordBuffer, content...)
+		parser.appendToRecordBuffer(content)
 		parser.skipBytes(1)
 
 		token, err := parser.readQuotedToken(terminator)
@@ -420,7 +483,7 @@ func (parser *CSVParser) readQuotedField() error {
 			}
 			if doubledDelimiter {
 				// consume the double quotation mark and continue
-				parser.recordBuffer = append(parser.recordBuffer, parser.quote...)
+				parser.appendToRecordBuffer(parser.quote)
 			} else {
 				// the field is completed, exit.
 				return nil
@@ -444,10 +507,14 @@ func (parser *CSVParser) replaceEOF(err error, replaced error) error {
 
 // ReadRow reads a row from the datafile.
 func (parser *CSVParser) ReadRow() error {
+	parser.beginRow()
 	row := &parser.lastRow
 	row.Length = 0
 	row.RowID++
 
+	if err := parser.skipLeadingLines(); err != nil {
+		return errors.Trace(err)
+	}
 	// skip the header first
 	if parser.shouldParseHeader {
 		err := parser.ReadColumns()
@@ -479,6 +546,9 @@ func (parser *CSVParser) ReadRow() error {
 	for i, record := range records {
 		row.Length += len(record)
 		unescaped, isNull := parser.unescapeString(record)
+		if parser.cfg.UnquotedEmptyNull && len(record) == 0 {
+			isNull = !parser.fieldQuoted[i]
+		}
 		if isNull {
 			row.Row[i].SetNull()
 		} else {
@@ -490,6 +560,9 @@ func (parser *CSVParser) ReadRow() error {
 }
 
 func (parser *CSVParser) ReadColumns() error {
+	if err := parser.skipLeadingLines(); err != nil {
+		return errors.Trace(err)
+	}
 	columns, err := parser.readRecord(nil)
 	if err != nil {
 		return errors.Trace(err)
@@ -512,14 +585,37 @@ func indexOfNewLine(b []byte) int {
 // returns the file offset beyond the terminator.
 // This function is used in strict-format dividing a CSV file.
 func (parser *CSVParser) ReadUntilTerminator() (int64, error) {
+	if err := parser.skipLine(); err != nil {
+		return 0, err
+	}
+	return parser.pos, nil
+}
+
+// skipLine skips the content until the terminator token is found, regardless
+// of the delimiters.
+func (parser *CSVParser) skipLine() error {
 	for {
 		_, firstByte, err := parser.readUntil(&parser.newLineByteSet)
 		if err != nil {
-			return 0, err
+			return err
 		}
 		parser.skipBytes(1)
 		if ok, err := parser.tryReadNewLine(firstByte); ok || err != nil {
-			return parser.pos, err
+			return err
 		}
 	}
 }
+
+// skipLeadingLines skips the `skip-lines` leading lines if the parser is at
+// the beginning of the file.
+func (parser *CSVParser) skipLeadingLines() error {
+	if parser.pos > 0 {
+		return nil
+	}
+	for i := 0; i < parser.cfg.SkipLines; i++ {
+		if err := parser.skipLine(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
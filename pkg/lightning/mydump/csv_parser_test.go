@@ -3,7 +3,9 @@ package mydump_test
 import (
 	"context"
 	"encoding/csv"
+	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -68,6 +70,48 @@ func (s *testMydumpCSVParserSuite) runTestCases(c *C, cfg *config.CSVConfig, blo
 	}
 }
 
+// runDialectTestCases is runTestCases comparing the rows by datumsEq, since the
+// empty strings may be either nil or empty slices.
+func (s *testMydumpCSVParserSuite) runDialectTestCases(c *C, cfg *config.CSVConfig, blockBufSize int64, cases []testCase) {
+	for _, tc := range cases {
+		parser := mydump.NewCSVParser(cfg, mydump.NewStringReader(tc.input), blockBufSize, s.ioWorkers, false)
+		for i, row := range tc.expected {
+			comment := Commentf("input = %q, row = %d", tc.input, i+1)
+			e := parser.ReadRow()
+			c.Assert(e, IsNil, Commentf("input = %q, row = %d, error = %s", tc.input, i+1, errors.ErrorStack(e)))
+			c.Assert(parser.LastRow().RowID, DeepEquals, int64(i)+1, comment)
+			c.Assert(parser.LastRow().Row, datumsEq, row, comment)
+		}
+		c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF, Commentf("input = %q", tc.input))
+	}
+}
+
+type datumsEqChecker struct {
+	*CheckerInfo
+}
+
+// datumsEq checks whether the datums are of the same kinds, collations and
+// values.
+var datumsEq = &datumsEqChecker{
+	&CheckerInfo{Name: "datumsEq", Params: []string{"obtained", "expected"}},
+}
+
+func (checker *datumsEqChecker) Check(params []interface{}, names []string) (result bool, error string) {
+	obtained := params[0].([]types.Datum)
+	expected := params[1].([]types.Datum)
+	if len(obtained) != len(expected) {
+		return false, "length mismatch"
+	}
+	for i := range obtained {
+		if obtained[i].Kind() != expected[i].Kind() ||
+			obtained[i].Collation() != expected[i].Collation() ||
+			obtained[i].GetString() != expected[i].GetString() {
+			return false, fmt.Sprintf("datum %d mismatch", i)
+		}
+	}
+	return true, ""
+}
+
 func (s *testMydumpCSVParserSuite) runFailingTestCases(c *C, cfg *config.CSVConfig, blockBufSize int64, cases []string) {
 	for _, tc := range cases {
 		parser := mydump.NewCSVParser(cfg, mydump.NewStringReader(tc), blockBufSize, s.ioWorkers, false)
@@ -885,6 +929,263 @@ func (s *testMydumpCSVParserSuite) TestTerminator(c *C) {
 	s.runTestCases(c, &cfg, 1, testCases)
 }
 
+func (s *testMydumpCSVParserSuite) TestEscapedBy(c *C) {
+	cfg := config.CSVConfig{
+		Separator:       ",",
+		Delimiter:       `"`,
+		BackslashEscape: true,
+		EscapedBy:       "^",
+		Null:            "^N",
+	}
+
+	testCases := []testCase{
+		{
+			input: `^N,"^N","a^"b",c^,d,"^^\n",\N` + "\n",
+			expected: [][]types.Datum{
+				{
+					nullDatum,
+					nullDatum,
+					types.NewStringDatum(`a"b`),
+					types.NewStringDatum("c,d"),
+					types.NewStringDatum(`^\n`),
+					types.NewStringDatum(`\N`),
+				},
+			},
+		},
+		{
+			input: `"^n^t^0","x\",^` + "\n",
+			expected: [][]types.Datum{
+				{types.NewStringDatum("\n\t\x00"), types.NewStringDatum(`x\`), types.NewStringDatum("\n")},
+			},
+		},
+	}
+	s.runDialectTestCases(c, &cfg, 1, testCases)
+	s.runDialectTestCases(c, &cfg, int64(config.ReadBlockSize), testCases)
+
+	s.runFailingTestCases(c, &cfg, 1, []string{`"abc^`, `abc^`})
+}
+
+func (s *testMydumpCSVParserSuite) TestComment(c *C) {
+	cfg := config.CSVConfig{
+		Separator: ",",
+		Delimiter: `"`,
+		Comment:   "--",
+	}
+
+	testCases := []testCase{
+		{
+			input: "-- header comment, \"unterminated\n1,2\n\n--\r\n-x,\"\n--y\"\n  -- not comment,3\n-- last",
+			expected: [][]types.Datum{
+				{types.NewStringDatum("1"), types.NewStringDatum("2")},
+				{types.NewStringDatum("-x"), types.NewStringDatum("\n--y")},
+				{types.NewStringDatum("  -- not comment"), types.NewStringDatum("3")},
+			},
+		},
+	}
+	s.runDialectTestCases(c, &cfg, 1, testCases)
+	s.runDialectTestCases(c, &cfg, int64(config.ReadBlockSize), testCases)
+}
+
+func (s *testMydumpCSVParserSuite) TestUnquotedEmptyNull(c *C) {
+	cfg := config.CSVConfig{
+		Separator:         ",",
+		Delimiter:         `"`,
+		Null:              "NULL",
+		UnquotedEmptyNull: true,
+	}
+
+	testCases := []testCase{
+		{
+			input: `,"",NULL,"NULL",a` + "\n\"\",\n",
+			expected: [][]types.Datum{
+				{nullDatum, types.NewStringDatum(""), nullDatum, nullDatum, types.NewStringDatum("a")},
+				{types.NewStringDatum(""), nullDatum},
+			},
+		},
+	}
+	s.runDialectTestCases(c, &cfg, 1, testCases)
+
+	cfg.UnquotedEmptyNull = false
+	testCases = []testCase{
+		{
+			input: `,"",NULL` + "\n",
+			expected: [][]types.Datum{
+				{types.NewStringDatum(""), types.NewStringDatum(""), nullDatum},
+			},
+		},
+	}
+	s.runDialectTestCases(c, &cfg, 1, testCases)
+}
+
+func (s *testMydumpCSVParserSuite) TestMultipleTerminators(c *C) {
+	cfg := config.CSVConfig{
+		Separator:   "|",
+		Terminators: []string{"\n", "||\n", "\r\n"},
+	}
+
+	testCases := []testCase{
+		{
+			input: "1|2||\n3|4\r\n5||6\n7|\r8\n",
+			expected: [][]types.Datum{
+				{types.NewStringDatum("1"), types.NewStringDatum("2")},
+				{types.NewStringDatum("3"), types.NewStringDatum("4")},
+				{types.NewStringDatum("5"), nullDatum, types.NewStringDatum("6")},
+				{types.NewStringDatum("7"), types.NewStringDatum("\r8")},
+			},
+		},
+	}
+	s.runDialectTestCases(c, &cfg, 1, testCases)
+	s.runDialectTestCases(c, &cfg, int64(config.ReadBlockSize), testCases)
+
+	parser := mydump.NewCSVParser(&cfg, mydump.NewStringReader("1|2||\n3|4\r\n5"), 1, s.ioWorkers, false)
+	pos, err := parser.ReadUntilTerminator()
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(6))
+	pos, err = parser.ReadUntilTerminator()
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(11))
+}
+
+func (s *testMydumpCSVParserSuite) TestSkipLines(c *C) {
+	cfg := config.CSVConfig{
+		Separator: ",",
+		Delimiter: `"`,
+		SkipLines: 2,
+	}
+	content := "exported at 2021-01-01\n\"report\n" + "a,b\n1,2\n"
+
+	parser := mydump.NewCSVParser(&cfg, mydump.NewStringReader(content), 1, s.ioWorkers, true)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.Columns(), DeepEquals, []string{"a", "b"})
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum("1"), types.NewStringDatum("2")})
+	c.Assert(parser, posEq, len(content), 1)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+
+	parser = mydump.NewCSVParser(&cfg, mydump.NewStringReader(content), 1, s.ioWorkers, false)
+	c.Assert(parser.ReadColumns(), IsNil)
+	c.Assert(parser.Columns(), DeepEquals, []string{"a", "b"})
+
+	// the lines are only skipped at the beginning of the file.
+	parser = mydump.NewCSVParser(&cfg, mydump.NewStringReader(content), 1, s.ioWorkers, false)
+	c.Assert(parser.SetPos(31, 0), IsNil)
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum("a"), types.NewStringDatum("b")})
+
+	parser = mydump.NewCSVParser(&cfg, mydump.NewStringReader("only one line"), 1, s.ioWorkers, false)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+// TestRandomDialects encodes random rows in various dialects and checks they
+// are parsed back.
+func (s *testMydumpCSVParserSuite) TestRandomDialects(c *C) {
+	dialects := []config.CSVConfig{
+		{
+			Separator:       ",",
+			Delimiter:       `"`,
+			BackslashEscape: true,
+			EscapedBy:       "^",
+			Null:            "^N",
+			Comment:         "#",
+			Terminators:     []string{"\r\n", "\n", "\r"},
+			SkipLines:       2,
+		},
+		{
+			Separator:         "|+|",
+			Delimiter:         "'",
+			Comment:           "--",
+			UnquotedEmptyNull: true,
+			Terminators:       []string{"\n", "||\n"},
+		},
+		{
+			Separator:       "\t",
+			Delimiter:       `"`,
+			BackslashEscape: true,
+			Null:            `\N`,
+			Comment:         "#",
+			Terminator:      "\n",
+			SkipLines:       1,
+		},
+		{
+			Separator:         "，",
+			Delimiter:         "」",
+			BackslashEscape:   true,
+			EscapedBy:         "~",
+			Comment:           "🤔",
+			UnquotedEmptyNull: true,
+			Terminators:       []string{"\n", "🌚"},
+		},
+	}
+	alphabet := []string{
+		",", `"`, "'", "|", "+", "-", "#", "^", "~", "\\", "\r", "\n", "\t",
+		" ", "x", "n", "N", "，", "」", "🤔", "🌚",
+	}
+	rng := rand.New(rand.NewSource(42))
+	randomString := func(exclude map[string]struct{}) string {
+		var sb strings.Builder
+		for n := rng.Intn(8); n > 0; n-- {
+			ch := alphabet[rng.Intn(len(alphabet))]
+			if _, ok := exclude[ch]; !ok {
+				sb.WriteString(ch)
+			}
+		}
+		return sb.String()
+	}
+
+	for _, cfg := range dialects {
+		cfg := cfg
+		terminators := cfg.GetTerminators()
+		// the comment and leading lines must not contain the first bytes of
+		// the terminators.
+		junkExclude := make(map[string]struct{})
+		for _, ch := range alphabet {
+			for _, terminator := range terminators {
+				if ch[0] == terminator[0] {
+					junkExclude[ch] = struct{}{}
+				}
+			}
+		}
+		randomTerminator := func() string {
+			return terminators[rng.Intn(len(terminators))]
+		}
+
+		var sb strings.Builder
+		for i := 0; i < cfg.SkipLines; i++ {
+			sb.WriteString(randomString(junkExclude) + randomTerminator())
+		}
+		var expected [][]types.Datum
+		for i := 0; i < 200; i++ {
+			if rng.Intn(4) == 0 {
+				sb.WriteString(cfg.Comment + randomString(junkExclude) + randomTerminator())
+			}
+			row := make([]types.Datum, 3)
+			for j := range row {
+				if j > 0 {
+					sb.WriteString(cfg.Separator)
+				}
+				if rng.Intn(5) == 0 {
+					if !cfg.UnquotedEmptyNull {
+						sb.WriteString(cfg.Null)
+					}
+					continue
+				}
+				value := randomString(nil)
+				row[j] = types.NewStringDatum(value)
+				if esc := cfg.GetEscapeChar(); esc != 0 {
+					value = strings.ReplaceAll(value, string(esc), string(esc)+string(esc))
+				}
+				value = strings.ReplaceAll(value, cfg.Delimiter, cfg.Delimiter+cfg.Delimiter)
+				sb.WriteString(cfg.Delimiter + value + cfg.Delimiter)
+			}
+			sb.WriteString(randomTerminator())
+			expected = append(expected, row)
+		}
+
+		testCases := []testCase{{input: sb.String(), expected: expected}}
+		s.runDialectTestCases(c, &cfg, 3, testCases)
+		s.runDialectTestCases(c, &cfg, int64(config.ReadBlockSize), testCases)
+	}
+}
+
 // Run `go test github.com/pingcap/br/pkg/lightning/mydump -check.b -check.bmem -test.v` to get benchmark result.
 // Please ensure your temporary storage has (c.N / 2) KiB of free space.
 
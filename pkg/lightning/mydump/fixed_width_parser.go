@@ -0,0 +1,398 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/worker"
+)
+
+// FixedWidthLayout is the compiled layout of the records of a fixed-width data
+// file.
+type FixedWidthLayout struct {
+	columns []string
+	fields  []config.FixedWidthColumn
+	trim    bool
+	null    *string
+}
+
+// NewFixedWidthLayout compiles the layout described by the rule.
+func NewFixedWidthLayout(rule *config.FixedWidthRule) (*FixedWidthLayout, error) {
+	layout := &FixedWidthLayout{trim: rule.Trim, null: rule.Null}
+	if len(rule.Copybook) > 0 {
+		fields, err := ParseCopybook(rule.Copybook)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		layout.fields = fields
+	} else {
+		for _, col := range rule.Columns {
+			layout.fields = append(layout.fields, *col)
+		}
+	}
+	seen := make(map[string]struct{}, len(layout.fields))
+	for _, field := range layout.fields {
+		name := strings.ToLower(field.Name)
+		if _, ok := seen[name]; ok {
+			return nil, errors.Errorf("duplicate column %s in the fixed-width layout", name)
+		}
+		seen[name] = struct{}{}
+		layout.columns = append(layout.columns, name)
+	}
+	return layout, nil
+}
+
+// GetFixedWidthLayout returns the layout of the fixed-width data files of the
+// table.
+func GetFixedWidthLayout(cfg *config.Config, db string, table string) (*FixedWidthLayout, error) {
+	rule, err := cfg.Mydumper.FixedWidth.GetFixedWidthRule(db, table, cfg.Mydumper.CaseSensitive)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rule == nil {
+		return nil, errors.Errorf("no `mydumper.fixed-width` layout is configured for table %s", common.UniqueTable(db, table))
+	}
+	return NewFixedWidthLayout(rule)
+}
+
+// Columns returns the lower-case names of the fields.
+func (layout *FixedWidthLayout) Columns() []string {
+	return layout.columns
+}
+
+// FixedWidthParser is a parser of the fixed-width text files, in which every
+// line is a record and the fields are at the fixed byte offsets of the line
+// described by the layout. The columns are the names of the fields. The fields
+// beyond the end of a short record are empty.
+type FixedWidthParser struct {
+	blockParser
+
+	layout *FixedWidthLayout
+}
+
+// NewFixedWidthParser creates a fixed-width text file parser.
+func NewFixedWidthParser(
+	layout *FixedWidthLayout,
+	reader ReadSeekCloser,
+	blockBufSize int64,
+	ioWorkers *worker.Pool,
+) *FixedWidthParser {
+	parser := &FixedWidthParser{
+		blockParser: makeBlockParser(reader, blockBufSize, ioWorkers),
+		layout:      layout,
+	}
+	parser.columns = layout.columns
+	return parser
+}
+
+// ReadRow reads a row from the datafile.
+func (parser *FixedWidthParser) ReadRow() error {
+	parser.beginRow()
+	row := &parser.lastRow
+	row.Length = 0
+	row.RowID++
+
+	var line []byte
+	for len(line) == 0 {
+		l, err := parser.readLine()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		line = bytes.TrimSuffix(l, []byte{'\r'})
+	}
+	row.Length = len(line)
+
+	fields := parser.layout.fields
+	row.Row = parser.acquireDatumSlice()
+	if cap(row.Row) >= len(fields) {
+		row.Row = row.Row[:len(fields)]
+	} else {
+		row.Row = make([]types.Datum, len(fields))
+	}
+	for i, field := range fields {
+		var value string
+		if field.Start < len(line) {
+			end := field.Start + field.Width
+			if end > len(line) {
+				end = len(line)
+			}
+			value = string(line[field.Start:end])
+		}
+		numeric := field.Scale > 0 || len(field.Sign) > 0
+		if parser.layout.trim || numeric {
+			value = strings.TrimSpace(value)
+		}
+		if parser.layout.null != nil && value == *parser.layout.null {
+			row.Row[i].SetNull()
+			continue
+		}
+		if numeric && len(value) > 0 {
+			var err error
+			if value, err = decodeNumber(value, field.Sign, field.Scale); err != nil {
+				return errors.Annotatef(err, "invalid value of field %s", field.Name)
+			}
+		}
+		row.Row[i].SetString(value, "utf8mb4_bin")
+	}
+	return nil
+}
+
+// decodeNumber moves the sign of the numeric value to the front, and inserts
+// the implied decimal point.
+func decodeNumber(value string, sign string, scale int) (string, error) {
+	switch sign {
+	case config.FixedWidthSignLeading:
+		digit, negative, err := decodeOverpunch(value[0])
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		value = string(digit) + value[1:]
+		if negative {
+			value = "-" + value
+		}
+	case config.FixedWidthSignTrailing:
+		digit, negative, err := decodeOverpunch(value[len(value)-1])
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+		value = value[:len(value)-1] + string(digit)
+		if negative {
+			value = "-" + value
+		}
+	case config.FixedWidthSignTrailingSeparate:
+		if last := value[len(value)-1]; last == '+' || last == '-' {
+			value = string(last) + value[:len(value)-1]
+		}
+	}
+	if scale > 0 {
+		value = insertDecimalPoint(value, scale)
+	}
+	return value, nil
+}
+
+// decodeOverpunch decodes the digit with an overpunched sign, where '{' and
+// 'A' to 'I' are +0 to +9, and '}' and 'J' to 'R' are -0 to -9. A plain digit
+// is positive.
+func decodeOverpunch(c byte) (digit byte, negative bool, err error) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c, false, nil
+	case c == '{':
+		return '0', false, nil
+	case 'A' <= c && c <= 'I':
+		return '1' + c - 'A', false, nil
+	case c == '}':
+		return '0', true, nil
+	case 'J' <= c && c <= 'R':
+		return '1' + c - 'J', true, nil
+	default:
+		return 0, false, errors.Errorf("invalid overpunched digit '%c'", c)
+	}
+}
+
+// insertDecimalPoint inserts the implied decimal point before the last `scale`
+// digits of the numeric value.
+func insertDecimalPoint(value string, scale int) string {
+	var sign string
+	if value[0] == '+' || value[0] == '-' {
+		sign, value = value[:1], value[1:]
+	}
+	if len(value) <= scale {
+		value = strings.Repeat("0", scale-len(value)+1) + value
+	}
+	return sign + value[:len(value)-scale] + "." + value[len(value)-scale:]
+}
+
+// ReadUntilTerminator seeks the file until the end of the current line, and
+// returns the file offset beyond the '\n'.
+// This function is used in dividing a fixed-width file.
+func (parser *FixedWidthParser) ReadUntilTerminator() (int64, error) {
+	if _, err := parser.readLine(); err != nil {
+		return 0, err
+	}
+	return parser.pos, nil
+}
+
+var (
+	copybookEntryRegexp = regexp.MustCompile(`\.(?:\s+|$)`)
+	pictureRepeatRegexp = regexp.MustCompile(`(.)\((\d+)\)`)
+)
+
+// ParseCopybook parses the fields of the records described by a COBOL
+// copybook. Only the elementary items of USAGE DISPLAY are supported, the
+// group items only group the fields. The names are converted into lower case
+// with '-' replaced by '_', and the FILLER items are skipped.
+//
+// The supported picture symbols are X, A and 9 for the characters, V for the
+// implied decimal point, S for the sign, and the editing symbols Z, 0, B, /,
+// ',', '.', '+', '-', '*' and '$'. The sign takes a character only if it is
+// SIGN IS SEPARATE, otherwise it is overpunched on the trailing digit, or the
+// leading digit with SIGN IS LEADING.
+func ParseCopybook(copybook string) ([]config.FixedWidthColumn, error) {
+	// drop the comment lines.
+	lines := strings.Split(copybook, "\n")
+	text := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "*") {
+			continue
+		}
+		text = append(text, line)
+	}
+
+	var fields []config.FixedWidthColumn
+	offset := 0
+	for _, entry := range copybookEntryRegexp.Split(strings.Join(text, "\n"), -1) {
+		tokens := strings.Fields(strings.ToUpper(entry))
+		if len(tokens) == 0 {
+			continue
+		}
+		level, err := strconv.Atoi(tokens[0])
+		if err != nil {
+			return nil, errors.Errorf("invalid level number in copybook entry '%s'", strings.TrimSpace(entry))
+		}
+		if level == 88 {
+			// the condition names occupy no space.
+			continue
+		}
+		tokens = tokens[1:]
+		name := "FILLER"
+		if len(tokens) > 0 && !isCopybookKeyword(tokens[0]) {
+			name, tokens = tokens[0], tokens[1:]
+		}
+
+		var picture string
+		leadingSign, separateSign := false, false
+	clauses:
+		for i := 0; i < len(tokens); i++ {
+			switch tokens[i] {
+			case "PIC", "PICTURE":
+				i++
+				if i < len(tokens) && tokens[i] == "IS" {
+					i++
+				}
+				if i >= len(tokens) {
+					return nil, errors.Errorf("missing picture string of copybook item %s", name)
+				}
+				picture = tokens[i]
+			case "USAGE", "IS", "DISPLAY", "SIGN", "TRAILING", "CHARACTER":
+			case "LEADING":
+				leadingSign = true
+			case "SEPARATE":
+				separateSign = true
+			case "COMP", "COMP-1", "COMP-2", "COMP-3", "COMP-4", "COMP-5",
+				"COMPUTATIONAL", "COMPUTATIONAL-1", "COMPUTATIONAL-2", "COMPUTATIONAL-3",
+				"COMPUTATIONAL-4", "COMPUTATIONAL-5", "BINARY", "PACKED-DECIMAL":
+				return nil, errors.Errorf("unsupported USAGE %s of copybook item %s, only USAGE DISPLAY is supported", tokens[i], name)
+			case "VALUE", "VALUES":
+				// the initial values are irrelevant to the data files.
+				break clauses
+			default:
+				return nil, errors.Errorf("unsupported clause %s of copybook item %s", tokens[i], name)
+			}
+		}
+		if len(picture) == 0 {
+			// a group item.
+			continue
+		}
+		width, scale, signed, err := parsePicture(picture)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid picture string of copybook item %s", name)
+		}
+		var sign string
+		if signed {
+			switch {
+			case separateSign && leadingSign:
+				// the leading '+' or '-' needs no decoding.
+				width++
+			case separateSign:
+				width++
+				sign = config.FixedWidthSignTrailingSeparate
+			case leadingSign:
+				sign = config.FixedWidthSignLeading
+			default:
+				sign = config.FixedWidthSignTrailing
+			}
+		}
+		if name != "FILLER" {
+			fields = append(fields, config.FixedWidthColumn{
+				Name:  strings.ReplaceAll(strings.ToLower(name), "-", "_"),
+				Start: offset,
+				Width: width,
+				Scale: scale,
+				Sign:  sign,
+			})
+		}
+		offset += width
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("no field is defined in the copybook")
+	}
+	return fields, nil
+}
+
+func isCopybookKeyword(token string) bool {
+	switch token {
+	case "PIC", "PICTURE", "USAGE", "VALUE", "VALUES", "OCCURS", "REDEFINES", "SIGN":
+		return true
+	default:
+		return false
+	}
+}
+
+// parsePicture returns the width, the number of implied decimal digits and
+// whether the picture string is signed. The width excludes the sign.
+func parsePicture(picture string) (width int, scale int, signed bool, err error) {
+	expanded := pictureRepeatRegexp.ReplaceAllStringFunc(picture, func(s string) string {
+		m := pictureRepeatRegexp.FindStringSubmatch(s)
+		n, _ := strconv.Atoi(m[2])
+		return strings.Repeat(m[1], n)
+	})
+	afterPoint := false
+	for i, c := range expanded {
+		switch c {
+		case 'X', 'A', 'Z', '0', 'B', '/', ',', '.', '+', '-', '*', '$':
+			width++
+		case '9':
+			width++
+			if afterPoint {
+				scale++
+			}
+		case 'S':
+			if i > 0 {
+				return 0, 0, false, errors.New("S is not the first symbol")
+			}
+			signed = true
+		case 'V':
+			if afterPoint {
+				return 0, 0, false, errors.New("more than one V")
+			}
+			afterPoint = true
+		default:
+			return 0, 0, false, errors.Errorf("unsupported symbol %c", c)
+		}
+	}
+	if width == 0 {
+		return 0, 0, false, errors.New("empty picture")
+	}
+	return width, scale, signed, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/worker"
+)
+
+var _ = Suite(&testMydumpFixedWidthParserSuite{})
+
+type testMydumpFixedWidthParserSuite struct {
+	ioWorkers *worker.Pool
+}
+
+func (s *testMydumpFixedWidthParserSuite) SetUpSuite(c *C) {
+	s.ioWorkers = worker.NewPool(context.Background(), 5, "test_fixed_width")
+}
+
+func (s *testMydumpFixedWidthParserSuite) newParser(c *C, rule *config.FixedWidthRule, content string) *mydump.FixedWidthParser {
+	layout, err := mydump.NewFixedWidthLayout(rule)
+	c.Assert(err, IsNil)
+	return mydump.NewFixedWidthParser(layout, mydump.NewStringReader(content), int64(config.ReadBlockSize), s.ioWorkers)
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestReadRow(c *C) {
+	null := "?"
+	rule := &config.FixedWidthRule{
+		Columns: []*config.FixedWidthColumn{
+			{Name: "ID", Start: 0, Width: 3},
+			{Name: "name", Start: 3, Width: 6},
+			{Name: "amount", Start: 9, Width: 6, Scale: 2},
+		},
+		Trim: true,
+		Null: &null,
+	}
+	content := "001Alice -01234\n\n" +
+		"002  ?      5\r\n" +
+		"003Bob"
+	parser := s.newParser(c, rule, content)
+	c.Assert(parser.Columns(), DeepEquals, []string{"id", "name", "amount"})
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{
+		RowID:  1,
+		Row:    []types.Datum{types.NewStringDatum("001"), types.NewStringDatum("Alice"), types.NewStringDatum("-012.34")},
+		Length: 15,
+	})
+	pos, _ := parser.Pos()
+	c.Assert(pos, Equals, int64(16))
+
+	// the empty lines are skipped, and the CRLF is stripped.
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{
+		RowID:  2,
+		Row:    []types.Datum{types.NewStringDatum("002"), types.NewDatum(nil), types.NewStringDatum("0.05")},
+		Length: 13,
+	})
+
+	// the fields beyond the end of a short record are empty.
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{
+		RowID:  3,
+		Row:    []types.Datum{types.NewStringDatum("003"), types.NewStringDatum("Bob"), types.NewStringDatum("")},
+		Length: 6,
+	})
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestNoTrim(c *C) {
+	rule := &config.FixedWidthRule{
+		Columns: []*config.FixedWidthColumn{
+			{Name: "a", Start: 2, Width: 3},
+			{Name: "b", Start: 0, Width: 2},
+		},
+	}
+	parser := s.newParser(c, rule, "xy z \n")
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum(" z "), types.NewStringDatum("xy")})
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestReadUntilTerminator(c *C) {
+	rule := &config.FixedWidthRule{Columns: []*config.FixedWidthColumn{{Name: "a", Width: 3}}}
+	parser := s.newParser(c, rule, "aaa\nbbb\nccc")
+
+	c.Assert(parser.SetPos(2, 0), IsNil)
+	pos, err := parser.ReadUntilTerminator()
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(4))
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum("bbb")})
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestParseCopybook(c *C) {
+	fields, err := mydump.ParseCopybook(`
+      * customer record
+       01  CUSTOMER-REC.
+           05  CUST-ID        PIC 9(5).
+           05  FILLER         PIC X.
+           05  CUST-NAME      PIC X(10) VALUE SPACES.
+           05  BALANCE        PIC S9(5)V99 SIGN IS LEADING SEPARATE.
+               88  OVERDRAWN  VALUE -1.
+           05  OPENED.
+               10  OPENED-YEAR    PICTURE IS 9999.
+               10  OPENED-MONTH   PIC 99 USAGE DISPLAY.
+           05  RATE           PIC ZZ9.99.
+`)
+	c.Assert(err, IsNil)
+	c.Assert(fields, DeepEquals, []config.FixedWidthColumn{
+		{Name: "cust_id", Start: 0, Width: 5},
+		{Name: "cust_name", Start: 6, Width: 10},
+		{Name: "balance", Start: 16, Width: 8, Scale: 2},
+		{Name: "opened_year", Start: 24, Width: 4},
+		{Name: "opened_month", Start: 28, Width: 2},
+		{Name: "rate", Start: 30, Width: 6},
+	})
+
+	cases := []struct {
+		copybook string
+		err      string
+	}{
+		{"01 REC. 05 A PIC X(3) OCCURS 2.", "unsupported clause OCCURS of copybook item A"},
+		{"01 REC. 05 A PIC S9(4) COMP-3.", "unsupported USAGE COMP-3 of copybook item A, only USAGE DISPLAY is supported"},
+		{"01 REC. 05 A PIC S9(4) USAGE IS COMPUTATIONAL.", "unsupported USAGE COMPUTATIONAL of copybook item A, .*"},
+		{"01 REC. 05 A PIC 9(4)S.", "invalid picture string of copybook item A: S is not the first symbol"},
+		{"01 REC. 05 A PIC 9V9V9.", "invalid picture string of copybook item A: more than one V"},
+		{"01 REC. 05 A PIC N(3).", "invalid picture string of copybook item A: unsupported symbol N"},
+		{"REC PIC X.", "invalid level number in copybook entry 'REC PIC X'"},
+		{"01 REC.", "no field is defined in the copybook"},
+	}
+	for _, ca := range cases {
+		_, err := mydump.ParseCopybook(ca.copybook)
+		c.Assert(err, ErrorMatches, ca.err, Commentf("copybook = %s", ca.copybook))
+	}
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestSignedCopybook(c *C) {
+	fields, err := mydump.ParseCopybook(`
+       01  REC.
+           05  TRAILING-AMT   PIC S9(5)V99.
+           05  LEADING-AMT    PIC S9(5)V99 SIGN IS LEADING.
+           05  SEPARATE-AMT   PIC S9(5)V99 SIGN IS TRAILING SEPARATE CHARACTER.
+           05  COUNT          PIC S999.
+`)
+	c.Assert(err, IsNil)
+	c.Assert(fields, DeepEquals, []config.FixedWidthColumn{
+		{Name: "trailing_amt", Start: 0, Width: 7, Scale: 2, Sign: config.FixedWidthSignTrailing},
+		{Name: "leading_amt", Start: 7, Width: 7, Scale: 2, Sign: config.FixedWidthSignLeading},
+		{Name: "separate_amt", Start: 14, Width: 8, Scale: 2, Sign: config.FixedWidthSignTrailingSeparate},
+		{Name: "count", Start: 22, Width: 3, Sign: config.FixedWidthSignTrailing},
+	})
+
+	parser := s.newParser(c, &config.FixedWidthRule{Copybook: `
+       01  REC.
+           05  TRAILING-AMT   PIC S9(5)V99.
+           05  LEADING-AMT    PIC S9(5)V99 SIGN IS LEADING.
+           05  SEPARATE-AMT   PIC S9(5)V99 SIGN IS TRAILING SEPARATE CHARACTER.
+           05  COUNT          PIC S999.
+`}, "123456PJ2345671234567-00{\n000000{A0000001234567+12}\n000000Z")
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{
+		types.NewStringDatum("-12345.67"),
+		types.NewStringDatum("-12345.67"),
+		types.NewStringDatum("-12345.67"),
+		types.NewStringDatum("000"),
+	})
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{
+		types.NewStringDatum("00000.00"),
+		types.NewStringDatum("10000.00"),
+		types.NewStringDatum("+12345.67"),
+		types.NewStringDatum("-120"),
+	})
+	c.Assert(parser.ReadRow(), ErrorMatches, "invalid value of field trailing_amt: invalid overpunched digit 'Z'")
+}
+
+func (s *testMydumpFixedWidthParserSuite) TestCopybookLayout(c *C) {
+	parser := s.newParser(c, &config.FixedWidthRule{
+		Copybook: "01 REC. 05 ID PIC 9(3). 05 FILLER PIC XX. 05 TOTAL PIC 9(3)V9.",
+		Trim:     true,
+	}, "042--1234\n")
+	c.Assert(parser.Columns(), DeepEquals, []string{"id", "total"})
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum("042"), types.NewStringDatum("123.4")})
+
+	_, err := mydump.NewFixedWidthLayout(&config.FixedWidthRule{
+		Columns: []*config.FixedWidthColumn{{Name: "a", Width: 1}, {Name: "A", Start: 1, Width: 1}},
+	})
+	c.Assert(err, ErrorMatches, "duplicate column a in the fixed-width layout")
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	tidbjson "github.com/pingcap/tidb/types/json"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/worker"
+)
+
+// JSONParser is a parser of the newline-delimited JSON (JSON Lines) files, in
+// which every line is a JSON object representing a row.
+//
+// The keys of the objects are mapped to the columns. If the columns are not
+// set by SetColumns, they are the keys of the first object read by the parser,
+// just like the header of a CSV file. A missing key of an object is filled by
+// a MinNotNull datum, which would be encoded as the default value of the
+// column.
+type JSONParser struct {
+	blockParser
+
+	// columnIndex maps the lower-case column names to the positions in the row.
+	columnIndex map[string]int
+	// unknownKeys are the keys not found in the columns, which are skipped.
+	unknownKeys map[string]struct{}
+	fields      []jsonField
+}
+
+type jsonField struct {
+	index int
+	value json.RawMessage
+}
+
+// NewJSONParser creates a JSON Lines parser.
+func NewJSONParser(
+	reader ReadSeekCloser,
+	blockBufSize int64,
+	ioWorkers *worker.Pool,
+) *JSONParser {
+	return &JSONParser{
+		blockParser: makeBlockParser(reader, blockBufSize, ioWorkers),
+		unknownKeys: make(map[string]struct{}),
+	}
+}
+
+// SetColumns sets the columns which the keys of the JSON objects are mapped to.
+func (parser *JSONParser) SetColumns(columns []string) {
+	parser.columns = columns
+	parser.columnIndex = make(map[string]int, len(columns))
+	for i, col := range columns {
+		parser.columnIndex[strings.ToLower(col)] = i
+	}
+}
+
+// ReadRow reads a row from the datafile.
+func (parser *JSONParser) ReadRow() error {
+	parser.beginRow()
+	row := &parser.lastRow
+	row.Length = 0
+	row.RowID++
+
+	var line []byte
+	for len(line) == 0 {
+		l, err := parser.readLine()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		line = bytes.TrimSpace(l)
+	}
+	row.Length = len(line)
+
+	if err := parser.readFields(line); err != nil {
+		content := line
+		if len(content) > 256 {
+			content = content[:256]
+		}
+		parser.Logger.Error("syntax error",
+			zap.Int64("pos", parser.pos),
+			zap.ByteString("content", content),
+		)
+		return errors.Annotate(err, "syntax error in JSON object")
+	}
+
+	row.Row = parser.acquireDatumSlice()
+	if cap(row.Row) >= len(parser.columns) {
+		row.Row = row.Row[:len(parser.columns)]
+	} else {
+		row.Row = make([]types.Datum, len(parser.columns))
+	}
+	for i := range row.Row {
+		row.Row[i].SetMinNotNull()
+	}
+	for _, field := range parser.fields {
+		if err := setJSONDatum(&row.Row[field.index], field.value); err != nil {
+			return errors.Annotatef(err, "invalid value of column %s", parser.columns[field.index])
+		}
+	}
+	return nil
+}
+
+// readFields decodes the top-level fields of the JSON object in the line.
+func (parser *JSONParser) readFields(line []byte) error {
+	parser.fields = parser.fields[:0]
+	// the columns are taken from the first object if they are not set.
+	inferColumns := parser.columnIndex == nil
+	if inferColumns {
+		parser.columnIndex = make(map[string]int)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	tok, err := decoder.Token()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if tok != json.Delim('{') {
+		return errors.Errorf("expected a JSON object, got %v", tok)
+	}
+	for decoder.More() {
+		tok, err = decoder.Token()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		key := strings.ToLower(tok.(string))
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return errors.Trace(err)
+		}
+		index, ok := parser.columnIndex[key]
+		if !ok {
+			if !inferColumns {
+				parser.skipUnknownKey(key)
+				continue
+			}
+			index = len(parser.columns)
+			parser.columns = append(parser.columns, key)
+			parser.columnIndex[key] = index
+		}
+		parser.fields = append(parser.fields, jsonField{index: index, value: value})
+	}
+	if _, err = decoder.Token(); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return errors.New("unexpected content after the JSON object")
+	}
+	return nil
+}
+
+func (parser *JSONParser) skipUnknownKey(key string) {
+	if _, ok := parser.unknownKeys[key]; ok {
+		return
+	}
+	parser.unknownKeys[key] = struct{}{}
+	parser.Logger.Warn("skip the unknown key in JSON objects",
+		zap.String("key", key), zap.Strings("columns", parser.columns))
+}
+
+// setJSONDatum converts the JSON value to a datum. The strings and numbers are
+// kept as strings and casted by the column type, the nested objects and arrays
+// are kept as JSON.
+func setJSONDatum(d *types.Datum, value json.RawMessage) error {
+	switch value[0] {
+	case 'n':
+		d.SetNull()
+	case 't':
+		d.SetInt64(1)
+	case 'f':
+		d.SetInt64(0)
+	case '"':
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return errors.Trace(err)
+		}
+		d.SetString(s, "utf8mb4_bin")
+	case '{', '[':
+		bj, err := tidbjson.ParseBinaryFromString(string(value))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		d.SetMysqlJSON(bj)
+	default:
+		d.SetString(string(value), "utf8mb4_bin")
+	}
+	return nil
+}
+
+// ReadUntilTerminator seeks the file until the end of the current line, and
+// returns the file offset beyond the '\n'.
+// This function is used in dividing a JSON Lines file.
+func (parser *JSONParser) ReadUntilTerminator() (int64, error) {
+	if _, err := parser.readLine(); err != nil {
+		return 0, err
+	}
+	return parser.pos, nil
+}
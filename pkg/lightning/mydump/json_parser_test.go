@@ -0,0 +1,115 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mydump_test
+
+import (
+	"context"
+	"io"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
+
+	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/mydump"
+	"github.com/pingcap/br/pkg/lightning/worker"
+)
+
+var _ = Suite(&testMydumpJSONParserSuite{})
+
+type testMydumpJSONParserSuite struct {
+	ioWorkers *worker.Pool
+}
+
+func (s *testMydumpJSONParserSuite) SetUpSuite(c *C) {
+	s.ioWorkers = worker.NewPool(context.Background(), 5, "test_json")
+}
+
+func missingDatum() types.Datum {
+	d := types.Datum{}
+	d.SetMinNotNull()
+	return d
+}
+
+func (s *testMydumpJSONParserSuite) TestReadRow(c *C) {
+	content := `{"a": 1, "B": "x\ty", "c": {"k": [1, 2]}}` + "\n\n" +
+		`{"b": "y", "a": null}` + "\r\n" +
+		`{"a": 2.50, "d": true, "c": [false]}`
+	parser := mydump.NewJSONParser(mydump.NewStringReader(content), int64(config.ReadBlockSize), s.ioWorkers)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.Columns(), DeepEquals, []string{"a", "b", "c"})
+	nested, err := json.ParseBinaryFromString(`{"k": [1, 2]}`)
+	c.Assert(err, IsNil)
+	c.Assert(parser.LastRow(), DeepEquals, mydump.Row{
+		RowID:  1,
+		Row:    []types.Datum{types.NewStringDatum("1"), types.NewStringDatum("x\ty"), types.NewJSONDatum(nested)},
+		Length: 41,
+	})
+	c.Assert(parser, posEq, 42, 1)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{nullDatum, types.NewStringDatum("y"), missingDatum()})
+	c.Assert(parser, posEq, 66, 2)
+
+	// the unknown key `d` is skipped.
+	c.Assert(parser.ReadRow(), IsNil)
+	array, err := json.ParseBinaryFromString(`[false]`)
+	c.Assert(err, IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{types.NewStringDatum("2.50"), missingDatum(), types.NewJSONDatum(array)})
+	c.Assert(parser, posEq, len(content), 3)
+
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testMydumpJSONParserSuite) TestSetColumnsAndPos(c *C) {
+	content := `{"a": 1}` + "\n" + `{"c": true, "b": "x"}` + "\n"
+	parser := mydump.NewJSONParser(mydump.NewStringReader(content), int64(config.ReadBlockSize), s.ioWorkers)
+	parser.SetColumns([]string{"A", "b", "c"})
+	c.Assert(parser.SetPos(9, 1), IsNil)
+
+	c.Assert(parser.ReadRow(), IsNil)
+	c.Assert(parser.LastRow().Row, DeepEquals, []types.Datum{missingDatum(), types.NewStringDatum("x"), types.NewIntDatum(1)})
+	c.Assert(parser, posEq, len(content), 2)
+	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+}
+
+func (s *testMydumpJSONParserSuite) TestSyntaxError(c *C) {
+	inputs := []string{
+		`[1, 2]`,
+		`{"a": 1} {"b": 2}`,
+		`{"a": 1`,
+		`{"a": 1}}`,
+		"{\"a\":\n1}",
+	}
+	for _, input := range inputs {
+		parser := mydump.NewJSONParser(mydump.NewStringReader(input), int64(config.ReadBlockSize), s.ioWorkers)
+		c.Assert(parser.ReadRow(), ErrorMatches, "syntax error in JSON object.*", Commentf("input = %q", input))
+	}
+}
+
+func (s *testMydumpJSONParserSuite) TestReadUntilTerminator(c *C) {
+	content := `{"a": 1}` + "\n" + `{"a": 2}`
+	parser := mydump.NewJSONParser(mydump.NewStringReader(content), int64(config.ReadBlockSize), s.ioWorkers)
+	c.Assert(parser.SetPos(3, 0), IsNil)
+	pos, err := parser.ReadUntilTerminator()
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(9))
+	pos, err = parser.ReadUntilTerminator()
+	c.Assert(err, IsNil)
+	c.Assert(pos, Equals, int64(len(content)))
+	_, err = parser.ReadUntilTerminator()
+	c.Assert(errors.Cause(err), Equals, io.EOF)
+}
@@ -3,17 +3,22 @@ package mydump
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	gojson "encoding/json"
 	"fmt"
 	"io"
 	"math/big"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
 	"github.com/xitongsys/parquet-go/parquet"
 	preader "github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
 	"github.com/xitongsys/parquet-go/source"
 	"go.uber.org/zap"
 
@@ -24,6 +29,9 @@ import (
 const (
 	batchReadRowSize = 32
 
+	// julianDayOfUnixEpoch is the Julian day of 1970-01-01.
+	julianDayOfUnixEpoch = 2440588
+
 	// if a parquet if small than this threshold, parquet will load the whole file in a byte slice to
 	// optimize the read performance
 	smallParquetFileThreshold = 256 * 1024 * 1024
@@ -32,13 +40,15 @@ const (
 type ParquetParser struct {
 	Reader      *preader.ParquetReader
 	columns     []string
-	columnMetas []*parquet.SchemaElement
-	rows        []interface{}
-	readRows    int64
-	curStart    int64
-	curIndex    int
-	lastRow     Row
-	logger      log.Logger
+	columnMetas []*parquetNode
+	// rowGroupStarts are the row numbers of the first rows of the row groups.
+	rowGroupStarts []int64
+	rows           []interface{}
+	readRows       int64
+	curStart       int64
+	curIndex       int
+	lastRow        Row
+	logger         log.Logger
 }
 
 // readerWrapper is a used for implement `source.ParquetFile`
@@ -135,6 +145,49 @@ func OpenParquetReader(
 	}, nil
 }
 
+// ParquetRowGroup is the summary of a row group in a parquet file.
+type ParquetRowGroup struct {
+	Rows int64
+	// Size is the compressed size of the column chunks in the row group.
+	Size int64
+}
+
+// ReadParquetFileRowGroups reads the row groups from the footer of the parquet file.
+func ReadParquetFileRowGroups(
+	ctx context.Context,
+	store storage.ExternalStorage,
+	r storage.ReadSeekCloser,
+	path string,
+) ([]ParquetRowGroup, error) {
+	wrapper := &readerWrapper{
+		ReadSeekCloser: r,
+		store:          store,
+		ctx:            ctx,
+		path:           path,
+	}
+	res := new(preader.ParquetReader)
+	res.NP = 1
+	res.PFile = wrapper
+	if err := res.ReadFooter(); err != nil {
+		return nil, err
+	}
+	rowGroups := make([]ParquetRowGroup, 0, len(res.Footer.RowGroups))
+	for _, rg := range res.Footer.RowGroups {
+		var size int64
+		for _, col := range rg.Columns {
+			size += col.GetMetaData().GetTotalCompressedSize()
+		}
+		if size == 0 {
+			size = rg.TotalByteSize
+		}
+		rowGroups = append(rowGroups, ParquetRowGroup{Rows: rg.NumRows, Size: size})
+	}
+	if err := wrapper.Close(); err != nil {
+		return nil, err
+	}
+	return rowGroups, nil
+}
+
 // a special func to fetch parquet file row count fast.
 func ReadParquetFileRowCount(
 	ctx context.Context,
@@ -185,32 +238,81 @@ func NewParquetParser(
 		return nil, errors.Trace(err)
 	}
 
-	columns := make([]string, 0, len(reader.Footer.Schema)-1)
-	columnMetas := make([]*parquet.SchemaElement, 0, len(reader.Footer.Schema)-1)
-	for _, c := range reader.SchemaHandler.SchemaElements {
-		if c.GetNumChildren() == 0 {
-			// NOTE: the SchemaElement.Name is capitalized, SchemaHandler.Infos.ExName is the raw column name
-			// though in this context, there is no difference between these two fields
-			columns = append(columns, strings.ToLower(c.Name))
+	root, err := buildParquetSchemaTree(reader.SchemaHandler)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// the top-level fields are the columns, the nested fields are converted to JSON.
+	columns := make([]string, 0, len(root.children))
+	for _, c := range root.children {
+		// NOTE: the SchemaElement.Name is capitalized, SchemaHandler.Infos.ExName is the raw column name
+		// though in this context, there is no difference between these two fields
+		columns = append(columns, strings.ToLower(c.meta.Name))
+	}
+
+	rowGroupStarts := make([]int64, 0, len(reader.Footer.RowGroups))
+	var rowGroupStart int64
+	for _, rg := range reader.Footer.RowGroups {
+		rowGroupStarts = append(rowGroupStarts, rowGroupStart)
+		rowGroupStart += rg.NumRows
+	}
+
+	return &ParquetParser{
+		Reader:         reader,
+		columns:        columns,
+		columnMetas:    root.children,
+		rowGroupStarts: rowGroupStarts,
+		logger:         log.L(),
+	}, nil
+}
+
+// parquetNode is a node of the parquet schema tree.
+type parquetNode struct {
+	// name is the raw field name.
+	name     string
+	meta     *parquet.SchemaElement
+	children []*parquetNode
+}
+
+func (n *parquetNode) isNested() bool {
+	return len(n.children) > 0 || n.meta.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED
+}
+
+// buildParquetSchemaTree builds the schema tree from the flattened schema
+// elements, the legacy ConvertedType of the leaves is transferred to LogicalType.
+func buildParquetSchemaTree(handler *schema.SchemaHandler) (*parquetNode, error) {
+	pos := 0
+	var build func() (*parquetNode, error)
+	build = func() (*parquetNode, error) {
+		if pos >= len(handler.SchemaElements) {
+			return nil, errors.New("invalid parquet schema")
+		}
+		idx := pos
+		pos++
+		meta := handler.SchemaElements[idx]
+		node := &parquetNode{name: handler.Infos[idx].ExName, meta: meta}
+		if meta.GetNumChildren() == 0 {
 			// transfer old ConvertedType to LogicalType
-			columnMeta := c
-			if c.ConvertedType != nil && c.LogicalType == nil {
-				newMeta := *c
-				columnMeta = &newMeta
-				if err := convertToLogicType(columnMeta); err != nil {
+			if meta.ConvertedType != nil && meta.LogicalType == nil {
+				newMeta := *meta
+				node.meta = &newMeta
+				if err := convertToLogicType(node.meta); err != nil {
 					return nil, err
 				}
 			}
-			columnMetas = append(columnMetas, columnMeta)
+			return node, nil
 		}
+		node.children = make([]*parquetNode, 0, meta.GetNumChildren())
+		for i := int32(0); i < meta.GetNumChildren(); i++ {
+			child, err := build()
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+		return node, nil
 	}
-
-	return &ParquetParser{
-		Reader:      reader,
-		columns:     columns,
-		columnMetas: columnMetas,
-		logger:      log.L(),
-	}, nil
+	return build()
 }
 
 func convertToLogicType(se *parquet.SchemaElement) error {
@@ -299,7 +401,10 @@ func convertToLogicType(se *parquet.SchemaElement) error {
 		logicalType.JSON = &parquet.JsonType{}
 	case parquet.ConvertedType_BSON:
 		logicalType.BSON = &parquet.BsonType{}
-	// case parquet.ConvertedType_INTERVAL, parquet.ConvertedType_MAP, parquet.ConvertedType_MAP_KEY_VALUE, parquet.ConvertedType_LIST:
+	case parquet.ConvertedType_INTERVAL:
+		// INTERVAL has no LogicalType, it's decoded by the ConvertedType.
+		return nil
+	// case parquet.ConvertedType_MAP, parquet.ConvertedType_MAP_KEY_VALUE, parquet.ConvertedType_LIST:
 	default:
 		return errors.Errorf("unsupported type: '%s'", *se.ConvertedType)
 	}
@@ -320,12 +425,20 @@ func (pp *ParquetParser) SetPos(pos int64, rowID int64) error {
 
 	if pos < pp.curStart+int64(len(pp.rows)) {
 		pp.curIndex = int(pos - pp.curStart)
-		pp.readRows = pos
 		return nil
 	}
 
-	if pos > pp.curStart+int64(len(pp.rows)) {
-		if err := pp.Reader.SkipRows(pos - pp.curStart - int64(len(pp.rows))); err != nil {
+	// skipping rows needs to read all the pages before the position, so we
+	// seek to the row group containing the position directly if possible.
+	skipRows := pos - pp.readRows
+	if index := pp.rowGroupIndex(pos); index > 0 && pp.rowGroupStarts[index] > pp.readRows {
+		if err := pp.seekRowGroup(index); err != nil {
+			return err
+		}
+		skipRows = pos - pp.rowGroupStarts[index]
+	}
+	if skipRows > 0 {
+		if err := pp.Reader.SkipRows(skipRows); err != nil {
 			return errors.Trace(err)
 		}
 	}
@@ -339,6 +452,32 @@ func (pp *ParquetParser) SetPos(pos int64, rowID int64) error {
 	return nil
 }
 
+// rowGroupIndex returns the index of the row group containing the row `pos`.
+func (pp *ParquetParser) rowGroupIndex(pos int64) int {
+	return sort.Search(len(pp.rowGroupStarts), func(i int) bool {
+		return pp.rowGroupStarts[i] > pos
+	}) - 1
+}
+
+// seekRowGroup reopens the column buffers of the reader at the beginning of
+// the row group `index`. The buffers are created by `reader.NewColumnBuffer`
+// with a copy of the footer starting from the row group, the footer of the
+// reader is kept so that `GetNumRows` still returns the rows of the file.
+func (pp *ParquetParser) seekRowGroup(index int) error {
+	footer := *pp.Reader.Footer
+	footer.RowGroups = footer.RowGroups[index:]
+	footer.NumRows -= pp.rowGroupStarts[index]
+	for path, cb := range pp.Reader.ColumnBuffers {
+		newCB, err := preader.NewColumnBuffer(pp.Reader.PFile, &footer, pp.Reader.SchemaHandler, path)
+		if err != nil {
+			return errors.Annotatef(err, "seek to row group %d", index)
+		}
+		cb.PFile.Close()
+		pp.Reader.ColumnBuffers[path] = newCB
+	}
+	return nil
+}
+
 func (pp *ParquetParser) Close() error {
 	pp.Reader.ReadStop()
 	return pp.Reader.PFile.Close()
@@ -378,7 +517,14 @@ func (pp *ParquetParser) ReadRow() error {
 	}
 	for i := 0; i < length; i++ {
 		pp.lastRow.Length += getDatumLen(v.Field(i))
-		if err := setDatumValue(&pp.lastRow.Row[i], v.Field(i), pp.columnMetas[i]); err != nil {
+		node := pp.columnMetas[i]
+		if node.isNested() {
+			if err := setDatumByNested(&pp.lastRow.Row[i], v.Field(i), node); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := setDatumValue(&pp.lastRow.Row[i], v.Field(i), node.meta); err != nil {
 			return err
 		}
 	}
@@ -393,8 +539,28 @@ func getDatumLen(v reflect.Value) int {
 			return getDatumLen(v.Elem())
 		}
 	}
-	if v.Kind() == reflect.String {
+	switch v.Kind() {
+	case reflect.String:
 		return len(v.String())
+	case reflect.Slice:
+		length := 0
+		for i := 0; i < v.Len(); i++ {
+			length += getDatumLen(v.Index(i))
+		}
+		return length
+	case reflect.Map:
+		length := 0
+		iter := v.MapRange()
+		for iter.Next() {
+			length += getDatumLen(iter.Key()) + getDatumLen(iter.Value())
+		}
+		return length
+	case reflect.Struct:
+		length := 0
+		for i := 0; i < v.NumField(); i++ {
+			length += getDatumLen(v.Field(i))
+		}
+		return length
 	}
 	return 8
 }
@@ -429,8 +595,29 @@ func setDatumValue(d *types.Datum, v reflect.Value, meta *parquet.SchemaElement)
 }
 
 func setDatumByString(d *types.Datum, v string, meta *parquet.SchemaElement) {
-	if meta.LogicalType != nil && meta.LogicalType.DECIMAL != nil {
+	switch {
+	case meta.GetType() == parquet.Type_INT96 && len(v) == 12:
+		// the deprecated INT96 timestamp, which is the nanoseconds of the day
+		// followed by the Julian day.
+		nanos := binary.LittleEndian.Uint64([]byte(v[:8]))
+		julianDay := binary.LittleEndian.Uint32([]byte(v[8:]))
+		t := time.Unix((int64(julianDay)-julianDayOfUnixEpoch)*86400, int64(nanos)).UTC()
+		v = t.Format("2006-01-02 15:04:05.999999Z")
+	case meta.ConvertedType != nil && *meta.ConvertedType == parquet.ConvertedType_INTERVAL && len(v) == 12:
+		// INTERVAL is the months, days and milliseconds in little-endian
+		// unsigned integers, which is kept as JSON.
+		interval := fmt.Sprintf(`{"months": %d, "days": %d, "milliseconds": %d}`,
+			binary.LittleEndian.Uint32([]byte(v[:4])),
+			binary.LittleEndian.Uint32([]byte(v[4:8])),
+			binary.LittleEndian.Uint32([]byte(v[8:])))
+		if bj, err := json.ParseBinaryFromString(interval); err == nil {
+			d.SetMysqlJSON(bj)
+			return
+		}
+	case meta.LogicalType != nil && meta.LogicalType.DECIMAL != nil:
 		v = binaryToDecimalStr([]byte(v), int(meta.LogicalType.DECIMAL.Scale))
+	case meta.LogicalType != nil && meta.LogicalType.UUID != nil && len(v) == 16:
+		v = fmt.Sprintf("%x-%x-%x-%x-%x", v[:4], v[4:6], v[6:8], v[8:10], v[10:])
 	}
 	d.SetString(v, "")
 }
@@ -477,6 +664,10 @@ func setDatumByInt(d *types.Datum, v int64, meta *parquet.SchemaElement) error {
 	}
 
 	logicalType := meta.LogicalType
+	if logicalType == nil {
+		d.SetInt64(v)
+		return nil
+	}
 	switch {
 	case logicalType.DECIMAL != nil:
 		if logicalType.DECIMAL.Scale == 0 {
@@ -488,8 +679,15 @@ func setDatumByInt(d *types.Datum, v int64, meta *parquet.SchemaElement) error {
 			minLen++
 		}
 		val := fmt.Sprintf("%0*d", minLen, v)
-		dotIndex := len(val) - int(*meta.Scale)
+		dotIndex := len(val) - int(logicalType.DECIMAL.Scale)
 		d.SetString(val[:dotIndex]+"."+val[dotIndex:], "")
+	case logicalType.INTEGER != nil && !logicalType.INTEGER.IsSigned:
+		// the unsigned integers are stored as the signed ones of the same width.
+		if logicalType.INTEGER.BitWidth <= 32 {
+			d.SetUint64(uint64(uint32(v)))
+		} else {
+			d.SetUint64(uint64(v))
+		}
 	case logicalType.DATE != nil:
 		dateStr := time.Unix(v*86400, 0).Format("2006-01-02")
 		d.SetString(dateStr, "")
@@ -509,6 +707,245 @@ func setDatumByInt(d *types.Datum, v int64, meta *parquet.SchemaElement) error {
 	return nil
 }
 
+// setDatumByNested converts a LIST, MAP, STRUCT or repeated value to JSON.
+func setDatumByNested(d *types.Datum, v reflect.Value, node *parquetNode) error {
+	value, err := nestedValue(v, node)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		d.SetNull()
+		return nil
+	}
+	content, err := gojson.Marshal(value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	bj, err := json.ParseBinaryFromString(string(content))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	d.SetMysqlJSON(bj)
+	return nil
+}
+
+// nestedValue converts the value of the schema node to a value which can be
+// marshaled to JSON.
+//
+// See: https://github.com/apache/parquet-format/blob/master/LogicalTypes.md#nested-types
+func nestedValue(v reflect.Value, node *parquetNode) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if node.meta.GetRepetitionType() != parquet.FieldRepetitionType_REPEATED {
+		return nestedElemValue(v, node)
+	}
+	if v.Kind() != reflect.Slice {
+		return nil, errors.Errorf("unexpected value of the repeated field %s: %s", node.name, v.Kind())
+	}
+	items := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item, err := nestedElemValue(v.Index(i), node)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// nestedElemValue converts a single occurrence of the schema node, regardless
+// of its repetition.
+func nestedElemValue(v reflect.Value, node *parquetNode) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch {
+	case len(node.children) == 0:
+		var d types.Datum
+		if err := setDatumValue(&d, v, node.meta); err != nil {
+			return nil, err
+		}
+		return d.GetValue(), nil
+	case isParquetList(node.meta):
+		return listValue(v, node)
+	case isParquetMap(node.meta):
+		return mapValue(v, node)
+	default:
+		return structValue(v, node)
+	}
+}
+
+func isParquetList(meta *parquet.SchemaElement) bool {
+	return meta.GetConvertedType() == parquet.ConvertedType_LIST ||
+		(meta.LogicalType != nil && meta.LogicalType.IsSetLIST())
+}
+
+func isParquetMap(meta *parquet.SchemaElement) bool {
+	ct := meta.GetConvertedType()
+	return ct == parquet.ConvertedType_MAP || ct == parquet.ConvertedType_MAP_KEY_VALUE ||
+		(meta.LogicalType != nil && meta.LogicalType.IsSetMAP())
+}
+
+// listValue converts a LIST, which is a group of a single repeated field. The
+// element of the list is resolved with the backward-compatibility rules:
+//   - a repeated primitive field is the element;
+//   - a repeated group with more than one field is the element;
+//   - a repeated group named `array` or `<list-name>_tuple` is the element;
+//   - otherwise the only field of the repeated group is the element.
+func listValue(v reflect.Value, node *parquetNode) (interface{}, error) {
+	if len(node.children) != 1 {
+		return nil, errors.Errorf("invalid parquet LIST %s: expect 1 field, but got %d", node.name, len(node.children))
+	}
+	repeated := node.children[0]
+	if repeated.meta.GetRepetitionType() != parquet.FieldRepetitionType_REPEATED {
+		return nil, errors.Errorf("invalid parquet LIST %s: the field %s is not repeated", node.name, repeated.name)
+	}
+	elemNode := repeated
+	if len(repeated.children) == 1 && repeated.name != "array" && repeated.name != node.name+"_tuple" {
+		elemNode = repeated.children[0]
+	}
+
+	elemValue := func(item reflect.Value) (interface{}, error) {
+		if elemNode == repeated {
+			return nestedElemValue(item, elemNode)
+		}
+		return nestedValue(item, elemNode)
+	}
+
+	var items []interface{}
+	switch v.Kind() {
+	case reflect.Slice:
+		// parquet-go reads the standard 3-level LIST as a slice of the elements.
+		items = make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := elemValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	case reflect.Struct:
+		// otherwise the LIST is read as a struct of the repeated field.
+		if v.NumField() != 1 || v.Field(0).Kind() != reflect.Slice {
+			return nil, errors.Errorf("unexpected value of the parquet LIST %s", node.name)
+		}
+		repeatedValue := v.Field(0)
+		items = make([]interface{}, 0, repeatedValue.Len())
+		for i := 0; i < repeatedValue.Len(); i++ {
+			item := repeatedValue.Index(i)
+			if elemNode != repeated {
+				if item.Kind() != reflect.Struct || item.NumField() != 1 {
+					return nil, errors.Errorf("unexpected value of the parquet LIST %s", node.name)
+				}
+				item = item.Field(0)
+			}
+			value, err := elemValue(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+	default:
+		return nil, errors.Errorf("unexpected value of the parquet LIST %s: %s", node.name, v.Kind())
+	}
+	return items, nil
+}
+
+// mapValue converts a MAP, which is a group of a repeated group `key_value` of
+// the required field `key` and the optional field `value`.
+func mapValue(v reflect.Value, node *parquetNode) (interface{}, error) {
+	if len(node.children) != 1 {
+		return nil, errors.Errorf("invalid parquet MAP %s: expect 1 field, but got %d", node.name, len(node.children))
+	}
+	keyValue := node.children[0]
+	if keyValue.meta.GetRepetitionType() != parquet.FieldRepetitionType_REPEATED ||
+		len(keyValue.children) == 0 || len(keyValue.children) > 2 {
+		return nil, errors.Errorf("invalid parquet MAP %s: expect a repeated group of the key and the value", node.name)
+	}
+	keyNode := keyValue.children[0]
+	var valueNode *parquetNode
+	if len(keyValue.children) == 2 {
+		valueNode = keyValue.children[1]
+	}
+
+	values := make(map[string]interface{})
+	setValue := func(k, v reflect.Value) error {
+		key, err := nestedValue(k, keyNode)
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return errors.Errorf("the key of the parquet MAP %s is null", node.name)
+		}
+		var value interface{}
+		if valueNode != nil {
+			if value, err = nestedValue(v, valueNode); err != nil {
+				return err
+			}
+		}
+		values[fmt.Sprint(key)] = value
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		// parquet-go reads the standard MAP as a map.
+		if valueNode == nil {
+			return nil, errors.Errorf("unexpected value of the parquet MAP %s", node.name)
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := setValue(iter.Key(), iter.Value()); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Struct:
+		// otherwise the MAP is read as a struct of the repeated group.
+		if v.NumField() != 1 || v.Field(0).Kind() != reflect.Slice {
+			return nil, errors.Errorf("unexpected value of the parquet MAP %s", node.name)
+		}
+		entries := v.Field(0)
+		for i := 0; i < entries.Len(); i++ {
+			entry := entries.Index(i)
+			if entry.Kind() != reflect.Struct || entry.NumField() != len(keyValue.children) {
+				return nil, errors.Errorf("unexpected value of the parquet MAP %s", node.name)
+			}
+			var value reflect.Value
+			if valueNode != nil {
+				value = entry.Field(1)
+			}
+			if err := setValue(entry.Field(0), value); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.Errorf("unexpected value of the parquet MAP %s: %s", node.name, v.Kind())
+	}
+	return values, nil
+}
+
+func structValue(v reflect.Value, node *parquetNode) (interface{}, error) {
+	if v.Kind() != reflect.Struct || v.NumField() != len(node.children) {
+		return nil, errors.Errorf("unexpected value of the parquet group %s", node.name)
+	}
+	values := make(map[string]interface{}, len(node.children))
+	for i, child := range node.children {
+		value, err := nestedValue(v.Field(i), child)
+		if err != nil {
+			return nil, err
+		}
+		values[child.name] = value
+	}
+	return values, nil
+}
+
 func formatTime(v int64, units *parquet.TimeUnit, format, utcFormat string, utc bool) string {
 	var sec, nsec int64
 	if units.MICROS != nil {
@@ -533,6 +970,11 @@ func (pp *ParquetParser) LastRow() Row {
 	return pp.lastRow
 }
 
+// RowText implements Parser. The rows have no text in a binary file.
+func (pp *ParquetParser) RowText() []byte {
+	return nil
+}
+
 func (pp *ParquetParser) RecycleRow(row Row) {
 }
 
@@ -2,14 +2,18 @@ package mydump
 
 import (
 	"context"
+	"encoding/binary"
 	"io"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"time"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
 	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
 	writer2 "github.com/xitongsys/parquet-go/writer"
 
 	"github.com/pingcap/br/pkg/storage"
@@ -265,3 +269,395 @@ func (s testParquetParserSuite) TestParquetAurora(c *C) {
 
 	c.Assert(parser.ReadRow(), Equals, io.EOF)
 }
+
+func (s testParquetParserSuite) TestParquetNestedTypes(c *C) {
+	type Attr struct {
+		Name  string `parquet:"name=name, type=UTF8"`
+		Count *int32 `parquet:"name=count, type=INT32"`
+	}
+	type Test struct {
+		ID    int32            `parquet:"name=id, type=INT32"`
+		Tags  []string         `parquet:"name=tags, type=LIST, valuetype=UTF8"`
+		Props map[string]int64 `parquet:"name=props, type=MAP, keytype=UTF8, valuetype=INT64"`
+		Attr  Attr             `parquet:"name=attr"`
+		Nums  []int32          `parquet:"name=nums, type=INT32, repetitiontype=REPEATED"`
+	}
+
+	dir := c.MkDir()
+	name := "nested.parquet"
+	pf, err := local.NewLocalFileWriter(filepath.Join(dir, name))
+	c.Assert(err, IsNil)
+	writer, err := writer2.NewParquetWriter(pf, new(Test), 1)
+	c.Assert(err, IsNil)
+	count := int32(3)
+	c.Assert(writer.Write(&Test{
+		ID:    1,
+		Tags:  []string{"a", "b"},
+		Props: map[string]int64{"x": 10},
+		Attr:  Attr{Name: "n", Count: &count},
+		Nums:  []int32{4, 5},
+	}), IsNil)
+	c.Assert(writer.Write(&Test{ID: 2}), IsNil)
+	c.Assert(writer.WriteStop(), IsNil)
+	c.Assert(pf.Close(), IsNil)
+
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	r, err := store.Open(context.TODO(), name)
+	c.Assert(err, IsNil)
+	reader, err := NewParquetParser(context.TODO(), store, r, name)
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	c.Assert(reader.Columns(), DeepEquals, []string{"id", "tags", "props", "attr", "nums"})
+
+	expected := [][]string{
+		{`["a", "b"]`, `{"x": 10}`, `{"count": 3, "name": "n"}`, `[4, 5]`},
+		{`[]`, `{}`, `{"count": null, "name": ""}`, `[]`},
+	}
+	for i, values := range expected {
+		c.Assert(reader.ReadRow(), IsNil)
+		row := reader.LastRow().Row
+		c.Assert(row, HasLen, 5)
+		c.Assert(row[0].GetInt64(), Equals, int64(i+1))
+		for j, value := range values {
+			c.Assert(row[j+1].Kind(), Equals, types.KindMysqlJSON, Commentf("row %d, column %d", i, j+1))
+			expectedJSON, err := json.ParseBinaryFromString(value)
+			c.Assert(err, IsNil)
+			c.Assert(row[j+1].GetMysqlJSON().String(), Equals, expectedJSON.String(), Commentf("row %d, column %d", i, j+1))
+		}
+	}
+	c.Assert(reader.ReadRow(), Equals, io.EOF)
+}
+
+func (s testParquetParserSuite) TestParquetLegacyNestedTypes(c *C) {
+	group := func(name string, rt parquet.FieldRepetitionType, ct *parquet.ConvertedType, numChildren int32) *parquet.SchemaElement {
+		return &parquet.SchemaElement{Name: name, RepetitionType: &rt, ConvertedType: ct, NumChildren: &numChildren}
+	}
+	leaf := func(name string, rt parquet.FieldRepetitionType, tp parquet.Type, ct *parquet.ConvertedType) *parquet.SchemaElement {
+		return &parquet.SchemaElement{Name: name, RepetitionType: &rt, Type: &tp, ConvertedType: ct}
+	}
+	list, mp, kv, utf8 := parquet.ConvertedType_LIST, parquet.ConvertedType_MAP, parquet.ConvertedType_MAP_KEY_VALUE, parquet.ConvertedType_UTF8
+	required, optional, repeated := parquet.FieldRepetitionType_REQUIRED, parquet.FieldRepetitionType_OPTIONAL, parquet.FieldRepetitionType_REPEATED
+	schemaElements := []*parquet.SchemaElement{
+		group("parquet_go_root", required, nil, 6),
+		leaf("id", required, parquet.Type_INT32, nil),
+		// 2-level LIST of a repeated primitive field.
+		group("nums", optional, &list, 1),
+		leaf("element", repeated, parquet.Type_INT32, nil),
+		// 2-level LIST of a repeated group with several fields.
+		group("pairs", optional, &list, 1),
+		group("pair", repeated, nil, 2),
+		leaf("a", required, parquet.Type_BYTE_ARRAY, &utf8),
+		leaf("b", required, parquet.Type_INT32, nil),
+		// the repeated groups named `array` and `<list-name>_tuple` are the elements.
+		group("arr", required, &list, 1),
+		group("array", repeated, nil, 1),
+		leaf("x", required, parquet.Type_INT32, nil),
+		group("points", required, &list, 1),
+		group("points_tuple", repeated, nil, 1),
+		leaf("x", required, parquet.Type_INT32, nil),
+		// MAP without the value field.
+		group("set", optional, &mp, 1),
+		group("key_value", repeated, &kv, 1),
+		leaf("key", required, parquet.Type_BYTE_ARRAY, &utf8),
+	}
+	type Pair struct {
+		A string
+		B int32
+	}
+	type X struct {
+		X int32
+	}
+	type Test struct {
+		Id     int32
+		Nums   *struct{ Element []int32 }
+		Pairs  *struct{ Pair []Pair }
+		Arr    struct{ Array []X }
+		Points struct{ Points_tuple []X }
+		Set    *struct{ Key_value []struct{ Key string } }
+	}
+
+	dir := c.MkDir()
+	name := "legacy.parquet"
+	pf, err := local.NewLocalFileWriter(filepath.Join(dir, name))
+	c.Assert(err, IsNil)
+	writer, err := writer2.NewParquetWriter(pf, schemaElements, 1)
+	c.Assert(err, IsNil)
+	test := &Test{Id: 1}
+	test.Nums = &struct{ Element []int32 }{Element: []int32{1, 2}}
+	test.Pairs = &struct{ Pair []Pair }{Pair: []Pair{{"x", 1}, {"y", 2}}}
+	test.Arr.Array = []X{{3}}
+	test.Points.Points_tuple = []X{{4}, {5}}
+	test.Set = &struct{ Key_value []struct{ Key string } }{Key_value: []struct{ Key string }{{"k"}}}
+	c.Assert(writer.Write(test), IsNil)
+	c.Assert(writer.Write(&Test{Id: 2}), IsNil)
+	c.Assert(writer.WriteStop(), IsNil)
+	c.Assert(pf.Close(), IsNil)
+
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	r, err := store.Open(context.TODO(), name)
+	c.Assert(err, IsNil)
+	reader, err := NewParquetParser(context.TODO(), store, r, name)
+	c.Assert(err, IsNil)
+	defer reader.Close()
+
+	c.Assert(reader.Columns(), DeepEquals, []string{"id", "nums", "pairs", "arr", "points", "set"})
+	expected := [][]string{
+		{`[1, 2]`, `[{"a": "x", "b": 1}, {"a": "y", "b": 2}]`, `[{"x": 3}]`, `[{"x": 4}, {"x": 5}]`, `{"k": null}`},
+		{``, ``, `[]`, `[]`, ``},
+	}
+	for i, values := range expected {
+		c.Assert(reader.ReadRow(), IsNil)
+		row := reader.LastRow().Row
+		c.Assert(row, HasLen, 6)
+		c.Assert(row[0].GetInt64(), Equals, int64(i+1))
+		for j, value := range values {
+			if value == "" {
+				c.Assert(row[j+1].IsNull(), IsTrue, Commentf("row %d, column %d", i, j+1))
+				continue
+			}
+			c.Assert(row[j+1].Kind(), Equals, types.KindMysqlJSON, Commentf("row %d, column %d", i, j+1))
+			expectedJSON, err := json.ParseBinaryFromString(value)
+			c.Assert(err, IsNil)
+			c.Assert(row[j+1].GetMysqlJSON().String(), Equals, expectedJSON.String(), Commentf("row %d, column %d", i, j+1))
+		}
+	}
+	c.Assert(reader.ReadRow(), Equals, io.EOF)
+}
+
+func (s testParquetParserSuite) TestParquetInvalidNestedTypes(c *C) {
+	required, repeated := parquet.FieldRepetitionType_REQUIRED, parquet.FieldRepetitionType_REPEATED
+	list, mp, int32Type := parquet.ConvertedType_LIST, parquet.ConvertedType_MAP, parquet.Type_INT32
+	leaf := &parquetNode{name: "element", meta: &parquet.SchemaElement{Type: &int32Type, RepetitionType: &repeated}}
+
+	cases := []struct {
+		node  *parquetNode
+		value interface{}
+		err   string
+	}{
+		{
+			// the repeated primitive field is the element, even if read as a slice.
+			node:  &parquetNode{name: "l", meta: &parquet.SchemaElement{ConvertedType: &list, RepetitionType: &required}, children: []*parquetNode{leaf}},
+			value: []int32{1, 2},
+		},
+		{
+			node:  &parquetNode{name: "l", meta: &parquet.SchemaElement{ConvertedType: &list, RepetitionType: &required}, children: []*parquetNode{leaf, leaf}},
+			value: struct{ A, B []int32 }{},
+			err:   "invalid parquet LIST l: expect 1 field, but got 2",
+		},
+		{
+			node: &parquetNode{name: "l", meta: &parquet.SchemaElement{ConvertedType: &list, RepetitionType: &required}, children: []*parquetNode{
+				{name: "element", meta: &parquet.SchemaElement{Type: &int32Type, RepetitionType: &required}},
+			}},
+			value: struct{ A int32 }{},
+			err:   "invalid parquet LIST l: the field element is not repeated",
+		},
+		{
+			node: &parquetNode{name: "m", meta: &parquet.SchemaElement{ConvertedType: &mp, RepetitionType: &required}, children: []*parquetNode{
+				{name: "key_value", meta: &parquet.SchemaElement{RepetitionType: &repeated}},
+			}},
+			value: map[string]int32{"a": 1},
+			err:   "invalid parquet MAP m: expect a repeated group of the key and the value",
+		},
+		{
+			node: &parquetNode{name: "m", meta: &parquet.SchemaElement{ConvertedType: &mp, RepetitionType: &required}, children: []*parquetNode{
+				{name: "key_value", meta: &parquet.SchemaElement{RepetitionType: &repeated}, children: []*parquetNode{leaf}},
+			}},
+			value: map[int32]int32{1: 1},
+			err:   "unexpected value of the parquet MAP m",
+		},
+	}
+	for _, ca := range cases {
+		var d types.Datum
+		err := setDatumByNested(&d, reflect.ValueOf(ca.value), ca.node)
+		if ca.err == "" {
+			c.Assert(err, IsNil)
+			c.Assert(d.GetMysqlJSON().String(), Equals, "[1, 2]")
+			continue
+		}
+		c.Assert(err, ErrorMatches, ca.err)
+	}
+}
+
+func (s testParquetParserSuite) TestParquetLogicalTypes(c *C) {
+	timestamp := func(unit *parquet.TimeUnit, utc bool) *parquet.LogicalType {
+		return &parquet.LogicalType{TIMESTAMP: &parquet.TimestampType{IsAdjustedToUTC: utc, Unit: unit}}
+	}
+	nanos := &parquet.TimeUnit{NANOS: parquet.NewNanoSeconds()}
+	millis := &parquet.TimeUnit{MILLIS: parquet.NewMilliSeconds()}
+	fixedLen := parquet.Type_FIXED_LEN_BYTE_ARRAY
+	int96 := parquet.Type_INT96
+
+	int96Value := make([]byte, 12)
+	binary.LittleEndian.PutUint64(int96Value, uint64(9*time.Hour+27*time.Minute+52*time.Second+356956*time.Microsecond))
+	binary.LittleEndian.PutUint32(int96Value[8:], 2440588+18564)
+
+	cases := []struct {
+		meta     *parquet.SchemaElement
+		value    interface{}
+		expected types.Datum
+	}{
+		{
+			meta:     &parquet.SchemaElement{LogicalType: timestamp(nanos, true)},
+			value:    int64(1603963672356956789),
+			expected: types.NewStringDatum("2020-10-29 09:27:52.356956Z"),
+		},
+		{
+			meta:     &parquet.SchemaElement{LogicalType: timestamp(millis, false)},
+			value:    int64(1603963672356),
+			expected: types.NewStringDatum("2020-10-29 09:27:52.356"),
+		},
+		{
+			meta:     &parquet.SchemaElement{LogicalType: &parquet.LogicalType{TIME: &parquet.TimeType{IsAdjustedToUTC: true, Unit: nanos}}},
+			value:    int64(62775123456789),
+			expected: types.NewStringDatum("17:26:15.123456Z"),
+		},
+		{
+			meta:     &parquet.SchemaElement{LogicalType: &parquet.LogicalType{DECIMAL: &parquet.DecimalType{Scale: 2, Precision: 9}}},
+			value:    int32(-12345),
+			expected: types.NewStringDatum("-123.45"),
+		},
+		{
+			meta:     &parquet.SchemaElement{Type: &fixedLen, LogicalType: &parquet.LogicalType{DECIMAL: &parquet.DecimalType{Scale: 2, Precision: 5}}},
+			value:    string([]byte{0xff, 0xcf, 0xc7}),
+			expected: types.NewStringDatum("-123.45"),
+		},
+		{
+			meta:     &parquet.SchemaElement{LogicalType: &parquet.LogicalType{INTEGER: &parquet.IntType{BitWidth: 32, IsSigned: false}}},
+			value:    int32(-1),
+			expected: types.NewUintDatum(4294967295),
+		},
+		{
+			meta:     &parquet.SchemaElement{LogicalType: &parquet.LogicalType{INTEGER: &parquet.IntType{BitWidth: 64, IsSigned: false}}},
+			value:    int64(-1),
+			expected: types.NewUintDatum(18446744073709551615),
+		},
+		{
+			meta:     &parquet.SchemaElement{Type: &fixedLen, LogicalType: &parquet.LogicalType{UUID: parquet.NewUUIDType()}},
+			value:    string([]byte{0xb1, 0xe7, 0xa6, 0xc4, 0x7f, 0x6c, 0x4a, 0x39, 0x9f, 0x5a, 0x8f, 0x0d, 0x8a, 0x1c, 0x2e, 0x3f}),
+			expected: types.NewStringDatum("b1e7a6c4-7f6c-4a39-9f5a-8f0d8a1c2e3f"),
+		},
+		{
+			meta:     &parquet.SchemaElement{Type: &int96},
+			value:    string(int96Value),
+			expected: types.NewStringDatum("2020-10-29 09:27:52.356956Z"),
+		},
+	}
+	for i, tc := range cases {
+		var d types.Datum
+		c.Assert(setDatumValue(&d, reflect.ValueOf(tc.value), tc.meta), IsNil, Commentf("case %d", i))
+		c.Assert(d.Kind(), Equals, tc.expected.Kind(), Commentf("case %d", i))
+		c.Assert(d.GetValue(), Equals, tc.expected.GetValue(), Commentf("case %d", i))
+	}
+
+	// INTERVAL has no LogicalType and is converted to JSON.
+	interval := parquet.ConvertedType_INTERVAL
+	meta := &parquet.SchemaElement{Type: &fixedLen, ConvertedType: &interval}
+	c.Assert(convertToLogicType(meta), IsNil)
+	value := make([]byte, 12)
+	binary.LittleEndian.PutUint32(value, 14)
+	binary.LittleEndian.PutUint32(value[4:], 3)
+	binary.LittleEndian.PutUint32(value[8:], 5000)
+	var d types.Datum
+	c.Assert(setDatumValue(&d, reflect.ValueOf(string(value)), meta), IsNil)
+	c.Assert(d.Kind(), Equals, types.KindMysqlJSON)
+	c.Assert(d.GetMysqlJSON().String(), Equals, `{"days": 3, "milliseconds": 5000, "months": 14}`)
+}
+
+// writeParquetRowGroups writes a parquet file of 100 rows in 10 row groups.
+func writeParquetRowGroups(c *C, path string) {
+	type Test struct {
+		S string `parquet:"name=s, type=UTF8"`
+		A int64  `parquet:"name=a, type=INT64"`
+	}
+	pf, err := local.NewLocalFileWriter(path)
+	c.Assert(err, IsNil)
+	writer, err := writer2.NewParquetWriter(pf, new(Test), 2)
+	c.Assert(err, IsNil)
+	for i := 0; i < 100; i++ {
+		c.Assert(writer.Write(&Test{S: strconv.Itoa(i), A: int64(i)}), IsNil)
+		if i%10 == 9 {
+			c.Assert(writer.Flush(true), IsNil)
+		}
+	}
+	c.Assert(writer.WriteStop(), IsNil)
+	c.Assert(pf.Close(), IsNil)
+}
+
+func (s testParquetParserSuite) TestParquetSeekRowGroup(c *C) {
+	dir := c.MkDir()
+	name := "row_groups.parquet"
+	writeParquetRowGroups(c, filepath.Join(dir, name))
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	rowGroups, err := func() ([]ParquetRowGroup, error) {
+		r, err := store.Open(context.TODO(), name)
+		c.Assert(err, IsNil)
+		return ReadParquetFileRowGroups(context.TODO(), store, r, name)
+	}()
+	c.Assert(err, IsNil)
+	c.Assert(rowGroups, HasLen, 10)
+	for _, rg := range rowGroups {
+		c.Assert(rg.Rows, Equals, int64(10))
+		c.Assert(rg.Size, Greater, int64(0))
+	}
+
+	verifyRow := func(reader *ParquetParser, i int) {
+		c.Assert(reader.ReadRow(), IsNil)
+		c.Assert(reader.lastRow.Row[0].GetString(), Equals, strconv.Itoa(i))
+		c.Assert(reader.lastRow.Row[1].GetInt64(), Equals, int64(i))
+		pos, _ := reader.Pos()
+		c.Assert(pos, Equals, int64(i+1))
+	}
+
+	for _, fileSize := range []int64{0, smallParquetFileThreshold + 1} {
+		r, err := OpenParquetReader(context.TODO(), store, name, fileSize)
+		c.Assert(err, IsNil)
+		reader, err := NewParquetParser(context.TODO(), store, r, name)
+		c.Assert(err, IsNil)
+
+		// seek to the beginning of a row group.
+		c.Assert(reader.SetPos(30, 100), IsNil)
+		verifyRow(reader, 30)
+		c.Assert(reader.lastRow.RowID, Equals, int64(101))
+		// seek into the buffered rows.
+		c.Assert(reader.SetPos(35, 105), IsNil)
+		verifyRow(reader, 35)
+		// seek into the middle of another row group.
+		c.Assert(reader.SetPos(77, 147), IsNil)
+		for i := 77; i < 100; i++ {
+			verifyRow(reader, i)
+		}
+		c.Assert(reader.ReadRow(), Equals, io.EOF)
+		c.Assert(reader.Close(), IsNil)
+	}
+}
+
+func (s testParquetParserSuite) TestMakeParquetFileRegions(c *C) {
+	dir := c.MkDir()
+	name := "db.tbl.parquet"
+	writeParquetRowGroups(c, filepath.Join(dir, name))
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	meta := &MDTableMeta{DB: "db", Name: "tbl"}
+	fileInfo := FileInfo{FileMeta: SourceFileMeta{Path: name, Type: SourceTypeParquet}}
+	// every row group is a region.
+	regions, sizes, err := makeParquetFileRegions(context.Background(), store, meta, fileInfo, 1)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 10)
+	c.Assert(sizes, HasLen, 10)
+	for i, region := range regions {
+		start := int64(i * 10)
+		c.Assert(region.Chunk, DeepEquals, Chunk{Offset: start, EndOffset: start + 10, PrevRowIDMax: start, RowIDMax: start + 10})
+	}
+
+	// all row groups are in one region.
+	regions, _, err = makeParquetFileRegions(context.Background(), store, meta, fileInfo, 1<<30)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, 1)
+	c.Assert(regions[0].Chunk, DeepEquals, Chunk{Offset: 0, EndOffset: 100, PrevRowIDMax: 0, RowIDMax: 100})
+}
@@ -41,6 +41,9 @@ type blockParser struct {
 	buf         []byte
 	blockBuf    []byte
 	isLastChunk bool
+	// rowStart is the buffer from the start of the row being read, `buf` is
+	// always a suffix of it, so the consumed prefix is the text of the row.
+	rowStart []byte
 
 	// The list of column names of the last INSERT statement.
 	columns []string
@@ -120,6 +123,10 @@ type Parser interface {
 	ReadRow() error
 	LastRow() Row
 	RecycleRow(row Row)
+	// RowText returns the original text of the row read by the last call to
+	// ReadRow, even if the row is malformed. It is only valid until the next
+	// call to ReadRow, and is nil if the data file is not a text file.
+	RowText() []byte
 
 	// Columns returns the _lower-case_ column names corresponding to values in
 	// the LastRow.
@@ -254,13 +261,16 @@ func (parser *blockParser) readBlock() error {
 		fallthrough
 	case nil:
 		// `parser.buf` reference to `appendBuf.Bytes`, so should use remainBuf to
-		// hold the `parser.buf` rest data to prevent slice overlap
+		// hold the `parser.buf` rest data to prevent slice overlap. The consumed
+		// text of the current row is kept as well.
+		consumed := len(parser.rowStart) - len(parser.buf)
 		parser.remainBuf.Reset()
-		parser.remainBuf.Write(parser.buf)
+		parser.remainBuf.Write(parser.rowStart)
 		parser.appendBuf.Reset()
 		parser.appendBuf.Write(parser.remainBuf.Bytes())
 		parser.appendBuf.Write(parser.blockBuf[:n])
-		parser.buf = parser.appendBuf.Bytes()
+		parser.rowStart = parser.appendBuf.Bytes()
+		parser.buf = parser.rowStart[consumed:]
 		metric.ChunkParserReadBlockSecondsHistogram.Observe(time.Since(startTime).Seconds())
 		return nil
 	default:
@@ -268,6 +278,31 @@ func (parser *blockParser) readBlock() error {
 	}
 }
 
+// readLine reads the buffer until the next '\n' and consumes it, the returned
+// line excludes the '\n'. The last line of the file may have no '\n' at end.
+func (parser *blockParser) readLine() ([]byte, error) {
+	for {
+		if index := bytes.IndexByte(parser.buf, '\n'); index >= 0 {
+			line := parser.buf[:index]
+			parser.buf = parser.buf[index+1:]
+			parser.pos += int64(index + 1)
+			return line, nil
+		}
+		if parser.isLastChunk {
+			line := parser.buf
+			parser.buf = nil
+			if len(line) == 0 {
+				return nil, io.EOF
+			}
+			parser.pos += int64(len(line))
+			return line, nil
+		}
+		if err := parser.readBlock(); err != nil {
+			return nil, err
+		}
+	}
+}
+
 var unescapeRegexp = regexp.MustCompile(`(?s)\\.`)
 
 func unescape(
@@ -412,6 +447,7 @@ func (parser *ChunkParser) ReadRow() error {
 	//              )             tokRowEnd
 	//                                              return
 
+	parser.beginRow()
 	row := &parser.lastRow
 	st := stateValues
 	row.Length = 0
@@ -528,6 +564,16 @@ func (parser *blockParser) LastRow() Row {
 	return parser.lastRow
 }
 
+// beginRow marks the start of the text of the row to be read.
+func (parser *blockParser) beginRow() {
+	parser.rowStart = parser.buf
+}
+
+// RowText implements Parser.
+func (parser *blockParser) RowText() []byte {
+	return parser.rowStart[:len(parser.rowStart)-len(parser.buf)]
+}
+
 // RecycleRow places the row object back into the allocation pool.
 func (parser *blockParser) RecycleRow(row Row) {
 	// We need farther benchmarking to make sure whether send a pointer
@@ -16,6 +16,7 @@ package mydump_test
 import (
 	"context"
 	"io"
+	"strings"
 
 	. "github.com/pingcap/check"
 	"github.com/pingcap/errors"
@@ -136,6 +137,34 @@ func (s *testMydumpParserSuite) TestReadRow(c *C) {
 	c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
 }
 
+func (s *testMydumpParserSuite) TestRowText(c *C) {
+	input := "INSERT INTO `t` (a, b) VALUES (1, 'x'),\n(2, 'y;z');\n" +
+		"INSERT INTO `t` VALUES (3, '" + strings.Repeat("w", 40) + "');"
+
+	// the text is kept even if the row spans several blocks.
+	for _, blockBufSize := range []int64{1, int64(config.ReadBlockSize)} {
+		parser := mydump.NewChunkParser(mysql.ModeNone, mydump.NewStringReader(input), blockBufSize, s.ioWorkers)
+		var prevOffset int64
+		for i := 0; i < 3; i++ {
+			c.Assert(parser.ReadRow(), IsNil)
+			offset, _ := parser.Pos()
+			c.Assert(string(parser.RowText()), Equals, input[prevOffset:offset])
+			prevOffset = offset
+		}
+		c.Assert(errors.Cause(parser.ReadRow()), Equals, io.EOF)
+	}
+
+	// the text of a malformed row is kept as well.
+	input = "INSERT INTO `t` VALUES (1, 'x'),\n(2 'y' , ;"
+	parser := mydump.NewChunkParser(mysql.ModeNone, mydump.NewStringReader(input), 1, s.ioWorkers)
+	c.Assert(parser.ReadRow(), IsNil)
+	offset, _ := parser.Pos()
+	c.Assert(parser.ReadRow(), NotNil)
+	newOffset, _ := parser.Pos()
+	c.Assert(newOffset, Greater, offset)
+	c.Assert(string(parser.RowText()), Equals, input[offset:newOffset])
+}
+
 func (s *testMydumpParserSuite) TestReadChunks(c *C) {
 	reader := mydump.NewStringReader(`
 		INSERT foo VALUES (1,2,3,4),(5,6,7,8),(9,10,11,12);
@@ -17,6 +17,7 @@ import (
 	"context"
 	"io"
 	"math"
+	"strings"
 	"sync"
 	"time"
 
@@ -253,22 +254,23 @@ func makeSourceFileRegion(
 	store storage.ExternalStorage,
 ) ([]*TableRegion, []float64, error) {
 	if fi.FileMeta.Type == SourceTypeParquet {
-		_, region, err := makeParquetFileRegion(ctx, store, meta, fi, 0)
-		if err != nil {
-			return nil, nil, err
-		}
-		return []*TableRegion{region}, []float64{float64(fi.FileMeta.FileSize)}, nil
+		return makeParquetFileRegions(ctx, store, meta, fi, int64(cfg.Mydumper.MaxRegionSize))
+	}
+	if fi.FileMeta.Type == SourceTypeAvro {
+		return makeAvroFileRegions(ctx, store, meta, fi, int64(cfg.Mydumper.MaxRegionSize))
 	}
 
 	dataFileSize := fi.FileMeta.FileSize
 	divisor := int64(columns)
 	isCsvFile := fi.FileMeta.Type == SourceTypeCSV
+	isLineFile := fi.FileMeta.Type == SourceTypeJSON || fi.FileMeta.Type == SourceTypeFixed
 	if !isCsvFile {
 		divisor += 2
 	}
 	// If a csv file is overlarge, we need to split it into multiple regions.
-	// Note: We can only split a csv file whose format is strict.
-	if isCsvFile && dataFileSize > int64(cfg.Mydumper.MaxRegionSize) && cfg.Mydumper.StrictFormat {
+	// Note: We can only split a csv file whose format is strict, while a JSON
+	// Lines or fixed-width file can always be split at the end of lines.
+	if (isCsvFile && cfg.Mydumper.StrictFormat || isLineFile) && dataFileSize > int64(cfg.Mydumper.MaxRegionSize) {
 		_, regions, subFileSizes, err := SplitLargeFile(ctx, meta, cfg, fi, divisor, 0, ioWorkers, store)
 		return regions, subFileSizes, err
 	}
@@ -294,40 +296,113 @@ func makeSourceFileRegion(
 	return []*TableRegion{tableRegion}, []float64{float64(fi.FileMeta.FileSize)}, nil
 }
 
-// because parquet files can't seek efficiently, there is no benefit in split.
-// parquet file are column orient, so the offset is read line number
-func makeParquetFileRegion(
+// makeParquetFileRegions splits a parquet file into regions of whole row
+// groups, the size of each region is at least `maxRegionSize` except the last
+// one. Parquet files are column oriented, so the offset is the row number, and
+// the offset of each region is the first row of a row group.
+func makeParquetFileRegions(
 	ctx context.Context,
 	store storage.ExternalStorage,
 	meta *MDTableMeta,
 	dataFile FileInfo,
-	prevRowIdxMax int64,
-) (int64, *TableRegion, error) {
+	maxRegionSize int64,
+) ([]*TableRegion, []float64, error) {
 	r, err := store.Open(ctx, dataFile.FileMeta.Path)
 	if err != nil {
-		return prevRowIdxMax, nil, errors.Trace(err)
+		return nil, nil, errors.Trace(err)
 	}
-	numberRows, err := ReadParquetFileRowCount(ctx, store, r, dataFile.FileMeta.Path)
+	rowGroups, err := ReadParquetFileRowGroups(ctx, store, r, dataFile.FileMeta.Path)
 	if err != nil {
-		return 0, nil, errors.Trace(err)
+		return nil, nil, errors.Trace(err)
 	}
-	rowIDMax := prevRowIdxMax + numberRows
-	region := &TableRegion{
-		DB:       meta.DB,
-		Table:    meta.Name,
-		FileMeta: dataFile.FileMeta,
-		Chunk: Chunk{
-			Offset:       0,
-			EndOffset:    numberRows,
-			PrevRowIDMax: prevRowIdxMax,
-			RowIDMax:     rowIDMax,
-		},
+	blocks := make([]rowBlock, 0, len(rowGroups))
+	for _, rg := range rowGroups {
+		blocks = append(blocks, rowBlock{rows: rg.Rows, size: rg.Size})
+	}
+	regions, sizes := makeRowBlockRegions(meta, dataFile, blocks, maxRegionSize)
+	return regions, sizes, nil
+}
+
+// terminatorReader seeks a data file to the end of a row.
+type terminatorReader interface {
+	SetPos(pos int64, rowID int64) error
+	ReadUntilTerminator() (int64, error)
+	Close() error
+}
+
+// makeAvroFileRegions splits an avro file into regions of whole blocks, the
+// size of each region is at least `maxRegionSize` except the last one. Like
+// parquet files, the offset is the row number.
+func makeAvroFileRegions(
+	ctx context.Context,
+	store storage.ExternalStorage,
+	meta *MDTableMeta,
+	dataFile FileInfo,
+	maxRegionSize int64,
+) ([]*TableRegion, []float64, error) {
+	avroBlocks, err := ReadAvroFileBlocks(ctx, store, dataFile.FileMeta.Path)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	blocks := make([]rowBlock, 0, len(avroBlocks))
+	for _, block := range avroBlocks {
+		blocks = append(blocks, rowBlock{rows: block.Rows, size: block.Size})
 	}
-	return rowIDMax, region, nil
+	regions, sizes := makeRowBlockRegions(meta, dataFile, blocks, maxRegionSize)
+	return regions, sizes, nil
 }
 
-// SplitLargeFile splits a large csv file into multiple regions, the size of
-// each regions is specified by `config.MaxRegionSize`.
+// rowBlock is a unit of rows which can't be split, e.g. an avro block or a
+// parquet row group.
+type rowBlock struct {
+	rows int64
+	size int64
+}
+
+// makeRowBlockRegions groups the blocks into regions, a new region is started
+// once the size of the current one reaches `maxRegionSize`. The offsets of the
+// regions are the row numbers.
+func makeRowBlockRegions(
+	meta *MDTableMeta,
+	dataFile FileInfo,
+	blocks []rowBlock,
+	maxRegionSize int64,
+) ([]*TableRegion, []float64) {
+	var regions []*TableRegion
+	var sizes []float64
+	var rows, size int64
+	newRegion := func() {
+		regions = append(regions, &TableRegion{
+			DB:       meta.DB,
+			Table:    meta.Name,
+			FileMeta: dataFile.FileMeta,
+			Chunk: Chunk{
+				Offset:       rows,
+				EndOffset:    rows,
+				PrevRowIDMax: rows,
+				RowIDMax:     rows,
+			},
+		})
+		sizes = append(sizes, 0)
+		size = 0
+	}
+	newRegion()
+	for _, block := range blocks {
+		if size >= maxRegionSize {
+			newRegion()
+		}
+		rows += block.rows
+		size += block.size
+		region := regions[len(regions)-1]
+		region.Chunk.EndOffset = rows
+		region.Chunk.RowIDMax = rows
+		sizes[len(sizes)-1] = float64(size)
+	}
+	return regions, sizes
+}
+
+// SplitLargeFile splits a large csv, JSON Lines or fixed-width file into multiple regions,
+// the size of each regions is specified by `config.MaxRegionSize`.
 // Note: We split the file coarsely, thus the format of csv file is needed to be
 // strict.
 // e.g.
@@ -346,18 +421,48 @@ func SplitLargeFile(
 	maxRegionSize := int64(cfg.Mydumper.MaxRegionSize)
 	dataFileSizes = make([]float64, 0, dataFile.FileMeta.FileSize/maxRegionSize+1)
 	startOffset, endOffset := int64(0), maxRegionSize
+	isCSVFile := dataFile.FileMeta.Type == SourceTypeCSV
+	terminator := strings.Join(cfg.Mydumper.CSV.GetTerminators(), " or ")
+	if !isCSVFile {
+		terminator = "\n"
+	}
+	var layout *FixedWidthLayout
+	if dataFile.FileMeta.Type == SourceTypeFixed {
+		if layout, err = GetFixedWidthLayout(cfg, meta.DB, meta.Name); err != nil {
+			return 0, nil, nil, err
+		}
+	}
 	var columns []string
-	if cfg.Mydumper.CSV.Header {
+	if cfg.Mydumper.CSV.Header && isCSVFile {
 		r, err := store.Open(ctx, dataFile.FileMeta.Path)
 		if err != nil {
 			return 0, nil, nil, err
 		}
+		convertor, err := NewCharsetConvertor(cfg.Mydumper.DataCharacterSet, cfg.Mydumper.DataInvalidChar)
+		if err != nil {
+			r.Close()
+			return 0, nil, nil, err
+		}
+		var decodeReader *DecodeReader
+		if convertor.DecodeStream() {
+			decodeReader = convertor.NewDecodeReader(r)
+			r = decodeReader
+		}
 		parser := NewCSVParser(&cfg.Mydumper.CSV, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker, true)
 		if err = parser.ReadColumns(); err != nil {
 			return 0, nil, nil, err
 		}
 		columns = parser.Columns()
 		startOffset, _ = parser.Pos()
+		if decodeReader != nil {
+			startOffset = decodeReader.SourceOffset(startOffset)
+		} else if convertor != nil {
+			for i, column := range columns {
+				if columns[i], err = convertor.Decode(column); err != nil {
+					return 0, nil, nil, errors.Annotatef(err, "invalid column name in the header of %s", dataFile.FileMeta.Path)
+				}
+			}
+		}
 		endOffset = startOffset + maxRegionSize
 	}
 	for {
@@ -368,7 +473,15 @@ func SplitLargeFile(
 			if err != nil {
 				return 0, nil, nil, err
 			}
-			parser := NewCSVParser(&cfg.Mydumper.CSV, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker, false)
+			var parser terminatorReader
+			switch dataFile.FileMeta.Type {
+			case SourceTypeJSON:
+				parser = NewJSONParser(r, int64(cfg.Mydumper.ReadBlockSize), ioWorker)
+			case SourceTypeFixed:
+				parser = NewFixedWidthParser(layout, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker)
+			default:
+				parser = NewCSVParser(&cfg.Mydumper.CSV, r, int64(cfg.Mydumper.ReadBlockSize), ioWorker, false)
+			}
 			if err = parser.SetPos(endOffset, prevRowIDMax); err != nil {
 				return 0, nil, nil, err
 			}
@@ -379,7 +492,7 @@ func SplitLargeFile(
 				}
 				log.L().Warn("file contains no terminator at end",
 					zap.String("path", dataFile.FileMeta.Path),
-					zap.String("terminator", cfg.Mydumper.CSV.Terminator))
+					zap.String("terminator", terminator))
 				pos = dataFile.FileMeta.FileSize
 			}
 			endOffset = pos
@@ -332,3 +332,89 @@ func (s *testMydumpRegionSuite) TestSplitLargeFileWithCustomTerminator(c *C) {
 		c.Assert(regions[i].Chunk.EndOffset, Equals, offsets[i][1])
 	}
 }
+
+func (s *testMydumpRegionSuite) TestSplitLargeJSONFile(c *C) {
+	meta := &MDTableMeta{
+		DB:   "json",
+		Name: "large_json",
+	}
+	cfg := &config.Config{
+		Mydumper: config.MydumperRuntime{
+			ReadBlockSize: config.ReadBlockSize,
+			CSV: config.CSVConfig{
+				Header: true,
+			},
+			Filter:        []string{"*.*"},
+			MaxRegionSize: 10,
+		},
+	}
+
+	dir := c.MkDir()
+	fileName := "test.json"
+	content := []byte(`{"a": 1, "b": "x"}` + "\n" + `{"a": 2}` + "\n" + `{"a": 3}` + "\n" + `{"b": "y"}`)
+	err := os.WriteFile(filepath.Join(dir, fileName), content, 0o644)
+	c.Assert(err, IsNil)
+	fileInfo := FileInfo{FileMeta: SourceFileMeta{Path: fileName, Type: SourceTypeJSON, FileSize: int64(len(content))}}
+	ioWorker := worker.NewPool(context.Background(), 4, "io")
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	// the CSV header config doesn't apply to JSON files.
+	offsets := [][]int64{{0, 19}, {19, 37}, {37, 47}}
+	_, regions, _, err := SplitLargeFile(context.Background(), meta, cfg, fileInfo, 4, 0, ioWorker, store)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, len(offsets))
+	for i := range offsets {
+		c.Assert(regions[i].Chunk.Offset, Equals, offsets[i][0])
+		c.Assert(regions[i].Chunk.EndOffset, Equals, offsets[i][1])
+		c.Assert(regions[i].Chunk.Columns, IsNil)
+	}
+}
+
+func (s *testMydumpRegionSuite) TestSplitLargeFixedWidthFile(c *C) {
+	meta := &MDTableMeta{
+		DB:   "fixed",
+		Name: "large_fixed",
+	}
+	cfg := &config.Config{
+		Mydumper: config.MydumperRuntime{
+			ReadBlockSize: config.ReadBlockSize,
+			CSV: config.CSVConfig{
+				Header: true,
+			},
+			Filter:        []string{"*.*"},
+			MaxRegionSize: 10,
+			FixedWidth: config.AllFixedWidthRules{{
+				DB:      "fixed",
+				Table:   "large_fixed",
+				Columns: []*config.FixedWidthColumn{{Name: "a", Width: 4}, {Name: "b", Start: 4, Width: 3}},
+			}},
+		},
+	}
+
+	dir := c.MkDir()
+	fileName := "test.dat"
+	content := []byte("0001abc\n0002def\n0003ghi\n0004jkl")
+	err := os.WriteFile(filepath.Join(dir, fileName), content, 0o644)
+	c.Assert(err, IsNil)
+	fileInfo := FileInfo{FileMeta: SourceFileMeta{Path: fileName, Type: SourceTypeFixed, FileSize: int64(len(content))}}
+	ioWorker := worker.NewPool(context.Background(), 4, "io")
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	// the regions end at the record boundaries.
+	offsets := [][]int64{{0, 16}, {16, 31}}
+	_, regions, _, err := SplitLargeFile(context.Background(), meta, cfg, fileInfo, 4, 0, ioWorker, store)
+	c.Assert(err, IsNil)
+	c.Assert(regions, HasLen, len(offsets))
+	for i := range offsets {
+		c.Assert(regions[i].Chunk.Offset, Equals, offsets[i][0])
+		c.Assert(regions[i].Chunk.EndOffset, Equals, offsets[i][1])
+		c.Assert(regions[i].Chunk.Columns, IsNil)
+	}
+
+	// the file can't be split without the layout.
+	cfg.Mydumper.FixedWidth = nil
+	_, _, _, err = SplitLargeFile(context.Background(), meta, cfg, fileInfo, 4, 0, ioWorker, store)
+	c.Assert(err, ErrorMatches, "no `mydumper.fixed-width` layout is configured for table `fixed`.`large_fixed`")
+}
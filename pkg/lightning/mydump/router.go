@@ -23,6 +23,9 @@ const (
 	SourceTypeCSV
 	SourceTypeParquet
 	SourceTypeViewSchema
+	SourceTypeJSON
+	SourceTypeAvro
+	SourceTypeFixed
 )
 
 const (
@@ -32,6 +35,9 @@ const (
 	TypeSQL      = "sql"
 	TypeCSV      = "csv"
 	TypeParquet  = "parquet"
+	TypeJSON     = "json"
+	TypeAvro     = "avro"
+	TypeFixed    = "fixed"
 	TypeIgnore   = "ignore"
 )
 
@@ -57,6 +63,12 @@ func parseSourceType(t string) (SourceType, error) {
 		return SourceTypeCSV, nil
 	case TypeParquet:
 		return SourceTypeParquet, nil
+	case TypeJSON:
+		return SourceTypeJSON, nil
+	case TypeAvro:
+		return SourceTypeAvro, nil
+	case TypeFixed:
+		return SourceTypeFixed, nil
 	case TypeIgnore:
 		return SourceTypeIgnore, nil
 	case ViewSchema:
@@ -78,6 +90,12 @@ func (s SourceType) String() string {
 		return TypeSQL
 	case SourceTypeParquet:
 		return TypeParquet
+	case SourceTypeJSON:
+		return TypeJSON
+	case SourceTypeAvro:
+		return TypeAvro
+	case SourceTypeFixed:
+		return TypeFixed
 	case SourceTypeViewSchema:
 		return ViewSchema
 	default:
@@ -113,8 +131,10 @@ var defaultFileRouteRules = []*config.FileRouteRule{
 	{Pattern: `(?i)^(?:[^/]*/)*([^/.]+)\.(.*?)-schema\.sql$`, Schema: "$1", Table: "$2", Type: TableSchema},
 	// view schema create file pattern, matches files like '{schema}.{table}-schema-view.sql'
 	{Pattern: `(?i)^(?:[^/]*/)*([^/.]+)\.(.*?)-schema-view\.sql$`, Schema: "$1", Table: "$2", Type: ViewSchema},
-	// source file pattern, matches files like '{schema}.{table}.0001.{sql|csv}'
-	{Pattern: `(?i)^(?:[^/]*/)*([^/.]+)\.(.*?)(?:\.([0-9]+))?\.(sql|csv|parquet)$`, Schema: "$1", Table: "$2", Type: "$4", Key: "$3"},
+	// source file pattern, matches files like '{schema}.{table}.0001.{sql|csv|parquet|json|avro}'
+	{Pattern: `(?i)^(?:[^/]*/)*([^/.]+)\.(.*?)(?:\.([0-9]+))?\.(sql|csv|parquet|json|avro)$`, Schema: "$1", Table: "$2", Type: "$4", Key: "$3"},
+	// JSON Lines file pattern, matches files like '{schema}.{table}.0001.{jsonl|ndjson}'
+	{Pattern: `(?i)^(?:[^/]*/)*([^/.]+)\.(.*?)(?:\.([0-9]+))?\.(?:jsonl|ndjson)$`, Schema: "$1", Table: "$2", Type: TypeJSON, Key: "$3"},
 }
 
 // // RouteRule is a rule to route file path to target schema/table
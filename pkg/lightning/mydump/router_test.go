@@ -254,3 +254,16 @@ func (t *testFileRouterSuite) TestRouteWithPath(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(res, IsNil)
 }
+
+func (t *testFileRouterSuite) TestDefaultJSONRouteRules(c *C) {
+	router, err := NewFileRouter(defaultFileRouteRules)
+	c.Assert(err, IsNil)
+	for _, path := range []string{"db.tbl.001.json", "db.tbl.001.jsonl", "dir/db.tbl.001.NDJSON"} {
+		res, err := router.Route(path)
+		c.Assert(err, IsNil)
+		c.Assert(res, NotNil, Commentf("path = %s", path))
+		c.Assert(*res, DeepEquals, RouteResult{
+			filter.Table{Schema: "db", Name: "tbl"}, "001", CompressionNone, SourceTypeJSON,
+		}, Commentf("path = %s", path))
+	}
+}
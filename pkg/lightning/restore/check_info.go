@@ -25,9 +25,12 @@ import (
 	"github.com/docker/go-units"
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/ddl"
 	"github.com/pingcap/tidb/table/tables"
+	"github.com/pingcap/tidb/types"
 	"github.com/tikv/pd/pkg/typeutil"
 	"github.com/tikv/pd/server/api"
 	pdconfig "github.com/tikv/pd/server/config"
@@ -191,6 +194,91 @@ func (rc *Controller) HasLargeCSV(dbMetas []*mydump.MDDatabaseMeta) error {
 	return nil
 }
 
+// charsetSampleSize is the size of the sample read from a data file to check
+// its character set.
+const charsetSampleSize = 64 * 1024
+
+// DataCharsetIsValid checks whether the data files are encoded in
+// `mydumper.data-character-set` by sampling the first text data file of each
+// table, and suggests the detected character set if they are not.
+func (rc *Controller) DataCharsetIsValid(ctx context.Context, dbMetas []*mydump.MDDatabaseMeta) error {
+	checkType := Critical
+	if rc.cfg.Mydumper.DataInvalidChar == mydump.InvalidCharReplace {
+		checkType = Warn
+	}
+	msgs := make([]string, 0)
+	defer func() {
+		if len(msgs) != 0 {
+			rc.checkTemplate.Collect(checkType, false, strings.Join(msgs, "\n"))
+		} else {
+			rc.checkTemplate.Collect(checkType, true, "data files are encoded in the data character set")
+		}
+	}()
+
+	charset := rc.cfg.Mydumper.DataCharacterSet
+	for _, db := range dbMetas {
+		for _, t := range db.Tables {
+			for _, f := range t.DataFiles {
+				if f.FileMeta.Compression != mydump.CompressionNone ||
+					(f.FileMeta.Type != mydump.SourceTypeCSV && f.FileMeta.Type != mydump.SourceTypeSQL &&
+						f.FileMeta.Type != mydump.SourceTypeFixed) {
+					continue
+				}
+				sample, err := rc.readDataSample(ctx, f.FileMeta.Path)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				detected := mydump.DetectCharset(sample)
+				if charset == mydump.CharsetBinary {
+					// the binary data is imported as is, which is only wrong
+					// if it is obviously not utf8mb4.
+					if detected == mydump.CharsetGB18030 {
+						msgs = append(msgs, fmt.Sprintf("data file %s seems to be encoded in gb18030, "+
+							"please set `mydumper.data-character-set` to convert it", f.FileMeta.Path))
+					}
+					break
+				}
+				convertor, err := mydump.NewCharsetConvertor(charset, mydump.InvalidCharError)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				if _, err := convertor.Decode(string(sample)); err != nil {
+					msg := fmt.Sprintf("data file %s contains characters invalid in %s", f.FileMeta.Path, charset)
+					if len(detected) > 0 {
+						msg += fmt.Sprintf(", it seems to be encoded in %s", detected)
+					}
+					msgs = append(msgs, msg)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// readDataSample reads the leading complete lines of the data file.
+func (rc *Controller) readDataSample(ctx context.Context, path string) ([]byte, error) {
+	reader, err := rc.store.Open(ctx, path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer reader.Close()
+
+	sample := make([]byte, charsetSampleSize)
+	n, err := io.ReadFull(reader, sample)
+	switch errors.Cause(err) {
+	case nil:
+		// drop the last line which may end with a truncated character.
+		if i := bytes.LastIndexByte(sample, '\n'); i >= 0 {
+			n = i + 1
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+	default:
+		return nil, errors.Trace(err)
+	}
+	return sample[:n], nil
+}
+
 func (rc *Controller) EstimateSourceData(ctx context.Context) (int64, error) {
 	sourceSize := int64(0)
 	originSource := int64(0)
@@ -250,9 +338,6 @@ func (rc *Controller) LocalResource(ctx context.Context, sourceSize int64) error
 		return errors.Trace(err)
 	}
 	localAvailable := storageSize.Available
-	if err = rc.taskMgr.InitTask(ctx, sourceSize); err != nil {
-		return errors.Trace(err)
-	}
 
 	var message string
 	var passed bool
@@ -338,7 +423,11 @@ func hasDefault(col *model.ColumnInfo) bool {
 		col.IsGenerated() || mysql.HasAutoIncrementFlag(col.Flag)
 }
 
-func (rc *Controller) readColumnsAndCount(ctx context.Context, dataFileMeta mydump.SourceFileMeta) (cols []string, colCnt int, err error) {
+func (rc *Controller) readColumnsAndCount(
+	ctx context.Context,
+	tableMeta *mydump.MDTableMeta,
+	dataFileMeta mydump.SourceFileMeta,
+) (cols []string, colCnt int, err error) {
 	var reader storage.ReadSeekCloser
 	if dataFileMeta.Type == mydump.SourceTypeParquet {
 		reader, err = mydump.OpenParquetReader(ctx, rc.store, dataFileMeta.Path, dataFileMeta.FileSize)
@@ -348,6 +437,15 @@ func (rc *Controller) readColumnsAndCount(ctx context.Context, dataFileMeta mydu
 	if err != nil {
 		return nil, 0, errors.Trace(err)
 	}
+	convertor, err := newCharsetConvertor(rc.cfg, dataFileMeta.Type)
+	if err != nil {
+		reader.Close()
+		return nil, 0, errors.Trace(err)
+	}
+	decodeReader := newDecodeReader(convertor, dataFileMeta.Type, reader)
+	if decodeReader != nil {
+		reader = decodeReader
+	}
 
 	var parser mydump.Parser
 	blockBufSize := int64(rc.cfg.Mydumper.ReadBlockSize)
@@ -362,6 +460,20 @@ func (rc *Controller) readColumnsAndCount(ctx context.Context, dataFileMeta mydu
 		if err != nil {
 			return nil, 0, errors.Trace(err)
 		}
+	case mydump.SourceTypeJSON:
+		parser = mydump.NewJSONParser(reader, blockBufSize, rc.ioWorkers)
+	case mydump.SourceTypeAvro:
+		parser, err = mydump.NewAvroParser(reader)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+	case mydump.SourceTypeFixed:
+		layout, err := mydump.GetFixedWidthLayout(rc.cfg, tableMeta.DB, tableMeta.Name)
+		if err != nil {
+			reader.Close()
+			return nil, 0, errors.Trace(err)
+		}
+		parser = mydump.NewFixedWidthParser(layout, reader, blockBufSize, rc.ioWorkers)
 	default:
 		panic(fmt.Sprintf("unknown file type '%s'", dataFileMeta.Type))
 	}
@@ -371,7 +483,13 @@ func (rc *Controller) readColumnsAndCount(ctx context.Context, dataFileMeta mydu
 	if err != nil && errors.Cause(err) != io.EOF {
 		return nil, 0, errors.Trace(err)
 	}
-	return parser.Columns(), len(parser.LastRow().Row), nil
+	cols = parser.Columns()
+	if dataFileMeta.Type == mydump.SourceTypeCSV && len(cols) > 0 && convertor != nil {
+		if cols, err = decodeColumnNames(convertor, decodeReader != nil, cols); err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+	}
+	return cols, len(parser.LastRow().Row), nil
 }
 
 // SchemaIsValid checks the import file and cluster schema is match.
@@ -392,6 +510,17 @@ func (rc *Controller) SchemaIsValid(ctx context.Context, tableInfo *mydump.MDTab
 	for _, col := range igCol.Columns {
 		igCols[col] = struct{}{}
 	}
+	transform, err := rc.cfg.Mydumper.Transform.GetTransformRule(tableInfo.DB, tableInfo.Name, rc.cfg.Mydumper.CaseSensitive)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var transformVars []string
+	if transform != nil {
+		if transformVars, err = kv.TransformVariables(transform.Columns, transform.Where); err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid transform rule of `%s`.`%s`: %s", tableInfo.DB, tableInfo.Name, err.Error()))
+			return msgs, nil
+		}
+	}
 
 	if len(tableInfo.DataFiles) == 0 {
 		log.L().Info("no data files detected", zap.String("db", tableInfo.DB), zap.String("table", tableInfo.Name))
@@ -409,16 +538,29 @@ func (rc *Controller) SchemaIsValid(ctx context.Context, tableInfo *mydump.MDTab
 	}
 	// tidb_rowid have a default value.
 	defaultCols[model.ExtraHandleName.String()] = struct{}{}
+	// the transformed columns are computed from the source columns.
+	if transform != nil {
+		for col := range transform.Columns {
+			defaultCols[col] = struct{}{}
+		}
+	}
+	// the source columns only referred by the transform expressions are not imported.
+	for _, col := range transformVars {
+		if model.FindColumnInfo(core.Columns, col) == nil {
+			igCols[col] = struct{}{}
+		}
+	}
 
 	for _, dataFile := range tableInfo.DataFiles {
 		// get columns name from data file.
 		dataFileMeta := dataFile.FileMeta
 
-		if tp := dataFileMeta.Type; tp != mydump.SourceTypeCSV && tp != mydump.SourceTypeSQL && tp != mydump.SourceTypeParquet {
+		if tp := dataFileMeta.Type; tp != mydump.SourceTypeCSV && tp != mydump.SourceTypeSQL && tp != mydump.SourceTypeParquet &&
+			tp != mydump.SourceTypeJSON && tp != mydump.SourceTypeAvro && tp != mydump.SourceTypeFixed {
 			msgs = append(msgs, fmt.Sprintf("file '%s' with unknown source type '%s'", dataFileMeta.Path, dataFileMeta.Type.String()))
 			return msgs, nil
 		}
-		colsFromDataFile, colCountFromDataFile, err := rc.readColumnsAndCount(ctx, dataFileMeta)
+		colsFromDataFile, colCountFromDataFile, err := rc.readColumnsAndCount(ctx, tableInfo, dataFileMeta)
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
@@ -507,6 +649,15 @@ func (rc *Controller) SampleDataFromTable(ctx context.Context, dbName string, ta
 	if err != nil {
 		return errors.Trace(err)
 	}
+	convertor, err := newCharsetConvertor(rc.cfg, sampleFile.Type)
+	if err != nil {
+		reader.Close()
+		return errors.Trace(err)
+	}
+	decodeReader := newDecodeReader(convertor, sampleFile.Type, reader)
+	if decodeReader != nil {
+		reader = decodeReader
+	}
 	idAlloc := kv.NewPanickingAllocators(0)
 	tbl, err := tables.TableFromMeta(idAlloc, tableInfo)
 
@@ -530,9 +681,26 @@ func (rc *Controller) SampleDataFromTable(ctx context.Context, dbName string, ta
 		if err != nil {
 			return errors.Trace(err)
 		}
+	case mydump.SourceTypeJSON:
+		parser = mydump.NewJSONParser(reader, blockBufSize, rc.ioWorkers)
+	case mydump.SourceTypeAvro:
+		parser, err = mydump.NewAvroParser(reader)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	case mydump.SourceTypeFixed:
+		layout, err := mydump.GetFixedWidthLayout(rc.cfg, dbName, tableMeta.Name)
+		if err != nil {
+			reader.Close()
+			return errors.Trace(err)
+		}
+		parser = mydump.NewFixedWidthParser(layout, reader, blockBufSize, rc.ioWorkers)
 	default:
 		panic(fmt.Sprintf("file '%s' with unknown source type '%s'", sampleFile.Path, sampleFile.Type.String()))
 	}
+	if decodeReader != nil {
+		parser = mydump.NewDecodedParser(parser, decodeReader)
+	}
 	defer parser.Close()
 	logTask := log.With(zap.String("table", tableMeta.Name)).Begin(zap.InfoLevel, "sample file")
 	igCols, err := rc.cfg.Mydumper.IgnoreColumns.GetIgnoreColumns(dbName, tableMeta.Name, rc.cfg.Mydumper.CaseSensitive)
@@ -550,6 +718,13 @@ func (rc *Controller) SampleDataFromTable(ctx context.Context, dbName string, ta
 	lastKey := make([]byte, 0)
 	tableMeta.IsRowOrdered = true
 	tableMeta.IndexRatio = 1.0
+	// the rows of the tidb backend are not KV pairs, and never need sorting.
+	checkRowOrder := rc.cfg.TikvImporter.Backend != config.BackendTiDB
+	var preview *TablePreview
+	if rc.previewRowCount > 0 {
+		preview = &TablePreview{DB: dbName, Table: tableMeta.Name}
+		rc.previews = append(rc.previews, preview)
+	}
 outloop:
 	for !reachEOF {
 		offset, _ := parser.Pos()
@@ -577,15 +752,21 @@ outloop:
 		lastRow := parser.LastRow()
 		rowSize += uint64(lastRow.Length)
 		rowCount += 1
+		if preview != nil && len(preview.Rows) < rc.previewRowCount {
+			if preview.Columns == nil {
+				preview.Columns = previewColumns(columnNames, tableInfo)
+			}
+			preview.Rows = append(preview.Rows, previewRow(lastRow.Row))
+		}
 
 		var dataChecksum, indexChecksum verification.KVChecksum
-		kvs, encodeErr := kvEncoder.Encode(logTask.Logger, lastRow.Row, lastRow.RowID, columnPermutation, offset)
+		kvs, encodeErr := kvEncoder.Encode(logTask.Logger, lastRow.Row, lastRow.RowID, columnPermutation, sampleFile.Path, offset)
 		parser.RecycleRow(lastRow)
 		if encodeErr != nil {
 			err = errors.Annotatef(encodeErr, "in file at offset %d", offset)
 			return errors.Trace(err)
 		}
-		if tableMeta.IsRowOrdered {
+		if checkRowOrder && tableMeta.IsRowOrdered {
 			kvs.ClassifyAndAppend(&dataKVs, &dataChecksum, &indexKVs, &indexChecksum)
 			for _, kv := range kv.KvPairsFromRows(dataKVs) {
 				if len(lastKey) == 0 {
@@ -614,3 +795,193 @@ outloop:
 	log.L().Info("Sample source data", zap.String("table", tableMeta.Name), zap.Float64("IndexRatio", tableMeta.IndexRatio), zap.Bool("IsSourceOrder", tableMeta.IsRowOrdered))
 	return nil
 }
+
+// PreCheckResult is the result of checking a task by PreCheck.
+type PreCheckResult struct {
+	// Success is whether all the checks are passed.
+	Success bool          `json:"success"`
+	Checks  []CheckResult `json:"checks"`
+	// SourceSize is the total size of the data files.
+	SourceSize int64 `json:"source-size"`
+	// EstimatedSize is the size of the encoded data estimated from the sampled
+	// rows of each table.
+	EstimatedSize int64           `json:"estimated-size"`
+	Tables        []*TablePreview `json:"tables"`
+}
+
+// TablePreview is the leading rows parsed from the first data file of a table.
+type TablePreview struct {
+	DB    string `json:"db"`
+	Table string `json:"table"`
+	// Columns are taken from the header of the data file, or from the table
+	// schema if the data file has no header.
+	Columns []string `json:"columns"`
+	// Rows are the parsed values, where NULL is nil.
+	Rows [][]interface{} `json:"rows"`
+}
+
+// PreCheck runs the checks of the task without importing anything. Unlike Run,
+// it neither creates the schemas nor registers the task in the target cluster,
+// and the tables not existing in the target yet are checked against their
+// schema files. Up to previewRowCount rows of each table are parsed and
+// returned for preview. The checkpoints are not checked if the controller is
+// created by NewPreCheckController.
+func (rc *Controller) PreCheck(ctx context.Context, previewRowCount int) (*PreCheckResult, error) {
+	rc.previewRowCount = previewRowCount
+	rc.previews = nil
+
+	if err := rc.ClusterIsAvailable(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := rc.StoragePermission(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	dbInfos, err := rc.loadSchemaInfoForPreCheck(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rc.dbInfos = dbInfos
+	rc.sysVars = ObtainImportantVariables(ctx, rc.tidbGlue.GetSQLExecutor())
+	if err = rc.DataCheck(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	estimatedSize, err := rc.EstimateSourceData(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rc.isLocalBackend() {
+		if err = rc.LocalResource(ctx, estimatedSize); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err = rc.ClusterResource(ctx, estimatedSize); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	result := &PreCheckResult{
+		Success:       rc.checkTemplate.Success(),
+		Checks:        rc.checkTemplate.Results(),
+		EstimatedSize: estimatedSize,
+		Tables:        rc.previews,
+	}
+	for _, db := range rc.dbMetas {
+		for _, tbl := range db.Tables {
+			result.SourceSize += tbl.TotalSize
+		}
+	}
+	return result, nil
+}
+
+// loadSchemaInfoForPreCheck loads the table schemas like LoadSchemaInfo, except
+// that the tables not existing in the target cluster are built from their
+// schema files instead of being created. The tables whose schemas can't be
+// built are collected as a failed check.
+func (rc *Controller) loadSchemaInfoForPreCheck(ctx context.Context) (map[string]*checkpoints.TidbDBInfo, error) {
+	getTableFunc := rc.backend.FetchRemoteTableModels
+	if !rc.tidbGlue.OwnsSQLExecutor() {
+		getTableFunc = rc.tidbGlue.GetTables
+	}
+
+	msgs := make([]string, 0)
+	result := make(map[string]*checkpoints.TidbDBInfo, len(rc.dbMetas))
+	for _, dbMeta := range rc.dbMetas {
+		// we can ignore error here since the database may not be created yet.
+		tables, _ := getTableFunc(ctx, dbMeta.Name)
+		tableMap := make(map[string]*model.TableInfo, len(tables))
+		for _, tbl := range tables {
+			tableMap[tbl.Name.L] = tbl
+		}
+
+		dbInfo := &checkpoints.TidbDBInfo{
+			Name:   dbMeta.Name,
+			Tables: make(map[string]*checkpoints.TidbTableInfo),
+		}
+		for _, tblMeta := range dbMeta.Tables {
+			tblInfo, ok := tableMap[strings.ToLower(tblMeta.Name)]
+			if !ok {
+				var err error
+				tblInfo, err = rc.buildTableInfoFromSchemaFile(ctx, tblMeta)
+				if err != nil {
+					msgs = append(msgs, fmt.Sprintf("table `%s`.`%s` schema is not available: %s",
+						dbMeta.Name, tblMeta.Name, err.Error()))
+					continue
+				}
+			}
+			tableName := tblInfo.Name.String()
+			dbInfo.Tables[tableName] = &checkpoints.TidbTableInfo{
+				ID:   tblInfo.ID,
+				DB:   dbMeta.Name,
+				Name: tableName,
+				Core: tblInfo,
+			}
+		}
+		result[dbMeta.Name] = dbInfo
+	}
+
+	if len(msgs) != 0 {
+		rc.checkTemplate.Collect(Critical, false, strings.Join(msgs, "\n"))
+	} else {
+		rc.checkTemplate.Collect(Critical, true, "table schemas are available")
+	}
+	return result, nil
+}
+
+// buildTableInfoFromSchemaFile builds the table info from the CREATE TABLE
+// statement in the schema file of the table.
+func (rc *Controller) buildTableInfoFromSchemaFile(ctx context.Context, tblMeta *mydump.MDTableMeta) (*model.TableInfo, error) {
+	if tblMeta.SchemaFile.FileMeta.Path == "" {
+		return nil, errors.New("schema file not found")
+	}
+	schema, err := tblMeta.GetSchema(ctx, rc.store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	stmts, _, err := rc.tidbGlue.GetParser().Parse(schema, "", "")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, stmt := range stmts {
+		if node, ok := stmt.(*ast.CreateTableStmt); ok {
+			tblInfo, err := ddl.BuildTableInfoFromAST(node)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			// the table is always created with the name of the table meta.
+			tblInfo.Name = model.NewCIStr(tblMeta.Name)
+			return tblInfo, nil
+		}
+	}
+	return nil, errors.New("no CREATE TABLE statement in the schema file")
+}
+
+// previewColumns returns the column names of the previewed rows.
+func previewColumns(columnNames []string, tableInfo *model.TableInfo) []string {
+	if len(columnNames) > 0 {
+		return append([]string(nil), columnNames...)
+	}
+	columns := make([]string, 0, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		if !col.Hidden {
+			columns = append(columns, col.Name.O)
+		}
+	}
+	return columns
+}
+
+// previewRow converts the parsed row into the values to preview.
+func previewRow(row []types.Datum) []interface{} {
+	values := make([]interface{}, 0, len(row))
+	for i := range row {
+		if row[i].IsNull() {
+			values = append(values, nil)
+			continue
+		}
+		value, err := row[i].ToString()
+		if err != nil {
+			value = fmt.Sprintf("%v", row[i].GetValue())
+		}
+		values = append(values, value)
+	}
+	return values
+}
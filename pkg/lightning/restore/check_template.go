@@ -41,6 +41,16 @@ type Template interface {
 
 	// Output print all checks results.
 	Output() string
+
+	// Results returns all checks results in the collected order.
+	Results() []CheckResult
+}
+
+// CheckResult is the result of a single check.
+type CheckResult struct {
+	Type    CheckType `json:"type"`
+	Passed  bool      `json:"passed"`
+	Message string    `json:"message"`
 }
 
 type SimpleTemplate struct {
@@ -48,6 +58,7 @@ type SimpleTemplate struct {
 	warnFailedCount     int
 	criticalFailedCount int
 	t                   table.Writer
+	results             []CheckResult
 }
 
 func NewSimpleTemplate() Template {
@@ -64,6 +75,7 @@ func NewSimpleTemplate() Template {
 		0,
 		0,
 		t,
+		nil,
 	}
 }
 
@@ -81,6 +93,7 @@ func (c *SimpleTemplate) Collect(t CheckType, passed bool, msg string) {
 	}
 	c.t.AppendRow(table.Row{c.count, msg, t, passed})
 	c.t.AppendSeparator()
+	c.results = append(c.results, CheckResult{Type: t, Passed: passed, Message: msg})
 }
 
 func (c *SimpleTemplate) Success() bool {
@@ -97,6 +110,10 @@ func (c *SimpleTemplate) FailedCount(t CheckType) int {
 	return 0
 }
 
+func (c *SimpleTemplate) Results() []CheckResult {
+	return c.results
+}
+
 func (c *SimpleTemplate) Output() string {
 	c.t.SetAllowedRowLength(170)
 	c.t.SetRowPainter(table.RowPainter(func(row table.Row) text.Colors {
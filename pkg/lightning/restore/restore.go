@@ -20,6 +20,7 @@ import (
 	"io"
 	"math"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,8 +29,11 @@ import (
 	"github.com/pingcap/errors"
 	"github.com/pingcap/failpoint"
 	sstpb "github.com/pingcap/kvproto/pkg/import_sstpb"
+	"github.com/pingcap/parser/charset"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/meta/autoid"
+	"github.com/pingcap/tidb/table"
+	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/collate"
 	"go.uber.org/atomic"
 	"go.uber.org/multierr"
@@ -44,6 +48,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/metric"
@@ -234,6 +239,8 @@ type Controller struct {
 	pauser        *common.Pauser
 	backend       backend.Backend
 	tidbGlue      glue.Glue
+	errorMgr      *errormanager.ErrorManager
+	metrics       *metric.Metrics
 
 	alterTableLock sync.Mutex
 	sysVars        map[string]string
@@ -254,6 +261,13 @@ type Controller struct {
 	diskQuotaLock  *diskQuotaLock
 	diskQuotaState atomic.Int32
 	compactState   atomic.Int32
+
+	tables *tableQueue
+
+	// previewRowCount is the number of the leading rows of each table kept in
+	// previews by SampleDataFromTable, which is only set by PreCheck.
+	previewRowCount int
+	previews        []*TablePreview
 }
 
 func NewRestoreController(
@@ -295,6 +309,20 @@ func NewRestoreControllerWithPauser(
 	if taskCp != nil {
 		cfg.TaskID = taskCp.TaskID
 	}
+	metrics := metric.NewMetrics(cfg.TaskID)
+
+	var errorMgr *errormanager.ErrorManager
+	// the duplicate keys are reported to the task info schema.
+	if !cfg.App.MaxError.IsZero() || cfg.TikvImporter.DuplicateDetection {
+		db, err := g.GetDB()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		errorMgr = errormanager.New(db, cfg)
+		if err := errorMgr.Init(ctx); err != nil {
+			return nil, errors.Annotate(err, "failed to init error manager")
+		}
+	}
 
 	var backend backend.Backend
 	switch cfg.TikvImporter.Backend {
@@ -309,7 +337,7 @@ func NewRestoreControllerWithPauser(
 		if err != nil {
 			return nil, errors.Annotate(err, "open tidb backend failed")
 		}
-		backend = tidb.NewTiDBBackend(db, cfg.TikvImporter.OnDuplicate)
+		backend = tidb.NewTiDBBackend(db, &cfg.TikvImporter, errorMgr)
 	case config.BackendLocal:
 		var rLimit local.Rlim_t
 		rLimit, err = local.GetSystemRLimit()
@@ -323,7 +351,7 @@ func NewRestoreControllerWithPauser(
 		}
 
 		backend, err = local.NewLocalBackend(ctx, tls, cfg.TiDB.PdAddr, &cfg.TikvImporter,
-			cfg.Checkpoint.Enable, g, maxOpenFiles)
+			cfg.Checkpoint.Enable, g, maxOpenFiles, errorMgr, metrics)
 		if err != nil {
 			return nil, errors.Annotate(err, "build local backend failed")
 		}
@@ -364,6 +392,8 @@ func NewRestoreControllerWithPauser(
 		pauser:        pauser,
 		backend:       backend,
 		tidbGlue:      g,
+		errorMgr:      errorMgr,
+		metrics:       metrics,
 		sysVars:       defaultImportantVariables,
 		tls:           tls,
 		checkTemplate: NewSimpleTemplate(),
@@ -377,14 +407,65 @@ func NewRestoreControllerWithPauser(
 		metaMgrBuilder: metaBuilder,
 		diskQuotaLock:  newDiskQuotaLock(),
 		taskMgr:        nil,
+		tables:         newTableQueue(),
 	}
 
 	return rc, nil
 }
 
+// NewPreCheckController creates a controller which only runs PreCheck. Unlike
+// NewRestoreController, it opens neither the checkpoints nor the error manager,
+// and its backend only checks the requirements and encodes the sampled rows,
+// so that nothing is created locally or in the target cluster.
+func NewPreCheckController(
+	ctx context.Context,
+	dbMetas []*mydump.MDDatabaseMeta,
+	cfg *config.Config,
+	s storage.ExternalStorage,
+	g glue.Glue,
+) (*Controller, error) {
+	tls, err := cfg.ToTLS()
+	if err != nil {
+		return nil, err
+	}
+
+	var backend backend.Backend
+	switch cfg.TikvImporter.Backend {
+	case config.BackendImporter:
+		backend = importer.NewCheckOnlyImporter(tls, cfg.TiDB.PdAddr)
+	case config.BackendTiDB:
+		db, err := g.GetDB()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		backend = tidb.NewTiDBBackend(db, &cfg.TikvImporter, nil)
+	case config.BackendLocal:
+		backend = local.NewCheckOnlyBackend(tls, cfg.TiDB.PdAddr, g)
+	default:
+		return nil, errors.New("unknown backend: " + cfg.TikvImporter.Backend)
+	}
+
+	return &Controller{
+		cfg:            cfg,
+		dbMetas:        dbMetas,
+		ioWorkers:      worker.NewPool(ctx, cfg.App.IOConcurrency, "io"),
+		backend:        backend,
+		tidbGlue:       g,
+		sysVars:        defaultImportantVariables,
+		tls:            tls,
+		checkTemplate:  NewSimpleTemplate(),
+		errorSummaries: makeErrorSummaries(log.L()),
+		store:          s,
+	}, nil
+}
+
 func (rc *Controller) Close() {
 	rc.backend.Close()
 	rc.tidbGlue.GetSQLExecutor().Close()
+	// the precheck controller has no metrics.
+	if rc.metrics != nil {
+		rc.metrics.Delete()
+	}
 }
 
 func (rc *Controller) Run(ctx context.Context) error {
@@ -430,6 +511,7 @@ outside:
 
 	task.End(zap.ErrorLevel, err)
 	rc.errorSummaries.emitLog()
+	rc.errorMgr.LogDuplicateSummary(log.L())
 
 	return errors.Trace(err)
 }
@@ -726,7 +808,7 @@ func (rc *Controller) restoreSchema(ctx context.Context) error {
 		return err
 	}
 
-	dbInfos, err := LoadSchemaInfo(ctx, rc.dbMetas, getTableFunc)
+	dbInfos, err := LoadSchemaInfo(ctx, rc.dbMetas, getTableFunc, rc.metrics)
 	if err != nil {
 		return errors.Trace(err)
 	}
@@ -774,8 +856,11 @@ func verifyCheckpoint(cfg *config.Config, taskCp *checkpoints.TaskCheckpoint) er
 	}
 	// always check the backend value even with 'check-requirements = false'
 	retryUsage := "destroy all checkpoints"
-	if cfg.Checkpoint.Driver == config.CheckpointDriverFile {
+	switch cfg.Checkpoint.Driver {
+	case config.CheckpointDriverFile:
 		retryUsage = fmt.Sprintf("delete the file '%s'", cfg.Checkpoint.DSN)
+	case config.CheckpointDriverPebble:
+		retryUsage = fmt.Sprintf("delete the directory '%s'", cfg.Checkpoint.DSN)
 	}
 	retryUsage += " and remove all restored tables and try again"
 
@@ -894,14 +979,17 @@ func (rc *Controller) estimateChunkCountIntoMetrics(ctx context.Context) error {
 					} else {
 						estimatedChunkCount++
 					}
+				} else if fileMeta.FileMeta.Type == mydump.SourceTypeJSON || fileMeta.FileMeta.Type == mydump.SourceTypeAvro ||
+					fileMeta.FileMeta.Type == mydump.SourceTypeParquet || fileMeta.FileMeta.Type == mydump.SourceTypeFixed {
+					estimatedChunkCount += math.Ceil(float64(fileMeta.FileMeta.FileSize) / float64(rc.cfg.Mydumper.MaxRegionSize))
 				} else {
 					estimatedChunkCount++
 				}
 			}
 		}
 	}
-	metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated).Add(estimatedChunkCount)
-	metric.ProcessedEngineCounter.WithLabelValues(metric.ChunkStateEstimated, metric.TableResultSuccess).
+	rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated).Add(estimatedChunkCount)
+	rc.metrics.ProcessedEngineCounter.WithLabelValues(metric.ChunkStateEstimated, metric.TableResultSuccess).
 		Add(float64(estimatedEngineCnt))
 	rc.tidbGlue.Record(glue.RecordEstimatedChunk, uint64(estimatedChunkCount))
 	return nil
@@ -924,9 +1012,9 @@ func (rc *Controller) saveStatusCheckpoint(tableName string, engineID int32, err
 	}
 
 	if engineID == checkpoints.WholeTableEngineID {
-		metric.RecordTableCount(statusIfSucceed.MetricName(), err)
+		rc.metrics.RecordTableCount(statusIfSucceed.MetricName(), err)
 	} else {
-		metric.RecordEngineCount(statusIfSucceed.MetricName(), err)
+		rc.metrics.RecordEngineCount(statusIfSucceed.MetricName(), err)
 	}
 
 	rc.saveCpCh <- saveCp{tableName: tableName, merger: merger}
@@ -951,7 +1039,7 @@ func (rc *Controller) listenCheckpointUpdates() {
 
 			if len(cpd) > 0 {
 				rc.checkpointsDB.Update(cpd)
-				web.BroadcastCheckpointDiff(cpd)
+				web.BroadcastCheckpointDiff(rc.cfg.TaskID, cpd)
 			}
 			rc.checkpointsWg.Done()
 		}
@@ -1087,23 +1175,23 @@ func (rc *Controller) buildRunPeriodicActionAndCancelFunc(ctx context.Context, s
 					nanoseconds := float64(time.Since(start).Nanoseconds())
 					// the estimated chunk is not accurate(likely under estimated), but the actual count is not accurate
 					// before the last table start, so use the bigger of the two should be a workaround
-					estimated := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated))
-					pending := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
+					estimated := metric.ReadCounter(rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateEstimated))
+					pending := metric.ReadCounter(rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
 					if estimated < pending {
 						estimated = pending
 					}
-					finished := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
-					totalTables := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
-					completedTables := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess))
+					finished := metric.ReadCounter(rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
+					totalTables := metric.ReadCounter(rc.metrics.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
+					completedTables := metric.ReadCounter(rc.metrics.TableCounter.WithLabelValues(metric.TableStateCompleted, metric.TableResultSuccess))
 					bytesRead := metric.ReadHistogramSum(metric.RowReadBytesHistogram)
-					engineEstimated := metric.ReadCounter(metric.ProcessedEngineCounter.WithLabelValues(metric.ChunkStateEstimated, metric.TableResultSuccess))
-					enginePending := metric.ReadCounter(metric.ProcessedEngineCounter.WithLabelValues(metric.ChunkStatePending, metric.TableResultSuccess))
+					engineEstimated := metric.ReadCounter(rc.metrics.ProcessedEngineCounter.WithLabelValues(metric.ChunkStateEstimated, metric.TableResultSuccess))
+					enginePending := metric.ReadCounter(rc.metrics.ProcessedEngineCounter.WithLabelValues(metric.ChunkStatePending, metric.TableResultSuccess))
 					if engineEstimated < enginePending {
 						engineEstimated = enginePending
 					}
-					engineFinished := metric.ReadCounter(metric.ProcessedEngineCounter.WithLabelValues(metric.TableStateImported, metric.TableResultSuccess))
-					bytesWritten := metric.ReadCounter(metric.BytesCounter.WithLabelValues(metric.TableStateWritten))
-					bytesImported := metric.ReadCounter(metric.BytesCounter.WithLabelValues(metric.TableStateImported))
+					engineFinished := metric.ReadCounter(rc.metrics.ProcessedEngineCounter.WithLabelValues(metric.TableStateImported, metric.TableResultSuccess))
+					bytesWritten := metric.ReadCounter(rc.metrics.BytesCounter.WithLabelValues(metric.TableStateWritten))
+					bytesImported := metric.ReadCounter(rc.metrics.BytesCounter.WithLabelValues(metric.TableStateImported))
 
 					var state string
 					var remaining zap.Field
@@ -1171,7 +1259,7 @@ func (rc *Controller) buildRunPeriodicActionAndCancelFunc(ctx context.Context, s
 					rc.enforceDiskQuota(ctx)
 
 				case <-glueProgressTicker.C:
-					finished := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
+					finished := metric.ReadCounter(rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateFinished))
 					rc.tidbGlue.Record(glue.RecordFinishedChunk, uint64(finished))
 				}
 			}
@@ -1193,6 +1281,9 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 	if rc.indexWorkers == nil {
 		rc.indexWorkers = worker.NewPool(ctx, rc.cfg.App.IndexConcurrency, "index")
 	}
+	if rc.tables == nil {
+		rc.tables = newTableQueue()
+	}
 
 	// for local backend, we should disable some pd scheduler and change some settings, to
 	// make split region and ingest sst more stable
@@ -1279,33 +1370,6 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 
 	defer close(stopPeriodicActions)
 
-	taskCh := make(chan task, rc.cfg.App.IndexConcurrency)
-	defer close(taskCh)
-
-	manager, err := newChecksumManager(ctx, rc)
-	if err != nil {
-		return errors.Trace(err)
-	}
-	ctx2 := context.WithValue(ctx, &checksumManagerKey, manager)
-	for i := 0; i < rc.cfg.App.IndexConcurrency; i++ {
-		go func() {
-			for task := range taskCh {
-				tableLogTask := task.tr.logger.Begin(zap.InfoLevel, "restore table")
-				web.BroadcastTableCheckpoint(task.tr.tableName, task.cp)
-				needPostProcess, err := task.tr.restoreTable(ctx2, rc, task.cp)
-				err = errors.Annotatef(err, "restore table %s failed", task.tr.tableName)
-				tableLogTask.End(zap.ErrorLevel, err)
-				web.BroadcastError(task.tr.tableName, err)
-				metric.RecordTableCount("completed", err)
-				restoreErr.Set(err)
-				if needPostProcess {
-					postProcessTaskChan <- task
-				}
-				wg.Done()
-			}
-		}()
-	}
-
 	// first collect all tables where the checkpoint is invalid
 	allInvalidCheckpoints := make(map[string]checkpoints.CheckpointStatus)
 	// collect all tables whose checkpoint's tableID can't match current tableID
@@ -1387,6 +1451,8 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 		return errors.New("TiDB Lightning has detected tables with illegal checkpoints; please remove these checkpoints first")
 	}
 
+	trs := make([]*TableRestore, 0, totalTables)
+	cps := make([]*checkpoints.TableCheckpoint, 0, totalTables)
 	for _, dbMeta := range rc.dbMetas {
 		dbInfo := rc.dbInfos[dbMeta.Name]
 		for _, tableMeta := range dbMeta.Tables {
@@ -1400,20 +1466,63 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 			if err != nil {
 				return errors.Trace(err)
 			}
-			tr, err := NewTableRestore(tableName, tableMeta, dbInfo, tableInfo, cp, igCols.Columns)
+			transform, err := rc.cfg.Mydumper.Transform.GetTransformRule(dbInfo.Name, tableInfo.Name, rc.cfg.Mydumper.CaseSensitive)
 			if err != nil {
 				return errors.Trace(err)
 			}
-
-			wg.Add(1)
-			select {
-			case taskCh <- task{tr: tr, cp: cp}:
-			case <-ctx.Done():
-				return ctx.Err()
+			tr, err := NewTableRestore(tableName, tableMeta, dbInfo, tableInfo, cp, igCols.Columns, transform)
+			if err != nil {
+				return errors.Trace(err)
 			}
+			trs = append(trs, tr)
+			cps = append(cps, cp)
+			web.BroadcastTableQueued(rc.cfg.TaskID, tableName, cp)
 		}
 	}
 
+	// the tables are dispatched in the order of their priorities, which can be
+	// changed while they are pending.
+	rc.tables.open(ctx, trs, cps, func(tableName string, merger checkpoints.TableCheckpointMerger) {
+		rc.saveCpCh <- saveCp{tableName: tableName, merger: merger}
+	})
+	defer rc.tables.close()
+
+	manager, err := newChecksumManager(ctx, rc)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	ctx2 := context.WithValue(ctx, &checksumManagerKey, manager)
+	for i := 0; i < rc.cfg.App.IndexConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				table, tableCtx, ok := rc.tables.next(ctx2)
+				if !ok {
+					return
+				}
+				task := task{tr: table.tr, cp: table.cp}
+				tableLogTask := task.tr.logger.Begin(zap.InfoLevel, "restore table")
+				web.BroadcastTableCheckpoint(rc.cfg.TaskID, task.tr.tableName, task.cp)
+				needPostProcess, err := task.tr.restoreTable(tableCtx, rc, task.cp)
+				if rc.tables.finish(table) {
+					// the table is cancelled through the HTTP API, which does not fail the task.
+					task.tr.logger.Info("stopped restoring the cancelled table", log.ShortError(err))
+					web.BroadcastError(rc.cfg.TaskID, task.tr.tableName, nil)
+					continue
+				}
+				err = errors.Annotatef(err, "restore table %s failed", task.tr.tableName)
+				tableLogTask.End(zap.ErrorLevel, err, zap.Int64("filteredRows", task.tr.filteredRows.Load()))
+				web.BroadcastError(rc.cfg.TaskID, task.tr.tableName, err)
+				rc.metrics.RecordTableCount("completed", err)
+				restoreErr.Set(err)
+				if needPostProcess {
+					postProcessTaskChan <- task
+				}
+			}
+		}()
+	}
+
 	wg.Wait()
 	// if context is done, should return directly
 	select {
@@ -1450,7 +1559,8 @@ func (rc *Controller) restoreTables(ctx context.Context) error {
 	wg.Wait()
 
 	err = restoreErr.Get()
-	logTask.End(zap.ErrorLevel, err)
+	logTask.End(zap.ErrorLevel, err,
+		zap.Float64("filteredRows", metric.ReadCounter(rc.metrics.RowsCounter.WithLabelValues(metric.RowStateFiltered))))
 	return err
 }
 
@@ -1527,7 +1637,7 @@ func (tr *TableRestore) restoreTable(
 		if err := rc.checkpointsDB.InsertEngineCheckpoints(ctx, tr.tableName, cp.Engines); err != nil {
 			return false, errors.Trace(err)
 		}
-		web.BroadcastTableCheckpoint(tr.tableName, cp)
+		web.BroadcastTableCheckpoint(rc.cfg.TaskID, tr.tableName, cp)
 
 		// rebase the allocator so it exceeds the number of rows.
 		if tr.tableInfo.Core.PKIsHandle && tr.tableInfo.Core.ContainsAutoRandomBits() {
@@ -1593,12 +1703,60 @@ func (rc *Controller) doCompact(ctx context.Context, level int32) error {
 	)
 }
 
+// importModeRegistry records the tasks of this process importing into each
+// cluster. The tasks may run concurrently in the server mode, and a cluster is
+// switched back to the normal mode only after all of them leave.
+type importModeRegistry struct {
+	mu sync.Mutex
+	// clusters maps the PD address to the IDs of the tasks importing into it.
+	clusters map[string]map[int64]struct{}
+}
+
+var importModeTasks = importModeRegistry{clusters: make(map[string]map[int64]struct{})}
+
+// enter records the task and switches the cluster to the import mode.
+func (r *importModeRegistry) enter(pdAddr string, taskID int64, switchMode func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tasks, ok := r.clusters[pdAddr]
+	if !ok {
+		tasks = make(map[int64]struct{})
+		r.clusters[pdAddr] = tasks
+	}
+	tasks[taskID] = struct{}{}
+	switchMode()
+}
+
+// leave forgets the task, and switches the cluster to the normal mode unless
+// other tasks are still importing into it. Returns whether the mode is switched.
+func (r *importModeRegistry) leave(pdAddr string, taskID int64, switchMode func()) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tasks := r.clusters[pdAddr]
+	delete(tasks, taskID)
+	if len(tasks) > 0 {
+		return false
+	}
+	delete(r.clusters, pdAddr)
+	switchMode()
+	return true
+}
+
 func (rc *Controller) switchToImportMode(ctx context.Context) {
-	rc.switchTiKVMode(ctx, sstpb.SwitchMode_Import)
+	importModeTasks.enter(rc.cfg.TiDB.PdAddr, rc.cfg.TaskID, func() {
+		rc.switchTiKVMode(ctx, sstpb.SwitchMode_Import)
+	})
 }
 
 func (rc *Controller) switchToNormalMode(ctx context.Context) error {
-	rc.switchTiKVMode(ctx, sstpb.SwitchMode_Normal)
+	switched := importModeTasks.leave(rc.cfg.TiDB.PdAddr, rc.cfg.TaskID, func() {
+		rc.switchTiKVMode(ctx, sstpb.SwitchMode_Normal)
+	})
+	if !switched {
+		log.L().Info("other tasks are still importing, keep tikv in import mode")
+	}
 	return nil
 }
 
@@ -1660,13 +1818,15 @@ func (rc *Controller) enforceDiskQuota(ctx context.Context) {
 			}
 
 			quota := int64(rc.cfg.TikvImporter.DiskQuota)
-			largeEngines, inProgressLargeEngines, totalDiskSize, totalMemSize := rc.backend.CheckDiskQuota(quota)
+			largeEngines, inProgressLargeEngines, totalDiskSize, totalMemSize, totalRemoteSize := rc.backend.CheckDiskQuota(quota)
 			metric.LocalStorageUsageBytesGauge.WithLabelValues("disk").Set(float64(totalDiskSize))
 			metric.LocalStorageUsageBytesGauge.WithLabelValues("mem").Set(float64(totalMemSize))
+			metric.LocalStorageUsageBytesGauge.WithLabelValues("remote").Set(float64(totalRemoteSize))
 
 			logger := log.With(
 				zap.Int64("diskSize", totalDiskSize),
 				zap.Int64("memSize", totalMemSize),
+				zap.Int64("remoteSize", totalRemoteSize),
 				zap.Int64("quota", quota),
 				zap.Int("largeEnginesCount", len(largeEngines)),
 				zap.Int("inProgressLargeEnginesCount", inProgressLargeEngines))
@@ -1695,13 +1855,18 @@ func (rc *Controller) enforceDiskQuota(ctx context.Context) {
 			}
 
 			// at this point, all engines are synchronized on disk.
-			// we then import every large engines one by one and complete.
+			// we then spill every closed large engines into the external storage if it is configured,
+			// and import the other large engines one by one and complete.
 			// if any engine failed to import, we just try again next time, since the data are still intact.
 			rc.diskQuotaState.Store(diskQuotaStateImporting)
 			task := logger.Begin(zap.WarnLevel, "importing large engines for disk quota")
 			var importErr error
 			for _, engine := range largeEngines {
-				if err := rc.backend.UnsafeImportAndReset(ctx, engine); err != nil {
+				spilled, err := rc.backend.SpillEngine(ctx, engine)
+				if err == nil && !spilled {
+					err = rc.backend.UnsafeImportAndReset(ctx, engine)
+				}
+				if err != nil {
 					importErr = multierr.Append(importErr, err)
 				}
 			}
@@ -1739,6 +1904,11 @@ func (rc *Controller) cleanCheckpoints(ctx context.Context) error {
 		zap.Int64("taskID", rc.cfg.TaskID),
 	)
 
+	if rc.tables != nil && rc.tables.hasCancelled() {
+		logger.Info("keep the checkpoints to restore the cancelled tables later")
+		return nil
+	}
+
 	task := logger.Begin(zap.InfoLevel, "clean checkpoints")
 	var err error
 	if rc.cfg.Checkpoint.KeepAfterSuccess {
@@ -1790,6 +1960,10 @@ func (rc *Controller) preCheckRequirements(ctx context.Context) error {
 			return errors.Trace(err)
 		}
 		if !taskExist {
+			if err = rc.taskMgr.InitTask(ctx, source); err != nil {
+				rc.taskMgr.CleanupTask(ctx)
+				return errors.Trace(err)
+			}
 			err = rc.LocalResource(ctx, source)
 			if err != nil {
 				rc.taskMgr.CleanupTask(ctx)
@@ -1826,6 +2000,11 @@ func (rc *Controller) DataCheck(ctx context.Context) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = rc.DataCharsetIsValid(ctx, rc.dbMetas); err != nil {
+		return errors.Trace(err)
+	}
+	// the pre-check controller never opens the checkpoints.
+	checkCheckpoints := rc.cfg.Checkpoint.Enable && rc.checkpointsDB != nil
 	checkPointCriticalMsgs := make([]string, 0, len(rc.dbMetas))
 	schemaCriticalMsgs := make([]string, 0, len(rc.dbMetas))
 	var msgs []string
@@ -1834,7 +2013,7 @@ func (rc *Controller) DataCheck(ctx context.Context) error {
 			// if hasCheckpoint is true, the table will start import from the checkpoint
 			// so we can skip TableHasDataInCluster and SchemaIsValid check.
 			noCheckpoint := true
-			if rc.cfg.Checkpoint.Enable {
+			if checkCheckpoints {
 				if msgs, noCheckpoint, err = rc.CheckpointIsValid(ctx, tableInfo); err != nil {
 					return errors.Trace(err)
 				}
@@ -1852,9 +2031,10 @@ func (rc *Controller) DataCheck(ctx context.Context) error {
 			}
 		}
 	}
-	if len(checkPointCriticalMsgs) != 0 {
+	switch {
+	case len(checkPointCriticalMsgs) != 0:
 		rc.checkTemplate.Collect(Critical, false, strings.Join(checkPointCriticalMsgs, "\n"))
-	} else {
+	case rc.checkpointsDB != nil:
 		rc.checkTemplate.Collect(Critical, true, "checkpoints are valid")
 	}
 	if len(schemaCriticalMsgs) != 0 {
@@ -1869,6 +2049,12 @@ type chunkRestore struct {
 	parser mydump.Parser
 	index  int
 	chunk  *checkpoints.ChunkCheckpoint
+	// convertor converts the text values into utf8mb4, it is nil if the data
+	// file needs no conversion.
+	convertor *mydump.CharsetConvertor
+	// streamed is true if the data file is decoded before being parsed, so
+	// the values are already in utf8mb4.
+	streamed bool
 }
 
 func newChunkRestore(
@@ -1881,9 +2067,12 @@ func newChunkRestore(
 	tableInfo *checkpoints.TidbTableInfo,
 ) (*chunkRestore, error) {
 	blockBufSize := int64(cfg.Mydumper.ReadBlockSize)
+	convertor, err := newCharsetConvertor(cfg, chunk.FileMeta.Type)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 
 	var reader storage.ReadSeekCloser
-	var err error
 	if chunk.FileMeta.Type == mydump.SourceTypeParquet {
 		reader, err = mydump.OpenParquetReader(ctx, store, chunk.FileMeta.Path, chunk.FileMeta.FileSize)
 	} else {
@@ -1892,6 +2081,10 @@ func newChunkRestore(
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	decodeReader := newDecodeReader(convertor, chunk.FileMeta.Type, reader)
+	if decodeReader != nil {
+		reader = decodeReader
+	}
 
 	var parser mydump.Parser
 	switch chunk.FileMeta.Type {
@@ -1905,24 +2098,182 @@ func newChunkRestore(
 		if err != nil {
 			return nil, errors.Trace(err)
 		}
+	case mydump.SourceTypeJSON:
+		parser = mydump.NewJSONParser(reader, blockBufSize, ioWorkers)
+	case mydump.SourceTypeAvro:
+		parser, err = mydump.NewAvroParser(reader)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	case mydump.SourceTypeFixed:
+		if tableInfo == nil {
+			reader.Close()
+			return nil, errors.Errorf("unknown table of the fixed-width file '%s'", chunk.Key.Path)
+		}
+		layout, err := mydump.GetFixedWidthLayout(cfg, tableInfo.DB, tableInfo.Name)
+		if err != nil {
+			reader.Close()
+			return nil, errors.Trace(err)
+		}
+		parser = mydump.NewFixedWidthParser(layout, reader, blockBufSize, ioWorkers)
 	default:
 		panic(fmt.Sprintf("file '%s' with unknown source type '%s'", chunk.Key.Path, chunk.FileMeta.Type.String()))
 	}
+	if decodeReader != nil {
+		parser = mydump.NewDecodedParser(parser, decodeReader)
+	}
 
 	if err = parser.SetPos(chunk.Chunk.Offset, chunk.Chunk.PrevRowIDMax); err != nil {
 		return nil, errors.Trace(err)
 	}
 	if len(chunk.ColumnPermutation) > 0 {
 		parser.SetColumns(getColumnNames(tableInfo.Core, chunk.ColumnPermutation))
+	} else if chunk.FileMeta.Type == mydump.SourceTypeJSON && tableInfo != nil {
+		// the keys of JSON objects differ from row to row, so map them to all
+		// the columns instead of the keys of the first object.
+		parser.SetColumns(getWritableColumnNames(tableInfo.Core))
 	}
 
 	return &chunkRestore{
-		parser: parser,
-		index:  index,
-		chunk:  chunk,
+		parser:    parser,
+		index:     index,
+		chunk:     chunk,
+		convertor: convertor,
+		streamed:  decodeReader != nil,
 	}, nil
 }
 
+// rowDecoder converts the text fields of the source rows into utf8mb4 with the
+// charset convertor, except the fields of the binary columns. If the data file
+// is decoded before being parsed, it only checks the text fields, and converts
+// the fields of the binary columns back into the source character set.
+type rowDecoder struct {
+	convertor *mydump.CharsetConvertor
+	streamed  bool
+	// binary marks the fields of the binary columns.
+	binary []bool
+	// names are the column names of the fields used in the error messages.
+	names []string
+}
+
+func newRowDecoder(convertor *mydump.CharsetConvertor, streamed bool, tableInfo *model.TableInfo, permutation []int) *rowDecoder {
+	d := &rowDecoder{convertor: convertor, streamed: streamed}
+	for i, index := range permutation {
+		if index < 0 || i >= len(tableInfo.Columns) {
+			continue
+		}
+		for len(d.names) <= index {
+			d.binary = append(d.binary, false)
+			d.names = append(d.names, "")
+		}
+		col := tableInfo.Columns[i]
+		d.binary[index] = col.Charset == charset.CharsetBin
+		d.names[index] = col.Name.O
+	}
+	return d
+}
+
+// decode converts the string values of the row in place.
+func (d *rowDecoder) decode(row []types.Datum, rowID int64) error {
+	for i := range row {
+		if row[i].Kind() != types.KindString {
+			continue
+		}
+		binary := i < len(d.binary) && d.binary[i]
+		if binary && !d.streamed {
+			continue
+		}
+		value := row[i].GetString()
+		var decoded string
+		var err error
+		switch {
+		case binary:
+			decoded, err = d.convertor.Encode(value)
+		case d.streamed:
+			decoded, err = value, d.convertor.CheckDecoded(value)
+		default:
+			decoded, err = d.convertor.Decode(value)
+		}
+		if err != nil {
+			name := fmt.Sprintf("#%d", i+1)
+			if i < len(d.names) && len(d.names[i]) > 0 {
+				name = d.names[i]
+			}
+			// the source bytes of a decoded value are lost, which is shown
+			// with U+FFFD in place of the invalid characters instead.
+			shown := value
+			if !d.streamed {
+				shown = hexPrefix(value)
+			}
+			return table.ErrTruncatedWrongValueForField.GenWithStackByArgs(
+				d.convertor.Charset(), shown, name, rowID)
+		}
+		row[i].SetString(decoded, row[i].Collation())
+	}
+	return nil
+}
+
+// decodeColumnNames converts the column names in the header of a CSV file. If
+// the file is decoded before being parsed, the names are only checked.
+func decodeColumnNames(convertor *mydump.CharsetConvertor, streamed bool, columnNames []string) ([]string, error) {
+	decoded := make([]string, 0, len(columnNames))
+	for _, name := range columnNames {
+		var d string
+		var err error
+		if streamed {
+			d, err = name, convertor.CheckDecoded(name)
+		} else {
+			d, err = convertor.Decode(name)
+		}
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid column name %s in the header", hexPrefix(name))
+		}
+		decoded = append(decoded, d)
+	}
+	return decoded, nil
+}
+
+// hexPrefix formats the leading bytes of the value like the "Incorrect string
+// value" errors of MySQL.
+func hexPrefix(value string) string {
+	const maxLen = 6
+	var sb strings.Builder
+	for i := 0; i < len(value) && i < maxLen; i++ {
+		fmt.Fprintf(&sb, "\\x%02X", value[i])
+	}
+	if len(value) > maxLen {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
+
+// newCharsetConvertor creates the convertor of the text values of the data
+// file. Only the CSV, SQL and fixed-width files are converted, the values of
+// the other formats are always in utf8mb4.
+func newCharsetConvertor(cfg *config.Config, sourceType mydump.SourceType) (*mydump.CharsetConvertor, error) {
+	switch sourceType {
+	case mydump.SourceTypeCSV, mydump.SourceTypeSQL, mydump.SourceTypeFixed:
+		return mydump.NewCharsetConvertor(cfg.Mydumper.DataCharacterSet, cfg.Mydumper.DataInvalidChar)
+	default:
+		return nil, nil
+	}
+}
+
+// newDecodeReader wraps the reader of a CSV or SQL file with a decode reader if
+// the file must be decoded before being parsed, otherwise it returns nil. The
+// fixed-width files are never decoded before being parsed, as their fields are
+// sliced by the byte offsets.
+func newDecodeReader(
+	convertor *mydump.CharsetConvertor,
+	sourceType mydump.SourceType,
+	reader storage.ReadSeekCloser,
+) *mydump.DecodeReader {
+	if !convertor.DecodeStream() || (sourceType != mydump.SourceTypeCSV && sourceType != mydump.SourceTypeSQL) {
+		return nil
+	}
+	return convertor.NewDecodeReader(reader)
+}
+
 func (cr *chunkRestore) close() {
 	cr.parser.Close()
 }
@@ -1955,6 +2306,17 @@ func getColumnNames(tableInfo *model.TableInfo, permutation []int) []string {
 	return names
 }
 
+// getWritableColumnNames returns the names of the non-generated columns.
+func getWritableColumnNames(tableInfo *model.TableInfo) []string {
+	names := make([]string, 0, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		if !col.IsGenerated() {
+			names = append(names, col.Name.L)
+		}
+	}
+	return names
+}
+
 var (
 	maxKVQueueSize         = 32             // Cache at most this number of rows before blocking the encode loop
 	minDeliverBytes uint64 = 96 * units.KiB // 96 KB (data + index). batch at least this amount of bytes to reduce number of messages
@@ -2175,12 +2537,23 @@ func (cr *chunkRestore) encodeLoop(
 		}
 	}
 
-	pauser, maxKvPairsCnt := rc.pauser, rc.cfg.TikvImporter.MaxKVPairs
+	pauser, tablePauser, maxKvPairsCnt := rc.pauser, t.pauser, rc.cfg.TikvImporter.MaxKVPairs
 	initializedColumns, reachEOF := false, false
+	var decoder *rowDecoder
+	var transformer *kv.RowTransformer
+	filteredRowsCounter := rc.metrics.RowsCounter.WithLabelValues(metric.RowStateFiltered)
+	defer func() {
+		if transformer != nil {
+			transformer.Close()
+		}
+	}()
 	for !reachEOF {
 		if err = pauser.Wait(ctx); err != nil {
 			return
 		}
+		if err = tablePauser.Wait(ctx); err != nil {
+			return
+		}
 		offset, _ := cr.parser.Pos()
 		if offset >= cr.chunk.Chunk.EndOffset {
 			break
@@ -2203,30 +2576,95 @@ func (cr *chunkRestore) encodeLoop(
 			case nil:
 				if !initializedColumns {
 					if len(cr.chunk.ColumnPermutation) == 0 {
+						if cr.convertor != nil && cr.chunk.FileMeta.Type == mydump.SourceTypeCSV && len(columnNames) > 0 {
+							if columnNames, err = decodeColumnNames(cr.convertor, cr.streamed, columnNames); err != nil {
+								return
+							}
+							cr.parser.SetColumns(columnNames)
+						}
 						if err = t.initializeColumns(columnNames, cr.chunk); err != nil {
 							return
 						}
 					}
+					if cr.convertor != nil {
+						decoder = newRowDecoder(cr.convertor, cr.streamed, t.tableInfo.Core, cr.chunk.ColumnPermutation)
+					}
+					if t.transform != nil {
+						if transformer, err = cr.newRowTransformer(ctx, t, rc, columnNames); err != nil {
+							return
+						}
+					}
 					initializedColumns = true
 				}
 			case io.EOF:
 				reachEOF = true
 				break outLoop
 			default:
-				err = errors.Annotatef(err, "in file %s at offset %d", &cr.chunk.Key, newOffset)
-				return
+				// the malformed row can be skipped only if the parser has moved forward.
+				if newOffset > curOffset {
+					var rowText string
+					if rc.errorMgr.Remaining(errormanager.SyntaxError) > 0 {
+						rowText = cr.rowText(nil)
+					}
+					err = rc.errorMgr.RecordSyntaxError(ctx, logger, t.tableName, cr.chunk.Key.Path, curOffset, rowText, err)
+				}
+				if err != nil {
+					err = errors.Annotatef(err, "in file %s at offset %d", &cr.chunk.Key, newOffset)
+					return
+				}
+				readDur += time.Since(readDurStart)
+				canDeliver = newOffset >= cr.chunk.Chunk.EndOffset
+				curOffset = newOffset
+				continue
 			}
 			readDur += time.Since(readDurStart)
 			encodeDurStart := time.Now()
 			lastRow := cr.parser.LastRow()
+			row, columnPermutation := lastRow.Row, cr.chunk.ColumnPermutation
+			var kvs kv.Row
+			var encodeErr error
+			keep := true
+			if decoder != nil {
+				encodeErr = decoder.decode(row, lastRow.RowID)
+			}
+			if transformer != nil && encodeErr == nil {
+				row, keep, encodeErr = transformer.Transform(row)
+				columnPermutation, columnNames = transformer.ColumnPermutation(), transformer.Columns()
+			}
+			if !keep {
+				// the filtered rows are never encoded, so they are not included
+				// in the local checksum compared with the imported data.
+				encodeDur += time.Since(encodeDurStart)
+				cr.parser.RecycleRow(lastRow)
+				t.filteredRows.Inc()
+				filteredRowsCounter.Inc()
+				canDeliver = newOffset >= cr.chunk.Chunk.EndOffset
+				curOffset = newOffset
+				continue
+			}
 			// sql -> kv
-			kvs, encodeErr := kvEncoder.Encode(logger, lastRow.Row, lastRow.RowID, cr.chunk.ColumnPermutation, curOffset)
+			if encodeErr == nil {
+				kvs, encodeErr = kvEncoder.Encode(logger, row, lastRow.RowID, columnPermutation, cr.chunk.Key.Path, curOffset)
+			}
 			encodeDur += time.Since(encodeDurStart)
+			rejected := encodeErr != nil
+			if rejected {
+				var rowText string
+				if rc.errorMgr.Remaining(errormanager.TypeError) > 0 || rc.errorMgr.Remaining(errormanager.CharsetError) > 0 {
+					rowText = cr.rowText(lastRow.Row)
+				}
+				encodeErr = rc.errorMgr.RecordTypeError(ctx, logger, t.tableName, cr.chunk.Key.Path, curOffset, rowText, encodeErr)
+			}
 			cr.parser.RecycleRow(lastRow)
 			if encodeErr != nil {
 				err = errors.Annotatef(encodeErr, "in file %s at offset %d", &cr.chunk.Key, newOffset)
 				return
 			}
+			if rejected {
+				canDeliver = newOffset >= cr.chunk.Chunk.EndOffset
+				curOffset = newOffset
+				continue
+			}
 			kvPacket = append(kvPacket, deliveredKVs{kvs: kvs, columns: columnNames, offset: newOffset, rowID: rowID})
 			kvSize += kvs.Size()
 			failpoint.Inject("mock-kv-size", func(val failpoint.Value) {
@@ -2260,6 +2698,79 @@ func (cr *chunkRestore) encodeLoop(
 	return
 }
 
+// newRowTransformer creates the transformer computing the target columns of
+// the rows in the chunk. The source columns of a CSV file with header are read
+// from the header, since the parser doesn't read it when resuming from the
+// middle of the file.
+func (cr *chunkRestore) newRowTransformer(
+	ctx context.Context,
+	t *TableRestore,
+	rc *Controller,
+	columnNames []string,
+) (*kv.RowTransformer, error) {
+	if cr.chunk.FileMeta.Type == mydump.SourceTypeCSV && rc.cfg.Mydumper.CSV.Header {
+		header, _, err := rc.readColumnsAndCount(ctx, t.tableMeta, cr.chunk.FileMeta)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		columnNames = header
+	}
+	transformer, err := kv.NewRowTransformer(t.tableInfo.Core, t.transform.Columns, t.transform.Where, columnNames, cr.chunk.ColumnPermutation, &kv.SessionOptions{
+		SQLMode:   rc.cfg.TiDB.SQLMode,
+		Timestamp: cr.chunk.Timestamp,
+		SysVars:   rc.sysVars,
+	})
+	return transformer, errors.Annotatef(err, "failed to create the transformer of %s", t.tableName)
+}
+
+// maxRowTextSize is the maximum size of the original text of a rejected row.
+const maxRowTextSize = 64 * 1024
+
+// rowText returns the original text of the row last read by the parser in
+// utf8mb4, or the formatted values of the row if the data file is not a text
+// file.
+func (cr *chunkRestore) rowText(row []types.Datum) string {
+	text := cr.parser.RowText()
+	if text == nil {
+		return formatRow(row)
+	}
+	if len(text) > maxRowTextSize {
+		text = text[:maxRowTextSize]
+	}
+	str := string(text)
+	if cr.convertor != nil && !cr.streamed {
+		if decoded, err := cr.convertor.Decode(str); err == nil {
+			str = decoded
+		}
+	}
+	return strings.ToValidUTF8(str, "\uFFFD")
+}
+
+// formatRow formats the values of a row into a tuple.
+func formatRow(row []types.Datum) string {
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for i, datum := range row {
+		if i != 0 {
+			sb.WriteString(", ")
+		}
+		switch datum.Kind() {
+		case types.KindNull:
+			sb.WriteString("NULL")
+		case types.KindMinNotNull:
+			sb.WriteString("DEFAULT")
+		default:
+			str, err := datum.ToString()
+			if err != nil {
+				str = datum.String()
+			}
+			sb.WriteString(strconv.Quote(str))
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
 func (cr *chunkRestore) restore(
 	ctx context.Context,
 	t *TableRestore,
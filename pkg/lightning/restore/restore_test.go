@@ -50,6 +50,7 @@ import (
 	"github.com/pingcap/br/pkg/lightning/checkpoints"
 	"github.com/pingcap/br/pkg/lightning/common"
 	"github.com/pingcap/br/pkg/lightning/config"
+	"github.com/pingcap/br/pkg/lightning/errormanager"
 	"github.com/pingcap/br/pkg/lightning/glue"
 	"github.com/pingcap/br/pkg/lightning/log"
 	"github.com/pingcap/br/pkg/lightning/metric"
@@ -96,7 +97,7 @@ func (s *restoreSuite) TestNewTableRestore(c *C) {
 	for _, tc := range testCases {
 		tableInfo := dbInfo.Tables[tc.name]
 		tableName := common.UniqueTable("mockdb", tableInfo.Name)
-		tr, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &checkpoints.TableCheckpoint{}, nil)
+		tr, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &checkpoints.TableCheckpoint{}, nil, nil)
 		c.Assert(tr, NotNil)
 		c.Assert(err, IsNil)
 	}
@@ -112,7 +113,7 @@ func (s *restoreSuite) TestNewTableRestoreFailure(c *C) {
 	}}
 	tableName := common.UniqueTable("mockdb", "failure")
 
-	_, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &checkpoints.TableCheckpoint{}, nil)
+	_, err := NewTableRestore(tableName, nil, dbInfo, tableInfo, &checkpoints.TableCheckpoint{}, nil, nil)
 	c.Assert(err, ErrorMatches, `failed to tables\.TableFromMeta.*`)
 }
 
@@ -133,6 +134,32 @@ func (s *restoreSuite) TestErrorSummaries(c *C) {
 	})
 }
 
+func (s *restoreSuite) TestImportModeRegistry(c *C) {
+	r := importModeRegistry{clusters: make(map[string]map[int64]struct{})}
+	switches := 0
+	switchMode := func() {
+		switches++
+	}
+
+	r.enter("pd1", 1, switchMode)
+	r.enter("pd1", 2, switchMode)
+	r.enter("pd2", 3, switchMode)
+	r.enter("pd1", 1, switchMode)
+	c.Assert(switches, Equals, 4)
+
+	// the cluster is switched back only after all the tasks leave.
+	switches = 0
+	c.Assert(r.leave("pd1", 1, switchMode), IsFalse)
+	c.Assert(r.leave("pd1", 1, switchMode), IsFalse)
+	c.Assert(r.leave("pd2", 3, switchMode), IsTrue)
+	c.Assert(r.leave("pd1", 2, switchMode), IsTrue)
+	c.Assert(switches, Equals, 2)
+	c.Assert(r.clusters, HasLen, 0)
+
+	// the tasks never entering the import mode can still switch back.
+	c.Assert(r.leave("pd1", 4, switchMode), IsTrue)
+}
+
 func (s *restoreSuite) TestVerifyCheckpoint(c *C) {
 	dir := c.MkDir()
 	cpdb := checkpoints.NewFileCheckpointsDB(filepath.Join(dir, "cp.pb"))
@@ -396,7 +423,7 @@ func (s *tableRestoreSuiteBase) SetUpSuite(c *C) {
 func (s *tableRestoreSuiteBase) SetUpTest(c *C) {
 	// Collect into the test TableRestore structure
 	var err error
-	s.tr, err = NewTableRestore("`db`.`table`", s.tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil)
+	s.tr, err = NewTableRestore("`db`.`table`", s.tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil, nil)
 	c.Assert(err, IsNil)
 
 	s.cfg = config.NewConfig()
@@ -583,7 +610,7 @@ func (s *tableRestoreSuite) TestPopulateChunksCSVHeader(c *C) {
 	cfg.Mydumper.StrictFormat = true
 	rc := &Controller{cfg: cfg, ioWorkers: worker.NewPool(context.Background(), 1, "io"), store: store}
 
-	tr, err := NewTableRestore("`db`.`table`", tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil)
+	tr, err := NewTableRestore("`db`.`table`", tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil, nil)
 	c.Assert(err, IsNil)
 	c.Assert(tr.populateChunks(context.Background(), rc, cp), IsNil)
 
@@ -849,7 +876,7 @@ func (s *tableRestoreSuite) TestImportKVSuccess(c *C) {
 	importer := backend.MakeBackend(mockBackend)
 	chptCh := make(chan saveCp)
 	defer close(chptCh)
-	rc := &Controller{saveCpCh: chptCh}
+	rc := &Controller{saveCpCh: chptCh, metrics: metric.NewMetrics(0)}
 	go func() {
 		for range chptCh {
 		}
@@ -881,7 +908,7 @@ func (s *tableRestoreSuite) TestImportKVFailure(c *C) {
 	importer := backend.MakeBackend(mockBackend)
 	chptCh := make(chan saveCp)
 	defer close(chptCh)
-	rc := &Controller{saveCpCh: chptCh}
+	rc := &Controller{saveCpCh: chptCh, metrics: metric.NewMetrics(0)}
 	go func() {
 		for range chptCh {
 		}
@@ -903,14 +930,101 @@ func (s *tableRestoreSuite) TestImportKVFailure(c *C) {
 	c.Assert(err, ErrorMatches, "fake import error.*")
 }
 
+func (s *tableRestoreSuite) TestPostProcessResolveRowsWithoutChecksum(c *C) {
+	controller := gomock.NewController(c)
+	defer controller.Finish()
+	mockBackend := mock.NewMockBackend(controller)
+
+	db, sqlMock, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	sqlMock.ExpectExec("ALTER TABLE `db`\\.`table` AUTO_INCREMENT=.+").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	cfg.TikvImporter.Upsert = true
+	cfg.TikvImporter.DuplicateDetection = true
+	cfg.TikvImporter.DuplicateResolution = config.DupeResAlgRemove
+	cfg.PostRestore.Checksum = config.OpLevelOff
+	cfg.PostRestore.Analyze = config.OpLevelOff
+	chptCh := make(chan saveCp, 16)
+	rc := &Controller{
+		cfg:            cfg,
+		backend:        backend.MakeBackend(mockBackend),
+		tidbGlue:       glue.NewExternalTiDBGlue(db, mysql.ModeNone),
+		saveCpCh:       chptCh,
+		metrics:        metric.NewMetrics(0),
+		checksumWorks:  worker.NewPool(ctx, 1, "checksum"),
+		errorSummaries: makeErrorSummaries(log.L()),
+	}
+
+	replaced := verification.MakeKVChecksum(12, 3, 456)
+	resolved := verification.MakeKVChecksum(20, 2, 789)
+	removed := replaced
+	removed.Add(&resolved)
+	mockBackend.EXPECT().ShouldPostProcess().Return(true).AnyTimes()
+	mockBackend.EXPECT().
+		MergeExistingRows(ctx, s.tr.encTable, s.tr.tableName, gomock.Any()).
+		Return(replaced, nil)
+	mockBackend.EXPECT().
+		CollectLocalDuplicateRows(ctx, s.tr.encTable, s.tr.tableName).
+		Return(nil)
+	mockBackend.EXPECT().
+		ResolveDuplicateRows(ctx, s.tr.encTable, s.tr.tableName, gomock.Any(), gomock.Any()).
+		Return(resolved, nil)
+	mockBackend.EXPECT().
+		FinishMergeExistingRows(ctx, s.tr.encTable).
+		Return(nil)
+
+	cp := &checkpoints.TableCheckpoint{
+		Status: checkpoints.CheckpointStatusIndexImported,
+		Engines: map[int32]*checkpoints.EngineCheckpoint{
+			-1: {Status: checkpoints.CheckpointStatusImported},
+			0:  {Status: checkpoints.CheckpointStatusImported},
+		},
+	}
+	_, err = s.tr.postProcess(ctx, rc, cp, false, nil)
+	c.Assert(err, IsNil)
+	c.Assert(cp.RemovedChecksum, Equals, removed)
+	c.Assert(cp.Status, Equals, checkpoints.CheckpointStatusAnalyzed)
+
+	close(chptCh)
+	cpd := checkpoints.NewTableCheckpointDiff()
+	for scp := range chptCh {
+		scp.merger.MergeInto(cpd)
+		if m, ok := scp.merger.(*checkpoints.StatusCheckpointMerger); ok && m.Status == checkpoints.CheckpointStatusDupeResolved {
+			break
+		}
+	}
+	saved := &checkpoints.TableCheckpoint{}
+	saved.Apply(cpd)
+	c.Assert(saved.RemovedChecksum, Equals, removed)
+	c.Assert(saved.Status, Equals, checkpoints.CheckpointStatusDupeResolved)
+
+	// the rows are not merged or resolved again after restarting.
+	cp.Status = checkpoints.CheckpointStatusDupeResolved
+	chptCh = make(chan saveCp, 16)
+	rc.saveCpCh = chptCh
+	mockBackend.EXPECT().
+		FinishMergeExistingRows(ctx, s.tr.encTable).
+		Return(nil)
+	_, err = s.tr.postProcess(ctx, rc, cp, false, nil)
+	c.Assert(err, IsNil)
+
+	sqlMock.ExpectClose()
+	c.Assert(db.Close(), IsNil)
+	c.Assert(sqlMock.ExpectationsWereMet(), IsNil)
+}
+
 func (s *tableRestoreSuite) TestTableRestoreMetrics(c *C) {
 	controller := gomock.NewController(c)
 	defer controller.Finish()
 
-	chunkPendingBase := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
-	chunkFinishedBase := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
-	engineFinishedBase := metric.ReadCounter(metric.ProcessedEngineCounter.WithLabelValues("imported", metric.TableResultSuccess))
-	tableFinishedBase := metric.ReadCounter(metric.TableCounter.WithLabelValues("index_imported", metric.TableResultSuccess))
+	metrics := metric.NewMetrics(0)
+	chunkPendingBase := metric.ReadCounter(metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
+	chunkFinishedBase := metric.ReadCounter(metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
+	engineFinishedBase := metric.ReadCounter(metrics.ProcessedEngineCounter.WithLabelValues("imported", metric.TableResultSuccess))
+	tableFinishedBase := metric.ReadCounter(metrics.TableCounter.WithLabelValues("index_imported", metric.TableResultSuccess))
 
 	ctx := context.Background()
 	chptCh := make(chan saveCp)
@@ -963,6 +1077,7 @@ func (s *tableRestoreSuite) TestTableRestoreMetrics(c *C) {
 		store:             s.store,
 		metaMgrBuilder:    noopMetaMgrBuilder{},
 		diskQuotaLock:     newDiskQuotaLock(),
+		metrics:           metrics,
 	}
 	go func() {
 		for range chptCh {
@@ -973,20 +1088,20 @@ func (s *tableRestoreSuite) TestTableRestoreMetrics(c *C) {
 	exec.EXPECT().ObtainStringWithLog(gomock.Any(), "SELECT version()", gomock.Any(), gomock.Any()).
 		Return("5.7.25-TiDB-v5.0.1", nil).AnyTimes()
 
-	web.BroadcastInitProgress(rc.dbMetas)
+	web.BroadcastInitProgress(rc.cfg.TaskID, rc.dbMetas)
 
 	err = rc.restoreTables(ctx)
 	c.Assert(err, IsNil)
 
-	chunkPending := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
-	chunkFinished := metric.ReadCounter(metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
+	chunkPending := metric.ReadCounter(metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
+	chunkFinished := metric.ReadCounter(metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending))
 	c.Assert(chunkPending-chunkPendingBase, Equals, float64(7))
 	c.Assert(chunkFinished-chunkFinishedBase, Equals, chunkPending)
 
-	engineFinished := metric.ReadCounter(metric.ProcessedEngineCounter.WithLabelValues("imported", metric.TableResultSuccess))
+	engineFinished := metric.ReadCounter(metrics.ProcessedEngineCounter.WithLabelValues("imported", metric.TableResultSuccess))
 	c.Assert(engineFinished-engineFinishedBase, Equals, float64(8))
 
-	tableFinished := metric.ReadCounter(metric.TableCounter.WithLabelValues("index_imported", metric.TableResultSuccess))
+	tableFinished := metric.ReadCounter(metrics.TableCounter.WithLabelValues("index_imported", metric.TableResultSuccess))
 	c.Assert(tableFinished-tableFinishedBase, Equals, float64(1))
 }
 
@@ -1159,7 +1274,7 @@ func (s *chunkRestoreSuite) TestDeliverLoop(c *C) {
 	}()
 
 	cfg := &config.Config{}
-	rc := &Controller{cfg: cfg, saveCpCh: saveCpCh, backend: importer, diskQuotaLock: newDiskQuotaLock()}
+	rc := &Controller{cfg: cfg, saveCpCh: saveCpCh, backend: importer, diskQuotaLock: newDiskQuotaLock(), metrics: metric.NewMetrics(0)}
 
 	_, err = s.cr.deliverLoop(ctx, kvsCh, s.tr, 0, dataWriter, indexWriter, rc)
 	c.Assert(err, IsNil)
@@ -1179,7 +1294,7 @@ func (s *chunkRestoreSuite) TestEncodeLoop(c *C) {
 	})
 	c.Assert(err, IsNil)
 	cfg := config.NewConfig()
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
 	c.Assert(err, IsNil)
 	c.Assert(kvsCh, HasLen, 2)
@@ -1205,7 +1320,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopCanceled(c *C) {
 
 	go cancel()
 	cfg := config.NewConfig()
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
 	c.Assert(errors.Cause(err), Equals, context.Canceled)
 	c.Assert(kvsCh, HasLen, 0)
@@ -1225,7 +1340,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopForcedError(c *C) {
 	s.cr.parser.Close()
 
 	cfg := config.NewConfig()
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
 	c.Assert(err, ErrorMatches, `in file .*[/\\]?db\.table\.2\.sql:0 at offset 0:.*file already closed`)
 	c.Assert(kvsCh, HasLen, 0)
@@ -1256,7 +1371,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopDeliverLimit(c *C) {
 	p := mydump.NewCSVParser(&cfg.Mydumper.CSV, reader, 111, w, false)
 	s.cr.parser = p
 
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 	c.Assert(failpoint.Enable(
 		"github.com/pingcap/br/pkg/lightning/restore/mock-kv-size", "return(110000000)"), IsNil)
 	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
@@ -1299,7 +1414,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopDeliverErrored(c *C) {
 		}
 	}()
 	cfg := config.NewConfig()
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
 	c.Assert(err, ErrorMatches, "fake deliver error")
 	c.Assert(kvsCh, HasLen, 0)
@@ -1316,7 +1431,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopColumnsMismatch(c *C) {
 
 	ctx := context.Background()
 	cfg := config.NewConfig()
-	rc := &Controller{pauser: DeliverPauser, cfg: cfg}
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, metrics: metric.NewMetrics(0)}
 
 	reader, err := store.Open(ctx, fileName)
 	c.Assert(err, IsNil)
@@ -1329,7 +1444,7 @@ func (s *chunkRestoreSuite) TestEncodeLoopColumnsMismatch(c *C) {
 
 	kvsCh := make(chan []deliveredKVs, 2)
 	deliverCompleteCh := make(chan deliverResult)
-	kvEncoder, err := tidb.NewTiDBBackend(nil, config.ReplaceOnDup).NewEncoder(
+	kvEncoder, err := tidb.NewTiDBBackend(nil, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil).NewEncoder(
 		s.tr.encTable,
 		&kv.SessionOptions{
 			SQLMode:   s.cfg.TiDB.SQLMode,
@@ -1342,6 +1457,320 @@ func (s *chunkRestoreSuite) TestEncodeLoopColumnsMismatch(c *C) {
 	c.Assert(kvsCh, HasLen, 0)
 }
 
+func (s *chunkRestoreSuite) TestEncodeLoopSkipRejectedRows(c *C) {
+	dir := c.MkDir()
+	fileName := "db.table.000.csv"
+	err := os.WriteFile(filepath.Join(dir, fileName), []byte("1,2,3\n4,5,6,7\n8,9,10\n"), 0o644)
+	c.Assert(err, IsNil)
+
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	cfg.App.TaskInfoSchemaName = "lightning_task_info"
+	cfg.App.MaxError.Type = 1
+	db, mockDB, err := sqlmock.New()
+	c.Assert(err, IsNil)
+	defer db.Close()
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, store: store, errorMgr: errormanager.New(db, cfg), metrics: metric.NewMetrics(0)}
+
+	// the rejected row is recorded with its original text, which is read
+	// across the blocks of the parser.
+	mockDB.ExpectExec("INSERT INTO `lightning_task_info`\\.type_error_v1.*").
+		WithArgs(0, s.tr.tableName, s.cr.chunk.Key.Path, 6, sqlmock.AnyArg(), "4,5,6,7\n").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	reader, err := store.Open(ctx, fileName)
+	c.Assert(err, IsNil)
+	w := worker.NewPool(ctx, 5, "io")
+	p := mydump.NewCSVParser(&cfg.Mydumper.CSV, reader, 1, w, false)
+
+	err = s.cr.parser.Close()
+	c.Assert(err, IsNil)
+	s.cr.parser = p
+	s.cr.chunk.FileMeta.Path = fileName
+	s.cr.chunk.Chunk.EndOffset = 21
+
+	kvsCh := make(chan []deliveredKVs, 2)
+	deliverCompleteCh := make(chan deliverResult)
+	kvEncoder, err := tidb.NewTiDBBackend(nil, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil).NewEncoder(
+		s.tr.encTable,
+		&kv.SessionOptions{
+			SQLMode:   s.cfg.TiDB.SQLMode,
+			Timestamp: 1234567895,
+		})
+	c.Assert(err, IsNil)
+
+	_, _, err = s.cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
+	c.Assert(err, IsNil)
+	c.Assert(rc.errorMgr.Remaining(errormanager.TypeError), Equals, int64(0))
+	c.Assert(mockDB.ExpectationsWereMet(), IsNil)
+	c.Assert(kvsCh, HasLen, 2)
+
+	kvs := <-kvsCh
+	c.Assert(kvs, HasLen, 2)
+	c.Assert(kvs[0].offset, Equals, int64(6))
+	c.Assert(kvs[1].offset, Equals, int64(21))
+
+	kvs = <-kvsCh
+	c.Assert(kvs, HasLen, 0)
+}
+
+func (s *chunkRestoreSuite) TestEncodeLoopTransform(c *C) {
+	dir := c.MkDir()
+	fileName := "db.table.000.csv"
+	err := os.WriteFile(filepath.Join(dir, fileName), []byte("a,x,y\n1,2,3\n4,5,6\n"), 0o644)
+	c.Assert(err, IsNil)
+
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	cfg.Mydumper.CSV.Header = true
+	w := worker.NewPool(ctx, 5, "io")
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, store: store, ioWorkers: w, sysVars: defaultImportantVariables, metrics: metric.NewMetrics(0)}
+
+	tr, err := NewTableRestore("`db`.`table`", s.tr.tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil, &config.TransformRule{
+		Columns: map[string]string{
+			"b": "@x + @y",
+			"c": "'9'",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(tr.ignoreColumns, DeepEquals, []string{"x", "y"})
+
+	reader, err := store.Open(ctx, fileName)
+	c.Assert(err, IsNil)
+	p := mydump.NewCSVParser(&cfg.Mydumper.CSV, reader, 111, w, true)
+
+	err = s.cr.parser.Close()
+	c.Assert(err, IsNil)
+	s.cr.parser = p
+	s.cr.chunk.FileMeta.Path = fileName
+	s.cr.chunk.FileMeta.Type = mydump.SourceTypeCSV
+	s.cr.chunk.Chunk.EndOffset = 18
+
+	kvsCh := make(chan []deliveredKVs, 2)
+	deliverCompleteCh := make(chan deliverResult)
+	kvEncoder, err := tidb.NewTiDBBackend(nil, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil).NewEncoder(
+		tr.encTable,
+		&kv.SessionOptions{
+			SQLMode:   s.cfg.TiDB.SQLMode,
+			Timestamp: 1234567895,
+		})
+	c.Assert(err, IsNil)
+
+	_, _, err = s.cr.encodeLoop(ctx, kvsCh, tr, tr.logger, kvEncoder, deliverCompleteCh, rc)
+	c.Assert(err, IsNil)
+	// the checkpointed column permutation refers to the source columns.
+	c.Assert(s.cr.chunk.ColumnPermutation, DeepEquals, []int{0, -1, -1, -1})
+	c.Assert(kvsCh, HasLen, 2)
+
+	kvs := <-kvsCh
+	c.Assert(kvs, HasLen, 2)
+	c.Assert(kvs[0].columns, DeepEquals, []string{"a", "b", "c"})
+	c.Assert(fmt.Sprint(kvs[0].kvs), Equals, "('1',5,'9')")
+	c.Assert(fmt.Sprint(kvs[1].kvs), Equals, "('4',11,'9')")
+
+	kvs = <-kvsCh
+	c.Assert(kvs, HasLen, 0)
+}
+
+func (s *chunkRestoreSuite) TestEncodeLoopWhere(c *C) {
+	dir := c.MkDir()
+	fileName := "db.table.000.csv"
+	err := os.WriteFile(filepath.Join(dir, fileName), []byte("1,2,3\n4,5,6\n7,8,9\n"), 0o644)
+	c.Assert(err, IsNil)
+
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	cfg.Mydumper.CSV.Header = false
+	w := worker.NewPool(ctx, 5, "io")
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, store: store, ioWorkers: w, sysVars: defaultImportantVariables, metrics: metric.NewMetrics(0)}
+
+	tr, err := NewTableRestore("`db`.`table`", s.tr.tableMeta, s.dbInfo, s.tableInfo, &checkpoints.TableCheckpoint{}, nil, &config.TransformRule{
+		Where: "@a > 1 AND @c < 9",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(tr.ignoreColumns, HasLen, 0)
+
+	reader, err := store.Open(ctx, fileName)
+	c.Assert(err, IsNil)
+	p := mydump.NewCSVParser(&cfg.Mydumper.CSV, reader, 111, w, false)
+
+	err = s.cr.parser.Close()
+	c.Assert(err, IsNil)
+	s.cr.parser = p
+	s.cr.chunk.FileMeta.Path = fileName
+	s.cr.chunk.FileMeta.Type = mydump.SourceTypeCSV
+	s.cr.chunk.Chunk.EndOffset = 18
+
+	kvsCh := make(chan []deliveredKVs, 2)
+	deliverCompleteCh := make(chan deliverResult)
+	kvEncoder, err := kv.NewTableKVEncoder(tr.encTable, &kv.SessionOptions{
+		SQLMode:   s.cfg.TiDB.SQLMode,
+		Timestamp: 1234567895,
+	})
+	c.Assert(err, IsNil)
+
+	filteredRows := metric.ReadCounter(rc.metrics.RowsCounter.WithLabelValues(metric.RowStateFiltered))
+	_, _, err = s.cr.encodeLoop(ctx, kvsCh, tr, tr.logger, kvEncoder, deliverCompleteCh, rc)
+	c.Assert(err, IsNil)
+	c.Assert(tr.filteredRows.Load(), Equals, int64(2))
+	c.Assert(metric.ReadCounter(rc.metrics.RowsCounter.WithLabelValues(metric.RowStateFiltered))-filteredRows, Equals, 2.0)
+	c.Assert(kvsCh, HasLen, 2)
+
+	// only the second row is encoded.
+	kvs := <-kvsCh
+	c.Assert(kvs, HasLen, 1)
+	c.Assert(kvs[0].offset, Equals, int64(12))
+
+	kvs = <-kvsCh
+	c.Assert(kvs, HasLen, 0)
+}
+
+func (s *chunkRestoreSuite) TestEncodeLoopCharset(c *C) {
+	p := parser.New()
+	se := tmock.NewContext()
+	node, err := p.ParseOneStmt("CREATE TABLE `table` (a VARCHAR(16), b VARBINARY(16))", "", "")
+	c.Assert(err, IsNil)
+	core, err := ddl.MockTableInfo(se, node.(*ast.CreateTableStmt), 0xabcdef)
+	c.Assert(err, IsNil)
+	core.State = model.StatePublic
+	tableInfo := &checkpoints.TidbTableInfo{Name: "table", DB: "db", Core: core}
+	dbInfo := &checkpoints.TidbDBInfo{Name: "db", Tables: map[string]*checkpoints.TidbTableInfo{"table": tableInfo}}
+
+	dir := c.MkDir()
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	ctx := context.Background()
+	w := worker.NewPool(ctx, 5, "io")
+
+	// "中文" encoded in gb18030, the value of the binary column is not converted.
+	// "\x95\x5c" ends with a backslash, which must not escape the delimiter.
+	content := "a,b\n\xd6\xd0\xce\xc4,\xd6\xd0\n\x95\x5c,\x95\x5c\n\xd6\xd0\xce,x\n"
+	err = os.WriteFile(filepath.Join(dir, "db.table.000.csv"), []byte(content), 0o644)
+	c.Assert(err, IsNil)
+
+	cases := []struct {
+		invalidChar string
+		rows        []string
+		err         string
+	}{
+		{
+			invalidChar: "replace",
+			rows:        []string{"('中文','\xd6\xd0')", "('昞','\x95\\\\')", "('中\ufffd','x')"},
+		},
+		{
+			invalidChar: "error",
+			rows:        []string{"('中文','\xd6\xd0')", "('昞','\x95\\\\')"},
+			err:         ".*Incorrect gb18030 value: '中\ufffd' for column 'a' at row 3.*",
+		},
+	}
+	for _, ca := range cases {
+		cfg := config.NewConfig()
+		cfg.Mydumper.CSV.Header = true
+		cfg.Mydumper.DataCharacterSet = "gb18030"
+		cfg.Mydumper.DataInvalidChar = ca.invalidChar
+		rc := &Controller{pauser: DeliverPauser, cfg: cfg, store: store, ioWorkers: w, metrics: metric.NewMetrics(0)}
+
+		tr, err := NewTableRestore("`db`.`table`", s.tr.tableMeta, dbInfo, tableInfo, &checkpoints.TableCheckpoint{}, nil, nil)
+		c.Assert(err, IsNil)
+		chunk := &checkpoints.ChunkCheckpoint{
+			Key:      checkpoints.ChunkCheckpointKey{Path: "db.table.000.csv"},
+			FileMeta: mydump.SourceFileMeta{Path: "db.table.000.csv", Type: mydump.SourceTypeCSV},
+			Chunk:    mydump.Chunk{EndOffset: int64(len(content)), RowIDMax: 3},
+		}
+		cr, err := newChunkRestore(ctx, 0, cfg, chunk, w, store, tableInfo)
+		c.Assert(err, IsNil)
+
+		kvsCh := make(chan []deliveredKVs, 2)
+		deliverCompleteCh := make(chan deliverResult)
+		kvEncoder, err := tidb.NewTiDBBackend(nil, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil).NewEncoder(tr.encTable, &kv.SessionOptions{})
+		c.Assert(err, IsNil)
+
+		_, _, err = cr.encodeLoop(ctx, kvsCh, tr, tr.logger, kvEncoder, deliverCompleteCh, rc)
+		cr.close()
+		if len(ca.err) > 0 {
+			c.Assert(err, ErrorMatches, ca.err)
+			c.Assert(kvsCh, HasLen, 0)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(kvsCh, HasLen, 2)
+		kvs := <-kvsCh
+		c.Assert(kvs, HasLen, len(ca.rows))
+		c.Assert(kvs[0].columns, DeepEquals, []string{"a", "b"})
+		for i, row := range ca.rows {
+			c.Assert(fmt.Sprint(kvs[i].kvs), Equals, row)
+		}
+		// the offsets are the offsets of the data file instead of the decoded rows.
+		c.Assert(kvs[0].offset, Equals, int64(strings.Index(content, "\x95")))
+		c.Assert(kvs[len(kvs)-1].offset, Equals, int64(len(content)))
+	}
+}
+
+func (s *chunkRestoreSuite) TestEncodeLoopFixedWidth(c *C) {
+	dir := c.MkDir()
+	fileName := "db.table.000.dat"
+	content := "  1 2 3\n  4  \n"
+	err := os.WriteFile(filepath.Join(dir, fileName), []byte(content), 0o644)
+	c.Assert(err, IsNil)
+	store, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	null := ""
+	cfg.Mydumper.FixedWidth = config.AllFixedWidthRules{{
+		DB:    "db",
+		Table: "table",
+		Columns: []*config.FixedWidthColumn{
+			{Name: "c", Start: 5, Width: 2},
+			{Name: "a", Start: 0, Width: 3},
+			{Name: "b", Start: 3, Width: 2},
+		},
+		Trim: true,
+		Null: &null,
+	}}
+	w := worker.NewPool(ctx, 5, "io")
+	rc := &Controller{pauser: DeliverPauser, cfg: cfg, store: store, ioWorkers: w, metrics: metric.NewMetrics(0)}
+
+	chunk := &checkpoints.ChunkCheckpoint{
+		Key:      checkpoints.ChunkCheckpointKey{Path: fileName},
+		FileMeta: mydump.SourceFileMeta{Path: fileName, Type: mydump.SourceTypeFixed},
+		Chunk:    mydump.Chunk{EndOffset: int64(len(content)), RowIDMax: 2},
+	}
+	cr, err := newChunkRestore(ctx, 0, cfg, chunk, w, store, s.tableInfo)
+	c.Assert(err, IsNil)
+	defer cr.close()
+
+	kvsCh := make(chan []deliveredKVs, 2)
+	deliverCompleteCh := make(chan deliverResult)
+	kvEncoder, err := tidb.NewTiDBBackend(nil, &config.TikvImporter{OnDuplicate: config.ReplaceOnDup}, nil).NewEncoder(s.tr.encTable, &kv.SessionOptions{})
+	c.Assert(err, IsNil)
+
+	_, _, err = cr.encodeLoop(ctx, kvsCh, s.tr, s.tr.logger, kvEncoder, deliverCompleteCh, rc)
+	c.Assert(err, IsNil)
+	c.Assert(chunk.ColumnPermutation, DeepEquals, []int{1, 2, 0, -1})
+	c.Assert(kvsCh, HasLen, 2)
+	kvs := <-kvsCh
+	c.Assert(kvs, HasLen, 2)
+	c.Assert(kvs[0].columns, DeepEquals, []string{"c", "a", "b"})
+	c.Assert(fmt.Sprint(kvs[0].kvs), Equals, "('3','1','2')")
+	c.Assert(fmt.Sprint(kvs[1].kvs), Equals, "(NULL,'4',NULL)")
+
+	// the layout of the table must be configured.
+	cfg.Mydumper.FixedWidth = nil
+	_, err = newChunkRestore(ctx, 0, cfg, chunk, w, store, s.tableInfo)
+	c.Assert(err, ErrorMatches, "no `mydumper.fixed-width` layout is configured for table `db`.`table`")
+}
+
 func (s *chunkRestoreSuite) TestRestore(c *C) {
 	ctx := context.Background()
 
@@ -1394,6 +1823,7 @@ func (s *chunkRestoreSuite) TestRestore(c *C) {
 		backend:       importer,
 		pauser:        DeliverPauser,
 		diskQuotaLock: newDiskQuotaLock(),
+		metrics:       metric.NewMetrics(0),
 	})
 	c.Assert(err, IsNil)
 	c.Assert(saveCpCh, HasLen, 2)
@@ -1465,6 +1895,7 @@ func (s *restoreSchemaSuite) SetUpSuite(c *C) {
 		store:         store,
 		dbMetas:       mydumpLoader.GetDatabases(),
 		checkpointsDB: &checkpoints.NullCheckpointsDB{},
+		metrics:       metric.NewMetrics(0),
 	}
 }
 
@@ -1755,6 +2186,137 @@ func (s *tableRestoreSuite) TestCheckHasLargeCSV(c *C) {
 	}
 }
 
+func (s *tableRestoreSuite) TestCheckDataCharset(c *C) {
+	dir := c.MkDir()
+	mockStore, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	files := map[string]string{
+		"utf8.csv":    "1,中文\n",
+		"gb18030.csv": "1,\xd6\xd0\xce\xc4\n",
+		"invalid.sql": "INSERT INTO t VALUES (1,'\xff\xff');\n",
+	}
+	for name, content := range files {
+		c.Assert(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644), IsNil)
+	}
+	dbMetas := func(path string, sourceType mydump.SourceType) []*mydump.MDDatabaseMeta {
+		return []*mydump.MDDatabaseMeta{{
+			Tables: []*mydump.MDTableMeta{{
+				DataFiles: []mydump.FileInfo{{FileMeta: mydump.SourceFileMeta{Path: path, Type: sourceType}}},
+			}},
+		}}
+	}
+
+	cases := []struct {
+		charset     string
+		invalidChar string
+		path        string
+		checkType   CheckType
+		failed      bool
+		msg         string
+	}{
+		{"binary", "error", "utf8.csv", Critical, false, ""},
+		{"binary", "error", "gb18030.csv", Critical, true, "(.*)gb18030.csv seems to be encoded in gb18030(.*)"},
+		{"utf8mb4", "error", "gb18030.csv", Critical, true, "(.*)gb18030.csv contains characters invalid in utf8mb4, it seems to be encoded in gb18030(.*)"},
+		{"gb18030", "error", "gb18030.csv", Critical, false, ""},
+		{"gb18030", "replace", "invalid.sql", Warn, true, "(.*)invalid.sql contains characters invalid in gb18030(.*)"},
+	}
+	for _, ca := range cases {
+		template := NewSimpleTemplate()
+		cfg := &config.Config{Mydumper: config.MydumperRuntime{DataCharacterSet: ca.charset, DataInvalidChar: ca.invalidChar}}
+		rc := &Controller{cfg: cfg, checkTemplate: template, store: mockStore}
+		sourceType := mydump.SourceTypeCSV
+		if strings.HasSuffix(ca.path, ".sql") {
+			sourceType = mydump.SourceTypeSQL
+		}
+		err := rc.DataCharsetIsValid(context.Background(), dbMetas(ca.path, sourceType))
+		c.Assert(err, IsNil)
+		comment := Commentf("case %+v", ca)
+		if !ca.failed {
+			c.Assert(template.FailedCount(ca.checkType), Equals, 0, comment)
+			continue
+		}
+		c.Assert(template.FailedCount(ca.checkType), Equals, 1, comment)
+		c.Assert(strings.ReplaceAll(template.Output(), "\n", ""), Matches, ca.msg, comment)
+	}
+}
+
+func (s *tableRestoreSuite) TestNewPreCheckController(c *C) {
+	ctx := context.Background()
+	for _, backendName := range []string{config.BackendLocal, config.BackendImporter, config.BackendTiDB} {
+		dir := c.MkDir()
+		db, sqlMock, err := sqlmock.New()
+		c.Assert(err, IsNil)
+
+		cfg := config.NewConfig()
+		cfg.TikvImporter.Backend = backendName
+		cfg.TikvImporter.SortedKVDir = filepath.Join(dir, "sorted-kv")
+		cfg.TikvImporter.DuplicateDetection = true
+		cfg.TikvImporter.DuplicateResolution = config.DupeResAlgRemove
+		cfg.App.MaxError.Type = 10
+		cfg.Checkpoint.Enable = true
+		cfg.Checkpoint.Driver = config.CheckpointDriverPebble
+		cfg.Checkpoint.DSN = filepath.Join(dir, "checkpoints")
+		cfg.Mydumper.SourceDir = dir
+
+		// neither the checkpoints, the local files nor the schemas of the
+		// error manager are created.
+		rc, err := NewPreCheckController(ctx, nil, cfg, nil, glue.NewExternalTiDBGlue(db, mysql.ModeNone))
+		c.Assert(err, IsNil)
+		c.Assert(rc.DataCheck(ctx), IsNil)
+		c.Assert(rc.checkTemplate.Results(), HasLen, 3)
+		sqlMock.ExpectClose()
+		rc.Close()
+		c.Assert(sqlMock.ExpectationsWereMet(), IsNil)
+
+		entries, err := os.ReadDir(dir)
+		c.Assert(err, IsNil)
+		c.Assert(entries, HasLen, 0, Commentf("backend %s", backendName))
+	}
+}
+
+func (s *tableRestoreSuite) TestSampleDataPreview(c *C) {
+	dir := c.MkDir()
+	mockStore, err := storage.NewLocalStorage(dir)
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "db.table.csv"), []byte("a,b,c\n1,x,\\N\n2,y,3\n3,z,4\n"), 0o644), IsNil)
+	tableMeta := &mydump.MDTableMeta{
+		DB:   "db",
+		Name: "table",
+		DataFiles: []mydump.FileInfo{{FileMeta: mydump.SourceFileMeta{
+			Path: "db.table.csv",
+			Type: mydump.SourceTypeCSV,
+		}}},
+	}
+
+	cfg := config.NewConfig()
+	cfg.TikvImporter.Backend = config.BackendTiDB
+	cfg.Mydumper.CSV.Header = true
+	cfg.Mydumper.CSV.Null = `\N`
+	rc := &Controller{
+		cfg:             cfg,
+		store:           mockStore,
+		backend:         tidb.NewTiDBBackend(nil, &cfg.TikvImporter, nil),
+		ioWorkers:       worker.NewPool(context.Background(), 1, "io"),
+		checkTemplate:   NewSimpleTemplate(),
+		previewRowCount: 2,
+	}
+	err = rc.SampleDataFromTable(context.Background(), "db", tableMeta, s.tableInfo.Core)
+	c.Assert(err, IsNil)
+	c.Assert(rc.previews, DeepEquals, []*TablePreview{{
+		DB:      "db",
+		Table:   "table",
+		Columns: []string{"a", "b", "c"},
+		Rows:    [][]interface{}{{"1", "x", nil}, {"2", "y", "3"}},
+	}})
+
+	rc.checkTemplate.Collect(Critical, true, "passed check")
+	rc.checkTemplate.Collect(Warn, false, "failed check")
+	c.Assert(rc.checkTemplate.Results(), DeepEquals, []CheckResult{
+		{Type: Critical, Passed: true, Message: "passed check"},
+		{Type: Warn, Passed: false, Message: "failed check"},
+	})
+}
+
 func (s *tableRestoreSuite) TestSchemaIsValid(c *C) {
 	dir := c.MkDir()
 	ctx := context.Background()
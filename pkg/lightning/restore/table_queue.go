@@ -0,0 +1,357 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+)
+
+type queuedTableState uint8
+
+const (
+	queuedTablePending queuedTableState = iota
+	queuedTableRunning
+	queuedTableFinished
+)
+
+type queuedTable struct {
+	tr *TableRestore
+	cp *checkpoints.TableCheckpoint
+	// seq is the position of the table in the data source, the tables of the
+	// same priority are restored in this order.
+	seq      int
+	state    queuedTableState
+	control  checkpoints.TableControl
+	priority int32
+	cancel   context.CancelFunc
+}
+
+// before returns whether the table should be restored before the other one.
+func (t *queuedTable) before(other *queuedTable) bool {
+	if t.priority != other.priority {
+		return t.priority > other.priority
+	}
+	return t.seq < other.seq
+}
+
+// tableQueue dispatches the tables to the table workers in the order of their
+// priorities, and pauses, resumes, cancels or reprioritizes the tables on the
+// requests of the HTTP API in the server mode. The control of every table is
+// saved into the checkpoints, so it is kept across restarts.
+type tableQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// enabled is whether the tables can be controlled. Otherwise the controls
+	// loaded from the checkpoints are reset, since there is no way to resume the
+	// paused or cancelled tables.
+	enabled bool
+	// opened is true while the tables are being restored.
+	opened  bool
+	done    chan struct{}
+	tables  map[string]*queuedTable
+	pending []*queuedTable
+	saveCp  func(tableName string, merger checkpoints.TableCheckpointMerger)
+}
+
+func newTableQueue() *tableQueue {
+	q := &tableQueue{tables: make(map[string]*queuedTable)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *tableQueue) enable() {
+	q.mu.Lock()
+	q.enabled = true
+	q.mu.Unlock()
+}
+
+// open starts dispatching the tables in `trs`, the tables are unblocked once
+// `ctx` is done.
+func (q *tableQueue) open(
+	ctx context.Context,
+	trs []*TableRestore,
+	cps []*checkpoints.TableCheckpoint,
+	saveCp func(tableName string, merger checkpoints.TableCheckpointMerger),
+) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.opened = true
+	q.done = make(chan struct{})
+	q.saveCp = saveCp
+	q.tables = make(map[string]*queuedTable, len(trs))
+	q.pending = make([]*queuedTable, 0, len(trs))
+	for i, tr := range trs {
+		t := &queuedTable{
+			tr:       tr,
+			cp:       cps[i],
+			seq:      i,
+			state:    queuedTablePending,
+			control:  cps[i].Control,
+			priority: cps[i].Priority,
+		}
+		if !q.enabled && t.control != checkpoints.TableControlNone {
+			tr.logger.Warn("table control is only supported in the server mode, restore the table normally",
+				zap.Stringer("control", t.control))
+			t.control = checkpoints.TableControlNone
+			q.save(t)
+		}
+		q.tables[tr.tableName] = t
+		q.pending = append(q.pending, t)
+	}
+
+	done := q.done
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.cond.Broadcast()
+			q.mu.Unlock()
+		case <-done:
+		}
+	}()
+}
+
+// close stops accepting the controls of the tables.
+func (q *tableQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.opened {
+		q.opened = false
+		close(q.done)
+	}
+}
+
+// next returns the pending table of the highest priority along with the
+// context to restore it, which is cancelled if the table is cancelled. It
+// waits while all the pending tables are paused, and returns false once there
+// is no pending table or `ctx` is done.
+func (q *tableQueue) next(ctx context.Context) (*queuedTable, context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if ctx.Err() != nil {
+			return nil, nil, false
+		}
+
+		var best *queuedTable
+		pending := q.pending[:0]
+		for _, t := range q.pending {
+			switch t.control {
+			case checkpoints.TableControlCancelled:
+				t.tr.logger.Info("skip the cancelled table")
+				t.state = queuedTableFinished
+				continue
+			case checkpoints.TableControlNone:
+				if best == nil || t.before(best) {
+					best = t
+				}
+			}
+			pending = append(pending, t)
+		}
+		q.pending = pending
+
+		if best != nil {
+			for i, t := range q.pending {
+				if t == best {
+					q.pending = append(q.pending[:i], q.pending[i+1:]...)
+					break
+				}
+			}
+			var tableCtx context.Context
+			tableCtx, best.cancel = context.WithCancel(ctx)
+			best.state = queuedTableRunning
+			return best, tableCtx, true
+		}
+		if len(q.pending) == 0 {
+			return nil, nil, false
+		}
+		q.cond.Wait()
+	}
+}
+
+// finish marks the table as finished, and returns whether it is cancelled.
+func (q *tableQueue) finish(t *queuedTable) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t.state = queuedTableFinished
+	t.cancel()
+	return t.control == checkpoints.TableControlCancelled
+}
+
+// hasCancelled returns whether any table is cancelled, whose checkpoints should
+// be kept to restore it later.
+func (q *tableQueue) hasCancelled() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, t := range q.tables {
+		if t.control == checkpoints.TableControlCancelled {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *tableQueue) lookup(tableName string) (*queuedTable, error) {
+	if !q.enabled {
+		return nil, errors.New("table control is only supported in the server mode")
+	}
+	if !q.opened {
+		return nil, errors.New("tables are not being restored")
+	}
+	t, ok := q.tables[tableName]
+	if !ok {
+		return nil, errors.NotFoundf("table %s", tableName)
+	}
+	return t, nil
+}
+
+func (q *tableQueue) save(t *queuedTable) {
+	q.saveCp(t.tr.tableName, &checkpoints.TableControlMerger{Control: t.control, Priority: t.priority})
+}
+
+func (q *tableQueue) pause(tableName string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, err := q.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case t.state == queuedTableFinished:
+		return errors.Errorf("table %s is already finished", tableName)
+	case t.control == checkpoints.TableControlCancelled:
+		return errors.Errorf("table %s is cancelled", tableName)
+	}
+
+	t.control = checkpoints.TableControlPaused
+	if t.state == queuedTableRunning {
+		t.tr.pauser.Pause()
+	}
+	q.save(t)
+	t.tr.logger.Info("table paused")
+	return nil
+}
+
+func (q *tableQueue) resume(tableName string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, err := q.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	if t.state == queuedTableFinished && t.control != checkpoints.TableControlCancelled {
+		return errors.Errorf("table %s is already finished", tableName)
+	}
+
+	t.control = checkpoints.TableControlNone
+	switch t.state {
+	case queuedTablePending:
+		q.cond.Broadcast()
+	case queuedTableRunning:
+		t.tr.pauser.Resume()
+	case queuedTableFinished:
+		t.tr.logger.Info("the cancelled table will be restored in the next run")
+	}
+	q.save(t)
+	t.tr.logger.Info("table resumed")
+	return nil
+}
+
+func (q *tableQueue) cancel(tableName string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, err := q.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	if t.state == queuedTableFinished {
+		return errors.Errorf("table %s is already finished", tableName)
+	}
+
+	t.control = checkpoints.TableControlCancelled
+	switch t.state {
+	case queuedTablePending:
+		// wake up the workers waiting for the paused tables.
+		q.cond.Broadcast()
+	case queuedTableRunning:
+		t.cancel()
+	}
+	q.save(t)
+	t.tr.logger.Info("table cancelled")
+	return nil
+}
+
+func (q *tableQueue) setPriority(tableName string, priority int32) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	t, err := q.lookup(tableName)
+	if err != nil {
+		return err
+	}
+	if t.state == queuedTableFinished {
+		return errors.Errorf("table %s is already finished", tableName)
+	}
+
+	t.priority = priority
+	q.save(t)
+	t.tr.logger.Info("table reprioritized", zap.Int32("priority", priority))
+	return nil
+}
+
+// EnableTableControl allows the tables to be paused, resumed, cancelled and
+// reprioritized while they are being restored, which is only supported in the
+// server mode.
+func (rc *Controller) EnableTableControl() {
+	rc.tables.enable()
+}
+
+// PauseTable stops dispatching the table if it is pending, or stops encoding
+// it if it is being restored, until it is resumed.
+func (rc *Controller) PauseTable(tableName string) error {
+	return rc.tables.pause(tableName)
+}
+
+// ResumeTable resumes the paused or cancelled table. A table cancelled while
+// being restored is restored in the next run.
+func (rc *Controller) ResumeTable(tableName string) error {
+	return rc.tables.resume(tableName)
+}
+
+// CancelTable stops restoring the table, which is skipped in this and later
+// runs until it is resumed. The checkpoints are kept after the task finishes
+// if any table is cancelled.
+func (rc *Controller) CancelTable(tableName string) error {
+	return rc.tables.cancel(tableName)
+}
+
+// SetTablePriority changes the priority of the table, the pending tables of
+// higher priorities are restored first.
+func (rc *Controller) SetTablePriority(tableName string, priority int32) error {
+	return rc.tables.setPriority(tableName, priority)
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restore
+
+import (
+	"context"
+	"time"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/lightning/checkpoints"
+	"github.com/pingcap/br/pkg/lightning/common"
+	"github.com/pingcap/br/pkg/lightning/log"
+)
+
+var _ = Suite(&tableQueueSuite{})
+
+type tableQueueSuite struct {
+	queue *tableQueue
+	saved map[string]checkpoints.TableControlMerger
+}
+
+func (s *tableQueueSuite) SetUpTest(c *C) {
+	s.queue = newTableQueue()
+	s.saved = make(map[string]checkpoints.TableControlMerger)
+}
+
+func (s *tableQueueSuite) open(ctx context.Context, cps ...*checkpoints.TableCheckpoint) {
+	trs := make([]*TableRestore, 0, len(cps))
+	for i := range cps {
+		tableName := common.UniqueTable("db", string(rune('a'+i)))
+		trs = append(trs, &TableRestore{tableName: tableName, logger: log.L(), pauser: common.NewPauser()})
+	}
+	s.queue.open(ctx, trs, cps, func(tableName string, merger checkpoints.TableCheckpointMerger) {
+		s.saved[tableName] = *merger.(*checkpoints.TableControlMerger)
+	})
+}
+
+func (s *tableQueueSuite) next(c *C, ctx context.Context) string {
+	t, _, ok := s.queue.next(ctx)
+	c.Assert(ok, IsTrue)
+	return t.tr.tableName
+}
+
+func (s *tableQueueSuite) TestPriority(c *C) {
+	ctx := context.Background()
+	s.queue.enable()
+	s.open(ctx,
+		&checkpoints.TableCheckpoint{},
+		&checkpoints.TableCheckpoint{Priority: 1},
+		&checkpoints.TableCheckpoint{},
+		&checkpoints.TableCheckpoint{},
+	)
+	defer s.queue.close()
+
+	c.Assert(s.next(c, ctx), Equals, "`db`.`b`")
+	c.Assert(s.queue.setPriority("`db`.`d`", 2), IsNil)
+	c.Assert(s.saved["`db`.`d`"], Equals, checkpoints.TableControlMerger{Priority: 2})
+	c.Assert(s.next(c, ctx), Equals, "`db`.`d`")
+	c.Assert(s.next(c, ctx), Equals, "`db`.`a`")
+	c.Assert(s.next(c, ctx), Equals, "`db`.`c`")
+	_, _, ok := s.queue.next(ctx)
+	c.Assert(ok, IsFalse)
+
+	err := s.queue.setPriority("`db`.`x`", 1)
+	c.Assert(errors.IsNotFound(err), IsTrue)
+}
+
+func (s *tableQueueSuite) TestPauseResume(c *C) {
+	ctx := context.Background()
+	s.queue.enable()
+	// the table paused in the previous run is not dispatched.
+	s.open(ctx,
+		&checkpoints.TableCheckpoint{Control: checkpoints.TableControlPaused},
+		&checkpoints.TableCheckpoint{},
+	)
+	defer s.queue.close()
+
+	running, _, ok := s.queue.next(ctx)
+	c.Assert(ok, IsTrue)
+	c.Assert(running.tr.tableName, Equals, "`db`.`b`")
+	c.Assert(s.queue.pause("`db`.`b`"), IsNil)
+	c.Assert(running.tr.pauser.IsPaused(), IsTrue)
+	c.Assert(s.saved["`db`.`b`"], Equals, checkpoints.TableControlMerger{Control: checkpoints.TableControlPaused})
+	c.Assert(s.queue.resume("`db`.`b`"), IsNil)
+	c.Assert(running.tr.pauser.IsPaused(), IsFalse)
+	c.Assert(s.queue.finish(running), IsFalse)
+
+	// the worker waits until the paused table is resumed.
+	nextCh := make(chan string)
+	go func() {
+		nextCh <- s.next(c, ctx)
+	}()
+	select {
+	case <-nextCh:
+		c.Fatal("the paused table is dispatched")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.Assert(s.queue.resume("`db`.`a`"), IsNil)
+	c.Assert(<-nextCh, Equals, "`db`.`a`")
+	c.Assert(s.saved["`db`.`a`"], Equals, checkpoints.TableControlMerger{})
+
+	c.Assert(s.queue.pause("`db`.`b`"), ErrorMatches, ".*already finished")
+}
+
+func (s *tableQueueSuite) TestCancel(c *C) {
+	ctx := context.Background()
+	s.queue.enable()
+	s.open(ctx,
+		&checkpoints.TableCheckpoint{},
+		&checkpoints.TableCheckpoint{Control: checkpoints.TableControlCancelled},
+		&checkpoints.TableCheckpoint{},
+	)
+	defer s.queue.close()
+
+	running, tableCtx, ok := s.queue.next(ctx)
+	c.Assert(ok, IsTrue)
+	c.Assert(running.tr.tableName, Equals, "`db`.`a`")
+	c.Assert(s.queue.cancel("`db`.`a`"), IsNil)
+	c.Assert(tableCtx.Err(), Equals, context.Canceled)
+	c.Assert(s.queue.finish(running), IsTrue)
+	c.Assert(s.saved["`db`.`a`"], Equals, checkpoints.TableControlMerger{Control: checkpoints.TableControlCancelled})
+
+	// the table cancelled in the previous run is skipped.
+	c.Assert(s.next(c, ctx), Equals, "`db`.`c`")
+	_, _, ok = s.queue.next(ctx)
+	c.Assert(ok, IsFalse)
+	c.Assert(s.queue.hasCancelled(), IsTrue)
+
+	// the cancelled table can be resumed for the next run.
+	c.Assert(s.queue.resume("`db`.`a`"), IsNil)
+	c.Assert(s.queue.resume("`db`.`b`"), IsNil)
+	c.Assert(s.queue.hasCancelled(), IsFalse)
+}
+
+func (s *tableQueueSuite) TestCancelContext(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.queue.enable()
+	s.open(ctx, &checkpoints.TableCheckpoint{Control: checkpoints.TableControlPaused})
+	defer s.queue.close()
+
+	// the worker waiting for the paused table stops once the context is done.
+	okCh := make(chan bool)
+	go func() {
+		_, _, ok := s.queue.next(ctx)
+		okCh <- ok
+	}()
+	cancel()
+	c.Assert(<-okCh, IsFalse)
+}
+
+func (s *tableQueueSuite) TestDisabled(c *C) {
+	ctx := context.Background()
+	// the controls are reset without the server mode.
+	s.open(ctx,
+		&checkpoints.TableCheckpoint{Control: checkpoints.TableControlPaused, Priority: 1},
+		&checkpoints.TableCheckpoint{Control: checkpoints.TableControlCancelled},
+	)
+	c.Assert(s.saved, DeepEquals, map[string]checkpoints.TableControlMerger{
+		"`db`.`a`": {Priority: 1},
+		"`db`.`b`": {},
+	})
+	c.Assert(s.next(c, ctx), Equals, "`db`.`a`")
+	c.Assert(s.next(c, ctx), Equals, "`db`.`b`")
+	c.Assert(s.queue.pause("`db`.`a`"), ErrorMatches, ".*only supported in the server mode")
+
+	s.queue.enable()
+	s.queue.close()
+	c.Assert(s.queue.pause("`db`.`a`"), ErrorMatches, "tables are not being restored")
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/pingcap/tidb/meta/autoid"
 	"github.com/pingcap/tidb/table"
 	"github.com/pingcap/tidb/table/tables"
+	"go.uber.org/atomic"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
@@ -53,6 +54,13 @@ type TableRestore struct {
 	logger    log.Logger
 
 	ignoreColumns []string
+	transform     *config.TransformRule
+	// filteredRows is the number of the rows not satisfying the `where`
+	// condition of the transform rule.
+	filteredRows atomic.Int64
+	// pauser pauses encoding this table only, while the pauser of the
+	// controller pauses all tables.
+	pauser *common.Pauser
 }
 
 func NewTableRestore(
@@ -62,6 +70,7 @@ func NewTableRestore(
 	tableInfo *checkpoints.TidbTableInfo,
 	cp *checkpoints.TableCheckpoint,
 	ignoreColumns []string,
+	transform *config.TransformRule,
 ) (*TableRestore, error) {
 	idAlloc := kv.NewPanickingAllocators(cp.AllocBase)
 	tbl, err := tables.TableFromMeta(idAlloc, tableInfo.Core)
@@ -69,6 +78,21 @@ func NewTableRestore(
 		return nil, errors.Annotatef(err, "failed to tables.TableFromMeta %s", tableName)
 	}
 
+	if transform != nil {
+		// the source columns only referred by the transform expressions are
+		// not imported directly.
+		vars, err := kv.TransformVariables(transform.Columns, transform.Where)
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid transform rule of %s", tableName)
+		}
+		ignoreColumns = append([]string(nil), ignoreColumns...)
+		for _, name := range vars {
+			if model.FindColumnInfo(tableInfo.Core.Columns, name) == nil {
+				ignoreColumns = append(ignoreColumns, name)
+			}
+		}
+	}
+
 	return &TableRestore{
 		tableName:     tableName,
 		dbInfo:        dbInfo,
@@ -78,6 +102,8 @@ func NewTableRestore(
 		alloc:         idAlloc,
 		logger:        log.With(zap.String("table", tableName)),
 		ignoreColumns: ignoreColumns,
+		transform:     transform,
+		pauser:        common.NewPauser(),
 	}, nil
 }
 
@@ -475,7 +501,7 @@ func (tr *TableRestore) restoreEngine(
 		var remainChunkCnt float64
 		if chunk.Chunk.Offset < chunk.Chunk.EndOffset {
 			remainChunkCnt = float64(chunk.Chunk.EndOffset-chunk.Chunk.Offset) / float64(chunk.Chunk.EndOffset-chunk.Key.Offset)
-			metric.ChunkCounter.WithLabelValues(metric.ChunkStatePending).Add(remainChunkCnt)
+			rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStatePending).Add(remainChunkCnt)
 		}
 
 		restoreWorker := rc.regionWorkers.Apply()
@@ -498,7 +524,7 @@ func (tr *TableRestore) restoreEngine(
 				wg.Done()
 				rc.regionWorkers.Recycle(w)
 			}()
-			metric.ChunkCounter.WithLabelValues(metric.ChunkStateRunning).Add(remainChunkCnt)
+			rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateRunning).Add(remainChunkCnt)
 			err := cr.restore(ctx, tr, engineID, dataWriter, indexWriter, rc)
 			var dataFlushStatus, indexFlushStaus backend.ChunkFlushStatus
 			if err == nil {
@@ -508,8 +534,8 @@ func (tr *TableRestore) restoreEngine(
 				indexFlushStaus, err = indexWriter.Close(ctx)
 			}
 			if err == nil {
-				metric.ChunkCounter.WithLabelValues(metric.ChunkStateFinished).Add(remainChunkCnt)
-				metric.BytesCounter.WithLabelValues(metric.TableStateWritten).Add(float64(cr.chunk.Checksum.SumSize()))
+				rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateFinished).Add(remainChunkCnt)
+				rc.metrics.BytesCounter.WithLabelValues(metric.TableStateWritten).Add(float64(cr.chunk.Checksum.SumSize()))
 				if dataFlushStatus != nil && indexFlushStaus != nil {
 					if dataFlushStatus.Flushed() && indexFlushStaus.Flushed() {
 						saveCheckpoint(rc, tr, engineID, cr.chunk)
@@ -524,7 +550,7 @@ func (tr *TableRestore) restoreEngine(
 					}
 				}
 			} else {
-				metric.ChunkCounter.WithLabelValues(metric.ChunkStateFailed).Add(remainChunkCnt)
+				rc.metrics.ChunkCounter.WithLabelValues(metric.ChunkStateFailed).Add(remainChunkCnt)
 				chunkErr.Set(err)
 				cancel()
 			}
@@ -632,6 +658,51 @@ func (tr *TableRestore) importEngine(
 	return nil
 }
 
+// resolveDuplicateRows merges the imported rows with the existing rows and
+// resolves the duplicate rows according to the task config, and returns the
+// checksum of the rows removed from the target table.
+func (tr *TableRestore) resolveDuplicateRows(
+	ctx context.Context,
+	rc *Controller,
+	cp *checkpoints.TableCheckpoint,
+) (verify.KVChecksum, error) {
+	var removedChecksum verify.KVChecksum
+	options := &kv.SessionOptions{
+		SQLMode: rc.cfg.TiDB.SQLMode,
+		SysVars: rc.sysVars,
+	}
+	if rc.cfg.TikvImporter.Upsert {
+		replacedChecksum, err := rc.backend.MergeExistingRows(ctx, tr.encTable, tr.tableName, options)
+		if err != nil {
+			return removedChecksum, err
+		}
+		removedChecksum.Add(&replacedChecksum)
+	}
+	if rc.cfg.TikvImporter.DuplicateDetection {
+		if err := rc.backend.CollectLocalDuplicateRows(ctx, tr.encTable, tr.tableName); err != nil {
+			tr.logger.Error("collect local duplicate keys failed", log.ShortError(err))
+		}
+		if rc.cfg.TikvImporter.DuplicateResolution != config.DupeResAlgNone {
+			resolvedChecksum, err := rc.backend.ResolveDuplicateRows(ctx, tr.encTable, tr.tableName, options, newRowSourceLocator(cp))
+			if err != nil {
+				return removedChecksum, err
+			}
+			removedChecksum.Add(&resolvedChecksum)
+			tr.logger.Info("resolved duplicate rows", zap.Object("removedChecksum", &resolvedChecksum))
+		}
+	}
+	return removedChecksum, nil
+}
+
+// finishMergeExistingRows removes the states of merging the existing rows
+// after the checksum.
+func (tr *TableRestore) finishMergeExistingRows(ctx context.Context, rc *Controller) error {
+	if !rc.cfg.TikvImporter.Upsert {
+		return nil
+	}
+	return errors.Trace(rc.backend.FinishMergeExistingRows(ctx, tr.encTable))
+}
+
 // postProcess execute rebase-auto-id/checksum/analyze according to the task config.
 //
 // if the parameter forcePostProcess to true, postProcess force run checksum and analyze even if the
@@ -676,39 +747,60 @@ func (tr *TableRestore) postProcess(
 		return false, nil
 	}
 
+	// 4. merge the imported rows with the existing rows and resolve the duplicate
+	// rows. The rows in the target table are changed, so it must be done even if
+	// the checksum is skipped.
+	if cp.Status < checkpoints.CheckpointStatusDupeResolved {
+		removedChecksum, err := tr.resolveDuplicateRows(ctx, rc, cp)
+		if err == nil {
+			rc.saveCpCh <- saveCp{
+				tableName: tr.tableName,
+				merger:    &checkpoints.RemovedChecksumMerger{Checksum: removedChecksum},
+			}
+		}
+		rc.saveStatusCheckpoint(tr.tableName, checkpoints.WholeTableEngineID, err, checkpoints.CheckpointStatusDupeResolved)
+		if err != nil {
+			return false, err
+		}
+		cp.RemovedChecksum = removedChecksum
+		cp.Status = checkpoints.CheckpointStatusDupeResolved
+	}
+
 	w := rc.checksumWorks.Apply()
 	defer rc.checksumWorks.Recycle(w)
 
 	finished := true
 	if cp.Status < checkpoints.CheckpointStatusChecksummed {
-		// 4. do table checksum
+		// 5. do table checksum
 		var localChecksum verify.KVChecksum
 		for _, engine := range cp.Engines {
 			for _, chunk := range engine.Chunks {
 				localChecksum.Add(&chunk.Checksum)
 			}
 		}
+		// the removed rows are not in the target table anymore. Some of them are
+		// existing rows, which are counted in the base checksum added below.
+		localChecksum.Sub(&cp.RemovedChecksum)
 
 		if rc.cfg.PostRestore.Checksum == config.OpLevelOff {
 			tr.logger.Info("skip checksum")
 			rc.saveStatusCheckpoint(tr.tableName, checkpoints.WholeTableEngineID, nil, checkpoints.CheckpointStatusChecksumSkipped)
+			if err := tr.finishMergeExistingRows(ctx, rc); err != nil {
+				return false, err
+			}
 		} else {
 			if forcePostProcess || !rc.cfg.PostRestore.PostProcessAtLast {
 				tr.logger.Info("local checksum", zap.Object("checksum", &localChecksum))
-				if rc.cfg.TikvImporter.DuplicateDetection {
-					if err := rc.backend.CollectLocalDuplicateRows(ctx, tr.encTable); err != nil {
-						tr.logger.Error("collect local duplicate keys failed", log.ShortError(err))
-					}
-				}
 				needChecksum, baseTotalChecksum, err := metaMgr.CheckAndUpdateLocalChecksum(ctx, &localChecksum)
 				if err != nil {
 					return false, err
 				}
 				if !needChecksum {
-					return false, nil
+					// the checksum is done by another lightning.
+					return false, tr.finishMergeExistingRows(ctx, rc)
 				}
 				if rc.cfg.TikvImporter.DuplicateDetection {
-					if err := rc.backend.CollectRemoteDuplicateRows(ctx, tr.encTable); err != nil {
+					if err := rc.backend.CollectRemoteDuplicateRows(ctx, tr.encTable, tr.tableName); err != nil {
 						tr.logger.Error("collect remote duplicate keys failed", log.ShortError(err))
 						err = nil
 					}
@@ -739,6 +831,9 @@ func (tr *TableRestore) postProcess(
 				}
 
 				cp.Status = checkpoints.CheckpointStatusChecksummed
+				if err := tr.finishMergeExistingRows(ctx, rc); err != nil {
+					return false, err
+				}
 			} else {
 				finished = false
 			}
@@ -748,7 +843,7 @@ func (tr *TableRestore) postProcess(
 		return !finished, nil
 	}
 
-	// 5. do table analyze
+	// 6. do table analyze
 	if cp.Status < checkpoints.CheckpointStatusAnalyzed {
 		switch {
 		case rc.cfg.PostRestore.Analyze == config.OpLevelOff:
@@ -777,6 +872,27 @@ func (tr *TableRestore) postProcess(
 	return !finished, nil
 }
 
+// newRowSourceLocator returns a locator of the data files by the row IDs. The
+// chunks of the table own consecutive row ID ranges, each ends at RowIDMax.
+func newRowSourceLocator(cp *checkpoints.TableCheckpoint) backend.RowSourceLocator {
+	chunks := make([]*checkpoints.ChunkCheckpoint, 0)
+	for _, engine := range cp.Engines {
+		chunks = append(chunks, engine.Chunks...)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].Chunk.RowIDMax < chunks[j].Chunk.RowIDMax
+	})
+	return func(rowID int64) string {
+		i := sort.Search(len(chunks), func(i int) bool {
+			return chunks[i].Chunk.RowIDMax >= rowID
+		})
+		if i == len(chunks) {
+			return ""
+		}
+		return chunks[i].Key.Path
+	}
+}
+
 func parseColumnPermutations(tableInfo *model.TableInfo, columns []string, ignoreColumns []string) ([]int, error) {
 	colPerm := make([]int, 0, len(tableInfo.Columns)+1)
 
@@ -238,6 +238,7 @@ func LoadSchemaInfo(
 	ctx context.Context,
 	schemas []*mydump.MDDatabaseMeta,
 	getTables func(context.Context, string) ([]*model.TableInfo, error),
+	metrics *metric.Metrics,
 ) (map[string]*checkpoints.TidbDBInfo, error) {
 	result := make(map[string]*checkpoints.TidbDBInfo, len(schemas))
 	for _, schema := range schemas {
@@ -264,10 +265,10 @@ func LoadSchemaInfo(
 			tableName := tblInfo.Name.String()
 			if tblInfo.State != model.StatePublic {
 				err := errors.Errorf("table [%s.%s] state is not public", schema.Name, tableName)
-				metric.RecordTableCount(metric.TableStatePending, err)
+				metrics.RecordTableCount(metric.TableStatePending, err)
 				return nil, err
 			}
-			metric.RecordTableCount(metric.TableStatePending, err)
+			metrics.RecordTableCount(metric.TableStatePending, err)
 			if err != nil {
 				return nil, errors.Trace(err)
 			}
@@ -284,7 +284,8 @@ func (s *tidbSuite) TestDropTable(c *C) {
 func (s *tidbSuite) TestLoadSchemaInfo(c *C) {
 	ctx := context.Background()
 
-	tableCntBefore := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
+	metrics := metric.NewMetrics(0)
+	tableCntBefore := metric.ReadCounter(metrics.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
 
 	// Prepare the mock reply.
 	nodes, _, err := s.timgr.parser.Parse(
@@ -323,7 +324,7 @@ func (s *tidbSuite) TestLoadSchemaInfo(c *C) {
 	loaded, err := LoadSchemaInfo(ctx, dbMetas, func(ctx context.Context, schema string) ([]*model.TableInfo, error) {
 		c.Assert(schema, Equals, "db")
 		return tableInfos, nil
-	})
+	}, metrics)
 	c.Assert(err, IsNil)
 	c.Assert(loaded, DeepEquals, map[string]*checkpoints.TidbDBInfo{
 		"db": {
@@ -345,7 +346,7 @@ func (s *tidbSuite) TestLoadSchemaInfo(c *C) {
 		},
 	})
 
-	tableCntAfter := metric.ReadCounter(metric.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
+	tableCntAfter := metric.ReadCounter(metrics.TableCounter.WithLabelValues(metric.TableStatePending, metric.TableResultSuccess))
 
 	c.Assert(tableCntAfter-tableCntBefore, Equals, 2.0)
 }
@@ -355,7 +356,7 @@ func (s *tidbSuite) TestLoadSchemaInfoMissing(c *C) {
 
 	_, err := LoadSchemaInfo(ctx, []*mydump.MDDatabaseMeta{{Name: "asdjalsjdlas"}}, func(ctx context.Context, schema string) ([]*model.TableInfo, error) {
 		return nil, errors.Errorf("[schema:1049]Unknown database '%s'", schema)
-	})
+	}, metric.NewMetrics(0))
 	c.Assert(err, ErrorMatches, ".*Unknown database.*")
 }
 
@@ -80,6 +80,14 @@ func (c *KVChecksum) Add(other *KVChecksum) {
 	c.checksum ^= other.checksum
 }
 
+// Sub removes the KV pairs summarized by `other`, which must be a subset of the
+// KV pairs summarized by this checksum.
+func (c *KVChecksum) Sub(other *KVChecksum) {
+	c.bytes -= other.bytes
+	c.kvs -= other.kvs
+	c.checksum ^= other.checksum
+}
+
 func (c *KVChecksum) Sum() uint64 {
 	return c.checksum
 }
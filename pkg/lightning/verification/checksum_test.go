@@ -78,6 +78,26 @@ func (s *testKVChcksumSuite) TestChcksum(c *C) {
 	c.Assert(uint64NotEqual(checksum.Sum(), excpectChecksum), IsTrue)
 }
 
+func (s *testKVChcksumSuite) TestChecksumSub(c *C) {
+	kvs := []common.KvPair{
+		{Key: []byte("a"), Val: []byte("1")},
+		{Key: []byte("bb"), Val: []byte("22")},
+		{Key: []byte("ccc"), Val: []byte("333")},
+	}
+	expected := verification.NewKVChecksum(0)
+	expected.Update(kvs[:1])
+
+	checksum := verification.NewKVChecksum(0)
+	checksum.Update(kvs)
+	removed := verification.NewKVChecksum(0)
+	removed.Update(kvs[1:])
+	checksum.Sub(removed)
+	c.Assert(*checksum, Equals, *expected)
+
+	checksum.Sub(expected)
+	c.Assert(*checksum, Equals, verification.MakeKVChecksum(0, 0, 0))
+}
+
 func (s *testKVChcksumSuite) TestChecksumJSON(c *C) {
 	testStruct := &struct {
 		Checksum verification.KVChecksum
@@ -57,7 +57,10 @@ func (cpm *checkpointsMap) update(diffs map[string]*checkpoints.TableCheckpointD
 	defer cpm.mu.Unlock()
 
 	for key, diff := range diffs {
-		cp := cpm.checkpoints[key]
+		cp, ok := cpm.checkpoints[key]
+		if !ok {
+			continue
+		}
 		cp.Apply(diff)
 
 		tw := int64(0)
@@ -109,28 +112,106 @@ type taskProgress struct {
 	checkpoints checkpointsMap
 }
 
-var currentProgress = taskProgress{
-	checkpoints: makeCheckpointsMap(),
+// taskProgresses is the progress of the tasks (task ID → progress), the tasks
+// may run concurrently in the server mode. The progress of a completed task is
+// kept until another task starts.
+type taskProgresses struct {
+	mu    sync.RWMutex
+	tasks map[int64]*taskProgress
+	// latest is the ID of the task started most recently, whose progress is
+	// reported if the task is not specified.
+	latest int64
+}
+
+var currentProgress = taskProgresses{
+	tasks: make(map[int64]*taskProgress),
+}
+
+func newTaskProgress() *taskProgress {
+	return &taskProgress{checkpoints: makeCheckpointsMap()}
+}
+
+// get returns the progress of the task, which is created if not existing.
+func (tps *taskProgresses) get(taskID int64) *taskProgress {
+	tps.mu.RLock()
+	tp, ok := tps.tasks[taskID]
+	tps.mu.RUnlock()
+	if ok {
+		return tp
+	}
+
+	tps.mu.Lock()
+	defer tps.mu.Unlock()
+	if tp, ok = tps.tasks[taskID]; !ok {
+		tp = newTaskProgress()
+		tps.tasks[taskID] = tp
+	}
+	return tp
 }
 
-func BroadcastStartTask() {
+// find returns the progress of the task, or of the latest task if `taskID` is
+// zero.
+func (tps *taskProgresses) find(taskID int64) (*taskProgress, error) {
+	tps.mu.RLock()
+	defer tps.mu.RUnlock()
+
+	if taskID == 0 {
+		taskID = tps.latest
+	}
+	if tp, ok := tps.tasks[taskID]; ok {
+		return tp, nil
+	}
+	if taskID == 0 {
+		// no task is started yet.
+		return newTaskProgress(), nil
+	}
+	return nil, errors.NotFoundf("task %d", taskID)
+}
+
+func BroadcastStartTask(taskID int64) {
+	tp := newTaskProgress()
+	tp.Status = taskStatusRunning
+
 	currentProgress.mu.Lock()
-	currentProgress.Status = taskStatusRunning
+	for id, other := range currentProgress.tasks {
+		other.mu.RLock()
+		running := other.Status == taskStatusRunning
+		other.mu.RUnlock()
+		if !running {
+			delete(currentProgress.tasks, id)
+		}
+	}
+	currentProgress.tasks[taskID] = tp
+	currentProgress.latest = taskID
 	currentProgress.mu.Unlock()
+}
+
+// BroadcastTaskID records that the task reuses the ID in the checkpoints, so
+// its progress is reported under the new ID.
+func BroadcastTaskID(oldTaskID, newTaskID int64) {
+	currentProgress.mu.Lock()
+	defer currentProgress.mu.Unlock()
 
-	currentProgress.checkpoints.clear()
+	if tp, ok := currentProgress.tasks[oldTaskID]; ok {
+		delete(currentProgress.tasks, oldTaskID)
+		currentProgress.tasks[newTaskID] = tp
+	}
+	if currentProgress.latest == oldTaskID {
+		currentProgress.latest = newTaskID
+	}
 }
 
-func BroadcastEndTask(err error) {
+func BroadcastEndTask(taskID int64, err error) {
 	errString := errors.ErrorStack(err)
 
-	currentProgress.mu.Lock()
-	currentProgress.Status = taskStatusCompleted
-	currentProgress.Message = errString
-	currentProgress.mu.Unlock()
+	tp := currentProgress.get(taskID)
+	tp.mu.Lock()
+	tp.Status = taskStatusCompleted
+	tp.Message = errString
+	tp.mu.Unlock()
 }
 
-func BroadcastInitProgress(databases []*mydump.MDDatabaseMeta) {
+func BroadcastInitProgress(taskID int64, databases []*mydump.MDDatabaseMeta) {
 	tables := make(map[string]*tableInfo, len(databases))
 
 	for _, db := range databases {
@@ -140,47 +221,69 @@ func BroadcastInitProgress(databases []*mydump.MDDatabaseMeta) {
 		}
 	}
 
-	currentProgress.mu.Lock()
-	currentProgress.Tables = tables
-	currentProgress.mu.Unlock()
+	tp := currentProgress.get(taskID)
+	tp.mu.Lock()
+	tp.Tables = tables
+	tp.mu.Unlock()
 }
 
-func BroadcastTableCheckpoint(tableName string, cp *checkpoints.TableCheckpoint) {
-	currentProgress.mu.Lock()
-	currentProgress.Tables[tableName].Status = taskStatusRunning
-	currentProgress.mu.Unlock()
+func BroadcastTableCheckpoint(taskID int64, tableName string, cp *checkpoints.TableCheckpoint) {
+	tp := currentProgress.get(taskID)
+	tp.mu.Lock()
+	tp.Tables[tableName].Status = taskStatusRunning
+	tp.mu.Unlock()
 
 	// create a deep copy to avoid false sharing
-	currentProgress.checkpoints.insert(tableName, cp.DeepCopy())
+	tp.checkpoints.insert(tableName, cp.DeepCopy())
 }
 
-func BroadcastCheckpointDiff(diffs map[string]*checkpoints.TableCheckpointDiff) {
-	totalWrittens := currentProgress.checkpoints.update(diffs)
+// BroadcastTableQueued records the checkpoint of a table waiting to be
+// restored, so its control and priority can be queried before it starts.
+func BroadcastTableQueued(taskID int64, tableName string, cp *checkpoints.TableCheckpoint) {
+	currentProgress.get(taskID).checkpoints.insert(tableName, cp.DeepCopy())
+}
 
-	currentProgress.mu.Lock()
+func BroadcastCheckpointDiff(taskID int64, diffs map[string]*checkpoints.TableCheckpointDiff) {
+	tp := currentProgress.get(taskID)
+	totalWrittens := tp.checkpoints.update(diffs)
+
+	tp.mu.Lock()
 	for _, tw := range totalWrittens {
-		currentProgress.Tables[tw.key].TotalWritten = tw.totalWritten
+		tp.Tables[tw.key].TotalWritten = tw.totalWritten
 	}
-	currentProgress.mu.Unlock()
+	tp.mu.Unlock()
 }
 
-func BroadcastError(tableName string, err error) {
+func BroadcastError(taskID int64, tableName string, err error) {
 	errString := errors.ErrorStack(err)
 
-	currentProgress.mu.Lock()
-	if tbl := currentProgress.Tables[tableName]; tbl != nil {
+	tp := currentProgress.get(taskID)
+	tp.mu.Lock()
+	if tbl := tp.Tables[tableName]; tbl != nil {
 		tbl.Status = taskStatusCompleted
 		tbl.Message = errString
 	}
-	currentProgress.mu.Unlock()
+	tp.mu.Unlock()
 }
 
-func MarshalTaskProgress() ([]byte, error) {
-	currentProgress.mu.RLock()
-	defer currentProgress.mu.RUnlock()
-	return json.Marshal(&currentProgress)
+// MarshalTaskProgress encodes the progress of the task, or of the latest task
+// if `taskID` is zero.
+func MarshalTaskProgress(taskID int64) ([]byte, error) {
+	tp, err := currentProgress.find(taskID)
+	if err != nil {
+		return nil, err
+	}
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+	return json.Marshal(tp)
 }
 
-func MarshalTableCheckpoints(tableName string) ([]byte, error) {
-	return currentProgress.checkpoints.marshal(tableName)
+// MarshalTableCheckpoints encodes the checkpoint of the table in the task, or
+// in the latest task if `taskID` is zero.
+func MarshalTableCheckpoints(taskID int64, tableName string) ([]byte, error) {
+	tp, err := currentProgress.find(taskID)
+	if err != nil {
+		return nil, err
+	}
+	return tp.checkpoints.marshal(tableName)
 }
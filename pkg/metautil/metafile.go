@@ -20,10 +20,12 @@ import (
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/tidb/statistics/handle"
 	"github.com/pingcap/tidb/tablecodec"
+	"github.com/tikv/pd/server/schedule/placement"
 	"go.uber.org/zap"
 
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/pdutil"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/summary"
 )
@@ -35,6 +37,10 @@ const (
 	MetaFile = "backupmeta"
 	// MetaJSONFile represents backup meta json file name
 	MetaJSONFile = "backupmeta.json"
+	// StatsFile represents the index file of the table statistics.
+	StatsFile = "backupmeta.stats"
+	// PlacementFile represents the file of the placement rules and table attributes.
+	PlacementFile = "backupmeta.placement"
 	// MaxBatchSize represents the internal channel buffer size of MetaWriter and MetaReader.
 	MaxBatchSize = 1024
 
@@ -91,6 +97,16 @@ type Table struct {
 	Files           []*backuppb.File
 	TiFlashReplicas int
 	Stats           *handle.JSONTable
+	// StatsFile is the metafile holding the statistics of the table,
+	// it is nil if the stats are embedded in the schema or not backed up.
+	StatsFile *backuppb.File
+}
+
+// PlacementMeta is the placement metadata of the backed up tables,
+// including the PD placement rules and the region label rules (table attributes).
+type PlacementMeta struct {
+	Rules      []placement.Rule   `json:"rules"`
+	LabelRules []pdutil.LabelRule `json:"label_rules"`
 }
 
 // NoChecksum checks whether the table has a calculated checksum.
@@ -206,6 +222,70 @@ func (reader *MetaReader) ReadDDLs(ctx context.Context) ([]byte, error) {
 	}
 }
 
+// ReadStatsIndex reads the index of the table statistics metafiles.
+// It returns an empty index if the backup doesn't contain any stats metafile.
+func (reader *MetaReader) ReadStatsIndex(ctx context.Context) (map[int64]*backuppb.File, error) {
+	index := make(map[int64]*backuppb.File)
+	exists, err := reader.storage.FileExists(ctx, StatsFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !exists {
+		return index, nil
+	}
+	content, err := reader.storage.ReadFile(ctx, StatsFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	statsIndex := &backuppb.MetaFile{}
+	if err = proto.Unmarshal(content, statsIndex); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, file := range statsIndex.MetaFiles {
+		index[tablecodec.DecodeTableID(file.GetStartKey())] = file
+	}
+	return index, nil
+}
+
+// ReadStats reads the statistics of one table from the stats metafile.
+func (reader *MetaReader) ReadStats(ctx context.Context, file *backuppb.File) (*handle.JSONTable, error) {
+	content, err := reader.storage.ReadFile(ctx, file.Name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checksum := sha256.Sum256(content)
+	if !bytes.Equal(file.Sha256, checksum[:]) {
+		return nil, errors.Annotatef(berrors.ErrInvalidMetaFile,
+			"checksum mismatch expect %x, got %x", file.Sha256, checksum[:])
+	}
+	stats := &handle.JSONTable{}
+	if err = json.Unmarshal(content, stats); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return stats, nil
+}
+
+// ReadPlacement reads the placement metadata of the backed up tables.
+// It returns nil if the backup doesn't contain the placement metadata.
+func (reader *MetaReader) ReadPlacement(ctx context.Context) (*PlacementMeta, error) {
+	exists, err := reader.storage.FileExists(ctx, PlacementFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	content, err := reader.storage.ReadFile(ctx, PlacementFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	meta := &PlacementMeta{}
+	if err = json.Unmarshal(content, meta); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return meta, nil
+}
+
 // ReadSchemasFiles reads the schema and datafiles from the backupmeta.
 // This function is compatible with the old backupmeta.
 func (reader *MetaReader) ReadSchemasFiles(ctx context.Context, output chan<- *Table) error {
@@ -232,6 +312,10 @@ func (reader *MetaReader) ReadSchemasFiles(ctx context.Context, output chan<- *T
 	if err != nil {
 		return errors.Trace(err)
 	}
+	statsIndex, err := reader.ReadStatsIndex(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
 
 	for {
 		// table ID -> *Table
@@ -261,6 +345,7 @@ func (reader *MetaReader) ReadSchemasFiles(ctx context.Context, output chan<- *T
 				TotalBytes:      s.TotalBytes,
 				TiFlashReplicas: int(s.TiflashReplicas),
 				Stats:           stats,
+				StatsFile:       statsIndex[tableInfo.ID],
 			}
 			if files, ok := fileMap[tableInfo.ID]; ok {
 				table.Files = append(table.Files, files...)
@@ -430,6 +515,13 @@ type MetaWriter struct {
 
 	// records the total item of in one write meta job.
 	flushedItemNum int
+
+	// statsMu protects statsIndex and statsSize, since the stats are written
+	// concurrently. They are kept out of metafileSizes, which is written by
+	// the goroutine of StartWriteMetasAsync without any lock.
+	statsMu    sync.Mutex
+	statsIndex *backuppb.MetaFile
+	statsSize  int
 }
 
 // NewMetaWriter creates MetaWriter.
@@ -636,6 +728,66 @@ func (writer *MetaWriter) flushMetasV2(ctx context.Context, op AppendOp) error {
 	return nil
 }
 
+// WriteStats writes the statistics of one table into a separate metafile,
+// so the stats never need to be held in memory all together.
+// It is safe to call WriteStats concurrently.
+func (writer *MetaWriter) WriteStats(ctx context.Context, tableID int64, stats *handle.JSONTable) error {
+	content, err := json.Marshal(stats)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	fname := fmt.Sprintf("%s.%d", StatsFile, tableID)
+	if err = writer.storage.WriteFile(ctx, fname, content); err != nil {
+		return errors.Trace(err)
+	}
+	checksum := sha256.Sum256(content)
+	file := &backuppb.File{
+		Name:     fname,
+		Sha256:   checksum[:],
+		Size_:    uint64(len(content)),
+		StartKey: tablecodec.EncodeTablePrefix(tableID),
+		EndKey:   tablecodec.EncodeTablePrefix(tableID + 1),
+	}
+
+	writer.statsMu.Lock()
+	defer writer.statsMu.Unlock()
+	if writer.statsIndex == nil {
+		writer.statsIndex = &backuppb.MetaFile{}
+	}
+	writer.statsIndex.MetaFiles = append(writer.statsIndex.MetaFiles, file)
+	writer.statsSize += len(content)
+	return nil
+}
+
+// FinishWriteStats flushes the index of the stats metafiles written by WriteStats.
+func (writer *MetaWriter) FinishWriteStats(ctx context.Context) error {
+	writer.statsMu.Lock()
+	defer writer.statsMu.Unlock()
+	if writer.statsIndex == nil {
+		return nil
+	}
+	content, err := writer.statsIndex.Marshal()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("save stats index",
+		zap.Int("tables", len(writer.statsIndex.MetaFiles)),
+		zap.Int("size", writer.statsSize))
+	return writer.storage.WriteFile(ctx, StatsFile, content)
+}
+
+// WritePlacement writes the placement metadata of the backed up tables.
+func (writer *MetaWriter) WritePlacement(ctx context.Context, meta *PlacementMeta) error {
+	content, err := json.Marshal(meta)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Info("save placement meta",
+		zap.Int("rules", len(meta.Rules)),
+		zap.Int("label rules", len(meta.LabelRules)))
+	return writer.storage.WriteFile(ctx, PlacementFile, content)
+}
+
 // ArchiveSize represents the size of ArchiveSize.
 func (writer *MetaWriter) ArchiveSize() uint64 {
 	total := uint64(0)
@@ -5,13 +5,16 @@ package metautil
 import (
 	"context"
 	"crypto/sha256"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/pingcap/check"
 	backuppb "github.com/pingcap/kvproto/pkg/backup"
+	"github.com/pingcap/tidb/statistics/handle"
 
 	mockstorage "github.com/pingcap/br/pkg/mock/storage"
+	"github.com/pingcap/br/pkg/storage"
 )
 
 type metaSuit struct{}
@@ -133,3 +136,71 @@ func (m *metaSuit) TestWalkMetaFile(c *C) {
 		c.Assert(files[i], DeepEquals, expect[i])
 	}
 }
+
+func (m *metaSuit) TestWriteReadStats(c *C) {
+	ctx := context.Background()
+	es, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+	reader := NewMetaReader(&backuppb.BackupMeta{}, es)
+
+	// backups without stats have an empty index.
+	index, err := reader.ReadStatsIndex(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(index, HasLen, 0)
+
+	writer := NewMetaWriter(es, MetaFileSize, false)
+	c.Assert(writer.FinishWriteStats(ctx), IsNil)
+	exists, err := es.FileExists(ctx, StatsFile)
+	c.Assert(err, IsNil)
+	c.Assert(exists, IsFalse)
+
+	c.Assert(writer.WriteStats(ctx, 42, &handle.JSONTable{TableName: "t1", Count: 10}), IsNil)
+	c.Assert(writer.WriteStats(ctx, 43, &handle.JSONTable{TableName: "t2", Count: 20}), IsNil)
+	c.Assert(writer.FinishWriteStats(ctx), IsNil)
+
+	index, err = reader.ReadStatsIndex(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(index, HasLen, 2)
+	stats, err := reader.ReadStats(ctx, index[42])
+	c.Assert(err, IsNil)
+	c.Assert(stats.TableName, Equals, "t1")
+	c.Assert(stats.Count, Equals, int64(10))
+	stats, err = reader.ReadStats(ctx, index[43])
+	c.Assert(err, IsNil)
+	c.Assert(stats.TableName, Equals, "t2")
+
+	// a corrupted stats file is rejected.
+	c.Assert(es.WriteFile(ctx, index[43].Name, []byte("{}")), IsNil)
+	_, err = reader.ReadStats(ctx, index[43])
+	c.Assert(err, ErrorMatches, ".*ErrInvalidMetaFile.*")
+}
+
+func (m *metaSuit) TestWriteStatsWithMetasV2(c *C) {
+	ctx := context.Background()
+	es, err := storage.NewLocalStorage(c.MkDir())
+	c.Assert(err, IsNil)
+
+	// the schemas are flushed into metafiles while the stats are written.
+	writer := NewMetaWriter(es, 1, true)
+	writer.StartWriteMetasAsync(ctx, AppendSchema)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(tableID int64) {
+			defer wg.Done()
+			for j := int64(0); j < 20; j++ {
+				c.Assert(writer.WriteStats(ctx, tableID*100+j, &handle.JSONTable{TableName: "t"}), IsNil)
+			}
+		}(int64(i))
+	}
+	for i := 0; i < 80; i++ {
+		c.Assert(writer.Send(&backuppb.Schema{Db: []byte("db"), Table: []byte("t")}, AppendSchema), IsNil)
+	}
+	wg.Wait()
+	c.Assert(writer.FinishWriteMetas(ctx, AppendSchema), IsNil)
+	c.Assert(writer.FinishWriteStats(ctx), IsNil)
+
+	index, err := NewMetaReader(&backuppb.BackupMeta{}, es).ReadStatsIndex(ctx)
+	c.Assert(err, IsNil)
+	c.Assert(index, HasLen, 80)
+}
@@ -12,6 +12,7 @@ import (
 	uuid "github.com/google/uuid"
 	backend "github.com/pingcap/br/pkg/lightning/backend"
 	kv "github.com/pingcap/br/pkg/lightning/backend/kv"
+	verification "github.com/pingcap/br/pkg/lightning/verification"
 	model "github.com/pingcap/parser/model"
 	table "github.com/pingcap/tidb/table"
 	reflect "reflect"
@@ -96,31 +97,31 @@ func (mr *MockBackendMockRecorder) CloseEngine(arg0, arg1, arg2 interface{}) *go
 }
 
 // CollectLocalDuplicateRows mocks base method
-func (m *MockBackend) CollectLocalDuplicateRows(arg0 context.Context, arg1 table.Table) error {
+func (m *MockBackend) CollectLocalDuplicateRows(arg0 context.Context, arg1 table.Table, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CollectLocalDuplicateRows", arg0, arg1)
+	ret := m.ctrl.Call(m, "CollectLocalDuplicateRows", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CollectLocalDuplicateRows indicates an expected call of CollectLocalDuplicateRows
-func (mr *MockBackendMockRecorder) CollectLocalDuplicateRows(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockBackendMockRecorder) CollectLocalDuplicateRows(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectLocalDuplicateRows", reflect.TypeOf((*MockBackend)(nil).CollectLocalDuplicateRows), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectLocalDuplicateRows", reflect.TypeOf((*MockBackend)(nil).CollectLocalDuplicateRows), arg0, arg1, arg2)
 }
 
 // CollectRemoteDuplicateRows mocks base method
-func (m *MockBackend) CollectRemoteDuplicateRows(arg0 context.Context, arg1 table.Table) error {
+func (m *MockBackend) CollectRemoteDuplicateRows(arg0 context.Context, arg1 table.Table, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CollectRemoteDuplicateRows", arg0, arg1)
+	ret := m.ctrl.Call(m, "CollectRemoteDuplicateRows", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CollectRemoteDuplicateRows indicates an expected call of CollectRemoteDuplicateRows
-func (mr *MockBackendMockRecorder) CollectRemoteDuplicateRows(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockBackendMockRecorder) CollectRemoteDuplicateRows(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectRemoteDuplicateRows", reflect.TypeOf((*MockBackend)(nil).CollectRemoteDuplicateRows), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CollectRemoteDuplicateRows", reflect.TypeOf((*MockBackend)(nil).CollectRemoteDuplicateRows), arg0, arg1, arg2)
 }
 
 // EngineFileSizes mocks base method
@@ -152,6 +153,20 @@ func (mr *MockBackendMockRecorder) FetchRemoteTableModels(arg0, arg1 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchRemoteTableModels", reflect.TypeOf((*MockBackend)(nil).FetchRemoteTableModels), arg0, arg1)
 }
 
+// FinishMergeExistingRows mocks base method
+func (m *MockBackend) FinishMergeExistingRows(arg0 context.Context, arg1 table.Table) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishMergeExistingRows", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FinishMergeExistingRows indicates an expected call of FinishMergeExistingRows
+func (mr *MockBackendMockRecorder) FinishMergeExistingRows(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishMergeExistingRows", reflect.TypeOf((*MockBackend)(nil).FinishMergeExistingRows), arg0, arg1)
+}
+
 // FlushAllEngines mocks base method
 func (m *MockBackend) FlushAllEngines(arg0 context.Context) error {
 	m.ctrl.T.Helper()
@@ -223,6 +238,21 @@ func (mr *MockBackendMockRecorder) MakeEmptyRows() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MakeEmptyRows", reflect.TypeOf((*MockBackend)(nil).MakeEmptyRows))
 }
 
+// MergeExistingRows mocks base method
+func (m *MockBackend) MergeExistingRows(arg0 context.Context, arg1 table.Table, arg2 string, arg3 *kv.SessionOptions) (verification.KVChecksum, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MergeExistingRows", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(verification.KVChecksum)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MergeExistingRows indicates an expected call of MergeExistingRows
+func (mr *MockBackendMockRecorder) MergeExistingRows(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MergeExistingRows", reflect.TypeOf((*MockBackend)(nil).MergeExistingRows), arg0, arg1, arg2, arg3)
+}
+
 // NewEncoder mocks base method
 func (m *MockBackend) NewEncoder(arg0 table.Table, arg1 *kv.SessionOptions) (kv.Encoder, error) {
 	m.ctrl.T.Helper()
@@ -266,6 +296,21 @@ func (mr *MockBackendMockRecorder) ResetEngine(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetEngine", reflect.TypeOf((*MockBackend)(nil).ResetEngine), arg0, arg1)
 }
 
+// ResolveDuplicateRows mocks base method
+func (m *MockBackend) ResolveDuplicateRows(arg0 context.Context, arg1 table.Table, arg2 string, arg3 *kv.SessionOptions, arg4 backend.RowSourceLocator) (verification.KVChecksum, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolveDuplicateRows", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(verification.KVChecksum)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolveDuplicateRows indicates an expected call of ResolveDuplicateRows
+func (mr *MockBackendMockRecorder) ResolveDuplicateRows(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolveDuplicateRows", reflect.TypeOf((*MockBackend)(nil).ResolveDuplicateRows), arg0, arg1, arg2, arg3, arg4)
+}
+
 // RetryImportDelay mocks base method
 func (m *MockBackend) RetryImportDelay() time.Duration {
 	m.ctrl.T.Helper()
@@ -294,6 +339,21 @@ func (mr *MockBackendMockRecorder) ShouldPostProcess() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShouldPostProcess", reflect.TypeOf((*MockBackend)(nil).ShouldPostProcess))
 }
 
+// SpillEngine mocks base method
+func (m *MockBackend) SpillEngine(arg0 context.Context, arg1 uuid.UUID) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SpillEngine", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SpillEngine indicates an expected call of SpillEngine
+func (mr *MockBackendMockRecorder) SpillEngine(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SpillEngine", reflect.TypeOf((*MockBackend)(nil).SpillEngine), arg0, arg1)
+}
+
 // MockEngineWriter is a mock of EngineWriter interface
 type MockEngineWriter struct {
 	ctrl     *gomock.Controller
@@ -31,8 +31,31 @@ var Nop UndoFunc = func(context.Context) error { return nil }
 const (
 	resetTSURL       = "/pd/api/v1/admin/reset-ts"
 	placementRuleURL = "/pd/api/v1/config/rules"
+	labelRulesURL    = "/pd/api/v1/config/region-label/rules"
+	labelRuleURL     = "/pd/api/v1/config/region-label/rule"
 )
 
+// RegionLabel is the label of a region.
+type RegionLabel struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// KeyRange is a hex encoded key range of a label rule.
+type KeyRange struct {
+	StartKeyHex string `json:"start_key"`
+	EndKeyHex   string `json:"end_key"`
+}
+
+// LabelRule is the rule to label the regions in key ranges,
+// which keeps the table attributes (e.g. `ALTER TABLE ... ATTRIBUTES`).
+type LabelRule struct {
+	ID       string        `json:"id"`
+	Labels   []RegionLabel `json:"labels"`
+	RuleType string        `json:"rule_type"`
+	Data     []KeyRange    `json:"data"`
+}
+
 // ResetTS resets the timestamp of PD to a bigger value.
 func ResetTS(ctx context.Context, pdAddr string, ts uint64, tlsConf *tls.Config) error {
 	payload, err := json.Marshal(struct {
@@ -101,6 +124,87 @@ func GetPlacementRules(ctx context.Context, pdAddr string, tlsConf *tls.Config)
 	return rules, nil
 }
 
+// GetLabelRules return the current region label rules.
+// An empty list is returned if PD doesn't support region label rules.
+func GetLabelRules(ctx context.Context, pdAddr string, tlsConf *tls.Config) ([]LabelRule, error) {
+	cli := httputil.NewClient(tlsConf)
+	prefix := "http://"
+	if tlsConf != nil {
+		prefix = "https://"
+	}
+	reqURL := prefix + pdAddr + labelRulesURL
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(resp.Body)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return []LabelRule{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Annotatef(berrors.ErrPDInvalidResponse, "get label rules failed: resp=%v, err=%v, code=%d", buf.String(), err, resp.StatusCode)
+	}
+	var rules []LabelRule
+	err = json.Unmarshal(buf.Bytes(), &rules)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rules, nil
+}
+
+// SetLabelRule inserts or updates a region label rule.
+func SetLabelRule(ctx context.Context, pdAddr string, rule LabelRule, tlsConf *tls.Config) error {
+	payload, err := json.Marshal(rule)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cli := httputil.NewClient(tlsConf)
+	prefix := "http://"
+	if tlsConf != nil {
+		prefix = "https://"
+	}
+	reqURL := prefix + pdAddr + labelRuleURL
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cli.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf := new(bytes.Buffer)
+		_, _ = buf.ReadFrom(resp.Body)
+		return errors.Annotatef(berrors.ErrPDInvalidResponse, "set label rule failed: req=%v, resp=%v", string(payload), buf.String())
+	}
+	return nil
+}
+
+// DecodeTableIDFromKeyHex decodes the table ID from a hex encoded key of placement rules.
+func DecodeTableIDFromKeyHex(keyHex string) (int64, bool) {
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return 0, false
+	}
+	_, decoded, err := codec.DecodeBytes(key)
+	if err != nil {
+		return 0, false
+	}
+	tableID := tablecodec.DecodeTableID(decoded)
+	return tableID, tableID != 0
+}
+
 // SearchPlacementRule returns the placement rule matched to the table or nil.
 func SearchPlacementRule(tableID int64, placementRules []placement.Rule, role placement.PeerRoleType) *placement.Rule {
 	for _, rule := range placementRules {
@@ -93,6 +93,18 @@ type Client struct {
 	// and restore stats with #dump.LoadStatsFromJSON
 	statsHandler *handle.Handle
 	dom          *domain.Domain
+	// metaReader reads the stats metafiles of the tables lazily.
+	metaReader          *metautil.MetaReader
+	analyzeMissingStats bool
+	stats               *statsRestorer
+
+	withSysTable     bool
+	sysTableConflict SysTableConflictPolicy
+
+	// priority and startTime are used for reporting the completion of each table.
+	priority  *TablePriority
+	startTime time.Time
+	tiFlash   *tiFlashReplicaRestorer
 }
 
 // NewRestoreClient returns a new RestoreClient.
@@ -127,6 +139,8 @@ func NewRestoreClient(
 		switchCh:      make(chan struct{}),
 		dom:           dom,
 		statsHandler:  statsHandle,
+		stats:         newStatsRestorer(),
+		startTime:     time.Now(),
 	}, nil
 }
 
@@ -167,6 +181,9 @@ func (rc *Client) Close() {
 	if rc.db != nil {
 		rc.db.Close()
 	}
+	if rc.tiFlash != nil {
+		rc.tiFlash.db.Close()
+	}
 	log.Info("Restore client closed")
 }
 
@@ -194,6 +211,7 @@ func (rc *Client) InitBackupMeta(c context.Context, backupMeta *backuppb.BackupM
 		rc.ddlJobs = ddlJobs
 	}
 	rc.backupMeta = backupMeta
+	rc.metaReader = reader
 	log.Info("load backupmeta", zap.Int("databases", len(rc.databases)), zap.Int("jobs", len(rc.ddlJobs)))
 
 	metaClient := NewSplitClient(rc.pdClient, rc.tlsConf)
@@ -827,6 +845,8 @@ func (rc *Client) execChecksum(ctx context.Context, tbl CreatedTable, kvClient k
 
 	if tbl.OldTable.NoChecksum() {
 		logger.Warn("table has no checksum, skipping checksum")
+		CollectTableResult(tbl, summary.ChecksumSkipped, nil)
+		rc.OnTableRestored(ctx, tbl)
 		return nil
 	}
 
@@ -851,6 +871,7 @@ func (rc *Client) execChecksum(ctx context.Context, tbl CreatedTable, kvClient k
 		// TODO: update progress here.
 	})
 	if err != nil {
+		CollectTableResult(tbl, "", err)
 		return errors.Trace(err)
 	}
 
@@ -866,20 +887,30 @@ func (rc *Client) execChecksum(ctx context.Context, tbl CreatedTable, kvClient k
 			zap.Uint64("origin tidb total bytes", table.TotalBytes),
 			zap.Uint64("calculated total bytes", checksumResp.TotalBytes),
 		)
-		return errors.Annotate(berrors.ErrRestoreChecksumMismatch, "failed to validate checksum")
-	}
-	if table.Stats != nil {
-		logger.Info("start loads analyze after validate checksum",
-			zap.Int64("old id", tbl.OldTable.Info.ID),
-			zap.Int64("new id", tbl.Table.ID),
-		)
-		if err := rc.statsHandler.LoadStatsFromJSON(rc.dom.InfoSchema(), table.Stats); err != nil {
-			logger.Error("analyze table failed", zap.Any("table", table.Stats), zap.Error(err))
-		}
+		err := errors.Annotate(berrors.ErrRestoreChecksumMismatch, "failed to validate checksum")
+		CollectTableResult(tbl, summary.ChecksumMismatched, err)
+		return err
 	}
+	CollectTableResult(tbl, summary.ChecksumMatched, nil)
+	rc.OnTableRestored(ctx, tbl)
 	return nil
 }
 
+// CollectTableResult collects the result of the restored table into the task report.
+func CollectTableResult(tbl CreatedTable, checksum string, err error) {
+	result := summary.TableResult{
+		DB:       tbl.OldTable.DB.Name.O,
+		Table:    tbl.OldTable.Info.Name.O,
+		KVs:      tbl.OldTable.TotalKvs,
+		Bytes:    tbl.OldTable.TotalBytes,
+		Checksum: checksum,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	summary.CollectTable(result)
+}
+
 const (
 	restoreLabelKey   = "exclusive"
 	restoreLabelValue = "restore"
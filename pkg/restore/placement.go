@@ -0,0 +1,309 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/tikv/pd/server/schedule/placement"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/conn"
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// tiflashRuleGroup is the group of the placement rules created by TiDB for TiFlash replicas,
+// these rules are recreated by `ALTER TABLE ... SET TIFLASH REPLICA` instead.
+const tiflashRuleGroup = "tiflash"
+
+// tablePrefixLen is the length of `t{tableID}`.
+var tablePrefixLen = len(tablecodec.EncodeTablePrefix(0))
+
+// PlacementLabelMapping translates the label values used by the placement rules
+// of the backup cluster to the ones of the restore cluster,
+// in the form of label key -> old value -> new value.
+type PlacementLabelMapping map[string]map[string]string
+
+// LoadPlacementLabelMapping loads the label mapping from a JSON file, e.g.
+// `{"zone": {"bj": "sh"}}` translates the label `zone=bj` to `zone=sh`.
+func LoadPlacementLabelMapping(path string) (PlacementLabelMapping, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	mapping := make(PlacementLabelMapping)
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return nil, errors.Annotatef(berrors.ErrInvalidArgument,
+			"invalid placement label mapping file %s: %v", path, err)
+	}
+	return mapping, nil
+}
+
+func (m PlacementLabelMapping) translate(key, value string) string {
+	if newValue, ok := m[key][value]; ok {
+		return newValue
+	}
+	return value
+}
+
+// PlacementPlan is the placement rules and TiFlash replicas to be applied
+// to the restored tables.
+type PlacementPlan struct {
+	Rules      []placement.Rule
+	LabelRules []pdutil.LabelRule
+	// TiFlashReplicas are the ALTER TABLE statements which restore TiFlash replicas.
+	TiFlashReplicas []string
+}
+
+// rewriteKeyRange rewrites a hex encoded key range of the old table to the new table.
+// The end key may be the prefix of the next table, which is rewritten to the next of the new table.
+func rewriteKeyRange(startKeyHex, endKeyHex string, idMap map[int64]int64) (string, string, bool) {
+	decode := func(keyHex string) []byte {
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil
+		}
+		_, raw, err := codec.DecodeBytes(key, nil)
+		if err != nil || len(raw) < tablePrefixLen {
+			return nil
+		}
+		return raw
+	}
+	encode := func(tableID int64, suffix []byte) string {
+		key := append(tablecodec.EncodeTablePrefix(tableID), suffix...)
+		return hex.EncodeToString(codec.EncodeBytes([]byte{}, key))
+	}
+
+	start, end := decode(startKeyHex), decode(endKeyHex)
+	if start == nil || end == nil {
+		return "", "", false
+	}
+	oldID := tablecodec.DecodeTableID(start)
+	newID, ok := idMap[oldID]
+	if !ok {
+		return "", "", false
+	}
+	newStart := encode(newID, start[tablePrefixLen:])
+	switch endID := tablecodec.DecodeTableID(end); {
+	case endID == oldID:
+		return newStart, encode(newID, end[tablePrefixLen:]), true
+	case endID == oldID+1 && len(end) == tablePrefixLen:
+		return newStart, encode(newID+1, nil), true
+	default:
+		return "", "", false
+	}
+}
+
+// rewriteRuleID replaces the old table ID in the rule ID with the new one.
+func rewriteRuleID(id string, oldID, newID int64) string {
+	re := regexp.MustCompile(fmt.Sprintf(`(^|[^0-9])%d([^0-9]|$)`, oldID))
+	return re.ReplaceAllString(id, fmt.Sprintf("${1}%d${2}", newID))
+}
+
+// RewritePlacementMeta rewrites the backed up placement rules and label rules to the new tables.
+// The rules of the tables not restored are dropped.
+func RewritePlacementMeta(
+	meta *metautil.PlacementMeta,
+	idMap map[int64]int64,
+	mapping PlacementLabelMapping,
+) *PlacementPlan {
+	plan := &PlacementPlan{}
+	for _, rule := range meta.Rules {
+		if rule.GroupID == tiflashRuleGroup {
+			continue
+		}
+		oldID, ok := pdutil.DecodeTableIDFromKeyHex(rule.StartKeyHex)
+		if !ok {
+			continue
+		}
+		startKeyHex, endKeyHex, ok := rewriteKeyRange(rule.StartKeyHex, rule.EndKeyHex, idMap)
+		if !ok {
+			continue
+		}
+		rule.ID = rewriteRuleID(rule.ID, oldID, idMap[oldID])
+		rule.StartKeyHex, rule.EndKeyHex = startKeyHex, endKeyHex
+		constraints := make([]placement.LabelConstraint, 0, len(rule.LabelConstraints))
+		for _, c := range rule.LabelConstraints {
+			values := make([]string, 0, len(c.Values))
+			for _, v := range c.Values {
+				values = append(values, mapping.translate(c.Key, v))
+			}
+			c.Values = values
+			constraints = append(constraints, c)
+		}
+		rule.LabelConstraints = constraints
+		plan.Rules = append(plan.Rules, rule)
+	}
+
+	for _, rule := range meta.LabelRules {
+		data := make([]pdutil.KeyRange, 0, len(rule.Data))
+		for _, r := range rule.Data {
+			oldID, ok := pdutil.DecodeTableIDFromKeyHex(r.StartKeyHex)
+			if !ok {
+				break
+			}
+			startKeyHex, endKeyHex, ok := rewriteKeyRange(r.StartKeyHex, r.EndKeyHex, idMap)
+			if !ok {
+				break
+			}
+			rule.ID = rewriteRuleID(rule.ID, oldID, idMap[oldID])
+			data = append(data, pdutil.KeyRange{StartKeyHex: startKeyHex, EndKeyHex: endKeyHex})
+		}
+		// a label rule is restored only if all of its key ranges are restored.
+		if len(data) == 0 || len(data) != len(rule.Data) {
+			continue
+		}
+		rule.Data = data
+		plan.LabelRules = append(plan.LabelRules, rule)
+	}
+	return plan
+}
+
+// mapRestoredTableIDs maps the IDs of the backed up tables and partitions to the restored ones.
+// In the preview, the tables not created yet keep the backed up IDs,
+// because the new IDs are only allocated when creating them.
+func (rc *Client) mapRestoredTableIDs(
+	dom *domain.Domain, tables []*metautil.Table, preview bool,
+) (map[int64]int64, map[*metautil.Table]*model.TableInfo) {
+	idMap := make(map[int64]int64)
+	newTables := make(map[*metautil.Table]*model.TableInfo)
+	for _, table := range tables {
+		newTable, err := rc.GetTableSchema(dom, table.DB.Name, table.Info.Name)
+		if err != nil {
+			// e.g. the system tables restored via temporary databases.
+			if _, isSysDB := utils.GetSysDBName(table.DB.Name); !preview || isSysDB {
+				continue
+			}
+			newTable = table.Info.Clone()
+			newTable.TiFlashReplica = nil
+		}
+		newTables[table] = newTable
+		for oldID, newID := range getTableIDMap(newTable, table.Info) {
+			idMap[oldID] = newID
+		}
+	}
+	return idMap, newTables
+}
+
+// PlanPlacement builds the placement rules and TiFlash replicas to be applied to the restored tables.
+// The preview plan can be built before creating the tables, see mapRestoredTableIDs.
+func (rc *Client) PlanPlacement(
+	ctx context.Context,
+	dom *domain.Domain,
+	tables []*metautil.Table,
+	mapping PlacementLabelMapping,
+	preview bool,
+) (*PlacementPlan, error) {
+	meta, err := rc.metaReader.ReadPlacement(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if meta == nil {
+		meta = &metautil.PlacementMeta{}
+	}
+	idMap, newTables := rc.mapRestoredTableIDs(dom, tables, preview)
+	plan := RewritePlacementMeta(meta, idMap, mapping)
+
+	tiFlashStores, err := rc.tiFlashStoreCount(ctx)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, table := range tables {
+		newTable, ok := newTables[table]
+		if !ok {
+			continue
+		}
+		if sql, ok := tiFlashReplicaSQL(table, newTable, tiFlashStores); ok {
+			plan.TiFlashReplicas = append(plan.TiFlashReplicas, sql)
+		}
+	}
+	return plan, nil
+}
+
+func (rc *Client) tiFlashStoreCount(ctx context.Context) (int, error) {
+	tiFlashStores, err := conn.GetAllTiKVStores(ctx, rc.pdClient, conn.TiFlashOnly)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return len(tiFlashStores), nil
+}
+
+// tiFlashReplicaSQL returns the statement to set the backed up TiFlash replicas of the restored table,
+// or false if the restored table needn't or can't have the replicas.
+func tiFlashReplicaSQL(table *metautil.Table, newTable *model.TableInfo, tiFlashStores int) (string, bool) {
+	if table.TiFlashReplicas == 0 {
+		return "", false
+	}
+	if newTable.TiFlashReplica != nil && newTable.TiFlashReplica.Count >= uint64(table.TiFlashReplicas) {
+		return "", false
+	}
+	if table.TiFlashReplicas > tiFlashStores {
+		log.Warn("not enough TiFlash stores to restore the TiFlash replicas",
+			zap.Stringer("db", table.DB.Name),
+			zap.Stringer("table", table.Info.Name),
+			zap.Int("replicas", table.TiFlashReplicas),
+			zap.Int("TiFlash stores", tiFlashStores))
+		return "", false
+	}
+	return fmt.Sprintf("ALTER TABLE %s SET TIFLASH REPLICA %d;",
+		utils.EncloseDBAndTable(table.DB.Name.O, newTable.Name.O), table.TiFlashReplicas), true
+}
+
+// RestorePlacement applies the placement rules of the plan to the restore cluster.
+// The TiFlash replicas are set as soon as each table is restored, see OnTableRestored.
+func (rc *Client) RestorePlacement(ctx context.Context, pdAddrs []string, plan *PlacementPlan) error {
+	for _, rule := range plan.Rules {
+		log.Info("restore placement rule",
+			zap.String("group", rule.GroupID), zap.String("id", rule.ID))
+		if err := rc.toolClient.SetPlacementRule(ctx, rule); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, rule := range plan.LabelRules {
+		log.Info("restore label rule", zap.String("id", rule.ID))
+		i := 0
+		err := utils.WithRetry(ctx, func() error {
+			idx := i % len(pdAddrs)
+			i++
+			return errors.Trace(pdutil.SetLabelRule(ctx, pdAddrs[idx], rule, rc.tlsConf))
+		}, newPDReqBackoffer())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	summary.CollectInt("restore placement rules", len(plan.Rules))
+	summary.CollectInt("restore label rules", len(plan.LabelRules))
+	return nil
+}
+
+// String implements fmt.Stringer, which is used to preview the plan.
+func (p *PlacementPlan) String() string {
+	content, err := json.MarshalIndent(struct {
+		Rules           []placement.Rule   `json:"rules"`
+		LabelRules      []pdutil.LabelRule `json:"label_rules"`
+		TiFlashReplicas []string           `json:"tiflash_replicas"`
+	}{p.Rules, p.LabelRules, p.TiFlashReplicas}, "", "  ")
+	if err != nil {
+		return "<invalid placement plan: " + strconv.Quote(err.Error()) + ">"
+	}
+	return string(content)
+}
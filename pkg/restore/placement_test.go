@@ -0,0 +1,79 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"encoding/hex"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/tablecodec"
+	"github.com/pingcap/tidb/util/codec"
+	"github.com/tikv/pd/server/schedule/placement"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/pdutil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+var _ = Suite(&testPlacementSuite{})
+
+type testPlacementSuite struct{}
+
+func tableKeyHex(tableID int64) string {
+	return hex.EncodeToString(codec.EncodeBytes([]byte{}, tablecodec.EncodeTablePrefix(tableID)))
+}
+
+func (s *testPlacementSuite) TestRewritePlacementMeta(c *C) {
+	meta := &metautil.PlacementMeta{
+		Rules: []placement.Rule{
+			{
+				GroupID:     "pd",
+				ID:          "table_rule_45_0",
+				StartKeyHex: tableKeyHex(45),
+				EndKeyHex:   tableKeyHex(46),
+				Role:        placement.Voter,
+				Count:       3,
+				LabelConstraints: []placement.LabelConstraint{
+					{Key: "zone", Op: "in", Values: []string{"bj", "gz"}},
+				},
+			},
+			// TiFlash rules are restored by setting TiFlash replicas.
+			{GroupID: "tiflash", ID: "table-45-r", StartKeyHex: tableKeyHex(45), EndKeyHex: tableKeyHex(46)},
+			// the table isn't restored.
+			{GroupID: "pd", ID: "table_rule_50_0", StartKeyHex: tableKeyHex(50), EndKeyHex: tableKeyHex(51)},
+		},
+		LabelRules: []pdutil.LabelRule{
+			{
+				ID:       "schema/test/t",
+				Labels:   []pdutil.RegionLabel{{Key: "merge_option", Value: "deny"}},
+				RuleType: "key-range",
+				Data:     []pdutil.KeyRange{{StartKeyHex: tableKeyHex(45), EndKeyHex: tableKeyHex(46)}},
+			},
+			{
+				ID:       "schema/test/t2",
+				RuleType: "key-range",
+				Data: []pdutil.KeyRange{
+					{StartKeyHex: tableKeyHex(45), EndKeyHex: tableKeyHex(46)},
+					{StartKeyHex: tableKeyHex(50), EndKeyHex: tableKeyHex(51)},
+				},
+			},
+		},
+	}
+	mapping := restore.PlacementLabelMapping{"zone": {"bj": "sh"}}
+	plan := restore.RewritePlacementMeta(meta, map[int64]int64{45: 145}, mapping)
+
+	c.Assert(plan.Rules, HasLen, 1)
+	rule := plan.Rules[0]
+	c.Assert(rule.ID, Equals, "table_rule_145_0")
+	c.Assert(rule.StartKeyHex, Equals, tableKeyHex(145))
+	c.Assert(rule.EndKeyHex, Equals, tableKeyHex(146))
+	c.Assert(rule.LabelConstraints[0].Values, DeepEquals, []string{"sh", "gz"})
+	// the backed up rules must not be modified.
+	c.Assert(meta.Rules[0].LabelConstraints[0].Values, DeepEquals, []string{"bj", "gz"})
+
+	// the label rule with a key range of not restored tables is dropped.
+	c.Assert(plan.LabelRules, HasLen, 1)
+	c.Assert(plan.LabelRules[0].ID, Equals, "schema/test/t")
+	c.Assert(plan.LabelRules[0].Data, DeepEquals,
+		[]pdutil.KeyRange{{StartKeyHex: tableKeyHex(145), EndKeyHex: tableKeyHex(146)}})
+}
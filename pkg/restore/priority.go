@@ -0,0 +1,207 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+	"github.com/pingcap/tidb/kv"
+	"go.uber.org/zap"
+
+	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// TablePriority assigns the tables to ordered priority groups,
+// the tables in a group with smaller index are restored earlier.
+// The tables not matched by any group are in the last group.
+type TablePriority struct {
+	groups []filter.Filter
+}
+
+// ParseTablePriority parses the priority groups, each group is a list of table filter rules, e.g.
+// `[["db.orders", "db.users"], ["db.*"]]` restores `db.orders` and `db.users` before other tables in `db`.
+func ParseTablePriority(groups [][]string) (*TablePriority, error) {
+	p := &TablePriority{groups: make([]filter.Filter, 0, len(groups))}
+	for _, rules := range groups {
+		if len(rules) == 0 {
+			continue
+		}
+		f, err := filter.Parse(rules)
+		if err != nil {
+			return nil, errors.Annotatef(berrors.ErrInvalidArgument, "invalid table priority %v: %v", rules, err)
+		}
+		p.groups = append(p.groups, filter.CaseInsensitive(f))
+	}
+	return p, nil
+}
+
+// LoadTablePriorityFile loads the priority groups from a file, each line of which is a group
+// of comma separated table filter rules. Empty lines and lines starting with `#` are ignored.
+func LoadTablePriorityFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer f.Close()
+
+	groups := make([][]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		groups = append(groups, splitPriorityRules(line))
+	}
+	return groups, errors.Trace(scanner.Err())
+}
+
+// splitPriorityRules splits a group of comma separated table filter rules.
+func splitPriorityRules(group string) []string {
+	rules := make([]string, 0)
+	for _, rule := range strings.Split(group, ",") {
+		if rule = strings.TrimSpace(rule); len(rule) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ParseTablePriorityFlag parses the priority groups from the command line,
+// groups are separated by `;` and the rules in a group are separated by `,`.
+func ParseTablePriorityFlag(value string) [][]string {
+	groups := make([][]string, 0)
+	for _, group := range strings.Split(value, ";") {
+		if rules := splitPriorityRules(group); len(rules) > 0 {
+			groups = append(groups, rules)
+		}
+	}
+	return groups
+}
+
+// Len returns the number of the priority groups, including the last group of unmatched tables.
+func (p *TablePriority) Len() int {
+	if p == nil {
+		return 1
+	}
+	return len(p.groups) + 1
+}
+
+// Of returns the priority group of the table.
+func (p *TablePriority) Of(table *metautil.Table) int {
+	if p == nil {
+		return 0
+	}
+	dbName := table.DB.Name.O
+	if name, ok := utils.GetSysDBName(table.DB.Name); utils.IsSysDB(name) && ok {
+		dbName = name
+	}
+	for i, f := range p.groups {
+		if f.MatchTable(dbName, table.Info.Name.O) {
+			return i
+		}
+	}
+	return len(p.groups)
+}
+
+// Sort sorts the tables by priority, the order of tables in the same group is kept.
+func (p *TablePriority) Sort(tables []*metautil.Table) {
+	sort.SliceStable(tables, func(i, j int) bool {
+		return p.Of(tables[i]) < p.Of(tables[j])
+	})
+}
+
+// Group splits the tables into the non-empty priority groups in order.
+func (p *TablePriority) Group(tables []*metautil.Table) [][]*metautil.Table {
+	groups := make([][]*metautil.Table, p.Len())
+	for _, table := range tables {
+		i := p.Of(table)
+		groups[i] = append(groups[i], table)
+	}
+	result := make([][]*metautil.Table, 0, len(groups))
+	for _, group := range groups {
+		if len(group) > 0 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+// SetTablePriority sets the priority of the tables to restore, which is reported
+// when a table is restored.
+func (rc *Client) SetTablePriority(p *TablePriority) {
+	rc.priority = p
+}
+
+// tiFlashReplicaRestorer sets the TiFlash replicas of the restored tables
+// by its own session, since the tables are restored concurrently.
+type tiFlashReplicaRestorer struct {
+	mu     sync.Mutex
+	db     *DB
+	stores int
+}
+
+// EnableTiFlashReplicaRestore makes the client set the backed up TiFlash replicas
+// of each table as soon as its data is restored.
+func (rc *Client) EnableTiFlashReplicaRestore(ctx context.Context, g glue.Glue, store kv.Storage) error {
+	stores, err := rc.tiFlashStoreCount(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	db, err := NewDB(g, store)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	rc.tiFlash = &tiFlashReplicaRestorer{db: db, stores: stores}
+	return nil
+}
+
+// restoreTableTiFlashReplica sets the backed up TiFlash replicas of the restored table.
+func (rc *Client) restoreTableTiFlashReplica(ctx context.Context, tbl CreatedTable) {
+	sql, ok := tiFlashReplicaSQL(tbl.OldTable, tbl.Table, rc.tiFlash.stores)
+	if !ok {
+		return
+	}
+	rc.tiFlash.mu.Lock()
+	err := rc.tiFlash.db.se.Execute(ctx, sql)
+	rc.tiFlash.mu.Unlock()
+	if err != nil {
+		// the data is restored anyway, so TiFlash replicas can be added by hand later.
+		log.Warn("restore TiFlash replica failed", zap.String("query", sql), logutil.ShortError(err))
+		return
+	}
+	summary.CollectInt("restore TiFlash replicas", 1)
+}
+
+// OnTableRestored is called as soon as the data of a table is restored and validated,
+// it restores the stats and TiFlash replicas of the table, and reports the completion.
+func (rc *Client) OnTableRestored(ctx context.Context, tbl CreatedTable) {
+	rc.RestoreTableStats(ctx, tbl)
+	if rc.tiFlash != nil {
+		rc.restoreTableTiFlashReplica(ctx, tbl)
+	}
+	priority := rc.priority.Of(tbl.OldTable)
+	log.Info("table restore completed",
+		zap.String("db", tbl.OldTable.DB.Name.O),
+		zap.String("table", tbl.Table.Name.O),
+		zap.Int("priority", priority),
+		zap.Duration("since restore start", time.Since(rc.startTime)))
+	if rc.priority != nil {
+		summary.CollectInt(fmt.Sprintf("restored tables of priority %d", priority), 1)
+	}
+}
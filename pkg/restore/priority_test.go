@@ -0,0 +1,80 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/model"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+var _ = Suite(&testPrioritySuite{})
+
+type testPrioritySuite struct{}
+
+func mockTable(db, table string) *metautil.Table {
+	return &metautil.Table{
+		DB:   &model.DBInfo{Name: model.NewCIStr(db)},
+		Info: &model.TableInfo{Name: model.NewCIStr(table)},
+	}
+}
+
+func tableNames(tables []*metautil.Table) []string {
+	names := make([]string, 0, len(tables))
+	for _, t := range tables {
+		names = append(names, t.DB.Name.O+"."+t.Info.Name.O)
+	}
+	return names
+}
+
+func (s *testPrioritySuite) TestParseTablePriorityFlag(c *C) {
+	c.Assert(restore.ParseTablePriorityFlag(""), HasLen, 0)
+	c.Assert(restore.ParseTablePriorityFlag(" db.orders, db.users ;; db.* ;"), DeepEquals,
+		[][]string{{"db.orders", "db.users"}, {"db.*"}})
+}
+
+func (s *testPrioritySuite) TestLoadTablePriorityFile(c *C) {
+	path := filepath.Join(c.MkDir(), "priority.txt")
+	content := "# critical tables\ndb.orders, db.users\n\ndb.*\n"
+	c.Assert(os.WriteFile(path, []byte(content), 0o644), IsNil)
+	groups, err := restore.LoadTablePriorityFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(groups, DeepEquals, [][]string{{"db.orders", "db.users"}, {"db.*"}})
+}
+
+func (s *testPrioritySuite) TestTablePriority(c *C) {
+	p, err := restore.ParseTablePriority([][]string{{"db.orders", "db.users"}, {"db.*"}})
+	c.Assert(err, IsNil)
+	c.Assert(p.Len(), Equals, 3)
+
+	tables := []*metautil.Table{
+		mockTable("other", "t"),
+		mockTable("db", "logs"),
+		mockTable("DB", "Users"),
+		mockTable("db", "orders"),
+	}
+	c.Assert(p.Of(tables[0]), Equals, 2)
+	c.Assert(p.Of(tables[1]), Equals, 1)
+	c.Assert(p.Of(tables[2]), Equals, 0)
+
+	groups := p.Group(tables)
+	c.Assert(groups, HasLen, 3)
+	c.Assert(tableNames(groups[0]), DeepEquals, []string{"DB.Users", "db.orders"})
+	c.Assert(tableNames(groups[1]), DeepEquals, []string{"db.logs"})
+	c.Assert(tableNames(groups[2]), DeepEquals, []string{"other.t"})
+
+	p.Sort(tables)
+	c.Assert(tableNames(tables), DeepEquals, []string{"DB.Users", "db.orders", "db.logs", "other.t"})
+
+	// no priority keeps the order and puts all tables in one group.
+	var none *restore.TablePriority
+	c.Assert(none.Group(tables), HasLen, 1)
+
+	_, err = restore.ParseTablePriority([][]string{{"db.`"}})
+	c.Assert(err, ErrorMatches, ".*invalid table priority.*")
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/pingcap/tidb/statistics/handle"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pingcap/br/pkg/logutil"
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/utils"
+)
+
+// StatsTreatment is the way the statistics of a restored table are recovered.
+type StatsTreatment string
+
+const (
+	// StatsLoaded means the backed up stats are loaded into the new table.
+	StatsLoaded StatsTreatment = "loaded"
+	// StatsAnalyzed means the table is analyzed after restore,
+	// because its stats are missing or stale.
+	StatsAnalyzed StatsTreatment = "analyzed"
+	// StatsSkipped means the table is left without stats.
+	StatsSkipped StatsTreatment = "skipped"
+	// StatsFailed means neither loading nor analyzing the stats succeeded.
+	StatsFailed StatsTreatment = "failed"
+)
+
+// staleStatsRatio is the ratio of modified rows to total rows above which
+// the backed up stats are considered stale, the same as the default
+// `tidb_auto_analyze_ratio`.
+const staleStatsRatio = 0.5
+
+// statsRestorer loads the backed up stats into the restored tables,
+// and queues the tables whose stats are missing or stale for ANALYZE.
+type statsRestorer struct {
+	mu         sync.Mutex
+	analyze    []CreatedTable
+	treatments map[StatsTreatment][]string
+}
+
+func newStatsRestorer() *statsRestorer {
+	return &statsRestorer{
+		treatments: make(map[StatsTreatment][]string),
+	}
+}
+
+func (r *statsRestorer) record(tbl CreatedTable, treatment StatsTreatment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := utils.EncloseDBAndTable(tbl.OldTable.DB.Name.O, tbl.Table.Name.O)
+	r.treatments[treatment] = append(r.treatments[treatment], name)
+}
+
+func (r *statsRestorer) queueAnalyze(tbl CreatedTable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.analyze = append(r.analyze, tbl)
+}
+
+// IsStatsStale checks whether the backed up stats of the table are missing
+// or too outdated to be useful, so the table should be analyzed instead.
+func IsStatsStale(table *metautil.Table, stats *handle.JSONTable) bool {
+	if stats == nil {
+		return true
+	}
+	if stats.Count == 0 {
+		// an empty table has no stats worth to load, but a non-empty one needs analyze.
+		return table.TotalKvs > 0
+	}
+	return float64(stats.ModifyCount)/float64(stats.Count) > staleStatsRatio
+}
+
+// EnableAnalyzeMissingStats makes the client analyze the tables whose stats are
+// missing or stale after restore.
+func (rc *Client) EnableAnalyzeMissingStats() {
+	rc.analyzeMissingStats = true
+}
+
+// readTableStats reads the backed up stats of the table,
+// from either the schema (old backupmeta) or the stats metafile.
+func (rc *Client) readTableStats(ctx context.Context, table *metautil.Table) (*handle.JSONTable, error) {
+	if table.Stats != nil {
+		return table.Stats, nil
+	}
+	if table.StatsFile == nil || rc.metaReader == nil {
+		return nil, nil
+	}
+	stats, err := rc.metaReader.ReadStats(ctx, table.StatsFile)
+	return stats, errors.Trace(err)
+}
+
+// RestoreTableStats loads the backed up stats into the restored table.
+// Tables whose stats are missing or stale are queued for analyze,
+// see AnalyzeQueuedTables.
+func (rc *Client) RestoreTableStats(ctx context.Context, tbl CreatedTable) {
+	if rc.statsHandler == nil {
+		rc.stats.record(tbl, StatsSkipped)
+		return
+	}
+	logger := log.With(
+		zap.String("db", tbl.OldTable.DB.Name.O),
+		zap.String("table", tbl.OldTable.Info.Name.O),
+		zap.Int64("old id", tbl.OldTable.Info.ID),
+		zap.Int64("new id", tbl.Table.ID),
+	)
+
+	stats, err := rc.readTableStats(ctx, tbl.OldTable)
+	if err != nil {
+		logger.Warn("read table stats failed", logutil.ShortError(err))
+	}
+	if IsStatsStale(tbl.OldTable, stats) {
+		if rc.analyzeMissingStats {
+			logger.Info("table stats are missing or stale, queue it to analyze")
+			rc.stats.queueAnalyze(tbl)
+		} else {
+			rc.stats.record(tbl, StatsSkipped)
+		}
+		return
+	}
+
+	// the stats are bound to the new table by name, so the new table ID is used.
+	stats.DatabaseName = tbl.OldTable.DB.Name.O
+	stats.TableName = tbl.Table.Name.O
+	logger.Info("start loading table stats")
+	if err := rc.statsHandler.LoadStatsFromJSON(rc.dom.InfoSchema(), stats); err != nil {
+		logger.Error("load table stats failed", logutil.ShortError(err))
+		if rc.analyzeMissingStats {
+			rc.stats.queueAnalyze(tbl)
+		} else {
+			rc.stats.record(tbl, StatsFailed)
+		}
+		return
+	}
+	rc.stats.record(tbl, StatsLoaded)
+}
+
+// AnalyzeQueuedTables analyzes the tables queued by RestoreTableStats, concurrently
+// with the sessions of dbPool if any, and then reports which tables got which stats treatment.
+func (rc *Client) AnalyzeQueuedTables(ctx context.Context, dbPool []*DB) {
+	rc.stats.mu.Lock()
+	queue := rc.stats.analyze
+	rc.stats.analyze = nil
+	rc.stats.mu.Unlock()
+
+	analyzeOneTable := func(db *DB, tbl CreatedTable) {
+		if ctx.Err() != nil {
+			rc.stats.record(tbl, StatsFailed)
+			return
+		}
+		sql := fmt.Sprintf("ANALYZE TABLE %s;",
+			utils.EncloseDBAndTable(tbl.OldTable.DB.Name.O, tbl.Table.Name.O))
+		if err := db.se.Execute(ctx, sql); err != nil {
+			log.Warn("analyze table failed", zap.String("query", sql), logutil.ShortError(err))
+			rc.stats.record(tbl, StatsFailed)
+			return
+		}
+		rc.stats.record(tbl, StatsAnalyzed)
+	}
+	if len(dbPool) > 0 {
+		var eg errgroup.Group
+		workers := utils.NewWorkerPool(uint(len(dbPool)), "analyze workers")
+		for _, t := range queue {
+			tbl := t
+			workers.ApplyWithIDInErrorGroup(&eg, func(id uint64) error {
+				analyzeOneTable(dbPool[id%uint64(len(dbPool))], tbl)
+				return nil
+			})
+		}
+		// the failures are only recorded, so there is no error to wait for.
+		_ = eg.Wait()
+	} else {
+		for _, tbl := range queue {
+			analyzeOneTable(rc.db, tbl)
+		}
+	}
+
+	rc.stats.mu.Lock()
+	defer rc.stats.mu.Unlock()
+	fields := make([]zap.Field, 0, len(rc.stats.treatments))
+	for treatment, tables := range rc.stats.treatments {
+		sort.Strings(tables)
+		summary.CollectInt(fmt.Sprintf("stats %s tables", treatment), len(tables))
+		fields = append(fields, zap.Strings(string(treatment), tables))
+	}
+	log.Info("restore table stats finished", fields...)
+}
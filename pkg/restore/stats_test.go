@@ -0,0 +1,31 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	. "github.com/pingcap/check"
+	"github.com/pingcap/tidb/statistics/handle"
+
+	"github.com/pingcap/br/pkg/metautil"
+	"github.com/pingcap/br/pkg/restore"
+)
+
+var _ = Suite(&testRestoreStatsSuite{})
+
+type testRestoreStatsSuite struct{}
+
+func (s *testRestoreStatsSuite) TestIsStatsStale(c *C) {
+	empty := &metautil.Table{}
+	nonEmpty := &metautil.Table{TotalKvs: 100}
+
+	c.Assert(restore.IsStatsStale(nonEmpty, nil), IsTrue)
+	c.Assert(restore.IsStatsStale(empty, nil), IsTrue)
+
+	// an empty table doesn't need to be analyzed.
+	c.Assert(restore.IsStatsStale(empty, &handle.JSONTable{}), IsFalse)
+	c.Assert(restore.IsStatsStale(nonEmpty, &handle.JSONTable{}), IsTrue)
+
+	c.Assert(restore.IsStatsStale(nonEmpty, &handle.JSONTable{Count: 100, ModifyCount: 10}), IsFalse)
+	c.Assert(restore.IsStatsStale(nonEmpty, &handle.JSONTable{Count: 100, ModifyCount: 50}), IsFalse)
+	c.Assert(restore.IsStatsStale(nonEmpty, &handle.JSONTable{Count: 100, ModifyCount: 51}), IsTrue)
+}
@@ -5,6 +5,8 @@ package restore
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
@@ -31,18 +33,7 @@ var statsTables = map[string]struct{}{
 
 var unRecoverableTable = map[string]struct{}{
 	// some variables in tidb (e.g. gc_safe_point) cannot be recovered.
-	"tidb":             {},
-	"global_variables": {},
-
-	// all user related tables cannot be recovered for now.
-	"columns_priv":  {},
-	"db":            {},
-	"default_roles": {},
-	"global_grants": {},
-	"global_priv":   {},
-	"role_edges":    {},
-	"tables_priv":   {},
-	"user":          {},
+	"tidb": {},
 
 	// gc info don't need to recover.
 	"gc_delete_range":      {},
@@ -52,6 +43,92 @@ var unRecoverableTable = map[string]struct{}{
 	"schema_index_usage": {},
 }
 
+// sysPrivilegeTables are the tables holding users, privileges, bindings and global variables,
+// they can only be recovered in the `--with-sys-table` mode.
+var sysPrivilegeTables = map[string]struct{}{
+	"user":             {},
+	"db":               {},
+	"tables_priv":      {},
+	"columns_priv":     {},
+	"role_edges":       {},
+	"default_roles":    {},
+	"global_priv":      {},
+	"global_grants":    {},
+	"bind_info":        {},
+	"global_variables": {},
+}
+
+// SysTableConflictPolicy is the way to handle the rows of system tables
+// which conflict with the existing rows in the target cluster.
+type SysTableConflictPolicy string
+
+const (
+	// SysTableConflictSkip keeps the existing rows.
+	SysTableConflictSkip SysTableConflictPolicy = "skip"
+	// SysTableConflictReplace overwrites the existing rows.
+	SysTableConflictReplace SysTableConflictPolicy = "replace"
+	// SysTableConflictError fails the restore of the table.
+	SysTableConflictError SysTableConflictPolicy = "error"
+)
+
+// ParseSysTableConflictPolicy parses the conflict policy of system tables.
+func ParseSysTableConflictPolicy(policy string) (SysTableConflictPolicy, error) {
+	switch p := SysTableConflictPolicy(strings.ToLower(policy)); p {
+	case SysTableConflictSkip, SysTableConflictReplace, SysTableConflictError:
+		return p, nil
+	default:
+		return "", errors.Annotatef(berrors.ErrInvalidArgument,
+			"invalid system table conflict policy '%s', should be one of 'skip|replace|error'", policy)
+	}
+}
+
+// sysTableFilter is a filter that additionally matches the privilege tables in the `mysql` schema.
+type sysTableFilter struct {
+	filter.Filter
+}
+
+// NewSysTableFilter extends the table filter with the privilege tables in the `mysql` schema,
+// so they are backed up and restored in the `--with-sys-table` mode.
+// It is used for the filters not built from rules, e.g. the one of `--db` and `--table`.
+func NewSysTableFilter(f filter.Filter) filter.Filter {
+	return sysTableFilter{Filter: f}
+}
+
+func (f sysTableFilter) MatchTable(schema string, table string) bool {
+	if isSysPrivilegeTable(schema, table) {
+		return true
+	}
+	return f.Filter.MatchTable(schema, table)
+}
+
+func (f sysTableFilter) MatchSchema(schema string) bool {
+	return utils.IsSysDB(strings.ToLower(schema)) || f.Filter.MatchSchema(schema)
+}
+
+// SysTableFilterRules adds the privilege tables in the `mysql` schema to the table filter rules
+// in the `--with-sys-table` mode. The rules given explicitly are applied after the privilege tables,
+// so an exclusion like `!mysql.bind_info` still filters the table out. The default rules, which
+// exclude the whole `mysql` schema, are applied before them instead.
+func SysTableFilterRules(rules []string, explicit bool) []string {
+	sysRules := make([]string, 0, len(sysPrivilegeTables))
+	for table := range sysPrivilegeTables {
+		sysRules = append(sysRules, mysql.SystemDB+"."+table)
+	}
+	sort.Strings(sysRules)
+	if explicit {
+		return append(sysRules, rules...)
+	}
+	return append(append([]string{}, rules...), sysRules...)
+}
+
+func isSysPrivilegeTable(schema, table string) bool {
+	if !utils.IsSysDB(strings.ToLower(schema)) {
+		return false
+	}
+	_, ok := sysPrivilegeTables[strings.ToLower(table)]
+	return ok
+}
+
 func isUnrecoverableTable(tableName string) bool {
 	_, ok := unRecoverableTable[tableName]
 	return ok
@@ -62,9 +139,17 @@ func isStatsTable(tableName string) bool {
 	return ok
 }
 
+// EnableSysTable makes the client restore the privilege tables in the `mysql` schema,
+// the rows conflicting with existing ones are handled by the policy.
+func (rc *Client) EnableSysTable(policy SysTableConflictPolicy) {
+	rc.withSysTable = true
+	rc.sysTableConflict = policy
+}
+
 // RestoreSystemSchemas restores the system schema(i.e. the `mysql` schema).
 // Detail see https://github.com/pingcap/br/issues/679#issuecomment-762592254.
-func (rc *Client) RestoreSystemSchemas(ctx context.Context, f filter.Filter) {
+// The tables failed to restore are only warned, except the conflicting rows under the `error` policy.
+func (rc *Client) RestoreSystemSchemas(ctx context.Context, f filter.Filter) error {
 	sysDB := mysql.SystemDB
 
 	temporaryDB := utils.TemporaryDBName(sysDB)
@@ -72,29 +157,36 @@ func (rc *Client) RestoreSystemSchemas(ctx context.Context, f filter.Filter) {
 
 	if !f.MatchSchema(sysDB) {
 		log.Debug("system database filtered out", zap.String("database", sysDB))
-		return
+		return nil
 	}
 	originDatabase, ok := rc.databases[temporaryDB.O]
 	if !ok {
 		log.Info("system database not backed up, skipping", zap.String("database", sysDB))
-		return
+		return nil
 	}
 	db, ok := rc.getDatabaseByName(sysDB)
 	if !ok {
 		// Or should we create the database here?
 		log.Warn("target database not exist, aborting", zap.String("database", sysDB))
-		return
+		return nil
 	}
 
+	var conflictErr error
 	tablesRestored := make([]string, 0, len(originDatabase.Tables))
 	for _, table := range originDatabase.Tables {
 		tableName := table.Info.Name
 		if f.MatchTable(sysDB, tableName.O) {
 			if err := rc.replaceTemporaryTableToSystable(ctx, tableName.L, db); err != nil {
+				if berrors.Is(err, berrors.ErrRestoreSysTableConflict) {
+					// the tables restored before still need to take effect.
+					conflictErr = err
+					break
+				}
 				log.Warn("error during merging temporary tables into system tables",
 					logutil.ShortError(err),
 					zap.Stringer("table", tableName),
 				)
+				continue
 			}
 			tablesRestored = append(tablesRestored, tableName.L)
 		}
@@ -104,6 +196,7 @@ func (rc *Client) RestoreSystemSchemas(ctx context.Context, f filter.Filter) {
 			log.Warn("error during reconfigurating the system tables", zap.String("database", sysDB), logutil.ShortError(e))
 		}
 	}
+	return errors.Trace(conflictErr)
 }
 
 // database is a record of a database.
@@ -136,19 +229,49 @@ func (rc *Client) getDatabaseByName(name string) (*database, bool) {
 // e.g. after inserting to the table mysql.user, we must execute `FLUSH PRIVILEGES` to allow it take effect.
 func (rc *Client) afterSystemTablesReplaced(ctx context.Context, tables []string) error {
 	var err error
+	flushPrivileges, reloadBindings := false, false
 	for _, table := range tables {
-		switch {
-		case table == "user":
-			// We cannot execute `rc.dom.NotifyUpdatePrivilege` here, because there isn't
-			// sessionctx.Context provided by the glue.
-			// TODO: update the glue type and allow we retrive a session context from it.
+		switch table {
+		case "bind_info":
+			reloadBindings = true
+		case "global_variables":
+			// global variables are reloaded by TiDB itself, nothing to do.
+		default:
+			if _, ok := sysPrivilegeTables[table]; ok {
+				flushPrivileges = true
+			}
+		}
+	}
+	if flushPrivileges {
+		if e := rc.db.se.Execute(ctx, "FLUSH PRIVILEGES;"); e != nil {
+			err = multierr.Append(err, errors.Annotatef(berrors.ErrUnsupportedSystemTable,
+				"restored user info may not take effect, until you should execute `FLUSH PRIVILEGES` manually: %v", e))
+		}
+	}
+	if reloadBindings {
+		if e := rc.db.se.Execute(ctx, "ADMIN RELOAD BINDINGS;"); e != nil {
 			err = multierr.Append(err, errors.Annotatef(berrors.ErrUnsupportedSystemTable,
-				"restored user info may not take effect, until you should execute `FLUSH PRIVILEGES` manually"))
+				"restored bindings may not take effect, until you should execute `ADMIN RELOAD BINDINGS` manually: %v", e))
 		}
 	}
 	return err
 }
 
+// sysTableInsertSQL makes the SQL to insert the rows of the temporary table into the system table,
+// handling the conflicting rows by the policy.
+func sysTableInsertSQL(policy SysTableConflictPolicy, table, temporaryTable string) string {
+	var verb string
+	switch policy {
+	case SysTableConflictSkip:
+		verb = "INSERT IGNORE INTO"
+	case SysTableConflictError:
+		verb = "INSERT INTO"
+	default:
+		verb = "REPLACE INTO"
+	}
+	return fmt.Sprintf("%s %s SELECT * FROM %s;", verb, table, temporaryTable)
+}
+
 // replaceTemporaryTableToSystable replaces the temporary table to real system table.
 func (rc *Client) replaceTemporaryTableToSystable(ctx context.Context, tableName string, db *database) error {
 	execSQL := func(sql string) error {
@@ -187,14 +310,29 @@ func (rc *Client) replaceTemporaryTableToSystable(ctx context.Context, tableName
 		return berrors.ErrUnsupportedSystemTable.GenWithStack("restoring unsupported `mysql` schema table")
 	}
 
+	if _, ok := sysPrivilegeTables[tableName]; ok && !rc.withSysTable {
+		return berrors.ErrUnsupportedSystemTable.GenWithStack(
+			"restoring users, privileges and global variables needs `--with-sys-table`")
+	}
+
 	if db.ExistingTables[tableName] != nil {
-		log.Info("table existing, using replace into for restore",
+		policy := rc.sysTableConflict
+		if policy == "" {
+			policy = SysTableConflictReplace
+		}
+		log.Info("table existing, using insert for restore",
 			zap.String("table", tableName),
-			zap.Stringer("schema", db.Name))
-		replaceIntoSQL := fmt.Sprintf("REPLACE INTO %s SELECT * FROM %s;",
+			zap.Stringer("schema", db.Name),
+			zap.String("conflict policy", string(policy)))
+		insertSQL := sysTableInsertSQL(policy,
 			utils.EncloseDBAndTable(db.Name.L, tableName),
 			utils.EncloseDBAndTable(db.TemporaryName.L, tableName))
-		return execSQL(replaceIntoSQL)
+		err := execSQL(insertSQL)
+		if err != nil && policy == SysTableConflictError {
+			return berrors.ErrRestoreSysTableConflict.Wrap(err).GenWithStack(
+				"failed to restore %s under the `error` conflict policy", utils.EncloseDBAndTable(db.Name.L, tableName))
+		}
+		return err
 	}
 
 	renameSQL := fmt.Sprintf("RENAME TABLE %s TO %s;",
@@ -0,0 +1,76 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package restore_test
+
+import (
+	"strings"
+
+	. "github.com/pingcap/check"
+	filter "github.com/pingcap/tidb-tools/pkg/table-filter"
+
+	"github.com/pingcap/br/pkg/restore"
+)
+
+var _ = Suite(&testSysTableSuite{})
+
+type testSysTableSuite struct{}
+
+func (s *testSysTableSuite) TestParseSysTableConflictPolicy(c *C) {
+	for _, policy := range []string{"skip", "replace", "error", "REPLACE"} {
+		p, err := restore.ParseSysTableConflictPolicy(policy)
+		c.Assert(err, IsNil)
+		c.Assert(string(p), Equals, strings.ToLower(policy), Commentf("policy %s", policy))
+	}
+	_, err := restore.ParseSysTableConflictPolicy("overwrite")
+	c.Assert(err, ErrorMatches, ".*invalid system table conflict policy.*")
+}
+
+func (s *testSysTableSuite) TestSysTableFilter(c *C) {
+	f, err := filter.Parse(restore.SysTableFilterRules([]string{"db1.*"}, true))
+	c.Assert(err, IsNil)
+	f = filter.CaseInsensitive(f)
+
+	c.Assert(f.MatchSchema("db1"), IsTrue)
+	c.Assert(f.MatchSchema("mysql"), IsTrue)
+	c.Assert(f.MatchSchema("db2"), IsFalse)
+
+	c.Assert(f.MatchTable("db1", "t1"), IsTrue)
+	c.Assert(f.MatchTable("db2", "t1"), IsFalse)
+	c.Assert(f.MatchTable("mysql", "user"), IsTrue)
+	c.Assert(f.MatchTable("MySQL", "Tables_Priv"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "bind_info"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "stats_meta"), IsFalse)
+	c.Assert(f.MatchTable("db1", "user"), IsTrue)
+	c.Assert(f.MatchTable("db2", "user"), IsFalse)
+}
+
+func (s *testSysTableSuite) TestSysTableFilterExclusion(c *C) {
+	f, err := filter.Parse(restore.SysTableFilterRules([]string{"db1.*", "!mysql.bind_info"}, true))
+	c.Assert(err, IsNil)
+	c.Assert(f.MatchTable("db1", "t1"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "user"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "bind_info"), IsFalse)
+
+	// an explicit exclusion of the whole schema filters out the privilege tables too.
+	f, err = filter.Parse(restore.SysTableFilterRules([]string{"*.*", "!mysql.*"}, true))
+	c.Assert(err, IsNil)
+	c.Assert(f.MatchTable("db1", "t1"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "user"), IsFalse)
+
+	// the default rules excluding the `mysql` schema don't filter out the privilege tables.
+	f, err = filter.Parse(restore.SysTableFilterRules([]string{"*.*", "!mysql.*"}, false))
+	c.Assert(err, IsNil)
+	c.Assert(f.MatchTable("db1", "t1"), IsTrue)
+	c.Assert(f.MatchSchema("mysql"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "user"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "stats_meta"), IsFalse)
+}
+
+func (s *testSysTableSuite) TestNewSysTableFilter(c *C) {
+	f := restore.NewSysTableFilter(filter.NewSchemasFilter("db1"))
+	c.Assert(f.MatchSchema("mysql"), IsTrue)
+	c.Assert(f.MatchTable("db1", "t1"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "user"), IsTrue)
+	c.Assert(f.MatchTable("mysql", "stats_meta"), IsFalse)
+	c.Assert(f.MatchTable("db2", "t1"), IsFalse)
+}
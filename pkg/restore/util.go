@@ -35,13 +35,11 @@ var (
 	quoteRegexp     = regexp.MustCompile("`(?:[^`]|``)*`")
 )
 
-// GetRewriteRules returns the rewrite rule of the new table and the old table.
-func GetRewriteRules(
-	newTable, oldTable *model.TableInfo, newTimeStamp uint64,
-) *RewriteRules {
+// getTableIDMap maps the IDs of the old table and its partitions to the new ones.
+func getTableIDMap(newTable, oldTable *model.TableInfo) map[int64]int64 {
 	tableIDs := make(map[int64]int64)
 	tableIDs[oldTable.ID] = newTable.ID
-	if oldTable.Partition != nil {
+	if oldTable.Partition != nil && newTable.Partition != nil {
 		for _, srcPart := range oldTable.Partition.Definitions {
 			for _, destPart := range newTable.Partition.Definitions {
 				if srcPart.Name == destPart.Name {
@@ -50,6 +48,14 @@ func GetRewriteRules(
 			}
 		}
 	}
+	return tableIDs
+}
+
+// GetRewriteRules returns the rewrite rule of the new table and the old table.
+func GetRewriteRules(
+	newTable, oldTable *model.TableInfo, newTimeStamp uint64,
+) *RewriteRules {
+	tableIDs := getTableIDMap(newTable, oldTable)
 	indexIDs := make(map[int64]int64)
 	for _, srcIndex := range oldTable.Indices {
 		for _, destIndex := range newTable.Indices {
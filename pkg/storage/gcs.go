@@ -142,6 +142,16 @@ func (s *gcsStorage) FileExists(ctx context.Context, name string) (bool, error)
 	return true, nil
 }
 
+// DeleteFile deletes the file in storage.
+func (s *gcsStorage) DeleteFile(ctx context.Context, name string) error {
+	object := s.objectName(name)
+	err := s.bucket.Object(object).Delete(ctx)
+	if err != nil && errors.Cause(err) != storage.ErrObjectNotExist { // nolint:errorlint
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 // Open a Reader by file path.
 func (s *gcsStorage) Open(ctx context.Context, path string) (ExternalFileReader, error) {
 	object := s.objectName(path)
@@ -77,6 +77,14 @@ func (s *HdfsStorage) FileExists(ctx context.Context, name string) (bool, error)
 	return true, nil
 }
 
+func (s *HdfsStorage) DeleteFile(ctx context.Context, name string) error {
+	path := filepath.Join(s.base, name)
+	if err := s.client.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 func (s *HdfsStorage) Open(ctx context.Context, path string) (ExternalFileReader, error) {
 	// TODO:open 路径整理 done
 	reader, err := s.client.Open(filepath.Join(s.base, path))
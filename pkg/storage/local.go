@@ -44,6 +44,15 @@ func (l *LocalStorage) FileExists(ctx context.Context, name string) (bool, error
 	return pathExists(path)
 }
 
+// DeleteFile implement ExternalStorage.DeleteFile.
+func (l *LocalStorage) DeleteFile(ctx context.Context, name string) error {
+	path := filepath.Join(l.base, name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 // WalkDir traverse all the files in a dir.
 //
 // fn is the function called for each regular file visited by WalkDir.
@@ -52,21 +52,6 @@ func WrapParseBackend(rawURL string, options *BackendOptions) (*backuppb.Storage
 	return storageBackend, nil, err
 }
 
-func WrapParseBackend(rawURL string, options *BackendOptions) (*backuppb.StorageBackend, *HdfsConfig, error) {
-	if len(rawURL) == 0 {
-		return nil, nil, errors.Annotate(berrors.ErrStorageInvalidConfig, "empty store is not allowed")
-	}
-	u, err := ParseRawURL(rawURL)
-	if err != nil {
-		return nil, nil, errors.Trace(err)
-	}
-	if u.Scheme == "hdfs" {
-		return nil, &HdfsConfig{Address: u.Host}, err
-	}
-	storageBackend, err := ParseBackend(rawURL, options)
-	return storageBackend, nil, err
-}
-
 // ParseBackend constructs a structured backend description from the
 // storage URL.
 func ParseBackend(rawURL string, options *BackendOptions) (*backuppb.StorageBackend, error) {
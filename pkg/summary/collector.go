@@ -45,7 +45,20 @@ type LogCollector interface {
 
 	SetSuccessStatus(success bool)
 
+	// CollectTable collects the result of a table, the results of the same table are merged.
+	CollectTable(result TableResult)
+
+	// CollectTS collects a resolved timestamp, e.g. the backup-ts.
+	CollectTS(name string, ts uint64)
+
+	// CollectInfo collects a descriptive field, e.g. the cluster version.
+	CollectInfo(name string, value string)
+
 	Summary(name string)
+
+	// Report returns the report of the last summary,
+	// or the collected fields so far if the summary isn't done.
+	Report() Report
 }
 
 type logFunc func(msg string, fields ...zap.Field)
@@ -84,6 +97,10 @@ type logCollector struct {
 	uints            map[string]uint64
 	successStatus    bool
 	startTime        time.Time
+	tables           map[string]*TableResult
+	tses             map[string]uint64
+	infos            map[string]string
+	lastReport       *Report
 
 	log logFunc
 }
@@ -99,6 +116,9 @@ func NewLogCollector(log logFunc) LogCollector {
 		durations:        make(map[string]time.Duration),
 		ints:             make(map[string]int),
 		uints:            make(map[string]uint64),
+		tables:           make(map[string]*TableResult),
+		tses:             make(map[string]uint64),
+		infos:            make(map[string]string),
 		log:              log,
 		startTime:        time.Now(),
 	}
@@ -162,6 +182,8 @@ func logKeyFor(key string) string {
 
 func (tc *logCollector) Summary(name string) {
 	tc.mu.Lock()
+	report := tc.buildReport(name)
+	tc.lastReport = &report
 	defer func() {
 		tc.durations = make(map[string]time.Duration)
 		tc.ints = make(map[string]int)
@@ -47,3 +47,32 @@ func (suit *testCollectorSuite) TestSumDurationInt(c *C) {
 	assertContains(zap.Duration("b", 2*time.Second))
 	assertContains(zap.Int("c", 4))
 }
+
+func (suit *testCollectorSuite) TestReport(c *C) {
+	col := NewLogCollector(func(string, ...zap.Field) {})
+	col.SetUnit(BackupUnit)
+	col.CollectInt("c", 2)
+	col.CollectDuration("d", time.Second)
+	col.CollectTS("backup-ts", 42)
+	col.CollectInfo("cluster-version", "v5.0.0")
+	col.CollectTable(TableResult{DB: "db", Table: "t2", Checksum: ChecksumSkipped})
+	col.CollectTable(TableResult{DB: "db", Table: "t1", KVs: 10, Bytes: 100})
+	col.CollectTable(TableResult{DB: "db", Table: "t1", Checksum: ChecksumMatched})
+	col.SetSuccessStatus(true)
+	col.Summary("foo")
+	// the report is kept after the summary resets the collected fields.
+	col.CollectInt("c", 1)
+
+	report := col.Report()
+	c.Assert(report.Command, Equals, "foo")
+	c.Assert(report.Unit, Equals, BackupUnit)
+	c.Assert(report.Success, IsTrue)
+	c.Assert(report.Ints, DeepEquals, map[string]int{"c": 2})
+	c.Assert(report.Durations, DeepEquals, map[string]string{"d": "1s"})
+	c.Assert(report.TSes, DeepEquals, map[string]uint64{"backup-ts": 42})
+	c.Assert(report.Infos, DeepEquals, map[string]string{"cluster-version": "v5.0.0"})
+	c.Assert(report.Tables, DeepEquals, []TableResult{
+		{DB: "db", Table: "t1", KVs: 10, Bytes: 100, Checksum: ChecksumMatched},
+		{DB: "db", Table: "t2", Checksum: ChecksumSkipped},
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package summary
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// ChecksumCalculated means the checksum of the table is calculated during backup.
+	ChecksumCalculated = "calculated"
+	// ChecksumMatched means the checksum of the restored table matches the backup.
+	ChecksumMatched = "matched"
+	// ChecksumMismatched means the checksum of the restored table doesn't match the backup.
+	ChecksumMismatched = "mismatched"
+	// ChecksumSkipped means the checksum of the table isn't calculated or validated.
+	ChecksumSkipped = "skipped"
+)
+
+// TableResult is the result of backing up or restoring a table.
+type TableResult struct {
+	DB       string `json:"db"`
+	Table    string `json:"table"`
+	KVs      uint64 `json:"kvs"`
+	Bytes    uint64 `json:"bytes"`
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// merge updates the result by the non-empty fields of another result of the same table.
+func (r *TableResult) merge(other TableResult) {
+	if other.KVs != 0 {
+		r.KVs = other.KVs
+	}
+	if other.Bytes != 0 {
+		r.Bytes = other.Bytes
+	}
+	if other.Checksum != "" {
+		r.Checksum = other.Checksum
+	}
+	if other.Error != "" {
+		r.Error = other.Error
+	}
+}
+
+// Report is the machine-readable summary of a task.
+type Report struct {
+	Command   string    `json:"command"`
+	Unit      string    `json:"unit,omitempty"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+	StartTime time.Time `json:"start-time"`
+	EndTime   time.Time `json:"end-time"`
+	TotalTake string    `json:"total-take"`
+
+	TotalRanges   int `json:"total-ranges"`
+	RangesSucceed int `json:"ranges-succeed"`
+	RangesFailed  int `json:"ranges-failed"`
+
+	// Infos are the descriptive fields, e.g. the storage URL and versions.
+	Infos map[string]string `json:"infos"`
+	// TSes are the resolved timestamps, e.g. the backup-ts.
+	TSes           map[string]uint64 `json:"tses"`
+	Durations      map[string]string `json:"durations"`
+	Ints           map[string]int    `json:"ints"`
+	Uints          map[string]uint64 `json:"uints"`
+	Data           map[string]uint64 `json:"data"`
+	FailureReasons map[string]string `json:"failure-reasons,omitempty"`
+	Tables         []TableResult     `json:"tables"`
+}
+
+// buildReport builds the report of the collected fields, the caller must hold the lock.
+func (tc *logCollector) buildReport(name string) Report {
+	now := time.Now()
+	report := Report{
+		Command:        name,
+		Unit:           tc.unit,
+		Success:        tc.successStatus && len(tc.failureReasons) == 0,
+		StartTime:      tc.startTime,
+		EndTime:        now,
+		TotalTake:      now.Sub(tc.startTime).String(),
+		TotalRanges:    tc.failureUnitCount + tc.successUnitCount,
+		RangesSucceed:  tc.successUnitCount,
+		RangesFailed:   tc.failureUnitCount,
+		Infos:          make(map[string]string, len(tc.infos)),
+		TSes:           make(map[string]uint64, len(tc.tses)),
+		Durations:      make(map[string]string, len(tc.durations)),
+		Ints:           make(map[string]int, len(tc.ints)),
+		Uints:          make(map[string]uint64, len(tc.uints)),
+		Data:           make(map[string]uint64, len(tc.successData)),
+		FailureReasons: make(map[string]string, len(tc.failureReasons)),
+		Tables:         make([]TableResult, 0, len(tc.tables)),
+	}
+	for key, val := range tc.infos {
+		report.Infos[key] = val
+	}
+	for key, val := range tc.tses {
+		report.TSes[key] = val
+	}
+	for key, val := range tc.durations {
+		report.Durations[key] = val.String()
+	}
+	for key, val := range tc.ints {
+		report.Ints[key] = val
+	}
+	for key, val := range tc.uints {
+		report.Uints[key] = val
+	}
+	for key, val := range tc.successData {
+		report.Data[key] = val
+	}
+	for key, val := range tc.failureReasons {
+		report.FailureReasons[key] = val.Error()
+	}
+	for _, table := range tc.tables {
+		report.Tables = append(report.Tables, *table)
+	}
+	sort.Slice(report.Tables, func(i, j int) bool {
+		if report.Tables[i].DB != report.Tables[j].DB {
+			return report.Tables[i].DB < report.Tables[j].DB
+		}
+		return report.Tables[i].Table < report.Tables[j].Table
+	})
+	return report
+}
+
+func (tc *logCollector) CollectTable(result TableResult) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	key := result.DB + "." + result.Table
+	if table, ok := tc.tables[key]; ok {
+		table.merge(result)
+		return
+	}
+	tc.tables[key] = &result
+}
+
+func (tc *logCollector) CollectTS(name string, ts uint64) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.tses[name] = ts
+}
+
+func (tc *logCollector) CollectInfo(name string, value string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.infos[name] = value
+}
+
+func (tc *logCollector) Report() Report {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.lastReport != nil {
+		return *tc.lastReport
+	}
+	return tc.buildReport("")
+}
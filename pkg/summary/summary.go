@@ -39,6 +39,26 @@ func SetSuccessStatus(success bool) {
 	collector.SetSuccessStatus(success)
 }
 
+// CollectTable collects the result of a table.
+func CollectTable(result TableResult) {
+	collector.CollectTable(result)
+}
+
+// CollectTS collects a resolved timestamp.
+func CollectTS(name string, ts uint64) {
+	collector.CollectTS(name, ts)
+}
+
+// CollectInfo collects a descriptive field.
+func CollectInfo(name string, value string) {
+	collector.CollectInfo(name, value)
+}
+
+// GetReport returns the report of the last summary.
+func GetReport() Report {
+	return collector.Report()
+}
+
 // Summary outputs summary log.
 func Summary(name string) {
 	collector.Summary(name)
@@ -44,6 +44,7 @@ const (
 	flagRemoveSchedulers = "remove-schedulers"
 	flagIgnoreStats      = "ignore-stats"
 	flagUseBackupMetaV2  = "use-backupmeta-v2"
+	flagWithSysTable     = "with-sys-table"
 
 	flagGCTTL = "gcttl"
 
@@ -68,6 +69,7 @@ type BackupConfig struct {
 	RemoveSchedulers bool          `json:"remove-schedulers" toml:"remove-schedulers"`
 	IgnoreStats      bool          `json:"ignore-stats" toml:"ignore-stats"`
 	UseBackupMetaV2  bool          `json:"use-backupmeta-v2"`
+	WithSysTable     bool          `json:"with-sys-table" toml:"with-sys-table"`
 	CompressionConfig
 }
 
@@ -92,13 +94,15 @@ func DefineBackupFlags(flags *pflag.FlagSet) {
 	// This flag can impact the online cluster, so hide it in case of abuse.
 	_ = flags.MarkHidden(flagRemoveSchedulers)
 
-	// Disable stats by default. because of
-	// 1. DumpStatsToJson is not stable
-	// 2. It increases memory usage and might cause BR OOM.
-	// TODO: we need a better way to backup/restore stats.
-	flags.Bool(flagIgnoreStats, true, "ignore backup stats, used for test")
-	// This flag is used for test. we should backup stats all the time.
-	_ = flags.MarkHidden(flagIgnoreStats)
+	// Disable stats by default, because backing up stats needs the domain,
+	// which loads all table info into memory.
+	// The stats of each table are dumped and written as a separate metafile,
+	// so the memory used by stats is bounded to a few tables.
+	flags.Bool(flagIgnoreStats, true,
+		"ignore backup stats, set it to false to backup the stats of each table as a separate metafile")
+
+	flags.Bool(flagWithSysTable, false,
+		"backup the users, privileges, bindings and global variables in the `mysql` schema")
 
 	flags.Bool(flagUseBackupMetaV2, false,
 		"use backup meta v2 to store meta info")
@@ -157,6 +161,15 @@ func (cfg *BackupConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.WithSysTable, err = flags.GetBool(flagWithSysTable)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.WithSysTable {
+		if err = cfg.parseSysTableFilter(flags); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	cfg.UseBackupMetaV2, err = flags.GetBool(flagUseBackupMetaV2)
 	return errors.Trace(err)
 }
@@ -274,6 +287,10 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 		return errors.Trace(err)
 	}
 	g.Record("BackupTS", backupTS)
+	summary.CollectTS("backup-ts", backupTS)
+	if cfg.LastBackupTS > 0 {
+		summary.CollectTS("last-backup-ts", cfg.LastBackupTS)
+	}
 	sp := utils.BRServiceSafePoint{
 		BackupTS: backupTS,
 		TTL:      client.GetGCTTL(),
@@ -323,6 +340,7 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	if err != nil {
 		return errors.Trace(err)
 	}
+	summary.CollectInfo("cluster-version", clusterVersion)
 
 	ranges, schemas, err := backup.BuildBackupRangeAndSchema(mgr.GetStorage(), cfg.TableFilter, backupTS)
 	if err != nil {
@@ -464,6 +482,12 @@ func RunBackup(c context.Context, g glue.Glue, cmdName string, cfg *BackupConfig
 	// Checksum has finished, close checksum progress.
 	updateCh.Close()
 
+	// Placement rules are optional for restore, so failing to save them doesn't fail the backup.
+	if err = backup.BackupPlacement(
+		ctx, metawriter, cfg.PD, mgr.GetTLSConfig(), schemas.TableIDs()); err != nil {
+		log.Warn("failed to backup placement rules, they won't be restored", zap.Error(err))
+	}
+
 	if !skipChecksum {
 		// Check if checksum from files matches checksum from coprocessor.
 		err = checksum.FastChecksum(ctx, metawriter.Backupmeta(), client.GetStorage())
@@ -29,6 +29,7 @@ import (
 	"github.com/pingcap/br/pkg/conn"
 	berrors "github.com/pingcap/br/pkg/errors"
 	"github.com/pingcap/br/pkg/glue"
+	"github.com/pingcap/br/pkg/restore"
 	"github.com/pingcap/br/pkg/storage"
 	"github.com/pingcap/br/pkg/utils"
 )
@@ -69,6 +70,7 @@ const (
 	// flagEnableOpenTracing is whether to enable opentracing
 	flagEnableOpenTracing = "enable-opentracing"
 	flagSkipCheckPath     = "skip-check-path"
+	flagReportFile        = "report-file"
 
 	defaultSwitchInterval       = 5 * time.Minute
 	defaultGRPCKeepaliveTime    = 10 * time.Second
@@ -150,6 +152,9 @@ type Config struct {
 	GRPCKeepaliveTime time.Duration `json:"grpc-keepalive-time" toml:"grpc-keepalive-time"`
 	// GrpcKeepaliveTimeout is the max time a grpc conn can keep idel before killed.
 	GRPCKeepaliveTimeout time.Duration `json:"grpc-keepalive-timeout" toml:"grpc-keepalive-timeout"`
+
+	// ReportFile is the local path or storage URL where the JSON task report is written.
+	ReportFile string `json:"report-file" toml:"report-file"`
 }
 
 // DefineCommonFlags defines the flags common to all BRIE commands.
@@ -197,6 +202,9 @@ func DefineCommonFlags(flags *pflag.FlagSet) {
 	flags.BoolP(flagSkipCheckPath, "", false, "Skip path verification")
 	_ = flags.MarkHidden(flagSkipCheckPath)
 
+	flags.String(flagReportFile, "",
+		"write a JSON report of the task to this local path or storage URL, even if the task fails")
+
 	storage.DefineFlags(flags)
 }
 
@@ -276,6 +284,9 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	if cfg.ChecksumConcurrency, err = flags.GetUint(flagChecksumConcurrency); err != nil {
 		return errors.Trace(err)
 	}
+	if cfg.ReportFile, err = flags.GetString(flagReportFile); err != nil {
+		return errors.Trace(err)
+	}
 
 	var rateLimit, rateLimitUnit uint64
 	if rateLimit, err = flags.GetUint64(flagRateLimit); err != nil {
@@ -371,6 +382,30 @@ func (cfg *Config) ParseFromFlags(flags *pflag.FlagSet) error {
 	return cfg.normalizePDURLs()
 }
 
+// parseSysTableFilter makes the table filter match the privilege tables in the `mysql` schema
+// as well, for the `--with-sys-table` mode.
+func (cfg *Config) parseSysTableFilter(flags *pflag.FlagSet) error {
+	filterFlag := flags.Lookup(flagFilter)
+	if filterFlag == nil {
+		cfg.TableFilter = restore.NewSysTableFilter(cfg.TableFilter)
+		return nil
+	}
+	rules := restore.SysTableFilterRules(filterFlag.Value.(pflag.SliceValue).GetSlice(), filterFlag.Changed)
+	f, err := filter.Parse(rules)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	caseSensitive, err := flags.GetBool(flagCaseSensitive)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !caseSensitive {
+		f = filter.CaseInsensitive(f)
+	}
+	cfg.TableFilter = f
+	return nil
+}
+
 // NewMgr creates a new mgr at the given PD address.
 func NewMgr(ctx context.Context,
 	g glue.Glue, pds []string,
@@ -476,16 +511,26 @@ func ReadBackupMeta(
 	return u, s, backupMeta, nil
 }
 
+// redactStorageURL hides the query and user info of the storage URL, which may contain credentials.
+func redactStorageURL(rawURL string) (*url.URL, error) {
+	hiddenQuery, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// hide all query and user info here.
+	hiddenQuery.RawQuery = ""
+	hiddenQuery.User = nil
+	return hiddenQuery, nil
+}
+
 // flagToZapField checks whether this flag can be logged,
 // if need to log, return its zap field. Or return a field with hidden value.
 func flagToZapField(f *pflag.Flag) zap.Field {
 	if f.Name == flagStorage {
-		hiddenQuery, err := url.Parse(f.Value.String())
+		hiddenQuery, err := redactStorageURL(f.Value.String())
 		if err != nil {
 			return zap.String(f.Name, "<invalid URI>")
 		}
-		// hide all query here.
-		hiddenQuery.RawQuery = ""
 		return zap.Stringer(f.Name, hiddenQuery)
 	}
 	return zap.Stringer(f.Name, f.Value)
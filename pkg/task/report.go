@@ -0,0 +1,86 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+
+	"github.com/pingcap/br/pkg/storage"
+	"github.com/pingcap/br/pkg/summary"
+	"github.com/pingcap/br/pkg/version/build"
+)
+
+// splitReportPath splits the report path into the storage URL of its directory and the file name.
+func splitReportPath(reportPath string) (string, string) {
+	idx := strings.LastIndex(reportPath, "/")
+	if idx < 0 {
+		return ".", reportPath
+	}
+	dir := reportPath[:idx]
+	if dir == "" || strings.HasSuffix(dir, ":/") {
+		// `/report.json` or `s3://report.json` is at the root.
+		dir += "/"
+	}
+	return dir, reportPath[idx+1:]
+}
+
+// BuildReport builds the report of the task from the collected summary.
+func BuildReport(cfg *Config, cmdName string, taskErr error) summary.Report {
+	report := summary.GetReport()
+	report.Command = cmdName
+	if taskErr != nil {
+		report.Success = false
+		report.Error = taskErr.Error()
+	}
+	// don't modify the infos shared with the collector.
+	infos := make(map[string]string, len(report.Infos)+3)
+	for key, val := range report.Infos {
+		infos[key] = val
+	}
+	report.Infos = infos
+	report.Infos["storage"] = "<invalid URI>"
+	if u, err := redactStorageURL(cfg.Storage); err == nil {
+		report.Infos["storage"] = u.String()
+	}
+	report.Infos["br-version"] = build.ReleaseVersion
+	report.Infos["br-git-hash"] = build.GitHash
+	return report
+}
+
+// WriteReport writes the JSON report of the task to cfg.ReportFile if it is set.
+// Failing to write the report is logged only, so the result of the task is kept.
+func WriteReport(ctx context.Context, cfg *Config, cmdName string, taskErr error) {
+	if len(cfg.ReportFile) == 0 {
+		return
+	}
+	if err := writeReport(ctx, cfg, BuildReport(cfg, cmdName, taskErr)); err != nil {
+		log.Warn("failed to write the task report", zap.String("path", cfg.ReportFile), zap.Error(err))
+		return
+	}
+	log.Info("task report written", zap.String("path", cfg.ReportFile))
+}
+
+func writeReport(ctx context.Context, cfg *Config, report summary.Report) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	dir, name := splitReportPath(cfg.ReportFile)
+	u, err := storage.ParseBackend(dir, &cfg.BackendOptions)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s, err := storage.New(ctx, u, &storage.ExternalStorageOptions{
+		NoCredentials: cfg.NoCreds,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.WriteFile(ctx, name, content))
+}
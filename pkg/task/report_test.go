@@ -0,0 +1,62 @@
+// Copyright 2021 PingCAP, Inc. Licensed under Apache-2.0.
+
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/pingcap/check"
+	"github.com/pingcap/errors"
+
+	"github.com/pingcap/br/pkg/summary"
+)
+
+var _ = Suite(&testReportSuite{})
+
+type testReportSuite struct{}
+
+func (*testReportSuite) TestSplitReportPath(c *C) {
+	cases := []struct {
+		path string
+		dir  string
+		name string
+	}{
+		{"report.json", ".", "report.json"},
+		{"/report.json", "/", "report.json"},
+		{"/tmp/br/report.json", "/tmp/br", "report.json"},
+		{"s3://bucket/report.json", "s3://bucket", "report.json"},
+		{"local:///tmp/report.json", "local:///tmp", "report.json"},
+	}
+	for _, ca := range cases {
+		dir, name := splitReportPath(ca.path)
+		c.Assert(dir, Equals, ca.dir, Commentf("path %s", ca.path))
+		c.Assert(name, Equals, ca.name, Commentf("path %s", ca.path))
+	}
+}
+
+func (*testReportSuite) TestWriteReport(c *C) {
+	summary.SetUnit(summary.RestoreUnit)
+	summary.CollectTable(summary.TableResult{DB: "db", Table: "t", KVs: 1, Checksum: summary.ChecksumMatched})
+
+	path := filepath.Join(c.MkDir(), "br", "report.json")
+	cfg := &Config{
+		Storage:    "s3://bucket/prefix?access-key=secret",
+		ReportFile: path,
+	}
+	WriteReport(context.Background(), cfg, "Full restore", errors.New("restore failed"))
+
+	content, err := os.ReadFile(path)
+	c.Assert(err, IsNil)
+	report := summary.Report{}
+	c.Assert(json.Unmarshal(content, &report), IsNil)
+	c.Assert(report.Command, Equals, "Full restore")
+	c.Assert(report.Success, IsFalse)
+	c.Assert(report.Error, Equals, "restore failed")
+	c.Assert(report.Infos["storage"], Equals, "s3://bucket/prefix")
+	c.Assert(report.Tables, DeepEquals, []summary.TableResult{
+		{DB: "db", Table: "t", KVs: 1, Checksum: summary.ChecksumMatched},
+	})
+}
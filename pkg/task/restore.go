@@ -4,6 +4,7 @@ package task
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/pingcap/br/pkg/metautil"
@@ -31,8 +32,16 @@ import (
 )
 
 const (
-	flagOnline   = "online"
-	flagNoSchema = "no-schema"
+	flagOnline              = "online"
+	flagNoSchema            = "no-schema"
+	flagAnalyzeMissingStats = "analyze-missing-stats"
+	flagSysTableConflict    = "sys-table-conflict"
+	flagWithPlacement       = "with-placement"
+	flagPlacementLabelMap   = "placement-label-mapping"
+	flagPlacementPreview    = "placement-preview"
+	flagTablePriority       = "table-priority"
+	flagTablePriorityFile   = "table-priority-file"
+	flagStrictPriority      = "strict-priority"
 
 	// FlagMergeRegionSizeBytes is the flag name of merge small regions by size
 	FlagMergeRegionSizeBytes = "merge-region-size-bytes"
@@ -103,6 +112,19 @@ type RestoreConfig struct {
 	RestoreCommonConfig
 
 	NoSchema bool `json:"no-schema" toml:"no-schema"`
+	// AnalyzeMissingStats analyzes the tables whose stats are missing or stale after restore.
+	AnalyzeMissingStats bool `json:"analyze-missing-stats" toml:"analyze-missing-stats"`
+	// WithSysTable restores the users, privileges, bindings and global variables in the `mysql` schema.
+	WithSysTable     bool                           `json:"with-sys-table" toml:"with-sys-table"`
+	SysTableConflict restore.SysTableConflictPolicy `json:"sys-table-conflict" toml:"sys-table-conflict"`
+	// WithPlacement restores the placement rules, table attributes and TiFlash replicas of the tables.
+	WithPlacement         bool   `json:"with-placement" toml:"with-placement"`
+	PlacementLabelMapping string `json:"placement-label-mapping" toml:"placement-label-mapping"`
+	PlacementPreview      bool   `json:"placement-preview" toml:"placement-preview"`
+	// TablePriority is the ordered groups of table filter rules, the tables of former groups are restored earlier.
+	TablePriority [][]string `json:"table-priority" toml:"table-priority"`
+	// StrictPriority restores a group of tables only after the former groups are fully restored.
+	StrictPriority bool `json:"strict-priority" toml:"strict-priority"`
 }
 
 // DefineRestoreFlags defines common flags for the restore tidb command.
@@ -110,6 +132,26 @@ func DefineRestoreFlags(flags *pflag.FlagSet) {
 	flags.Bool(flagNoSchema, false, "skip creating schemas and tables, reuse existing empty ones")
 	// Do not expose this flag
 	_ = flags.MarkHidden(flagNoSchema)
+	flags.Bool(flagAnalyzeMissingStats, false,
+		"analyze the tables whose stats are missing or stale in the backup after restore, "+
+			"it may take a long time for large tables")
+	flags.Bool(flagWithSysTable, false,
+		"restore the users, privileges, bindings and global variables in the `mysql` schema")
+	flags.String(flagSysTableConflict, string(restore.SysTableConflictReplace),
+		"how to handle the system table rows conflicting with existing ones, value can be one of 'skip|replace|error'")
+	flags.Bool(flagWithPlacement, false,
+		"restore the placement rules, table attributes and TiFlash replicas of the tables")
+	flags.String(flagPlacementLabelMap, "",
+		"a JSON file translating the label values of placement rules, e.g. {\"zone\": {\"old-zone\": \"new-zone\"}}")
+	flags.Bool(flagPlacementPreview, false,
+		"print the placement rules and TiFlash replicas to be restored, and exit without restoring anything")
+	flags.String(flagTablePriority, "",
+		"the tables to restore first, groups of tables are separated by ';' and the table filter rules "+
+			"in a group are separated by ',', e.g. 'db.orders,db.users;db.*'")
+	flags.String(flagTablePriorityFile, "",
+		"a file of the tables to restore first, each line is a group of ',' separated table filter rules")
+	flags.Bool(flagStrictPriority, false,
+		"restore a group of tables only after all tables of the former groups are restored")
 
 	DefineRestoreCommonFlags(flags)
 }
@@ -121,10 +163,62 @@ func (cfg *RestoreConfig) ParseFromFlags(flags *pflag.FlagSet) error {
 	if err != nil {
 		return errors.Trace(err)
 	}
+	cfg.AnalyzeMissingStats, err = flags.GetBool(flagAnalyzeMissingStats)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.WithSysTable, err = flags.GetBool(flagWithSysTable)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	conflict, err := flags.GetString(flagSysTableConflict)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cfg.SysTableConflict, err = restore.ParseSysTableConflictPolicy(conflict); err != nil {
+		return errors.Trace(err)
+	}
+	cfg.WithPlacement, err = flags.GetBool(flagWithPlacement)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.PlacementLabelMapping, err = flags.GetString(flagPlacementLabelMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.PlacementPreview, err = flags.GetBool(flagPlacementPreview)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	priority, err := flags.GetString(flagTablePriority)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cfg.TablePriority = restore.ParseTablePriorityFlag(priority)
+	priorityFile, err := flags.GetString(flagTablePriorityFile)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(priorityFile) > 0 {
+		groups, err := restore.LoadTablePriorityFile(priorityFile)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		cfg.TablePriority = append(cfg.TablePriority, groups...)
+	}
+	cfg.StrictPriority, err = flags.GetBool(flagStrictPriority)
+	if err != nil {
+		return errors.Trace(err)
+	}
 	err = cfg.Config.ParseFromFlags(flags)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if cfg.WithSysTable {
+		if err = cfg.parseSysTableFilter(flags); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	err = cfg.RestoreCommonConfig.ParseFromFlags(flags)
 	if err != nil {
 		return errors.Trace(err)
@@ -208,6 +302,9 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return errors.Trace(err)
 	}
 	defer mgr.Close()
+	if clusterVersion, err := mgr.GetClusterVersion(ctx); err == nil {
+		summary.CollectInfo("cluster-version", clusterVersion)
+	}
 
 	keepaliveCfg := GetKeepalive(&cfg.Config)
 	keepaliveCfg.PermitWithoutStream = true
@@ -237,6 +334,24 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	if cfg.NoSchema {
 		client.EnableSkipCreateSQL()
 	}
+	if cfg.AnalyzeMissingStats {
+		client.EnableAnalyzeMissingStats()
+	}
+	if cfg.WithSysTable {
+		client.EnableSysTable(cfg.SysTableConflict)
+	}
+	if cfg.WithPlacement && !cfg.PlacementPreview {
+		if err = client.EnableTiFlashReplicaRestore(ctx, g, mgr.GetStorage()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	var priority *restore.TablePriority
+	if len(cfg.TablePriority) > 0 {
+		if priority, err = restore.ParseTablePriority(cfg.TablePriority); err != nil {
+			return errors.Trace(err)
+		}
+		client.SetTablePriority(priority)
+	}
 	client.SetSwitchModeInterval(cfg.SwitchModeInterval)
 	err = client.LoadRestoreStores(ctx)
 	if err != nil {
@@ -247,6 +362,9 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	if err != nil {
 		return errors.Trace(err)
 	}
+	summary.CollectTS("backup-ts", backupMeta.EndVersion)
+	summary.CollectInfo("backup-cluster-version", backupMeta.ClusterVersion)
+	summary.CollectInfo("backup-br-version", backupMeta.BrVersion)
 	backupVersion := version.NormalizeBackupVersion(backupMeta.ClusterVersion)
 	if cfg.CheckRequirements && backupVersion != nil {
 		if versionErr := version.CheckClusterVersion(ctx, mgr.GetPDClient(), version.CheckVersionForBackup(backupVersion)); versionErr != nil {
@@ -265,15 +383,26 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		return err
 	}
 	files, tables, dbs := filterRestoreFiles(client, cfg)
+	// the tables are created and restored in priority order.
+	priority.Sort(tables)
 	if len(dbs) == 0 && len(tables) != 0 {
 		return errors.Annotate(berrors.ErrRestoreInvalidBackup, "contain tables but no databases")
 	}
+	if cfg.WithPlacement && cfg.PlacementPreview {
+		// the preview exits before creating any table or writing any data.
+		if err = previewPlacement(ctx, client, mgr, cfg, tables); err != nil {
+			return errors.Trace(err)
+		}
+		summary.SetSuccessStatus(true)
+		return nil
+	}
 	archiveSize := reader.ArchiveSize(ctx, files)
 	g.Record(summary.RestoreDataSize, archiveSize)
 	restoreTS, err := client.GetTS(ctx)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	summary.CollectTS("restore-ts", restoreTS)
 
 	sp := utils.BRServiceSafePoint{
 		BackupTS: restoreTS,
@@ -329,8 +458,6 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		}
 	}
 
-	// We make bigger errCh so we won't block on multi-part failed.
-	errCh := make(chan error, 32)
 	// Maybe allow user modify the DDL concurrency isn't necessary,
 	// because executing DDL is really I/O bound (or, algorithm bound?),
 	// and we cost most of time at waiting DDL jobs be enqueued.
@@ -350,7 +477,6 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 			zap.Int("sessionCount", len(dbPool)),
 		)
 	}
-	tableStream := client.GoCreateTables(ctx, mgr.GetDomain(), tables, newTS, dbPool, errCh)
 	if len(files) == 0 {
 		log.Info("no files, empty databases and tables are restored")
 		summary.SetSuccessStatus(true)
@@ -360,9 +486,6 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 	tableFileMap := restore.MapTableToFiles(files)
 	log.Debug("mapped table to files", zap.Any("result map", tableFileMap))
 
-	rangeStream := restore.GoValidateFileRanges(
-		ctx, tableStream, tableFileMap, cfg.MergeSmallRegionKeyCount, cfg.MergeSmallRegionKeyCount, errCh)
-
 	rangeSize := restore.EstimateRangeSize(files)
 	summary.CollectInt("restore ranges", rangeSize)
 	log.Info("range and file prepared", zap.Int("file count", len(files)), zap.Int("range count", rangeSize))
@@ -399,6 +522,61 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		int64(rangeSize+len(files)+len(tables)),
 		!cfg.LogProgress)
 	defer updateCh.Close()
+
+	// In strict priority mode, a group of tables is restored only after the former groups are done,
+	// otherwise all tables are restored in one pipeline, where the tables of high priority come first.
+	groups := [][]*metautil.Table{tables}
+	if cfg.StrictPriority {
+		groups = priority.Group(tables)
+	}
+	for i, group := range groups {
+		log.Info("start restoring table group", zap.Int("group", i), zap.Int("table count", len(group)))
+		err = restoreTableGroup(ctx, client, mgr, cfg, group, tableFileMap, newTS, dbPool, batchSize, updateCh)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// Analyze the tables whose stats are missing or stale in the backup.
+	client.AnalyzeQueuedTables(ctx, dbPool)
+
+	if cfg.WithPlacement {
+		if err = restorePlacement(ctx, client, mgr, cfg, tables); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// The cost of rename user table / replace into system table wouldn't be so high.
+	// So leave it out of the pipeline for easier implementation.
+	if err = client.RestoreSystemSchemas(ctx, cfg.TableFilter); err != nil {
+		return errors.Trace(err)
+	}
+
+	// Set task summary to success status.
+	summary.SetSuccessStatus(true)
+	return nil
+}
+
+// restoreTableGroup creates the tables and restores their data in a pipeline,
+// and returns after all the tables are restored.
+func restoreTableGroup(
+	ctx context.Context,
+	client *restore.Client,
+	mgr *conn.Mgr,
+	cfg *RestoreConfig,
+	tables []*metautil.Table,
+	tableFileMap map[int64][]*backuppb.File,
+	newTS uint64,
+	dbPool []*restore.DB,
+	batchSize int,
+	updateCh glue.Progress,
+) error {
+	// We make bigger errCh so we won't block on multi-part failed.
+	errCh := make(chan error, 32)
+	tableStream := client.GoCreateTables(ctx, mgr.GetDomain(), tables, newTS, dbPool, errCh)
+	rangeStream := restore.GoValidateFileRanges(
+		ctx, tableStream, tableFileMap, cfg.MergeSmallRegionKeyCount, cfg.MergeSmallRegionKeyCount, errCh)
+
 	sender, err := restore.NewTiKVSender(ctx, client, updateCh)
 	if err != nil {
 		return errors.Trace(err)
@@ -416,7 +594,7 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 			ctx, afterRestoreStream, mgr.GetStorage().GetClient(), errCh, updateCh, cfg.ChecksumConcurrency)
 	} else {
 		// when user skip checksum, just collect tables, and drop them.
-		finish = dropToBlackhole(ctx, afterRestoreStream, errCh, updateCh)
+		finish = dropToBlackhole(ctx, client, afterRestoreStream, errCh, updateCh)
 	}
 
 	select {
@@ -424,25 +602,56 @@ func RunRestore(c context.Context, g glue.Glue, cmdName string, cfg *RestoreConf
 		err = multierr.Append(err, multierr.Combine(restore.Exhaust(errCh)...))
 	case <-finish:
 	}
+	return errors.Trace(err)
+}
 
-	// If any error happened, return now.
+// restorePlacement re-applies the backed up placement rules, table attributes
+// and TiFlash replicas to the restored tables.
+func restorePlacement(
+	ctx context.Context,
+	client *restore.Client,
+	mgr *conn.Mgr,
+	cfg *RestoreConfig,
+	tables []*metautil.Table,
+) error {
+	mapping, err := restore.LoadPlacementLabelMapping(cfg.PlacementLabelMapping)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	plan, err := client.PlanPlacement(ctx, mgr.GetDomain(), tables, mapping, false)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(client.RestorePlacement(ctx, cfg.PD, plan))
+}
 
-	// The cost of rename user table / replace into system table wouldn't be so high.
-	// So leave it out of the pipeline for easier implementation.
-	client.RestoreSystemSchemas(ctx, cfg.TableFilter)
-
-	// Set task summary to success status.
-	summary.SetSuccessStatus(true)
+// previewPlacement prints the placement rules, table attributes and TiFlash replicas
+// to be restored, the tables not created yet keep the backed up table IDs.
+func previewPlacement(
+	ctx context.Context,
+	client *restore.Client,
+	mgr *conn.Mgr,
+	cfg *RestoreConfig,
+	tables []*metautil.Table,
+) error {
+	mapping, err := restore.LoadPlacementLabelMapping(cfg.PlacementLabelMapping)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	plan, err := client.PlanPlacement(ctx, mgr.GetDomain(), tables, mapping, true)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// the preview is for human, so print it to stdout rather than the log file.
+	fmt.Println(plan)
 	return nil
 }
 
 // dropToBlackhole drop all incoming tables into black hole,
-// i.e. don't execute checksum, just increase the process anyhow.
+// i.e. don't execute checksum, just restore the stats and increase the process anyhow.
 func dropToBlackhole(
 	ctx context.Context,
+	client *restore.Client,
 	tableStream <-chan restore.CreatedTable,
 	errCh chan<- error,
 	updateCh glue.Progress,
@@ -457,10 +666,12 @@ func dropToBlackhole(
 			case <-ctx.Done():
 				errCh <- ctx.Err()
 				return
-			case _, ok := <-tableStream:
+			case tbl, ok := <-tableStream:
 				if !ok {
 					return
 				}
+				restore.CollectTableResult(tbl, summary.ChecksumSkipped, nil)
+				client.OnTableRestored(ctx, tbl)
 				updateCh.Inc()
 			}
 		}
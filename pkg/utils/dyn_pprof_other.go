@@ -3,9 +3,13 @@
 
 package utils
 
-import tidbutils "github.com/pingcap/tidb-tools/pkg/utils"
+import (
+	tidbutils "github.com/pingcap/tidb-tools/pkg/utils"
+
+	"github.com/pingcap/br/pkg/httputil"
+)
 
 // StartDynamicPProfListener starts the listener that will enable pprof when received `startPProfSignal`
-func StartDynamicPProfListener(tls *tidbutils.TLS) {
+func StartDynamicPProfListener(tls *tidbutils.TLS, auth *httputil.AuthConfig) {
 	// nothing to do on no posix signal supporting systems.
 }
@@ -10,6 +10,8 @@ import (
 
 	tidbutils "github.com/pingcap/tidb-tools/pkg/utils"
 
+	"github.com/pingcap/br/pkg/httputil"
+
 	"github.com/pingcap/log"
 	"go.uber.org/zap"
 )
@@ -17,14 +19,14 @@ import (
 const startPProfSignal = syscall.SIGUSR1
 
 // StartDynamicPProfListener starts the listener that will enable pprof when received `startPProfSignal`.
-func StartDynamicPProfListener(tls *tidbutils.TLS) {
+func StartDynamicPProfListener(tls *tidbutils.TLS, auth *httputil.AuthConfig) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, startPProfSignal)
 	go func() {
 		for sig := range signalChan {
 			if sig == startPProfSignal {
 				log.Info("signal received, starting pprof...", zap.Stringer("signal", sig))
-				if err := StartPProfListener("0.0.0.0:0", tls); err != nil {
+				if err := StartPProfListener("0.0.0.0:0", tls, auth); err != nil {
 					log.Warn("failed to start pprof", zap.Error(err))
 					return
 				}
@@ -3,6 +3,7 @@
 package utils
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	tidbutils "github.com/pingcap/tidb-tools/pkg/utils"
 
 	berrors "github.com/pingcap/br/pkg/errors"
+	"github.com/pingcap/br/pkg/httputil"
 
 	"github.com/pingcap/errors"
 
@@ -53,14 +55,25 @@ func listen(statusAddr string) (net.Listener, error) {
 }
 
 // StartPProfListener forks a new goroutine listening on specified port and provide pprof info.
-func StartPProfListener(statusAddr string, wrapper *tidbutils.TLS) error {
+// The requests are authorized by auth, which is optional.
+func StartPProfListener(statusAddr string, wrapper *tidbutils.TLS, auth *httputil.AuthConfig) error {
+	if auth == nil {
+		auth = &httputil.AuthConfig{}
+	}
+	if err := auth.Validate(wrapper.TLSConfig() != nil); err != nil {
+		return errors.Trace(err)
+	}
 	listener, err := listen(statusAddr)
 	if err != nil {
 		return err
 	}
+	if tlsConfig := auth.ServerTLSConfig(wrapper.TLSConfig()); tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	handler := httputil.NewAuthorizer(auth).Wrap(http.DefaultServeMux, httputil.PProfRole)
 
 	go func() {
-		if e := http.Serve(wrapper.WrapListener(listener), nil); e != nil {
+		if e := http.Serve(listener, handler); e != nil {
 			log.Warn("failed to serve pprof", zap.String("addr", startedPProf), zap.Error(e))
 			mu.Lock()
 			startedPProf = ""
@@ -36,6 +36,7 @@ export enum CheckpointStatus {
     Imported = 120,
     IndexImported = 140,
     AlteredAutoInc = 150,
+    DupeResolved = 160,
     ChecksumSkipped = 170,
     Checksummed = 180,
     AnalyzeSkipped = 200,
@@ -47,6 +48,7 @@ export enum CheckpointStatus {
     ImportErrored = 12,
     IndexImportErrored = 14,
     AlterAutoIncErrored = 15,
+    DupeResolveErrored = 16,
     ChecksumErrored = 18,
     AnalyzeErrored = 21,
 }
@@ -138,6 +140,8 @@ export function labelOfCheckpointStatus(status: CheckpointStatus): string {
         case CheckpointStatus.IndexImported:
             return "index imported";
         case CheckpointStatus.AlteredAutoInc:
+            return "resolving duplicates";
+        case CheckpointStatus.DupeResolved:
             return "doing checksum";
         case CheckpointStatus.Checksummed:
         case CheckpointStatus.ChecksumSkipped:
@@ -158,6 +162,8 @@ export function labelOfCheckpointStatus(status: CheckpointStatus): string {
             return "index importing (errored)";
         case CheckpointStatus.AlterAutoIncErrored:
             return "alter auto inc (errored)";
+        case CheckpointStatus.DupeResolveErrored:
+            return "resolving duplicates (errored)";
         case CheckpointStatus.ChecksumErrored:
             return "checksum (errored)";
         case CheckpointStatus.AnalyzeErrored:
@@ -191,6 +197,8 @@ export function stepOfCheckpointStatus(status: CheckpointStatus): number {
         case CheckpointStatus.AlterAutoIncErrored:
             return 5;
         case CheckpointStatus.AlteredAutoInc:
+        case CheckpointStatus.DupeResolved:
+        case CheckpointStatus.DupeResolveErrored:
         case CheckpointStatus.ChecksumErrored:
             return 6;
         case CheckpointStatus.Checksummed:
@@ -225,6 +233,36 @@ export async function submitTask(taskCfg: string): Promise<void> {
     throw err.error;
 }
 
+export interface CheckResult {
+    type: 'critical' | 'performance'
+    passed: boolean
+    message: string
+}
+
+export interface TablePreview {
+    db: string
+    table: string
+    columns: string[]
+    rows: (string | null)[][]
+}
+
+export interface PreCheckResult {
+    success: boolean
+    checks: CheckResult[]
+    'source-size': number
+    'estimated-size': number
+    tables: TablePreview[] | null
+}
+
+export async function preCheckTask(taskCfg: string, rows: number): Promise<PreCheckResult> {
+    const resp = await fetch('../precheck?rows=' + rows, { method: 'POST', body: taskCfg });
+    const res = await resp.json();
+    if (resp.ok) {
+        return res;
+    }
+    throw res.error;
+}
+
 export async function fetchPaused(): Promise<boolean> {
     const resp = await fetch('../pause');
     const res = await resp.json();